@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Artists link their Spotify account and name the Spotify artist that is
+// theirs; the artist ID is stored on the profile for catalog import and
+// verification. The app runs Spotify's authorization (optionally with PKCE)
+// and posts the code here; the tokens are kept for those later jobs.
+const (
+	providerSpotify = "spotify"
+	spotifyTokenURL = "https://accounts.spotify.com/api/token"
+	spotifyAPIURL   = "https://api.spotify.com/v1"
+)
+
+var (
+	spotifyHTTP            = &http.Client{Timeout: 10 * time.Second}
+	spotifyArtistIDPattern = regexp.MustCompile(`^[0-9A-Za-z]{22}$`)
+	errSpotifyNotFound     = errors.New("not found on spotify")
+)
+
+type spotifyTokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// parseSpotifyArtistID accepts a bare ID, a spotify:artist: URI, or an
+// open.spotify.com artist URL.
+func parseSpotifyArtistID(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if id, ok := strings.CutPrefix(raw, "spotify:artist:"); ok {
+		raw = id
+	} else if u, err := url.Parse(raw); err == nil && u.Host == "open.spotify.com" {
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		// Localized links look like /intl-de/artist/<id>.
+		if len(parts) >= 2 && parts[len(parts)-2] == "artist" {
+			raw = parts[len(parts)-1]
+		}
+	}
+	return raw, spotifyArtistIDPattern.MatchString(raw)
+}
+
+func spotifyConfigured() bool {
+	return cfg.SpotifyClientID != "" && cfg.SpotifyClientSecret != "" && cfg.SpotifyRedirectURL != ""
+}
+
+// exchangeSpotifyCode trades an authorization code for tokens. A rejected
+// code is errGrantRejected.
+func exchangeSpotifyCode(ctx context.Context, code, verifier string) (spotifyTokens, error) {
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {cfg.SpotifyRedirectURL},
+	}
+	if verifier != "" {
+		form.Set("code_verifier", verifier)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, spotifyTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return spotifyTokens{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(cfg.SpotifyClientID, cfg.SpotifyClientSecret)
+
+	resp, err := spotifyHTTP.Do(req)
+	if err != nil {
+		return spotifyTokens{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		return spotifyTokens{}, errGrantRejected
+	}
+	if resp.StatusCode != http.StatusOK {
+		return spotifyTokens{}, fmt.Errorf("spotify token endpoint returned %d", resp.StatusCode)
+	}
+
+	var t spotifyTokens
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&t); err != nil {
+		return spotifyTokens{}, err
+	}
+	if t.AccessToken == "" {
+		return spotifyTokens{}, errors.New("spotify returned no access token")
+	}
+	return t, nil
+}
+
+// spotifyGet calls the Web API and decodes the JSON response into out.
+func spotifyGet(ctx context.Context, accessToken, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, spotifyAPIURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := spotifyHTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusBadRequest {
+		return errSpotifyNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("spotify returned %d", resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+// RegisterAccountLinkRoutes defines /auth/link and /auth/links.
+func RegisterAccountLinkRoutes(r *gin.Engine) {
+	// POST /auth/link/spotify — {"code":"...","code_verifier":"...","artist_id":"spotify:artist:..."}
+	r.POST("/auth/link/spotify", RequireAuth(), func(c *gin.Context) {
+		if !spotifyConfigured() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "spotify is not configured"})
+			return
+		}
+		var body struct {
+			Code         string `json:"code"`
+			CodeVerifier string `json:"code_verifier"`
+			ArtistID     string `json:"artist_id"`
+		}
+		if err := c.BindJSON(&body); err != nil || body.Code == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+			return
+		}
+		artistID, ok := parseSpotifyArtistID(body.ArtistID)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "artist_id must be a Spotify artist ID, URI, or URL"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		tokens, err := exchangeSpotifyCode(ctx, body.Code, body.CodeVerifier)
+		if errors.Is(err, errGrantRejected) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "spotify rejected the authorization code", "code": "invalid_grant"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+
+		var me struct {
+			ID          string `json:"id"`
+			DisplayName string `json:"display_name"`
+		}
+		if err := spotifyGet(ctx, tokens.AccessToken, "/me", &me); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		var artist struct {
+			Name string `json:"name"`
+		}
+		err = spotifyGet(ctx, tokens.AccessToken, "/artists/"+artistID, &artist)
+		if errors.Is(err, errSpotifyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "spotify artist not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		tag, err := tx.Exec(ctx,
+			`UPDATE profiles SET spotify_artist_id = $2 WHERE id = $1;`, currentUserID(c), artistID)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				c.JSON(http.StatusConflict, gin.H{"error": "that spotify artist is already linked to another profile"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "profile not found"})
+			return
+		}
+
+		var link AccountLink
+		err = tx.QueryRow(ctx, `
+			INSERT INTO account_links (user_id, provider, external_user_id, external_name, artist_id,
+			                           access_token, refresh_token, token_expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8)
+			ON CONFLICT (user_id, provider) DO UPDATE SET
+				external_user_id = EXCLUDED.external_user_id, external_name = EXCLUDED.external_name,
+				artist_id = EXCLUDED.artist_id, access_token = EXCLUDED.access_token,
+				refresh_token = COALESCE(EXCLUDED.refresh_token, account_links.refresh_token),
+				token_expires_at = EXCLUDED.token_expires_at, updated_at = now()
+			RETURNING provider, external_user_id, external_name, artist_id, created_at, updated_at;
+		`, currentUserID(c), providerSpotify, me.ID, me.DisplayName, artistID,
+			tokens.AccessToken, tokens.RefreshToken, time.Now().Add(time.Duration(tokens.ExpiresIn)*time.Second),
+		).Scan(&link.Provider, &link.ExternalUserID, &link.ExternalName, &link.ArtistID, &link.CreatedAt, &link.UpdatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"link": link, "artist_name": artist.Name})
+	})
+
+	// GET /auth/links — the caller's linked accounts
+	r.GET("/auth/links", RequireAuth(), func(c *gin.Context) {
+		rows, err := db.Query(context.Background(), `
+			SELECT provider, external_user_id, external_name, artist_id, created_at, updated_at
+			FROM account_links WHERE user_id = $1
+			ORDER BY provider;
+		`, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		links := []AccountLink{}
+		for rows.Next() {
+			var l AccountLink
+			if err := rows.Scan(&l.Provider, &l.ExternalUserID, &l.ExternalName, &l.ArtistID, &l.CreatedAt, &l.UpdatedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			links = append(links, l)
+		}
+		c.JSON(http.StatusOK, links)
+	})
+}
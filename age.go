@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Users give their birth date and region once, with PUT /auth/me/birth-date.
+// Handlers only ever see the derived age bracket, which RequireAuth stores
+// under "age_bracket": below the region's minimum age the account is
+// locked out of everything but /auth, and minors can't open explicit songs
+// or send tips. With MIN_AGE_BY_REGION set, writes also wait for a birth
+// date, as they do for legal consent. Adults can turn on safe mode to have
+// explicit songs kept from them the way they are from minors; RequireAuth
+// stores it under "safe_mode".
+const (
+	ageBracketUnknown      = "unknown"
+	ageBracketUnderMinimum = "under_minimum"
+	ageBracketMinor        = "minor"
+	ageBracketAdult        = "adult"
+
+	adultAge          = 18
+	defaultMinimumAge = 13
+	maxBirthAge       = 120
+)
+
+var regionPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// minimumAges holds MIN_AGE_BY_REGION; "default" covers unlisted regions.
+var minimumAges = map[string]int{"default": defaultMinimumAge}
+
+// ageGatingRequired is set when MIN_AGE_BY_REGION is configured.
+var ageGatingRequired bool
+
+// InitAgeGating reads the minimum sign-up ages.
+func InitAgeGating() {
+	for _, pair := range cfg.MinAgeByRegion {
+		region, age, ok := strings.Cut(pair, "=")
+		region = strings.TrimSpace(region)
+		if region != "default" {
+			region = strings.ToUpper(region)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(age))
+		if !ok || err != nil || n < 0 || (region != "default" && !regionPattern.MatchString(region)) {
+			log.Printf("⚠️  ignoring MIN_AGE_BY_REGION entry %q, want REGION=age", pair)
+			continue
+		}
+		minimumAges[region] = n
+		ageGatingRequired = true
+	}
+	if ageGatingRequired {
+		log.Printf("✅ Requiring a birth date before writes (minimum age %d by default)", minimumAges["default"])
+	}
+}
+
+func minimumAge(region string) int {
+	if n, ok := minimumAges[region]; ok {
+		return n
+	}
+	return minimumAges["default"]
+}
+
+// ageOn is the age in whole years on day now of someone born on birth.
+func ageOn(birth, now time.Time) int {
+	age := now.Year() - birth.Year()
+	if now.Month() < birth.Month() || (now.Month() == birth.Month() && now.Day() < birth.Day()) {
+		age--
+	}
+	return age
+}
+
+// ageBracketFor derives the bracket from a birth date, today.
+func ageBracketFor(birth *time.Time, region string) string {
+	if birth == nil {
+		return ageBracketUnknown
+	}
+	age := ageOn(*birth, time.Now().UTC())
+	switch {
+	case age < minimumAge(region):
+		return ageBracketUnderMinimum
+	case age < adultAge:
+		return ageBracketMinor
+	}
+	return ageBracketAdult
+}
+
+// ageBracket returns the caller's bracket as set by RequireAuth, or
+// "unknown" for anonymous requests.
+func ageBracket(c *gin.Context) string {
+	if b := c.GetString("age_bracket"); b != "" {
+		return b
+	}
+	return ageBracketUnknown
+}
+
+// isMinor reports whether bracket is below adulthood. An unknown age is not
+// treated as a minor.
+func isMinor(bracket string) bool {
+	return bracket == ageBracketMinor || bracket == ageBracketUnderMinimum
+}
+
+// checkAge stores the caller's age bracket and safe mode for handlers,
+// locks out under-age accounts, and (with age gating required) holds writes
+// until a birth date is given. RequireAuth and RequireAPIKey call it.
+func checkAge(c *gin.Context, userID string) bool {
+	e, err := accessProfile(c.Request.Context(), userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	bracket := ageBracketFor(e.birthDate, e.region)
+	c.Set("age_bracket", bracket)
+	c.Set("safe_mode", e.safeMode)
+	if gateExempt(c.FullPath()) {
+		return true
+	}
+
+	if bracket == ageBracketUnderMinimum {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "you are below the minimum age for this service",
+			"code":  "under_minimum_age",
+		})
+		return false
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	if ageGatingRequired && bracket == ageBracketUnknown {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "add your birth date to continue",
+			"code":  "birth_date_required",
+		})
+		return false
+	}
+	return true
+}
+
+// abortIfMinor writes the age-restricted response for minors.
+func abortIfMinor(c *gin.Context, bracket, what string) bool {
+	if !isMinor(bracket) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": what + " is not available to users under 18", "code": "age_restricted"})
+	return true
+}
+
+// hideExplicit reports whether explicit songs are left out for the caller:
+// minors, and anyone with safe mode on.
+func hideExplicit(c *gin.Context) bool {
+	return isMinor(ageBracket(c)) || c.GetBool("safe_mode")
+}
+
+// abortIfExplicit writes the response for an explicit item the caller
+// can't open: age-restricted for minors, safe_mode for safe mode.
+func abortIfExplicit(c *gin.Context, what string) bool {
+	if abortIfMinor(c, ageBracket(c), what) {
+		return true
+	}
+	if !c.GetBool("safe_mode") {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": what + " is explicit; turn off safe mode to open it", "code": "safe_mode"})
+	return true
+}
+
+// RegisterAgeRoutes defines the birth date endpoint, safe mode, and the
+// explicit flag on songs.
+func RegisterAgeRoutes(r *gin.Engine) {
+	// PUT /auth/me/birth-date {"birth_date":"2001-04-30","region":"US"} — once; returns the age bracket only
+	r.PUT("/auth/me/birth-date", RequireAuth(), func(c *gin.Context) {
+		var body struct {
+			BirthDate string `json:"birth_date"`
+			Region    string `json:"region"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		birth, err := time.Parse("2006-01-02", body.BirthDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "birth_date must be YYYY-MM-DD"})
+			return
+		}
+		if age := ageOn(birth, time.Now().UTC()); birth.After(time.Now()) || age > maxBirthAge {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "birth_date is not plausible"})
+			return
+		}
+		region := strings.ToUpper(strings.TrimSpace(body.Region))
+		if !regionPattern.MatchString(region) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "region must be a two-letter country code"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		userID := currentUserID(c)
+		tag, err := db.Exec(ctx, `
+			UPDATE profiles SET birth_date = $2, region = $3 WHERE id = $1 AND birth_date IS NULL;
+		`, userID, birth, region)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "birth date is already set; contact support to correct it"})
+			return
+		}
+		forgetAccessProfile(ctx, userID)
+
+		bracket := ageBracketFor(&birth, region)
+		if bracket == ageBracketUnderMinimum {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":       "you are below the minimum age for this service",
+				"code":        "under_minimum_age",
+				"minimum_age": minimumAge(region),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"age_bracket": bracket})
+	})
+
+	// GET /auth/me/safe-mode — explicit_hidden is also true for minors, whatever safe_mode says
+	r.GET("/auth/me/safe-mode", RequireAuth(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"safe_mode": c.GetBool("safe_mode"), "explicit_hidden": hideExplicit(c)})
+	})
+
+	// PUT /auth/me/safe-mode {"safe_mode":true}
+	r.PUT("/auth/me/safe-mode", RequireAuth(), func(c *gin.Context) {
+		var body struct {
+			SafeMode *bool `json:"safe_mode"`
+		}
+		if err := c.BindJSON(&body); err != nil || body.SafeMode == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": `expected {"safe_mode":true|false}`})
+			return
+		}
+		ctx := c.Request.Context()
+		userID := currentUserID(c)
+		tag, err := db.Exec(ctx, `UPDATE profiles SET safe_mode = $2, updated_at = now() WHERE id = $1;`, userID, *body.SafeMode)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "profile not found"})
+			return
+		}
+		forgetAccessProfile(ctx, userID)
+
+		c.Set("safe_mode", *body.SafeMode)
+		c.JSON(http.StatusOK, gin.H{"safe_mode": *body.SafeMode, "explicit_hidden": hideExplicit(c)})
+	})
+
+	// PUT /songs/:id/explicit — {"explicit":true}
+	r.PUT("/songs/:id/explicit", RequireAuthOrAPIKey(), RequireScope(apiScopeSongsWrite), func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "label")
+		if !ok {
+			return
+		}
+		var body struct {
+			Explicit *bool `json:"explicit"`
+		}
+		if err := c.BindJSON(&body); err != nil || body.Explicit == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": `expected {"explicit":true|false}`})
+			return
+		}
+		if _, err := db.Exec(context.Background(),
+			`UPDATE songs SET explicit = $2 WHERE id = $1;`, songID, *body.Explicit); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"song_id": songID, "explicit": *body.Explicit})
+	})
+}
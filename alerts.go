@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Artist alerts compare yesterday's rolled-up count for a metric against the
+// day before (drop/rise by a percentage) or against a fixed threshold
+// (above/below). They are evaluated once per UTC day by a periodic job and
+// delivered as notifications.
+const (
+	alertEvalName     = "artist_alerts"
+	alertEvalInterval = time.Hour
+)
+
+var alertMetrics = map[string]bool{"plays": true, "likes": true, "shares": true, "comments": true, "tips": true}
+var alertConditions = map[string]bool{"drop_percent": true, "rise_percent": true, "above": true, "below": true}
+
+type alertInput struct {
+	SongID    *int64   `json:"song_id"`
+	Metric    string   `json:"metric"`
+	Condition string   `json:"condition"`
+	Threshold *float64 `json:"threshold"`
+	Enabled   *bool    `json:"enabled"`
+}
+
+const alertColumns = `id, user_id, song_id, metric, condition, threshold, enabled, last_triggered_day, created_at`
+
+func scanAlert(row pgx.Row) (ArtistAlert, error) {
+	var a ArtistAlert
+	err := row.Scan(&a.ID, &a.UserID, &a.SongID, &a.Metric, &a.Condition, &a.Threshold, &a.Enabled, &a.LastTriggeredDay, &a.CreatedAt)
+	return a, err
+}
+
+// songOwnedBy reports whether songID belongs to artistID.
+func songOwnedBy(ctx context.Context, songID int64, artistID string) (bool, error) {
+	var owned bool
+	err := db.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM songs WHERE id = $1 AND artist_id = $2 AND trashed_at IS NULL);`,
+		songID, artistID,
+	).Scan(&owned)
+	return owned, err
+}
+
+// alertFires applies the condition to yesterday's and the previous day's values.
+func alertFires(a ArtistAlert, yesterday, previous int64) bool {
+	switch a.Condition {
+	case "above":
+		return float64(yesterday) > a.Threshold
+	case "below":
+		return float64(yesterday) < a.Threshold
+	case "drop_percent":
+		return previous > 0 && float64(previous-yesterday)/float64(previous)*100 >= a.Threshold
+	case "rise_percent":
+		return previous > 0 && float64(yesterday-previous)/float64(previous)*100 >= a.Threshold
+	}
+	return false
+}
+
+// evaluateAlerts checks every enabled alert not yet evaluated for yesterday.
+func evaluateAlerts(ctx context.Context) error {
+	yesterday := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+
+	rows, err := db.Query(ctx, `
+		SELECT `+alertColumns+`
+		FROM artist_alerts
+		WHERE enabled AND (last_evaluated_day IS NULL OR last_evaluated_day < $1);
+	`, yesterday)
+	if err != nil {
+		return err
+	}
+	var alerts []ArtistAlert
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		alerts = append(alerts, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, a := range alerts {
+		if err := evaluateAlert(ctx, a, yesterday); err != nil {
+			log.Printf("alert %d: %v", a.ID, err)
+		}
+	}
+	return nil
+}
+
+func evaluateAlert(ctx context.Context, a ArtistAlert, yesterday time.Time) error {
+	// The metric name is whitelisted on create, so it is safe as a column name.
+	sql := fmt.Sprintf(`
+		SELECT
+			COALESCE(SUM(s.%[1]s) FILTER (WHERE s.day = $2::date), 0)::bigint,
+			COALESCE(SUM(s.%[1]s) FILTER (WHERE s.day = $2::date - 1), 0)::bigint
+		FROM song_daily_stats s
+		JOIN songs ON songs.id = s.song_id
+		WHERE songs.artist_id = $1
+		  AND ($3::bigint IS NULL OR s.song_id = $3)
+		  AND s.day BETWEEN $2::date - 1 AND $2::date;
+	`, a.Metric)
+
+	var current, previous int64
+	if err := db.QueryRow(ctx, sql, a.UserID, yesterday, a.SongID).Scan(&current, &previous); err != nil {
+		return err
+	}
+
+	if alertFires(a, current, previous) {
+		scope := "your catalog"
+		if a.SongID != nil {
+			scope = fmt.Sprintf("song %d", *a.SongID)
+		}
+		title := fmt.Sprintf("Daily %s alert", a.Metric)
+		body := fmt.Sprintf("%s had %d %s on %s (previous day: %d).",
+			scope, current, a.Metric, yesterday.Format(dateLayout), previous)
+		data := gin.H{"alert_id": a.ID, "song_id": a.SongID, "value": current, "previous": previous}
+		if err := notify(ctx, a.UserID, "artist_alert", title, body, data); err != nil {
+			return err
+		}
+		_, err := db.Exec(ctx, `UPDATE artist_alerts SET last_triggered_day = $2 WHERE id = $1;`, a.ID, yesterday)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := db.Exec(ctx, `UPDATE artist_alerts SET last_evaluated_day = $2 WHERE id = $1;`, a.ID, yesterday)
+	return err
+}
+
+// RegisterAlertRoutes defines /me/alerts CRUD.
+func RegisterAlertRoutes(r *gin.Engine) {
+	me := r.Group("/me/alerts", RequireAuth())
+
+	// GET /me/alerts
+	me.GET("", func(c *gin.Context) {
+		rows, err := db.Query(context.Background(),
+			`SELECT `+alertColumns+` FROM artist_alerts WHERE user_id = $1 ORDER BY created_at;`,
+			currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		alerts := []ArtistAlert{}
+		for rows.Next() {
+			a, err := scanAlert(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			alerts = append(alerts, a)
+		}
+		c.JSON(http.StatusOK, alerts)
+	})
+
+	// POST /me/alerts — e.g. {"metric":"plays","condition":"drop_percent","threshold":50}
+	me.POST("", func(c *gin.Context) {
+		var body alertInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if !alertMetrics[body.Metric] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "metric must be plays, likes, shares, comments, or tips"})
+			return
+		}
+		if !alertConditions[body.Condition] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "condition must be drop_percent, rise_percent, above, or below"})
+			return
+		}
+		if body.Threshold == nil || *body.Threshold <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "threshold must be > 0"})
+			return
+		}
+		if body.Condition == "drop_percent" && *body.Threshold > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a drop cannot exceed 100%"})
+			return
+		}
+
+		if body.SongID != nil {
+			owned, err := songOwnedBy(context.Background(), *body.SongID, currentUserID(c))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if !owned {
+				c.JSON(http.StatusForbidden, gin.H{"error": "you can only set alerts on your own songs"})
+				return
+			}
+		}
+
+		a, err := scanAlert(db.QueryRow(context.Background(), `
+			INSERT INTO artist_alerts (user_id, song_id, metric, condition, threshold)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+alertColumns+`;
+		`, currentUserID(c), body.SongID, body.Metric, body.Condition, *body.Threshold))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, a)
+	})
+
+	// PATCH /me/alerts/:id — threshold and/or enabled
+	me.PATCH("/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alert id"})
+			return
+		}
+
+		var body alertInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if body.Threshold != nil && *body.Threshold <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "threshold must be > 0"})
+			return
+		}
+
+		a, err := scanAlert(db.QueryRow(context.Background(), `
+			UPDATE artist_alerts SET
+				threshold = COALESCE($3, threshold),
+				enabled   = COALESCE($4, enabled)
+			WHERE id = $1 AND user_id = $2
+			RETURNING `+alertColumns+`;
+		`, id, currentUserID(c), body.Threshold, body.Enabled))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "alert not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, a)
+	})
+
+	// DELETE /me/alerts/:id
+	me.DELETE("/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alert id"})
+			return
+		}
+
+		tag, err := db.Exec(context.Background(),
+			`DELETE FROM artist_alerts WHERE id = $1 AND user_id = $2;`, id, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "alert not found"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
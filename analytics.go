@@ -2,7 +2,11 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,7 +24,7 @@ func RegisterAnalyticsRoutes(r *gin.Engine) {
 				COUNT(CASE WHEN events.event_type = 'review' THEN 1 END) AS total_reviews,
 				COUNT(CASE WHEN events.event_type = 'tip' THEN 1 END) AS total_tips
 			FROM songs
-			LEFT JOIN events ON songs.id = events.song_id
+			LEFT JOIN events ON songs.id = events.song_id AND NOT events.is_bot
 			GROUP BY songs.id
 			ORDER BY total_events DESC;
 		`
@@ -53,4 +57,200 @@ func RegisterAnalyticsRoutes(r *gin.Engine) {
 
 		c.JSON(http.StatusOK, analytics)
 	})
+
+	// GET /analytics/songs/:id — engagement totals plus completion and skip rates
+	r.GET("/analytics/songs/:id", func(c *gin.Context) {
+		songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+			return
+		}
+
+		sql := `
+			SELECT
+				COUNT(*) FILTER (WHERE event_type = 'play'),
+				COUNT(*) FILTER (WHERE event_type = 'play_progress' AND properties->>'percent' = '25'),
+				COUNT(*) FILTER (WHERE event_type = 'play_progress' AND properties->>'percent' = '50'),
+				COUNT(*) FILTER (WHERE event_type = 'play_progress' AND properties->>'percent' = '75'),
+				COUNT(*) FILTER (WHERE event_type = 'play_progress' AND properties->>'percent' = '100'),
+				COUNT(*) FILTER (WHERE event_type = 'skip'),
+				COUNT(*) FILTER (WHERE event_type = 'comment'),
+				COUNT(*) FILTER (WHERE event_type = 'review'),
+				COUNT(*) FILTER (WHERE event_type = 'tip')
+			FROM events
+			WHERE song_id = $1 AND NOT is_bot;
+		`
+
+		type SongDetailAnalytics struct {
+			SongID         int64              `json:"song_id"`
+			TotalPlays     int64              `json:"total_plays"`
+			TotalSkips     int64              `json:"total_skips"`
+			TotalComments  int64              `json:"total_comments"`
+			TotalReviews   int64              `json:"total_reviews"`
+			TotalTips      int64              `json:"total_tips"`
+			ReachedPercent map[string]float64 `json:"reached_percent"`
+			CompletionRate float64            `json:"completion_rate"`
+			SkipRate       float64            `json:"skip_rate"`
+		}
+
+		a := SongDetailAnalytics{SongID: songID}
+		var p25, p50, p75, p100 int64
+		err = db.QueryRow(context.Background(), sql, songID).Scan(
+			&a.TotalPlays, &p25, &p50, &p75, &p100, &a.TotalSkips,
+			&a.TotalComments, &a.TotalReviews, &a.TotalTips,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Share of started plays that reached each checkpoint; the drop between
+		// checkpoints shows where listeners leave.
+		a.ReachedPercent = map[string]float64{
+			"25":  ratio(p25, a.TotalPlays),
+			"50":  ratio(p50, a.TotalPlays),
+			"75":  ratio(p75, a.TotalPlays),
+			"100": ratio(p100, a.TotalPlays),
+		}
+		a.CompletionRate = ratio(p100, a.TotalPlays)
+		a.SkipRate = ratio(a.TotalSkips, a.TotalPlays)
+
+		c.JSON(http.StatusOK, a)
+	})
+
+	// GET /analytics/songs/:id/dropoff?bucket=10&from=&to=
+	//
+	// Each listening session (properties.session_id) contributes the furthest
+	// position it reported via play_progress, pause, skip, or seek events.
+	// Retention for a bucket is the share of sessions that got at least that
+	// far. Forward seeks count the skipped section as heard, so this is an
+	// upper bound in sections people jump over.
+	r.GET("/analytics/songs/:id/dropoff", func(c *gin.Context) {
+		songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+			return
+		}
+
+		bucket, err := strconv.Atoi(c.DefaultQuery("bucket", "10"))
+		if err != nil || bucket < 1 || bucket > 60 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "bucket must be 1-60 seconds"})
+			return
+		}
+
+		from, to, err := parseDateRange(c, 30, 366)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		sql := `
+			WITH sessions AS (
+				SELECT
+					properties->>'session_id' AS session_id,
+					MAX(GREATEST(
+						COALESCE((properties->>'position_seconds')::numeric, 0),
+						COALESCE((properties->>'from_seconds')::numeric, 0)
+					)) AS reached
+				FROM events
+				WHERE song_id = $1
+				  AND NOT is_bot
+				  AND event_type IN ('play_progress', 'pause', 'skip', 'seek')
+				  AND properties ? 'session_id'
+				  AND occurred_at >= $3 AND occurred_at < $4
+				GROUP BY 1
+			)
+			SELECT FLOOR(reached / $2)::int AS bucket, COUNT(*)
+			FROM sessions
+			GROUP BY bucket
+			ORDER BY bucket;
+		`
+
+		rows, err := db.Query(context.Background(), sql, songID, bucket, from, to.AddDate(0, 0, 1))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		// endedIn[b] = sessions whose furthest position falls in bucket b
+		endedIn := map[int]int64{}
+		maxBucket := -1
+		var sessions int64
+		for rows.Next() {
+			var b int
+			var n int64
+			if err := rows.Scan(&b, &n); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			endedIn[b] = n
+			sessions += n
+			if b > maxBucket {
+				maxBucket = b
+			}
+		}
+
+		type RetentionBucket struct {
+			StartSeconds int     `json:"start_seconds"`
+			EndSeconds   int     `json:"end_seconds"`
+			Listeners    int64   `json:"listeners"`
+			Retention    float64 `json:"retention"`
+		}
+
+		buckets := make([]RetentionBucket, maxBucket+1)
+		var remaining int64
+		for b := maxBucket; b >= 0; b-- {
+			remaining += endedIn[b]
+			buckets[b] = RetentionBucket{
+				StartSeconds: b * bucket,
+				EndSeconds:   (b + 1) * bucket,
+				Listeners:    remaining,
+				Retention:    ratio(remaining, sessions),
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"song_id":        songID,
+			"bucket_seconds": bucket,
+			"sessions":       sessions,
+			"buckets":        buckets,
+		})
+	})
+}
+
+// ratio returns n/d rounded to 4 places, or 0 when d is 0.
+func ratio(n, d int64) float64 {
+	if d == 0 {
+		return 0
+	}
+	return math.Round(float64(n)/float64(d)*10000) / 10000
+}
+
+const dateLayout = "2006-01-02"
+
+// parseDateRange reads ?from= and ?to= (YYYY-MM-DD, inclusive). Missing
+// values default to the last defaultDays days ending today (UTC).
+func parseDateRange(c *gin.Context, defaultDays, maxDays int) (from, to time.Time, err error) {
+	to = time.Now().UTC().Truncate(24 * time.Hour)
+	if s := c.Query("to"); s != "" {
+		if to, err = time.Parse(dateLayout, s); err != nil {
+			return from, to, fmt.Errorf("to must be YYYY-MM-DD")
+		}
+	}
+
+	from = to.AddDate(0, 0, -(defaultDays - 1))
+	if s := c.Query("from"); s != "" {
+		if from, err = time.Parse(dateLayout, s); err != nil {
+			return from, to, fmt.Errorf("from must be YYYY-MM-DD")
+		}
+	}
+
+	if from.After(to) {
+		return from, to, fmt.Errorf("from must not be after to")
+	}
+	if to.Sub(from) > time.Duration(maxDays)*24*time.Hour {
+		return from, to, fmt.Errorf("date range may span at most %d days", maxDays)
+	}
+	return from, to, nil
 }
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Artists can schedule an announcement (say, for a lyric video drop) that is
+// posted as a pinned comment at publish_at. Each announcement remembers the
+// announcement_publish job responsible for it; rescheduling enqueues a new
+// job, and a job that no longer matches skips without posting.
+const (
+	announcementPublishJob = "announcement_publish"
+	maxAnnouncementLead    = 365 * 24 * time.Hour
+)
+
+type announcementPublishPayload struct {
+	AnnouncementID int64 `json:"announcement_id"`
+}
+
+func init() {
+	RegisterJobHandler(announcementPublishJob, runAnnouncementPublish)
+}
+
+const announcementColumns = `id, song_id, created_by::text, body, publish_at, status, job_id, comment_id, created_at, updated_at`
+
+func scanAnnouncement(row pgx.Row) (SongAnnouncement, error) {
+	var a SongAnnouncement
+	err := row.Scan(&a.ID, &a.SongID, &a.CreatedBy, &a.Body, &a.PublishAt, &a.Status, &a.JobID, &a.CommentID,
+		&a.CreatedAt, &a.UpdatedAt)
+	return a, err
+}
+
+// pinComment pins commentID and unpins whatever was pinned on its song
+// before; a song shows at most one pinned comment.
+func pinComment(ctx context.Context, tx pgx.Tx, songID, commentID int64) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE comments
+		SET pinned_at = CASE WHEN id = $2 THEN COALESCE(pinned_at, now()) END
+		WHERE song_id = $1 AND (id = $2 OR pinned_at IS NOT NULL);
+	`, songID, commentID)
+	return err
+}
+
+func runAnnouncementPublish(ctx context.Context, job *Job) (interface{}, error) {
+	var p announcementPublishPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	a, err := scanAnnouncement(tx.QueryRow(ctx,
+		`SELECT `+announcementColumns+` FROM song_announcements WHERE id = $1 FOR UPDATE;`, p.AnnouncementID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return gin.H{"skipped": true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if a.Status != "scheduled" || a.JobID == nil || *a.JobID != job.ID {
+		return gin.H{"skipped": true, "status": a.Status}, nil
+	}
+
+	var commentID int64
+	var artistID string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO comments (song_id, author_id, posted_by, body)
+		SELECT id, artist_id, $2, $3 FROM songs WHERE id = $1
+		RETURNING id, author_id::text;
+	`, a.SongID, a.CreatedBy, a.Body).Scan(&commentID, &artistID)
+	if err != nil {
+		return nil, err
+	}
+	if err := pinComment(ctx, tx, a.SongID, commentID); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE song_announcements SET status = 'published', comment_id = $2, updated_at = now()
+		WHERE id = $1;
+	`, a.ID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	recordServerEvent(a.SongID, artistID, "comment")
+	return gin.H{"announcement_id": a.ID, "comment_id": commentID}, nil
+}
+
+// announcementInput validates a create or update body. publishAt is nil
+// when the field was omitted.
+func announcementInput(c *gin.Context, requireAll bool) (body *string, publishAt *time.Time, ok bool) {
+	var in struct {
+		Body      *string    `json:"body"`
+		PublishAt *time.Time `json:"publish_at"`
+	}
+	if err := c.BindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON; publish_at must be RFC 3339"})
+		return nil, nil, false
+	}
+	if requireAll && (in.Body == nil || in.PublishAt == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "body and publish_at are required"})
+		return nil, nil, false
+	}
+	if in.Body != nil {
+		trimmed := strings.TrimSpace(*in.Body)
+		if trimmed == "" || len(trimmed) > maxCommentBody {
+			commentDenied(c, http.StatusBadRequest, commentErrInvalid, "body must be 1-2000 characters")
+			return nil, nil, false
+		}
+		in.Body = &trimmed
+	}
+	if in.PublishAt != nil {
+		now := time.Now()
+		if !in.PublishAt.After(now) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "publish_at must be in the future"})
+			return nil, nil, false
+		}
+		if in.PublishAt.Sub(now) > maxAnnouncementLead {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "publish_at must be within a year"})
+			return nil, nil, false
+		}
+	}
+	return in.Body, in.PublishAt, true
+}
+
+// scheduledAnnouncement loads :announcementId on the song, writing the error
+// response when it's missing or has already been posted or canceled.
+func scheduledAnnouncement(c *gin.Context, songID int64) (SongAnnouncement, bool) {
+	id, err := strconv.ParseInt(c.Param("announcementId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid announcement id"})
+		return SongAnnouncement{}, false
+	}
+	a, err := scanAnnouncement(db.QueryRow(context.Background(),
+		`SELECT `+announcementColumns+` FROM song_announcements WHERE id = $1 AND song_id = $2;`, id, songID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "announcement not found"})
+		return SongAnnouncement{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return SongAnnouncement{}, false
+	}
+	if a.Status != "scheduled" {
+		c.JSON(http.StatusConflict, gin.H{"error": "announcement has already been " + a.Status})
+		return SongAnnouncement{}, false
+	}
+	return a, true
+}
+
+// scheduleAnnouncement enqueues a publish job for a.PublishAt and makes it
+// the one responsible for a; any earlier job then skips.
+func scheduleAnnouncement(ctx context.Context, a SongAnnouncement, createdBy string) (SongAnnouncement, error) {
+	jobID, err := EnqueueJobAt(ctx, announcementPublishJob, announcementPublishPayload{AnnouncementID: a.ID}, createdBy, a.PublishAt)
+	if err != nil {
+		return a, err
+	}
+	if _, err := db.Exec(ctx,
+		`UPDATE song_announcements SET job_id = $2 WHERE id = $1;`, a.ID, jobID); err != nil {
+		return a, err
+	}
+	a.JobID = &jobID
+	return a, nil
+}
+
+// RegisterAnnouncementRoutes defines scheduled announcements on songs.
+func RegisterAnnouncementRoutes(r *gin.Engine) {
+	songs := r.Group("/songs/:id/announcements", RequireAuth(), RequireTeamScope(scopeCommentsReply))
+
+	// GET /songs/:id/announcements — every announcement, soonest first
+	songs.GET("", func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "manage announcements on")
+		if !ok {
+			return
+		}
+
+		rows, err := db.Query(context.Background(),
+			`SELECT `+announcementColumns+` FROM song_announcements WHERE song_id = $1 ORDER BY publish_at, id;`, songID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		announcements := []SongAnnouncement{}
+		for rows.Next() {
+			a, err := scanAnnouncement(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			announcements = append(announcements, a)
+		}
+		c.JSON(http.StatusOK, announcements)
+	})
+
+	// POST /songs/:id/announcements — {"body":"...","publish_at":"2025-06-01T17:00:00Z"}
+	songs.POST("", func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "manage announcements on")
+		if !ok {
+			return
+		}
+		body, publishAt, ok := announcementInput(c, true)
+		if !ok {
+			return
+		}
+
+		a, err := scanAnnouncement(db.QueryRow(context.Background(), `
+			INSERT INTO song_announcements (song_id, created_by, body, publish_at)
+			VALUES ($1, $2, $3, $4)
+			RETURNING `+announcementColumns+`;
+		`, songID, actorID(c), *body, *publishAt))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if a, err = scheduleAnnouncement(context.Background(), a, actorID(c)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, a)
+	})
+
+	// PATCH /songs/:id/announcements/:announcementId — {"body":"...","publish_at":"..."}; while still scheduled
+	songs.PATCH("/:announcementId", func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "manage announcements on")
+		if !ok {
+			return
+		}
+		a, ok := scheduledAnnouncement(c, songID)
+		if !ok {
+			return
+		}
+		body, publishAt, ok := announcementInput(c, false)
+		if !ok {
+			return
+		}
+
+		a, err := scanAnnouncement(db.QueryRow(context.Background(), `
+			UPDATE song_announcements
+			SET body = COALESCE($2, body), publish_at = COALESCE($3, publish_at), updated_at = now()
+			WHERE id = $1 AND status = 'scheduled'
+			RETURNING `+announcementColumns+`;
+		`, a.ID, body, publishAt))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "announcement is no longer scheduled"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if publishAt != nil {
+			if a, err = scheduleAnnouncement(context.Background(), a, actorID(c)); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, a)
+	})
+
+	// DELETE /songs/:id/announcements/:announcementId — cancels it before it's posted
+	songs.DELETE("/:announcementId", func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "manage announcements on")
+		if !ok {
+			return
+		}
+		a, ok := scheduledAnnouncement(c, songID)
+		if !ok {
+			return
+		}
+
+		tag, err := db.Exec(context.Background(), `
+			UPDATE song_announcements SET status = 'canceled', updated_at = now()
+			WHERE id = $1 AND status = 'scheduled';
+		`, a.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "announcement is no longer scheduled"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	apiKeyPrefix       = "lpk_"
+	maxAPIKeysPerUser  = 10
+	apiKeyDisplayChars = 8
+
+	defaultAPIKeyRate = 60
+	maxAPIKeyRate     = 1200
+	apiKeyRateWindow  = time.Minute
+)
+
+type createAPIKeyInput struct {
+	Name               string   `json:"name"`
+	Scopes             []string `json:"scopes"`
+	RateLimitPerMinute int      `json:"rate_limit_per_minute"`
+}
+
+const apiKeyColumns = `id, user_id, name, prefix, scopes, rate_limit_per_minute, last_used_at, revoked_at, created_at`
+
+func scanAPIKey(row pgx.Row) (APIKey, error) {
+	var k APIKey
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.Scopes, &k.RateLimitPerMinute, &k.LastUsedAt, &k.RevokedAt, &k.CreatedAt)
+	return k, err
+}
+
+// apiKeyRateTracker counts requests per key in fixed one-minute windows.
+// Counts are per instance, so a key's effective limit scales with replicas.
+type apiKeyRateTracker struct {
+	mu        sync.Mutex
+	windows   map[int64]*botWindow
+	lastPrune time.Time
+}
+
+var apiKeyRates = &apiKeyRateTracker{windows: map[int64]*botWindow{}}
+
+// hit records a request for keyID and returns the count in the current
+// window and when that window ends.
+func (t *apiKeyRateTracker) hit(keyID int64) (int, time.Time) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.Sub(t.lastPrune) > apiKeyRateWindow {
+		for k, w := range t.windows {
+			if now.Sub(w.start) > apiKeyRateWindow {
+				delete(t.windows, k)
+			}
+		}
+		t.lastPrune = now
+	}
+
+	w, ok := t.windows[keyID]
+	if !ok || now.Sub(w.start) > apiKeyRateWindow {
+		w = &botWindow{start: now}
+		t.windows[keyID] = w
+	}
+	w.hits++
+	return w.hits, w.start.Add(apiKeyRateWindow)
+}
+
+// enforceRateLimit counts a request for id against limit per minute and
+// sets the X-RateLimit headers, writing the 429 (naming what was limited)
+// and returning false once it's exceeded.
+func enforceRateLimit(c *gin.Context, t *apiKeyRateTracker, id int64, limit int, what string) bool {
+	hits, reset := t.hit(id)
+	remaining := limit - hits
+	if remaining < 0 {
+		remaining = 0
+	}
+	c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	if hits > limit {
+		c.Header("Retry-After", strconv.Itoa(int(time.Until(reset).Seconds())+1))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": what + " rate limit exceeded"})
+		return false
+	}
+	return true
+}
+
+// RequireAPIKey authenticates the X-API-Key header and stores the key
+// owner's ID under "user_id" (and the key's under "api_key_id"), so handlers
+// can use currentUserID as with a bearer token. The key must stay within
+// its per-minute rate limit; its scopes go under "token_scopes" for
+// RequireScope.
+func RequireAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-API-Key")
+		if key == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing X-API-Key header"})
+			return
+		}
+
+		k, err := scanAPIKey(db.QueryRow(context.Background(), `
+			UPDATE api_keys SET last_used_at = now()
+			WHERE key_hash = $1 AND revoked_at IS NULL
+			RETURNING `+apiKeyColumns+`;
+		`, hashToken(key)))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !enforceRateLimit(c, apiKeyRates, k.ID, k.RateLimitPerMinute, "API key") {
+			return
+		}
+
+		c.Set("user_id", k.UserID)
+		c.Set("api_key_id", k.ID)
+		c.Set("token_scopes", k.Scopes)
+		// A key acts as its owner, so the owner's age rules apply.
+		if !checkAge(c, k.UserID) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAuthOrAPIKey accepts either a bearer token or an X-API-Key, for
+// routes partners call without a user session.
+func RequireAuthOrAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("X-API-Key") != "" && bearerToken(c) == "" {
+			RequireAPIKey()(c)
+			return
+		}
+		RequireAuth()(c)
+	}
+}
+
+// OptionalAPIKey authenticates an X-API-Key when one is sent and otherwise
+// lets the request through as it was.
+func OptionalAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("X-API-Key") == "" {
+			c.Next()
+			return
+		}
+		RequireAPIKey()(c)
+	}
+}
+
+// createAPIKey mints a key for the caller. The raw key is only returned here.
+func createAPIKey(c *gin.Context) {
+	var body createAPIKeyInput
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if len(body.Scopes) == 0 {
+		body.Scopes = []string{apiScopeSongsRead}
+	}
+	for i, s := range body.Scopes {
+		if legacy, ok := legacyAPIScopes[s]; ok {
+			body.Scopes[i] = legacy
+		} else if !apiScopes[s] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "scopes must be songs:read, songs:write, analytics:read, or tips:write"})
+			return
+		}
+	}
+	if body.RateLimitPerMinute == 0 {
+		body.RateLimitPerMinute = defaultAPIKeyRate
+	}
+	if body.RateLimitPerMinute < 1 || body.RateLimitPerMinute > maxAPIKeyRate {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rate_limit_per_minute must be 1-" + strconv.Itoa(maxAPIKeyRate)})
+		return
+	}
+
+	var count int
+	if err := db.QueryRow(context.Background(),
+		`SELECT COUNT(*) FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL;`, currentUserID(c),
+	).Scan(&count); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if count >= maxAPIKeysPerUser {
+		c.JSON(http.StatusConflict, gin.H{"error": "too many active API keys; revoke one first"})
+		return
+	}
+
+	key, err := newOpaqueToken(apiKeyPrefix)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	k, err := scanAPIKey(db.QueryRow(context.Background(), `
+		INSERT INTO api_keys (user_id, name, key_hash, prefix, scopes, rate_limit_per_minute)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+apiKeyColumns+`;
+	`, currentUserID(c), body.Name, hashToken(key), key[:len(apiKeyPrefix)+apiKeyDisplayChars],
+		body.Scopes, body.RateLimitPerMinute))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"api_key": k, "key": key})
+}
+
+// RegisterAPIKeyRoutes defines /me/api-keys for minting and revoking keys.
+func RegisterAPIKeyRoutes(r *gin.Engine) {
+	// POST /auth/api-keys — same as POST /me/api-keys
+	r.POST("/auth/api-keys", RequireAuth(), createAPIKey)
+
+	me := r.Group("/me/api-keys", RequireAuth())
+
+	// GET /me/api-keys
+	me.GET("", func(c *gin.Context) {
+		rows, err := db.Query(context.Background(), `
+			SELECT `+apiKeyColumns+`
+			FROM api_keys WHERE user_id = $1 ORDER BY id;
+		`, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		keys := []APIKey{}
+		for rows.Next() {
+			k, err := scanAPIKey(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			keys = append(keys, k)
+		}
+		c.JSON(http.StatusOK, keys)
+	})
+
+	// POST /me/api-keys {name, scopes, rate_limit_per_minute}
+	me.POST("", createAPIKey)
+
+	// DELETE /me/api-keys/:id — revokes the key
+	me.DELETE("/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid key id"})
+			return
+		}
+
+		tag, err := db.Exec(context.Background(), `
+			UPDATE api_keys SET revoked_at = now()
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+		`, id, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Raw events older than the retention window are archived to Spaces as
+// gzipped CSV and deleted from Postgres. Archival never goes past the
+// rollup watermarks, so every archived event has already been counted.
+// Rollups for archived days can no longer be backfilled from Postgres.
+const (
+	eventArchiveJob      = "event_archive"
+	eventArchiveName     = "event_archive"
+	eventArchiveInterval = 24 * time.Hour
+	eventArchiveBatch    = 50000
+)
+
+type eventArchivePayload struct {
+	RetentionMonths int `json:"retention_months"`
+}
+
+type eventArchiveResult struct {
+	Cutoff   time.Time `json:"cutoff"`
+	Archived int64     `json:"archived"`
+	Files    []string  `json:"files"`
+}
+
+var eventArchiveColumns = []string{
+	"id", "song_id", "user_id", "event_type", "schema_version", "occurred_at", "properties", "is_bot",
+}
+
+func init() {
+	RegisterJobHandler(eventArchiveJob, runEventArchive)
+}
+
+// scheduleEventArchive queues an archival run unless one is already pending.
+func scheduleEventArchive(ctx context.Context) error {
+	if cfg.EventRetentionMonths <= 0 {
+		return nil
+	}
+
+	var pending bool
+	err := db.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM jobs WHERE type = $1 AND status IN ('queued', 'running'));
+	`, eventArchiveJob).Scan(&pending)
+	if err != nil || pending {
+		return err
+	}
+
+	_, err = EnqueueJob(ctx, eventArchiveJob, eventArchivePayload{RetentionMonths: cfg.EventRetentionMonths}, "")
+	return err
+}
+
+func runEventArchive(ctx context.Context, job *Job) (interface{}, error) {
+	if storage == nil {
+		return nil, fmt.Errorf("storage is not configured")
+	}
+
+	var payload eventArchivePayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return nil, err
+	}
+	if payload.RetentionMonths <= 0 {
+		return nil, fmt.Errorf("retention_months must be > 0")
+	}
+
+	cutoff := eventArchiveCutoff(payload.RetentionMonths)
+	p, err := previewEventArchive(ctx, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	maxID, total := p.MaxID, p.Events
+
+	result := eventArchiveResult{Cutoff: cutoff, Files: []string{}}
+	for result.Archived < total {
+		key, n, err := archiveEventBatch(ctx, cutoff, maxID)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			break
+		}
+		result.Archived += n
+		result.Files = append(result.Files, key)
+		SetJobProgress(ctx, job.ID, int(result.Archived*100/total))
+	}
+	return result, nil
+}
+
+// eventArchivePreview is what a run would archive and delete: Events rows
+// with IDs from FirstID to LastID, none past the rollups' MaxID.
+type eventArchivePreview struct {
+	Cutoff  time.Time `json:"cutoff"`
+	Events  int64     `json:"events"`
+	FirstID *int64    `json:"first_id"`
+	LastID  *int64    `json:"last_id"`
+	MaxID   int64     `json:"rollup_watermark_id"`
+}
+
+// eventArchiveCutoff is the start of the UTC month retentionMonths back, so
+// each run archives whole months.
+func eventArchiveCutoff(retentionMonths int) time.Time {
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -retentionMonths, 0)
+}
+
+func previewEventArchive(ctx context.Context, cutoff time.Time) (eventArchivePreview, error) {
+	p := eventArchivePreview{Cutoff: cutoff}
+	err := db.QueryRow(ctx, `
+		WITH safe AS (SELECT COALESCE(MIN(last_event_id), 0) AS id FROM rollup_watermarks WHERE name = ANY($2))
+		SELECT safe.id, e.n, e.first_id, e.last_id
+		FROM safe, LATERAL (
+			SELECT COUNT(*) AS n, MIN(id) AS first_id, MAX(id) AS last_id
+			FROM events WHERE occurred_at < $1 AND id <= safe.id
+		) e;
+	`, cutoff, []string{dailyStatsRollupName, uniquesRollupName}).Scan(&p.MaxID, &p.Events, &p.FirstID, &p.LastID)
+	return p, err
+}
+
+// archiveEventBatch uploads the oldest batch of archivable events and then
+// deletes exactly those rows. Rows are selected in ID order, so every
+// matching row between the first and last ID is in the file.
+func archiveEventBatch(ctx context.Context, cutoff time.Time, maxID int64) (string, int64, error) {
+	tmp, err := os.CreateTemp("", "events-archive-*.csv.gz")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	rows, err := db.Query(ctx, `
+		SELECT id, song_id, user_id::text, event_type, schema_version, occurred_at, properties::text, is_bot
+		FROM events
+		WHERE occurred_at < $1 AND id <= $2
+		ORDER BY id
+		LIMIT $3;
+	`, cutoff, maxID, eventArchiveBatch)
+	if err != nil {
+		return "", 0, err
+	}
+
+	gz := gzip.NewWriter(tmp)
+	w := csv.NewWriter(gz)
+	w.Write(eventArchiveColumns)
+
+	var firstID, lastID, n int64
+	for rows.Next() {
+		var (
+			id, songID    int64
+			userID        *string
+			eventType     string
+			schemaVersion int
+			occurredAt    time.Time
+			properties    string
+			isBot         bool
+		)
+		if err := rows.Scan(&id, &songID, &userID, &eventType, &schemaVersion, &occurredAt, &properties, &isBot); err != nil {
+			rows.Close()
+			return "", 0, err
+		}
+		if n == 0 {
+			firstID = id
+		}
+		lastID = id
+		n++
+
+		user := ""
+		if userID != nil {
+			user = *userID
+		}
+		w.Write([]string{
+			strconv.FormatInt(id, 10), strconv.FormatInt(songID, 10), user, eventType,
+			strconv.Itoa(schemaVersion), occurredAt.UTC().Format(time.RFC3339Nano), properties, strconv.FormatBool(isBot),
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", 0, err
+	}
+	if n == 0 {
+		return "", 0, nil
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", 0, err
+	}
+	if err := gz.Close(); err != nil {
+		return "", 0, err
+	}
+
+	size, err := tmp.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+
+	key := fmt.Sprintf("archives/events/%s/%d-%d.csv.gz", cutoff.Format("2006-01"), firstID, lastID)
+	if err := storage.PutObject(ctx, key, tmp, size, "application/gzip"); err != nil {
+		return "", 0, err
+	}
+
+	if _, err := db.Exec(ctx, `
+		DELETE FROM events WHERE occurred_at < $1 AND id BETWEEN $2 AND $3;
+	`, cutoff, firstID, lastID); err != nil {
+		return "", 0, err
+	}
+	return key, n, nil
+}
+
+// RegisterArchiveRoutes defines the admin endpoints for event archival runs.
+func RegisterArchiveRoutes(r *gin.Engine) {
+	admin := r.Group("/admin/archival-runs", RequireAuth(), RequireRole("admin"), RequireMFA())
+
+	// GET /admin/archival-runs — most recent runs first
+	admin.GET("", func(c *gin.Context) {
+		rows, err := db.Query(context.Background(), `
+			SELECT id, type, payload, status, progress, result, error, attempts,
+			       created_by, created_at, updated_at, finished_at
+			FROM jobs WHERE type = $1
+			ORDER BY id DESC
+			LIMIT 50;
+		`, eventArchiveJob)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		runs := []Job{}
+		for rows.Next() {
+			var job Job
+			if err := rows.Scan(&job.ID, &job.Type, &job.Payload, &job.Status, &job.Progress, &job.Result,
+				&job.Error, &job.Attempts, &job.CreatedBy, &job.CreatedAt, &job.UpdatedAt, &job.FinishedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			runs = append(runs, job)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"retention_months": cfg.EventRetentionMonths, "runs": runs})
+	})
+
+	// POST /admin/archival-runs — {"retention_months": 13}, defaults to the configured policy;
+	// ?dry_run=true reports what a run started now would archive without queuing it
+	admin.POST("", func(c *gin.Context) {
+		if storage == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is not configured"})
+			return
+		}
+
+		body := eventArchivePayload{RetentionMonths: cfg.EventRetentionMonths}
+		if c.Request.ContentLength > 0 {
+			if err := c.BindJSON(&body); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+				return
+			}
+		}
+		if body.RetentionMonths <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "retention_months must be > 0"})
+			return
+		}
+
+		if c.Query("dry_run") == "true" {
+			p, err := previewEventArchive(c.Request.Context(), eventArchiveCutoff(body.RetentionMonths))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"dry_run": true, "archive": body, "would_archive": p})
+			return
+		}
+
+		jobID, err := EnqueueJob(context.Background(), eventArchiveJob, body, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"job_id":     jobID,
+			"status":     "queued",
+			"status_url": fmt.Sprintf("/admin/archival-runs/%d", jobID),
+		})
+	})
+
+	// GET /admin/archival-runs/:id
+	admin.GET("/:id", func(c *gin.Context) {
+		jobID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+			return
+		}
+
+		job, found, err := GetJob(context.Background(), jobID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !found || job.Type != eventArchiveJob {
+			c.JSON(http.StatusNotFound, gin.H{"error": "archival run not found"})
+			return
+		}
+
+		var payload eventArchivePayload
+		json.Unmarshal(job.Payload, &payload)
+		c.JSON(http.StatusOK, gin.H{"job": job, "archive": payload})
+	})
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Artwork and waveform JSON are stored content-addressed at
+// assets/<sha256>.<ext>. A URL never changes meaning, so /assets responses
+// are cacheable forever; replacing an asset just points the song at a new
+// hash.
+const (
+	assetKeyPrefix    = "assets/"
+	assetCacheControl = "public, max-age=31536000, immutable"
+	maxArtworkBytes   = 5 << 20
+	maxWaveformBytes  = 1 << 20
+)
+
+var artworkTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/webp": "webp",
+}
+
+var assetContentTypes = map[string]string{
+	"jpg":  "image/jpeg",
+	"png":  "image/png",
+	"webp": "image/webp",
+	"json": "application/json",
+}
+
+var assetFilePattern = regexp.MustCompile(`^([0-9a-f]{64})\.(jpg|png|webp|json)$`)
+
+// assetURL is the public URL for a stored asset.
+func assetURL(hash, ext string) string {
+	return cfg.AssetBaseURL + "/assets/" + hash + "." + ext
+}
+
+// storeAssetObject uploads data under its hash, skipping the upload if that
+// content is already stored, and returns the hash.
+func storeAssetObject(ctx context.Context, ext string, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	key := assetKeyPrefix + hash + "." + ext
+
+	if _, err := storage.HeadObject(ctx, key); errors.Is(err, ErrObjectNotFound) {
+		if err := storage.PutObject(ctx, key, bytes.NewReader(data), int64(len(data)), assetContentTypes[ext]); err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// storeSongAsset stores data and points the song's asset of this kind at it
+// with the given moderation status, returning the hash.
+// The previous object is left in place: cached payloads may still use it.
+func storeSongAsset(ctx context.Context, songID int64, kind, ext, status string, data []byte) (string, error) {
+	hash, err := storeAssetObject(ctx, ext, data)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.Exec(ctx, `
+		INSERT INTO song_assets (song_id, kind, hash, ext, content_type, size_bytes, moderation_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (song_id, kind) DO UPDATE SET
+			hash = EXCLUDED.hash, ext = EXCLUDED.ext, content_type = EXCLUDED.content_type,
+			size_bytes = EXCLUDED.size_bytes, moderation_status = EXCLUDED.moderation_status, updated_at = now();
+	`, songID, kind, hash, ext, assetContentTypes[ext], len(data), status)
+	if err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// readImageBody reads a JPEG, PNG, or WebP request body of at most maxBytes,
+// writing the error response when it isn't one.
+func readImageBody(c *gin.Context, maxBytes int64, what string) ([]byte, string, bool) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read body"})
+		return nil, "", false
+	}
+	if int64(len(data)) > maxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": what + " is too large", "max_bytes": maxBytes})
+		return nil, "", false
+	}
+	sniffed := strings.SplitN(http.DetectContentType(data), ";", 2)[0]
+	ext := artworkTypes[sniffed]
+	if ext == "" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": what + " must be JPEG, PNG, or WebP"})
+		return nil, "", false
+	}
+	return data, ext, true
+}
+
+// validWaveform accepts {"peaks":[numbers...]} with at least one peak.
+func validWaveform(data []byte) bool {
+	var w struct {
+		Peaks []float64 `json:"peaks"`
+	}
+	return json.Unmarshal(data, &w) == nil && len(w.Peaks) > 0
+}
+
+// serveImmutableObject proxies a storage object whose key never changes
+// meaning, with a far-future cache and etag.
+func serveImmutableObject(c *gin.Context, key, etag, contentType string) {
+	if c.GetHeader("If-None-Match") == etag {
+		c.Header("Cache-Control", assetCacheControl)
+		c.Header("ETag", etag)
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	body, err := storage.GetObject(context.Background(), key)
+	if errors.Is(err, ErrObjectNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "asset not found"})
+		return
+	}
+	if err != nil {
+		writeStorageError(c, http.StatusBadGateway, err)
+		return
+	}
+	defer body.Close()
+
+	c.Header("Cache-Control", assetCacheControl)
+	c.Header("ETag", etag)
+	c.Header("Content-Type", contentType)
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, body)
+}
+
+// RegisterAssetRoutes defines asset uploads and the immutable asset proxy.
+func RegisterAssetRoutes(r *gin.Engine) {
+	// GET /assets/:file — <sha256>.<ext>, served with a far-future cache
+	r.GET("/assets/:file", func(c *gin.Context) {
+		if c.Param("file") == placeholderAsset {
+			c.Header("Cache-Control", placeholderCacheControl)
+			c.Data(http.StatusOK, "image/png", placeholderPNG)
+			return
+		}
+		m := assetFilePattern.FindStringSubmatch(c.Param("file"))
+		if m == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "asset not found"})
+			return
+		}
+		if storage == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is not configured"})
+			return
+		}
+
+		serveImmutableObject(c, assetKeyPrefix+m[0], `"`+m[1]+`"`, assetContentTypes[m[2]])
+	})
+
+	// PUT /songs/:id/artwork — raw JPEG, PNG, or WebP body
+	// PUT /songs/:id/waveform — {"peaks":[...]}
+	upload := func(kind string, maxBytes int64) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+				return
+			}
+			if storage == nil {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is not configured"})
+				return
+			}
+
+			owned, err := songOwnedBy(context.Background(), songID, currentUserID(c))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if !owned {
+				c.JSON(http.StatusForbidden, gin.H{"error": "you can only change assets on your own songs"})
+				return
+			}
+
+			var (
+				data   []byte
+				ext    string
+				status = moderationApproved
+			)
+			if kind == "artwork" {
+				var ok bool
+				if data, ext, ok = readImageBody(c, maxBytes, "artwork"); !ok {
+					return
+				}
+				status = initialModerationStatus()
+			} else {
+				data, err = io.ReadAll(io.LimitReader(c.Request.Body, maxBytes+1))
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "could not read body"})
+					return
+				}
+				if int64(len(data)) > maxBytes {
+					c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "asset is too large", "max_bytes": maxBytes})
+					return
+				}
+				if !validWaveform(data) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": `waveform must be {"peaks":[...]}`})
+					return
+				}
+				ext = "json"
+			}
+
+			ctx := context.Background()
+			hash, err := storeSongAsset(ctx, songID, kind, ext, status, data)
+			if err != nil {
+				writeStorageError(c, http.StatusInternalServerError, err)
+				return
+			}
+			if status == moderationPending {
+				if err := queueImageModeration(ctx, imageKindArtwork, strconv.FormatInt(songID, 10), hash, ext, currentUserID(c)); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+			}
+			resp := gin.H{"song_id": songID, "kind": kind, "url": moderatedImageURL(hash, ext, status)}
+			if kind == "artwork" {
+				resp["moderation_status"] = status
+			}
+			c.JSON(http.StatusOK, resp)
+		}
+	}
+	r.PUT("/songs/:id/artwork", RequireAuth(), upload("artwork", maxArtworkBytes))
+	r.PUT("/songs/:id/waveform", RequireAuth(), upload("waveform", maxWaveformBytes))
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Project members can attach a small image or a short voice memo to a chat
+// message or stem comment. The file is uploaded to the project first, at
+// POST /projects/:id/attachments, and its id passed with the message or
+// comment. Every attachment starts "pending" and the attachment_scan job
+// checks it: voice memos must decode and be at most maxVoiceMemoDuration,
+// and everything goes through the virus scanner. A file that passes is
+// "clean" and served through short-lived URLs; one that fails is "infected"
+// or "rejected" and its object is deleted. With no scanner configured the
+// job still checks voice memos but skips the virus scan. Attachments not
+// used within attachmentClaimWindow are pruned.
+const (
+	attachmentScanJob = "attachment_scan"
+
+	attachmentKindImage = "image"
+	attachmentKindAudio = "audio"
+
+	attachmentPending  = "pending"
+	attachmentClean    = "clean"
+	attachmentInfected = "infected"
+	attachmentRejected = "rejected"
+
+	maxAttachmentImageBytes = 5 << 20
+	maxVoiceMemoBytes       = 10 << 20
+	maxVoiceMemoDuration    = 2 * time.Minute
+	attachmentURLExpiry     = time.Hour
+
+	attachmentPruneName     = "attachment_prune"
+	attachmentPruneInterval = time.Hour
+	attachmentPruneBatch    = 100
+	attachmentClaimWindow   = 24 * time.Hour
+)
+
+// VirusScanner checks a file for malware.
+type VirusScanner interface {
+	Scan(ctx context.Context, r io.Reader) (virusVerdict, error)
+}
+
+type virusVerdict struct {
+	Infected  bool
+	Signature string
+}
+
+// virusScanner is nil when scanning is off.
+var virusScanner VirusScanner
+
+// InitVirusScanning selects the scanner from VIRUS_SCANNER.
+func InitVirusScanning() error {
+	switch cfg.VirusScanner {
+	case "":
+		return nil
+	case "clamd":
+		virusScanner = clamdScanner{addr: cfg.ClamdAddr}
+	default:
+		return fmt.Errorf("unknown VIRUS_SCANNER %q, attachments are not scanned", cfg.VirusScanner)
+	}
+	log.Printf("✅ Scanning attachments with %s", cfg.VirusScanner)
+	return nil
+}
+
+// clamdScanner streams files to clamd with the INSTREAM command.
+type clamdScanner struct {
+	addr string
+}
+
+// clamdChunk is the size of each INSTREAM chunk.
+const clamdChunk = 64 << 10
+
+func (s clamdScanner) Scan(ctx context.Context, r io.Reader) (virusVerdict, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return virusVerdict{}, err
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(2 * time.Minute)
+	}
+	conn.SetDeadline(deadline)
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("zINSTREAM\x00")
+	buf := make([]byte, clamdChunk)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			binary.Write(w, binary.BigEndian, uint32(n))
+			w.Write(buf[:n])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return virusVerdict{}, err
+		}
+	}
+	binary.Write(w, binary.BigEndian, uint32(0))
+	if err := w.Flush(); err != nil {
+		return virusVerdict{}, err
+	}
+
+	// The reply is "stream: OK", "stream: <signature> FOUND", or
+	// "<reason> ERROR", NUL-terminated.
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return virusVerdict{}, err
+	}
+	reply = strings.TrimSpace(strings.TrimSuffix(reply, "\x00"))
+	switch {
+	case strings.HasSuffix(reply, " OK"):
+		return virusVerdict{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		sig := strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+		return virusVerdict{Infected: true, Signature: sig}, nil
+	}
+	return virusVerdict{}, fmt.Errorf("clamd: %s", reply)
+}
+
+const attachmentColumns = `id, project_id, uploader_id::text, kind, content_type, size_bytes, storage_key, storage_region,
+	duration_ms, scan_status, created_at`
+
+func scanAttachment(row pgx.Row) (ProjectAttachment, error) {
+	var a ProjectAttachment
+	err := row.Scan(&a.ID, &a.ProjectID, &a.UploaderID, &a.Kind, &a.ContentType, &a.SizeBytes, &a.StorageKey,
+		&a.StorageRegion, &a.DurationMs, &a.ScanStatus, &a.CreatedAt)
+	if err == nil && a.ScanStatus == attachmentClean && storage != nil {
+		url := storageFor(a.StorageRegion).PresignGet(a.StorageKey, attachmentURLExpiry)
+		a.URL = &url
+	}
+	return a, err
+}
+
+// loadAttachments returns the attachments with ids, for attaching to
+// messages and comments.
+func loadAttachments(ctx context.Context, ids []int64) (map[int64]ProjectAttachment, error) {
+	attachments := map[int64]ProjectAttachment{}
+	if len(ids) == 0 {
+		return attachments, nil
+	}
+	rows, err := db.Query(ctx, `SELECT `+attachmentColumns+` FROM project_attachments WHERE id = ANY ($1);`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments[a.ID] = a
+	}
+	return attachments, rows.Err()
+}
+
+// attachmentUsed is the condition that attachment a is already on a
+// message or comment.
+const attachmentUsed = `(EXISTS (SELECT 1 FROM project_messages m WHERE m.attachment_id = a.id)
+	OR EXISTS (SELECT 1 FROM stem_comments sc WHERE sc.attachment_id = a.id))`
+
+// checkAttachment makes sure the caller may attach attachmentID in
+// projectID: it is theirs, unused, and not failed. It writes the error
+// response when not.
+func checkAttachment(c *gin.Context, projectID, attachmentID int64) bool {
+	var status string
+	var used bool
+	err := db.QueryRow(c.Request.Context(), `
+		SELECT a.scan_status, `+attachmentUsed+` FROM project_attachments a
+		WHERE a.id = $1 AND a.project_id = $2 AND a.uploader_id = $3;
+	`, attachmentID, projectID, currentUserID(c)).Scan(&status, &used)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if status != attachmentPending && status != attachmentClean {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "attachment failed its checks", "scan_status": status})
+		return false
+	}
+	if used {
+		c.JSON(http.StatusConflict, gin.H{"error": "attachment is already in use"})
+		return false
+	}
+	return true
+}
+
+// writeAttachError writes the response for a failed message or comment
+// insert; two posts racing for one attachment clash on its unique index.
+func writeAttachError(c *gin.Context, err error) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		c.JSON(http.StatusConflict, gin.H{"error": "attachment is already in use"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// attachmentBody reads an attachment upload: a JPEG, PNG, or WebP image,
+// recognised by its content, or a voice memo in one of the audio types,
+// by its Content-Type. It writes the error response when refused.
+func attachmentBody(c *gin.Context) (data []byte, kind, contentType, ext string, ok bool) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxVoiceMemoBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read body"})
+		return nil, "", "", "", false
+	}
+	if len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "attachment is empty"})
+		return nil, "", "", "", false
+	}
+
+	sniffed := strings.SplitN(http.DetectContentType(data), ";", 2)[0]
+	declared := strings.TrimSpace(strings.SplitN(c.GetHeader("Content-Type"), ";", 2)[0])
+	switch {
+	case artworkTypes[sniffed] != "":
+		if len(data) > maxAttachmentImageBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "image is too large", "max_bytes": maxAttachmentImageBytes})
+			return nil, "", "", "", false
+		}
+		return data, attachmentKindImage, sniffed, artworkTypes[sniffed], true
+	case audioTypes[declared] != "":
+		if len(data) > maxVoiceMemoBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "voice memo is too large", "max_bytes": maxVoiceMemoBytes})
+			return nil, "", "", "", false
+		}
+		return data, attachmentKindAudio, declared, audioTypes[declared], true
+	}
+	c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "attachment must be a JPEG, PNG, or WebP image or an audio file",
+		"content_type": declared})
+	return nil, "", "", "", false
+}
+
+type attachmentScanPayload struct {
+	AttachmentID int64 `json:"attachment_id"`
+}
+
+func init() {
+	RegisterJobHandler(attachmentScanJob, runAttachmentScan)
+}
+
+// failAttachment marks a failed and removes its object.
+func failAttachment(ctx context.Context, a ProjectAttachment, status, reason string) error {
+	if err := storageFor(a.StorageRegion).DeleteObject(ctx, a.StorageKey); err != nil {
+		log.Printf("attachment %d: failed to delete %s object %s: %v", a.ID, status, a.StorageKey, err)
+	}
+	_, err := db.Exec(ctx, `
+		UPDATE project_attachments SET scan_status = $2, scan_result = $3, scanned_at = now() WHERE id = $1;
+	`, a.ID, status, reason)
+	return err
+}
+
+func runAttachmentScan(ctx context.Context, job *Job) (interface{}, error) {
+	var p attachmentScanPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return nil, err
+	}
+	a, err := scanAttachment(db.QueryRow(ctx,
+		`SELECT `+attachmentColumns+` FROM project_attachments WHERE id = $1;`, p.AttachmentID))
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && a.ScanStatus != attachmentPending) {
+		return gin.H{"skipped": true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if storage == nil {
+		return nil, fmt.Errorf("storage is not configured")
+	}
+	store := storageFor(a.StorageRegion)
+
+	if a.Kind == attachmentKindAudio {
+		probe, err := probeAudio(ctx, store.PresignGet(a.StorageKey, processingURLExpiry))
+		if err != nil {
+			return gin.H{"scan_status": attachmentRejected},
+				failAttachment(ctx, a, attachmentRejected, "voice memo could not be decoded")
+		}
+		if probe.DurationMs == nil || time.Duration(*probe.DurationMs)*time.Millisecond > maxVoiceMemoDuration {
+			return gin.H{"scan_status": attachmentRejected},
+				failAttachment(ctx, a, attachmentRejected, fmt.Sprintf("voice memo must be at most %s", maxVoiceMemoDuration))
+		}
+		a.DurationMs = probe.DurationMs
+	}
+
+	result := "not scanned"
+	if virusScanner != nil {
+		body, err := store.GetObject(ctx, a.StorageKey)
+		if err != nil {
+			return nil, err
+		}
+		v, err := virusScanner.Scan(ctx, body)
+		body.Close()
+		if err != nil {
+			// Unscanned files are never served: once retries run out, the
+			// attachment is refused.
+			if job.Attempts >= jobMaxAttempts {
+				if ferr := failAttachment(ctx, a, attachmentRejected, "virus scan failed: "+err.Error()); ferr != nil {
+					log.Printf("attachment %d: failed to reject: %v", a.ID, ferr)
+				}
+			}
+			return nil, err
+		}
+		if v.Infected {
+			return gin.H{"scan_status": attachmentInfected, "signature": v.Signature},
+				failAttachment(ctx, a, attachmentInfected, v.Signature)
+		}
+		result = "clean"
+	}
+
+	if _, err := db.Exec(ctx, `
+		UPDATE project_attachments SET scan_status = $2, scan_result = $3, duration_ms = $4, scanned_at = now()
+		WHERE id = $1 AND scan_status = $5;
+	`, a.ID, attachmentClean, result, a.DurationMs, attachmentPending); err != nil {
+		return nil, err
+	}
+	return gin.H{"scan_status": attachmentClean, "duration_ms": a.DurationMs}, nil
+}
+
+// pruneAttachments removes attachments nobody used within the claim
+// window, failed ones included, and the attachments of deleted messages
+// and stems.
+func pruneAttachments(ctx context.Context) error {
+	if storage == nil {
+		return nil
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT `+attachmentColumns+` FROM project_attachments a
+		WHERE a.created_at < $1 AND NOT `+attachmentUsed+`
+		ORDER BY a.id
+		LIMIT $2;
+	`, time.Now().Add(-attachmentClaimWindow), attachmentPruneBatch)
+	if err != nil {
+		return err
+	}
+	attachments := []ProjectAttachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		attachments = append(attachments, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, a := range attachments {
+		if a.ScanStatus != attachmentInfected && a.ScanStatus != attachmentRejected {
+			if err := storageFor(a.StorageRegion).DeleteObject(ctx, a.StorageKey); err != nil && !errors.Is(err, ErrObjectNotFound) {
+				log.Printf("attachment %d: failed to delete %s: %v", a.ID, a.StorageKey, err)
+				continue
+			}
+		}
+		if _, err := db.Exec(ctx, `DELETE FROM project_attachments WHERE id = $1;`, a.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterAttachmentRoutes defines attachment uploads for project chat and
+// stem comments.
+func RegisterAttachmentRoutes(r *gin.Engine) {
+	// POST /projects/:id/attachments — raw image or voice memo body; members only.
+	// Pass the returned id as attachment_id on a message or stem comment.
+	r.POST("/projects/:id/attachments", RequireProjectAccess(), func(c *gin.Context) {
+		if storage == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is not configured"})
+			return
+		}
+		data, kind, contentType, ext, ok := attachmentBody(c)
+		if !ok {
+			return
+		}
+
+		ctx := c.Request.Context()
+		projectID := c.GetInt64("project_id")
+		region := uploadRegion(c)
+		key := fmt.Sprintf("projects/%d/attachments/%d.%s", projectID, time.Now().UnixNano(), ext)
+		if err := storageFor(region).PutObject(ctx, key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+			writeStorageError(c, http.StatusBadGateway, err)
+			return
+		}
+
+		a, err := scanAttachment(db.QueryRow(ctx, `
+			INSERT INTO project_attachments (project_id, uploader_id, kind, content_type, size_bytes, storage_key, storage_region)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING `+attachmentColumns+`;
+		`, projectID, currentUserID(c), kind, contentType, len(data), key, region))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		jobID, err := EnqueueJob(ctx, attachmentScanJob, attachmentScanPayload{AttachmentID: a.ID}, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"attachment": a, "job_id": jobID})
+	})
+
+	// GET /projects/:id/attachments/:attachmentId — members and guests; poll until scan_status is final
+	r.GET("/projects/:id/attachments/:attachmentId", RequireProjectAccess(), func(c *gin.Context) {
+		attachmentID, err := strconv.ParseInt(c.Param("attachmentId"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid attachment id"})
+			return
+		}
+		a, err := scanAttachment(db.QueryRow(c.Request.Context(), `
+			SELECT `+attachmentColumns+` FROM project_attachments WHERE id = $1 AND project_id = $2;
+		`, attachmentID, c.GetInt64("project_id")))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, a)
+	})
+}
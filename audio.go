@@ -0,0 +1,567 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Song audio is stored as renditions. The artist's upload is the
+// "original"; each upload queues an audio_processing job that analyses the
+// file and marks the rendition ready or failed. A song can only be
+// published once its original is ready.
+const (
+	audioProcessingJob  = "audio_processing"
+	audioKeyPrefix      = "audio/"
+	originalRendition   = "original"
+	maxAudioBytes       = 500 << 20
+	processingURLExpiry = time.Hour
+	// Long enough for a player to start; it comes back here to resume.
+	streamURLExpiry = 10 * time.Minute
+
+	// Declared and decoded durations may differ by the larger of these.
+	durationTolerance      = 2 * time.Second
+	durationToleranceRatio = 0.02
+	// ebur128's absolute gate: anything quieter measures as silence.
+	silenceFloorLUFS = -70.0
+)
+
+// Processing error codes, stable for clients to branch on.
+const (
+	processingDecodeFailed     = "decode_failed"
+	processingEmpty            = "empty"
+	processingSilent           = "silent"
+	processingDurationMismatch = "duration_mismatch"
+)
+
+var audioTypes = map[string]string{
+	"audio/mpeg":   "mp3",
+	"audio/wav":    "wav",
+	"audio/x-wav":  "wav",
+	"audio/flac":   "flac",
+	"audio/x-flac": "flac",
+	"audio/aac":    "aac",
+	"audio/mp4":    "m4a",
+	"audio/ogg":    "ogg",
+}
+
+type audioProcessingPayload struct {
+	SongID    int64  `json:"song_id"`
+	Rendition string `json:"rendition"`
+}
+
+func init() {
+	RegisterJobHandler(audioProcessingJob, runAudioProcessing)
+}
+
+const renditionColumns = `song_id, name, storage_key, storage_region, content_type, size_bytes, status, error_code, error,
+	duration_ms, bit_rate, sample_rate, channels, codec, integrated_lufs, true_peak_dbtp, source_key, processed_at`
+
+func scanRendition(row pgx.Row) (SongRendition, error) {
+	var r SongRendition
+	err := row.Scan(&r.SongID, &r.Name, &r.StorageKey, &r.StorageRegion, &r.ContentType, &r.SizeBytes, &r.Status, &r.ErrorCode,
+		&r.Error, &r.DurationMs, &r.BitRate, &r.SampleRate, &r.Channels, &r.Codec, &r.IntegratedLUFS, &r.TruePeakDBTP, &r.SourceKey, &r.ProcessedAt)
+	r.ReplayGainDB = replayGain(r.IntegratedLUFS, r.TruePeakDBTP)
+	return r, err
+}
+
+func loadRenditions(ctx context.Context, songID int64) ([]SongRendition, error) {
+	rows, err := db.Query(ctx,
+		`SELECT `+renditionColumns+` FROM song_renditions WHERE song_id = $1 ORDER BY name;`, songID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	renditions := []SongRendition{}
+	for rows.Next() {
+		r, err := scanRendition(rows)
+		if err != nil {
+			return nil, err
+		}
+		renditions = append(renditions, r)
+	}
+	return renditions, rows.Err()
+}
+
+// processingError is a validation failure the artist fixes by uploading
+// again, so it is recorded straight away rather than retried.
+type processingError struct {
+	Code    string
+	Message string
+}
+
+// validateAnalysis checks a decoded rendition against the song.
+func validateAnalysis(a audioAnalysis, declaredSeconds *int) *processingError {
+	if a.DurationMs <= 0 {
+		return &processingError{processingEmpty,
+			"The file contains no audio. Export the track again and re-upload it."}
+	}
+	if a.IntegratedLUFS == nil || *a.IntegratedLUFS <= silenceFloorLUFS {
+		return &processingError{processingSilent,
+			"The audio is silent. Check that the export isn't an empty or muted mix, then re-upload it."}
+	}
+	if declaredSeconds != nil {
+		declared := time.Duration(*declaredSeconds) * time.Second
+		decoded := time.Duration(a.DurationMs) * time.Millisecond
+		tolerance := time.Duration(float64(declared) * durationToleranceRatio)
+		if tolerance < durationTolerance {
+			tolerance = durationTolerance
+		}
+		if diff := decoded - declared; diff > tolerance || diff < -tolerance {
+			return &processingError{processingDurationMismatch, fmt.Sprintf(
+				"The audio is %s long but the song is declared as %s. Upload the full track or correct the song's duration.",
+				formatTrackTime(decoded), formatTrackTime(declared))}
+		}
+	}
+	return nil
+}
+
+// formatTrackTime renders d as m:ss.
+func formatTrackTime(d time.Duration) string {
+	s := int(d.Round(time.Second) / time.Second)
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+func runAudioProcessing(ctx context.Context, job *Job) (interface{}, error) {
+	var p audioProcessingPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return nil, err
+	}
+
+	r, err := scanRendition(db.QueryRow(ctx,
+		`SELECT `+renditionColumns+` FROM song_renditions WHERE song_id = $1 AND name = $2;`,
+		p.SongID, p.Rendition))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return gin.H{"skipped": true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if storage == nil {
+		return nil, fmt.Errorf("storage is not configured")
+	}
+
+	// A failed decode may be transient (storage, network), so it is retried
+	// and only reported to the artist once attempts run out.
+	a, err := analyzeAudio(ctx, storageFor(r.StorageRegion).PresignGet(r.StorageKey, processingURLExpiry))
+	if err != nil {
+		if job.Attempts >= jobMaxAttempts {
+			failRendition(ctx, r, &processingError{processingDecodeFailed, fmt.Sprintf(
+				"The file could not be decoded (%v). It may be truncated or corrupt; re-export it and upload again.", err)})
+		}
+		return nil, err
+	}
+	probe, err := probeAudio(ctx, storageFor(r.StorageRegion).PresignGet(r.StorageKey, processingURLExpiry))
+	if err != nil {
+		return nil, err
+	}
+
+	var declared *int
+	if err := db.QueryRow(ctx, `SELECT duration_seconds FROM songs WHERE id = $1;`, r.SongID).Scan(&declared); err != nil {
+		return nil, err
+	}
+	if perr := validateAnalysis(a, declared); perr != nil {
+		failRendition(ctx, r, perr)
+		return gin.H{"status": "failed", "error_code": perr.Code}, nil
+	}
+
+	// The storage key guards against a re-upload that landed mid-job.
+	_, err = db.Exec(ctx, `
+		UPDATE song_renditions
+		SET status = 'ready', error_code = NULL, error = NULL, duration_ms = $4,
+		    integrated_lufs = $5, true_peak_dbtp = $6, bit_rate = $7, sample_rate = $8, channels = $9, codec = $10,
+		    processed_at = now()
+		WHERE song_id = $1 AND name = $2 AND storage_key = $3;
+	`, r.SongID, r.Name, r.StorageKey, a.DurationMs, a.IntegratedLUFS, a.TruePeakDBTP,
+		probe.BitRate, probe.SampleRate, probe.Channels, probe.Codec)
+	if err != nil {
+		return nil, err
+	}
+	if r.Name == originalRendition {
+		if _, err := db.Exec(ctx, `UPDATE songs SET duration_seconds = COALESCE(duration_seconds, $2) WHERE id = $1;`,
+			r.SongID, (a.DurationMs+500)/1000); err != nil {
+			return nil, err
+		}
+		if _, err := db.Exec(ctx, `UPDATE song_audio_versions SET duration_ms = $3 WHERE song_id = $1 AND storage_key = $2;`,
+			r.SongID, r.StorageKey, a.DurationMs); err != nil {
+			return nil, err
+		}
+		if _, err := EnqueueJob(ctx, audioTranscodeJob,
+			audioTranscodePayload{SongID: r.SongID, SourceKey: r.StorageKey}, ""); err != nil {
+			return nil, err
+		}
+	}
+	return gin.H{"status": "ready", "duration_ms": a.DurationMs, "integrated_lufs": a.IntegratedLUFS, "true_peak_dbtp": a.TruePeakDBTP,
+		"bit_rate": probe.BitRate, "sample_rate": probe.SampleRate, "codec": probe.Codec}, nil
+}
+
+func failRendition(ctx context.Context, r SongRendition, perr *processingError) {
+	_, err := db.Exec(ctx, `
+		UPDATE song_renditions
+		SET status = 'failed', error_code = $4, error = $5, processed_at = now()
+		WHERE song_id = $1 AND name = $2 AND storage_key = $3;
+	`, r.SongID, r.Name, r.StorageKey, perr.Code, perr.Message)
+	if err != nil {
+		log.Printf("rendition %d/%s: failed to record failure: %v", r.SongID, r.Name, err)
+	}
+}
+
+// ownedSongID parses :id and checks the caller owns it, writing the error
+// response when not.
+func ownedSongID(c *gin.Context, action string) (int64, bool) {
+	songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+		return 0, false
+	}
+	owned, err := songOwnedBy(context.Background(), songID, currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return 0, false
+	}
+	if !owned {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only " + action + " your own songs"})
+		return 0, false
+	}
+	return songID, true
+}
+
+// audioUpload describes a raw audio request body.
+type audioUpload struct {
+	ContentType string
+	Ext         string
+	Size        int64
+}
+
+// audioUploadFrom checks that the request body is a supported audio type of
+// a known, acceptable size and that storage is available, writing the error
+// response when not.
+func audioUploadFrom(c *gin.Context) (audioUpload, bool) {
+	if storage == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is not configured"})
+		return audioUpload{}, false
+	}
+
+	contentType := strings.TrimSpace(strings.SplitN(c.GetHeader("Content-Type"), ";", 2)[0])
+	ext := audioTypes[contentType]
+	if ext == "" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "unsupported audio type", "content_type": contentType})
+		return audioUpload{}, false
+	}
+	size := c.Request.ContentLength
+	if size <= 0 {
+		c.JSON(http.StatusLengthRequired, gin.H{"error": "Content-Length is required"})
+		return audioUpload{}, false
+	}
+	if size > maxAudioBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "audio is too large", "max_bytes": maxAudioBytes})
+		return audioUpload{}, false
+	}
+	return audioUpload{ContentType: contentType, Ext: ext, Size: size}, true
+}
+
+// attachSongAudio makes the stored object at key the song's original
+// rendition, recording it as the song's next audio version, and queues
+// processing. The audio it replaces is kept as an earlier version.
+func attachSongAudio(ctx context.Context, songID int64, key, region, contentType string, size int64, userID string) (SongRendition, int64, error) {
+	if err := recordAudioVersion(ctx, songID, key, region, contentType, size, userID); err != nil {
+		return SongRendition{}, 0, err
+	}
+	rend, jobID, err := setOriginalAudio(ctx, songID, key, region, contentType, size, userID)
+	if err != nil {
+		return SongRendition{}, 0, err
+	}
+	if err := pruneAudioVersions(ctx, songID); err != nil {
+		log.Printf("song %d: failed to prune audio versions: %v", songID, err)
+	}
+	return rend, jobID, nil
+}
+
+// setOriginalAudio points the song's original rendition at the stored
+// object at key and queues processing.
+func setOriginalAudio(ctx context.Context, songID int64, key, region, contentType string, size int64, userID string) (SongRendition, int64, error) {
+	rend, err := scanRendition(db.QueryRow(ctx, `
+		INSERT INTO song_renditions (song_id, name, storage_key, storage_region, content_type, size_bytes)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (song_id, name) DO UPDATE SET
+			storage_key = EXCLUDED.storage_key, storage_region = EXCLUDED.storage_region, content_type = EXCLUDED.content_type,
+			size_bytes = EXCLUDED.size_bytes, status = 'pending', error_code = NULL, error = NULL,
+			duration_ms = NULL, integrated_lufs = NULL, true_peak_dbtp = NULL,
+			created_at = now(), processed_at = NULL
+		RETURNING `+renditionColumns+`;
+	`, songID, originalRendition, key, region, contentType, size))
+	if err != nil {
+		return SongRendition{}, 0, err
+	}
+
+	jobID, err := EnqueueJob(ctx, audioProcessingJob,
+		audioProcessingPayload{SongID: songID, Rendition: originalRendition}, userID)
+	if err != nil {
+		return SongRendition{}, 0, err
+	}
+	return rend, jobID, nil
+}
+
+// RegisterAudioRoutes defines song audio upload, processing status, and
+// publishing.
+func RegisterAudioRoutes(r *gin.Engine) {
+	// PUT /songs/:id/audio — raw audio body with its Content-Type and Content-Length
+	r.PUT("/songs/:id/audio", RequireAuth(), func(c *gin.Context) {
+		songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+			return
+		}
+		upload, ok := audioUploadFrom(c)
+		if !ok {
+			return
+		}
+
+		owned, err := songOwnedBy(context.Background(), songID, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !owned {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you can only upload audio to your own songs"})
+			return
+		}
+
+		key := fmt.Sprintf("%s%d/%d/%s.%s", audioKeyPrefix, songID, time.Now().UnixNano(), originalRendition, upload.Ext)
+		region := uploadRegion(c)
+		if err := storageFor(region).PutObject(context.Background(), key, c.Request.Body, upload.Size, upload.ContentType); err != nil {
+			writeStorageError(c, http.StatusBadGateway, err)
+			return
+		}
+
+		rend, jobID, err := attachSongAudio(context.Background(), songID, key, region, upload.ContentType, upload.Size, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"rendition": rend, "job_id": jobID})
+	})
+
+	// GET /songs/:id/processing — per-rendition status and actionable errors
+	r.GET("/songs/:id/processing", RequireAuth(), RequireTeamScope(scopeReleasesManage), func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "view processing for")
+		if !ok {
+			return
+		}
+
+		var published bool
+		if err := db.QueryRow(context.Background(), `SELECT published FROM songs WHERE id = $1;`, songID).Scan(&published); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		renditions, err := loadRenditions(context.Background(), songID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		publishable := false
+		for _, rend := range renditions {
+			if rend.Name == originalRendition {
+				publishable = rend.Status == "ready"
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"song_id":     songID,
+			"published":   published,
+			"publishable": publishable,
+			"renditions":  renditions,
+		})
+	})
+
+	// POST /songs/:id/publish — only once the uploaded audio passed processing.
+	// Optional body {"cross_post":["twitter"]} picks the social accounts to
+	// announce on; without it every connection with auto_post is used.
+	r.POST("/songs/:id/publish", RequireAuth(), RequireTeamScope(scopeReleasesManage), func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "publish")
+		if !ok {
+			return
+		}
+		var body struct {
+			CrossPost []string `json:"cross_post"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&body); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+				return
+			}
+		}
+		for _, p := range body.CrossPost {
+			if !socialProviders[p] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported provider %q", p)})
+				return
+			}
+		}
+
+		rend, err := scanRendition(db.QueryRow(context.Background(),
+			`SELECT `+renditionColumns+` FROM song_renditions WHERE song_id = $1 AND name = $2;`,
+			songID, originalRendition))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "upload audio before publishing"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		switch rend.Status {
+		case "pending":
+			c.JSON(http.StatusConflict, gin.H{"error": "audio is still processing"})
+			return
+		case "failed":
+			c.JSON(http.StatusConflict, gin.H{
+				"error":      "audio failed processing",
+				"error_code": rend.ErrorCode,
+				"detail":     rend.Error,
+			})
+			return
+		}
+
+		ctx := context.Background()
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback(ctx)
+		tag, err := tx.Exec(ctx, `UPDATE songs SET published = true WHERE id = $1 AND NOT published;`, songID)
+		if err == nil && tag.RowsAffected() > 0 {
+			err = appendDomainEvent(ctx, tx, domainSongPublished, "song", strconv.FormatInt(songID, 10), currentUserID(c), nil)
+		}
+		if err == nil {
+			err = tx.Commit(ctx)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// The song is out either way; a cross-post problem is reported, not fatal.
+		resp := gin.H{"song_id": songID, "published": true}
+		posts, err := crossPostRelease(context.Background(), currentUserID(c), songID, body.CrossPost)
+		if err != nil {
+			log.Printf("song %d: cross-posting failed: %v", songID, err)
+			resp["cross_post_error"] = err.Error()
+		}
+		resp["social_posts"] = posts
+		c.JSON(http.StatusOK, resp)
+	})
+	// GET /songs/:id/stream?format=original|hls|preview — counts the play and
+	// serves the audio: a redirect to a short-lived URL (Range requests go
+	// straight to storage), or for hls the manifest with signed segment URLs.
+	// Artists can play their own unpublished songs, which isn't counted.
+	r.GET("/songs/:id/stream", OptionalAuth(), func(c *gin.Context) {
+		songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+			return
+		}
+		format := c.DefaultQuery("format", originalRendition)
+		if format != originalRendition && format != hlsRendition && format != previewRendition {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be original, hls, or preview"})
+			return
+		}
+		ctx := c.Request.Context()
+		userID := currentUserID(c)
+
+		var (
+			published, explicit bool
+			artistID            *string
+		)
+		err = db.QueryRow(ctx, `SELECT published, explicit, artist_id::text FROM songs WHERE id = $1 AND trashed_at IS NULL;`,
+			songID).Scan(&published, &explicit, &artistID)
+		own := err == nil && userID != "" && artistID != nil && *artistID == userID
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && !published && !own) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "song not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if explicit && !own && abortIfExplicit(c, "this song") {
+			return
+		}
+
+		renditions, err := loadRenditions(ctx, songID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var orig, rend *SongRendition
+		for i := range renditions {
+			switch renditions[i].Name {
+			case originalRendition:
+				orig = &renditions[i]
+			case format:
+				rend = &renditions[i]
+			}
+		}
+		if format == originalRendition {
+			rend = orig
+		}
+		if orig == nil || orig.Status != "ready" {
+			c.JSON(http.StatusConflict, gin.H{"error": "song audio is not ready"})
+			return
+		}
+		// Transcodes of an earlier upload are stale until the job catches up.
+		if rend == nil || rend.Status != "ready" ||
+			(format != originalRendition && (rend.SourceKey == nil || *rend.SourceKey != orig.StorageKey)) {
+			c.JSON(http.StatusConflict, gin.H{"error": "the " + format + " stream is not ready yet; play the original"})
+			return
+		}
+		if storage == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is not configured"})
+			return
+		}
+
+		// A player resuming or seeking sends a later range; only the
+		// start of the file counts as a play.
+		if r := c.GetHeader("Range"); published && !own && (r == "" || strings.HasPrefix(r, "bytes=0-")) {
+			e := IngestEvent{
+				SchemaVersion: currentEventSchemaVersion,
+				EventType:     "play",
+				SongID:        songID,
+				OccurredAt:    time.Now().UTC(),
+				Properties:    map[string]interface{}{"source": "stream", "format": format},
+			}
+			if userID != "" {
+				e.UserID = &userID
+			}
+			if err := publishBeacon(c, e); err != nil {
+				log.Printf("song %d: failed to record stream play: %v", songID, err)
+			}
+		}
+
+		c.Header("Cache-Control", "no-store")
+		store := storageFor(rend.StorageRegion)
+		if format == hlsRendition {
+			// Segments are fetched as playback reaches them, so their URLs
+			// have to last the whole song.
+			manifest, err := signedHLSManifest(ctx, store, rend.StorageKey, streamURLExpiry+hlsSegmentURLGrace(rend.DurationMs))
+			if err != nil {
+				writeStorageError(c, http.StatusBadGateway, err)
+				return
+			}
+			c.Data(http.StatusOK, hlsContentType, manifest)
+			return
+		}
+		c.Redirect(http.StatusFound, store.PresignGet(rend.StorageKey, streamURLExpiry))
+	})
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Renditions are analysed with ffmpeg: a full decode (which doubles as
+// corruption detection) through the ebur128 filter (ITU-R BS.1770) for
+// integrated loudness and true peak. Players apply replayGain to bring
+// every song to the same perceived level.
+const (
+	loudnessTargetLUFS  = -14.0
+	loudnessCeilingDBTP = -1.0
+	audioAnalysisLimit  = 10 * time.Minute
+)
+
+var (
+	integratedLoudnessPattern = regexp.MustCompile(`I:\s+(-?[0-9.]+|-inf) LUFS`)
+	truePeakPattern           = regexp.MustCompile(`Peak:\s+(-?[0-9.]+|-inf) dBFS`)
+	progressTimePattern       = regexp.MustCompile(`(?m)^out_time_us=([0-9]+)$`)
+)
+
+// audioAnalysis is what one ffmpeg pass over a rendition tells us.
+type audioAnalysis struct {
+	DurationMs     int64
+	IntegratedLUFS *float64
+	TruePeakDBTP   *float64
+}
+
+// analyzeAudio decodes all of inputURL with ffmpeg, failing on the first
+// decode error (-xerror), and measures loudness on the way. Either loudness
+// value is nil when the audio is silent enough that ffmpeg reports -inf.
+func analyzeAudio(ctx context.Context, inputURL string) (audioAnalysis, error) {
+	ctx, cancel := context.WithTimeout(ctx, audioAnalysisLimit)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, cfg.FFmpegPath,
+		"-nostats", "-hide_banner", "-xerror", "-i", inputURL,
+		"-af", "ebur128=peak=true", "-progress", "pipe:1", "-f", "null", "-")
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return audioAnalysis{}, fmt.Errorf("ffmpeg: %v: %s", err, lastLines(stderr.Bytes(), 3))
+	}
+
+	// Per-frame lines also contain "I: ... LUFS"; the summary comes last.
+	out := stderr.String()
+	lufsMatches := integratedLoudnessPattern.FindAllStringSubmatch(out, -1)
+	peakMatches := truePeakPattern.FindAllStringSubmatch(out, -1)
+	if len(lufsMatches) == 0 || len(peakMatches) == 0 {
+		return audioAnalysis{}, fmt.Errorf("ffmpeg: no loudness summary in output")
+	}
+
+	// -progress reports out_time_us as decoding advances; the last is the total.
+	var a audioAnalysis
+	for _, m := range progressTimePattern.FindAllStringSubmatch(stdout.String(), -1) {
+		if us, err := strconv.ParseInt(m[1], 10, 64); err == nil {
+			a.DurationMs = us / 1000
+		}
+	}
+	a.IntegratedLUFS = parseDB(lufsMatches[len(lufsMatches)-1][1])
+	a.TruePeakDBTP = parseDB(peakMatches[len(peakMatches)-1][1])
+	return a, nil
+}
+
+// probeDuration reads the duration ffprobe reports for inputURL, which is
+// cheap enough to run over every stem in a project.
+func probeDuration(ctx context.Context, inputURL string) (float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, cfg.FFprobePath,
+		"-v", "error", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", inputURL)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("ffprobe: %v: %s", err, lastLines(stderr.Bytes(), 3))
+	}
+	seconds, err := strconv.ParseFloat(string(bytes.TrimSpace(stdout.Bytes())), 64)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe: no duration for input")
+	}
+	return seconds, nil
+}
+
+// audioProbe is the container and first audio stream as ffprobe reports
+// them. Fields the file doesn't carry are nil.
+type audioProbe struct {
+	DurationMs *int64
+	BitRate    *int64
+	SampleRate *int
+	Channels   *int
+	Codec      *string
+}
+
+// probeAudio reads inputURL's format and first audio stream with ffprobe.
+// The bitrate is the stream's, falling back to the container's for codecs
+// such as FLAC that don't state one.
+func probeAudio(ctx context.Context, inputURL string) (audioProbe, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, cfg.FFprobePath,
+		"-v", "error", "-select_streams", "a:0",
+		"-show_entries", "format=duration,bit_rate:stream=codec_name,sample_rate,channels,bit_rate",
+		"-of", "json", inputURL)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return audioProbe{}, fmt.Errorf("ffprobe: %v: %s", err, lastLines(stderr.Bytes(), 3))
+	}
+
+	var out struct {
+		Streams []struct {
+			CodecName  string `json:"codec_name"`
+			SampleRate string `json:"sample_rate"`
+			Channels   int    `json:"channels"`
+			BitRate    string `json:"bit_rate"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+			BitRate  string `json:"bit_rate"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return audioProbe{}, fmt.Errorf("ffprobe: %v", err)
+	}
+	if len(out.Streams) == 0 {
+		return audioProbe{}, fmt.Errorf("ffprobe: no audio stream in input")
+	}
+	st := out.Streams[0]
+
+	var p audioProbe
+	if seconds, err := strconv.ParseFloat(out.Format.Duration, 64); err == nil {
+		ms := int64(seconds * 1000)
+		p.DurationMs = &ms
+	}
+	for _, s := range []string{st.BitRate, out.Format.BitRate} {
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil && v > 0 {
+			p.BitRate = &v
+			break
+		}
+	}
+	if v, err := strconv.Atoi(st.SampleRate); err == nil && v > 0 {
+		p.SampleRate = &v
+	}
+	if st.Channels > 0 {
+		p.Channels = &st.Channels
+	}
+	if st.CodecName != "" {
+		p.Codec = &st.CodecName
+	}
+	return p, nil
+}
+
+func parseDB(s string) *float64 {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// lastLines returns the final n lines of out, for error messages.
+func lastLines(out []byte, n int) string {
+	lines := bytes.Split(bytes.TrimSpace(out), []byte("\n"))
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return string(bytes.Join(lines, []byte(" | ")))
+}
+
+// replayGain is the gain in dB that brings a rendition to the target
+// loudness without pushing its true peak above the ceiling.
+func replayGain(lufs, peak *float64) *float64 {
+	if lufs == nil {
+		return nil
+	}
+	gain := loudnessTargetLUFS - *lufs
+	if peak != nil && *peak+gain > loudnessCeilingDBTP {
+		gain = loudnessCeilingDBTP - *peak
+	}
+	return &gain
+}
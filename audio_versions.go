@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Each upload of a song's audio is kept as a numbered version instead of
+// replacing the last one, so the artist can swap in a new master without
+// losing the song's comments and stats, and roll back if it was a mistake.
+// Restoring a version points the original rendition back at its file and
+// processes it again. The newest maxAudioVersions are kept, plus whichever
+// one is current.
+const maxAudioVersions = 10
+
+const audioVersionColumns = `v.song_id, v.version, v.storage_key, v.storage_region, v.content_type, v.size_bytes,
+	v.duration_ms, v.uploaded_by::text, COALESCE(r.storage_key = v.storage_key, false), v.created_at`
+
+const audioVersionFrom = `song_audio_versions v
+	LEFT JOIN song_renditions r ON r.song_id = v.song_id AND r.name = 'original'`
+
+func scanAudioVersion(row pgx.Row) (SongAudioVersion, error) {
+	var v SongAudioVersion
+	err := row.Scan(&v.SongID, &v.Version, &v.StorageKey, &v.StorageRegion, &v.ContentType, &v.SizeBytes,
+		&v.DurationMs, &v.UploadedBy, &v.Current, &v.CreatedAt)
+	return v, err
+}
+
+// recordAudioVersion adds the stored object at key as songID's next version.
+func recordAudioVersion(ctx context.Context, songID int64, key, region, contentType string, size int64, userID string) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO song_audio_versions (song_id, version, storage_key, storage_region, content_type, size_bytes, uploaded_by)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4, $5, NULLIF($6, '')::uuid
+		FROM song_audio_versions WHERE song_id = $1;
+	`, songID, key, region, contentType, size, userID)
+	return err
+}
+
+// pruneAudioVersions deletes all but the newest maxAudioVersions of
+// songID's versions, never the current one.
+func pruneAudioVersions(ctx context.Context, songID int64) error {
+	rows, err := db.Query(ctx, `
+		SELECT `+audioVersionColumns+` FROM `+audioVersionFrom+`
+		WHERE v.song_id = $1
+		ORDER BY v.version DESC
+		OFFSET $2;
+	`, songID, maxAudioVersions)
+	if err != nil {
+		return err
+	}
+	var stale []SongAudioVersion
+	for rows.Next() {
+		v, err := scanAudioVersion(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if !v.Current {
+			stale = append(stale, v)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, v := range stale {
+		// Versions released from a project point at the stem's own file,
+		// which stays with the project.
+		if strings.HasPrefix(v.StorageKey, audioKeyPrefix) {
+			if err := storageFor(v.StorageRegion).DeleteObject(ctx, v.StorageKey); err != nil {
+				return err
+			}
+		}
+		if _, err := db.Exec(ctx, `DELETE FROM song_audio_versions WHERE song_id = $1 AND version = $2;`,
+			songID, v.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterAudioVersionRoutes defines a song's audio history and rollback.
+func RegisterAudioVersionRoutes(r *gin.Engine) {
+	// GET /songs/:id/versions — newest first; current marks the one playing
+	r.GET("/songs/:id/versions", RequireAuth(), RequireTeamScope(scopeReleasesManage), func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "view versions of")
+		if !ok {
+			return
+		}
+
+		rows, err := db.Query(c.Request.Context(), `
+			SELECT `+audioVersionColumns+` FROM `+audioVersionFrom+`
+			WHERE v.song_id = $1
+			ORDER BY v.version DESC;
+		`, songID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+		versions := []SongAudioVersion{}
+		for rows.Next() {
+			v, err := scanAudioVersion(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			versions = append(versions, v)
+		}
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, versions)
+	})
+
+	// POST /songs/:id/versions/:version/restore — makes an earlier upload the
+	// song's audio again; it is processed like a new upload
+	r.POST("/songs/:id/versions/:version/restore", RequireAuth(), RequireTeamScope(scopeReleasesManage), func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "change")
+		if !ok {
+			return
+		}
+		number, err := strconv.Atoi(c.Param("version"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+			return
+		}
+
+		ctx := context.Background()
+		v, err := scanAudioVersion(db.QueryRow(ctx, `
+			SELECT `+audioVersionColumns+` FROM `+audioVersionFrom+` WHERE v.song_id = $1 AND v.version = $2;
+		`, songID, number))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "version not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if v.Current {
+			c.JSON(http.StatusConflict, gin.H{"error": "this version is already the song's audio"})
+			return
+		}
+		if storage == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is not configured"})
+			return
+		}
+
+		rend, jobID, err := setOriginalAudio(ctx, songID, v.StorageKey, v.StorageRegion, v.ContentType, v.SizeBytes, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("song %d: restored audio version %d", songID, v.Version)
+		c.JSON(http.StatusAccepted, gin.H{"version": v.Version, "rendition": rend, "job_id": jobID})
+	})
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims are the fields we read from a Supabase access token.
+type Claims struct {
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	SessionID string `json:"session_id"`
+	// UserRole is the app role added by a Supabase custom access token
+	// hook, read only with JWT_ROLE_CLAIM set.
+	UserRole string `json:"user_role"`
+	jwt.RegisteredClaims
+}
+
+// ValidateToken verifies a Supabase access token and returns its claims.
+// JWT_VERIFICATION picks the keys: "hs256" (the project's shared JWT
+// secret), "jwks" (the project's asymmetric keys, RS256 or ES256), or
+// "both", which accepts either while a project migrates to asymmetric keys.
+func ValidateToken(tokenString string) (*Claims, error) {
+	mode := cfg.JWTVerification
+	useSecret := mode == jwtVerifyHS256 || mode == jwtVerifyBoth
+	useJWKS := mode == jwtVerifyJWKS || mode == jwtVerifyBoth
+	if !useSecret && !useJWKS {
+		return nil, fmt.Errorf("JWT_VERIFICATION must be hs256, jwks, or both; got %q", mode)
+	}
+	if useSecret && cfg.JWTSecret == "" {
+		return nil, errors.New("SUPABASE_JWT_SECRET is not configured")
+	}
+	if useJWKS && cfg.JWKSURL == "" && cfg.SupabaseURL == "" {
+		return nil, errors.New("SUPABASE_URL or SUPABASE_JWKS_URL is not configured")
+	}
+
+	var methods []string
+	if useSecret {
+		methods = append(methods, "HS256")
+	}
+	if useJWKS {
+		methods = append(methods, "RS256", "ES256")
+	}
+
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+			return []byte(cfg.JWTSecret), nil
+		}
+		kid, _ := t.Header["kid"].(string)
+		return jwks.key(context.Background(), kid)
+	}, jwt.WithValidMethods(methods), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	return claims, nil
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer ..." header.
+func bearerToken(c *gin.Context) string {
+	h := c.GetHeader("Authorization")
+	if len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
+
+// RequireAuth rejects requests without a valid access token and stores the
+// caller's user ID under "user_id" and their age bracket under "age_bracket"
+// in the gin context. Writes are also rejected until the caller has
+// accepted the current legal versions. Impersonation tokens issued to
+// support staff are accepted too; see requireImpersonation.
+func RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing bearer token"})
+			return
+		}
+		if strings.HasPrefix(token, impersonationTokenPrefix) {
+			requireImpersonation(c, token)
+			return
+		}
+		if strings.HasPrefix(token, oauthTokenPrefix) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "client tokens can only call the public read API"})
+			return
+		}
+
+		claims, err := ValidateToken(token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+
+		c.Set("user_id", claims.Subject)
+		c.Set("claims", claims)
+		if !checkAge(c, claims.Subject) || !checkConsent(c, claims.Subject) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// OptionalAuth sets "user_id" when a valid bearer token is present but lets
+// anonymous requests through. An invalid token is still rejected. A client
+// token that AllowClient accepted counts as anonymous.
+func OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("oauth_client_id"); bearerToken(c) == "" || ok {
+			c.Next()
+			return
+		}
+		RequireAuth()(c)
+	}
+}
+
+// currentUserID returns the authenticated caller's ID, or "" if none.
+func currentUserID(c *gin.Context) string {
+	return c.GetString("user_id")
+}
+
+// newOpaqueToken returns a random URL-safe token with the given prefix.
+func newOpaqueToken(prefix string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex SHA-256 of a token; only hashes are stored.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// supabaseHTTP calls Supabase Auth; refreshes are on the client's critical
+// path, so it fails fast. Calls are counted for /admin/ops.
+var supabaseHTTP = &http.Client{
+	Timeout:   10 * time.Second,
+	Transport: countingTransport{base: http.DefaultTransport, stats: supabaseCalls},
+}
+
+// errGrantRejected means Supabase refused the grant: an expired, revoked, or
+// reused refresh token, or a bad authorization code or ID token.
+var errGrantRejected = errors.New("grant rejected")
+
+// supabaseToken calls Supabase Auth's token endpoint with grantType and
+// returns the new session.
+func supabaseToken(ctx context.Context, grantType string, payload interface{}) (AuthResponse, error) {
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		cfg.SupabaseURL+"/auth/v1/token?grant_type="+grantType, bytes.NewReader(body))
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("apikey", cfg.SupabaseAnonKey)
+
+	resp, err := supabaseHTTP.Do(req)
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	switch resp.StatusCode {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusUnprocessableEntity:
+		return AuthResponse{}, errGrantRejected
+	}
+	if resp.StatusCode/100 != 2 {
+		return AuthResponse{}, fmt.Errorf("supabase auth returned %d: %s", resp.StatusCode, raw)
+	}
+
+	var session AuthResponse
+	if err := json.Unmarshal(raw, &session); err != nil {
+		return AuthResponse{}, err
+	}
+	if session.AccessToken == "" || session.RefreshToken == "" {
+		return AuthResponse{}, errors.New("supabase auth returned no session")
+	}
+	return session, nil
+}
+
+// refreshSession exchanges a refresh token for a new session. Supabase
+// rotates refresh tokens, so the response carries a new one that replaces
+// the old.
+func refreshSession(ctx context.Context, refreshToken string) (AuthResponse, error) {
+	return supabaseToken(ctx, "refresh_token", gin.H{"refresh_token": refreshToken})
+}
+
+// supabaseConfigured reports whether we can call Supabase Auth.
+func supabaseConfigured() bool {
+	return cfg.SupabaseURL != "" && cfg.SupabaseAnonKey != ""
+}
+
+// RegisterAuthRoutes defines the /auth endpoints.
+func RegisterAuthRoutes(r *gin.Engine) {
+	a := r.Group("/auth")
+
+	// GET /auth/introspect — who the token belongs to, plus experiment variants
+	a.GET("/introspect", RequireAuth(), func(c *gin.Context) {
+		claims := c.MustGet("claims").(*Claims)
+
+		role, err := callerRole(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		experiments, err := experimentAssignments(context.Background(), claims.Subject)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		resp := gin.H{
+			"user_id":     claims.Subject,
+			"email":       claims.Email,
+			"role":        role,
+			"expires_at":  claims.ExpiresAt,
+			"experiments": experiments,
+		}
+		if admin := impersonatorID(c); admin != "" {
+			resp["impersonated_by"] = admin
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+
+	// POST /auth/refresh — {"refresh_token":"..."}; the response's refresh_token replaces the old one
+	a.POST("/refresh", func(c *gin.Context) {
+		if !supabaseConfigured() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "auth refresh is not configured"})
+			return
+		}
+
+		var body struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		if err := c.BindJSON(&body); err != nil || body.RefreshToken == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		session, err := refreshSession(c.Request.Context(), body.RefreshToken)
+		if errors.Is(err, errGrantRejected) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "refresh token is invalid, expired, or already used; sign in again",
+				"code":  "invalid_refresh_token",
+			})
+			return
+		}
+		if err != nil {
+			log.Printf("auth refresh: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "could not reach the auth provider"})
+			return
+		}
+
+		c.JSON(http.StatusOK, session)
+	})
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// A backfill recomputes rollups from raw events for a date range, one UTC
+// day per transaction, replacing whatever the incremental rollups wrote.
+// Each day only counts events at or below the rollup's watermark (locked for
+// the duration of the day), so events the incremental rollup has not reached
+// yet are still added exactly once when it does. Run one after changing how
+// a rollup aggregates.
+const (
+	rollupBackfillJob     = "rollup_backfill"
+	maxRollupBackfillDays = 366
+)
+
+type rollupBackfillPayload struct {
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Targets []string `json:"targets"`
+}
+
+type rollupBackfillResult struct {
+	Days    int              `json:"days"`
+	Targets []string         `json:"targets"`
+	Rows    map[string]int64 `json:"rows"`
+}
+
+// backfillDayFunc rebuilds one target for one day inside tx from events up
+// to lastID and returns the number of rollup rows written.
+type backfillDayFunc func(ctx context.Context, tx pgx.Tx, day time.Time, lastID int64) (int64, error)
+
+// rollupBackfills maps each backfillable target to its watermark name, the
+// table whose days it replaces, and its per-day rebuild.
+var rollupBackfills = map[string]struct {
+	watermark string
+	table     string
+	rebuild   backfillDayFunc
+}{
+	"daily_stats":      {dailyStatsRollupName, "song_daily_stats", backfillDailyStats},
+	"unique_listeners": {uniquesRollupName, "song_daily_uniques", backfillUniqueListeners},
+}
+
+// rollupBackfillPreview is what a backfill would replace for one target:
+// Rows existing rollup rows across Songs songs.
+type rollupBackfillPreview struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+	Songs int64  `json:"songs"`
+}
+
+func previewRollupBackfill(ctx context.Context, from, to time.Time, targets []string) (map[string]rollupBackfillPreview, error) {
+	previews := map[string]rollupBackfillPreview{}
+	for _, target := range targets {
+		p := rollupBackfillPreview{Table: rollupBackfills[target].table}
+		if err := db.QueryRow(ctx,
+			`SELECT COUNT(*), COUNT(DISTINCT song_id) FROM `+p.Table+` WHERE day BETWEEN $1::date AND $2::date;`,
+			from, to).Scan(&p.Rows, &p.Songs); err != nil {
+			return nil, err
+		}
+		previews[target] = p
+	}
+	return previews, nil
+}
+
+func init() {
+	RegisterJobHandler(rollupBackfillJob, runRollupBackfill)
+}
+
+func runRollupBackfill(ctx context.Context, job *Job) (interface{}, error) {
+	var payload rollupBackfillPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return nil, err
+	}
+	from, err := time.Parse(dateLayout, payload.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := time.Parse(dateLayout, payload.To)
+	if err != nil {
+		return nil, err
+	}
+
+	days := int(to.Sub(from).Hours()/24) + 1
+	steps := days * len(payload.Targets)
+	result := rollupBackfillResult{Days: days, Targets: payload.Targets, Rows: map[string]int64{}}
+
+	done := 0
+	for _, target := range payload.Targets {
+		b, ok := rollupBackfills[target]
+		if !ok {
+			return nil, fmt.Errorf("unknown backfill target %q", target)
+		}
+		for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+			n, err := backfillDay(ctx, b.watermark, b.rebuild, day)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", target, day.Format(dateLayout), err)
+			}
+			result.Rows[target] += n
+			done++
+			SetJobProgress(ctx, job.ID, done*100/steps)
+		}
+	}
+	return result, nil
+}
+
+func backfillDay(ctx context.Context, watermark string, rebuild backfillDayFunc, day time.Time) (int64, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the watermark row keeps the incremental rollup out while we
+	// replace the day.
+	lastID, err := readWatermark(ctx, tx, watermark)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := rebuild(ctx, tx, day, lastID)
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit(ctx)
+}
+
+func backfillDailyStats(ctx context.Context, tx pgx.Tx, day time.Time, lastID int64) (int64, error) {
+	if _, err := tx.Exec(ctx, `DELETE FROM song_daily_stats WHERE day = $1::date;`, day); err != nil {
+		return 0, err
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO song_daily_stats (song_id, day, plays, skips, likes, shares, comments, reviews, tips)
+		SELECT song_id,
+		       $1,
+		       COUNT(*) FILTER (WHERE event_type = 'play'),
+		       COUNT(*) FILTER (WHERE event_type = 'skip'),
+		       COUNT(*) FILTER (WHERE event_type = 'like'),
+		       COUNT(*) FILTER (WHERE event_type = 'share'),
+		       COUNT(*) FILTER (WHERE event_type = 'comment'),
+		       COUNT(*) FILTER (WHERE event_type = 'review'),
+		       COUNT(*) FILTER (WHERE event_type = 'tip')
+		FROM events
+		WHERE occurred_at >= $2 AND occurred_at < $3
+		  AND id <= $4 AND NOT is_bot
+		GROUP BY song_id;
+	`, day, day, day.AddDate(0, 0, 1), lastID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func backfillUniqueListeners(ctx context.Context, tx pgx.Tx, day time.Time, lastID int64) (int64, error) {
+	if _, err := tx.Exec(ctx, `DELETE FROM song_daily_uniques WHERE day = $1::date;`, day); err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT song_id, COALESCE(user_id::text, properties->>'device_id')
+		FROM events
+		WHERE occurred_at >= $1 AND occurred_at < $2
+		  AND id <= $3 AND event_type = 'play' AND NOT is_bot
+		  AND (user_id IS NOT NULL OR properties ? 'device_id');
+	`, day, day.AddDate(0, 0, 1), lastID)
+	if err != nil {
+		return 0, err
+	}
+
+	sketches := map[int64]*HLL{}
+	for rows.Next() {
+		var (
+			songID   int64
+			listener string
+		)
+		if err := rows.Scan(&songID, &listener); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		h, ok := sketches[songID]
+		if !ok {
+			h = NewHLL()
+			sketches[songID] = h
+		}
+		h.Add(listener)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for songID, h := range sketches {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO song_daily_uniques (song_id, day, registers, estimate)
+			VALUES ($1, $2, $3, $4);
+		`, songID, day, h.Bytes(), int64(h.Count())); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(sketches)), nil
+}
+
+// RegisterBackfillRoutes defines the admin endpoints for rollup backfills.
+func RegisterBackfillRoutes(r *gin.Engine) {
+	admin := r.Group("/admin/backfills", RequireAuth(), RequireRole("admin"), RequireMFA())
+
+	// POST /admin/backfills — {"from":"2025-01-01","to":"2025-01-31","targets":["daily_stats"]};
+	// ?dry_run=true reports the rollup rows it would replace without queuing it
+	admin.POST("", func(c *gin.Context) {
+		var body rollupBackfillPayload
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+
+		from, err := time.Parse(dateLayout, body.From)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+			return
+		}
+		to, err := time.Parse(dateLayout, body.To)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be YYYY-MM-DD"})
+			return
+		}
+		if from.After(to) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+			return
+		}
+		if to.Sub(from) >= maxRollupBackfillDays*24*time.Hour {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("a backfill may span at most %d days", maxRollupBackfillDays)})
+			return
+		}
+
+		if len(body.Targets) == 0 {
+			for target := range rollupBackfills {
+				body.Targets = append(body.Targets, target)
+			}
+		}
+		for _, target := range body.Targets {
+			if _, ok := rollupBackfills[target]; !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown target %q", target)})
+				return
+			}
+		}
+
+		if c.Query("dry_run") == "true" {
+			previews, err := previewRollupBackfill(c.Request.Context(), from, to, body.Targets)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"dry_run":       true,
+				"backfill":      body,
+				"days":          int(to.Sub(from).Hours()/24) + 1,
+				"would_replace": previews,
+			})
+			return
+		}
+
+		jobID, err := EnqueueJob(context.Background(), rollupBackfillJob, body, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"job_id":     jobID,
+			"status":     "queued",
+			"status_url": fmt.Sprintf("/admin/backfills/%d", jobID),
+		})
+	})
+
+	// GET /admin/backfills/:id — status and progress
+	admin.GET("/:id", func(c *gin.Context) {
+		jobID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+			return
+		}
+
+		job, found, err := GetJob(context.Background(), jobID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !found || job.Type != rollupBackfillJob {
+			c.JSON(http.StatusNotFound, gin.H{"error": "backfill not found"})
+			return
+		}
+
+		var payload rollupBackfillPayload
+		json.Unmarshal(job.Payload, &payload)
+		c.JSON(http.StatusOK, gin.H{"job": job, "backfill": payload})
+	})
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Fans earn badges for how they engage. A periodic job awards them from
+// comments, play events, and cleared tips: first_comment and plays_100 are
+// kept once earned, while top_supporter follows the current top 1% of
+// tippers by total over the last supporterWindowDays and is taken back
+// from anyone who drops out. Badges that would reveal activity a user has
+// hidden (see privacy.go) are only shown to the user themselves.
+const (
+	badgesName     = "badges"
+	badgesInterval = time.Hour
+
+	badgeFirstComment = "first_comment"
+	badgePlays100     = "plays_100"
+	badgeTopSupporter = "top_supporter"
+
+	deepListenerSongs   = 100
+	supporterWindowDays = 90
+	supporterPercentile = 0.01
+)
+
+var badgeInfo = map[string]struct{ Title, Description string }{
+	badgeFirstComment: {"First Comment", "Left their first comment on a song."},
+	badgePlays100:     {"Deep Listener", "Played 100 different songs."},
+	badgeTopSupporter: {"Top Supporter", "Among the top 1% of tippers over the last 90 days."},
+}
+
+// badgeVisible is the WHERE clause hiding badges whose activity the owner
+// keeps private; it expects user_badges as b and profiles as p.
+const badgeVisible = `(NOT (b.badge = '` + badgePlays100 + `' AND COALESCE(p.hide_listening_history, false))
+	AND NOT (b.badge = '` + badgeTopSupporter + `' AND COALESCE(p.hide_tips, false)))`
+
+// awardBadges brings user_badges up to date.
+func awardBadges(ctx context.Context) error {
+	if _, err := db.Exec(ctx, `
+		INSERT INTO user_badges (user_id, badge, awarded_at)
+		SELECT author_id, $1, MIN(created_at) FROM comments WHERE trashed_at IS NULL GROUP BY author_id
+		ON CONFLICT DO NOTHING;
+	`, badgeFirstComment); err != nil {
+		return err
+	}
+	if _, err := db.Exec(ctx, `
+		INSERT INTO user_badges (user_id, badge)
+		SELECT user_id, $1 FROM events
+		WHERE event_type = 'play' AND NOT is_bot AND user_id IS NOT NULL AND song_id IS NOT NULL
+		GROUP BY user_id
+		HAVING COUNT(DISTINCT song_id) >= $2
+		ON CONFLICT DO NOTHING;
+	`, badgePlays100, deepListenerSongs); err != nil {
+		return err
+	}
+
+	// At least one supporter qualifies whenever anyone has tipped.
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `
+		CREATE TEMP TABLE top_supporters ON COMMIT DROP AS
+		SELECT sender_id AS user_id FROM (
+			SELECT sender_id, row_number() OVER (ORDER BY SUM(amount) DESC, sender_id) AS rank, COUNT(*) OVER () AS n
+			FROM tips
+			WHERE review_status = 'cleared' AND created_at > now() - make_interval(days => $1)
+			GROUP BY sender_id
+		) ranked
+		WHERE rank <= GREATEST(1, ceil(n * $2::float8));
+	`, supporterWindowDays, supporterPercentile); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM user_badges WHERE badge = $1 AND user_id NOT IN (SELECT user_id FROM top_supporters);
+	`, badgeTopSupporter); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO user_badges (user_id, badge) SELECT user_id, $1 FROM top_supporters
+		ON CONFLICT DO NOTHING;
+	`, badgeTopSupporter); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// loadBadgeNames returns the visible badges of each of userIDs, for
+// attaching to payloads that list several users.
+func loadBadgeNames(ctx context.Context, userIDs []string) (map[string][]string, error) {
+	rows, err := db.Query(ctx, `
+		SELECT b.user_id::text, b.badge FROM user_badges b
+		LEFT JOIN profiles p ON p.id = b.user_id
+		WHERE b.user_id::text = ANY ($1) AND `+badgeVisible+`
+		ORDER BY b.awarded_at, b.badge;
+	`, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	badges := map[string][]string{}
+	for rows.Next() {
+		var userID, badge string
+		if err := rows.Scan(&userID, &badge); err != nil {
+			return nil, err
+		}
+		badges[userID] = append(badges[userID], badge)
+	}
+	return badges, rows.Err()
+}
+
+// RegisterBadgeRoutes defines the public badge list.
+func RegisterBadgeRoutes(r *gin.Engine) {
+	// GET /users/:id/badges — earliest earned first
+	r.GET("/users/:id/badges", OptionalAuth(), func(c *gin.Context) {
+		userID := c.Param("id")
+		if !userIDPattern.MatchString(userID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			return
+		}
+
+		rows, err := db.Query(c.Request.Context(), `
+			SELECT b.badge, b.awarded_at FROM user_badges b
+			LEFT JOIN profiles p ON p.id = b.user_id
+			WHERE b.user_id = $1 AND ($2 OR `+badgeVisible+`)
+			ORDER BY b.awarded_at, b.badge;
+		`, userID, userID == currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		badges := []Badge{}
+		for rows.Next() {
+			var b Badge
+			if err := rows.Scan(&b.Badge, &b.AwardedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			info := badgeInfo[b.Badge]
+			b.Title, b.Description = info.Title, info.Description
+			badges = append(badges, b)
+		}
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, badges)
+	})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The embeddable player runs on third-party pages, so it can't rely on CORS
+// or our auth. It reports plays and views either as an image request
+// (GET /beacon.gif) or via navigator.sendBeacon (POST /beacon with a
+// text/plain body); neither triggers a preflight. Beacons are anonymous and
+// go through the same bot classification as POST /events.
+const maxBeaconBodyBytes = 1 << 10
+
+// beaconEventTypes are the only events the embed may report.
+var beaconEventTypes = map[string]bool{"play": true, "view": true}
+
+// transparentGIF is a 1x1 transparent GIF.
+var transparentGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+type beaconInput struct {
+	SongID    int64  `json:"song_id"`
+	EventType string `json:"event_type"`
+}
+
+// beaconEvent validates a beacon and builds the event to publish.
+func beaconEvent(c *gin.Context, in beaconInput) (IngestEvent, bool) {
+	if !beaconEventTypes[in.EventType] {
+		return IngestEvent{}, false
+	}
+
+	props := map[string]interface{}{"source": "embed"}
+	if u, err := url.Parse(c.GetHeader("Referer")); err == nil && u.Host != "" {
+		props["embed_host"] = u.Hostname()
+	}
+
+	e := IngestEvent{
+		SchemaVersion: currentEventSchemaVersion,
+		EventType:     in.EventType,
+		SongID:        in.SongID,
+		OccurredAt:    time.Now().UTC(),
+		Properties:    props,
+	}
+	return e, validateEvent(e) == nil
+}
+
+// publishBeacon classifies and publishes one event observed without a
+// client batch: a beacon, a play counted by GET /songs/:id/stream, or a
+// download.
+func publishBeacon(c *gin.Context, e IngestEvent) error {
+	events := []IngestEvent{e}
+	verdict := classifyBot(context.Background(), c, len(events))
+	if verdict.Action == botActionDrop {
+		return nil
+	}
+	verdict.apply(events)
+	return eventSink.Publish(context.Background(), events[0])
+}
+
+// RegisterBeaconRoutes defines the embed beacon endpoints.
+func RegisterBeaconRoutes(r *gin.Engine) {
+	// GET /beacon.gif?song_id=1&event_type=play
+	r.GET("/beacon.gif", func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+
+		songID, err := strconv.ParseInt(c.Query("song_id"), 10, 64)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		e, ok := beaconEvent(c, beaconInput{SongID: songID, EventType: c.Query("event_type")})
+		if !ok {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+
+		if err := publishBeacon(c, e); err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Data(http.StatusOK, "image/gif", transparentGIF)
+	})
+
+	// POST /beacon — body {"song_id":1,"event_type":"play"}, any content type
+	r.POST("/beacon", func(c *gin.Context) {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBeaconBodyBytes))
+		if err != nil {
+			c.Status(http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		var in beaconInput
+		if err := decodeStrict(json.RawMessage(body), &in); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		e, ok := beaconEvent(c, in)
+		if !ok {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+
+		if err := publishBeacon(c, e); err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Bot filtering runs on event ingestion. Admin-managed rules match on
+// user agent (case-insensitive substring) or client IP (address or CIDR),
+// and either tag the event as bot traffic (kept but excluded from rollups)
+// or drop it. Clients sending events faster than a human could are tagged.
+const (
+	botActionTag  = "tag"
+	botActionDrop = "drop"
+
+	botRuleCacheTTL    = time.Minute
+	botBehaviorWindow  = time.Minute
+	botBehaviorMaxHits = 60
+)
+
+type createBotRuleInput struct {
+	Kind    string `json:"kind"`
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"`
+	Note    string `json:"note"`
+}
+
+// botVerdict is the outcome of classifying one request.
+type botVerdict struct {
+	Action string // "", botActionTag, or botActionDrop
+	Reason string
+}
+
+var botRules = &botRuleCache{}
+
+// botRuleCache keeps enabled rules in memory, reloading them after a TTL
+// or immediately after an admin change.
+type botRuleCache struct {
+	mu       sync.RWMutex
+	rules    []BotRule
+	loadedAt time.Time
+}
+
+func (b *botRuleCache) get(ctx context.Context) ([]BotRule, error) {
+	b.mu.RLock()
+	if time.Since(b.loadedAt) < botRuleCacheTTL {
+		rules := b.rules
+		b.mu.RUnlock()
+		return rules, nil
+	}
+	b.mu.RUnlock()
+
+	rules, err := loadBotRules(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	b.rules = rules
+	b.loadedAt = time.Now()
+	b.mu.Unlock()
+	return rules, nil
+}
+
+func (b *botRuleCache) invalidate() {
+	b.mu.Lock()
+	b.loadedAt = time.Time{}
+	b.mu.Unlock()
+}
+
+func loadBotRules(ctx context.Context, enabledOnly bool) ([]BotRule, error) {
+	rows, err := db.Query(ctx, `
+		SELECT id, kind, pattern, action, enabled, note, created_at
+		FROM bot_rules
+		WHERE enabled OR NOT $1
+		ORDER BY id;
+	`, enabledOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []BotRule{}
+	for rows.Next() {
+		var r BotRule
+		if err := rows.Scan(&r.ID, &r.Kind, &r.Pattern, &r.Action, &r.Enabled, &r.Note, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// botRateTracker counts events per client in fixed windows.
+type botRateTracker struct {
+	mu        sync.Mutex
+	windows   map[string]*botWindow
+	lastPrune time.Time
+}
+
+type botWindow struct {
+	start time.Time
+	hits  int
+}
+
+var botRates = &botRateTracker{windows: map[string]*botWindow{}}
+
+// hit records n events for key and returns the total in the current window.
+func (t *botRateTracker) hit(key string, n int) int {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.Sub(t.lastPrune) > botBehaviorWindow {
+		for k, w := range t.windows {
+			if now.Sub(w.start) > botBehaviorWindow {
+				delete(t.windows, k)
+			}
+		}
+		t.lastPrune = now
+	}
+
+	w, ok := t.windows[key]
+	if !ok || now.Sub(w.start) > botBehaviorWindow {
+		w = &botWindow{start: now}
+		t.windows[key] = w
+	}
+	w.hits += n
+	return w.hits
+}
+
+// classifyBot checks the request against the rule list, then against the
+// per-client rate. Drop rules win over tag rules.
+func classifyBot(ctx context.Context, c *gin.Context, events int) botVerdict {
+	ua := strings.ToLower(c.Request.UserAgent())
+	ip := net.ParseIP(c.ClientIP())
+
+	verdict := botVerdict{}
+	if ua == "" {
+		verdict = botVerdict{Action: botActionTag, Reason: "empty user agent"}
+	}
+
+	rules, err := botRules.get(ctx)
+	if err == nil {
+		for _, r := range rules {
+			if !botRuleMatches(r, ua, ip) {
+				continue
+			}
+			if r.Action == botActionDrop {
+				return botVerdict{Action: botActionDrop, Reason: "rule " + strconv.FormatInt(r.ID, 10)}
+			}
+			verdict = botVerdict{Action: botActionTag, Reason: "rule " + strconv.FormatInt(r.ID, 10)}
+		}
+	}
+
+	key := currentUserID(c)
+	if key == "" {
+		key = "ip:" + c.ClientIP()
+	}
+	if botRates.hit(key, events) > botBehaviorMaxHits && verdict.Action == "" {
+		verdict = botVerdict{Action: botActionTag, Reason: "event rate"}
+	}
+	return verdict
+}
+
+// apply marks events as bot traffic when the verdict is to tag them.
+func (v botVerdict) apply(events []IngestEvent) {
+	if v.Action != botActionTag {
+		return
+	}
+	for i := range events {
+		if events[i].Properties == nil {
+			events[i].Properties = map[string]interface{}{}
+		}
+		events[i].IsBot = true
+		events[i].Properties["bot_reason"] = v.Reason
+	}
+}
+
+func botRuleMatches(r BotRule, ua string, ip net.IP) bool {
+	switch r.Kind {
+	case "user_agent":
+		return ua != "" && strings.Contains(ua, strings.ToLower(r.Pattern))
+	case "ip":
+		if ip == nil {
+			return false
+		}
+		if _, network, err := net.ParseCIDR(r.Pattern); err == nil {
+			return network.Contains(ip)
+		}
+		return ip.Equal(net.ParseIP(r.Pattern))
+	}
+	return false
+}
+
+// RegisterBotRoutes defines the admin endpoints for the bot rule list.
+func RegisterBotRoutes(r *gin.Engine) {
+	admin := r.Group("/admin/bot-rules", RequireAuth(), RequireRole("admin"), RequireMFA())
+
+	// GET /admin/bot-rules
+	admin.GET("", func(c *gin.Context) {
+		rules, err := loadBotRules(context.Background(), false)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, rules)
+	})
+
+	// POST /admin/bot-rules
+	admin.POST("", func(c *gin.Context) {
+		var body createBotRuleInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+
+		body.Pattern = strings.TrimSpace(body.Pattern)
+		if body.Pattern == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pattern is required"})
+			return
+		}
+		switch body.Kind {
+		case "user_agent":
+		case "ip":
+			_, _, cidrErr := net.ParseCIDR(body.Pattern)
+			if cidrErr != nil && net.ParseIP(body.Pattern) == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "pattern must be an IP or CIDR"})
+				return
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be user_agent or ip"})
+			return
+		}
+		if body.Action == "" {
+			body.Action = botActionTag
+		}
+		if body.Action != botActionTag && body.Action != botActionDrop {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "action must be tag or drop"})
+			return
+		}
+
+		var rule BotRule
+		err := db.QueryRow(context.Background(), `
+			INSERT INTO bot_rules (kind, pattern, action, note)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, kind, pattern, action, enabled, note, created_at;
+		`, body.Kind, body.Pattern, body.Action, body.Note,
+		).Scan(&rule.ID, &rule.Kind, &rule.Pattern, &rule.Action, &rule.Enabled, &rule.Note, &rule.CreatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		botRules.invalidate()
+		c.JSON(http.StatusCreated, rule)
+	})
+
+	// PATCH /admin/bot-rules/:id — {"enabled": false} to pause a rule
+	admin.PATCH("/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule id"})
+			return
+		}
+
+		var body struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if err := c.BindJSON(&body); err != nil || body.Enabled == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "enabled is required"})
+			return
+		}
+
+		tag, err := db.Exec(context.Background(),
+			`UPDATE bot_rules SET enabled = $2 WHERE id = $1;`, id, *body.Enabled)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "rule not found"})
+			return
+		}
+
+		botRules.invalidate()
+		c.Status(http.StatusNoContent)
+	})
+
+	// DELETE /admin/bot-rules/:id
+	admin.DELETE("/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule id"})
+			return
+		}
+
+		tag, err := db.Exec(context.Background(), `DELETE FROM bot_rules WHERE id = $1;`, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "rule not found"})
+			return
+		}
+
+		botRules.invalidate()
+		c.Status(http.StatusNoContent)
+	})
+}
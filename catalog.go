@@ -0,0 +1,478 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Catalog metadata export for distributors, as CSV or a DDEX ERN-style
+// NewReleaseMessage (one single release per song).
+
+// creditRoles are the contributor roles we accept, named as in DDEX.
+var creditRoles = map[string]bool{
+	"MainArtist": true, "FeaturedArtist": true, "Composer": true, "Lyricist": true,
+	"ComposerLyricist": true, "Producer": true, "Remixer": true, "MixingEngineer": true,
+	"MasteringEngineer": true, "Arranger": true,
+}
+
+// An ISRC is country (2 letters), registrant (3 alphanumerics), year (2
+// digits), and designation (5 digits). It is stored in this compact form;
+// the hyphenated display form is accepted on input.
+var isrcPattern = regexp.MustCompile(`^[A-Z]{2}[A-Z0-9]{3}[0-9]{7}$`)
+
+const (
+	maxCreditName  = 200
+	maxSongCredits = 100
+	maxLabelName   = 200
+)
+
+// normalizeISRC uppercases s and strips hyphens and spaces, failing when
+// the result isn't an ISRC.
+func normalizeISRC(s string) (string, error) {
+	isrc := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(s))
+	if !isrcPattern.MatchString(isrc) {
+		return "", fmt.Errorf("isrc must look like CC-XXX-YY-NNNNN")
+	}
+	return isrc, nil
+}
+
+// validateCredits trims names and checks each credit in place, returning
+// the index of the first bad one with the error.
+func validateCredits(credits []SongCredit) (int, error) {
+	if len(credits) > maxSongCredits {
+		return -1, fmt.Errorf("a song can have at most %d credits", maxSongCredits)
+	}
+	for i := range credits {
+		credits[i].Name = strings.TrimSpace(credits[i].Name)
+		if credits[i].Name == "" {
+			return i, fmt.Errorf("every credit needs a name")
+		}
+		if utf8.RuneCountInString(credits[i].Name) > maxCreditName {
+			return i, fmt.Errorf("credit names must be at most %d characters", maxCreditName)
+		}
+		if !creditRoles[credits[i].Role] {
+			return i, fmt.Errorf("unsupported role %q", credits[i].Role)
+		}
+		if credits[i].UserID != nil && !userIDPattern.MatchString(*credits[i].UserID) {
+			return i, fmt.Errorf("invalid user_id")
+		}
+	}
+	return -1, nil
+}
+
+// writeCreditsError writes a validateCredits failure.
+func writeCreditsError(c *gin.Context, index int, err error) {
+	resp := gin.H{"error": err.Error()}
+	if index >= 0 {
+		resp["index"] = index
+	}
+	c.JSON(http.StatusBadRequest, resp)
+}
+
+func loadCredits(ctx context.Context, songID int64) ([]SongCredit, error) {
+	rows, err := db.Query(ctx, `
+		SELECT id, song_id, name, role, user_id::text, position FROM song_credits
+		WHERE song_id = $1
+		ORDER BY position, id;
+	`, songID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credits := []SongCredit{}
+	for rows.Next() {
+		var cr SongCredit
+		if err := rows.Scan(&cr.ID, &cr.SongID, &cr.Name, &cr.Role, &cr.UserID, &cr.Position); err != nil {
+			return nil, err
+		}
+		credits = append(credits, cr)
+	}
+	return credits, rows.Err()
+}
+
+// replaceCredits swaps the song's credits for the given ones, in order.
+func replaceCredits(ctx context.Context, tx pgx.Tx, songID int64, body []SongCredit) ([]SongCredit, error) {
+	if _, err := tx.Exec(ctx, `DELETE FROM song_credits WHERE song_id = $1;`, songID); err != nil {
+		return nil, err
+	}
+	credits := []SongCredit{}
+	for i, cr := range body {
+		cr.SongID, cr.Position = songID, i
+		err := tx.QueryRow(ctx, `
+			INSERT INTO song_credits (song_id, name, role, user_id, position)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id;
+		`, songID, cr.Name, cr.Role, cr.UserID, i).Scan(&cr.ID)
+		if err != nil {
+			return nil, err
+		}
+		credits = append(credits, cr)
+	}
+	return credits, nil
+}
+
+// catalogSong is a song with everything a distributor needs.
+type catalogSong struct {
+	ID              int64
+	Title           string
+	ISRC            *string
+	Label           *string
+	ReleaseDate     *time.Time
+	DurationSeconds *int
+	Published       bool
+	ArtistName      string
+	Credits         []SongCredit
+}
+
+func loadCatalog(ctx context.Context, artistID string) ([]catalogSong, error) {
+	rows, err := db.Query(ctx, `
+		SELECT s.id, s.title, s.isrc, s.label, s.release_date, s.duration_seconds, s.published,
+		       COALESCE(p.display_name, '')
+		FROM songs s
+		LEFT JOIN profiles p ON p.id = s.artist_id
+		WHERE s.artist_id = $1
+		ORDER BY s.id;
+	`, artistID)
+	if err != nil {
+		return nil, err
+	}
+
+	songs := []catalogSong{}
+	index := map[int64]int{}
+	for rows.Next() {
+		var s catalogSong
+		if err := rows.Scan(&s.ID, &s.Title, &s.ISRC, &s.Label, &s.ReleaseDate, &s.DurationSeconds,
+			&s.Published, &s.ArtistName); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[s.ID] = len(songs)
+		songs = append(songs, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(ctx, `
+		SELECT c.id, c.song_id, c.name, c.role, c.user_id, c.position
+		FROM song_credits c
+		JOIN songs s ON s.id = c.song_id
+		WHERE s.artist_id = $1
+		ORDER BY c.song_id, c.position, c.id;
+	`, artistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cr SongCredit
+		if err := rows.Scan(&cr.ID, &cr.SongID, &cr.Name, &cr.Role, &cr.UserID, &cr.Position); err != nil {
+			return nil, err
+		}
+		if i, ok := index[cr.SongID]; ok {
+			songs[i].Credits = append(songs[i].Credits, cr)
+		}
+	}
+	return songs, rows.Err()
+}
+
+func writeCatalogCSV(c *gin.Context, songs []catalogSong) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="catalog.csv"`)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"song_id", "title", "artist", "isrc", "label", "release_date", "duration_seconds", "published", "contributors"})
+	for _, s := range songs {
+		contributors := make([]string, 0, len(s.Credits))
+		for _, cr := range s.Credits {
+			contributors = append(contributors, fmt.Sprintf("%s (%s)", cr.Name, cr.Role))
+		}
+		release, duration := "", ""
+		if s.ReleaseDate != nil {
+			release = s.ReleaseDate.Format(dateLayout)
+		}
+		if s.DurationSeconds != nil {
+			duration = strconv.Itoa(*s.DurationSeconds)
+		}
+		w.Write([]string{
+			strconv.FormatInt(s.ID, 10), s.Title, s.ArtistName, deref(s.ISRC), deref(s.Label),
+			release, duration, strconv.FormatBool(s.Published), strings.Join(contributors, "; "),
+		})
+	}
+	w.Flush()
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// DDEX ERN types, trimmed to the fields we can fill.
+type ddexMessage struct {
+	XMLName       xml.Name        `xml:"ern:NewReleaseMessage"`
+	XMLNSErn      string          `xml:"xmlns:ern,attr"`
+	SchemaVersion string          `xml:"MessageSchemaVersionId,attr"`
+	Header        ddexHeader      `xml:"MessageHeader"`
+	Resources     []ddexRecording `xml:"ResourceList>SoundRecording"`
+	Releases      []ddexRelease   `xml:"ReleaseList>Release"`
+}
+
+type ddexHeader struct {
+	MessageID  string `xml:"MessageId"`
+	SenderName string `xml:"MessageSender>PartyName>FullName"`
+	CreatedAt  string `xml:"MessageCreatedDateTime"`
+}
+
+type ddexContributor struct {
+	Name string `xml:"PartyName>FullName"`
+	Role string `xml:"Role"`
+}
+
+type ddexRecording struct {
+	Reference     string            `xml:"ResourceReference"`
+	ISRC          string            `xml:"ResourceId>ISRC,omitempty"`
+	Title         string            `xml:"DisplayTitleText"`
+	DisplayArtist string            `xml:"DisplayArtistName,omitempty"`
+	Duration      string            `xml:"Duration,omitempty"`
+	Contributors  []ddexContributor `xml:"Contributor"`
+}
+
+type ddexRelease struct {
+	Reference   string `xml:"ReleaseReference"`
+	Type        string `xml:"ReleaseType"`
+	Title       string `xml:"DisplayTitleText"`
+	Label       string `xml:"LabelName,omitempty"`
+	ReleaseDate string `xml:"ReleaseDate,omitempty"`
+	Resource    string `xml:"ResourceGroup>ResourceGroupContentItem>ReleaseResourceReference"`
+}
+
+func writeCatalogDDEX(c *gin.Context, artistID string, songs []catalogSong) {
+	now := time.Now().UTC()
+	msg := ddexMessage{
+		XMLNSErn:      "http://ddex.net/xml/ern/43",
+		SchemaVersion: "ern/43",
+		Header: ddexHeader{
+			MessageID:  fmt.Sprintf("leep-%s-%d", artistID, now.Unix()),
+			SenderName: "Leep",
+			CreatedAt:  now.Format(time.RFC3339),
+		},
+	}
+
+	for _, s := range songs {
+		if !s.Published {
+			continue
+		}
+		ref := fmt.Sprintf("A%d", s.ID)
+		rec := ddexRecording{Reference: ref, ISRC: deref(s.ISRC), Title: s.Title, DisplayArtist: s.ArtistName}
+		if s.DurationSeconds != nil {
+			d := *s.DurationSeconds
+			rec.Duration = fmt.Sprintf("PT%dM%dS", d/60, d%60)
+		}
+		for _, cr := range s.Credits {
+			rec.Contributors = append(rec.Contributors, ddexContributor{Name: cr.Name, Role: cr.Role})
+		}
+		msg.Resources = append(msg.Resources, rec)
+
+		rel := ddexRelease{Reference: fmt.Sprintf("R%d", s.ID), Type: "Single", Title: s.Title, Label: deref(s.Label), Resource: ref}
+		if s.ReleaseDate != nil {
+			rel.ReleaseDate = s.ReleaseDate.Format(dateLayout)
+		}
+		msg.Releases = append(msg.Releases, rel)
+	}
+
+	out, err := xml.MarshalIndent(msg, "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="catalog-ddex.xml"`)
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", append([]byte(xml.Header), out...))
+}
+
+// RegisterCatalogRoutes defines credits management and the catalog export.
+func RegisterCatalogRoutes(r *gin.Engine) {
+	// GET /me/catalog/export?format=ddex|csv
+	r.GET("/me/catalog/export", RequireAuth(), func(c *gin.Context) {
+		format := c.DefaultQuery("format", "csv")
+		if format != "csv" && format != "ddex" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be ddex or csv"})
+			return
+		}
+
+		songs, err := loadCatalog(context.Background(), currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if format == "ddex" {
+			writeCatalogDDEX(c, currentUserID(c), songs)
+			return
+		}
+		writeCatalogCSV(c, songs)
+	})
+
+	// PUT /songs/:id/metadata {"isrc","label","release_date":"2025-06-01","credits":[...]}
+	// Replaces the distributor metadata; omitted fields are cleared, except
+	// credits, which are only replaced when given.
+	r.PUT("/songs/:id/metadata", RequireAuthOrAPIKey(), RequireScope(apiScopeSongsWrite), func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "edit the metadata of")
+		if !ok {
+			return
+		}
+		var body struct {
+			ISRC        *string       `json:"isrc"`
+			Label       *string       `json:"label"`
+			ReleaseDate *string       `json:"release_date"`
+			Credits     *[]SongCredit `json:"credits"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+
+		var isrc, label *string
+		if body.ISRC != nil && strings.TrimSpace(*body.ISRC) != "" {
+			v, err := normalizeISRC(*body.ISRC)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			isrc = &v
+		}
+		if body.Label != nil && strings.TrimSpace(*body.Label) != "" {
+			v := strings.TrimSpace(*body.Label)
+			if utf8.RuneCountInString(v) > maxLabelName {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("label must be at most %d characters", maxLabelName)})
+				return
+			}
+			label = &v
+		}
+		var releaseDate *time.Time
+		if body.ReleaseDate != nil && *body.ReleaseDate != "" {
+			d, err := time.Parse("2006-01-02", *body.ReleaseDate)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "release_date must be YYYY-MM-DD"})
+				return
+			}
+			releaseDate = &d
+		}
+		if body.Credits != nil {
+			if i, err := validateCredits(*body.Credits); err != nil {
+				writeCreditsError(c, i, err)
+				return
+			}
+		}
+		ctx := c.Request.Context()
+
+		// An ISRC identifies one recording, so no two songs may share one.
+		if isrc != nil {
+			var taken bool
+			if err := db.QueryRow(ctx, `
+				SELECT EXISTS (SELECT 1 FROM songs WHERE isrc = $1 AND id <> $2 AND trashed_at IS NULL);
+			`, *isrc, songID).Scan(&taken); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if taken {
+				c.JSON(http.StatusConflict, gin.H{"error": "this isrc is already assigned to another song"})
+				return
+			}
+		}
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		if _, err := tx.Exec(ctx, `UPDATE songs SET isrc = $2, label = $3, release_date = $4 WHERE id = $1;`,
+			songID, isrc, label, releaseDate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if body.Credits != nil {
+			if _, err := replaceCredits(ctx, tx, songID, *body.Credits); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		s, err := loadSong(ctx, songID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, s)
+	})
+
+	// PUT /songs/:id/credits — replaces the song's credits, in order
+	r.PUT("/songs/:id/credits", RequireAuth(), func(c *gin.Context) {
+		songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+			return
+		}
+
+		var body []SongCredit
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if i, err := validateCredits(body); err != nil {
+			writeCreditsError(c, i, err)
+			return
+		}
+
+		owned, err := songOwnedBy(context.Background(), songID, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !owned {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you can only edit credits on your own songs"})
+			return
+		}
+
+		tx, err := db.Begin(context.Background())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback(context.Background())
+
+		credits, err := replaceCredits(context.Background(), tx, songID, body)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := tx.Commit(context.Background()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, credits)
+	})
+}
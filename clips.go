@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Listeners cut short clips out of published songs and share them by link.
+// A clip is rendered from the song's original by a clip_render job into a
+// standalone MP3 with a short fade at each end, stored next to the song's
+// audio. The share page (GET /clips/:slug) always attributes the clip to
+// the full song so every share leads back to it.
+const (
+	clipRenderJob   = "clip_render"
+	clipKeyPrefix   = "clips/"
+	minClipLength   = 5 * time.Second
+	maxClipLength   = 60 * time.Second
+	maxClipTitle    = 100
+	clipFade        = 0.3 // seconds
+	clipRenderLimit = 2 * time.Minute
+	clipURLExpiry   = time.Hour
+	maxClipsPerDay  = 50
+	clipsPageSize   = 50
+)
+
+type clipRenderPayload struct {
+	ClipID int64 `json:"clip_id"`
+}
+
+func init() {
+	RegisterJobHandler(clipRenderJob, runClipRender)
+}
+
+const clipColumns = `id, slug, song_id, creator_id::text, title, start_ms, end_ms, status, error, storage_key, storage_region,
+	plays, created_at, rendered_at`
+
+func scanClip(row pgx.Row) (Clip, error) {
+	var cl Clip
+	err := row.Scan(&cl.ID, &cl.Slug, &cl.SongID, &cl.CreatorID, &cl.Title, &cl.StartMs, &cl.EndMs, &cl.Status, &cl.Error,
+		&cl.StorageKey, &cl.StorageRegion, &cl.Plays, &cl.CreatedAt, &cl.RenderedAt)
+	return cl, err
+}
+
+// newClipSlug returns a short random share slug.
+func newClipSlug() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// renderClip cuts [startMs, endMs) out of inputURL into an MP3 at path.
+func renderClip(ctx context.Context, inputURL, path string, startMs, endMs int64) error {
+	ctx, cancel := context.WithTimeout(ctx, clipRenderLimit)
+	defer cancel()
+
+	length := float64(endMs-startMs) / 1000
+	fades := fmt.Sprintf("afade=t=in:d=%.2f,afade=t=out:st=%.3f:d=%.2f", clipFade, length-clipFade, clipFade)
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, cfg.FFmpegPath,
+		"-nostats", "-hide_banner", "-y",
+		"-ss", fmt.Sprintf("%.3f", float64(startMs)/1000), "-i", inputURL, "-t", fmt.Sprintf("%.3f", length),
+		"-vn", "-af", fades, "-c:a", "libmp3lame", "-b:a", "192k", "-f", "mp3", path)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg: %v: %s", err, lastLines(stderr.Bytes(), 3))
+	}
+	return nil
+}
+
+func runClipRender(ctx context.Context, job *Job) (interface{}, error) {
+	var p clipRenderPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return nil, err
+	}
+	cl, err := scanClip(db.QueryRow(ctx, `SELECT `+clipColumns+` FROM clips WHERE id = $1 AND status = 'pending';`, p.ClipID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return gin.H{"skipped": true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if storage == nil {
+		return nil, fmt.Errorf("storage is not configured")
+	}
+
+	rend, err := scanRendition(db.QueryRow(ctx,
+		`SELECT `+renditionColumns+` FROM song_renditions WHERE song_id = $1 AND name = $2 AND status = 'ready';`,
+		cl.SongID, originalRendition))
+	if errors.Is(err, pgx.ErrNoRows) {
+		failClip(ctx, cl.ID, "The song's audio is no longer available.")
+		return gin.H{"status": "failed"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "clip-*.mp3")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := renderClip(ctx, storageFor(rend.StorageRegion).PresignGet(rend.StorageKey, processingURLExpiry),
+		tmp.Name(), cl.StartMs, cl.EndMs); err != nil {
+		if job.Attempts >= jobMaxAttempts {
+			failClip(ctx, cl.ID, "The clip could not be rendered.")
+		}
+		return nil, err
+	}
+	size, err := tmp.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%s%d/%s.mp3", clipKeyPrefix, cl.SongID, cl.Slug)
+	if err := storageFor(rend.StorageRegion).PutObject(ctx, key, tmp, size, "audio/mpeg"); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(ctx, `
+		UPDATE clips SET status = 'ready', storage_key = $2, storage_region = $3, size_bytes = $4, rendered_at = now()
+		WHERE id = $1;
+	`, cl.ID, key, rend.StorageRegion, size); err != nil {
+		return nil, err
+	}
+	return gin.H{"status": "ready", "size_bytes": size}, nil
+}
+
+func failClip(ctx context.Context, clipID int64, msg string) {
+	if _, err := db.Exec(ctx, `UPDATE clips SET status = 'failed', error = $2, rendered_at = now() WHERE id = $1;`,
+		clipID, msg); err != nil {
+		log.Printf("clip %d: failed to record failure: %v", clipID, err)
+	}
+}
+
+// loadSharedClip returns the clip in :slug with the song it came from,
+// writing the 404 when either is gone or the song is hidden from the
+// caller.
+func loadSharedClip(c *gin.Context) (Clip, Song, bool) {
+	ctx := c.Request.Context()
+	cl, err := scanClip(db.QueryRow(ctx, `SELECT `+clipColumns+` FROM clips WHERE slug = $1;`, c.Param("slug")))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "clip not found"})
+		return Clip{}, Song{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return Clip{}, Song{}, false
+	}
+	s, err := scanSong(db.QueryRow(ctx, `SELECT `+songColumns+` FROM `+songFrom+` WHERE s.id = $1;`, cl.SongID))
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !s.Published) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "clip not found"})
+		return Clip{}, Song{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return Clip{}, Song{}, false
+	}
+	if s.Explicit && abortIfExplicit(c, "this clip") {
+		return Clip{}, Song{}, false
+	}
+	return cl, s, true
+}
+
+// withShareURLs fills in the clip's share, audio, and attribution links.
+func withShareURLs(c *gin.Context, cl Clip, s Song) Clip {
+	origin := requestOrigin(c)
+	cl.ShareURL = origin + "/clips/" + cl.Slug
+	if cl.Status == "ready" {
+		cl.AudioURL = origin + "/clips/" + cl.Slug + "/audio"
+	}
+	cl.Song = &ClipAttribution{
+		SongID:         s.ID,
+		Title:          s.Title,
+		ArtistID:       s.ArtistID,
+		ArtistVerified: s.ArtistVerified,
+		ArtworkURL:     s.ArtworkURL,
+		URL:            origin + "/songs/" + strconv.FormatInt(s.ID, 10),
+		StartMs:        cl.StartMs,
+	}
+	return cl
+}
+
+// RegisterClipRoutes defines clip creation, share pages, and clip audio.
+func RegisterClipRoutes(r *gin.Engine) {
+	// POST /songs/:id/clips {"start_ms","end_ms","title"} — queues rendering; poll GET /clips/:slug for status
+	r.POST("/songs/:id/clips", RequireAuth(), func(c *gin.Context) {
+		songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+			return
+		}
+		var body struct {
+			StartMs *int64 `json:"start_ms"`
+			EndMs   *int64 `json:"end_ms"`
+			Title   string `json:"title"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if body.StartMs == nil || body.EndMs == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_ms and end_ms are required"})
+			return
+		}
+		title := strings.TrimSpace(body.Title)
+		if utf8.RuneCountInString(title) > maxClipTitle {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("title must be at most %d characters", maxClipTitle)})
+			return
+		}
+		if storage == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is not configured"})
+			return
+		}
+		ctx := c.Request.Context()
+		userID := currentUserID(c)
+
+		s, err := scanSong(db.QueryRow(ctx, `SELECT `+songColumns+` FROM `+songFrom+` WHERE s.id = $1;`, songID))
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && !s.Published) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "song not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if s.Explicit && abortIfExplicit(c, "this song") {
+			return
+		}
+
+		rend, err := scanRendition(db.QueryRow(ctx,
+			`SELECT `+renditionColumns+` FROM song_renditions WHERE song_id = $1 AND name = $2 AND status = 'ready';`,
+			songID, originalRendition))
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && rend.DurationMs == nil) {
+			c.JSON(http.StatusConflict, gin.H{"error": "this song's audio isn't available for clipping"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		start, end, duration := *body.StartMs, *body.EndMs, *rend.DurationMs
+		length := time.Duration(end-start) * time.Millisecond
+		switch {
+		case start < 0 || end > duration:
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "clip must be within the song", "duration_ms": duration})
+			return
+		case length < minClipLength || length > maxClipLength:
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("clips must be %d-%d seconds long",
+				int(minClipLength.Seconds()), int(maxClipLength.Seconds()))})
+			return
+		}
+
+		var today int
+		if err := db.QueryRow(ctx, `
+			SELECT COUNT(*) FROM clips WHERE creator_id = $1 AND created_at > now() - interval '1 day';
+		`, userID).Scan(&today); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if today >= maxClipsPerDay {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": fmt.Sprintf("you can create %d clips a day", maxClipsPerDay)})
+			return
+		}
+
+		slug, err := newClipSlug()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if title == "" {
+			title = s.Title
+		}
+		cl, err := scanClip(db.QueryRow(ctx, `
+			INSERT INTO clips (slug, song_id, creator_id, title, start_ms, end_ms)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING `+clipColumns+`;
+		`, slug, songID, userID, title, start, end))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		jobID, err := EnqueueJob(ctx, clipRenderJob, clipRenderPayload{ClipID: cl.ID}, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"clip": withShareURLs(c, cl, s), "job_id": jobID})
+	})
+
+	// GET /songs/:id/clips — the song's rendered clips, most played first
+	r.GET("/songs/:id/clips", OptionalAuth(), func(c *gin.Context) {
+		songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+			return
+		}
+		ctx := c.Request.Context()
+		s, err := scanSong(db.QueryRow(ctx, `SELECT `+songColumns+` FROM `+songFrom+` WHERE s.id = $1;`, songID))
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && !songVisibleTo(s, currentUserID(c))) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "song not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if s.Explicit && abortIfExplicit(c, "this song") {
+			return
+		}
+
+		rows, err := db.Query(ctx, `
+			SELECT `+clipColumns+` FROM clips
+			WHERE song_id = $1 AND status = 'ready'
+			ORDER BY plays DESC, id DESC
+			LIMIT $2;
+		`, songID, clipsPageSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		clips := []Clip{}
+		for rows.Next() {
+			cl, err := scanClip(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			clips = append(clips, withShareURLs(c, cl, s))
+		}
+		c.JSON(http.StatusOK, clips)
+	})
+
+	// GET /clips/:slug — share page data with attribution to the full song
+	r.GET("/clips/:slug", OptionalAuth(), func(c *gin.Context) {
+		cl, s, ok := loadSharedClip(c)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, withShareURLs(c, cl, s))
+	})
+
+	// GET /clips/:slug/audio — counts the play and redirects to the MP3
+	r.GET("/clips/:slug/audio", OptionalAuth(), func(c *gin.Context) {
+		cl, _, ok := loadSharedClip(c)
+		if !ok {
+			return
+		}
+		if cl.Status != "ready" {
+			c.JSON(http.StatusConflict, gin.H{"error": "clip is " + cl.Status})
+			return
+		}
+		if r := c.GetHeader("Range"); (r == "" || strings.HasPrefix(r, "bytes=0-")) &&
+			classifyBot(context.Background(), c, 1).Action == "" {
+			if _, err := db.Exec(context.Background(), `UPDATE clips SET plays = plays + 1 WHERE id = $1;`, cl.ID); err != nil {
+				log.Printf("clip %d: failed to count play: %v", cl.ID, err)
+			}
+		}
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, storageFor(*cl.StorageRegion).PresignGet(*cl.StorageKey, clipURLExpiry))
+	})
+
+	// DELETE /clips/:slug — the clip's creator or the song's artist
+	r.DELETE("/clips/:slug", RequireAuth(), func(c *gin.Context) {
+		ctx := c.Request.Context()
+		userID := currentUserID(c)
+		cl, err := scanClip(db.QueryRow(ctx, `
+			DELETE FROM clips
+			WHERE slug = $1 AND (creator_id = $2 OR song_id IN (SELECT id FROM songs WHERE artist_id = $2))
+			RETURNING `+clipColumns+`;
+		`, c.Param("slug"), userID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "clip not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if cl.StorageKey != nil {
+			if err := storageFor(*cl.StorageRegion).DeleteObject(ctx, *cl.StorageKey); err != nil && !errors.Is(err, ErrObjectNotFound) {
+				log.Printf("clip %d: failed to delete %s: %v", cl.ID, *cl.StorageKey, err)
+			}
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Collections are editorial song lists (staff picks, genre spotlights)
+// that admins and curators build. Each has a position among the others and
+// an optional window (starts_at, ends_at) so features can be scheduled
+// ahead; outside it a collection is visible only in the admin list. Songs
+// keep their order, and drafts or songs unpublished later are skipped when
+// serving.
+const (
+	maxCollectionTitle       = 120
+	maxCollectionDescription = 2000
+	maxCollectionSongs       = 200
+	discoverCollections      = 10
+	discoverSongsPerList     = 10
+)
+
+var collectionKinds = map[string]bool{"staff_picks": true, "genre_spotlight": true, "editorial": true}
+
+var collectionSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{2,63}$`)
+
+type collectionInput struct {
+	Slug        *string    `json:"slug"`
+	Title       *string    `json:"title"`
+	Description *string    `json:"description"`
+	Kind        *string    `json:"kind"`
+	Genre       *string    `json:"genre"`
+	CoverURL    *string    `json:"cover_url"`
+	Position    *int       `json:"position"`
+	StartsAt    *time.Time `json:"starts_at"`
+	EndsAt      *string    `json:"ends_at"`
+}
+
+// validate checks the fields that are set; create additionally requires
+// slug, title, and kind. An ends_at of "" clears the end.
+func (in *collectionInput) validate() error {
+	for _, f := range []*string{in.Title, in.Description, in.CoverURL} {
+		if f != nil {
+			*f = strings.TrimSpace(*f)
+		}
+	}
+	if in.Slug != nil && !collectionSlugPattern.MatchString(*in.Slug) {
+		return fmt.Errorf("slug must be 3-64 lowercase letters, digits, or dashes")
+	}
+	if in.Title != nil && (*in.Title == "" || utf8.RuneCountInString(*in.Title) > maxCollectionTitle) {
+		return fmt.Errorf("title must be 1-%d characters", maxCollectionTitle)
+	}
+	if in.Description != nil && utf8.RuneCountInString(*in.Description) > maxCollectionDescription {
+		return fmt.Errorf("description must be at most %d characters", maxCollectionDescription)
+	}
+	if in.Kind != nil && !collectionKinds[*in.Kind] {
+		return fmt.Errorf("kind must be staff_picks, genre_spotlight, or editorial")
+	}
+	if in.Genre != nil && *in.Genre != "" {
+		g, err := normalizeGenre(*in.Genre)
+		if err != nil {
+			return err
+		}
+		in.Genre = &g
+	}
+	if in.CoverURL != nil && *in.CoverURL != "" {
+		if u, err := url.Parse(*in.CoverURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("cover_url must be an https URL")
+		}
+	}
+	if in.EndsAt != nil && *in.EndsAt != "" {
+		t, err := time.Parse(time.RFC3339, *in.EndsAt)
+		if err != nil {
+			return fmt.Errorf("ends_at must be an RFC 3339 time, or empty to clear it")
+		}
+		if in.StartsAt != nil && !t.After(*in.StartsAt) {
+			return fmt.Errorf("ends_at must be after starts_at")
+		}
+	}
+	return nil
+}
+
+const collectionColumns = `id, slug, title, description, kind, genre, cover_url, position, starts_at, ends_at,
+	created_by::text, created_at, updated_at`
+
+// collectionActive is the WHERE clause for collections inside their window.
+const collectionActive = `starts_at <= now() AND (ends_at IS NULL OR ends_at > now())`
+
+func scanCollection(row pgx.Row) (Collection, error) {
+	var cl Collection
+	err := row.Scan(&cl.ID, &cl.Slug, &cl.Title, &cl.Description, &cl.Kind, &cl.Genre, &cl.CoverURL, &cl.Position,
+		&cl.StartsAt, &cl.EndsAt, &cl.CreatedBy, &cl.CreatedAt, &cl.UpdatedAt)
+	return cl, err
+}
+
+// collectionSongs lists up to limit of a collection's published songs in
+// order, leaving out explicit ones when hideExplicit is set.
+func collectionSongs(ctx context.Context, collectionID int64, hideExplicit bool, limit int) ([]Song, error) {
+	rows, err := db.Query(ctx, `
+		SELECT `+songColumns+` FROM `+songFrom+`
+		JOIN collection_songs cs ON cs.song_id = s.id
+		WHERE cs.collection_id = $1 AND s.published AND NOT (s.explicit AND $2)
+		ORDER BY cs.position
+		LIMIT $3;
+	`, collectionID, hideExplicit, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	songs := []Song{}
+	for rows.Next() {
+		s, err := scanSong(rows)
+		if err != nil {
+			return nil, err
+		}
+		songs = append(songs, s)
+	}
+	return songs, rows.Err()
+}
+
+// listCollections returns collections in display order; active limits them
+// to ones inside their window.
+func listCollections(ctx context.Context, active bool, kind, genre string, limit int) ([]Collection, error) {
+	where := `TRUE`
+	if active {
+		where = collectionActive
+	}
+	rows, err := db.Query(ctx, `
+		SELECT `+collectionColumns+` FROM collections
+		WHERE `+where+` AND ($1 = '' OR kind = $1) AND ($2 = '' OR genre = $2)
+		ORDER BY position, starts_at DESC, id
+		LIMIT $3;
+	`, kind, genre, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []Collection{}
+	for rows.Next() {
+		cl, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, cl)
+	}
+	return collections, rows.Err()
+}
+
+// writeCollectionError maps a slug clash to 409 and a failed table check
+// (the window or a spotlight's genre) to 400.
+func writeCollectionError(c *gin.Context, err error) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		c.JSON(http.StatusConflict, gin.H{"error": "that slug is taken"})
+		return
+	}
+	if errors.As(err, &pgErr) && pgErr.Code == "23514" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be after starts_at, and genre spotlights need a genre"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// RegisterCollectionRoutes defines editorial collections: admin and curator
+// management, the public list and pages, and the discover feed.
+func RegisterCollectionRoutes(r *gin.Engine) {
+	// GET /collections?kind=&genre= — collections featured now, in editorial order
+	r.GET("/collections", AllowClient(), RequireScope(apiScopeSongsRead), func(c *gin.Context) {
+		genre := c.Query("genre")
+		if genre != "" {
+			var err error
+			if genre, err = normalizeGenre(genre); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		collections, err := listCollections(c.Request.Context(), true, c.Query("kind"), genre, 100)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, collections)
+	})
+
+	// GET /collections/:slug — the collection and its songs
+	r.GET("/collections/:slug", AllowClient(), RequireScope(apiScopeSongsRead), OptionalAuth(), func(c *gin.Context) {
+		ctx := c.Request.Context()
+		cl, err := scanCollection(db.QueryRow(ctx,
+			`SELECT `+collectionColumns+` FROM collections WHERE slug = $1 AND `+collectionActive+`;`, c.Param("slug")))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "collection not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if cl.Songs, err = collectionSongs(ctx, cl.ID, hideExplicit(c), maxCollectionSongs); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, cl)
+	})
+
+	// GET /discover — featured collections with the first few songs of each
+	r.GET("/discover", AllowClient(), RequireScope(apiScopeSongsRead), OptionalAuth(), func(c *gin.Context) {
+		ctx := c.Request.Context()
+		collections, err := listCollections(ctx, true, "", "", discoverCollections)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		hide := hideExplicit(c)
+		featured := collections[:0]
+		for _, cl := range collections {
+			if cl.Songs, err = collectionSongs(ctx, cl.ID, hide, discoverSongsPerList); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if len(cl.Songs) > 0 {
+				featured = append(featured, cl)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"collections": featured})
+	})
+
+	admin := r.Group("/admin/collections", RequireAuth(), RequireRole("admin", "curator"), RequireMFA())
+
+	// GET /admin/collections — every collection, including scheduled and ended ones
+	admin.GET("", func(c *gin.Context) {
+		collections, err := listCollections(c.Request.Context(), false, c.Query("kind"), "", 500)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, collections)
+	})
+
+	// POST /admin/collections {"slug","title","description","kind","genre","cover_url","position","starts_at","ends_at"}
+	admin.POST("", func(c *gin.Context) {
+		var in collectionInput
+		if err := c.BindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if in.Slug == nil || in.Title == nil || in.Kind == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "slug, title, and kind are required"})
+			return
+		}
+		if err := in.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if *in.Kind == "genre_spotlight" && (in.Genre == nil || *in.Genre == "") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "genre spotlights need a genre"})
+			return
+		}
+
+		cl, err := scanCollection(db.QueryRow(context.Background(), `
+			INSERT INTO collections (slug, title, description, kind, genre, cover_url, position, starts_at, ends_at, created_by)
+			VALUES ($1, $2, NULLIF($3, ''), $4, NULLIF($5, ''), NULLIF($6, ''), COALESCE($7, 0), COALESCE($8, now()),
+				NULLIF($9, '')::timestamptz, $10)
+			RETURNING `+collectionColumns+`;
+		`, in.Slug, in.Title, in.Description, in.Kind, in.Genre, in.CoverURL, in.Position, in.StartsAt, in.EndsAt,
+			currentUserID(c)))
+		if err != nil {
+			writeCollectionError(c, err)
+			return
+		}
+		cl.Songs = []Song{}
+		c.JSON(http.StatusCreated, cl)
+	})
+
+	// PATCH /admin/collections/:id — any of the POST fields; "" clears optional text and ends_at
+	admin.PATCH("/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid collection id"})
+			return
+		}
+		var in collectionInput
+		if err := c.BindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if err := in.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		cl, err := scanCollection(db.QueryRow(context.Background(), `
+			UPDATE collections SET
+				slug        = COALESCE($2, slug),
+				title       = COALESCE($3, title),
+				description = CASE WHEN $4::text IS NULL THEN description ELSE NULLIF($4, '') END,
+				kind        = COALESCE($5, kind),
+				genre       = CASE WHEN $6::text IS NULL THEN genre ELSE NULLIF($6, '') END,
+				cover_url   = CASE WHEN $7::text IS NULL THEN cover_url ELSE NULLIF($7, '') END,
+				position    = COALESCE($8, position),
+				starts_at   = COALESCE($9, starts_at),
+				ends_at     = CASE WHEN $10::text IS NULL THEN ends_at ELSE NULLIF($10, '')::timestamptz END,
+				updated_at  = now()
+			WHERE id = $1
+			RETURNING `+collectionColumns+`;
+		`, id, in.Slug, in.Title, in.Description, in.Kind, in.Genre, in.CoverURL, in.Position, in.StartsAt, in.EndsAt))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "collection not found"})
+			return
+		}
+		if err != nil {
+			writeCollectionError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, cl)
+	})
+
+	// PUT /admin/collections/:id/songs {"song_ids":[3,1,2]} — replaces the songs, in order
+	admin.PUT("/:id/songs", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid collection id"})
+			return
+		}
+		var body struct {
+			SongIDs []int64 `json:"song_ids"`
+		}
+		if err := c.BindJSON(&body); err != nil || body.SongIDs == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": `expected {"song_ids":[...]}`})
+			return
+		}
+		if len(body.SongIDs) > maxCollectionSongs {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d songs", maxCollectionSongs)})
+			return
+		}
+		seen := map[int64]bool{}
+		for _, s := range body.SongIDs {
+			if seen[s] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("song %d is listed twice", s)})
+				return
+			}
+			seen[s] = true
+		}
+		ctx := c.Request.Context()
+
+		var missing []int64
+		if err := db.QueryRow(ctx, `
+			SELECT COALESCE(array_agg(t.song_id), '{}') FROM unnest($1::bigint[]) AS t (song_id)
+			WHERE NOT EXISTS (SELECT 1 FROM songs s WHERE s.id = t.song_id AND s.published);
+		`, body.SongIDs).Scan(&missing); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(missing) > 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "songs must exist and be published", "song_ids": missing})
+			return
+		}
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		tag, err := tx.Exec(ctx, `UPDATE collections SET updated_at = now() WHERE id = $1;`, id)
+		if err == nil && tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "collection not found"})
+			return
+		}
+		if err == nil {
+			_, err = tx.Exec(ctx, `DELETE FROM collection_songs WHERE collection_id = $1;`, id)
+		}
+		if err == nil {
+			_, err = tx.Exec(ctx, `
+				INSERT INTO collection_songs (collection_id, song_id, position)
+				SELECT $1, song_id, position FROM unnest($2::bigint[]) WITH ORDINALITY AS t (song_id, position);
+			`, id, body.SongIDs)
+		}
+		if err == nil {
+			err = tx.Commit(ctx)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"collection_id": id, "song_ids": body.SongIDs})
+	})
+
+	// DELETE /admin/collections/:id
+	admin.DELETE("/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid collection id"})
+			return
+		}
+		tag, err := db.Exec(context.Background(), `DELETE FROM collections WHERE id = $1;`, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "collection not found"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Artists moderate comments on their songs: a per-song policy (everyone,
+// followers only, or disabled), hiding individual comments, and pinning one.
+// Hidden comments stay visible to the artist. Rejections carry a "code" clients
+// can branch on.
+const (
+	maxCommentBody     = 2000
+	defaultCommentPage = 50
+	maxCommentPage     = 100
+
+	commentPolicyEveryone  = "everyone"
+	commentPolicyFollowers = "followers"
+	commentPolicyDisabled  = "disabled"
+
+	commentErrSongNotFound  = "song_not_found"
+	commentErrInvalid       = "invalid_comment"
+	commentErrDisabled      = "comments_disabled"
+	commentErrAuthRequired  = "auth_required"
+	commentErrFollowersOnly = "followers_only"
+)
+
+var commentPolicies = map[string]bool{
+	commentPolicyEveryone:  true,
+	commentPolicyFollowers: true,
+	commentPolicyDisabled:  true,
+}
+
+const commentColumns = `id, song_id, author_id::text, parent_id, body, hidden_at, pinned_at, created_at`
+
+func scanComment(row pgx.Row) (Comment, error) {
+	var cm Comment
+	err := row.Scan(&cm.ID, &cm.SongID, &cm.AuthorID, &cm.ParentID, &cm.Body, &cm.HiddenAt, &cm.PinnedAt, &cm.CreatedAt)
+	return cm, err
+}
+
+// commentDenied writes a moderation rejection.
+func commentDenied(c *gin.Context, status int, code, msg string) {
+	c.JSON(status, gin.H{"error": msg, "code": code})
+}
+
+// checkCommentPolicy reports whether authorID may comment on songID, writing
+// the rejection when not. authenticated is false for anonymous comments,
+// whose author_id is only what the client claimed.
+func checkCommentPolicy(c *gin.Context, songID int64, authorID string, authenticated bool) bool {
+	var (
+		policy    string
+		artistID  *string
+		published bool
+		following bool
+	)
+	err := db.QueryRow(context.Background(), `
+		SELECT s.comment_policy, s.artist_id::text, s.published,
+		       EXISTS (SELECT 1 FROM follows f WHERE f.follower_id::text = $2 AND f.followee_id = s.artist_id)
+		FROM songs s WHERE s.id = $1;
+	`, songID, authorID).Scan(&policy, &artistID, &published, &following)
+	isArtist := authenticated && artistID != nil && *artistID == authorID
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !published && !isArtist) {
+		commentDenied(c, http.StatusNotFound, commentErrSongNotFound, "song not found")
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if isArtist {
+		return true
+	}
+
+	switch policy {
+	case commentPolicyDisabled:
+		commentDenied(c, http.StatusForbidden, commentErrDisabled, "comments are turned off for this song")
+		return false
+	case commentPolicyFollowers:
+		if !authenticated {
+			commentDenied(c, http.StatusUnauthorized, commentErrAuthRequired, "sign in to comment on this song")
+			return false
+		}
+		if !following {
+			commentDenied(c, http.StatusForbidden, commentErrFollowersOnly, "only the artist's followers can comment on this song")
+			return false
+		}
+	}
+	return true
+}
+
+// commentOnOwnSong loads a comment's song and checks the caller is its
+// artist, writing the error response when not.
+func commentOnOwnSong(c *gin.Context, action string) (commentID, songID int64, ok bool) {
+	commentID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid comment id"})
+		return 0, 0, false
+	}
+
+	err = db.QueryRow(context.Background(),
+		`SELECT song_id FROM comments WHERE id = $1 AND trashed_at IS NULL;`, commentID).Scan(&songID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
+		return 0, 0, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return 0, 0, false
+	}
+
+	owned, err := songOwnedBy(context.Background(), songID, currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return 0, 0, false
+	}
+	if !owned {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only " + action + " comments on your own songs"})
+		return 0, 0, false
+	}
+	return commentID, songID, true
+}
+
+// RegisterCommentRoutes defines comments, artist replies, and moderation.
+func RegisterCommentRoutes(r *gin.Engine) {
+	// POST /comments — {"song_id":1,"body":"..."}; signed-in callers comment as themselves
+	r.POST("/comments", OptionalAuth(), func(c *gin.Context) {
+		var body Comment
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		authenticated := currentUserID(c) != ""
+		if authenticated {
+			body.AuthorID = currentUserID(c)
+		}
+		body.Body = strings.TrimSpace(body.Body)
+		if body.Body == "" || len(body.Body) > maxCommentBody {
+			commentDenied(c, http.StatusBadRequest, commentErrInvalid, "body must be 1-2000 characters")
+			return
+		}
+		if !checkCommentPolicy(c, body.SongID, body.AuthorID, authenticated) {
+			return
+		}
+
+		sql := `INSERT INTO comments (song_id, author_id, body)
+		        VALUES ($1, $2, $3)
+		        RETURNING id, song_id, author_id, body, created_at;`
+
+		err := db.QueryRow(context.Background(), sql,
+			body.SongID, body.AuthorID, body.Body,
+		).Scan(&body.ID, &body.SongID, &body.AuthorID, &body.Body, &body.CreatedAt)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Record engagement event
+		recordServerEvent(body.SongID, body.AuthorID, "comment")
+
+		c.JSON(http.StatusCreated, body)
+	})
+
+	// GET /songs/:id/comments?before_id=&limit=&badges=true — newest first; hidden ones only for the artist
+	r.GET("/songs/:id/comments", OptionalAuth(), func(c *gin.Context) {
+		songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultCommentPage)))
+		if err != nil || limit < 1 || limit > maxCommentPage {
+			limit = defaultCommentPage
+		}
+		var beforeID *int64
+		if s := c.Query("before_id"); s != "" {
+			id, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before_id"})
+				return
+			}
+			beforeID = &id
+		}
+
+		var (
+			policy    string
+			artistID  *string
+			published bool
+		)
+		err = db.QueryRow(context.Background(),
+			`SELECT comment_policy, artist_id::text, published FROM songs WHERE id = $1;`, songID,
+		).Scan(&policy, &artistID, &published)
+		isArtist := artistID != nil && *artistID == currentUserID(c)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && !published && !isArtist) {
+			commentDenied(c, http.StatusNotFound, commentErrSongNotFound, "song not found")
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		rows, err := db.Query(context.Background(), `
+			SELECT `+commentColumns+`
+			FROM comments
+			WHERE song_id = $1 AND ($2::bigint IS NULL OR id < $2) AND (hidden_at IS NULL OR $3)
+			  AND trashed_at IS NULL
+			  AND NOT EXISTS (SELECT 1 FROM comments p WHERE p.id = comments.parent_id AND p.trashed_at IS NOT NULL)
+			ORDER BY id DESC
+			LIMIT $4;
+		`, songID, beforeID, isArtist, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		comments := []Comment{}
+		for rows.Next() {
+			cm, err := scanComment(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			comments = append(comments, cm)
+		}
+		rows.Close()
+
+		var pinned *Comment
+		if beforeID == nil {
+			// The pinned comment heads the first page, whatever its age.
+			p, err := scanComment(db.QueryRow(context.Background(), `
+				SELECT `+commentColumns+` FROM comments
+				WHERE song_id = $1 AND pinned_at IS NOT NULL AND (hidden_at IS NULL OR $2) AND trashed_at IS NULL;
+			`, songID, isArtist))
+			switch {
+			case err == nil:
+				pinned = &p
+			case !errors.Is(err, pgx.ErrNoRows):
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		// ?badges=true attaches each author's badges for display.
+		if c.Query("badges") == "true" {
+			authors := []string{}
+			for _, cm := range comments {
+				authors = append(authors, cm.AuthorID)
+			}
+			if pinned != nil {
+				authors = append(authors, pinned.AuthorID)
+			}
+			badges, err := loadBadgeNames(c.Request.Context(), authors)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			for i := range comments {
+				comments[i].AuthorBadges = badges[comments[i].AuthorID]
+			}
+			if pinned != nil {
+				pinned.AuthorBadges = badges[pinned.AuthorID]
+			}
+		}
+
+		resp := gin.H{"comment_policy": policy, "comments": comments}
+		if pinned != nil {
+			resp["pinned"] = pinned
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+
+	// PUT /songs/:id/comment-policy — {"policy":"everyone|followers|disabled"}
+	r.PUT("/songs/:id/comment-policy", RequireAuthOrAPIKey(), RequireScope(apiScopeSongsWrite), func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "moderate")
+		if !ok {
+			return
+		}
+
+		var body struct {
+			Policy string `json:"policy"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if !commentPolicies[body.Policy] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "policy must be everyone, followers, or disabled"})
+			return
+		}
+
+		if _, err := db.Exec(context.Background(),
+			`UPDATE songs SET comment_policy = $2 WHERE id = $1;`, songID, body.Policy); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"song_id": songID, "comment_policy": body.Policy})
+	})
+
+	// PATCH /comments/:id — {"hidden":true} and/or {"pinned":true}; the song's artist only
+	r.PATCH("/comments/:id", RequireAuth(), func(c *gin.Context) {
+		commentID, songID, ok := commentOnOwnSong(c, "moderate")
+		if !ok {
+			return
+		}
+
+		var body struct {
+			Hidden *bool `json:"hidden"`
+			Pinned *bool `json:"pinned"`
+		}
+		if err := c.BindJSON(&body); err != nil || (body.Hidden == nil && body.Pinned == nil) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "hidden or pinned is required"})
+			return
+		}
+
+		tx, err := db.Begin(context.Background())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback(context.Background())
+
+		if body.Hidden != nil {
+			_, err = tx.Exec(context.Background(), `
+				UPDATE comments SET
+					hidden_at = CASE WHEN $2 THEN COALESCE(hidden_at, now()) END,
+					hidden_by = CASE WHEN $2 THEN $3::uuid END
+				WHERE id = $1;
+			`, commentID, *body.Hidden, currentUserID(c))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if body.Pinned != nil {
+			if *body.Pinned {
+				err = pinComment(context.Background(), tx, songID, commentID)
+			} else {
+				_, err = tx.Exec(context.Background(), `UPDATE comments SET pinned_at = NULL WHERE id = $1;`, commentID)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		cm, err := scanComment(tx.QueryRow(context.Background(),
+			`SELECT `+commentColumns+` FROM comments WHERE id = $1;`, commentID))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := tx.Commit(context.Background()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, cm)
+	})
+
+	// POST /comments/:id/replies — {"body":"..."}; posted as the song's artist
+	r.POST("/comments/:id/replies", RequireAuth(), RequireTeamScope(scopeCommentsReply), func(c *gin.Context) {
+		parentID, songID, ok := commentOnOwnSong(c, "reply to")
+		if !ok {
+			return
+		}
+
+		var body struct {
+			Body string `json:"body"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		body.Body = strings.TrimSpace(body.Body)
+		if body.Body == "" || len(body.Body) > maxCommentBody {
+			commentDenied(c, http.StatusBadRequest, commentErrInvalid, "body must be 1-2000 characters")
+			return
+		}
+
+		reply := Comment{SongID: songID, AuthorID: currentUserID(c), ParentID: &parentID, Body: body.Body}
+		err := db.QueryRow(context.Background(), `
+			INSERT INTO comments (song_id, author_id, parent_id, posted_by, body)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, created_at;
+		`, songID, reply.AuthorID, parentID, actorID(c), reply.Body).Scan(&reply.ID, &reply.CreatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		recordServerEvent(songID, reply.AuthorID, "comment")
+		c.JSON(http.StatusCreated, reply)
+	})
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Config holds the settings read from the environment at startup.
+type Config struct {
+	DatabaseURL string
+	JWTSecret   string
+
+	// DBMaxConns and DBMinConns bound the Postgres pool (DB_MAX_CONNS,
+	// DB_MIN_CONNS). Connections are replaced after DBMaxConnLifetime and
+	// closed after DBMaxConnIdleTime unused (DB_MAX_CONN_LIFETIME,
+	// DB_MAX_CONN_IDLE_TIME, durations like "30m").
+	DBMaxConns        int
+	DBMinConns        int
+	DBMaxConnLifetime time.Duration
+	DBMaxConnIdleTime time.Duration
+
+	// DBSlowQuery logs queries that take longer, without their parameters
+	// (DB_SLOW_QUERY, e.g. "500ms"; 0 turns it off).
+	DBSlowQuery time.Duration
+
+	// JWTVerification is how access tokens are verified: hs256 (JWTSecret),
+	// jwks (the project's asymmetric keys from JWKSURL), or both. JWKSURL
+	// defaults to the project's /auth/v1/.well-known/jwks.json.
+	JWTVerification string
+	JWKSURL         string
+
+	// SupabaseURL and SupabaseAnonKey are used to call Supabase Auth on the
+	// client's behalf (token refresh).
+	SupabaseURL     string
+	SupabaseAnonKey string
+
+	// OAuthRedirectURLs are the app URLs Google/Apple sign-in may return to
+	// (OAUTH_REDIRECT_URLS, comma-separated); the first is the default.
+	OAuthRedirectURLs []string
+
+	SpacesEndpoint string
+	SpacesRegion   string
+	SpacesBucket   string
+	SpacesKey      string
+	SpacesSecret   string
+
+	// SpacesRegionBuckets adds buckets in other regions for uploads
+	// (SPACES_REGION_BUCKETS, comma-separated region=bucket pairs, e.g.
+	// "ams3=leep-ams3,sgp1=leep-sgp1"). They share the key and secret.
+	SpacesRegionBuckets []string
+
+	// AssetBaseURL prefixes public asset URLs, e.g. a CDN in front of /assets.
+	AssetBaseURL string
+
+	// ShareBaseURL is the public web app origin used for links posted to
+	// social accounts, e.g. https://leep.example.
+	ShareBaseURL string
+
+	// TwitterClientID, TwitterClientSecret, and TwitterRedirectURL are the
+	// X (Twitter) OAuth 2.0 app used to connect artists' accounts.
+	TwitterClientID     string
+	TwitterClientSecret string
+	TwitterRedirectURL  string
+
+	// SpotifyClientID, SpotifyClientSecret, and SpotifyRedirectURL are the
+	// Spotify app artists link their accounts through.
+	SpotifyClientID     string
+	SpotifyClientSecret string
+	SpotifyRedirectURL  string
+
+	// FFmpegPath and FFprobePath are the binaries used by audio processing jobs.
+	FFmpegPath  string
+	FFprobePath string
+
+	EventStream      string
+	EventStreamTopic string
+	NATSAddr         string
+	KafkaRESTURL     string
+
+	// ImageModeration selects the artwork/avatar check (IMAGE_MODERATION:
+	// "http" or empty for none). The http moderator POSTs each image to
+	// ImageModerationURL with ImageModerationKey as a bearer token.
+	ImageModeration    string
+	ImageModerationURL string
+	ImageModerationKey string
+
+	// VirusScanner selects the scan for chat and comment attachments
+	// (VIRUS_SCANNER: "clamd" or empty for none). The clamd scanner streams
+	// each file to the daemon at ClamdAddr.
+	VirusScanner string
+	ClamdAddr    string
+
+	// LiveMediaServer selects the media server behind live sessions
+	// (LIVE_MEDIA_SERVER: "http" or empty for none). The http server's stream
+	// API is at LiveMediaServerURL with LiveMediaServerKey as a bearer token.
+	LiveMediaServer    string
+	LiveMediaServerURL string
+	LiveMediaServerKey string
+
+	// EventRetentionMonths is how long raw events stay in Postgres before the
+	// archival job moves them to Spaces. 0 disables scheduled archival.
+	EventRetentionMonths int
+
+	// StripeWebhookSecret verifies Stripe webhook signatures (whsec_...).
+	StripeWebhookSecret string
+	// StripeSecretKey calls the Stripe API for escrow payments and Connect
+	// transfers; StripeConnectReturnURL is where Connect onboarding sends
+	// producers back to.
+	StripeSecretKey        string
+	StripeConnectReturnURL string
+
+	// OpenAPIValidation checks traffic against openapi.json: off, requests
+	// (reject requests that don't match), or all (also log responses that
+	// drift from the contract; for dev and staging).
+	OpenAPIValidation string
+
+	// PlatformFeePercent is the platform's cut of each tip, used to report
+	// net amounts to artists.
+	PlatformFeePercent float64
+
+	// LegalVersions lists the current version of each legal document users
+	// must accept before writing (LEGAL_VERSIONS, "document=version,...",
+	// e.g. "tos=2026-10-01,privacy=2026-09-15").
+	LegalVersions []string
+
+	// MinAgeByRegion sets the minimum sign-up age per region
+	// (MIN_AGE_BY_REGION, "REGION=age,...", with "default" for the rest,
+	// e.g. "default=13,KR=14,NL=16"). Setting it also requires a birth date
+	// before writes.
+	MinAgeByRegion []string
+
+	// GeoIPCountryHeader names the header the CDN or load balancer sets to
+	// the client's country code (GEOIP_COUNTRY_HEADER, default
+	// CF-IPCountry). It locates tip payers who give no billing country.
+	GeoIPCountryHeader string
+
+	// JWTRoleClaim trusts the user_role claim a Supabase custom access
+	// token hook adds, when present, instead of looking the role up
+	// (JWT_ROLE_CLAIM=true). Role changes then apply on token refresh.
+	JWTRoleClaim bool
+
+	// TrustedProxies lists the load balancer or CDN addresses and CIDRs
+	// whose X-Forwarded-For is believed when working out a client's IP
+	// (TRUSTED_PROXIES, comma-separated). Empty trusts none, so the client
+	// IP is the connection's address and can't be spoofed with a header.
+	TrustedProxies []string
+}
+
+var cfg *Config
+
+// LoadConfig loads .env (if present) and reads settings into `cfg`.
+func LoadConfig() {
+	// Load local env vars (DATABASE_URL=...)
+	if err := godotenv.Load(); err != nil {
+		// not fatal in production, but locally we expect .env to exist
+		log.Println("⚠️  No .env file found, continuing anyway")
+	}
+
+	cfg = &Config{
+		DatabaseURL: os.Getenv("DATABASE_URL"),
+		JWTSecret:   os.Getenv("SUPABASE_JWT_SECRET"),
+
+		DBMaxConns:        envInt("DB_MAX_CONNS", 25),
+		DBMinConns:        envInt("DB_MIN_CONNS", 5),
+		DBMaxConnLifetime: envDuration("DB_MAX_CONN_LIFETIME", time.Hour),
+		DBMaxConnIdleTime: envDuration("DB_MAX_CONN_IDLE_TIME", 30*time.Minute),
+		DBSlowQuery:       envDuration("DB_SLOW_QUERY", 500*time.Millisecond),
+
+		JWTVerification: envOr("JWT_VERIFICATION", jwtVerifyHS256),
+		JWKSURL:         os.Getenv("SUPABASE_JWKS_URL"),
+
+		SupabaseURL:     strings.TrimRight(os.Getenv("SUPABASE_URL"), "/"),
+		SupabaseAnonKey: os.Getenv("SUPABASE_ANON_KEY"),
+
+		OAuthRedirectURLs: envList("OAUTH_REDIRECT_URLS"),
+
+		SpacesEndpoint: os.Getenv("SPACES_ENDPOINT"),
+		SpacesRegion:   envOr("SPACES_REGION", "nyc3"),
+		SpacesBucket:   os.Getenv("SPACES_BUCKET"),
+		SpacesKey:      os.Getenv("SPACES_KEY"),
+		SpacesSecret:   os.Getenv("SPACES_SECRET"),
+		AssetBaseURL:   strings.TrimRight(os.Getenv("ASSET_BASE_URL"), "/"),
+		ShareBaseURL:   strings.TrimRight(os.Getenv("SHARE_BASE_URL"), "/"),
+		FFmpegPath:     envOr("FFMPEG_PATH", "ffmpeg"),
+		FFprobePath:    envOr("FFPROBE_PATH", "ffprobe"),
+
+		SpacesRegionBuckets: envList("SPACES_REGION_BUCKETS"),
+
+		TwitterClientID:     os.Getenv("TWITTER_CLIENT_ID"),
+		TwitterClientSecret: os.Getenv("TWITTER_CLIENT_SECRET"),
+		TwitterRedirectURL:  os.Getenv("TWITTER_REDIRECT_URL"),
+
+		SpotifyClientID:     os.Getenv("SPOTIFY_CLIENT_ID"),
+		SpotifyClientSecret: os.Getenv("SPOTIFY_CLIENT_SECRET"),
+		SpotifyRedirectURL:  os.Getenv("SPOTIFY_REDIRECT_URL"),
+
+		EventStream:      os.Getenv("EVENT_STREAM"),
+		EventStreamTopic: envOr("EVENT_STREAM_TOPIC", "leep.events"),
+		NATSAddr:         envOr("NATS_URL", "nats://127.0.0.1:4222"),
+		KafkaRESTURL:     envOr("KAFKA_REST_URL", "http://127.0.0.1:8082"),
+
+		ImageModeration:    os.Getenv("IMAGE_MODERATION"),
+		ImageModerationURL: os.Getenv("IMAGE_MODERATION_URL"),
+		ImageModerationKey: os.Getenv("IMAGE_MODERATION_KEY"),
+
+		VirusScanner: os.Getenv("VIRUS_SCANNER"),
+		ClamdAddr:    envOr("CLAMD_ADDR", "127.0.0.1:3310"),
+
+		LiveMediaServer:    os.Getenv("LIVE_MEDIA_SERVER"),
+		LiveMediaServerURL: os.Getenv("LIVE_MEDIA_SERVER_URL"),
+		LiveMediaServerKey: os.Getenv("LIVE_MEDIA_SERVER_KEY"),
+
+		EventRetentionMonths: envInt("EVENT_RETENTION_MONTHS", 0),
+		PlatformFeePercent:   envFloat("PLATFORM_FEE_PERCENT", 0),
+		StripeWebhookSecret:  os.Getenv("STRIPE_WEBHOOK_SECRET"),
+
+		OpenAPIValidation: envOr("OPENAPI_VALIDATION", "off"),
+
+		StripeSecretKey:        os.Getenv("STRIPE_SECRET_KEY"),
+		StripeConnectReturnURL: os.Getenv("STRIPE_CONNECT_RETURN_URL"),
+
+		LegalVersions:  envList("LEGAL_VERSIONS"),
+		MinAgeByRegion: envList("MIN_AGE_BY_REGION"),
+
+		GeoIPCountryHeader: envOr("GEOIP_COUNTRY_HEADER", "CF-IPCountry"),
+
+		JWTRoleClaim: os.Getenv("JWT_ROLE_CLAIM") == "true",
+
+		TrustedProxies: envList("TRUSTED_PROXIES"),
+	}
+}
+
+// envOr returns the env var key, or def when it is unset.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// envList returns the comma-separated env var key with blanks dropped.
+func envList(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// envInt returns the env var key parsed as an int, or def when it is unset
+// or invalid.
+func envInt(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// envFloat returns the env var key parsed as a float, or def when it is
+// unset or invalid.
+func envFloat(key string, def float64) float64 {
+	v, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// envDuration returns the env var key parsed as a duration, or def when it
+// is unset or invalid.
+func envDuration(key string, def time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Users accept the current version of each legal document in
+// LEGAL_VERSIONS (at signup, and again whenever a version is bumped) with
+// POST /me/consent. Until they have, authenticated writes are refused with
+// code "consent_required"; reads, /auth account management, and the consent
+// endpoints themselves stay open. Every acceptance is kept for compliance.
+const consentCacheTTL = time.Minute
+
+// legalDocuments are the required documents in LEGAL_VERSIONS order.
+var legalDocuments = []LegalDocument{}
+
+// consentedUsers remembers users who have accepted every required version,
+// so writes don't query user_consents each time. Only passes are cached:
+// a user who accepts is let through at once.
+var consentedUsers = &consentCache{entries: map[string]time.Time{}}
+
+type consentCache struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+func (cc *consentCache) ok(userID string) bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	at, ok := cc.entries[userID]
+	if ok && time.Since(at) >= consentCacheTTL {
+		delete(cc.entries, userID)
+		return false
+	}
+	return ok
+}
+
+func (cc *consentCache) set(userID string) {
+	now := time.Now()
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for k, at := range cc.entries {
+		if now.Sub(at) >= consentCacheTTL {
+			delete(cc.entries, k)
+		}
+	}
+	cc.entries[userID] = now
+}
+
+// InitConsent reads the required legal document versions.
+func InitConsent() {
+	seen := map[string]bool{}
+	for _, pair := range cfg.LegalVersions {
+		doc, version, ok := strings.Cut(pair, "=")
+		doc, version = strings.ToLower(strings.TrimSpace(doc)), strings.TrimSpace(version)
+		if !ok || doc == "" || version == "" {
+			log.Printf("⚠️  ignoring LEGAL_VERSIONS entry %q, want document=version", pair)
+			continue
+		}
+		if seen[doc] {
+			continue
+		}
+		seen[doc] = true
+		legalDocuments = append(legalDocuments, LegalDocument{Document: doc, Version: version})
+	}
+	if len(legalDocuments) > 0 {
+		log.Printf("✅ Requiring consent to %d legal documents before writes", len(legalDocuments))
+	}
+}
+
+// pendingConsents returns the required document versions userID has not
+// accepted.
+func pendingConsents(ctx context.Context, userID string) ([]LegalDocument, error) {
+	pending := []LegalDocument{}
+	if len(legalDocuments) == 0 {
+		return pending, nil
+	}
+	rows, err := db.Query(ctx, `SELECT document, version FROM user_consents WHERE user_id = $1;`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	accepted := map[LegalDocument]bool{}
+	for rows.Next() {
+		var d LegalDocument
+		if err := rows.Scan(&d.Document, &d.Version); err != nil {
+			return nil, err
+		}
+		accepted[d] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, d := range legalDocuments {
+		if !accepted[d] {
+			pending = append(pending, d)
+		}
+	}
+	return pending, nil
+}
+
+// gateExempt reports whether path stays open to users held by the consent
+// or age gates: account management and the consent endpoints.
+func gateExempt(path string) bool {
+	return strings.HasPrefix(path, "/auth/") || path == "/me/consent"
+}
+
+// checkConsent aborts an authenticated write by a user who hasn't accepted
+// the current legal versions. RequireAuth calls it.
+func checkConsent(c *gin.Context, userID string) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	if len(legalDocuments) == 0 || gateExempt(c.FullPath()) || consentedUsers.ok(userID) {
+		return true
+	}
+
+	pending, err := pendingConsents(c.Request.Context(), userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if len(pending) > 0 {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error":    "accept the current terms to continue",
+			"code":     "consent_required",
+			"required": pending,
+		})
+		return false
+	}
+	consentedUsers.set(userID)
+	return true
+}
+
+const userConsentColumns = `id, user_id::text, document, version, ip, user_agent, accepted_at`
+
+// loadConsentHistory returns userID's acceptances, newest first.
+func loadConsentHistory(ctx context.Context, userID string) ([]UserConsent, error) {
+	rows, err := db.Query(ctx, `
+		SELECT `+userConsentColumns+` FROM user_consents
+		WHERE user_id = $1
+		ORDER BY accepted_at DESC, id DESC;
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []UserConsent{}
+	for rows.Next() {
+		var uc UserConsent
+		if err := rows.Scan(&uc.ID, &uc.UserID, &uc.Document, &uc.Version, &uc.IP, &uc.UserAgent, &uc.AcceptedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, uc)
+	}
+	return history, rows.Err()
+}
+
+// RegisterConsentRoutes defines the legal version listing, consent
+// acceptance, and consent history endpoints.
+func RegisterConsentRoutes(r *gin.Engine) {
+	// GET /legal/versions — the versions a new user must accept
+	r.GET("/legal/versions", func(c *gin.Context) {
+		c.JSON(http.StatusOK, legalDocuments)
+	})
+
+	// GET /me/consent — pending versions and acceptance history
+	r.GET("/me/consent", RequireAuth(), func(c *gin.Context) {
+		ctx := c.Request.Context()
+		pending, err := pendingConsents(ctx, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		history, err := loadConsentHistory(ctx, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"pending": pending, "history": history})
+	})
+
+	// POST /me/consent {"accept":[{"document":"tos","version":"2026-10-01"}]}
+	r.POST("/me/consent", RequireAuth(), func(c *gin.Context) {
+		var body struct {
+			Accept []LegalDocument `json:"accept"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if len(body.Accept) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "accept must list at least one document"})
+			return
+		}
+		current := map[LegalDocument]bool{}
+		for _, d := range legalDocuments {
+			current[d] = true
+		}
+		for _, d := range body.Accept {
+			if !current[d] {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":   d.Document + " " + d.Version + " is not a current version",
+					"current": legalDocuments,
+				})
+				return
+			}
+		}
+
+		ctx := c.Request.Context()
+		userID := currentUserID(c)
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback(ctx)
+		for _, d := range body.Accept {
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO user_consents (user_id, document, version, ip, user_agent)
+				VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''))
+				ON CONFLICT (user_id, document, version) DO NOTHING;
+			`, userID, d.Document, d.Version, c.ClientIP(), c.Request.UserAgent()); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		pending, err := pendingConsents(ctx, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"pending": pending})
+	})
+
+	// GET /admin/users/:id/consents — history for a compliance request
+	r.GET("/admin/users/:id/consents", RequireAuth(), RequireRole("admin"), RequireMFA(), func(c *gin.Context) {
+		if !userIDPattern.MatchString(c.Param("id")) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			return
+		}
+		history, err := loadConsentHistory(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"user_id": c.Param("id"), "current": legalDocuments, "history": history})
+	})
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Remix contests: the artist uploads source stems and sets a deadline,
+// producers upload one entry each (re-uploading replaces it) until then,
+// and the artist shortlists entries and assigns placements once it closes.
+// Contests, stems, and entries are public; files are served through
+// short-lived signed URLs.
+const (
+	contestURLExpiry   = time.Hour
+	maxContestFilename = 100
+	maxContestTitle    = 200
+)
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+const contestColumns = `id, artist_id::text, song_id, title, rules, deadline, deadline > now(), created_at`
+
+func scanContest(row pgx.Row) (RemixContest, error) {
+	var ct RemixContest
+	err := row.Scan(&ct.ID, &ct.ArtistID, &ct.SongID, &ct.Title, &ct.Rules, &ct.Deadline, &ct.Open, &ct.CreatedAt)
+	return ct, err
+}
+
+const contestEntryColumns = `id, contest_id, producer_id::text, title, storage_key, storage_region, size_bytes, content_type,
+	shortlisted, placement, created_at, updated_at`
+
+func scanContestEntry(row pgx.Row) (ContestEntry, error) {
+	var e ContestEntry
+	err := row.Scan(&e.ID, &e.ContestID, &e.ProducerID, &e.Title, &e.StorageKey, &e.StorageRegion, &e.SizeBytes, &e.ContentType,
+		&e.Shortlisted, &e.Placement, &e.CreatedAt, &e.UpdatedAt)
+	return e, err
+}
+
+// loadContest writes the error response when the contest can't be loaded.
+func loadContest(c *gin.Context) (RemixContest, bool) {
+	contestID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid contest id"})
+		return RemixContest{}, false
+	}
+
+	ct, err := scanContest(db.QueryRow(context.Background(),
+		`SELECT `+contestColumns+` FROM remix_contests WHERE id = $1;`, contestID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "contest not found"})
+		return RemixContest{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return RemixContest{}, false
+	}
+	return ct, true
+}
+
+func loadContestStems(ctx context.Context, contestID int64) ([]ContestStem, error) {
+	rows, err := db.Query(ctx, `
+		SELECT id, contest_id, filename, storage_key, storage_region, size_bytes, content_type, created_at
+		FROM contest_stems WHERE contest_id = $1 ORDER BY id;
+	`, contestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stems := []ContestStem{}
+	for rows.Next() {
+		var s ContestStem
+		if err := rows.Scan(&s.ID, &s.ContestID, &s.Filename, &s.StorageKey, &s.StorageRegion, &s.SizeBytes, &s.ContentType, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		if storage != nil {
+			s.DownloadURL = storageFor(s.StorageRegion).PresignGet(s.StorageKey, contestURLExpiry)
+		}
+		stems = append(stems, s)
+	}
+	return stems, rows.Err()
+}
+
+func loadContestEntries(ctx context.Context, contestID int64) ([]ContestEntry, error) {
+	rows, err := db.Query(ctx, `
+		SELECT `+contestEntryColumns+` FROM contest_entries
+		WHERE contest_id = $1
+		ORDER BY placement NULLS LAST, shortlisted DESC, created_at;
+	`, contestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []ContestEntry{}
+	for rows.Next() {
+		e, err := scanContestEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		if storage != nil {
+			e.StreamURL = storageFor(e.StorageRegion).PresignGet(e.StorageKey, contestURLExpiry)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// contestFilename makes an uploaded name safe to use in a storage key.
+func contestFilename(name string) string {
+	name = strings.Trim(unsafeFilenameChars.ReplaceAllString(name, "_"), "._")
+	if len(name) > maxContestFilename {
+		name = name[:maxContestFilename]
+	}
+	if name == "" {
+		name = "stem"
+	}
+	return name
+}
+
+// RegisterContestRoutes defines remix contests, their stems, and entries.
+func RegisterContestRoutes(r *gin.Engine) {
+	// GET /contests?status=open|closed
+	r.GET("/contests", func(c *gin.Context) {
+		status := c.DefaultQuery("status", "open")
+		if status != "open" && status != "closed" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be open or closed"})
+			return
+		}
+
+		order := "deadline"
+		if status == "closed" {
+			order = "deadline DESC"
+		}
+		rows, err := db.Query(context.Background(),
+			`SELECT `+contestColumns+` FROM remix_contests WHERE (deadline > now()) = $1 ORDER BY `+order+` LIMIT 100;`,
+			status == "open")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		contests := []RemixContest{}
+		for rows.Next() {
+			ct, err := scanContest(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			contests = append(contests, ct)
+		}
+		c.JSON(http.StatusOK, contests)
+	})
+
+	// GET /contests/:id — contest, stems to download, and entries
+	r.GET("/contests/:id", func(c *gin.Context) {
+		ct, ok := loadContest(c)
+		if !ok {
+			return
+		}
+
+		stems, err := loadContestStems(context.Background(), ct.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		entries, err := loadContestEntries(context.Background(), ct.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"contest": ct, "stems": stems, "entries": entries})
+	})
+
+	// POST /contests — {"title":"...","rules":"...","deadline":"2025-07-01T00:00:00Z","song_id":1}
+	r.POST("/contests", RequireAuth(), func(c *gin.Context) {
+		var body struct {
+			Title    string     `json:"title"`
+			Rules    string     `json:"rules"`
+			Deadline *time.Time `json:"deadline"`
+			SongID   *int64     `json:"song_id"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		body.Title = strings.TrimSpace(body.Title)
+		if body.Title == "" || len(body.Title) > maxContestTitle {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "title must be 1-200 characters"})
+			return
+		}
+		if body.Deadline == nil || !body.Deadline.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "deadline must be in the future"})
+			return
+		}
+		if body.SongID != nil {
+			owned, err := songOwnedBy(context.Background(), *body.SongID, currentUserID(c))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if !owned {
+				c.JSON(http.StatusForbidden, gin.H{"error": "you can only run contests for your own songs"})
+				return
+			}
+		}
+
+		ct, err := scanContest(db.QueryRow(context.Background(), `
+			INSERT INTO remix_contests (artist_id, song_id, title, rules, deadline)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+contestColumns+`;
+		`, currentUserID(c), body.SongID, body.Title, body.Rules, *body.Deadline))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, ct)
+	})
+
+	// POST /contests/:id/stems?filename=drums.wav — raw audio body; artist only, while open
+	r.POST("/contests/:id/stems", RequireAuth(), func(c *gin.Context) {
+		ct, ok := loadContest(c)
+		if !ok {
+			return
+		}
+		if ct.ArtistID != currentUserID(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only the contest's artist can add stems"})
+			return
+		}
+		if !ct.Open {
+			c.JSON(http.StatusConflict, gin.H{"error": "the contest has closed"})
+			return
+		}
+		upload, ok := audioUploadFrom(c)
+		if !ok {
+			return
+		}
+
+		filename := contestFilename(c.Query("filename"))
+		key := fmt.Sprintf("contests/%d/stems/%d-%s", ct.ID, time.Now().UnixNano(), filename)
+		region := uploadRegion(c)
+		if err := storageFor(region).PutObject(context.Background(), key, c.Request.Body, upload.Size, upload.ContentType); err != nil {
+			writeStorageError(c, http.StatusBadGateway, err)
+			return
+		}
+
+		s := ContestStem{ContestID: ct.ID, Filename: filename, StorageKey: key, StorageRegion: region,
+			SizeBytes: upload.Size, ContentType: upload.ContentType}
+		err := db.QueryRow(context.Background(), `
+			INSERT INTO contest_stems (contest_id, filename, storage_key, storage_region, size_bytes, content_type)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, created_at;
+		`, ct.ID, s.Filename, key, region, s.SizeBytes, s.ContentType).Scan(&s.ID, &s.CreatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, s)
+	})
+
+	// DELETE /contests/:id/stems/:stemId — artist only
+	r.DELETE("/contests/:id/stems/:stemId", RequireAuth(), func(c *gin.Context) {
+		ct, ok := loadContest(c)
+		if !ok {
+			return
+		}
+		if ct.ArtistID != currentUserID(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only the contest's artist can remove stems"})
+			return
+		}
+		stemID, err := strconv.ParseInt(c.Param("stemId"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid stem id"})
+			return
+		}
+
+		var key, region string
+		err = db.QueryRow(context.Background(),
+			`DELETE FROM contest_stems WHERE id = $1 AND contest_id = $2 RETURNING storage_key, storage_region;`, stemID, ct.ID,
+		).Scan(&key, &region)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "stem not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if storage != nil {
+			if err := storageFor(region).DeleteObject(context.Background(), key); err != nil {
+				log.Printf("contest %d: failed to delete stem %s: %v", ct.ID, key, err)
+			}
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	// POST /contests/:id/entries?title=My+Remix — raw audio body; one entry per producer, replaced on re-upload
+	r.POST("/contests/:id/entries", RequireAuth(), func(c *gin.Context) {
+		ct, ok := loadContest(c)
+		if !ok {
+			return
+		}
+		if ct.ArtistID == currentUserID(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you can't enter your own contest"})
+			return
+		}
+		if !ct.Open {
+			c.JSON(http.StatusConflict, gin.H{"error": "the contest has closed"})
+			return
+		}
+		title := strings.TrimSpace(c.Query("title"))
+		if title == "" || len(title) > maxContestTitle {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "title must be 1-200 characters"})
+			return
+		}
+		upload, ok := audioUploadFrom(c)
+		if !ok {
+			return
+		}
+
+		key := fmt.Sprintf("contests/%d/entries/%s/%d.%s", ct.ID, currentUserID(c), time.Now().UnixNano(), upload.Ext)
+		region := uploadRegion(c)
+		if err := storageFor(region).PutObject(context.Background(), key, c.Request.Body, upload.Size, upload.ContentType); err != nil {
+			writeStorageError(c, http.StatusBadGateway, err)
+			return
+		}
+
+		var previousKey, previousRegion *string
+		db.QueryRow(context.Background(),
+			`SELECT storage_key, storage_region FROM contest_entries WHERE contest_id = $1 AND producer_id = $2;`,
+			ct.ID, currentUserID(c)).Scan(&previousKey, &previousRegion)
+
+		e, err := scanContestEntry(db.QueryRow(context.Background(), `
+			INSERT INTO contest_entries (contest_id, producer_id, title, storage_key, storage_region, size_bytes, content_type)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (contest_id, producer_id) DO UPDATE SET
+				title = EXCLUDED.title, storage_key = EXCLUDED.storage_key, storage_region = EXCLUDED.storage_region,
+				size_bytes = EXCLUDED.size_bytes, content_type = EXCLUDED.content_type, updated_at = now()
+			RETURNING `+contestEntryColumns+`;
+		`, ct.ID, currentUserID(c), title, key, region, upload.Size, upload.ContentType))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if previousKey != nil && *previousKey != key {
+			if err := storageFor(*previousRegion).DeleteObject(context.Background(), *previousKey); err != nil {
+				log.Printf("contest %d: failed to delete replaced entry %s: %v", ct.ID, *previousKey, err)
+			}
+		}
+
+		c.JSON(http.StatusCreated, e)
+	})
+
+	// PATCH /contests/:id/entries/:entryId — {"shortlisted":true,"placement":1}; artist only
+	r.PATCH("/contests/:id/entries/:entryId", RequireAuth(), func(c *gin.Context) {
+		ct, ok := loadContest(c)
+		if !ok {
+			return
+		}
+		if ct.ArtistID != currentUserID(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only the contest's artist can judge entries"})
+			return
+		}
+		entryID, err := strconv.ParseInt(c.Param("entryId"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid entry id"})
+			return
+		}
+
+		var body struct {
+			Shortlisted *bool `json:"shortlisted"`
+			Placement   *int  `json:"placement"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if body.Placement != nil {
+			if ct.Open {
+				c.JSON(http.StatusConflict, gin.H{"error": "placements can be set once the contest closes"})
+				return
+			}
+			if *body.Placement < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "placement must be 1 or more"})
+				return
+			}
+		}
+
+		// A placed entry is always shortlisted; removing it from the
+		// shortlist clears its placement.
+		e, err := scanContestEntry(db.QueryRow(context.Background(), `
+			UPDATE contest_entries SET
+				shortlisted = COALESCE($3::bool, shortlisted) OR $4::int IS NOT NULL,
+				placement   = CASE WHEN $3::bool = false AND $4::int IS NULL THEN NULL ELSE COALESCE($4, placement) END,
+				updated_at  = now()
+			WHERE id = $1 AND contest_id = $2
+			RETURNING `+contestEntryColumns+`;
+		`, entryID, ct.ID, body.Shortlisted, body.Placement))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "entry not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, e)
+	})
+}
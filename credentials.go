@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Password and email changes go through Supabase Auth. A password change
+// checks the current password by signing in with it (under the sign-in
+// throttle), sets the new one on that fresh session, and signs out every
+// other session (the caller's current one included) so a leaked password
+// or refresh token stops working; the caller continues on the fresh session
+// in the response. An email change only takes effect once the confirmation
+// link Supabase sends is followed.
+const minPasswordLength = 8
+
+// supabaseAuthError is a 4xx from Supabase Auth, e.g. a weak password or
+// an email already in use.
+type supabaseAuthError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *supabaseAuthError) Error() string {
+	return fmt.Sprintf("supabase auth returned %d: %s", e.Status, e.Message)
+}
+
+// updateSupabaseUser updates the user behind accessToken.
+func updateSupabaseUser(ctx context.Context, accessToken string, payload interface{}) error {
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, cfg.SupabaseURL+"/auth/v1/user", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("apikey", cfg.SupabaseAnonKey)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := supabaseHTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	if resp.StatusCode/100 == 4 {
+		var out struct {
+			ErrorCode        string `json:"error_code"`
+			Msg              string `json:"msg"`
+			Message          string `json:"message"`
+			ErrorDescription string `json:"error_description"`
+		}
+		json.Unmarshal(raw, &out)
+		msg := out.Msg
+		for _, m := range []string{out.Message, out.ErrorDescription} {
+			if msg == "" {
+				msg = m
+			}
+		}
+		return &supabaseAuthError{Status: resp.StatusCode, Code: out.ErrorCode, Message: msg}
+	}
+	return fmt.Errorf("supabase auth returned %d: %s", resp.StatusCode, raw)
+}
+
+// writeSupabaseAuthError writes err from updateSupabaseUser.
+func writeSupabaseAuthError(c *gin.Context, action string, err error) {
+	var authErr *supabaseAuthError
+	if errors.As(err, &authErr) {
+		status := http.StatusBadRequest
+		if authErr.Code == "email_exists" {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": authErr.Message, "code": authErr.Code})
+		return
+	}
+	log.Printf("%s: %v", action, err)
+	c.JSON(http.StatusBadGateway, gin.H{"error": "could not reach the auth provider"})
+}
+
+// RegisterCredentialRoutes defines PATCH /auth/password and PATCH /auth/email.
+func RegisterCredentialRoutes(r *gin.Engine) {
+	a := r.Group("/auth", RequireAuth(), func(c *gin.Context) {
+		if !supabaseConfigured() {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "auth is not configured"})
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+	})
+
+	// PATCH /auth/password {"current_password","new_password"} — returns a new session
+	a.PATCH("/password", func(c *gin.Context) {
+		var body struct {
+			CurrentPassword string `json:"current_password"`
+			NewPassword     string `json:"new_password"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if body.CurrentPassword == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "current_password is required"})
+			return
+		}
+		if len(body.NewPassword) < minPasswordLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("new_password must be at least %d characters", minPasswordLength)})
+			return
+		}
+		if body.NewPassword == body.CurrentPassword {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "new_password must differ from the current one"})
+			return
+		}
+		claims := c.MustGet("claims").(*Claims)
+		if claims.Email == "" {
+			c.JSON(http.StatusConflict, gin.H{"error": "this account signs in without a password"})
+			return
+		}
+		ctx := c.Request.Context()
+
+		session, ok := checkPassword(c, claims.Email, body.CurrentPassword, "invalid_password", "current password is incorrect")
+		if !ok {
+			return
+		}
+		fresh, err := ValidateToken(session.AccessToken)
+		if err != nil || fresh.Subject != claims.Subject || fresh.SessionID == "" {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "the auth provider returned an unexpected session"})
+			return
+		}
+
+		if err := updateSupabaseUser(ctx, session.AccessToken, gin.H{"password": body.NewPassword}); err != nil {
+			writeSupabaseAuthError(c, "password change", err)
+			return
+		}
+
+		// Deleting a session deletes its refresh tokens, as in DELETE
+		// /auth/sessions/:id.
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback(ctx)
+		if _, err := tx.Exec(ctx,
+			`DELETE FROM auth.sessions WHERE user_id = $1 AND id <> $2::uuid;`, claims.Subject, fresh.SessionID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := tx.Exec(ctx,
+			`DELETE FROM mfa_sessions WHERE user_id = $1 AND session_id <> $2;`, claims.Subject, fresh.SessionID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, session)
+	})
+
+	// PATCH /auth/email {"email"} — Supabase emails a confirmation link
+	a.PATCH("/email", func(c *gin.Context) {
+		var body struct {
+			Email string `json:"email"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		email := strings.TrimSpace(body.Email)
+		if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "email is not a valid address"})
+			return
+		}
+		claims := c.MustGet("claims").(*Claims)
+		if strings.EqualFold(email, claims.Email) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "that is already your email"})
+			return
+		}
+		if otherAccountWithEmail(c.Request.Context(), claims.Subject, email) != "" {
+			c.JSON(http.StatusConflict, gin.H{"error": "another account already uses this email", "code": "email_exists"})
+			return
+		}
+
+		if err := updateSupabaseUser(c.Request.Context(), bearerToken(c), gin.H{"email": email}); err != nil {
+			writeSupabaseAuthError(c, "email change", err)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"email":   email,
+			"status":  "confirmation_sent",
+			"message": "the change takes effect once the link sent to the new address is followed",
+		})
+	})
+}
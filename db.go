@@ -2,42 +2,99 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"strings"
+	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 var db *pgxpool.Pool
 
-// InitDB loads .env, connects to Supabase Postgres, and stores the pool in `db`.
-func InitDB() {
-	// Load local env vars (DATABASE_URL=...)
-	err := godotenv.Load()
+// dbPoolConfig builds the pool settings from cfg.
+func dbPoolConfig() (*pgxpool.Config, error) {
+	if cfg.DatabaseURL == "" {
+		return nil, errors.New("DATABASE_URL is not set in environment (.env)")
+	}
+	poolCfg, err := pgxpool.ParseConfig(cfg.DatabaseURL)
 	if err != nil {
-		// not fatal in production, but locally we expect .env to exist
-		log.Println("⚠️  No .env file found, continuing anyway")
+		return nil, fmt.Errorf("invalid DATABASE_URL: %w", err)
 	}
-
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("❌ DATABASE_URL is not set in environment (.env)")
+	if cfg.DBMaxConns < 1 || cfg.DBMinConns < 0 || cfg.DBMinConns > cfg.DBMaxConns {
+		return nil, fmt.Errorf("DB_MIN_CONNS (%d) and DB_MAX_CONNS (%d) must satisfy 0 <= min <= max, max >= 1",
+			cfg.DBMinConns, cfg.DBMaxConns)
+	}
+	poolCfg.MaxConns = int32(cfg.DBMaxConns)
+	poolCfg.MinConns = int32(cfg.DBMinConns)
+	poolCfg.MaxConnLifetime = cfg.DBMaxConnLifetime
+	poolCfg.MaxConnIdleTime = cfg.DBMaxConnIdleTime
+	if cfg.DBSlowQuery > 0 {
+		poolCfg.ConnConfig.Tracer = slowQueryTracer{threshold: cfg.DBSlowQuery}
 	}
+	return poolCfg, nil
+}
 
-	// Create a connection pool
-	pool, err := pgxpool.New(context.Background(), dbURL)
+// connectDB connects to Supabase Postgres and stores the pool in `db`.
+func connectDB(ctx context.Context, poolCfg *pgxpool.Config) error {
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
 	if err != nil {
-		log.Fatalf("❌ Failed to create DB pool: %v", err)
+		return fmt.Errorf("create pool: %w", err)
 	}
-
 	// Ping to verify connection works
-	err = pool.Ping(context.Background())
-	if err != nil {
-		log.Fatalf("❌ Failed to ping database: %v", err)
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return fmt.Errorf("ping: %w", err)
 	}
 
 	db = pool
 	fmt.Println("✅ Connected to Supabase Postgres successfully!")
+	return nil
+}
+
+// slowQueryTracer logs queries that run longer than threshold. Parameters
+// can hold emails, tokens, and message bodies, so only their types are
+// logged.
+type slowQueryTracer struct {
+	threshold time.Duration
+}
+
+type slowQueryKey struct{}
+
+type slowQueryStart struct {
+	at   time.Time
+	sql  string
+	args []any
+}
+
+func (t slowQueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, slowQueryKey{}, slowQueryStart{at: time.Now(), sql: data.SQL, args: data.Args})
+}
+
+func (t slowQueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(slowQueryKey{}).(slowQueryStart)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(start.at)
+	if elapsed < t.threshold {
+		return
+	}
+	status := "ok"
+	if data.Err != nil {
+		status = "error: " + data.Err.Error()
+	}
+	log.Printf("⚠️  Slow query (%s, %s): %s [%s]", elapsed.Round(time.Millisecond), status,
+		strings.Join(strings.Fields(start.sql), " "), redactedArgs(start.args))
+}
+
+// redactedArgs describes query parameters by position and type only.
+func redactedArgs(args []any) string {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		parts[i] = fmt.Sprintf("$%d=<%T>", i+1, a)
+	}
+	return strings.Join(parts, " ")
 }
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Artists link a Discord channel by pasting its webhook URL. Release and
+// milestone announcements are queued as discord_deliveries rows and posted
+// by the job queue, which retries failures; the delivery log is the
+// integration's status page.
+const (
+	discordPostJob       = "discord_post"
+	discordKindRelease   = "release"
+	discordKindMilestone = "milestone"
+	discordKindTest      = "test"
+	maxDiscordContent    = 2000
+)
+
+type discordIntegrationInput struct {
+	WebhookURL       *string `json:"webhook_url"`
+	ChannelName      *string `json:"channel_name"`
+	NotifyReleases   *bool   `json:"notify_releases"`
+	NotifyMilestones *bool   `json:"notify_milestones"`
+	Enabled          *bool   `json:"enabled"`
+}
+
+type discordPostPayload struct {
+	DeliveryID int64 `json:"delivery_id"`
+}
+
+func init() {
+	RegisterJobHandler(discordPostJob, runDiscordPost)
+}
+
+// validDiscordWebhookURL accepts only Discord's own webhook endpoints, so
+// the integration can't be pointed at arbitrary hosts.
+func validDiscordWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host != "discord.com" && host != "discordapp.com" && host != "ptb.discord.com" && host != "canary.discord.com" {
+		return false
+	}
+	return strings.HasPrefix(u.Path, "/api/webhooks/")
+}
+
+const discordIntegrationColumns = `id, user_id, webhook_url, channel_name, notify_releases, notify_milestones, enabled, created_at, updated_at`
+
+func scanDiscordIntegration(row pgx.Row) (DiscordIntegration, error) {
+	var d DiscordIntegration
+	err := row.Scan(&d.ID, &d.UserID, &d.WebhookURL, &d.ChannelName, &d.NotifyReleases,
+		&d.NotifyMilestones, &d.Enabled, &d.CreatedAt, &d.UpdatedAt)
+	return d, err
+}
+
+// announceToDiscord queues a message to the artist's Discord channel if they
+// have one linked and opted into this kind. Failures are logged.
+func announceToDiscord(ctx context.Context, artistID, kind, content string) {
+	var integrationID int64
+	err := db.QueryRow(ctx, `
+		SELECT id FROM discord_integrations
+		WHERE user_id = $1 AND enabled
+		  AND (($2 = 'release' AND notify_releases) OR ($2 = 'milestone' AND notify_milestones));
+	`, artistID, kind).Scan(&integrationID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return
+	}
+	if err == nil {
+		_, err = queueDiscordDelivery(ctx, integrationID, kind, content)
+	}
+	if err != nil {
+		log.Printf("discord %s for %s: %v", kind, artistID, err)
+	}
+}
+
+func queueDiscordDelivery(ctx context.Context, integrationID int64, kind, content string) (int64, error) {
+	if len(content) > maxDiscordContent {
+		content = content[:maxDiscordContent]
+	}
+
+	var deliveryID int64
+	err := db.QueryRow(ctx, `
+		INSERT INTO discord_deliveries (integration_id, kind, content)
+		VALUES ($1, $2, $3)
+		RETURNING id;
+	`, integrationID, kind, content).Scan(&deliveryID)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = EnqueueJob(ctx, discordPostJob, discordPostPayload{DeliveryID: deliveryID}, "")
+	return deliveryID, err
+}
+
+func runDiscordPost(ctx context.Context, job *Job) (interface{}, error) {
+	var p discordPostPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return nil, err
+	}
+
+	var (
+		content, hookURL string
+		enabled          bool
+	)
+	err := db.QueryRow(ctx, `
+		SELECT d.content, i.webhook_url, i.enabled
+		FROM discord_deliveries d
+		JOIN discord_integrations i ON i.id = d.integration_id
+		WHERE d.id = $1;
+	`, p.DeliveryID).Scan(&content, &hookURL, &enabled)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !enabled) {
+		return gin.H{"skipped": true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	body, _ := json.Marshal(gin.H{
+		"content":          content,
+		"username":         "Leep",
+		"allowed_mentions": gin.H{"parse": []string{}},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hookURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var status *int
+	resp, err := webhookHTTP.Do(req)
+	if err == nil {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		status = &resp.StatusCode
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			err = fmt.Errorf("discord returned %d", resp.StatusCode)
+		}
+	}
+
+	if err == nil {
+		_, dbErr := db.Exec(ctx, `
+			UPDATE discord_deliveries
+			SET status = 'delivered', attempts = attempts + 1, response_status = $2,
+			    last_error = NULL, delivered_at = now()
+			WHERE id = $1;
+		`, p.DeliveryID, status)
+		return gin.H{"response_status": *status}, dbErr
+	}
+
+	finalStatus := "pending"
+	if job.Attempts >= jobMaxAttempts {
+		finalStatus = "failed"
+	}
+	if _, dbErr := db.Exec(ctx, `
+		UPDATE discord_deliveries
+		SET status = $2, attempts = attempts + 1, response_status = $3, last_error = $4
+		WHERE id = $1;
+	`, p.DeliveryID, finalStatus, status, err.Error()); dbErr != nil {
+		log.Printf("discord delivery %d: failed to record attempt: %v", p.DeliveryID, dbErr)
+	}
+	return nil, err
+}
+
+// RegisterDiscordRoutes defines /me/integrations/discord.
+func RegisterDiscordRoutes(r *gin.Engine) {
+	me := r.Group("/me/integrations/discord", RequireAuth())
+
+	// GET /me/integrations/discord — settings plus recent deliveries
+	me.GET("", func(c *gin.Context) {
+		d, err := scanDiscordIntegration(db.QueryRow(context.Background(),
+			`SELECT `+discordIntegrationColumns+` FROM discord_integrations WHERE user_id = $1;`,
+			currentUserID(c)))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "discord is not linked"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		rows, err := db.Query(context.Background(), `
+			SELECT id, kind, content, status, attempts, response_status, last_error, created_at, delivered_at
+			FROM discord_deliveries WHERE integration_id = $1
+			ORDER BY id DESC
+			LIMIT 50;
+		`, d.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		deliveries := []DiscordDelivery{}
+		for rows.Next() {
+			var dd DiscordDelivery
+			if err := rows.Scan(&dd.ID, &dd.Kind, &dd.Content, &dd.Status, &dd.Attempts,
+				&dd.ResponseStatus, &dd.LastError, &dd.CreatedAt, &dd.DeliveredAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			deliveries = append(deliveries, dd)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"integration": d, "deliveries": deliveries})
+	})
+
+	// PUT /me/integrations/discord — links or updates the channel
+	me.PUT("", func(c *gin.Context) {
+		var body discordIntegrationInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if body.WebhookURL != nil && !validDiscordWebhookURL(*body.WebhookURL) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "webhook_url must be a Discord webhook URL"})
+			return
+		}
+
+		// Linking for the first time requires a URL; later updates may omit it.
+		d, err := scanDiscordIntegration(db.QueryRow(context.Background(), `
+			INSERT INTO discord_integrations AS i (user_id, webhook_url, channel_name, notify_releases, notify_milestones, enabled)
+			SELECT $1, $2, COALESCE($3, ''), COALESCE($4, true), COALESCE($5, true), COALESCE($6, true)
+			WHERE $2::text IS NOT NULL OR EXISTS (SELECT 1 FROM discord_integrations WHERE user_id = $1)
+			ON CONFLICT (user_id) DO UPDATE SET
+				webhook_url       = COALESCE($2, i.webhook_url),
+				channel_name      = COALESCE($3, i.channel_name),
+				notify_releases   = COALESCE($4, i.notify_releases),
+				notify_milestones = COALESCE($5, i.notify_milestones),
+				enabled           = COALESCE($6, i.enabled),
+				updated_at        = now()
+			RETURNING `+discordIntegrationColumns+`;
+		`, currentUserID(c), body.WebhookURL, body.ChannelName, body.NotifyReleases, body.NotifyMilestones, body.Enabled))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "webhook_url is required"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, d)
+	})
+
+	// POST /me/integrations/discord/test — queues a test message
+	me.POST("/test", func(c *gin.Context) {
+		var integrationID int64
+		err := db.QueryRow(context.Background(),
+			`SELECT id FROM discord_integrations WHERE user_id = $1;`, currentUserID(c),
+		).Scan(&integrationID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "discord is not linked"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		deliveryID, err := queueDiscordDelivery(context.Background(), integrationID, discordKindTest,
+			"✅ Leep is connected to this channel.")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"delivery_id": deliveryID})
+	})
+
+	// DELETE /me/integrations/discord — unlinks the channel
+	me.DELETE("", func(c *gin.Context) {
+		_, err := db.Exec(context.Background(),
+			`DELETE FROM discord_integrations WHERE user_id = $1;`, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Stripe sends charge.dispute.* webhooks for chargebacks. A dispute is
+// matched to its tip by payment intent; while it's open the tip's net is
+// frozen out of the artist's balance (a "frozen" tip_ledger entry). Winning
+// releases it ("unfrozen"); losing keeps it deducted ("charged_back"). The
+// artist is notified when a dispute opens and when it closes.
+const (
+	stripeSignatureTolerance = 5 * time.Minute
+	maxStripeWebhookBody     = 1 << 20
+	maxDisputePage           = 100
+)
+
+// stripeDispute is the part of Stripe's Dispute object we read.
+type stripeDispute struct {
+	ID              string `json:"id"`
+	Amount          int64  `json:"amount"`
+	Currency        string `json:"currency"`
+	Reason          string `json:"reason"`
+	Status          string `json:"status"`
+	PaymentIntent   string `json:"payment_intent"`
+	EvidenceDetails struct {
+		DueBy int64 `json:"due_by"`
+	} `json:"evidence_details"`
+}
+
+// disputeClosed reports whether a Stripe dispute status is final.
+func disputeClosed(status string) bool {
+	return status == "won" || status == "lost" || status == "warning_closed"
+}
+
+// verifyStripeSignature checks a Stripe-Signature header
+// ("t=<unix>,v1=<hex>[,v1=...]") against the raw body.
+func verifyStripeSignature(header string, body []byte, secret string, now time.Time) bool {
+	var (
+		ts   int64
+		sigs []string
+	)
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "t":
+			ts, _ = strconv.ParseInt(v, 10, 64)
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	if ts == 0 || len(sigs) == 0 {
+		return false
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > stripeSignatureTolerance || d < -stripeSignatureTolerance {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", ts)
+	mac.Write(body)
+	expected := mac.Sum(nil)
+	for _, s := range sigs {
+		if got, err := hex.DecodeString(s); err == nil && hmac.Equal(got, expected) {
+			return true
+		}
+	}
+	return false
+}
+
+const disputeColumns = `id, stripe_dispute_id, payment_intent_id, tip_id, artist_id::text, amount::float8, frozen_amount::float8,
+	currency, reason, status, evidence_due_by, created_at, updated_at, closed_at`
+
+func scanDispute(row pgx.Row) (TipDispute, error) {
+	var d TipDispute
+	err := row.Scan(&d.ID, &d.StripeDisputeID, &d.PaymentIntentID, &d.TipID, &d.ArtistID, &d.Amount, &d.FrozenAmount,
+		&d.Currency, &d.Reason, &d.Status, &d.EvidenceDueBy, &d.CreatedAt, &d.UpdatedAt, &d.ClosedAt)
+	return d, err
+}
+
+// applyDispute records a dispute's latest state, freezing or settling the
+// tip's amount as it opens and closes. It returns the dispute and whether
+// the artist should hear about it (it just opened or just closed).
+func applyDispute(ctx context.Context, sd stripeDispute) (TipDispute, bool, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return TipDispute{}, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var dueBy *time.Time
+	if sd.EvidenceDetails.DueBy > 0 {
+		t := time.Unix(sd.EvidenceDetails.DueBy, 0).UTC()
+		dueBy = &t
+	}
+	closed := disputeClosed(sd.Status)
+
+	prev, err := scanDispute(tx.QueryRow(ctx,
+		`SELECT `+disputeColumns+` FROM tip_disputes WHERE stripe_dispute_id = $1 FOR UPDATE;`, sd.ID))
+	isNew := errors.Is(err, pgx.ErrNoRows)
+	if err != nil && !isNew {
+		return TipDispute{}, false, err
+	}
+
+	var d TipDispute
+	if isNew {
+		var (
+			tipID        *int64
+			artistID     *string
+			tipAmount    float64
+			reviewStatus string
+		)
+		err := tx.QueryRow(ctx, `
+			SELECT t.id, s.artist_id::text, t.amount::float8, t.review_status
+			FROM tips t LEFT JOIN songs s ON s.id = t.song_id
+			WHERE t.payment_intent_id = $1;
+		`, sd.PaymentIntent).Scan(&tipID, &artistID, &tipAmount, &reviewStatus)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return TipDispute{}, false, err
+		}
+
+		// Only money that's in the balance can be frozen: a cleared tip's net,
+		// scaled down for a partial dispute.
+		amount := float64(sd.Amount) / 100
+		frozen := 0.0
+		if tipID != nil && artistID != nil && reviewStatus == tipStatusCleared && !closed {
+			_, frozen = tipFee(math.Min(amount, tipAmount))
+		}
+
+		d, err = scanDispute(tx.QueryRow(ctx, `
+			INSERT INTO tip_disputes (stripe_dispute_id, payment_intent_id, tip_id, artist_id, amount, frozen_amount,
+			                          currency, reason, status, evidence_due_by, closed_at)
+			VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, $9, $10, CASE WHEN $11 THEN now() END)
+			RETURNING `+disputeColumns+`;
+		`, sd.ID, sd.PaymentIntent, tipID, artistID, amount, frozen, sd.Currency, sd.Reason, sd.Status, dueBy, closed))
+		if err != nil {
+			return TipDispute{}, false, err
+		}
+		if frozen > 0 {
+			if err := disputeLedger(ctx, tx, d, "frozen", -frozen); err != nil {
+				return TipDispute{}, false, err
+			}
+		}
+	} else {
+		d, err = scanDispute(tx.QueryRow(ctx, `
+			UPDATE tip_disputes
+			SET status = $2, reason = $3, evidence_due_by = $4, updated_at = now(),
+			    closed_at = CASE WHEN $5 THEN COALESCE(closed_at, now()) END
+			WHERE id = $1
+			RETURNING `+disputeColumns+`;
+		`, prev.ID, sd.Status, sd.Reason, dueBy, closed))
+		if err != nil {
+			return TipDispute{}, false, err
+		}
+		if closed && prev.ClosedAt == nil && d.FrozenAmount > 0 {
+			action, amount := "unfrozen", d.FrozenAmount
+			if d.Status == "lost" {
+				action, amount = "charged_back", 0
+			}
+			if err := disputeLedger(ctx, tx, d, action, amount); err != nil {
+				return TipDispute{}, false, err
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return TipDispute{}, false, err
+	}
+	notifyArtist := d.ArtistID != nil && (isNew || (closed && prev.ClosedAt == nil))
+	return d, notifyArtist, nil
+}
+
+func disputeLedger(ctx context.Context, tx pgx.Tx, d TipDispute, action string, amount float64) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO tip_ledger (tip_id, artist_id, action, amount, note)
+		VALUES ($1, $2, $3, $4, $5);
+	`, d.TipID, d.ArtistID, action, amount, "stripe dispute "+d.StripeDisputeID)
+	return err
+}
+
+func notifyDispute(ctx context.Context, d TipDispute) {
+	title, body := "A tip was disputed",
+		fmt.Sprintf("A tipper disputed a %.2f %s tip. %.2f is on hold until the dispute is resolved.",
+			d.Amount, strings.ToUpper(d.Currency), d.FrozenAmount)
+	switch {
+	case d.Status == "lost":
+		title, body = "A tip dispute was lost", "The disputed tip was charged back and won't be paid out."
+	case d.ClosedAt != nil:
+		title, body = "A tip dispute was resolved", "The dispute was closed in your favor and the amount is back in your balance."
+	}
+	data := gin.H{"dispute_id": d.ID, "tip_id": d.TipID, "status": d.Status}
+	if err := notify(ctx, *d.ArtistID, "tip_dispute", title, body, data); err != nil {
+		log.Printf("dispute %s: failed to notify artist: %v", d.StripeDisputeID, err)
+	}
+}
+
+// RegisterDisputeRoutes defines the Stripe webhook and the admin dispute view.
+func RegisterDisputeRoutes(r *gin.Engine) {
+	// POST /stripe/webhooks — signed by Stripe; charge.dispute.* and payment_intent.succeeded are applied, others acknowledged
+	r.POST("/stripe/webhooks", func(c *gin.Context) {
+		if cfg.StripeWebhookSecret == "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "stripe webhooks are not configured"})
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxStripeWebhookBody))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "could not read body"})
+			return
+		}
+		if !verifyStripeSignature(c.GetHeader("Stripe-Signature"), body, cfg.StripeWebhookSecret, time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid signature"})
+			return
+		}
+
+		var event struct {
+			ID   string `json:"id"`
+			Type string `json:"type"`
+			Data struct {
+				Object json.RawMessage `json:"object"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &event); err != nil || event.ID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event"})
+			return
+		}
+		// apply runs after the event is recorded; an error un-records it.
+		var apply func(ctx context.Context) error
+		switch {
+		case strings.HasPrefix(event.Type, "charge.dispute."):
+			var sd stripeDispute
+			if err := json.Unmarshal(event.Data.Object, &sd); err != nil || sd.ID == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dispute object"})
+				return
+			}
+			apply = func(ctx context.Context) error {
+				d, notifyArtist, err := applyDispute(ctx, sd)
+				if err != nil {
+					return err
+				}
+				if d.TipID == nil {
+					log.Printf("stripe dispute %s: no tip with payment intent %q", sd.ID, sd.PaymentIntent)
+				}
+				if notifyArtist {
+					notifyDispute(ctx, d)
+				}
+				return nil
+			}
+		case event.Type == "payment_intent.succeeded":
+			var pi stripePaymentIntent
+			if err := json.Unmarshal(event.Data.Object, &pi); err != nil || pi.ID == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payment intent object"})
+				return
+			}
+			apply = func(ctx context.Context) error { return fundEscrow(ctx, pi) }
+		default:
+			c.JSON(http.StatusOK, gin.H{"received": true, "ignored": true})
+			return
+		}
+
+		// Record the event first so a retry of one already applied is a no-op.
+		tag, err := db.Exec(context.Background(),
+			`INSERT INTO stripe_events (id, type) VALUES ($1, $2) ON CONFLICT DO NOTHING;`, event.ID, event.Type)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusOK, gin.H{"received": true, "duplicate": true})
+			return
+		}
+
+		if err := apply(context.Background()); err != nil {
+			// Forget the event so Stripe's retry applies it.
+			if _, delErr := db.Exec(context.Background(), `DELETE FROM stripe_events WHERE id = $1;`, event.ID); delErr != nil {
+				log.Printf("stripe event %s: failed to forget after error: %v", event.ID, delErr)
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"received": true})
+	})
+
+	admin := r.Group("/admin/disputes", RequireAuth(), RequireRole("admin"), RequireMFA())
+
+	// GET /admin/disputes?status=open|closed|all — evidence deadlines first
+	admin.GET("", func(c *gin.Context) {
+		status := c.DefaultQuery("status", "open")
+		if status != "open" && status != "closed" && status != "all" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be open, closed, or all"})
+			return
+		}
+
+		rows, err := db.Query(context.Background(), `
+			SELECT `+disputeColumns+` FROM tip_disputes
+			WHERE $1 = 'all' OR ($1 = 'open') = (closed_at IS NULL)
+			ORDER BY evidence_due_by NULLS LAST, id DESC
+			LIMIT $2;
+		`, status, maxDisputePage)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		disputes := []TipDispute{}
+		for rows.Next() {
+			d, err := scanDispute(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			disputes = append(disputes, d)
+		}
+		c.JSON(http.StatusOK, disputes)
+	})
+
+	// GET /admin/disputes/:id — with its ledger entries
+	admin.GET("/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dispute id"})
+			return
+		}
+		d, err := scanDispute(db.QueryRow(context.Background(),
+			`SELECT `+disputeColumns+` FROM tip_disputes WHERE id = $1;`, id))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "dispute not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		rows, err := db.Query(context.Background(), `
+			SELECT id, tip_id, artist_id::text, action, amount::float8, admin_id::text, note, created_at
+			FROM tip_ledger WHERE tip_id = $1
+			ORDER BY id;
+		`, d.TipID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		ledger := []TipLedgerEntry{}
+		for rows.Next() {
+			var e TipLedgerEntry
+			if err := rows.Scan(&e.ID, &e.TipID, &e.ArtistID, &e.Action, &e.Amount, &e.AdminID, &e.Note, &e.CreatedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			ledger = append(ledger, e)
+		}
+		c.JSON(http.StatusOK, gin.H{"dispute": d, "ledger": ledger})
+	})
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Domain events record what happened to songs, tips, and projects in an
+// append-only log for support. Each is written in the transaction that
+// makes the change, so the log never shows a change that rolled back or
+// misses one that committed.
+const (
+	domainSongPublished = "song.published"
+	domainTipConfirmed  = "tip.confirmed"
+	domainMemberAdded   = "project.member_added"
+
+	maxDomainEventPage = 200
+)
+
+// appendDomainEvent adds an event to the log inside tx. actorID may be "".
+func appendDomainEvent(ctx context.Context, tx pgx.Tx, eventType, entityType, entityID, actorID string, data interface{}) error {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO domain_events (type, entity_type, entity_id, actor_id, data)
+		VALUES ($1, $2, $3, NULLIF($4, '')::uuid, $5);
+	`, eventType, entityType, entityID, actorID, raw)
+	return err
+}
+
+// RegisterDomainEventRoutes defines the admin view of the domain event log.
+func RegisterDomainEventRoutes(r *gin.Engine) {
+	admin := r.Group("/admin/domain-events", RequireAuth(), RequireRole("admin"), RequireMFA())
+
+	// GET /admin/domain-events?entity_type=song&entity_id=42&type=&before_id=&limit= — newest first
+	admin.GET("", func(c *gin.Context) {
+		entityType, entityID := c.Query("entity_type"), c.Query("entity_id")
+		if entityID != "" && entityType == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "entity_id needs entity_type"})
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit < 1 || limit > maxDomainEventPage {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be 1-" + strconv.Itoa(maxDomainEventPage)})
+			return
+		}
+		var beforeID *int64
+		if v := c.Query("before_id"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before_id"})
+				return
+			}
+			beforeID = &id
+		}
+
+		rows, err := db.Query(context.Background(), `
+			SELECT id, type, entity_type, entity_id, actor_id::text, data, occurred_at
+			FROM domain_events
+			WHERE ($1 = '' OR entity_type = $1)
+			  AND ($2 = '' OR entity_id = $2)
+			  AND ($3 = '' OR type = $3)
+			  AND ($4::bigint IS NULL OR id < $4)
+			ORDER BY id DESC
+			LIMIT $5;
+		`, entityType, entityID, c.Query("type"), beforeID, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		events := []DomainEvent{}
+		for rows.Next() {
+			var e DomainEvent
+			if err := rows.Scan(&e.ID, &e.Type, &e.EntityType, &e.EntityID, &e.ActorID, &e.Data, &e.OccurredAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			events = append(events, e)
+		}
+		c.JSON(http.StatusOK, events)
+	})
+}
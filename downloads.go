@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Artists can let listeners download a song's original file. Downloads are
+// off until the artist turns them on, and can be limited to the artist's
+// followers or to fans who have tipped the song. The artist can always
+// download their own songs; everyone else's downloads are logged as
+// "download" events.
+const (
+	downloadGateNone   = "none"
+	downloadGateFollow = "follow"
+	downloadGateTip    = "tip"
+
+	downloadErrDisabled       = "downloads_disabled"
+	downloadErrAuthRequired   = "auth_required"
+	downloadErrFollowRequired = "follow_required"
+	downloadErrTipRequired    = "tip_required"
+
+	// Long enough to start a large file on a slow connection.
+	downloadURLExpiry = 15 * time.Minute
+)
+
+var downloadGates = map[string]bool{
+	downloadGateNone:   true,
+	downloadGateFollow: true,
+	downloadGateTip:    true,
+}
+
+// downloadFilename is the name a song's original is saved as: its title
+// with characters that upset file systems replaced, plus ext.
+func downloadFilename(title, ext string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			return -1
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	name = strings.Trim(name, ". ")
+	if name == "" {
+		name = "song"
+	}
+	return name + ext
+}
+
+// checkDownloadGate reports whether userID passes the song's download gate,
+// writing the rejection when not.
+func checkDownloadGate(c *gin.Context, s Song, userID string) bool {
+	if s.DownloadGate == downloadGateNone {
+		return true
+	}
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sign in to download this song", "code": downloadErrAuthRequired})
+		return false
+	}
+
+	var following, tipped bool
+	if err := db.QueryRow(c.Request.Context(), `
+		SELECT EXISTS (SELECT 1 FROM follows f WHERE f.follower_id::text = $2 AND f.followee_id::text = $3),
+		       EXISTS (SELECT 1 FROM tips t WHERE t.song_id = $1 AND t.sender_id::text = $2 AND t.review_status = 'cleared');
+	`, s.ID, userID, s.ArtistID).Scan(&following, &tipped); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	switch {
+	case s.DownloadGate == downloadGateFollow && !following:
+		c.JSON(http.StatusForbidden, gin.H{"error": "follow the artist to download this song", "code": downloadErrFollowRequired})
+		return false
+	case s.DownloadGate == downloadGateTip && !tipped:
+		c.JSON(http.StatusForbidden, gin.H{"error": "tip this song to download it", "code": downloadErrTipRequired})
+		return false
+	}
+	return true
+}
+
+// RegisterDownloadRoutes defines song download settings and downloads.
+func RegisterDownloadRoutes(r *gin.Engine) {
+	// PUT /songs/:id/downloads — {"enabled":true,"gate":"none|follow|tip"}; gate is kept when left out
+	r.PUT("/songs/:id/downloads", RequireAuthOrAPIKey(), RequireScope(apiScopeSongsWrite), func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "change")
+		if !ok {
+			return
+		}
+
+		var body struct {
+			Enabled *bool   `json:"enabled"`
+			Gate    *string `json:"gate"`
+		}
+		if err := c.BindJSON(&body); err != nil || body.Enabled == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "enabled is required"})
+			return
+		}
+		if body.Gate != nil && !downloadGates[*body.Gate] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "gate must be none, follow, or tip"})
+			return
+		}
+
+		var (
+			enabled bool
+			gate    string
+		)
+		if err := db.QueryRow(context.Background(), `
+			UPDATE songs SET downloads_enabled = $2, download_gate = COALESCE($3, download_gate)
+			WHERE id = $1
+			RETURNING downloads_enabled, download_gate;
+		`, songID, *body.Enabled, body.Gate).Scan(&enabled, &gate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"song_id": songID, "downloads_enabled": enabled, "download_gate": gate})
+	})
+
+	// GET /songs/:id/download — redirects to a short-lived URL that saves the
+	// original file, if the song allows downloads and the caller passes its gate.
+	r.GET("/songs/:id/download", OptionalAuth(), func(c *gin.Context) {
+		songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+			return
+		}
+		ctx := c.Request.Context()
+		userID := currentUserID(c)
+
+		s, err := scanSong(db.QueryRow(ctx, `SELECT `+songColumns+` FROM `+songFrom+` WHERE s.id = $1 AND s.trashed_at IS NULL;`, songID))
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && !songVisibleTo(s, userID)) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "song not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		own := userID != "" && s.ArtistID != nil && *s.ArtistID == userID
+		if !own {
+			if s.Explicit && abortIfExplicit(c, "this song") {
+				return
+			}
+			if !s.DownloadsEnabled {
+				c.JSON(http.StatusForbidden, gin.H{"error": "the artist hasn't allowed downloads of this song", "code": downloadErrDisabled})
+				return
+			}
+			if !checkDownloadGate(c, s, userID) {
+				return
+			}
+		}
+
+		orig, err := scanRendition(db.QueryRow(ctx,
+			`SELECT `+renditionColumns+` FROM song_renditions WHERE song_id = $1 AND name = $2 AND status = 'ready';`,
+			songID, originalRendition))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "song audio is not ready"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if storage == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is not configured"})
+			return
+		}
+
+		if !own {
+			e := IngestEvent{
+				SchemaVersion: currentEventSchemaVersion,
+				EventType:     "download",
+				SongID:        songID,
+				OccurredAt:    time.Now().UTC(),
+				Properties:    map[string]interface{}{"gate": s.DownloadGate},
+			}
+			if userID != "" {
+				e.UserID = &userID
+			}
+			if err := publishBeacon(c, e); err != nil {
+				log.Printf("song %d: failed to record download: %v", songID, err)
+			}
+		}
+
+		c.Header("Cache-Control", "no-store")
+		filename := downloadFilename(s.Title, path.Ext(orig.StorageKey))
+		c.Redirect(http.StatusFound, storageFor(orig.StorageRegion).PresignDownload(orig.StorageKey, filename, downloadURLExpiry))
+	})
+}
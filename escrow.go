@@ -0,0 +1,626 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// A project owner funds a producer's fee into escrow: POST /escrows creates
+// a PaymentIntent the app confirms with Stripe, and payment_intent.succeeded
+// marks it funded. Marking the milestone complete releases it to the
+// producer's Stripe Connect account as a transfer. Either side can dispute a
+// funded escrow instead; an admin then releases or refunds it. Transfers and
+// refunds run as escrow_settle jobs with idempotency keys, so retries never
+// pay twice.
+const (
+	escrowSettleJob = "escrow_settle"
+	stripeAPIURL    = "https://api.stripe.com/v1"
+	maxEscrowPage   = 100
+)
+
+var stripeHTTP = &http.Client{Timeout: 10 * time.Second}
+
+func init() {
+	RegisterJobHandler(escrowSettleJob, runEscrowSettle)
+}
+
+type escrowSettlePayload struct {
+	EscrowID int64 `json:"escrow_id"`
+}
+
+// stripePaymentIntent is the part of Stripe's PaymentIntent object we read.
+type stripePaymentIntent struct {
+	ID             string            `json:"id"`
+	Status         string            `json:"status"`
+	Amount         int64             `json:"amount"`
+	AmountReceived int64             `json:"amount_received"`
+	LatestCharge   string            `json:"latest_charge"`
+	Metadata       map[string]string `json:"metadata"`
+}
+
+const escrowColumns = `id, project_id, artist_id::text, producer_id::text, milestone, amount::float8, currency, status,
+	dispute_reason, disputed_by::text, resolution_note, last_error, created_at, funded_at, settled_at, updated_at`
+
+func scanEscrow(row pgx.Row) (ProjectEscrow, error) {
+	var e ProjectEscrow
+	err := row.Scan(&e.ID, &e.ProjectID, &e.ArtistID, &e.ProducerID, &e.Milestone, &e.Amount, &e.Currency, &e.Status,
+		&e.DisputeReason, &e.DisputedBy, &e.ResolutionNote, &e.LastError, &e.CreatedAt, &e.FundedAt, &e.SettledAt, &e.UpdatedAt)
+	return e, err
+}
+
+// toCents converts a NUMERIC(12,2) amount to Stripe's minor units.
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// stripePost calls the Stripe API and decodes the response into out. The
+// idempotency key makes a retried call return the original result.
+func stripePost(ctx context.Context, path string, form url.Values, idempotencyKey string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, stripeAPIURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	return stripeDo(req, out)
+}
+
+// stripeGet reads a Stripe API object into out.
+func stripeGet(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, stripeAPIURL+path, nil)
+	if err != nil {
+		return err
+	}
+	return stripeDo(req, out)
+}
+
+func stripeDo(req *http.Request, out interface{}) error {
+	req.SetBasicAuth(cfg.StripeSecretKey, "")
+	resp, err := stripeHTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(raw, &e) == nil && e.Error.Message != "" {
+			return fmt.Errorf("stripe returned %d: %s", resp.StatusCode, e.Error.Message)
+		}
+		return fmt.Errorf("stripe returned %d", resp.StatusCode)
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// fundEscrow marks the escrow paid by a succeeded PaymentIntent as funded.
+// PaymentIntents that aren't for an escrow (tips) are ignored.
+func fundEscrow(ctx context.Context, pi stripePaymentIntent) error {
+	e, err := scanEscrow(db.QueryRow(ctx, `
+		UPDATE project_escrows
+		SET status = 'funded', charge_id = NULLIF($3, ''), funded_at = now(), updated_at = now()
+		WHERE payment_intent_id = $1 AND status = 'awaiting_payment' AND $2 >= round(amount * 100)
+		RETURNING `+escrowColumns+`;
+	`, pi.ID, pi.AmountReceived, pi.LatestCharge))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	data := gin.H{"escrow_id": e.ID, "project_id": e.ProjectID}
+	body := fmt.Sprintf("%.2f %s for \"%s\" is held in escrow until the milestone is complete.",
+		e.Amount, strings.ToUpper(e.Currency), e.Milestone)
+	if err := notify(ctx, e.ProducerID, "escrow_funded", "Your fee was funded", body, data); err != nil {
+		log.Printf("escrow %d: failed to notify producer: %v", e.ID, err)
+	}
+	return nil
+}
+
+// settleEscrow enqueues the transfer or refund for an escrow in releasing
+// or refunding.
+func settleEscrow(ctx context.Context, e ProjectEscrow, createdBy string) error {
+	_, err := EnqueueJob(ctx, escrowSettleJob, escrowSettlePayload{EscrowID: e.ID}, createdBy)
+	return err
+}
+
+func runEscrowSettle(ctx context.Context, job *Job) (interface{}, error) {
+	var p escrowSettlePayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return nil, err
+	}
+
+	var (
+		status, producerID, currency         string
+		amount                               float64
+		paymentIntentID, chargeID, accountID *string
+	)
+	err := db.QueryRow(ctx, `
+		SELECT e.status, e.producer_id::text, e.amount::float8, e.currency, e.payment_intent_id, e.charge_id,
+		       pr.stripe_account_id
+		FROM project_escrows e LEFT JOIN profiles pr ON pr.id = e.producer_id
+		WHERE e.id = $1;
+	`, p.EscrowID).Scan(&status, &producerID, &amount, &currency, &paymentIntentID, &chargeID, &accountID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && status != "releasing" && status != "refunding") {
+		return gin.H{"skipped": true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		obj struct {
+			ID string `json:"id"`
+		}
+		final string
+	)
+	if status == "releasing" {
+		if accountID == nil {
+			err = errors.New("the producer has no connected Stripe account")
+		} else {
+			form := url.Values{
+				"amount":                {strconv.FormatInt(toCents(amount), 10)},
+				"currency":              {currency},
+				"destination":           {*accountID},
+				"transfer_group":        {fmt.Sprintf("escrow_%d", p.EscrowID)},
+				"metadata[escrow_id]":   {strconv.FormatInt(p.EscrowID, 10)},
+				"metadata[producer_id]": {producerID},
+			}
+			// Tying the transfer to the charge lets it go out before the
+			// charge's funds are available in the platform balance.
+			if chargeID != nil {
+				form.Set("source_transaction", *chargeID)
+			}
+			err = stripePost(ctx, "/transfers", form, fmt.Sprintf("escrow-%d-transfer", p.EscrowID), &obj)
+		}
+		final = "released"
+	} else {
+		if paymentIntentID == nil {
+			err = errors.New("the escrow has no payment to refund")
+		} else {
+			err = stripePost(ctx, "/refunds", url.Values{
+				"payment_intent":      {*paymentIntentID},
+				"metadata[escrow_id]": {strconv.FormatInt(p.EscrowID, 10)},
+			}, fmt.Sprintf("escrow-%d-refund", p.EscrowID), &obj)
+		}
+		final = "refunded"
+	}
+	if err != nil {
+		if _, dbErr := db.Exec(ctx,
+			`UPDATE project_escrows SET last_error = $2, updated_at = now() WHERE id = $1;`,
+			p.EscrowID, err.Error()); dbErr != nil {
+			log.Printf("escrow %d: failed to record error: %v", p.EscrowID, dbErr)
+		}
+		return nil, err
+	}
+
+	e, err := scanEscrow(db.QueryRow(ctx, `
+		UPDATE project_escrows
+		SET status = $2,
+		    transfer_id = CASE WHEN $2 = 'released' THEN $3 ELSE transfer_id END,
+		    refund_id = CASE WHEN $2 = 'refunded' THEN $3 ELSE refund_id END,
+		    last_error = NULL, settled_at = now(), updated_at = now()
+		WHERE id = $1 AND status = $4
+		RETURNING `+escrowColumns+`;
+	`, p.EscrowID, final, obj.ID, status))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return gin.H{"skipped": true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data := gin.H{"escrow_id": e.ID, "project_id": e.ProjectID}
+	recipient, title := e.ProducerID, "Your fee was released"
+	if final == "refunded" {
+		recipient, title = e.ArtistID, "Your escrow payment was refunded"
+	}
+	body := fmt.Sprintf("%.2f %s for \"%s\".", e.Amount, strings.ToUpper(e.Currency), e.Milestone)
+	if err := notify(ctx, recipient, "escrow_"+final, title, body, data); err != nil {
+		log.Printf("escrow %d: failed to notify: %v", e.ID, err)
+	}
+	return gin.H{final: true, "stripe_id": obj.ID}, nil
+}
+
+// RegisterEscrowRoutes defines project escrows, Connect onboarding, and the
+// admin dispute resolution endpoints.
+func RegisterEscrowRoutes(r *gin.Engine) {
+	stripeReady := func(c *gin.Context) {
+		if cfg.StripeSecretKey == "" {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "payments are not configured"})
+			return
+		}
+		c.Next()
+	}
+
+	// POST /me/payments/connect — returns a Stripe onboarding "url" for receiving escrow payments
+	r.POST("/me/payments/connect", RequireAuth(), RequireMFA(), stripeReady, func(c *gin.Context) {
+		if cfg.StripeConnectReturnURL == "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "payments are not configured"})
+			return
+		}
+		ctx := c.Request.Context()
+		userID := currentUserID(c)
+
+		var accountID *string
+		err := db.QueryRow(ctx, `SELECT stripe_account_id FROM profiles WHERE id = $1;`, userID).Scan(&accountID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "profile not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if accountID == nil {
+			var acct struct {
+				ID string `json:"id"`
+			}
+			if err := stripePost(ctx, "/accounts", url.Values{
+				"type":                               {"express"},
+				"capabilities[transfers][requested]": {"true"},
+				"metadata[user_id]":                  {userID},
+			}, "connect-account-"+userID, &acct); err != nil {
+				c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+				return
+			}
+			if _, err := db.Exec(ctx,
+				`UPDATE profiles SET stripe_account_id = $2 WHERE id = $1 AND stripe_account_id IS NULL;`,
+				userID, acct.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			accountID = &acct.ID
+		}
+
+		var link struct {
+			URL string `json:"url"`
+		}
+		if err := stripePost(ctx, "/account_links", url.Values{
+			"account":     {*accountID},
+			"type":        {"account_onboarding"},
+			"refresh_url": {cfg.StripeConnectReturnURL},
+			"return_url":  {cfg.StripeConnectReturnURL},
+		}, "", &link); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"url": link.URL, "account_id": *accountID})
+	})
+
+	p := r.Group("/projects/:id/escrows", RequireProjectAccess(), stripeReady)
+
+	// escrowParam loads :escrowId within the project, writing the error when missing.
+	escrowParam := func(c *gin.Context) (ProjectEscrow, bool) {
+		id, err := strconv.ParseInt(c.Param("escrowId"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid escrow id"})
+			return ProjectEscrow{}, false
+		}
+		e, err := scanEscrow(db.QueryRow(context.Background(),
+			`SELECT `+escrowColumns+` FROM project_escrows WHERE id = $1 AND project_id = $2;`,
+			id, c.GetInt64("project_id")))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "escrow not found"})
+			return ProjectEscrow{}, false
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return ProjectEscrow{}, false
+		}
+		return e, true
+	}
+
+	// GET /projects/:id/escrows
+	p.GET("", func(c *gin.Context) {
+		if rejectGuest(c) {
+			return
+		}
+		rows, err := db.Query(context.Background(),
+			`SELECT `+escrowColumns+` FROM project_escrows WHERE project_id = $1 ORDER BY created_at DESC;`,
+			c.GetInt64("project_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		escrows := []ProjectEscrow{}
+		for rows.Next() {
+			e, err := scanEscrow(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			escrows = append(escrows, e)
+		}
+		c.JSON(http.StatusOK, escrows)
+	})
+
+	// POST /projects/:id/escrows — owner only; {"producer_id":"...","milestone":"Final mix","amount":250}
+	// Returns the escrow and the PaymentIntent "client_secret" to confirm in the app.
+	p.POST("", func(c *gin.Context) {
+		projectID := c.GetInt64("project_id")
+		if !requireProjectOwner(c, projectID) {
+			return
+		}
+		var body struct {
+			ProducerID string  `json:"producer_id"`
+			Milestone  string  `json:"milestone"`
+			Amount     float64 `json:"amount"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		body.Milestone = strings.TrimSpace(body.Milestone)
+		if body.ProducerID == "" || body.Milestone == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "producer_id and milestone are required"})
+			return
+		}
+		if body.Amount < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be at least 1.00"})
+			return
+		}
+		ctx := c.Request.Context()
+
+		isOwner, isMember, _, err := projectAccess(ctx, projectID, body.ProducerID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if isOwner || !isMember {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "producer_id must be a collaborator on this project"})
+			return
+		}
+
+		e, err := scanEscrow(db.QueryRow(ctx, `
+			INSERT INTO project_escrows (project_id, artist_id, producer_id, milestone, amount)
+			VALUES ($1, $2, $3, $4, round($5::numeric, 2))
+			RETURNING `+escrowColumns+`;
+		`, projectID, currentUserID(c), body.ProducerID, body.Milestone, body.Amount))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var pi struct {
+			ID           string `json:"id"`
+			ClientSecret string `json:"client_secret"`
+		}
+		err = stripePost(ctx, "/payment_intents", url.Values{
+			"amount":                             {strconv.FormatInt(toCents(e.Amount), 10)},
+			"currency":                           {e.Currency},
+			"automatic_payment_methods[enabled]": {"true"},
+			"transfer_group":                     {fmt.Sprintf("escrow_%d", e.ID)},
+			"metadata[escrow_id]":                {strconv.FormatInt(e.ID, 10)},
+			"metadata[project_id]":               {strconv.FormatInt(projectID, 10)},
+		}, fmt.Sprintf("escrow-%d-payment", e.ID), &pi)
+		if err == nil {
+			_, err = db.Exec(ctx, `UPDATE project_escrows SET payment_intent_id = $2 WHERE id = $1;`, e.ID, pi.ID)
+		}
+		if err != nil {
+			if _, delErr := db.Exec(ctx, `DELETE FROM project_escrows WHERE id = $1;`, e.ID); delErr != nil {
+				log.Printf("escrow %d: failed to remove after error: %v", e.ID, delErr)
+			}
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"escrow": e, "client_secret": pi.ClientSecret})
+	})
+
+	// POST /projects/:id/escrows/:escrowId/complete — owner marks the milestone done; releases to the producer
+	p.POST("/:escrowId/complete", func(c *gin.Context) {
+		projectID := c.GetInt64("project_id")
+		if !requireProjectOwner(c, projectID) {
+			return
+		}
+		e, ok := escrowParam(c)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		var accountID *string
+		if err := db.QueryRow(ctx,
+			`SELECT stripe_account_id FROM profiles WHERE id = $1;`, e.ProducerID).Scan(&accountID); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if accountID == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "the producer needs to connect a Stripe account before funds can be released"})
+			return
+		}
+
+		e, err := scanEscrow(db.QueryRow(ctx, `
+			UPDATE project_escrows SET status = 'releasing', updated_at = now()
+			WHERE id = $1 AND status = 'funded'
+			RETURNING `+escrowColumns+`;
+		`, e.ID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "only a funded escrow can be released"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := settleEscrow(ctx, e, currentUserID(c)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, e)
+	})
+
+	// POST /projects/:id/escrows/:escrowId/dispute — artist or producer; {"reason":"..."} holds the funds for an admin
+	p.POST("/:escrowId/dispute", func(c *gin.Context) {
+		if rejectGuest(c) {
+			return
+		}
+		e, ok := escrowParam(c)
+		if !ok {
+			return
+		}
+		userID := currentUserID(c)
+		if userID != e.ArtistID && userID != e.ProducerID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only the artist or producer can dispute this escrow"})
+			return
+		}
+		var body struct {
+			Reason string `json:"reason"`
+		}
+		if err := c.BindJSON(&body); err != nil || strings.TrimSpace(body.Reason) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+			return
+		}
+		ctx := c.Request.Context()
+
+		e, err := scanEscrow(db.QueryRow(ctx, `
+			UPDATE project_escrows
+			SET status = 'disputed', dispute_reason = $2, disputed_by = $3, updated_at = now()
+			WHERE id = $1 AND status = 'funded'
+			RETURNING `+escrowColumns+`;
+		`, e.ID, strings.TrimSpace(body.Reason), userID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "only a funded escrow can be disputed"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		other := e.ProducerID
+		if userID == e.ProducerID {
+			other = e.ArtistID
+		}
+		msg := fmt.Sprintf("The escrow for \"%s\" is on hold until an admin resolves the dispute.", e.Milestone)
+		if err := notify(ctx, other, "escrow_disputed", "An escrow payment was disputed", msg,
+			gin.H{"escrow_id": e.ID, "project_id": e.ProjectID}); err != nil {
+			log.Printf("escrow %d: failed to notify: %v", e.ID, err)
+		}
+		c.JSON(http.StatusOK, e)
+	})
+
+	admin := r.Group("/admin/escrows", RequireAuth(), RequireRole("admin"), RequireMFA(), stripeReady)
+
+	// GET /admin/escrows?status=disputed — oldest first
+	admin.GET("", func(c *gin.Context) {
+		rows, err := db.Query(context.Background(), `
+			SELECT `+escrowColumns+` FROM project_escrows
+			WHERE status = $1
+			ORDER BY updated_at
+			LIMIT $2;
+		`, c.DefaultQuery("status", "disputed"), maxEscrowPage)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		escrows := []ProjectEscrow{}
+		for rows.Next() {
+			e, err := scanEscrow(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			escrows = append(escrows, e)
+		}
+		c.JSON(http.StatusOK, escrows)
+	})
+
+	// POST /admin/escrows/:id/resolve — {"outcome":"release"|"refund","note":"..."}
+	admin.POST("/:id/resolve", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid escrow id"})
+			return
+		}
+		var body struct {
+			Outcome string `json:"outcome"`
+			Note    string `json:"note"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		status := map[string]string{"release": "releasing", "refund": "refunding"}[body.Outcome]
+		if status == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "outcome must be release or refund"})
+			return
+		}
+		ctx := c.Request.Context()
+
+		e, err := scanEscrow(db.QueryRow(ctx, `
+			UPDATE project_escrows
+			SET status = $2, resolution_note = NULLIF($3, ''), resolved_by = $4, updated_at = now()
+			WHERE id = $1 AND status = 'disputed'
+			RETURNING `+escrowColumns+`;
+		`, id, status, strings.TrimSpace(body.Note), currentUserID(c)))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "escrow not found or not disputed"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := settleEscrow(ctx, e, currentUserID(c)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, e)
+	})
+
+	// POST /admin/escrows/:id/retry — re-queues a transfer or refund that failed
+	admin.POST("/:id/retry", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid escrow id"})
+			return
+		}
+		e, err := scanEscrow(db.QueryRow(context.Background(),
+			`SELECT `+escrowColumns+` FROM project_escrows WHERE id = $1;`, id))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "escrow not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if e.Status != "releasing" && e.Status != "refunding" {
+			c.JSON(http.StatusConflict, gin.H{"error": "only a releasing or refunding escrow can be retried"})
+			return
+		}
+		if err := settleEscrow(context.Background(), e, currentUserID(c)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, e)
+	})
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Event payloads are versioned by schema_version. Old app builds stay on
+// older versions for years, so every version we ever shipped keeps a decoder
+// that validates it and translates it to the current IngestEvent shape.
+const (
+	currentEventSchemaVersion = 2
+	maxEventBodyBytes         = 64 << 10
+	maxEventBatch             = 100
+	maxEventProperties        = 20
+	maxEventClockSkew         = 5 * time.Minute
+	maxEventAge               = 7 * 24 * time.Hour
+)
+
+// IngestEvent is a validated client event in the current schema.
+type IngestEvent struct {
+	SchemaVersion int                    `json:"schema_version"`
+	EventType     string                 `json:"event_type"`
+	SongID        int64                  `json:"song_id"`
+	UserID        *string                `json:"user_id"`
+	OccurredAt    time.Time              `json:"occurred_at"`
+	Properties    map[string]interface{} `json:"properties"`
+	IsBot         bool                   `json:"is_bot"`
+}
+
+// clientEventTypes are the event types clients may send. Engagement like
+// comments, reviews, and tips is recorded server-side by those handlers.
+var clientEventTypes = map[string]bool{
+	"play":          true,
+	"pause":         true,
+	"like":          true,
+	"share":         true,
+	"view":          true,
+	"play_progress": true,
+	"skip":          true,
+	"seek":          true,
+}
+
+// maxPlaybackSeconds bounds position properties (4 hours covers long mixes).
+const maxPlaybackSeconds = 4 * 60 * 60
+
+// playProgressCheckpoints are the percentages a play_progress event may report.
+var playProgressCheckpoints = map[float64]bool{25: true, 50: true, 75: true, 100: true}
+
+// eventDecoders maps schema_version to a strict decoder for that version.
+var eventDecoders = map[int]func(raw json.RawMessage) (IngestEvent, error){
+	1: decodeEventV1,
+	2: decodeEventV2,
+}
+
+// eventV1 is the original mobile payload. It had no timestamp and no
+// properties, and sent user_id in the body (which we no longer trust).
+type eventV1 struct {
+	SchemaVersion int     `json:"schema_version"`
+	SongID        int64   `json:"song_id"`
+	UserID        *string `json:"user_id"`
+	EventType     string  `json:"event_type"`
+}
+
+type eventV2 struct {
+	SchemaVersion int                    `json:"schema_version"`
+	SongID        int64                  `json:"song_id"`
+	EventType     string                 `json:"event_type"`
+	OccurredAt    *time.Time             `json:"occurred_at"`
+	Properties    map[string]interface{} `json:"properties"`
+}
+
+func decodeEventV1(raw json.RawMessage) (IngestEvent, error) {
+	var v eventV1
+	if err := decodeStrict(raw, &v); err != nil {
+		return IngestEvent{}, err
+	}
+
+	e := IngestEvent{
+		SchemaVersion: currentEventSchemaVersion,
+		EventType:     v.EventType,
+		SongID:        v.SongID,
+		OccurredAt:    time.Now().UTC(),
+		Properties:    map[string]interface{}{"translated_from": 1},
+	}
+	return e, validateEvent(e)
+}
+
+func decodeEventV2(raw json.RawMessage) (IngestEvent, error) {
+	var v eventV2
+	if err := decodeStrict(raw, &v); err != nil {
+		return IngestEvent{}, err
+	}
+	if v.OccurredAt == nil {
+		return IngestEvent{}, fmt.Errorf("occurred_at is required")
+	}
+
+	now := time.Now()
+	if v.OccurredAt.After(now.Add(maxEventClockSkew)) {
+		return IngestEvent{}, fmt.Errorf("occurred_at is in the future")
+	}
+	if v.OccurredAt.Before(now.Add(-maxEventAge)) {
+		return IngestEvent{}, fmt.Errorf("occurred_at is older than 7 days")
+	}
+	if len(v.Properties) > maxEventProperties {
+		return IngestEvent{}, fmt.Errorf("properties may have at most %d keys", maxEventProperties)
+	}
+
+	e := IngestEvent{
+		SchemaVersion: currentEventSchemaVersion,
+		EventType:     v.EventType,
+		SongID:        v.SongID,
+		OccurredAt:    v.OccurredAt.UTC(),
+		Properties:    v.Properties,
+	}
+	return e, validateEvent(e)
+}
+
+// validateEvent checks the rules shared by every schema version.
+func validateEvent(e IngestEvent) error {
+	if e.SongID <= 0 {
+		return fmt.Errorf("song_id is required")
+	}
+	if !clientEventTypes[e.EventType] {
+		return fmt.Errorf("unsupported event_type %q", e.EventType)
+	}
+
+	// Playback positions feed the drop-off heatmap, so they must be sane.
+	for _, key := range []string{"position_seconds", "from_seconds", "to_seconds"} {
+		if v, ok := e.Properties[key]; ok {
+			if s, isNum := v.(float64); !isNum || s < 0 || s > maxPlaybackSeconds {
+				return fmt.Errorf("properties.%s must be a number between 0 and %d", key, maxPlaybackSeconds)
+			}
+		}
+	}
+	if v, ok := e.Properties["session_id"]; ok {
+		if s, isStr := v.(string); !isStr || s == "" || len(s) > 64 {
+			return fmt.Errorf("properties.session_id must be a string of 1-64 characters")
+		}
+	}
+
+	switch e.EventType {
+	case "play_progress":
+		percent, ok := e.Properties["percent"].(float64)
+		if !ok || !playProgressCheckpoints[percent] {
+			return fmt.Errorf("play_progress requires properties.percent of 25, 50, 75, or 100")
+		}
+	case "seek":
+		_, hasFrom := e.Properties["from_seconds"]
+		_, hasTo := e.Properties["to_seconds"]
+		if !hasFrom || !hasTo {
+			return fmt.Errorf("seek requires properties.from_seconds and properties.to_seconds")
+		}
+	}
+	return nil
+}
+
+// decodeStrict unmarshals raw into v, rejecting unknown fields.
+func decodeStrict(raw json.RawMessage, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// decodeEvent picks the decoder for the payload's schema_version. Payloads
+// without one predate versioning and are treated as version 1.
+func decodeEvent(raw json.RawMessage) (IngestEvent, error) {
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return IngestEvent{}, fmt.Errorf("invalid JSON")
+	}
+	if header.SchemaVersion == 0 {
+		header.SchemaVersion = 1
+	}
+
+	decode, ok := eventDecoders[header.SchemaVersion]
+	if !ok {
+		return IngestEvent{}, fmt.Errorf("unsupported schema_version %d", header.SchemaVersion)
+	}
+	return decode(raw)
+}
+
+// recordEvent inserts one validated event into Postgres. Handlers publish
+// through eventSink instead so events also reach any streaming sink.
+func recordEvent(ctx context.Context, e IngestEvent) error {
+	if e.Properties == nil {
+		e.Properties = map[string]interface{}{}
+	}
+	props, err := json.Marshal(e.Properties)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(ctx, `
+		INSERT INTO events (song_id, user_id, event_type, schema_version, occurred_at, properties, is_bot)
+		VALUES ($1, $2, $3, $4, $5, $6, $7);
+	`, e.SongID, e.UserID, e.EventType, e.SchemaVersion, e.OccurredAt, props, e.IsBot)
+	return err
+}
+
+// recordServerEvent publishes an engagement event (comment, review, tip)
+// recorded by a server handler. Failures are logged, not returned, so they
+// never fail the request that caused them.
+func recordServerEvent(songID int64, userID, eventType string) {
+	e := IngestEvent{
+		SchemaVersion: currentEventSchemaVersion,
+		EventType:     eventType,
+		SongID:        songID,
+		UserID:        &userID,
+		OccurredAt:    time.Now().UTC(),
+	}
+	if err := eventSink.Publish(context.Background(), e); err != nil {
+		log.Printf("failed to record %s event for song %d: %v", eventType, songID, err)
+	}
+}
+
+// RegisterEventRoutes defines the client event ingestion endpoint.
+func RegisterEventRoutes(r *gin.Engine) {
+	// POST /events — a single event object or {"events": [...]}
+	r.POST("/events", OptionalAuth(), func(c *gin.Context) {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxEventBodyBytes))
+		if err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body too large"})
+			return
+		}
+
+		var batch struct {
+			Events []json.RawMessage `json:"events"`
+		}
+		raws := []json.RawMessage{body}
+		if err := json.Unmarshal(body, &batch); err == nil && batch.Events != nil {
+			raws = batch.Events
+		}
+		if len(raws) == 0 || len(raws) > maxEventBatch {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("send between 1 and %d events", maxEventBatch)})
+			return
+		}
+
+		var userID *string
+		if uid := currentUserID(c); uid != "" {
+			userID = &uid
+		}
+
+		events := make([]IngestEvent, 0, len(raws))
+		for i, raw := range raws {
+			e, err := decodeEvent(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "index": i})
+				return
+			}
+			e.UserID = userID
+			events = append(events, e)
+		}
+
+		// Dropped bot traffic still gets a 202 so crawlers can't probe the rules.
+		verdict := classifyBot(context.Background(), c, len(events))
+		if verdict.Action == botActionDrop {
+			c.JSON(http.StatusAccepted, gin.H{"accepted": len(events), "schema_version": currentEventSchemaVersion})
+			return
+		}
+		verdict.apply(events)
+
+		for _, e := range events {
+			if err := eventSink.Publish(context.Background(), e); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"accepted": len(events), "schema_version": currentEventSchemaVersion})
+	})
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Experiments assign users to variants deterministically: the same user
+// always lands in the same variant for a given experiment key, without
+// storing assignments. Only exposures (the client actually showing a
+// variant) are stored, and metric readouts are based on exposed users.
+
+var experimentKeyRe = regexp.MustCompile(`^[a-z0-9_]{1,64}$`)
+
+// experimentMetrics are the event types reported per variant.
+var experimentMetrics = []string{"play", "like", "share", "comment", "review", "tip"}
+
+type ExperimentVariant struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+type experimentInput struct {
+	Key            string              `json:"key"`
+	Description    string              `json:"description"`
+	TrafficPercent *int                `json:"traffic_percent"`
+	Variants       []ExperimentVariant `json:"variants"`
+	Status         string              `json:"status"`
+}
+
+// assignVariant returns the user's variant, or "" if the user falls outside
+// the experiment's traffic allocation.
+func assignVariant(e Experiment, userID string) string {
+	if e.Status != "running" || userID == "" {
+		return ""
+	}
+
+	// Separate hashes for enrollment and variant choice, so raising the
+	// traffic percentage never moves already-enrolled users between variants.
+	if experimentBucket(e.Key+":traffic:"+userID, 100) >= uint64(e.TrafficPercent) {
+		return ""
+	}
+
+	total := 0
+	for _, v := range e.Variants {
+		total += v.Weight
+	}
+	if total == 0 {
+		return ""
+	}
+
+	pick := int(experimentBucket(e.Key+":variant:"+userID, uint64(total)))
+	for _, v := range e.Variants {
+		if pick < v.Weight {
+			return v.Name
+		}
+		pick -= v.Weight
+	}
+	return ""
+}
+
+func experimentBucket(s string, n uint64) uint64 {
+	sum := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint64(sum[:8]) % n
+}
+
+func scanExperiment(row pgx.Row) (Experiment, error) {
+	var e Experiment
+	var variants []byte
+	err := row.Scan(&e.ID, &e.Key, &e.Description, &e.Status, &e.TrafficPercent, &variants, &e.CreatedAt)
+	if err != nil {
+		return e, err
+	}
+	err = json.Unmarshal(variants, &e.Variants)
+	return e, err
+}
+
+const experimentColumns = `id, key, description, status, traffic_percent, variants, created_at`
+
+func loadExperiments(ctx context.Context, runningOnly bool) ([]Experiment, error) {
+	rows, err := db.Query(ctx, `
+		SELECT `+experimentColumns+`
+		FROM experiments
+		WHERE status = 'running' OR NOT $1
+		ORDER BY created_at DESC;
+	`, runningOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	experiments := []Experiment{}
+	for rows.Next() {
+		e, err := scanExperiment(rows)
+		if err != nil {
+			return nil, err
+		}
+		experiments = append(experiments, e)
+	}
+	return experiments, rows.Err()
+}
+
+func loadExperiment(ctx context.Context, key string) (Experiment, bool, error) {
+	e, err := scanExperiment(db.QueryRow(ctx,
+		`SELECT `+experimentColumns+` FROM experiments WHERE key = $1;`, key))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return e, false, nil
+	}
+	return e, err == nil, err
+}
+
+// experimentAssignments maps running experiment keys to the user's variant.
+func experimentAssignments(ctx context.Context, userID string) (map[string]string, error) {
+	experiments, err := loadExperiments(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := map[string]string{}
+	for _, e := range experiments {
+		if v := assignVariant(e, userID); v != "" {
+			assignments[e.Key] = v
+		}
+	}
+	return assignments, nil
+}
+
+func validateExperimentInput(body experimentInput, creating bool) string {
+	if creating && !experimentKeyRe.MatchString(body.Key) {
+		return "key must be 1-64 lowercase letters, digits, or underscores"
+	}
+	if body.TrafficPercent != nil && (*body.TrafficPercent < 0 || *body.TrafficPercent > 100) {
+		return "traffic_percent must be 0-100"
+	}
+	if body.Status != "" && body.Status != "draft" && body.Status != "running" && body.Status != "stopped" {
+		return "status must be draft, running, or stopped"
+	}
+	if creating || body.Variants != nil {
+		if len(body.Variants) < 2 {
+			return "an experiment needs at least two variants"
+		}
+		seen := map[string]bool{}
+		for _, v := range body.Variants {
+			if v.Name == "" || v.Weight < 1 || seen[v.Name] {
+				return "variants need unique names and positive weights"
+			}
+			seen[v.Name] = true
+		}
+	}
+	return ""
+}
+
+// RegisterExperimentRoutes defines exposure logging and the admin API.
+func RegisterExperimentRoutes(r *gin.Engine) {
+	// POST /experiments/:key/exposures — the client showed the user their variant
+	r.POST("/experiments/:key/exposures", RequireAuth(), func(c *gin.Context) {
+		e, found, err := loadExperiment(context.Background(), c.Param("key"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "experiment not found"})
+			return
+		}
+
+		variant := assignVariant(e, currentUserID(c))
+		if variant == "" {
+			c.JSON(http.StatusOK, gin.H{"enrolled": false})
+			return
+		}
+
+		_, err = db.Exec(context.Background(), `
+			INSERT INTO experiment_exposures (experiment_id, user_id, variant)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (experiment_id, user_id) DO NOTHING;
+		`, e.ID, currentUserID(c), variant)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"enrolled": true, "variant": variant})
+	})
+
+	admin := r.Group("/admin", RequireAuth(), RequireRole("admin"), RequireMFA())
+
+	// GET /admin/experiments
+	admin.GET("/experiments", func(c *gin.Context) {
+		experiments, err := loadExperiments(context.Background(), false)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, experiments)
+	})
+
+	// POST /admin/experiments
+	admin.POST("/experiments", func(c *gin.Context) {
+		var body experimentInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if msg := validateExperimentInput(body, true); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		if body.Status == "" {
+			body.Status = "draft"
+		}
+		traffic := 100
+		if body.TrafficPercent != nil {
+			traffic = *body.TrafficPercent
+		}
+		variants, _ := json.Marshal(body.Variants)
+
+		e, err := scanExperiment(db.QueryRow(context.Background(), `
+			INSERT INTO experiments (key, description, status, traffic_percent, variants)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+experimentColumns+`;
+		`, body.Key, body.Description, body.Status, traffic, variants))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, e)
+	})
+
+	// PATCH /admin/experiments/:key — status, traffic, description, or variants
+	admin.PATCH("/experiments/:key", func(c *gin.Context) {
+		var body experimentInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if msg := validateExperimentInput(body, false); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		var variants []byte
+		if body.Variants != nil {
+			variants, _ = json.Marshal(body.Variants)
+		}
+		var status, description *string
+		if body.Status != "" {
+			status = &body.Status
+		}
+		if body.Description != "" {
+			description = &body.Description
+		}
+
+		e, err := scanExperiment(db.QueryRow(context.Background(), `
+			UPDATE experiments SET
+				status          = COALESCE($2, status),
+				traffic_percent = COALESCE($3, traffic_percent),
+				description     = COALESCE($4, description),
+				variants        = COALESCE($5, variants)
+			WHERE key = $1
+			RETURNING `+experimentColumns+`;
+		`, c.Param("key"), status, body.TrafficPercent, description, variants))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "experiment not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, e)
+	})
+
+	// GET /admin/analytics/experiments/:key — per-variant exposure and metrics.
+	// Only events after a user's first exposure count toward their variant.
+	admin.GET("/analytics/experiments/:key", func(c *gin.Context) {
+		e, found, err := loadExperiment(context.Background(), c.Param("key"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "experiment not found"})
+			return
+		}
+
+		rows, err := db.Query(context.Background(), `
+			SELECT x.variant,
+			       COUNT(DISTINCT x.user_id) AS exposed,
+			       ev.event_type,
+			       COUNT(ev.id) AS events,
+			       COUNT(DISTINCT ev.user_id) AS converted,
+			       GROUPING(ev.event_type) = 1 AS is_total
+			FROM experiment_exposures x
+			LEFT JOIN events ev
+			       ON ev.user_id = x.user_id
+			      AND ev.occurred_at >= x.first_exposed_at
+			      AND NOT ev.is_bot
+			      AND ev.event_type = ANY($2)
+			WHERE x.experiment_id = $1
+			GROUP BY GROUPING SETS ((x.variant), (x.variant, ev.event_type))
+			ORDER BY x.variant;
+		`, e.ID, experimentMetrics)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		type MetricReadout struct {
+			Events         int64   `json:"events"`
+			EventsPerUser  float64 `json:"events_per_user"`
+			ConvertedUsers int64   `json:"converted_users"`
+			ConversionRate float64 `json:"conversion_rate"`
+		}
+		type VariantReadout struct {
+			Variant string                   `json:"variant"`
+			Exposed int64                    `json:"exposed_users"`
+			Metrics map[string]MetricReadout `json:"metrics"`
+		}
+
+		byVariant := map[string]*VariantReadout{}
+		order := []string{}
+		for _, v := range e.Variants {
+			byVariant[v.Name] = &VariantReadout{Variant: v.Name, Metrics: map[string]MetricReadout{}}
+			order = append(order, v.Name)
+		}
+
+		type metricRow struct {
+			variant   string
+			eventType string
+			events    int64
+			converted int64
+		}
+		var metricRows []metricRow
+		for rows.Next() {
+			var (
+				variant   string
+				exposed   int64
+				eventType *string
+				events    int64
+				converted int64
+				isTotal   bool
+			)
+			if err := rows.Scan(&variant, &exposed, &eventType, &events, &converted, &isTotal); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			v, ok := byVariant[variant]
+			if !ok {
+				// Variant was removed after users were exposed to it.
+				v = &VariantReadout{Variant: variant, Metrics: map[string]MetricReadout{}}
+				byVariant[variant] = v
+				order = append(order, variant)
+			}
+			if isTotal {
+				v.Exposed = exposed
+				continue
+			}
+			if eventType == nil {
+				continue // exposed users with no matching events
+			}
+			metricRows = append(metricRows, metricRow{variant, *eventType, events, converted})
+		}
+
+		for _, m := range metricRows {
+			v := byVariant[m.variant]
+			v.Metrics[m.eventType] = MetricReadout{
+				Events:         m.events,
+				EventsPerUser:  ratio(m.events, v.Exposed),
+				ConvertedUsers: m.converted,
+				ConversionRate: ratio(m.converted, v.Exposed),
+			}
+		}
+
+		readouts := make([]VariantReadout, 0, len(order))
+		for _, name := range order {
+			readouts = append(readouts, *byVariant[name])
+		}
+		c.JSON(http.StatusOK, gin.H{"experiment": e, "variants": readouts})
+	})
+}
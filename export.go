@@ -0,0 +1,290 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	projectExportJob       = "project_export"
+	projectExportURLExpiry = 24 * time.Hour
+)
+
+type projectExportPayload struct {
+	ProjectID int64 `json:"project_id"`
+}
+
+type projectExportResult struct {
+	ArchiveKey string `json:"archive_key"`
+	SizeBytes  int64  `json:"size_bytes"`
+	Stems      int    `json:"stems"`
+}
+
+func init() {
+	RegisterJobHandler(projectExportJob, runProjectExport)
+}
+
+// RegisterExportRoutes defines the project export endpoints.
+func RegisterExportRoutes(r *gin.Engine) {
+	// POST /projects/:id/export — queues a bundle of stems, chat, tasks, and metadata
+	r.POST("/projects/:id/export", RequireProjectAccess(), func(c *gin.Context) {
+		if storage == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is not configured"})
+			return
+		}
+
+		projectID := c.GetInt64("project_id")
+		jobID, err := EnqueueJob(context.Background(), projectExportJob,
+			projectExportPayload{ProjectID: projectID}, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"job_id":     jobID,
+			"status":     "queued",
+			"status_url": fmt.Sprintf("/projects/%d/export/%d", projectID, jobID),
+		})
+	})
+
+	// GET /projects/:id/export/:jobId — status, plus a fresh signed URL once done
+	r.GET("/projects/:id/export/:jobId", RequireProjectAccess(), func(c *gin.Context) {
+		if rejectGuest(c) {
+			return
+		}
+
+		jobID, err := strconv.ParseInt(c.Param("jobId"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+			return
+		}
+
+		job, found, err := GetJob(context.Background(), jobID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var payload projectExportPayload
+		if found {
+			json.Unmarshal(job.Payload, &payload)
+		}
+		if !found || job.Type != projectExportJob || payload.ProjectID != c.GetInt64("project_id") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "export not found"})
+			return
+		}
+
+		resp := gin.H{"job": job}
+		if job.Status == "done" && storage != nil {
+			var result projectExportResult
+			if err := json.Unmarshal(job.Result, &result); err == nil && result.ArchiveKey != "" {
+				resp["download_url"] = storage.PresignGet(result.ArchiveKey, projectExportURLExpiry)
+				resp["expires_at"] = time.Now().Add(projectExportURLExpiry)
+			}
+		}
+
+		c.JSON(http.StatusOK, resp)
+	})
+}
+
+// runProjectExport builds the zip bundle in a temp file and uploads it to Spaces.
+func runProjectExport(ctx context.Context, job *Job) (interface{}, error) {
+	if storage == nil {
+		return nil, fmt.Errorf("storage is not configured")
+	}
+
+	var payload projectExportPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return nil, err
+	}
+
+	metadata, err := loadProjectMetadata(ctx, payload.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := loadProjectMessages(ctx, payload.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := loadProjectTasks(ctx, payload.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	stems, err := loadProjectStems(ctx, payload.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "project-export-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	zw := zip.NewWriter(tmp)
+	metadata.Stems = stems
+	for name, v := range map[string]interface{}{
+		"metadata.json": metadata,
+		"chat.json":     messages,
+		"tasks.json":    tasks,
+	} {
+		if err := writeZipJSON(zw, name, v); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, s := range stems {
+		if err := copyStemToZip(ctx, zw, s); err != nil {
+			return nil, fmt.Errorf("stem %d: %w", s.ID, err)
+		}
+		// Stems dominate the work, so they drive progress (leaving room for the upload).
+		SetJobProgress(ctx, job.ID, (i+1)*90/len(stems))
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	size, err := tmp.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("exports/projects/%d/%d.zip", payload.ProjectID, job.ID)
+	if err := storage.PutObject(ctx, key, tmp, size, "application/zip"); err != nil {
+		return nil, err
+	}
+
+	return projectExportResult{ArchiveKey: key, SizeBytes: size, Stems: len(stems)}, nil
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v interface{}) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func copyStemToZip(ctx context.Context, zw *zip.Writer, s ProjectStem) error {
+	body, err := storageFor(s.StorageRegion).GetObject(ctx, s.FileKey)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	// Audio is already compressed; storing avoids burning CPU on deflate.
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:   fmt.Sprintf("stems/%d-%s", s.ID, path.Base(s.Filename)),
+		Method: zip.Store,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, body)
+	return err
+}
+
+// projectExportMetadata is metadata.json in an export bundle; tools read
+// it back, so its fields are kept stable.
+type projectExportMetadata struct {
+	Project     Project             `json:"project"`
+	Invitations []ProjectInvitation `json:"invitations"`
+	Stems       []ProjectStem       `json:"stems"`
+	ExportedAt  time.Time           `json:"exported_at"`
+}
+
+func loadProjectMetadata(ctx context.Context, projectID int64) (projectExportMetadata, error) {
+	p, err := loadProject(ctx, projectID)
+	if err != nil {
+		return projectExportMetadata{}, err
+	}
+	invitations, err := loadProjectInvitations(ctx, projectID)
+	if err != nil {
+		return projectExportMetadata{}, err
+	}
+
+	return projectExportMetadata{Project: p, Invitations: invitations, ExportedAt: time.Now().UTC()}, nil
+}
+
+func loadProjectMessages(ctx context.Context, projectID int64) ([]ProjectMessage, error) {
+	rows, err := db.Query(ctx, `
+		SELECT `+projectMessageColumns+`
+		FROM project_messages WHERE project_id = $1 ORDER BY created_at;
+	`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []ProjectMessage{}
+	for rows.Next() {
+		m, err := scanProjectMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+func loadProjectTasks(ctx context.Context, projectID int64) ([]ProjectTask, error) {
+	rows, err := db.Query(ctx, `
+		SELECT id, project_id, title, assignee_id, created_by, completed_at, created_at
+		FROM project_tasks WHERE project_id = $1 ORDER BY created_at;
+	`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []ProjectTask{}
+	for rows.Next() {
+		var t ProjectTask
+		if err := rows.Scan(&t.ID, &t.ProjectID, &t.Title, &t.AssigneeID, &t.CreatedBy, &t.CompletedAt, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
+
+func loadProjectStems(ctx context.Context, projectID int64) ([]ProjectStem, error) {
+	rows, err := db.Query(ctx, `
+		SELECT id, project_id, uploader_id, filename, file_key, storage_region, size_bytes, content_type,
+		       duration_ms, bit_rate, sample_rate, channels, codec, probed_at, created_at
+		FROM project_stems WHERE project_id = $1 ORDER BY created_at;
+	`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stems := []ProjectStem{}
+	for rows.Next() {
+		var s ProjectStem
+		if err := rows.Scan(&s.ID, &s.ProjectID, &s.UploaderID, &s.Filename, &s.FileKey, &s.StorageRegion, &s.SizeBytes, &s.ContentType,
+			&s.DurationMs, &s.BitRate, &s.SampleRate, &s.Channels, &s.Codec, &s.ProbedAt, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		stems = append(stems, s)
+	}
+	return stems, rows.Err()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterFollowRoutes defines follow/unfollow for the caller.
+func RegisterFollowRoutes(r *gin.Engine) {
+	// POST /users/:id/follow
+	r.POST("/users/:id/follow", RequireAuth(), func(c *gin.Context) {
+		followeeID := c.Param("id")
+		if followeeID == currentUserID(c) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot follow yourself"})
+			return
+		}
+
+		var f Follow
+		err := db.QueryRow(context.Background(), `
+			INSERT INTO follows (follower_id, followee_id)
+			VALUES ($1, $2)
+			ON CONFLICT (follower_id, followee_id) DO UPDATE SET follower_id = EXCLUDED.follower_id
+			RETURNING id, follower_id, followee_id, created_at;
+		`, currentUserID(c), followeeID).Scan(&f.ID, &f.FollowerID, &f.FolloweeID, &f.CreatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, f)
+	})
+
+	// DELETE /users/:id/follow
+	r.DELETE("/users/:id/follow", RequireAuth(), func(c *gin.Context) {
+		_, err := db.Exec(context.Background(),
+			`DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2;`,
+			currentUserID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+}
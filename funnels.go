@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// funnelSteps is the activation funnel, in order. A subject counts toward a
+// step at most once (the first time it happens).
+var funnelSteps = []string{"visit", "signup", "profile_complete", "first_upload", "first_publish"}
+
+type funnelEventInput struct {
+	Step        string `json:"step"`
+	AnonymousID string `json:"anonymous_id"`
+}
+
+// recordFunnelStep stores the first occurrence of step for a user. The
+// anonymous ID (from the pre-signup visit) links visits to signups.
+func recordFunnelStep(ctx context.Context, userID, anonymousID, step string) error {
+	var uid, anon *string
+	if userID != "" {
+		uid = &userID
+	}
+	if anonymousID != "" {
+		anon = &anonymousID
+	}
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO funnel_events (user_id, anonymous_id, step)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING;
+	`, uid, anon, step)
+	return err
+}
+
+type FunnelStep struct {
+	Step           string  `json:"step"`
+	Count          int64   `json:"count"`
+	FromPrevious   float64 `json:"conversion_from_previous"`
+	FromFirst      float64 `json:"conversion_from_first"`
+	ChangeVsPeriod float64 `json:"change_vs_compare,omitempty"`
+}
+
+// computeFunnel counts the cohort that visited in [from, until) and how far
+// it got. Visits are keyed by anonymous_id; later steps by the user_id that
+// signed up from one of those visits.
+func computeFunnel(ctx context.Context, from, until time.Time) ([]FunnelStep, error) {
+	sql := `
+		WITH cohort AS (
+			SELECT DISTINCT anonymous_id
+			FROM funnel_events
+			WHERE step = 'visit' AND occurred_at >= $1 AND occurred_at < $2
+		),
+		users AS (
+			SELECT DISTINCT f.user_id
+			FROM funnel_events f
+			JOIN cohort c ON c.anonymous_id = f.anonymous_id
+			WHERE f.step = 'signup' AND f.user_id IS NOT NULL
+		)
+		SELECT
+			(SELECT COUNT(*) FROM cohort),
+			(SELECT COUNT(*) FROM users),
+			COUNT(DISTINCT f.user_id) FILTER (WHERE f.step = 'profile_complete'),
+			COUNT(DISTINCT f.user_id) FILTER (WHERE f.step = 'first_upload'),
+			COUNT(DISTINCT f.user_id) FILTER (WHERE f.step = 'first_publish')
+		FROM funnel_events f
+		JOIN users u ON u.user_id = f.user_id;
+	`
+
+	counts := make([]int64, len(funnelSteps))
+	if err := db.QueryRow(ctx, sql, from, until).Scan(&counts[0], &counts[1], &counts[2], &counts[3], &counts[4]); err != nil {
+		return nil, err
+	}
+
+	steps := make([]FunnelStep, len(funnelSteps))
+	for i, name := range funnelSteps {
+		steps[i] = FunnelStep{Step: name, Count: counts[i], FromFirst: ratio(counts[i], counts[0])}
+		if i == 0 {
+			steps[i].FromPrevious = 1
+		} else {
+			steps[i].FromPrevious = ratio(counts[i], counts[i-1])
+		}
+	}
+	return steps, nil
+}
+
+// RegisterFunnelRoutes defines funnel step tracking and the admin readout.
+func RegisterFunnelRoutes(r *gin.Engine) {
+	// POST /funnel/events — visit is anonymous; later steps need a signed-in user
+	r.POST("/funnel/events", OptionalAuth(), func(c *gin.Context) {
+		var body funnelEventInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+
+		known := false
+		for _, s := range funnelSteps {
+			known = known || s == body.Step
+		}
+		if !known {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown funnel step"})
+			return
+		}
+		if len(body.AnonymousID) > 64 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "anonymous_id is too long"})
+			return
+		}
+
+		userID := currentUserID(c)
+		if body.Step == "visit" && body.AnonymousID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "visit requires anonymous_id"})
+			return
+		}
+		if body.Step != "visit" && userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "sign in to record this step"})
+			return
+		}
+		if body.Step == "visit" {
+			userID = ""
+		}
+
+		if err := recordFunnelStep(context.Background(), userID, body.AnonymousID, body.Step); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	admin := r.Group("/admin/analytics", RequireAuth(), RequireRole("admin"), RequireMFA())
+
+	// GET /admin/analytics/funnels?from=&to=&compare_from=&compare_to=
+	admin.GET("/funnels", func(c *gin.Context) {
+		from, to, err := parseDateRange(c, 30, 366)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		steps, err := computeFunnel(context.Background(), from, to.AddDate(0, 0, 1))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		resp := gin.H{
+			"from":  from.Format(dateLayout),
+			"to":    to.Format(dateLayout),
+			"steps": steps,
+		}
+
+		// Optional comparison period, e.g. the previous month.
+		if c.Query("compare_from") != "" || c.Query("compare_to") != "" {
+			cmpFrom, err := time.Parse(dateLayout, c.Query("compare_from"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "compare_from must be YYYY-MM-DD"})
+				return
+			}
+			cmpTo, err := time.Parse(dateLayout, c.Query("compare_to"))
+			if err != nil || cmpTo.Before(cmpFrom) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "compare_to must be YYYY-MM-DD and not before compare_from"})
+				return
+			}
+
+			cmp, err := computeFunnel(context.Background(), cmpFrom, cmpTo.AddDate(0, 0, 1))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			// Relative change in each step's overall conversion.
+			for i := range steps {
+				if cmp[i].FromFirst > 0 {
+					steps[i].ChangeVsPeriod = (steps[i].FromFirst - cmp[i].FromFirst) / cmp[i].FromFirst
+				}
+			}
+			resp["compare"] = gin.H{
+				"from":  cmpFrom.Format(dateLayout),
+				"to":    cmpTo.Format(dateLayout),
+				"steps": cmp,
+			}
+		}
+
+		c.JSON(http.StatusOK, resp)
+	})
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	guestTokenPrefix      = "lpg_"
+	defaultGuestLinkHours = 72
+	maxGuestLinkHours     = 24 * 30
+)
+
+type createGuestLinkInput struct {
+	Label          string `json:"label"`
+	ExpiresInHours int    `json:"expires_in_hours"`
+}
+
+// guestTokenFrom reads a guest token from the X-Guest-Token header. It is
+// never taken from the query string, which access and proxy logs record.
+func guestTokenFrom(c *gin.Context) string {
+	return c.GetHeader("X-Guest-Token")
+}
+
+// RequireProjectAccess lets through project members (via bearer token) and
+// holders of an unexpired, unrevoked guest link for the project in :id.
+// Guests are read-only: any non-GET request is rejected.
+// It stores "project_id" and, for guests, "guest_link_id" in the context.
+func RequireProjectAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		projectID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
+			return
+		}
+		c.Set("project_id", projectID)
+		authorizeProject(c, projectID)
+	}
+}
+
+// authorizeProject is RequireProjectAccess for a project ID found some
+// other way, e.g. through a stem; it aborts the request when access is
+// denied.
+func authorizeProject(c *gin.Context, projectID int64) {
+	if token := guestTokenFrom(c); token != "" {
+		var linkID int64
+		err := db.QueryRow(context.Background(), `
+			UPDATE project_guest_links
+			SET last_used_at = now()
+			WHERE token_hash = $1
+			  AND project_id = $2
+			  AND revoked_at IS NULL
+			  AND expires_at > now()
+			RETURNING id;
+		`, hashToken(token), projectID).Scan(&linkID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "guest link is invalid or expired"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "guest access is read-only"})
+			return
+		}
+
+		c.Set("guest_link_id", linkID)
+		c.Next()
+		return
+	}
+
+	RequireAuth()(c)
+	if c.IsAborted() {
+		return
+	}
+
+	_, isMember, found, err := projectAccess(context.Background(), projectID, currentUserID(c))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !found {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+	if !isMember {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not a member of this project"})
+		return
+	}
+}
+
+// rejectGuest aborts with 403 when the request was authorized by a guest link.
+func rejectGuest(c *gin.Context) bool {
+	if _, isGuest := c.Get("guest_link_id"); isGuest {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not available to guests"})
+		return true
+	}
+	return false
+}
+
+// requireProjectOwner aborts unless the authenticated caller owns the project.
+func requireProjectOwner(c *gin.Context, projectID int64) bool {
+	isOwner, _, found, err := projectAccess(context.Background(), projectID, currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return false
+	}
+	if !isOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the project owner can do this"})
+		return false
+	}
+	return true
+}
+
+// RegisterGuestRoutes defines the owner endpoints for managing guest links.
+func RegisterGuestRoutes(r *gin.Engine) {
+	g := r.Group("/projects/:id/guest-links", RequireAuth())
+
+	// POST /projects/:id/guest-links — returns the token once; only its hash is kept
+	g.POST("", func(c *gin.Context) {
+		projectID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
+			return
+		}
+
+		var body createGuestLinkInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if body.ExpiresInHours == 0 {
+			body.ExpiresInHours = defaultGuestLinkHours
+		}
+		if body.ExpiresInHours < 1 || body.ExpiresInHours > maxGuestLinkHours {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_hours must be 1-720"})
+			return
+		}
+
+		if !requireProjectOwner(c, projectID) {
+			return
+		}
+
+		token, err := newOpaqueToken(guestTokenPrefix)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		sql := `
+			INSERT INTO project_guest_links (project_id, token_hash, label, created_by, expires_at)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, project_id, label, created_by, expires_at, revoked_at, last_used_at, created_at;
+		`
+
+		var l ProjectGuestLink
+		err = db.QueryRow(context.Background(), sql,
+			projectID, hashToken(token), body.Label, currentUserID(c),
+			time.Now().Add(time.Duration(body.ExpiresInHours)*time.Hour),
+		).Scan(&l.ID, &l.ProjectID, &l.Label, &l.CreatedBy, &l.ExpiresAt, &l.RevokedAt, &l.LastUsedAt, &l.CreatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"link": l, "token": token})
+	})
+
+	// GET /projects/:id/guest-links
+	g.GET("", func(c *gin.Context) {
+		projectID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
+			return
+		}
+		if !requireProjectOwner(c, projectID) {
+			return
+		}
+
+		rows, err := db.Query(context.Background(), `
+			SELECT id, project_id, label, created_by, expires_at, revoked_at, last_used_at, created_at
+			FROM project_guest_links
+			WHERE project_id = $1
+			ORDER BY created_at DESC;
+		`, projectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		links := []ProjectGuestLink{}
+		for rows.Next() {
+			var l ProjectGuestLink
+			if err := rows.Scan(&l.ID, &l.ProjectID, &l.Label, &l.CreatedBy, &l.ExpiresAt, &l.RevokedAt, &l.LastUsedAt, &l.CreatedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			links = append(links, l)
+		}
+
+		c.JSON(http.StatusOK, links)
+	})
+
+	// DELETE /projects/:id/guest-links/:linkId — revokes immediately
+	g.DELETE("/:linkId", func(c *gin.Context) {
+		projectID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
+			return
+		}
+		linkID, err := strconv.ParseInt(c.Param("linkId"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid link id"})
+			return
+		}
+		if !requireProjectOwner(c, projectID) {
+			return
+		}
+
+		tag, err := db.Exec(context.Background(), `
+			UPDATE project_guest_links
+			SET revoked_at = now()
+			WHERE id = $1 AND project_id = $2 AND revoked_at IS NULL;
+		`, linkID, projectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "guest link not found"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Handles give profiles pretty URLs (/artists/:handle). They are 3-30
+// lowercase letters, digits, or underscores starting with a letter or
+// digit, unique, and not one of the reserved words below, which are kept
+// for routes, staff, and the brand.
+var handlePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_]{2,29}$`)
+
+var reservedHandles = map[string]bool{
+	"admin": true, "administrator": true, "api": true, "app": true, "artists": true, "assets": true,
+	"auth": true, "avatars": true, "billing": true, "blog": true, "dashboard": true, "help": true,
+	"leep": true, "leep_official": true, "legal": true, "login": true, "logout": true, "me": true,
+	"mod": true, "moderator": true, "null": true, "privacy": true, "root": true, "settings": true,
+	"signin": true, "signup": true, "songs": true, "staff": true, "support": true, "system": true,
+	"terms": true, "undefined": true, "usernames": true,
+}
+
+// normalizeHandle lowercases name and returns why it can't be a handle
+// ("invalid" or "reserved"), or "" if it can.
+func normalizeHandle(name string) (string, string) {
+	handle := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "@"))
+	switch {
+	case !handlePattern.MatchString(handle):
+		return handle, "invalid"
+	case reservedHandles[handle]:
+		return handle, "reserved"
+	}
+	return handle, ""
+}
+
+// RegisterHandleRoutes defines handle availability, claiming, and lookup.
+func RegisterHandleRoutes(r *gin.Engine) {
+	// GET /usernames/check?name= — {"handle","available","reason"}; your own handle counts as available
+	r.GET("/usernames/check", OptionalAuth(), func(c *gin.Context) {
+		handle, reason := normalizeHandle(c.Query("name"))
+		if reason == "" {
+			var owner string
+			err := db.QueryRow(context.Background(),
+				`SELECT id::text FROM profiles WHERE handle = $1;`, handle).Scan(&owner)
+			if err == nil && owner != currentUserID(c) {
+				reason = "taken"
+			} else if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		resp := gin.H{"handle": handle, "available": reason == ""}
+		if reason != "" {
+			resp["reason"] = reason
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+
+	// PATCH /auth/me/handle {"handle"}
+	r.PATCH("/auth/me/handle", RequireAuth(), func(c *gin.Context) {
+		var body struct {
+			Handle string `json:"handle"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		handle, reason := normalizeHandle(body.Handle)
+		switch reason {
+		case "invalid":
+			c.JSON(http.StatusBadRequest, gin.H{"error": "handle must be 3-30 letters, digits, or underscores", "code": "invalid"})
+			return
+		case "reserved":
+			c.JSON(http.StatusConflict, gin.H{"error": "that handle is reserved", "code": "reserved"})
+			return
+		}
+
+		p, err := scanProfile(db.QueryRow(context.Background(), `
+			UPDATE profiles SET handle = $2, updated_at = now()
+			WHERE id = $1
+			RETURNING `+profileColumns+`;
+		`, currentUserID(c), handle))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "profile not found"})
+			return
+		}
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				c.JSON(http.StatusConflict, gin.H{"error": "that handle is taken", "code": "taken"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, p)
+	})
+
+	// GET /artists/:handle
+	r.GET("/artists/:handle", AllowClient(), RequireScope(apiScopeSongsRead), func(c *gin.Context) {
+		handle := strings.ToLower(strings.TrimPrefix(c.Param("handle"), "@"))
+		if !handlePattern.MatchString(handle) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "artist not found"})
+			return
+		}
+		p, err := scanProfile(db.QueryRow(context.Background(),
+			`SELECT `+profileColumns+` FROM profiles WHERE handle = $1;`, handle))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "artist not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, p)
+	})
+}
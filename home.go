@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// The app home screen is a list of modules whose order and settings staff
+// change without a client release. Each save is a new home_layouts row, so
+// the previous layout is a lookup away if a change goes wrong; GET /home
+// renders the newest. Modules that come out empty for a caller (a
+// collection outside its window, new_from_followed for a signed-out
+// listener) are left out rather than sent blank.
+const (
+	homeFeaturedCollection = "featured_collection"
+	homeTrending           = "trending"
+	homeNewFromFollowed    = "new_from_followed"
+
+	maxHomeModules     = 20
+	defaultHomeLimit   = 10
+	maxHomeLimit       = 50
+	maxHomeModuleTitle = 80
+	homeTrendingDays   = 7
+	homeFollowedDays   = 30
+	homeLayoutHistory  = 20
+)
+
+var homeModuleTypes = map[string]bool{homeFeaturedCollection: true, homeTrending: true, homeNewFromFollowed: true}
+
+// validateHomeModules checks and normalizes a layout in place.
+func validateHomeModules(modules []HomeModuleConfig) error {
+	if len(modules) == 0 || len(modules) > maxHomeModules {
+		return fmt.Errorf("a layout has 1-%d modules", maxHomeModules)
+	}
+	for i := range modules {
+		m := &modules[i]
+		if !homeModuleTypes[m.Type] {
+			return fmt.Errorf("module %d: type must be featured_collection, trending, or new_from_followed", i)
+		}
+		m.Title = strings.TrimSpace(m.Title)
+		if utf8.RuneCountInString(m.Title) > maxHomeModuleTitle {
+			return fmt.Errorf("module %d: title must be at most %d characters", i, maxHomeModuleTitle)
+		}
+		if m.Limit == 0 {
+			m.Limit = defaultHomeLimit
+		}
+		if m.Limit < 1 || m.Limit > maxHomeLimit {
+			return fmt.Errorf("module %d: limit must be 1-%d", i, maxHomeLimit)
+		}
+		if m.Type == homeFeaturedCollection {
+			if !collectionSlugPattern.MatchString(m.CollectionSlug) {
+				return fmt.Errorf("module %d: featured_collection needs a collection_slug", i)
+			}
+		} else if m.CollectionSlug != "" {
+			return fmt.Errorf("module %d: only featured_collection takes a collection_slug", i)
+		}
+	}
+	return nil
+}
+
+const homeLayoutColumns = `id, modules, created_by::text, created_at`
+
+func scanHomeLayout(row pgx.Row) (HomeLayout, error) {
+	var l HomeLayout
+	var modules []byte
+	if err := row.Scan(&l.ID, &modules, &l.CreatedBy, &l.CreatedAt); err != nil {
+		return l, err
+	}
+	err := json.Unmarshal(modules, &l.Modules)
+	return l, err
+}
+
+// trendingSongs is the most played published songs over the last week.
+func trendingSongs(ctx context.Context, hideExplicit bool, limit int) ([]Song, error) {
+	return querySongs(ctx, `
+		SELECT `+songColumns+` FROM `+songFrom+`
+		JOIN (
+			SELECT song_id, SUM(plays) AS plays FROM song_daily_stats
+			WHERE day > (now() AT TIME ZONE 'UTC')::date - $1::int
+			GROUP BY song_id
+		) st ON st.song_id = s.id
+		WHERE s.published AND NOT (s.explicit AND $2)
+		ORDER BY st.plays DESC, s.id DESC
+		LIMIT $3;
+	`, homeTrendingDays, hideExplicit, limit)
+}
+
+// followedSongs is the newest published songs from artists userID follows.
+func followedSongs(ctx context.Context, userID string, hideExplicit bool, limit int) ([]Song, error) {
+	return querySongs(ctx, `
+		SELECT `+songColumns+` FROM `+songFrom+`
+		JOIN follows f ON f.followee_id = s.artist_id AND f.follower_id = $1
+		WHERE s.published AND NOT (s.explicit AND $2)
+		  AND s.created_at > now() - make_interval(days => $3)
+		ORDER BY s.created_at DESC, s.id DESC
+		LIMIT $4;
+	`, userID, hideExplicit, homeFollowedDays, limit)
+}
+
+func querySongs(ctx context.Context, sql string, args ...interface{}) ([]Song, error) {
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	songs := []Song{}
+	for rows.Next() {
+		s, err := scanSong(rows)
+		if err != nil {
+			return nil, err
+		}
+		songs = append(songs, s)
+	}
+	return songs, rows.Err()
+}
+
+// renderHomeModule fills in one module for the caller, or returns nil when
+// it has nothing to show.
+func renderHomeModule(c *gin.Context, m HomeModuleConfig) (*HomeModule, error) {
+	ctx := c.Request.Context()
+	hide := hideExplicit(c)
+	out := HomeModule{Type: m.Type, Title: m.Title}
+
+	switch m.Type {
+	case homeFeaturedCollection:
+		cl, err := scanCollection(db.QueryRow(ctx,
+			`SELECT `+collectionColumns+` FROM collections WHERE slug = $1 AND `+collectionActive+`;`, m.CollectionSlug))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if cl.Songs, err = collectionSongs(ctx, cl.ID, hide, m.Limit); err != nil {
+			return nil, err
+		}
+		if out.Title == "" {
+			out.Title = cl.Title
+		}
+		out.Collection = &cl
+		out.Songs = cl.Songs
+	case homeTrending:
+		songs, err := trendingSongs(ctx, hide, m.Limit)
+		if err != nil {
+			return nil, err
+		}
+		out.Songs = songs
+	case homeNewFromFollowed:
+		userID := currentUserID(c)
+		if userID == "" {
+			return nil, nil
+		}
+		songs, err := followedSongs(ctx, userID, hide, m.Limit)
+		if err != nil {
+			return nil, err
+		}
+		out.Songs = songs
+	}
+	if len(out.Songs) == 0 {
+		return nil, nil
+	}
+	return &out, nil
+}
+
+// RegisterHomeRoutes defines the home screen and its layout management.
+func RegisterHomeRoutes(r *gin.Engine) {
+	// GET /home — the current layout's modules, rendered for the caller
+	r.GET("/home", OptionalAuth(), func(c *gin.Context) {
+		l, err := scanHomeLayout(db.QueryRow(c.Request.Context(),
+			`SELECT `+homeLayoutColumns+` FROM home_layouts ORDER BY id DESC LIMIT 1;`))
+		if errors.Is(err, pgx.ErrNoRows) {
+			l = HomeLayout{Modules: []HomeModuleConfig{{Type: homeTrending, Limit: defaultHomeLimit}}}
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		modules := []HomeModule{}
+		for _, m := range l.Modules {
+			rendered, err := renderHomeModule(c, m)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if rendered != nil {
+				modules = append(modules, *rendered)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"layout_id": l.ID, "modules": modules})
+	})
+
+	admin := r.Group("/admin/home", RequireAuth(), RequireRole("admin", "curator"), RequireMFA())
+
+	// GET /admin/home — recent layouts, newest (current) first
+	admin.GET("", func(c *gin.Context) {
+		rows, err := db.Query(c.Request.Context(),
+			`SELECT `+homeLayoutColumns+` FROM home_layouts ORDER BY id DESC LIMIT $1;`, homeLayoutHistory)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		layouts := []HomeLayout{}
+		for rows.Next() {
+			l, err := scanHomeLayout(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			layouts = append(layouts, l)
+		}
+		c.JSON(http.StatusOK, layouts)
+	})
+
+	// PUT /admin/home {"modules":[{"type":"featured_collection","collection_slug":"staff-picks","title":"","limit":10},{"type":"trending"}]}
+	admin.PUT("", func(c *gin.Context) {
+		var body struct {
+			Modules []HomeModuleConfig `json:"modules"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if err := validateHomeModules(body.Modules); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx := c.Request.Context()
+
+		// A slug that doesn't exist is a typo; one outside its window is
+		// fine, as it may be scheduled.
+		for i, m := range body.Modules {
+			if m.Type != homeFeaturedCollection {
+				continue
+			}
+			var exists bool
+			if err := db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM collections WHERE slug = $1);`,
+				m.CollectionSlug).Scan(&exists); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if !exists {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("module %d: no collection %q", i, m.CollectionSlug)})
+				return
+			}
+		}
+
+		modules, _ := json.Marshal(body.Modules)
+		l, err := scanHomeLayout(db.QueryRow(ctx, `
+			INSERT INTO home_layouts (modules, created_by) VALUES ($1, $2)
+			RETURNING `+homeLayoutColumns+`;
+		`, modules, currentUserID(c)))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, l)
+	})
+}
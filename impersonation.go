@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// Support staff can "log in as" a user to reproduce what they see. An admin
+// (with MFA) issues a short-lived impersonation token for the user, giving
+// a reason; the token is sent as an ordinary bearer token, and RequireAuth
+// then treats the request as the user's. Tokens are read-only unless issued
+// with the write scope, never reach admin, credential, or payment-setup
+// routes, and every request made with one is recorded in
+// impersonation_audit.
+const (
+	impersonationTokenPrefix = "lpi_"
+
+	impersonationScopeRead  = "read"
+	impersonationScopeWrite = "write"
+
+	defaultImpersonationTTL = 15 * time.Minute
+	maxImpersonationTTL     = time.Hour
+	maxImpersonationReason  = 500
+)
+
+// impersonationBlockedPrefixes are routes an impersonation token can never
+// reach, whatever its scope.
+var impersonationBlockedPrefixes = []string{
+	"/admin",
+	"/auth/mfa",
+	"/auth/email",
+	"/auth/password",
+	"/auth/refresh",
+	"/auth/api-keys",
+	"/auth/link",
+	"/auth/oauth",
+	"/auth/sessions",
+	"/auth/me/birth-date",
+	"/me/api-keys",
+	"/me/consent",
+	"/me/payments",
+	"/me/oauth-clients",
+	"/me/team",
+	"/me/webhooks",
+	"/me/social",
+	"/me/integrations",
+}
+
+const impersonationColumns = `id, admin_id::text, user_id::text, scope, reason, expires_at, revoked_at, revoked_by::text, created_at`
+
+func scanImpersonationSession(row pgx.Row) (ImpersonationSession, error) {
+	var s ImpersonationSession
+	err := row.Scan(&s.ID, &s.AdminID, &s.UserID, &s.Scope, &s.Reason, &s.ExpiresAt, &s.RevokedAt, &s.RevokedBy, &s.CreatedAt)
+	return s, err
+}
+
+// impersonationBlocked reports whether path is off limits to impersonation.
+func impersonationBlocked(path string) bool {
+	for _, p := range impersonationBlockedPrefixes {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// impersonatorID returns the admin behind an impersonated request, or "".
+func impersonatorID(c *gin.Context) string {
+	return c.GetString("impersonator_id")
+}
+
+// requireImpersonation authenticates an impersonation token for RequireAuth:
+// it stores the impersonated user under "user_id" and the admin under
+// "impersonator_id", enforces the token's scope, and records the request
+// in the audit log once it has been handled.
+func requireImpersonation(c *gin.Context, token string) {
+	ctx := c.Request.Context()
+	s, err := scanImpersonationSession(db.QueryRow(ctx, `
+		SELECT `+impersonationColumns+` FROM impersonation_sessions
+		WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > now();
+	`, hashToken(token)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "impersonation token is invalid, expired, or revoked"})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Set("user_id", s.UserID)
+	c.Set("impersonator_id", s.AdminID)
+	c.Set("claims", &Claims{
+		SessionID: "impersonation:" + strconv.FormatInt(s.ID, 10),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   s.UserID,
+			ExpiresAt: jwt.NewNumericDate(s.ExpiresAt),
+		},
+	})
+	c.Header("X-Impersonated-By", s.AdminID)
+	defer recordImpersonatedRequest(c, s)
+
+	if impersonationBlocked(c.FullPath()) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not available while impersonating", "code": "impersonation_forbidden"})
+		return
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		if s.Scope != impersonationScopeWrite {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "this impersonation token is read-only", "code": "impersonation_read_only"})
+			return
+		}
+	}
+	if !checkAge(c, s.UserID) || !checkConsent(c, s.UserID) {
+		return
+	}
+	c.Next()
+}
+
+// recordImpersonatedRequest writes one audit row for a request made with an
+// impersonation token, including refused ones.
+func recordImpersonatedRequest(c *gin.Context, s ImpersonationSession) {
+	if _, err := db.Exec(context.Background(), `
+		INSERT INTO impersonation_audit (session_id, admin_id, user_id, method, path, route, status, ip, user_agent)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, NULLIF($9, ''));
+	`, s.ID, s.AdminID, s.UserID, c.Request.Method, c.Request.URL.Path, c.FullPath(), c.Writer.Status(),
+		c.ClientIP(), c.Request.UserAgent()); err != nil {
+		log.Printf("impersonation %d: failed to audit %s %s: %v", s.ID, c.Request.Method, c.Request.URL.Path, err)
+	}
+}
+
+// RegisterImpersonationRoutes defines issuing, listing, revoking, and
+// auditing impersonation tokens.
+func RegisterImpersonationRoutes(r *gin.Engine) {
+	// POST /admin/users/:id/impersonate {"reason":"ticket #123","scope":"read|write","ttl_minutes":15}
+	// Returns the token once, with the session.
+	r.POST("/admin/users/:id/impersonate", RequireAuth(), RequireRole("admin"), RequireMFA(), func(c *gin.Context) {
+		userID := c.Param("id")
+		if !userIDPattern.MatchString(userID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			return
+		}
+		if userID == currentUserID(c) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "you can't impersonate yourself"})
+			return
+		}
+		var body struct {
+			Reason     string `json:"reason"`
+			Scope      string `json:"scope"`
+			TTLMinutes int    `json:"ttl_minutes"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		reason := strings.TrimSpace(body.Reason)
+		if reason == "" || utf8.RuneCountInString(reason) > maxImpersonationReason {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required (a ticket or short explanation)", "max_length": maxImpersonationReason})
+			return
+		}
+		if body.Scope == "" {
+			body.Scope = impersonationScopeRead
+		}
+		if body.Scope != impersonationScopeRead && body.Scope != impersonationScopeWrite {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be read or write"})
+			return
+		}
+		ttl := defaultImpersonationTTL
+		if body.TTLMinutes != 0 {
+			ttl = time.Duration(body.TTLMinutes) * time.Minute
+		}
+		if ttl <= 0 || ttl > maxImpersonationTTL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ttl_minutes must be between 1 and 60"})
+			return
+		}
+		ctx := c.Request.Context()
+
+		role, err := userRole(ctx, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if role == "admin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "admins can't be impersonated"})
+			return
+		}
+		var exists bool
+		if err := db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM profiles WHERE id = $1);`, userID).Scan(&exists); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		token, err := newOpaqueToken(impersonationTokenPrefix)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		s, err := scanImpersonationSession(db.QueryRow(ctx, `
+			INSERT INTO impersonation_sessions (admin_id, user_id, token_hash, scope, reason, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING `+impersonationColumns+`;
+		`, currentUserID(c), userID, hashToken(token), body.Scope, reason, time.Now().Add(ttl)))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("impersonation %d: admin %s started %s session as %s (%s)", s.ID, s.AdminID, s.Scope, s.UserID, reason)
+
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusCreated, gin.H{"token": token, "session": s})
+	})
+
+	admin := r.Group("/admin/impersonations", RequireAuth(), RequireRole("admin"), RequireMFA())
+
+	// GET /admin/impersonations?user_id=&admin_id= — newest first
+	admin.GET("", func(c *gin.Context) {
+		userID, adminID := c.Query("user_id"), c.Query("admin_id")
+		for _, id := range []string{userID, adminID} {
+			if id != "" && !userIDPattern.MatchString(id) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+				return
+			}
+		}
+
+		rows, err := db.Query(context.Background(), `
+			SELECT `+impersonationColumns+` FROM impersonation_sessions
+			WHERE ($1 = '' OR user_id::text = $1) AND ($2 = '' OR admin_id::text = $2)
+			ORDER BY id DESC
+			LIMIT 200;
+		`, userID, adminID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		sessions := []ImpersonationSession{}
+		for rows.Next() {
+			s, err := scanImpersonationSession(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			sessions = append(sessions, s)
+		}
+		c.JSON(http.StatusOK, sessions)
+	})
+
+	// POST /admin/impersonations/:id/revoke
+	admin.POST("/:id/revoke", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session id"})
+			return
+		}
+		s, err := scanImpersonationSession(db.QueryRow(context.Background(), `
+			UPDATE impersonation_sessions SET revoked_at = now(), revoked_by = $2
+			WHERE id = $1 AND revoked_at IS NULL AND expires_at > now()
+			RETURNING `+impersonationColumns+`;
+		`, id, currentUserID(c)))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no active session with that id"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, s)
+	})
+
+	// GET /admin/impersonations/:id/audit — every request made with the token, oldest first
+	admin.GET("/:id/audit", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session id"})
+			return
+		}
+
+		rows, err := db.Query(context.Background(), `
+			SELECT id, session_id, admin_id::text, user_id::text, method, path, route, status, ip, user_agent, created_at
+			FROM impersonation_audit
+			WHERE session_id = $1
+			ORDER BY id
+			LIMIT 1000;
+		`, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		entries := []ImpersonationAuditEntry{}
+		for rows.Next() {
+			var e ImpersonationAuditEntry
+			if err := rows.Scan(&e.ID, &e.SessionID, &e.AdminID, &e.UserID, &e.Method, &e.Path, &e.Route,
+				&e.Status, &e.IP, &e.UserAgent, &e.CreatedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			entries = append(entries, e)
+		}
+		c.JSON(http.StatusOK, entries)
+	})
+}
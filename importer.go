@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Catalog import reads an artist's public SoundCloud or Bandcamp profile,
+// follows links to their track and album pages, and creates an unpublished
+// draft song per track. Only public metadata (title, duration, release
+// date, source link) is imported: neither platform permits downloading
+// audio, so artists attach audio to the drafts themselves.
+const (
+	catalogImportJob       = "catalog_import"
+	maxImportPages         = 50
+	maxImportTracks        = 200
+	maxImportPageBytes     = 2 << 20
+	importFetchDelay       = 500 * time.Millisecond
+	importRequestTimeout   = 15 * time.Second
+	importUserAgent        = "LeepCatalogImport/1.0 (+https://leep.app)"
+	importSourceBandcamp   = "bandcamp"
+	importSourceSoundCloud = "soundcloud"
+)
+
+type catalogImportPayload struct {
+	ArtistID string `json:"artist_id"`
+	URL      string `json:"url"`
+	Source   string `json:"source"`
+}
+
+type catalogImportResult struct {
+	Pages   int     `json:"pages"`
+	Found   int     `json:"found"`
+	Created []int64 `json:"created_song_ids"`
+	Skipped int     `json:"skipped"`
+}
+
+// importedTrack is one track's public metadata.
+type importedTrack struct {
+	Title       string
+	URL         string
+	DurationSec *int
+	ReleasedAt  *time.Time
+}
+
+var (
+	importHTTP = &http.Client{Timeout: importRequestTimeout}
+
+	ldJSONPattern  = regexp.MustCompile(`(?is)<script[^>]+type="application/ld\+json"[^>]*>(.*?)</script>`)
+	ogTitlePattern = regexp.MustCompile(`(?i)<meta[^>]+property="og:title"[^>]+content="([^"]*)"`)
+	hrefPattern    = regexp.MustCompile(`(?i)href="([^"#?]+)"`)
+	isoDuration    = regexp.MustCompile(`^P(?:T?(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)$`)
+)
+
+func init() {
+	RegisterJobHandler(catalogImportJob, runCatalogImport)
+}
+
+// importSource validates a profile URL and reports which platform it is on.
+func importSource(raw string) (*url.URL, string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, "", fmt.Errorf("url must be an absolute http(s) URL")
+	}
+	u.Scheme = "https"
+	host := strings.ToLower(u.Hostname())
+
+	switch {
+	case host == "soundcloud.com" || host == "www.soundcloud.com" || host == "m.soundcloud.com":
+		// Profile root only: soundcloud.com/<user>
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) != 1 || parts[0] == "" {
+			return nil, "", fmt.Errorf("use your SoundCloud profile URL, e.g. https://soundcloud.com/yourname")
+		}
+		u.Host = "soundcloud.com"
+		return u, importSourceSoundCloud, nil
+	case strings.HasSuffix(host, ".bandcamp.com"):
+		u.Path = "/"
+		return u, importSourceBandcamp, nil
+	}
+	return nil, "", fmt.Errorf("only SoundCloud and Bandcamp profiles can be imported")
+}
+
+func fetchImportPage(ctx context.Context, pageURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", importUserAgent)
+
+	resp, err := importHTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned %d", pageURL, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxImportPageBytes))
+	return string(body), err
+}
+
+// trackPageLinks returns same-site links on a profile page that look like
+// track or album pages, in page order and without duplicates.
+func trackPageLinks(base *url.URL, source, page string) []string {
+	seen := map[string]bool{}
+	var links []string
+	for _, m := range hrefPattern.FindAllStringSubmatch(page, -1) {
+		ref, err := base.Parse(html.UnescapeString(m[1]))
+		if err != nil || !strings.EqualFold(ref.Hostname(), base.Hostname()) {
+			continue
+		}
+
+		path := strings.Trim(ref.Path, "/")
+		switch source {
+		case importSourceBandcamp:
+			if !strings.HasPrefix(path, "album/") && !strings.HasPrefix(path, "track/") {
+				continue
+			}
+		case importSourceSoundCloud:
+			// soundcloud.com/<user>/<track>, skipping the profile's own tabs.
+			parts := strings.Split(path, "/")
+			user := strings.Trim(base.Path, "/")
+			if len(parts) != 2 || parts[0] != user {
+				continue
+			}
+			switch parts[1] {
+			case "tracks", "albums", "sets", "reposts", "likes", "following", "followers", "popular-tracks", "comments":
+				continue
+			}
+		}
+
+		ref.RawQuery, ref.Fragment = "", ""
+		if s := ref.String(); !seen[s] {
+			seen[s] = true
+			links = append(links, s)
+		}
+	}
+	return links
+}
+
+// parseISODuration converts schema.org durations like "PT3M25S" (or
+// Bandcamp's "P00H03M25S") to seconds.
+func parseISODuration(s string) *int {
+	m := isoDuration.FindStringSubmatch(strings.Replace(s, "P00H", "PT0H", 1))
+	if m == nil {
+		return nil
+	}
+	total := 0
+	for i, mult := range []int{3600, 60, 1} {
+		if m[i+1] != "" {
+			n, _ := strconv.Atoi(m[i+1])
+			total += n * mult
+		}
+	}
+	return &total
+}
+
+// ldTrack is the subset of schema.org MusicRecording/MusicAlbum we read.
+type ldTrack struct {
+	Type          interface{}     `json:"@type"`
+	Name          string          `json:"name"`
+	URL           string          `json:"url"`
+	ID            string          `json:"@id"`
+	Duration      string          `json:"duration"`
+	DatePublished string          `json:"datePublished"`
+	Track         json.RawMessage `json:"track"`
+	Item          *ldTrack        `json:"item"`
+}
+
+func (t ldTrack) isType(want string) bool {
+	switch v := t.Type.(type) {
+	case string:
+		return v == want
+	case []interface{}:
+		for _, s := range v {
+			if s == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tracksFromPage extracts tracks from a page's JSON-LD, falling back to its
+// og:title for single-track pages without structured data.
+func tracksFromPage(pageURL, page string) []importedTrack {
+	var tracks []importedTrack
+	add := func(t ldTrack, fallbackDate string) {
+		link := t.URL
+		if link == "" {
+			link = t.ID
+		}
+		if t.Name == "" || link == "" {
+			return
+		}
+		it := importedTrack{Title: t.Name, URL: link, DurationSec: parseISODuration(t.Duration)}
+		date := t.DatePublished
+		if date == "" {
+			date = fallbackDate
+		}
+		for _, layout := range []string{time.RFC3339, "02 Jan 2006 15:04:05 MST", dateLayout} {
+			if ts, err := time.Parse(layout, date); err == nil {
+				it.ReleasedAt = &ts
+				break
+			}
+		}
+		tracks = append(tracks, it)
+	}
+
+	for _, m := range ldJSONPattern.FindAllStringSubmatch(page, -1) {
+		var doc ldTrack
+		if err := json.Unmarshal([]byte(m[1]), &doc); err != nil {
+			continue
+		}
+
+		switch {
+		case doc.isType("MusicRecording"):
+			add(doc, "")
+		case doc.isType("MusicAlbum") || doc.isType("MusicPlaylist"):
+			// track is either an ItemList or an array of recordings.
+			var list struct {
+				ItemListElement []ldTrack `json:"itemListElement"`
+			}
+			var items []ldTrack
+			if err := json.Unmarshal(doc.Track, &list); err == nil && list.ItemListElement != nil {
+				for _, el := range list.ItemListElement {
+					if el.Item != nil {
+						items = append(items, *el.Item)
+					}
+				}
+			} else {
+				json.Unmarshal(doc.Track, &items)
+			}
+			for _, t := range items {
+				add(t, doc.DatePublished)
+			}
+		}
+	}
+
+	if len(tracks) == 0 {
+		if m := ogTitlePattern.FindStringSubmatch(page); m != nil {
+			tracks = append(tracks, importedTrack{Title: html.UnescapeString(m[1]), URL: pageURL})
+		}
+	}
+	return tracks
+}
+
+func runCatalogImport(ctx context.Context, job *Job) (interface{}, error) {
+	var payload catalogImportPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return nil, err
+	}
+	base, source, err := importSource(payload.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := fetchImportPage(ctx, base.String())
+	if err != nil {
+		return nil, err
+	}
+	links := trackPageLinks(base, source, profile)
+	if len(links) > maxImportPages {
+		links = links[:maxImportPages]
+	}
+
+	result := catalogImportResult{Pages: 1, Created: []int64{}}
+	seen := map[string]bool{}
+	var tracks []importedTrack
+	for _, t := range tracksFromPage(base.String(), profile) {
+		if t.URL != base.String() && !seen[t.URL] {
+			seen[t.URL] = true
+			tracks = append(tracks, t)
+		}
+	}
+
+	// Fetching pages is most of the work, so it drives progress.
+	for i, link := range links {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(importFetchDelay):
+		}
+
+		page, err := fetchImportPage(ctx, link)
+		if err != nil {
+			continue
+		}
+		result.Pages++
+		for _, t := range tracksFromPage(link, page) {
+			if !seen[t.URL] {
+				seen[t.URL] = true
+				tracks = append(tracks, t)
+			}
+		}
+		SetJobProgress(ctx, job.ID, (i+1)*90/len(links))
+	}
+
+	if len(tracks) > maxImportTracks {
+		tracks = tracks[:maxImportTracks]
+	}
+	result.Found = len(tracks)
+
+	for _, t := range tracks {
+		var songID int64
+		err := db.QueryRow(ctx, `
+			INSERT INTO songs (title, artist_id, published, source_url, duration_seconds, release_date)
+			VALUES ($1, $2, false, $3, $4, $5::date)
+			ON CONFLICT (artist_id, source_url) WHERE source_url IS NOT NULL DO NOTHING
+			RETURNING id;
+		`, t.Title, payload.ArtistID, t.URL, t.DurationSec, t.ReleasedAt).Scan(&songID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Already imported on an earlier run.
+			result.Skipped++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Created = append(result.Created, songID)
+	}
+	return result, nil
+}
+
+// RegisterImportRoutes defines the catalog import endpoints.
+func RegisterImportRoutes(r *gin.Engine) {
+	me := r.Group("/me/imports", RequireAuth())
+
+	// POST /me/imports — {"url":"https://yourname.bandcamp.com"}
+	me.POST("", func(c *gin.Context) {
+		var body struct {
+			URL string `json:"url"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+
+		u, source, err := importSource(body.URL)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		payload := catalogImportPayload{ArtistID: currentUserID(c), URL: u.String(), Source: source}
+		jobID, err := EnqueueJob(context.Background(), catalogImportJob, payload, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"job_id":     jobID,
+			"status":     "queued",
+			"status_url": fmt.Sprintf("/me/imports/%d", jobID),
+		})
+	})
+
+	// GET /me/imports/:id — progress, and the created draft song IDs once done
+	me.GET("/:id", func(c *gin.Context) {
+		jobID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+			return
+		}
+
+		job, found, err := GetJob(context.Background(), jobID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var payload catalogImportPayload
+		if found {
+			json.Unmarshal(job.Payload, &payload)
+		}
+		if !found || job.Type != catalogImportJob || payload.ArtistID != currentUserID(c) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "import not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"job": job, "import": payload})
+	})
+}
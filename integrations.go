@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Polling triggers for Zapier/Make. Each returns a flat JSON array, newest
+// first, where every item has a unique, increasing "id" — the shape those
+// platforms dedupe on. Pass ?since_id= (the highest id seen) to fetch only
+// newer items; X-Next-Since-Id carries the value for the next poll.
+const (
+	defaultTriggerLimit = 50
+	maxTriggerLimit     = 100
+)
+
+// integrationTrigger loads items for the artist newer than sinceID.
+type integrationTrigger func(ctx context.Context, artistID string, sinceID int64, limit int) ([]gin.H, error)
+
+var integrationTriggers = map[string]integrationTrigger{
+	"new_comment":  newCommentTrigger,
+	"new_tip":      newTipTrigger,
+	"new_follower": newFollowerTrigger,
+}
+
+func newCommentTrigger(ctx context.Context, artistID string, sinceID int64, limit int) ([]gin.H, error) {
+	rows, err := db.Query(ctx, `
+		SELECT c.id, c.song_id, s.title, c.author_id, c.body, c.created_at
+		FROM comments c
+		JOIN songs s ON s.id = c.song_id
+		WHERE s.artist_id = $1 AND c.id > $2 AND c.trashed_at IS NULL
+		ORDER BY c.id DESC
+		LIMIT $3;
+	`, artistID, sinceID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []gin.H{}
+	for rows.Next() {
+		var (
+			id, songID              int64
+			songTitle, author, body string
+			createdAt               time.Time
+		)
+		if err := rows.Scan(&id, &songID, &songTitle, &author, &body, &createdAt); err != nil {
+			return nil, err
+		}
+		items = append(items, gin.H{
+			"id": id, "song_id": songID, "song_title": songTitle,
+			"author_id": author, "body": body, "created_at": createdAt,
+		})
+	}
+	return items, rows.Err()
+}
+
+func newTipTrigger(ctx context.Context, artistID string, sinceID int64, limit int) ([]gin.H, error) {
+	rows, err := db.Query(ctx, `
+		SELECT t.id, t.song_id, s.title, CASE WHEN p.hide_tips THEN NULL ELSE t.sender_id::text END, t.amount, t.created_at
+		FROM tips t
+		JOIN songs s ON s.id = t.song_id
+		LEFT JOIN profiles p ON p.id = t.sender_id
+		WHERE s.artist_id = $1 AND t.id > $2 AND t.review_status = 'cleared'
+		ORDER BY t.id DESC
+		LIMIT $3;
+	`, artistID, sinceID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []gin.H{}
+	for rows.Next() {
+		var (
+			id, songID int64
+			songTitle  string
+			sender     *string
+			amount     float64
+			createdAt  time.Time
+		)
+		if err := rows.Scan(&id, &songID, &songTitle, &sender, &amount, &createdAt); err != nil {
+			return nil, err
+		}
+		fee, net := tipFee(amount)
+		items = append(items, gin.H{
+			"id": id, "song_id": songID, "song_title": songTitle, "sender_id": sender,
+			"gross_amount": amount, "fee_amount": fee, "net_amount": net, "created_at": createdAt,
+		})
+	}
+	return items, rows.Err()
+}
+
+func newFollowerTrigger(ctx context.Context, artistID string, sinceID int64, limit int) ([]gin.H, error) {
+	rows, err := db.Query(ctx, `
+		SELECT id, follower_id, created_at
+		FROM follows
+		WHERE followee_id = $1 AND id > $2
+		ORDER BY id DESC
+		LIMIT $3;
+	`, artistID, sinceID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []gin.H{}
+	for rows.Next() {
+		var (
+			id         int64
+			followerID string
+			createdAt  time.Time
+		)
+		if err := rows.Scan(&id, &followerID, &createdAt); err != nil {
+			return nil, err
+		}
+		items = append(items, gin.H{"id": id, "follower_id": followerID, "created_at": createdAt})
+	}
+	return items, rows.Err()
+}
+
+// RegisterIntegrationRoutes defines the API-key authenticated trigger feeds.
+func RegisterIntegrationRoutes(r *gin.Engine) {
+	// GET /integrations/triggers/:type?since_id=&limit=
+	r.GET("/integrations/triggers/:type", RequireAPIKey(), RequireScope(apiScopeSongsRead), func(c *gin.Context) {
+		trigger, ok := integrationTriggers[c.Param("type")]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "unknown trigger; use new_comment, new_tip, or new_follower"})
+			return
+		}
+
+		var sinceID int64
+		if s := c.Query("since_id"); s != "" {
+			v, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || v < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "since_id must be a non-negative integer"})
+				return
+			}
+			sinceID = v
+		}
+
+		limit := defaultTriggerLimit
+		if s := c.Query("limit"); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil || v < 1 || v > maxTriggerLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be 1-100"})
+				return
+			}
+			limit = v
+		}
+
+		items, err := trigger(context.Background(), currentUserID(c), sinceID, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		next := sinceID
+		if len(items) > 0 {
+			next = items[0]["id"].(int64)
+		}
+		c.Header("X-Next-Since-Id", strconv.FormatInt(next, 10))
+		c.JSON(http.StatusOK, items)
+	})
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// JobHandler runs one job and returns a JSON-encodable result.
+type JobHandler func(ctx context.Context, job *Job) (interface{}, error)
+
+// A claimed job is leased for jobLease and the lease is renewed every
+// jobLeaseRenewal while its handler runs, so a job whose worker died is
+// picked up again once the lease runs out.
+const (
+	jobMaxAttempts  = 3
+	jobPollInterval = 2 * time.Second
+	jobLease        = 5 * time.Minute
+	jobLeaseRenewal = time.Minute
+)
+
+var jobHandlers = map[string]JobHandler{}
+
+// RegisterJobHandler makes jobs of the given type runnable by the worker.
+func RegisterJobHandler(jobType string, h JobHandler) {
+	jobHandlers[jobType] = h
+}
+
+// EnqueueJob inserts a queued job and returns its ID.
+func EnqueueJob(ctx context.Context, jobType string, payload interface{}, createdBy string) (int64, error) {
+	return EnqueueJobAt(ctx, jobType, payload, createdBy, time.Now())
+}
+
+// EnqueueJobAt inserts a job that won't run before runAt.
+func EnqueueJobAt(ctx context.Context, jobType string, payload interface{}, createdBy string, runAt time.Time) (int64, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	var creator *string
+	if createdBy != "" {
+		creator = &createdBy
+	}
+
+	var id int64
+	err = db.QueryRow(ctx, `
+		INSERT INTO jobs (type, payload, created_by, run_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id;
+	`, jobType, raw, creator, runAt).Scan(&id)
+	return id, err
+}
+
+// SetJobProgress records a 0-100 progress value for a running job.
+func SetJobProgress(ctx context.Context, jobID int64, progress int) {
+	if _, err := db.Exec(ctx,
+		`UPDATE jobs SET progress = $2, updated_at = now() WHERE id = $1;`,
+		jobID, progress,
+	); err != nil {
+		log.Printf("job %d: failed to record progress: %v", jobID, err)
+	}
+}
+
+// GetJob loads a job by ID; found is false when it does not exist.
+func GetJob(ctx context.Context, jobID int64) (job Job, found bool, err error) {
+	err = db.QueryRow(ctx, `
+		SELECT id, type, payload, status, progress, result, error, attempts,
+		       created_by, created_at, updated_at, finished_at
+		FROM jobs WHERE id = $1;
+	`, jobID).Scan(&job.ID, &job.Type, &job.Payload, &job.Status, &job.Progress, &job.Result,
+		&job.Error, &job.Attempts, &job.CreatedBy, &job.CreatedAt, &job.UpdatedAt, &job.FinishedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return job, false, nil
+	}
+	return job, err == nil, err
+}
+
+// StartJobWorker polls the jobs table in the background until ctx is done.
+func StartJobWorker(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(jobPollInterval)
+		defer ticker.Stop()
+
+		for {
+			requeueExpiredJobs(ctx)
+			// Drain everything that is ready before sleeping again.
+			for runNextJob(ctx) {
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// StartPeriodic runs fn every interval until ctx is done. A Postgres advisory
+// lock keyed by name ensures only one server instance runs it at a time.
+func StartPeriodic(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			runPeriodic(ctx, name, fn)
+		}
+	}()
+}
+
+func runPeriodic(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	conn, err := db.Acquire(ctx)
+	if err != nil {
+		log.Printf("periodic %s: %v", name, err)
+		return
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock(hashtext($1));`, name).Scan(&locked); err != nil || !locked {
+		return
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock(hashtext($1));`, name)
+
+	if err := fn(ctx); err != nil {
+		log.Printf("periodic %s failed: %v", name, err)
+	}
+}
+
+// requeueExpiredJobs queues running jobs whose lease ran out again, or fails
+// them once they've used their attempts. Jobs claimed before leases existed
+// count from their last update.
+func requeueExpiredJobs(ctx context.Context) {
+	rows, err := db.Query(ctx, `
+		UPDATE jobs
+		SET status = CASE WHEN attempts < $2 THEN 'queued' ELSE 'failed' END,
+		    error = 'worker stopped before the job finished',
+		    locked_until = NULL, updated_at = now(),
+		    finished_at = CASE WHEN attempts < $2 THEN NULL ELSE now() END
+		WHERE status = 'running' AND COALESCE(locked_until, updated_at + make_interval(secs => $1)) < now()
+		RETURNING id, type, status;
+	`, jobLease.Seconds(), jobMaxAttempts)
+	if err != nil {
+		log.Printf("job worker: failed to requeue expired jobs: %v", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id              int64
+			jobType, status string
+		)
+		if err := rows.Scan(&id, &jobType, &status); err == nil {
+			log.Printf("job %d (%s): lease expired, now %s", id, jobType, status)
+		}
+	}
+}
+
+// renewJobLease extends job's lease every jobLeaseRenewal until done is closed.
+func renewJobLease(ctx context.Context, jobID int64, done <-chan struct{}) {
+	ticker := time.NewTicker(jobLeaseRenewal)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := db.Exec(ctx, `
+			UPDATE jobs SET locked_until = now() + make_interval(secs => $2)
+			WHERE id = $1 AND status = 'running';
+		`, jobID, jobLease.Seconds()); err != nil {
+			log.Printf("job %d: failed to renew lease: %v", jobID, err)
+		}
+	}
+}
+
+// runJobHandler runs h, turning a panic into an error so one bad job can't
+// take the worker down. panicked reports whether it did.
+func runJobHandler(ctx context.Context, h JobHandler, job *Job) (result interface{}, panicked bool, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("job %d (%s) panicked: %v\n%s", job.ID, job.Type, p, debug.Stack())
+			result, panicked, err = nil, true, fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	result, err = h(ctx, job)
+	return result, false, err
+}
+
+// runNextJob claims and runs one ready job. It reports whether a job was run.
+func runNextJob(ctx context.Context) bool {
+	var job Job
+	err := db.QueryRow(ctx, `
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1, updated_at = now(),
+		    locked_until = now() + make_interval(secs => $1)
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = 'queued' AND run_at <= now()
+			ORDER BY id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING id, type, payload, attempts, created_by;
+	`, jobLease.Seconds()).Scan(&job.ID, &job.Type, &job.Payload, &job.Attempts, &job.CreatedBy)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false
+	}
+	if err != nil {
+		log.Printf("job worker: failed to claim job: %v", err)
+		return false
+	}
+
+	handler, ok := jobHandlers[job.Type]
+	if !ok {
+		finishJob(ctx, &job, nil, errors.New("no handler for job type "+job.Type), false)
+		return true
+	}
+
+	done := make(chan struct{})
+	go renewJobLease(ctx, job.ID, done)
+	result, panicked, runErr := runJobHandler(ctx, handler, &job)
+	close(done)
+	// A panic is a bug, not a passing failure; retrying won't help.
+	finishJob(ctx, &job, result, runErr, !panicked && job.Attempts < jobMaxAttempts)
+	return true
+}
+
+// finishJob stores the outcome. Failed jobs are re-queued with a linear
+// backoff while retry is true, and marked failed otherwise.
+func finishJob(ctx context.Context, job *Job, result interface{}, runErr error, retry bool) {
+	var err error
+	switch {
+	case runErr == nil:
+		raw, _ := json.Marshal(result)
+		_, err = db.Exec(ctx, `
+			UPDATE jobs
+			SET status = 'done', progress = 100, result = $2, error = NULL,
+			    locked_until = NULL, updated_at = now(), finished_at = now()
+			WHERE id = $1;
+		`, job.ID, raw)
+	case retry:
+		log.Printf("job %d (%s) attempt %d failed, retrying: %v", job.ID, job.Type, job.Attempts, runErr)
+		_, err = db.Exec(ctx, `
+			UPDATE jobs
+			SET status = 'queued', error = $2, locked_until = NULL, updated_at = now(),
+			    run_at = now() + make_interval(mins => $3)
+			WHERE id = $1;
+		`, job.ID, runErr.Error(), job.Attempts)
+	default:
+		log.Printf("job %d (%s) failed: %v", job.ID, job.Type, runErr)
+		_, err = db.Exec(ctx, `
+			UPDATE jobs
+			SET status = 'failed', error = $2, locked_until = NULL, updated_at = now(), finished_at = now()
+			WHERE id = $1;
+		`, job.ID, runErr.Error())
+	}
+	if err != nil {
+		log.Printf("job %d: failed to record result: %v", job.ID, err)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Supabase projects on asymmetric signing keys publish their public keys
+// at /auth/v1/.well-known/jwks.json. The set is cached for jwksCacheTTL and
+// refetched early when a token names a key we haven't seen (a rotation),
+// at most once per jwksMinRefresh.
+const (
+	jwksCacheTTL   = 10 * time.Minute
+	jwksMinRefresh = 30 * time.Second
+
+	jwtVerifyHS256 = "hs256"
+	jwtVerifyJWKS  = "jwks"
+	jwtVerifyBoth  = "both"
+)
+
+var (
+	jwksHTTP = &http.Client{Timeout: 5 * time.Second}
+	jwks     = &jwksCache{}
+
+	errUnknownSigningKey = errors.New("token is signed with an unknown key")
+)
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey decodes an RSA or P-256 EC key.
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	b64 := base64.RawURLEncoding
+	switch k.Kty {
+	case "RSA":
+		n, err := b64.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := b64.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// jwksCache holds the project's signing keys by kid.
+type jwksCache struct {
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	loadedAt  time.Time
+	fetchedAt time.Time
+}
+
+// key returns the public key for kid, fetching the set when it is stale
+// or doesn't have kid.
+func (j *jwksCache) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if k, ok := j.keys[kid]; ok && time.Since(j.loadedAt) < jwksCacheTTL {
+		return k, nil
+	}
+	if time.Since(j.fetchedAt) >= jwksMinRefresh || j.keys == nil {
+		j.fetchedAt = time.Now()
+		keys, err := fetchJWKS(ctx)
+		if err != nil {
+			// Keep using the keys we have through a JWKS outage.
+			if k, ok := j.keys[kid]; ok {
+				return k, nil
+			}
+			return nil, err
+		}
+		j.keys, j.loadedAt = keys, time.Now()
+	}
+	if k, ok := j.keys[kid]; ok {
+		return k, nil
+	}
+	return nil, errUnknownSigningKey
+}
+
+// prime loads the key set ahead of the first token.
+func (j *jwksCache) prime(ctx context.Context) error {
+	keys, err := fetchJWKS(ctx)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.keys, j.loadedAt, j.fetchedAt = keys, time.Now(), time.Now()
+	return nil
+}
+
+func jwksURL() string {
+	if cfg.JWKSURL != "" {
+		return cfg.JWKSURL
+	}
+	return cfg.SupabaseURL + "/auth/v1/.well-known/jwks.json"
+}
+
+func fetchJWKS(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURL(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := jwksHTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks endpoint returned %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 256<<10)).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	return keys, nil
+}
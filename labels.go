@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Label accounts (profile role "label") own a label; admins maintain which
+// artists are signed to it. Earnings reports read the same records payouts
+// are reconciled against: cleared tips, split into fee and net with
+// tipFee's rounding, and paid payouts.
+const (
+	defaultEarningsDays = 30
+	maxEarningsDays     = 366
+)
+
+// artistEarnings is one roster artist's row in a label report.
+type artistEarnings struct {
+	ArtistID    string  `json:"artist_id"`
+	DisplayName string  `json:"display_name"`
+	Tips        int64   `json:"tips"`
+	Gross       float64 `json:"gross_amount"`
+	Fees        float64 `json:"fee_amount"`
+	Net         float64 `json:"net_amount"`
+	PaidOut     float64 `json:"paid_out_amount"`
+}
+
+func loadLabelEarnings(ctx context.Context, labelID int64, from, to time.Time) ([]artistEarnings, error) {
+	rows, err := db.Query(ctx, `
+		WITH roster AS (
+			SELECT artist_id FROM label_artists WHERE label_id = $1
+		), tipped AS (
+			SELECT s.artist_id,
+			       COUNT(*) AS tips,
+			       SUM(t.amount)::numeric AS gross,
+			       SUM(ROUND(t.amount::numeric * $4::numeric) / 100) AS fees
+			FROM tips t
+			JOIN songs s ON s.id = t.song_id
+			WHERE s.artist_id IN (SELECT artist_id FROM roster)
+			  AND t.review_status = 'cleared'
+			  AND t.created_at >= $2 AND t.created_at < $3
+			GROUP BY s.artist_id
+		), paid AS (
+			SELECT artist_id, SUM(amount) AS paid_out
+			FROM payouts
+			WHERE status = 'paid' AND artist_id IN (SELECT artist_id FROM roster)
+			  AND updated_at >= $2 AND updated_at < $3
+			GROUP BY artist_id
+		)
+		SELECT r.artist_id::text, COALESCE(p.display_name, ''),
+		       COALESCE(t.tips, 0), COALESCE(t.gross, 0)::float8, COALESCE(t.fees, 0)::float8,
+		       COALESCE(pd.paid_out, 0)::float8
+		FROM roster r
+		LEFT JOIN profiles p ON p.id = r.artist_id
+		LEFT JOIN tipped t ON t.artist_id = r.artist_id
+		LEFT JOIN paid pd ON pd.artist_id = r.artist_id
+		ORDER BY COALESCE(t.gross, 0) DESC, r.artist_id;
+	`, labelID, from, to, cfg.PlatformFeePercent)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	earnings := []artistEarnings{}
+	for rows.Next() {
+		var e artistEarnings
+		if err := rows.Scan(&e.ArtistID, &e.DisplayName, &e.Tips, &e.Gross, &e.Fees, &e.PaidOut); err != nil {
+			return nil, err
+		}
+		e.Net = math.Round((e.Gross-e.Fees)*100) / 100
+		earnings = append(earnings, e)
+	}
+	return earnings, rows.Err()
+}
+
+// labelForOwner loads the label and checks the caller owns it (admins may
+// read any label), writing the error response when not.
+func labelForOwner(c *gin.Context) (Label, bool) {
+	labelID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid label id"})
+		return Label{}, false
+	}
+
+	var l Label
+	err = db.QueryRow(context.Background(),
+		`SELECT id, owner_id::text, name, created_at FROM labels WHERE id = $1;`, labelID,
+	).Scan(&l.ID, &l.OwnerID, &l.Name, &l.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "label not found"})
+		return Label{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return Label{}, false
+	}
+	if l.OwnerID == currentUserID(c) {
+		return l, true
+	}
+
+	role, err := userRole(context.Background(), currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return Label{}, false
+	}
+	if role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you don't manage this label"})
+		return Label{}, false
+	}
+	return l, true
+}
+
+func writeEarningsCSV(c *gin.Context, l Label, earnings []artistEarnings, from, to time.Time) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="label-%d-earnings-%s-%s.csv"`,
+		l.ID, from.Format(dateLayout), to.Format(dateLayout)))
+
+	money := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"artist_id", "artist_name", "tips", "gross_amount", "fee_amount", "net_amount", "paid_out_amount"})
+	for _, e := range earnings {
+		w.Write([]string{e.ArtistID, e.DisplayName, strconv.FormatInt(e.Tips, 10),
+			money(e.Gross), money(e.Fees), money(e.Net), money(e.PaidOut)})
+	}
+	w.Flush()
+}
+
+// RegisterLabelRoutes defines label accounts, their roster, and earnings.
+func RegisterLabelRoutes(r *gin.Engine) {
+	// POST /labels — {"name":"..."}; label accounts only
+	r.POST("/labels", RequireAuth(), RequireRole("label"), func(c *gin.Context) {
+		var body struct {
+			Name string `json:"name"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		body.Name = strings.TrimSpace(body.Name)
+		if body.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+			return
+		}
+
+		l := Label{OwnerID: currentUserID(c), Name: body.Name}
+		err := db.QueryRow(context.Background(),
+			`INSERT INTO labels (owner_id, name) VALUES ($1, $2) RETURNING id, created_at;`,
+			l.OwnerID, l.Name,
+		).Scan(&l.ID, &l.CreatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, l)
+	})
+
+	// GET /labels/:id/artists — the roster
+	r.GET("/labels/:id/artists", RequireAuthOrAPIKey(), RequireScope(apiScopeSongsRead), func(c *gin.Context) {
+		l, ok := labelForOwner(c)
+		if !ok {
+			return
+		}
+
+		rows, err := db.Query(context.Background(), `
+			SELECT la.label_id, la.artist_id::text, COALESCE(p.display_name, ''), la.created_at
+			FROM label_artists la
+			LEFT JOIN profiles p ON p.id = la.artist_id
+			WHERE la.label_id = $1
+			ORDER BY la.created_at;
+		`, l.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		roster := []LabelArtist{}
+		for rows.Next() {
+			var a LabelArtist
+			if err := rows.Scan(&a.LabelID, &a.ArtistID, &a.DisplayName, &a.CreatedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			roster = append(roster, a)
+		}
+		c.JSON(http.StatusOK, roster)
+	})
+
+	// GET /labels/:id/earnings?from=&to=&format=json|csv
+	r.GET("/labels/:id/earnings", RequireAuthOrAPIKey(), RequireScope(apiScopeAnalyticsRead), func(c *gin.Context) {
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && format != "csv" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+			return
+		}
+		from, to, err := parseDateRange(c, defaultEarningsDays, maxEarningsDays)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		l, ok := labelForOwner(c)
+		if !ok {
+			return
+		}
+
+		earnings, err := loadLabelEarnings(context.Background(), l.ID, from, to.AddDate(0, 0, 1))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if format == "csv" {
+			writeEarningsCSV(c, l, earnings, from, to)
+			return
+		}
+
+		var totals artistEarnings
+		for _, e := range earnings {
+			totals.Tips += e.Tips
+			totals.Gross += e.Gross
+			totals.Fees += e.Fees
+			totals.Net += e.Net
+			totals.PaidOut += e.PaidOut
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"label":   l,
+			"from":    from.Format(dateLayout),
+			"to":      to.Format(dateLayout),
+			"artists": earnings,
+			"totals": gin.H{
+				"tips":            totals.Tips,
+				"gross_amount":    math.Round(totals.Gross*100) / 100,
+				"fee_amount":      math.Round(totals.Fees*100) / 100,
+				"net_amount":      math.Round(totals.Net*100) / 100,
+				"paid_out_amount": math.Round(totals.PaidOut*100) / 100,
+			},
+		})
+	})
+
+	admin := r.Group("/admin/labels", RequireAuth(), RequireRole("admin"), RequireMFA())
+
+	// PUT /admin/labels/:id/artists/:artist_id — signs an artist to the label
+	admin.PUT("/:id/artists/:artist_id", func(c *gin.Context) {
+		labelID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid label id"})
+			return
+		}
+
+		var exists bool
+		if err := db.QueryRow(context.Background(),
+			`SELECT EXISTS (SELECT 1 FROM labels WHERE id = $1);`, labelID).Scan(&exists); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "label not found"})
+			return
+		}
+
+		_, err = db.Exec(context.Background(), `
+			INSERT INTO label_artists (label_id, artist_id) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING;
+		`, labelID, c.Param("artist_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	// DELETE /admin/labels/:id/artists/:artist_id
+	admin.DELETE("/:id/artists/:artist_id", func(c *gin.Context) {
+		labelID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid label id"})
+			return
+		}
+
+		tag, err := db.Exec(context.Background(),
+			`DELETE FROM label_artists WHERE label_id = $1 AND artist_id::text = $2;`, labelID, c.Param("artist_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "artist is not on this label"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
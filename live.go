@@ -0,0 +1,530 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Artists go live through an external media server: creating a session
+// asks it for a stream, whose RTMP and WebRTC (WHIP) ingest details only
+// the artist sees, and listeners get its HLS and WebRTC (WHEP) playback
+// URLs while the session is live. Each session is backed by an unpublished
+// song, so tips during the session are ordinary tips on that song (balances,
+// review, and disputes work unchanged) and its ID can later carry the
+// recording. Listeners poll /live/:id/playback as a heartbeat, which counts
+// them; chat is polled too. Stats cover the session from start to end plus
+// a grace period for late tips.
+const (
+	liveScheduled = "scheduled"
+	liveLive      = "live"
+	liveEnded     = "ended"
+
+	maxLiveTitleLength    = 200
+	maxLiveChatLength     = 500
+	liveChatPageSize      = 100
+	liveListenerWindow    = time.Minute
+	liveTipGrace          = 15 * time.Minute
+	liveChatRatePerMinute = 20
+)
+
+// MediaServer creates and tears down live streams.
+type MediaServer interface {
+	CreateStream(ctx context.Context, name string) (liveStream, error)
+	EndStream(ctx context.Context, streamID string) error
+}
+
+// liveStream is what the media server returns for a new stream.
+type liveStream struct {
+	ID                string `json:"id"`
+	RTMPURL           string `json:"rtmp_url"`
+	StreamKey         string `json:"stream_key"`
+	WebRTCIngestURL   string `json:"webrtc_ingest_url"`
+	HLSPlaybackURL    string `json:"hls_playback_url"`
+	WebRTCPlaybackURL string `json:"webrtc_playback_url"`
+}
+
+// mediaServer is nil when live sessions are off.
+var mediaServer MediaServer
+
+// InitLiveStreaming selects the media server from LIVE_MEDIA_SERVER.
+func InitLiveStreaming() error {
+	switch cfg.LiveMediaServer {
+	case "":
+		return nil
+	case "http":
+		if cfg.LiveMediaServerURL == "" {
+			return errors.New("LIVE_MEDIA_SERVER=http needs LIVE_MEDIA_SERVER_URL, live sessions are off")
+		}
+		mediaServer = httpMediaServer{
+			url:  strings.TrimRight(cfg.LiveMediaServerURL, "/"),
+			key:  cfg.LiveMediaServerKey,
+			http: &http.Client{Timeout: 15 * time.Second},
+		}
+	default:
+		return fmt.Errorf("unknown LIVE_MEDIA_SERVER %q, live sessions are off", cfg.LiveMediaServer)
+	}
+	log.Printf("✅ Live sessions enabled with %s media server", cfg.LiveMediaServer)
+	return nil
+}
+
+// httpMediaServer calls a media server's stream API: POST /streams
+// {"name"} answers with a liveStream, DELETE /streams/:id stops it.
+type httpMediaServer struct {
+	url  string
+	key  string
+	http *http.Client
+}
+
+func (m httpMediaServer) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var rd io.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		rd = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, m.url+path, rd)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.key != "" {
+		req.Header.Set("Authorization", "Bearer "+m.key)
+	}
+
+	resp, err := m.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("media server returned %d: %s", resp.StatusCode, raw)
+	}
+	if out != nil {
+		return json.Unmarshal(raw, out)
+	}
+	return nil
+}
+
+func (m httpMediaServer) CreateStream(ctx context.Context, name string) (liveStream, error) {
+	var s liveStream
+	if err := m.do(ctx, http.MethodPost, "/streams", gin.H{"name": name}, &s); err != nil {
+		return liveStream{}, err
+	}
+	if s.ID == "" || (s.HLSPlaybackURL == "" && s.WebRTCPlaybackURL == "") {
+		return liveStream{}, errors.New("media server returned a stream without an id or playback URL")
+	}
+	return s, nil
+}
+
+func (m httpMediaServer) EndStream(ctx context.Context, streamID string) error {
+	return m.do(ctx, http.MethodDelete, "/streams/"+url.PathEscape(streamID), nil, nil)
+}
+
+const liveSessionColumns = `id, artist_id::text, song_id, title, status, scheduled_for, started_at, ended_at, peak_listeners,
+	stream_id, rtmp_url, stream_key, webrtc_ingest_url, hls_playback_url, webrtc_playback_url, created_at`
+
+// scanLiveSession scans liveSessionColumns; ingest details are kept only
+// for viewerID's own sessions and playback URLs only while live.
+func scanLiveSession(row pgx.Row, viewerID string) (LiveSession, error) {
+	var s LiveSession
+	var ingest LiveIngest
+	var streamID string
+	var hls, webrtc *string
+	err := row.Scan(&s.ID, &s.ArtistID, &s.SongID, &s.Title, &s.Status, &s.ScheduledFor, &s.StartedAt, &s.EndedAt,
+		&s.PeakListeners, &streamID, &ingest.RTMPURL, &ingest.StreamKey, &ingest.WebRTCURL, &hls, &webrtc, &s.CreatedAt)
+	if err != nil {
+		return s, err
+	}
+	if s.ArtistID == viewerID && s.Status != liveEnded {
+		s.Ingest = &ingest
+	}
+	if s.Status == liveLive || s.ArtistID == viewerID {
+		s.HLSPlaybackURL, s.WebRTCPlaybackURL = hls, webrtc
+	}
+	return s, nil
+}
+
+// loadLiveSession returns the session in :id, writing the 404 or 500.
+func loadLiveSession(c *gin.Context) (LiveSession, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session id"})
+		return LiveSession{}, false
+	}
+	s, err := scanLiveSession(db.QueryRow(context.Background(),
+		`SELECT `+liveSessionColumns+` FROM live_sessions WHERE id = $1;`, id), currentUserID(c))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "live session not found"})
+		return LiveSession{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return LiveSession{}, false
+	}
+	return s, true
+}
+
+// ownedLiveSession is loadLiveSession for the session's artist only.
+func ownedLiveSession(c *gin.Context) (LiveSession, bool) {
+	s, ok := loadLiveSession(c)
+	if ok && s.ArtistID != currentUserID(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only manage your own live sessions"})
+		return LiveSession{}, false
+	}
+	return s, ok
+}
+
+// liveListenerKey identifies a listener for the heartbeat count.
+func liveListenerKey(c *gin.Context) string {
+	if uid := currentUserID(c); uid != "" {
+		return "user:" + uid
+	}
+	return "anon:" + hashToken(c.ClientIP()+"|"+c.Request.UserAgent())
+}
+
+// recordLiveListener counts a heartbeat and raises the session's peak.
+func recordLiveListener(ctx context.Context, c *gin.Context, sessionID int64) error {
+	if _, err := db.Exec(ctx, `
+		INSERT INTO live_listeners (session_id, listener_key) VALUES ($1, $2)
+		ON CONFLICT (session_id, listener_key) DO UPDATE SET last_seen_at = now();
+	`, sessionID, liveListenerKey(c)); err != nil {
+		return err
+	}
+	_, err := db.Exec(ctx, `
+		UPDATE live_sessions SET peak_listeners = GREATEST(peak_listeners, (
+			SELECT COUNT(*) FROM live_listeners
+			WHERE session_id = $1 AND last_seen_at > now() - make_interval(secs => $2)
+		))
+		WHERE id = $1;
+	`, sessionID, liveListenerWindow.Seconds())
+	return err
+}
+
+// RegisterLiveRoutes defines live sessions, playback, chat, and stats.
+func RegisterLiveRoutes(r *gin.Engine) {
+	// POST /live {"title","scheduled_for"} — returns the session with its ingest details
+	r.POST("/live", RequireAuth(), func(c *gin.Context) {
+		if mediaServer == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "live sessions are not configured"})
+			return
+		}
+		var body struct {
+			Title        string     `json:"title"`
+			ScheduledFor *time.Time `json:"scheduled_for"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		title := strings.TrimSpace(body.Title)
+		if title == "" || utf8.RuneCountInString(title) > maxLiveTitleLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("title must be 1-%d characters", maxLiveTitleLength)})
+			return
+		}
+		ctx := c.Request.Context()
+		artistID := currentUserID(c)
+
+		stream, err := mediaServer.CreateStream(ctx, title)
+		if err != nil {
+			log.Printf("live session for %s: %v", artistID, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "could not create the stream"})
+			return
+		}
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		var songID int64
+		if err := tx.QueryRow(ctx, `
+			INSERT INTO songs (title, artist_id, published) VALUES ($1, $2, false) RETURNING id;
+		`, title, artistID).Scan(&songID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		s, err := scanLiveSession(tx.QueryRow(ctx, `
+			INSERT INTO live_sessions (artist_id, song_id, title, scheduled_for, stream_id, rtmp_url, stream_key,
+				webrtc_ingest_url, hls_playback_url, webrtc_playback_url)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''))
+			RETURNING `+liveSessionColumns+`;
+		`, artistID, songID, title, body.ScheduledFor, stream.ID, stream.RTMPURL, stream.StreamKey,
+			stream.WebRTCIngestURL, stream.HLSPlaybackURL, stream.WebRTCPlaybackURL), artistID)
+		if err == nil {
+			err = tx.Commit(ctx)
+		}
+		if err != nil {
+			if endErr := mediaServer.EndStream(context.Background(), stream.ID); endErr != nil {
+				log.Printf("live stream %s: failed to clean up: %v", stream.ID, endErr)
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, s)
+	})
+
+	// GET /live — sessions live now, most listeners first
+	r.GET("/live", func(c *gin.Context) {
+		rows, err := db.Query(context.Background(), `
+			SELECT `+liveSessionColumns+` FROM live_sessions
+			WHERE status = 'live'
+			ORDER BY peak_listeners DESC, started_at
+			LIMIT 100;
+		`)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		sessions := []LiveSession{}
+		for rows.Next() {
+			s, err := scanLiveSession(rows, "")
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			sessions = append(sessions, s)
+		}
+		c.JSON(http.StatusOK, sessions)
+	})
+
+	// GET /live/:id — the artist also sees ingest details
+	r.GET("/live/:id", OptionalAuth(), func(c *gin.Context) {
+		s, ok := loadLiveSession(c)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, s)
+	})
+
+	// POST /live/:id/start and /live/:id/end — the artist marks the session live or over
+	transition := func(from, to string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			s, ok := ownedLiveSession(c)
+			if !ok {
+				return
+			}
+			ctx := c.Request.Context()
+
+			set := `started_at = now()`
+			if to == liveEnded {
+				set = `ended_at = now(), started_at = COALESCE(started_at, now())`
+			}
+			updated, err := scanLiveSession(db.QueryRow(ctx, `
+				UPDATE live_sessions SET status = $2, `+set+`
+				WHERE id = $1 AND status = ANY ($3)
+				RETURNING `+liveSessionColumns+`;
+			`, s.ID, to, strings.Split(from, ",")), currentUserID(c))
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusConflict, gin.H{"error": "session is already " + s.Status})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			if to == liveEnded && mediaServer != nil {
+				var streamID string
+				if err := db.QueryRow(ctx, `SELECT stream_id FROM live_sessions WHERE id = $1;`, s.ID).Scan(&streamID); err == nil {
+					if err := mediaServer.EndStream(ctx, streamID); err != nil {
+						log.Printf("live session %d: failed to end stream %s: %v", s.ID, streamID, err)
+					}
+				}
+			}
+			c.JSON(http.StatusOK, updated)
+		}
+	}
+	r.POST("/live/:id/start", RequireAuth(), transition(liveScheduled, liveLive))
+	r.POST("/live/:id/end", RequireAuth(), transition(liveScheduled+","+liveLive, liveEnded))
+
+	// GET /live/:id/playback — playback URLs; poll every ~30s while listening, it counts you as a listener
+	r.GET("/live/:id/playback", OptionalAuth(), func(c *gin.Context) {
+		s, ok := loadLiveSession(c)
+		if !ok {
+			return
+		}
+		if s.Status != liveLive {
+			c.JSON(http.StatusConflict, gin.H{"error": "session is not live", "status": s.Status})
+			return
+		}
+		if classifyBot(context.Background(), c, 1).Action == "" {
+			if err := recordLiveListener(c.Request.Context(), c, s.ID); err != nil {
+				log.Printf("live session %d: failed to count listener: %v", s.ID, err)
+			}
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, gin.H{
+			"session_id":          s.ID,
+			"hls_playback_url":    s.HLSPlaybackURL,
+			"webrtc_playback_url": s.WebRTCPlaybackURL,
+			"tip_song_id":         s.SongID,
+			"heartbeat_seconds":   int(liveListenerWindow.Seconds() / 2),
+		})
+	})
+
+	// POST /live/:id/chat {"body"}
+	r.POST("/live/:id/chat", RequireAuth(), func(c *gin.Context) {
+		s, ok := loadLiveSession(c)
+		if !ok {
+			return
+		}
+		if s.Status != liveLive {
+			c.JSON(http.StatusConflict, gin.H{"error": "chat is open only while the session is live"})
+			return
+		}
+		var body struct {
+			Body string `json:"body"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		text := strings.TrimSpace(body.Body)
+		if text == "" || utf8.RuneCountInString(text) > maxLiveChatLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("message must be 1-%d characters", maxLiveChatLength)})
+			return
+		}
+		ctx := c.Request.Context()
+
+		var recent int
+		if err := db.QueryRow(ctx, `
+			SELECT COUNT(*) FROM live_chat_messages
+			WHERE session_id = $1 AND author_id = $2 AND created_at > now() - interval '1 minute';
+		`, s.ID, currentUserID(c)).Scan(&recent); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if recent >= liveChatRatePerMinute {
+			c.Header("Retry-After", "60")
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "you're sending messages too fast"})
+			return
+		}
+
+		var m LiveChatMessage
+		err := db.QueryRow(ctx, `
+			INSERT INTO live_chat_messages (session_id, author_id, body) VALUES ($1, $2, $3)
+			RETURNING id, session_id, author_id::text, body, created_at;
+		`, s.ID, currentUserID(c), text).Scan(&m.ID, &m.SessionID, &m.AuthorID, &m.Body, &m.CreatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, m)
+	})
+
+	// GET /live/:id/chat?after=<message id> — oldest first; poll with the last id seen
+	r.GET("/live/:id/chat", func(c *gin.Context) {
+		sessionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session id"})
+			return
+		}
+		after, _ := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+
+		rows, err := db.Query(context.Background(), `
+			SELECT id, session_id, author_id::text, body, created_at FROM live_chat_messages
+			WHERE session_id = $1 AND id > $2 AND hidden_at IS NULL
+			ORDER BY id
+			LIMIT $3;
+		`, sessionID, after, liveChatPageSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		messages := []LiveChatMessage{}
+		for rows.Next() {
+			var m LiveChatMessage
+			if err := rows.Scan(&m.ID, &m.SessionID, &m.AuthorID, &m.Body, &m.CreatedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			messages = append(messages, m)
+		}
+		c.JSON(http.StatusOK, messages)
+	})
+
+	// DELETE /live/:id/chat/:messageId — the artist or the author hides a message
+	r.DELETE("/live/:id/chat/:messageId", RequireAuth(), func(c *gin.Context) {
+		s, ok := loadLiveSession(c)
+		if !ok {
+			return
+		}
+		messageID, err := strconv.ParseInt(c.Param("messageId"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
+			return
+		}
+		tag, err := db.Exec(context.Background(), `
+			UPDATE live_chat_messages SET hidden_at = now(), hidden_by = $3
+			WHERE id = $1 AND session_id = $2 AND hidden_at IS NULL AND ($4 OR author_id = $3);
+		`, messageID, s.ID, currentUserID(c), s.ArtistID == currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	// GET /live/:id/stats — listeners, chat, and tips for the artist
+	r.GET("/live/:id/stats", RequireAuth(), func(c *gin.Context) {
+		s, ok := ownedLiveSession(c)
+		if !ok {
+			return
+		}
+		st := LiveSessionStats{SessionID: s.ID, Status: s.Status, PeakListeners: s.PeakListeners}
+		if s.StartedAt != nil {
+			end := time.Now()
+			if s.EndedAt != nil {
+				end = *s.EndedAt
+			}
+			st.DurationSeconds = int64(end.Sub(*s.StartedAt).Seconds())
+		}
+
+		err := db.QueryRow(c.Request.Context(), `
+			SELECT
+				(SELECT COUNT(*) FROM live_listeners WHERE session_id = $1),
+				(SELECT COUNT(*) FROM live_chat_messages WHERE session_id = $1),
+				(SELECT COUNT(DISTINCT author_id) FROM live_chat_messages WHERE session_id = $1),
+				COUNT(t.id),
+				COALESCE(SUM(t.amount) FILTER (WHERE t.review_status = 'cleared'), 0)::float8
+			FROM live_sessions ls
+			LEFT JOIN tips t ON t.song_id = ls.song_id
+				AND t.created_at >= COALESCE(ls.started_at, ls.created_at)
+				AND (ls.ended_at IS NULL OR t.created_at < ls.ended_at + make_interval(secs => $2))
+			WHERE ls.id = $1
+			GROUP BY ls.id;
+		`, s.ID, liveTipGrace.Seconds()).Scan(&st.UniqueListeners, &st.ChatMessages, &st.Chatters, &st.Tips, &st.TipTotal)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, st)
+	})
+}
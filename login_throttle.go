@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Password sign-ins are throttled per email and per client IP against
+// credential stuffing. Failures are counted in login_failures (the email is
+// stored hashed); past the free attempts each further failure locks that
+// email or IP out for twice as long as the last, up to an hour. A
+// successful sign-in clears the email's count but not the IP's, so an
+// attacker can't reset their budget by signing in to their own account.
+// Counts are forgotten after a day without failures.
+const (
+	loginFailureEmail = "email"
+	loginFailureIP    = "ip"
+
+	loginFreeFailuresEmail = 5
+	loginFreeFailuresIP    = 20
+	loginBaseLockout       = 30 * time.Second
+	loginMaxLockout        = time.Hour
+	loginFailureMemory     = 24 * time.Hour
+
+	loginFailurePruneName     = "login_failure_prune"
+	loginFailurePruneInterval = time.Hour
+)
+
+// loginLockout returns how long a subject with failures is locked out for.
+func loginLockout(kind string, failures int) time.Duration {
+	free := loginFreeFailuresEmail
+	if kind == loginFailureIP {
+		free = loginFreeFailuresIP
+	}
+	if failures < free {
+		return 0
+	}
+	d := float64(loginBaseLockout) * math.Pow(2, float64(failures-free))
+	if d > float64(loginMaxLockout) {
+		return loginMaxLockout
+	}
+	return time.Duration(d)
+}
+
+// loginSubjects are the (kind, subject) pairs a sign-in attempt counts
+// against.
+func loginSubjects(email, ip string) [][2]string {
+	return [][2]string{
+		{loginFailureEmail, hashToken(strings.ToLower(strings.TrimSpace(email)))},
+		{loginFailureIP, ip},
+	}
+}
+
+// loginRetryAfter returns how long until email may try again from ip, or 0.
+func loginRetryAfter(ctx context.Context, email, ip string) (time.Duration, error) {
+	var wait time.Duration
+	for _, s := range loginSubjects(email, ip) {
+		var until time.Time
+		err := db.QueryRow(ctx, `
+			SELECT locked_until FROM login_failures
+			WHERE kind = $1 AND subject = $2 AND locked_until > now();
+		`, s[0], s[1]).Scan(&until)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if d := time.Until(until); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// recordLoginFailure counts a failed sign-in and returns the lockout it
+// triggers, or 0.
+func recordLoginFailure(ctx context.Context, email, ip string) (time.Duration, error) {
+	var wait time.Duration
+	for _, s := range loginSubjects(email, ip) {
+		var failures int
+		err := db.QueryRow(ctx, `
+			INSERT INTO login_failures (kind, subject, failures, last_failure_at)
+			VALUES ($1, $2, 1, now())
+			ON CONFLICT (kind, subject) DO UPDATE SET
+				failures = CASE WHEN login_failures.last_failure_at < now() - make_interval(secs => $3)
+					THEN 1 ELSE login_failures.failures + 1 END,
+				last_failure_at = now()
+			RETURNING failures;
+		`, s[0], s[1], loginFailureMemory.Seconds()).Scan(&failures)
+		if err != nil {
+			return 0, err
+		}
+		d := loginLockout(s[0], failures)
+		if d == 0 {
+			continue
+		}
+		if _, err := db.Exec(ctx, `
+			UPDATE login_failures SET locked_until = now() + make_interval(secs => $3)
+			WHERE kind = $1 AND subject = $2;
+		`, s[0], s[1], d.Seconds()); err != nil {
+			return 0, err
+		}
+		if d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// clearLoginFailures forgets email's failures after a successful sign-in.
+func clearLoginFailures(ctx context.Context, email string) error {
+	s := loginSubjects(email, "")[0]
+	_, err := db.Exec(ctx, `DELETE FROM login_failures WHERE kind = $1 AND subject = $2;`, s[0], s[1])
+	return err
+}
+
+// pruneLoginFailures drops counts that have been forgotten.
+func pruneLoginFailures(ctx context.Context) error {
+	_, err := db.Exec(ctx, `
+		DELETE FROM login_failures
+		WHERE last_failure_at < now() - make_interval(secs => $1)
+		  AND (locked_until IS NULL OR locked_until < now());
+	`, loginFailureMemory.Seconds())
+	return err
+}
+
+// writeLoginLocked writes the 429 for a locked-out email or IP.
+func writeLoginLocked(c *gin.Context, wait time.Duration) {
+	secs := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(secs))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "too many failed sign-in attempts; try again later",
+		"code":        "login_locked",
+		"retry_after": secs,
+	})
+}
+
+// checkPassword signs email in with password through Supabase Auth under
+// the sign-in throttle. It writes the error response (429 when locked out,
+// 401 with code rejectedCode for a wrong password) and returns false on
+// failure.
+func checkPassword(c *gin.Context, email, password, rejectedCode, rejectedMsg string) (AuthResponse, bool) {
+	ctx := c.Request.Context()
+	ip := c.ClientIP()
+
+	wait, err := loginRetryAfter(ctx, email, ip)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return AuthResponse{}, false
+	}
+	if wait > 0 {
+		writeLoginLocked(c, wait)
+		return AuthResponse{}, false
+	}
+
+	session, err := supabaseToken(ctx, "password", gin.H{"email": email, "password": password})
+	if errors.Is(err, errGrantRejected) {
+		wait, err := recordLoginFailure(ctx, email, ip)
+		if err != nil {
+			log.Printf("sign-in throttle: %v", err)
+		}
+		if wait > 0 {
+			writeLoginLocked(c, wait)
+			return AuthResponse{}, false
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": rejectedMsg, "code": rejectedCode})
+		return AuthResponse{}, false
+	}
+	if err != nil {
+		log.Printf("password sign-in: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "could not reach the auth provider"})
+		return AuthResponse{}, false
+	}
+	if err := clearLoginFailures(ctx, email); err != nil {
+		log.Printf("sign-in throttle: %v", err)
+	}
+	return session, true
+}
+
+// RegisterLoginRoutes defines POST /auth/login.
+func RegisterLoginRoutes(r *gin.Engine) {
+	// POST /auth/login {"email","password"} — returns a session, or 429 with retry_after when locked out
+	r.POST("/auth/login", func(c *gin.Context) {
+		if !supabaseConfigured() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "auth is not configured"})
+			return
+		}
+		var body struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		email := strings.TrimSpace(body.Email)
+		if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email || body.Password == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "email and password are required"})
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		session, ok := checkPassword(c, email, body.Password, "invalid_credentials", "email or password is incorrect")
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, session)
+	})
+}
@@ -2,14 +2,15 @@ package main
 
 import (
 	"context"
+	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 type createProjectInput struct {
-	OwnerID string `json:"owner_id"`
-	Title   string `json:"title"`
+	Title string `json:"title"`
 }
 
 type inviteInput struct {
@@ -18,20 +19,50 @@ type inviteInput struct {
 }
 
 func main() {
-	// Connect DB
-	InitDB()
+	// Load config, connect services, and start background work
+	Startup(context.Background())
 
 	r := gin.Default()
+	// Rate limits and lockouts key on ClientIP, so only configured proxies
+	// may set it through X-Forwarded-For.
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("❌ Invalid TRUSTED_PROXIES: %v", err)
+	}
+	r.Use(ValidateOpenAPI())
 
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"ok": true, "message": "Server running and DB connected"})
 	})
+	RegisterOpenAPIRoutes(r)
+
+	// ------------------------
+	// AUTH
+	// ------------------------
+	RegisterAuthRoutes(r)
+	RegisterLoginRoutes(r)
+	RegisterSocialLoginRoutes(r)
+	RegisterMFARoutes(r)
+	RegisterSessionRoutes(r)
+	RegisterCredentialRoutes(r)
+	RegisterProfileRoutes(r)
+	RegisterConsentRoutes(r)
+	RegisterAgeRoutes(r)
+	RegisterHandleRoutes(r)
+	RegisterSimilarArtistRoutes(r)
+	RegisterVerificationRoutes(r)
+	RegisterImpersonationRoutes(r)
+	RegisterRoleRoutes(r)
+	RegisterAccountLinkRoutes(r)
+	RegisterFollowRoutes(r)
+	RegisterRepostRoutes(r)
+	RegisterPrivacyRoutes(r)
 
 	// ------------------------
 	// PROJECTS
 	// ------------------------
-	r.POST("/projects", func(c *gin.Context) {
+	// POST /projects — the caller owns the new project
+	r.POST("/projects", RequireAuth(), func(c *gin.Context) {
 		var body createProjectInput
 		if err := c.BindJSON(&body); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
@@ -46,7 +77,7 @@ func main() {
 
 		var p Project
 		err := db.QueryRow(context.Background(), sql,
-			body.OwnerID, body.Title,
+			currentUserID(c), body.Title,
 		).Scan(&p.ID, &p.OwnerID, &p.Title, &p.CreatedAt)
 
 		if err != nil {
@@ -57,15 +88,58 @@ func main() {
 		c.JSON(http.StatusCreated, p)
 	})
 
+	RegisterProjectRoutes(r)
+	RegisterGuestRoutes(r)
+	RegisterStemCommentRoutes(r)
+	RegisterProjectMessageRoutes(r)
+	RegisterAttachmentRoutes(r)
+	RegisterExportRoutes(r)
+	RegisterProjectReleaseRoutes(r)
+
+	// ------------------------
+	// SONGS
+	// ------------------------
+	RegisterSongRoutes(r)
+	RegisterTranscriptRoutes(r)
+	RegisterClipRoutes(r)
+	RegisterPressKitRoutes(r)
+	RegisterShowRoutes(r)
+	RegisterLiveRoutes(r)
+	RegisterAssetRoutes(r)
+	RegisterProfileImageRoutes(r)
+	RegisterUploadRoutes(r)
+	RegisterUploadTokenRoutes(r)
+	RegisterAudioRoutes(r)
+	RegisterCommentRoutes(r)
+	RegisterBadgeRoutes(r)
+	RegisterPresenceRoutes(r)
+	RegisterTrashRoutes(r)
+	RegisterAnnouncementRoutes(r)
+	RegisterContestRoutes(r)
+
+	// ------------------------
+	// RELEASES
+	// ------------------------
+	RegisterReleaseRoutes(r)
+	RegisterImportRoutes(r)
+	RegisterCatalogRoutes(r)
+	RegisterSmartLinkRoutes(r)
+	RegisterSEORoutes(r)
+	RegisterSitemapRoutes(r)
+
 	// ------------------------
 	// INVITES
 	// ------------------------
-	r.POST("/invite", func(c *gin.Context) {
+	// POST /invite — only the project's owner can invite
+	r.POST("/invite", RequireAuth(), func(c *gin.Context) {
 		var body inviteInput
 		if err := c.BindJSON(&body); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
 			return
 		}
+		if !requireProjectOwner(c, body.ProjectID) {
+			return
+		}
 
 		sql := `
 			INSERT INTO project_invitations (project_id, invitee_id)
@@ -73,50 +147,31 @@ func main() {
 			RETURNING id, project_id, invitee_id, created_at;
 		`
 
-		var inv ProjectInvitation
-		err := db.QueryRow(context.Background(), sql,
-			body.ProjectID, body.InviteeID,
-		).Scan(&inv.ID, &inv.ProjectID, &inv.InviteeID, &inv.CreatedAt)
-
+		ctx := context.Background()
+		tx, err := db.Begin(ctx)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		defer tx.Rollback(ctx)
 
-		c.JSON(http.StatusCreated, inv)
-	})
-
-	// ------------------------
-	// COMMENTS
-	// ------------------------
-	r.POST("/comments", func(c *gin.Context) {
-		var body Comment
-		if err := c.BindJSON(&body); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
-			return
+		var inv ProjectInvitation
+		err = tx.QueryRow(ctx, sql,
+			body.ProjectID, body.InviteeID,
+		).Scan(&inv.ID, &inv.ProjectID, &inv.InviteeID, &inv.CreatedAt)
+		if err == nil {
+			err = appendDomainEvent(ctx, tx, domainMemberAdded, "project", strconv.FormatInt(inv.ProjectID, 10), currentUserID(c),
+				gin.H{"invitation_id": inv.ID, "user_id": inv.InviteeID})
+		}
+		if err == nil {
+			err = tx.Commit(ctx)
 		}
-
-		sql := `INSERT INTO comments (song_id, author_id, body)
-		        VALUES ($1, $2, $3)
-		        RETURNING id, song_id, author_id, body, created_at;`
-
-		err := db.QueryRow(context.Background(), sql,
-			body.SongID, body.AuthorID, body.Body,
-		).Scan(&body.ID, &body.SongID, &body.AuthorID, &body.Body, &body.CreatedAt)
-
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		// Record engagement event
-		eventSQL := `
-			INSERT INTO events (song_id, user_id, event_type)
-			VALUES ($1, $2, $3);
-		`
-		db.Exec(context.Background(), eventSQL, body.SongID, body.AuthorID, "comment")
-
-		c.JSON(http.StatusCreated, body)
+		c.JSON(http.StatusCreated, inv)
 	})
 
 	// ------------------------
@@ -148,11 +203,7 @@ func main() {
 		}
 
 		// Record engagement event
-		eventSQL := `
-			INSERT INTO events (song_id, user_id, event_type)
-			VALUES ($1, $2, $3);
-		`
-		db.Exec(context.Background(), eventSQL, body.SongID, body.ReviewerID, "review")
+		recordServerEvent(body.SongID, body.ReviewerID, "review")
 
 		c.JSON(http.StatusCreated, body)
 	})
@@ -160,45 +211,67 @@ func main() {
 	// ------------------------
 	// TIPS
 	// ------------------------
-	r.POST("/tips", func(c *gin.Context) {
-		var body Tip
-		if err := c.BindJSON(&body); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
-			return
-		}
+	RegisterTipRoutes(r)
+	RegisterTaxRoutes(r)
+	RegisterDisputeRoutes(r)
+	RegisterEscrowRoutes(r)
 
-		if body.Amount <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be > 0"})
-			return
-		}
-
-		sql := `INSERT INTO tips (song_id, sender_id, amount)
-		        VALUES ($1, $2, $3)
-		        RETURNING id, song_id, sender_id, amount, created_at;`
-
-		err := db.QueryRow(context.Background(), sql,
-			body.SongID, body.SenderID, body.Amount,
-		).Scan(&body.ID, &body.SongID, &body.SenderID, &body.Amount, &body.CreatedAt)
-
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+	// ------------------------
+	// PAYOUTS & WEBHOOKS
+	// ------------------------
+	RegisterPayoutRoutes(r)
+	RegisterLabelRoutes(r)
+	RegisterWebhookRoutes(r)
 
-		// Record engagement event
-		eventSQL := `
-			INSERT INTO events (song_id, user_id, event_type)
-			VALUES ($1, $2, $3);
-		`
-		db.Exec(context.Background(), eventSQL, body.SongID, body.SenderID, "tip")
+	// ------------------------
+	// INTEGRATIONS
+	// ------------------------
+	RegisterAPIKeyRoutes(r)
+	RegisterOAuthClientRoutes(r)
+	RegisterTeamRoutes(r)
+	RegisterIntegrationRoutes(r)
+	RegisterDiscordRoutes(r)
+	RegisterSocialRoutes(r)
 
-		c.JSON(http.StatusCreated, body)
-	})
+	// ------------------------
+	// EVENTS
+	// ------------------------
+	RegisterEventRoutes(r)
+	RegisterBeaconRoutes(r)
+	RegisterBotRoutes(r)
+	RegisterDomainEventRoutes(r)
+	RegisterModerationRoutes(r)
+	RegisterOpsRoutes(r)
 
 	// ------------------------
 	// ANALYTICS
 	// ------------------------
 	RegisterAnalyticsRoutes(r)
+	RegisterUniqueListenerRoutes(r)
+	RegisterFunnelRoutes(r)
+	RegisterExperimentRoutes(r)
+	RegisterPlatformStatsRoutes(r)
+	RegisterBackfillRoutes(r)
+	RegisterArchiveRoutes(r)
+
+	// ------------------------
+	// NOTIFICATIONS & ALERTS
+	// ------------------------
+	RegisterNotificationRoutes(r)
+	RegisterAlertRoutes(r)
+
+	// ------------------------
+	// SEARCH
+	// ------------------------
+	RegisterSearchRoutes(r)
+	RegisterCollectionRoutes(r)
+	RegisterHomeRoutes(r)
+	RegisterWeeklyPlaylistRoutes(r)
+	RegisterRelatedSongRoutes(r)
+	RegisterTrendingRoutes(r)
+	RegisterSongBatchRoutes(r)
+	RegisterDownloadRoutes(r)
+	RegisterAudioVersionRoutes(r)
 
 	// Run server
 	r.Run(":8080")
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Two-factor authentication uses TOTP (RFC 6238: SHA-1, 30-second steps, six
+// digits), which any authenticator app supports. Enrolling returns the
+// secret and an otpauth:// URL for the app to show as a QR code; verifying
+// the first code turns it on. A passed challenge returns an MFA token that
+// the client sends as X-MFA-Token alongside its access token. RequireMFA
+// guards the admin API and payout settings.
+const (
+	totpIssuer       = "Leep"
+	totpPeriod       = 30
+	totpDigits       = 6
+	totpSkewSteps    = 1
+	mfaSessionTTL    = 12 * time.Hour
+	mfaMaxFailures   = 5
+	mfaLockout       = 5 * time.Minute
+	mfaTokenHeader   = "X-MFA-Token"
+	mfaTokenPrefix   = "mfa_"
+	totpSecretLength = 20
+)
+
+var (
+	totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	errMFANotEnrolled = errors.New("two-factor authentication is not enrolled")
+	errMFALocked      = errors.New("too many wrong codes; try again in a few minutes")
+	errMFAInvalidCode = errors.New("invalid code")
+)
+
+// totpCode is the code for a time step.
+func totpCode(secret []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	n := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, n%1000000)
+}
+
+// totpMatch returns the step code matches, allowing one step of clock skew,
+// or 0 when it matches none after lastStep.
+func totpMatch(secret []byte, code string, now time.Time, lastStep int64) int64 {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpDigits {
+		return 0
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkewSteps; step <= current+totpSkewSteps; step++ {
+		if step > lastStep && subtle.ConstantTimeCompare([]byte(totpCode(secret, step)), []byte(code)) == 1 {
+			return step
+		}
+	}
+	return 0
+}
+
+// totpURL is the otpauth:// URL authenticator apps read from a QR code.
+func totpURL(account, secret string) string {
+	q := url.Values{
+		"secret":    {secret},
+		"issuer":    {totpIssuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(totpDigits)},
+		"period":    {fmt.Sprint(totpPeriod)},
+	}
+	label := url.PathEscape(totpIssuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// checkMFACode verifies code against the user's secret, recording the step
+// so it can't be replayed and counting failures toward a lockout. With
+// enrolling set it checks a secret that isn't enabled yet and enables it.
+func checkMFACode(ctx context.Context, userID, code string, enrolling bool) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		secret      string
+		enabledAt   *time.Time
+		lastStep    int64
+		lockedUntil *time.Time
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT secret, enabled_at, last_step, locked_until FROM user_mfa WHERE user_id = $1 FOR UPDATE;
+	`, userID).Scan(&secret, &enabledAt, &lastStep, &lockedUntil)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && (enabledAt == nil) != enrolling) {
+		return errMFANotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+	if lockedUntil != nil && time.Now().Before(*lockedUntil) {
+		return errMFALocked
+	}
+
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		return err
+	}
+	step := totpMatch(key, code, time.Now(), lastStep)
+	if step == 0 {
+		// The failure count is kept even though the code was wrong.
+		if _, err := tx.Exec(ctx, `
+			UPDATE user_mfa
+			SET failed_attempts = failed_attempts + 1,
+			    locked_until = CASE WHEN failed_attempts + 1 >= $2 THEN $3 END,
+			    updated_at = now()
+			WHERE user_id = $1;
+		`, userID, mfaMaxFailures, time.Now().Add(mfaLockout)); err != nil {
+			return err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return err
+		}
+		return errMFAInvalidCode
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE user_mfa
+		SET last_step = $2, failed_attempts = 0, locked_until = NULL,
+		    enabled_at = COALESCE(enabled_at, now()), updated_at = now()
+		WHERE user_id = $1;
+	`, userID, step); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// issueMFAToken stores a new MFA token for the caller's session.
+func issueMFAToken(c *gin.Context) (string, time.Time, error) {
+	token, err := newOpaqueToken(mfaTokenPrefix)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	claims := c.MustGet("claims").(*Claims)
+	expiresAt := time.Now().Add(mfaSessionTTL)
+
+	ctx := c.Request.Context()
+	if _, err := db.Exec(ctx, `DELETE FROM mfa_sessions WHERE expires_at < now();`); err != nil {
+		return "", time.Time{}, err
+	}
+	_, err = db.Exec(ctx, `
+		INSERT INTO mfa_sessions (token_hash, user_id, session_id, expires_at) VALUES ($1, $2, $3, $4);
+	`, hashToken(token), claims.Subject, claims.SessionID, expiresAt)
+	return token, expiresAt, err
+}
+
+// writeMFAError writes a failed code check.
+func writeMFAError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errMFANotEnrolled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "mfa_not_enrolled"})
+	case errors.Is(err, errMFALocked):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "code": "mfa_locked"})
+	case errors.Is(err, errMFAInvalidCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "code": "invalid_code"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// RequireMFA rejects callers without a valid X-MFA-Token for their session.
+// Callers who haven't enrolled get code "mfa_enrollment_required"; enrolled
+// callers without a token get "mfa_required". Use after RequireAuth.
+func RequireMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := c.MustGet("claims").(*Claims)
+		ctx := context.Background()
+
+		var enabled bool
+		err := db.QueryRow(ctx,
+			`SELECT enabled_at IS NOT NULL FROM user_mfa WHERE user_id = $1;`, claims.Subject).Scan(&enabled)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !enabled {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "two-factor authentication must be enabled for this", "code": "mfa_enrollment_required"})
+			return
+		}
+
+		var ok bool
+		if token := c.GetHeader(mfaTokenHeader); token != "" {
+			err = db.QueryRow(ctx, `
+				SELECT EXISTS (
+					SELECT 1 FROM mfa_sessions
+					WHERE token_hash = $1 AND user_id = $2 AND session_id = $3 AND expires_at > now()
+				);
+			`, hashToken(token), claims.Subject, claims.SessionID).Scan(&ok)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "a two-factor challenge is required", "code": "mfa_required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RegisterMFARoutes defines /auth/mfa.
+func RegisterMFARoutes(r *gin.Engine) {
+	m := r.Group("/auth/mfa", RequireAuth(), func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		c.Next()
+	})
+
+	codeBody := func(c *gin.Context) (string, bool) {
+		var body struct {
+			Code string `json:"code"`
+		}
+		if err := c.BindJSON(&body); err != nil || body.Code == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+			return "", false
+		}
+		return body.Code, true
+	}
+
+	// GET /auth/mfa — {"enabled":true,"enabled_at":"..."}
+	m.GET("", func(c *gin.Context) {
+		var enabledAt *time.Time
+		err := db.QueryRow(context.Background(),
+			`SELECT enabled_at FROM user_mfa WHERE user_id = $1;`, currentUserID(c)).Scan(&enabledAt)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"enabled": enabledAt != nil, "enabled_at": enabledAt})
+	})
+
+	// POST /auth/mfa/enroll — a new secret and its otpauth_url for a QR code; replaces an unverified one
+	m.POST("/enroll", func(c *gin.Context) {
+		raw := make([]byte, totpSecretLength)
+		if _, err := rand.Read(raw); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		secret := totpEncoding.EncodeToString(raw)
+
+		tag, err := db.Exec(context.Background(), `
+			INSERT INTO user_mfa (user_id, secret) VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET
+				secret = EXCLUDED.secret, last_step = 0, failed_attempts = 0, locked_until = NULL, updated_at = now()
+			WHERE user_mfa.enabled_at IS NULL;
+		`, currentUserID(c), secret)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is already enabled"})
+			return
+		}
+
+		account := c.MustGet("claims").(*Claims).Email
+		if account == "" {
+			account = currentUserID(c)
+		}
+		c.JSON(http.StatusOK, gin.H{"secret": secret, "otpauth_url": totpURL(account, secret)})
+	})
+
+	// POST /auth/mfa/verify — {"code":"123456"}; enables MFA and returns an mfa_token
+	m.POST("/verify", func(c *gin.Context) {
+		code, ok := codeBody(c)
+		if !ok {
+			return
+		}
+		if err := checkMFACode(c.Request.Context(), currentUserID(c), code, true); err != nil {
+			writeMFAError(c, err)
+			return
+		}
+		token, expiresAt, err := issueMFAToken(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"enabled": true, "mfa_token": token, "expires_at": expiresAt})
+	})
+
+	// POST /auth/mfa/challenge — {"code":"123456"}; returns an mfa_token to send as X-MFA-Token
+	m.POST("/challenge", func(c *gin.Context) {
+		code, ok := codeBody(c)
+		if !ok {
+			return
+		}
+		if err := checkMFACode(c.Request.Context(), currentUserID(c), code, false); err != nil {
+			writeMFAError(c, err)
+			return
+		}
+		token, expiresAt, err := issueMFAToken(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"mfa_token": token, "expires_at": expiresAt})
+	})
+
+	// DELETE /auth/mfa — {"code":"123456"}; turns MFA off
+	m.DELETE("", func(c *gin.Context) {
+		code, ok := codeBody(c)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+		if err := checkMFACode(ctx, currentUserID(c), code, false); err != nil {
+			writeMFAError(c, err)
+			return
+		}
+		if _, err := db.Exec(ctx, `DELETE FROM user_mfa WHERE user_id = $1;`, currentUserID(c)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := db.Exec(ctx, `DELETE FROM mfa_sessions WHERE user_id = $1;`, currentUserID(c)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
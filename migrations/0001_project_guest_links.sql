@@ -0,0 +1,16 @@
+-- Read-only guest links to a project (e.g. for an A&R contact).
+-- Only a SHA-256 of the token is stored; the raw token is shown once.
+CREATE TABLE IF NOT EXISTS project_guest_links (
+    id           BIGSERIAL PRIMARY KEY,
+    project_id   BIGINT NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    token_hash   TEXT NOT NULL UNIQUE,
+    label        TEXT NOT NULL DEFAULT '',
+    created_by   UUID NOT NULL,
+    expires_at   TIMESTAMPTZ NOT NULL,
+    revoked_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS project_guest_links_project_id_idx
+    ON project_guest_links (project_id);
@@ -0,0 +1,49 @@
+-- Background job queue polled by the worker in jobs.go.
+CREATE TABLE IF NOT EXISTS jobs (
+    id          BIGSERIAL PRIMARY KEY,
+    type        TEXT NOT NULL,
+    payload     JSONB NOT NULL DEFAULT '{}',
+    status      TEXT NOT NULL DEFAULT 'queued'
+                CHECK (status IN ('queued', 'running', 'done', 'failed')),
+    progress    INT NOT NULL DEFAULT 0,
+    result      JSONB,
+    error       TEXT,
+    attempts    INT NOT NULL DEFAULT 0,
+    run_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_by  UUID,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS jobs_ready_idx ON jobs (run_at) WHERE status = 'queued';
+
+-- Project workspace tables read by the export bundle.
+CREATE TABLE IF NOT EXISTS project_stems (
+    id           BIGSERIAL PRIMARY KEY,
+    project_id   BIGINT NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    uploader_id  UUID NOT NULL,
+    filename     TEXT NOT NULL,
+    file_key     TEXT NOT NULL,
+    size_bytes   BIGINT NOT NULL DEFAULT 0,
+    content_type TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS project_messages (
+    id         BIGSERIAL PRIMARY KEY,
+    project_id BIGINT NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    author_id  UUID NOT NULL,
+    body       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS project_tasks (
+    id           BIGSERIAL PRIMARY KEY,
+    project_id   BIGINT NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    title        TEXT NOT NULL,
+    assignee_id  UUID,
+    created_by   UUID NOT NULL,
+    completed_at TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
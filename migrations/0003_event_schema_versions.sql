@@ -0,0 +1,8 @@
+-- Versioned client event ingestion (POST /events).
+ALTER TABLE events ADD COLUMN IF NOT EXISTS schema_version INT NOT NULL DEFAULT 1;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS occurred_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE events ADD COLUMN IF NOT EXISTS properties JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE events ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE events ALTER COLUMN user_id DROP NOT NULL;
+
+CREATE INDEX IF NOT EXISTS events_song_id_occurred_at_idx ON events (song_id, occurred_at);
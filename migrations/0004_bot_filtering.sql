@@ -0,0 +1,20 @@
+-- Bot and crawler filtering on event ingestion.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS is_bot BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS bot_rules (
+    id         BIGSERIAL PRIMARY KEY,
+    kind       TEXT NOT NULL CHECK (kind IN ('user_agent', 'ip')),
+    pattern    TEXT NOT NULL,
+    action     TEXT NOT NULL DEFAULT 'tag' CHECK (action IN ('tag', 'drop')),
+    enabled    BOOLEAN NOT NULL DEFAULT true,
+    note       TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO bot_rules (kind, pattern, action, note) VALUES
+    ('user_agent', 'bot', 'tag', 'generic bots'),
+    ('user_agent', 'crawler', 'tag', 'generic crawlers'),
+    ('user_agent', 'spider', 'tag', 'generic spiders'),
+    ('user_agent', 'headlesschrome', 'tag', 'headless browsers'),
+    ('user_agent', 'curl/', 'drop', 'scripted clients'),
+    ('user_agent', 'python-requests', 'drop', 'scripted clients');
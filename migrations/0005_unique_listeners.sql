@@ -0,0 +1,16 @@
+-- HyperLogLog sketches of unique listeners per song per UTC day.
+CREATE TABLE IF NOT EXISTS song_daily_uniques (
+    song_id    BIGINT NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    day        DATE NOT NULL,
+    registers  BYTEA NOT NULL,
+    estimate   BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (song_id, day)
+);
+
+-- Progress markers for incremental rollups over the events table.
+CREATE TABLE IF NOT EXISTS rollup_watermarks (
+    name          TEXT PRIMARY KEY,
+    last_event_id BIGINT NOT NULL DEFAULT 0,
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,21 @@
+-- Privacy-scrubbed search logs. No user identifier is stored; search_id
+-- only links a result click back to the query that produced it.
+CREATE TABLE IF NOT EXISTS search_queries (
+    id           BIGSERIAL PRIMARY KEY,
+    search_id    UUID NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    query        TEXT NOT NULL,
+    result_count INT NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS search_queries_created_at_idx ON search_queries (created_at);
+
+CREATE TABLE IF NOT EXISTS search_clicks (
+    id         BIGSERIAL PRIMARY KEY,
+    search_id  UUID NOT NULL REFERENCES search_queries (search_id) ON DELETE CASCADE,
+    song_id    BIGINT NOT NULL,
+    position   INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS search_clicks_search_id_idx ON search_clicks (search_id);
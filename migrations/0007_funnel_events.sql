@@ -0,0 +1,16 @@
+-- First occurrence of each activation funnel step per subject.
+CREATE TABLE IF NOT EXISTS funnel_events (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      UUID,
+    anonymous_id TEXT,
+    step         TEXT NOT NULL,
+    occurred_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (user_id IS NOT NULL OR anonymous_id IS NOT NULL)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS funnel_events_user_step_idx
+    ON funnel_events (user_id, step) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS funnel_events_anon_step_idx
+    ON funnel_events (anonymous_id, step) WHERE user_id IS NULL;
+CREATE INDEX IF NOT EXISTS funnel_events_step_occurred_at_idx
+    ON funnel_events (step, occurred_at);
@@ -0,0 +1,21 @@
+-- A/B experiments. Assignment is computed from a hash of key + user ID,
+-- so only exposures are stored.
+CREATE TABLE IF NOT EXISTS experiments (
+    id              BIGSERIAL PRIMARY KEY,
+    key             TEXT NOT NULL UNIQUE,
+    description     TEXT NOT NULL DEFAULT '',
+    status          TEXT NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'running', 'stopped')),
+    traffic_percent INT NOT NULL DEFAULT 100 CHECK (traffic_percent BETWEEN 0 AND 100),
+    variants        JSONB NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS experiment_exposures (
+    experiment_id    BIGINT NOT NULL REFERENCES experiments (id) ON DELETE CASCADE,
+    user_id          UUID NOT NULL,
+    variant          TEXT NOT NULL,
+    first_exposed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (experiment_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS events_user_id_occurred_at_idx ON events (user_id, occurred_at);
@@ -0,0 +1,50 @@
+-- Songs are owned by the artist who uploaded them.
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS artist_id UUID;
+CREATE INDEX IF NOT EXISTS songs_artist_id_idx ON songs (artist_id);
+
+-- Per-song, per-UTC-day engagement counts rolled up from events (bots excluded).
+CREATE TABLE IF NOT EXISTS song_daily_stats (
+    song_id    BIGINT NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    day        DATE NOT NULL,
+    plays      BIGINT NOT NULL DEFAULT 0,
+    skips      BIGINT NOT NULL DEFAULT 0,
+    likes      BIGINT NOT NULL DEFAULT 0,
+    shares     BIGINT NOT NULL DEFAULT 0,
+    comments   BIGINT NOT NULL DEFAULT 0,
+    reviews    BIGINT NOT NULL DEFAULT 0,
+    tips       BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (song_id, day)
+);
+
+CREATE INDEX IF NOT EXISTS song_daily_stats_day_idx ON song_daily_stats (day);
+
+-- In-app notifications.
+CREATE TABLE IF NOT EXISTS notifications (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    UUID NOT NULL,
+    kind       TEXT NOT NULL,
+    title      TEXT NOT NULL,
+    body       TEXT NOT NULL DEFAULT '',
+    data       JSONB NOT NULL DEFAULT '{}',
+    read_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS notifications_user_id_created_at_idx ON notifications (user_id, created_at DESC);
+
+-- Artist-configured rate-of-change alerts.
+CREATE TABLE IF NOT EXISTS artist_alerts (
+    id                 BIGSERIAL PRIMARY KEY,
+    user_id            UUID NOT NULL,
+    song_id            BIGINT REFERENCES songs (id) ON DELETE CASCADE,
+    metric             TEXT NOT NULL,
+    condition          TEXT NOT NULL CHECK (condition IN ('drop_percent', 'rise_percent', 'above', 'below')),
+    threshold          DOUBLE PRECISION NOT NULL CHECK (threshold > 0),
+    enabled            BOOLEAN NOT NULL DEFAULT true,
+    last_evaluated_day DATE,
+    last_triggered_day DATE,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS artist_alerts_user_id_idx ON artist_alerts (user_id);
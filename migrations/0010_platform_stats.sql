@@ -0,0 +1,8 @@
+-- Single-row cache of headline numbers for the public /stats endpoint.
+CREATE TABLE IF NOT EXISTS platform_stats (
+    id              BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    total_songs     BIGINT NOT NULL,
+    total_artists   BIGINT NOT NULL,
+    plays_this_week BIGINT NOT NULL,
+    computed_at     TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,2 @@
+-- The archival job deletes old events by (occurred_at, id).
+CREATE INDEX IF NOT EXISTS events_occurred_at_idx ON events (occurred_at);
@@ -0,0 +1,43 @@
+-- Artist-registered webhook endpoints. An empty events array subscribes to everything.
+CREATE TABLE IF NOT EXISTS artist_webhooks (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    UUID NOT NULL,
+    url        TEXT NOT NULL,
+    secret     TEXT NOT NULL,
+    events     TEXT[] NOT NULL DEFAULT '{}',
+    enabled    BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS artist_webhooks_user_id_idx ON artist_webhooks (user_id);
+
+-- One row per event per webhook; the job queue drives delivery and retries.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              BIGSERIAL PRIMARY KEY,
+    webhook_id      BIGINT NOT NULL REFERENCES artist_webhooks (id) ON DELETE CASCADE,
+    event_type      TEXT NOT NULL,
+    payload         JSONB NOT NULL,
+    status          TEXT NOT NULL DEFAULT 'pending'
+                    CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts        INT NOT NULL DEFAULT 0,
+    response_status INT,
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, id DESC);
+
+-- Payouts to artists, recorded by admins as they move through the processor.
+CREATE TABLE IF NOT EXISTS payouts (
+    id             BIGSERIAL PRIMARY KEY,
+    artist_id      UUID NOT NULL,
+    amount         NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    status         TEXT NOT NULL DEFAULT 'initiated'
+                   CHECK (status IN ('initiated', 'paid', 'failed')),
+    failure_reason TEXT,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS payouts_artist_id_idx ON payouts (artist_id, created_at DESC);
@@ -0,0 +1,26 @@
+-- Personal API keys for integrations (Zapier, Make, scripts).
+-- Only a SHA-256 of the key is stored; the raw key is shown once.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      UUID NOT NULL,
+    name         TEXT NOT NULL DEFAULT '',
+    key_hash     TEXT NOT NULL UNIQUE,
+    prefix       TEXT NOT NULL,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys (user_id);
+
+-- Fans following artists (or any user following another).
+CREATE TABLE IF NOT EXISTS follows (
+    id          BIGSERIAL PRIMARY KEY,
+    follower_id UUID NOT NULL,
+    followee_id UUID NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX IF NOT EXISTS follows_followee_id_idx ON follows (followee_id, id DESC);
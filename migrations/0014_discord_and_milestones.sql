@@ -0,0 +1,37 @@
+-- Play-count milestones each song has reached, recorded once.
+CREATE TABLE IF NOT EXISTS song_milestones (
+    song_id    BIGINT NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    metric     TEXT NOT NULL,
+    threshold  BIGINT NOT NULL,
+    reached_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (song_id, metric, threshold)
+);
+
+-- One linked Discord channel webhook per artist.
+CREATE TABLE IF NOT EXISTS discord_integrations (
+    id                BIGSERIAL PRIMARY KEY,
+    user_id           UUID NOT NULL UNIQUE,
+    webhook_url       TEXT NOT NULL,
+    channel_name      TEXT NOT NULL DEFAULT '',
+    notify_releases   BOOLEAN NOT NULL DEFAULT true,
+    notify_milestones BOOLEAN NOT NULL DEFAULT true,
+    enabled           BOOLEAN NOT NULL DEFAULT true,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS discord_deliveries (
+    id              BIGSERIAL PRIMARY KEY,
+    integration_id  BIGINT NOT NULL REFERENCES discord_integrations (id) ON DELETE CASCADE,
+    kind            TEXT NOT NULL,
+    content         TEXT NOT NULL,
+    status          TEXT NOT NULL DEFAULT 'pending'
+                    CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts        INT NOT NULL DEFAULT 0,
+    response_status INT,
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS discord_deliveries_integration_id_idx ON discord_deliveries (integration_id, id DESC);
@@ -0,0 +1,21 @@
+-- An artist's upcoming releases and listening parties.
+CREATE TABLE IF NOT EXISTS scheduled_releases (
+    id         BIGSERIAL PRIMARY KEY,
+    artist_id  UUID NOT NULL,
+    song_id    BIGINT REFERENCES songs (id) ON DELETE SET NULL,
+    kind       TEXT NOT NULL CHECK (kind IN ('release', 'listening_party')),
+    title      TEXT NOT NULL,
+    notes      TEXT NOT NULL DEFAULT '',
+    starts_at  TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_releases_artist_id_idx ON scheduled_releases (artist_id, starts_at);
+
+-- Secret per-user token for calendar subscriptions, which can't send auth headers.
+CREATE TABLE IF NOT EXISTS calendar_feed_tokens (
+    user_id    UUID PRIMARY KEY,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
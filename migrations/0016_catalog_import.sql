@@ -0,0 +1,11 @@
+-- Draft songs are hidden until the artist publishes them.
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS published BOOLEAN NOT NULL DEFAULT true;
+
+-- Where an imported song came from, so re-running an import skips it.
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS source_url TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS songs_artist_id_source_url_idx
+    ON songs (artist_id, source_url) WHERE source_url IS NOT NULL;
+
+-- Track metadata carried over from the source platform.
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS duration_seconds INT;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS release_date DATE;
@@ -0,0 +1,16 @@
+-- Distributor metadata on songs.
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS isrc TEXT;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS label TEXT;
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS display_name TEXT;
+
+-- Ordered contributor credits per song (writers, producers, features, ...).
+CREATE TABLE IF NOT EXISTS song_credits (
+    id       BIGSERIAL PRIMARY KEY,
+    song_id  BIGINT NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    name     TEXT NOT NULL,
+    role     TEXT NOT NULL,
+    user_id  UUID,
+    position INT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS song_credits_song_id_idx ON song_credits (song_id, position);
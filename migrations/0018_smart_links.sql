@@ -0,0 +1,26 @@
+-- Link-in-bio pages for a song or scheduled release.
+CREATE TABLE IF NOT EXISTS smart_links (
+    id         BIGSERIAL PRIMARY KEY,
+    artist_id  UUID NOT NULL,
+    song_id    BIGINT REFERENCES songs (id) ON DELETE SET NULL,
+    release_id BIGINT REFERENCES scheduled_releases (id) ON DELETE SET NULL,
+    slug       TEXT NOT NULL UNIQUE,
+    title      TEXT NOT NULL,
+    links      JSONB NOT NULL DEFAULT '[]',
+    pixels     JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS smart_links_artist_id_idx ON smart_links (artist_id);
+
+-- Page views (platform IS NULL) and outbound clicks per platform.
+CREATE TABLE IF NOT EXISTS smart_link_hits (
+    id            BIGSERIAL PRIMARY KEY,
+    link_id       BIGINT NOT NULL REFERENCES smart_links (id) ON DELETE CASCADE,
+    platform      TEXT,
+    referrer_host TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS smart_link_hits_link_id_created_at_idx ON smart_link_hits (link_id, created_at);
@@ -0,0 +1,12 @@
+-- Current artwork/waveform per song. Objects live in Spaces under
+-- assets/<sha256>.<ext>, so a changed asset gets a new URL.
+CREATE TABLE IF NOT EXISTS song_assets (
+    song_id      BIGINT NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    kind         TEXT NOT NULL CHECK (kind IN ('artwork', 'waveform')),
+    hash         TEXT NOT NULL,
+    ext          TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size_bytes   BIGINT NOT NULL,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (song_id, kind)
+);
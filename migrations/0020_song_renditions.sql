@@ -0,0 +1,16 @@
+-- Audio files per song. "original" is the artist's upload; processing
+-- fills in loudness so players can normalize playback.
+CREATE TABLE IF NOT EXISTS song_renditions (
+    song_id         BIGINT NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    storage_key     TEXT NOT NULL,
+    content_type    TEXT NOT NULL,
+    size_bytes      BIGINT NOT NULL,
+    status          TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    error           TEXT,
+    integrated_lufs DOUBLE PRECISION,
+    true_peak_dbtp  DOUBLE PRECISION,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    processed_at    TIMESTAMPTZ,
+    PRIMARY KEY (song_id, name)
+);
@@ -0,0 +1,3 @@
+-- Ingest validation: decoded duration and a machine-readable failure code.
+ALTER TABLE song_renditions ADD COLUMN IF NOT EXISTS duration_ms BIGINT;
+ALTER TABLE song_renditions ADD COLUMN IF NOT EXISTS error_code TEXT;
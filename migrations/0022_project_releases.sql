@@ -0,0 +1,20 @@
+-- A project's bounce released as a song, with the optional check that the
+-- master is as long as the project's longest stem.
+CREATE TABLE IF NOT EXISTS project_releases (
+    id                   BIGSERIAL PRIMARY KEY,
+    project_id           BIGINT NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    song_id              BIGINT NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    master_stem_id       BIGINT NOT NULL REFERENCES project_stems (id),
+    created_by           UUID NOT NULL,
+    tolerance_seconds    DOUBLE PRECISION NOT NULL,
+    alignment_status     TEXT NOT NULL
+                         CHECK (alignment_status IN ('skipped', 'pending', 'ok', 'mismatch', 'error')),
+    master_seconds       DOUBLE PRECISION,
+    longest_stem_id      BIGINT,
+    longest_stem_seconds DOUBLE PRECISION,
+    alignment_error      TEXT,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    checked_at           TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS project_releases_project_id_idx ON project_releases (project_id);
@@ -0,0 +1,16 @@
+-- Accounts an artist has delegated scoped access to (managers).
+CREATE TABLE IF NOT EXISTS team_members (
+    artist_id  UUID NOT NULL,
+    member_id  UUID NOT NULL,
+    scopes     TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (artist_id, member_id),
+    CHECK (artist_id <> member_id)
+);
+
+CREATE INDEX IF NOT EXISTS team_members_member_id_idx ON team_members (member_id);
+
+-- Artist (or their team) replies to listener comments.
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS parent_id BIGINT REFERENCES comments (id) ON DELETE CASCADE;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS posted_by UUID;
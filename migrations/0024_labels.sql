@@ -0,0 +1,19 @@
+-- Label accounts and the artists signed to them. The roster is maintained
+-- by admins since it reflects a signed agreement.
+CREATE TABLE IF NOT EXISTS labels (
+    id         BIGSERIAL PRIMARY KEY,
+    owner_id   UUID NOT NULL,
+    name       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS labels_owner_id_idx ON labels (owner_id);
+
+CREATE TABLE IF NOT EXISTS label_artists (
+    label_id   BIGINT NOT NULL REFERENCES labels (id) ON DELETE CASCADE,
+    artist_id  UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (label_id, artist_id)
+);
+
+CREATE INDEX IF NOT EXISTS tips_created_at_idx ON tips (created_at);
@@ -0,0 +1,38 @@
+-- Remix contests: an artist shares source stems, producers submit one
+-- entry each before the deadline, and the artist shortlists and places.
+CREATE TABLE IF NOT EXISTS remix_contests (
+    id         BIGSERIAL PRIMARY KEY,
+    artist_id  UUID NOT NULL,
+    song_id    BIGINT REFERENCES songs (id) ON DELETE SET NULL,
+    title      TEXT NOT NULL,
+    rules      TEXT NOT NULL DEFAULT '',
+    deadline   TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS remix_contests_deadline_idx ON remix_contests (deadline);
+
+CREATE TABLE IF NOT EXISTS contest_stems (
+    id           BIGSERIAL PRIMARY KEY,
+    contest_id   BIGINT NOT NULL REFERENCES remix_contests (id) ON DELETE CASCADE,
+    filename     TEXT NOT NULL,
+    storage_key  TEXT NOT NULL,
+    size_bytes   BIGINT NOT NULL,
+    content_type TEXT NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS contest_entries (
+    id           BIGSERIAL PRIMARY KEY,
+    contest_id   BIGINT NOT NULL REFERENCES remix_contests (id) ON DELETE CASCADE,
+    producer_id  UUID NOT NULL,
+    title        TEXT NOT NULL,
+    storage_key  TEXT NOT NULL,
+    size_bytes   BIGINT NOT NULL,
+    content_type TEXT NOT NULL,
+    shortlisted  BOOLEAN NOT NULL DEFAULT false,
+    placement    INT CHECK (placement > 0),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (contest_id, producer_id)
+);
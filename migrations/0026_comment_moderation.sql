@@ -0,0 +1,8 @@
+-- Artist moderation of comments on their songs.
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS comment_policy TEXT NOT NULL DEFAULT 'everyone'
+    CHECK (comment_policy IN ('everyone', 'followers', 'disabled'));
+
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS hidden_at TIMESTAMPTZ;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS hidden_by UUID;
+
+CREATE INDEX IF NOT EXISTS comments_song_id_idx ON comments (song_id, id DESC);
@@ -0,0 +1,19 @@
+-- Pinned comments, and artist announcements scheduled to be posted as one.
+-- job_id is the announcement_publish job currently responsible for it.
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS pinned_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS song_announcements (
+    id         BIGSERIAL PRIMARY KEY,
+    song_id    BIGINT NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    created_by UUID NOT NULL,
+    body       TEXT NOT NULL,
+    publish_at TIMESTAMPTZ NOT NULL,
+    status     TEXT NOT NULL DEFAULT 'scheduled'
+               CHECK (status IN ('scheduled', 'published', 'canceled')),
+    job_id     BIGINT,
+    comment_id BIGINT REFERENCES comments (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS song_announcements_song_id_idx ON song_announcements (song_id, publish_at);
@@ -0,0 +1,45 @@
+-- Artists' connected social accounts and the release posts sent to them.
+-- X (Twitter) connections hold OAuth tokens; Instagram goes through an
+-- artist-run webhook proxy, signed with secret.
+CREATE TABLE IF NOT EXISTS social_connections (
+    id               BIGSERIAL PRIMARY KEY,
+    user_id          UUID NOT NULL,
+    provider         TEXT NOT NULL CHECK (provider IN ('twitter', 'instagram')),
+    account_name     TEXT NOT NULL DEFAULT '',
+    access_token     TEXT,
+    refresh_token    TEXT,
+    token_expires_at TIMESTAMPTZ,
+    webhook_url      TEXT,
+    secret           TEXT,
+    auto_post        BOOLEAN NOT NULL DEFAULT true,
+    status           TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'reconnect_required')),
+    last_error       TEXT,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, provider)
+);
+
+-- Pending OAuth authorizations; rows older than a few minutes are stale.
+CREATE TABLE IF NOT EXISTS social_oauth_states (
+    state         TEXT PRIMARY KEY,
+    user_id       UUID NOT NULL,
+    provider      TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS social_posts (
+    id            BIGSERIAL PRIMARY KEY,
+    connection_id BIGINT NOT NULL REFERENCES social_connections (id) ON DELETE CASCADE,
+    song_id       BIGINT NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    content       TEXT NOT NULL,
+    link_url      TEXT,
+    image_url     TEXT,
+    status        TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'posted', 'failed')),
+    attempts      INT NOT NULL DEFAULT 0,
+    external_id   TEXT,
+    last_error    TEXT,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    posted_at     TIMESTAMPTZ,
+    UNIQUE (connection_id, song_id)
+);
@@ -0,0 +1,24 @@
+-- Tips matching the fraud heuristics are held for review and kept out of the
+-- artist's balance until an admin approves them. tip_ledger records every
+-- review decision and its effect on the balance.
+ALTER TABLE tips ADD COLUMN IF NOT EXISTS review_status TEXT NOT NULL DEFAULT 'cleared'
+    CHECK (review_status IN ('cleared', 'held', 'denied'));
+ALTER TABLE tips ADD COLUMN IF NOT EXISTS review_reasons TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE tips ADD COLUMN IF NOT EXISTS reviewed_by UUID;
+ALTER TABLE tips ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS tips_held_idx ON tips (created_at) WHERE review_status = 'held';
+CREATE INDEX IF NOT EXISTS tips_sender_id_idx ON tips (sender_id, created_at);
+
+CREATE TABLE IF NOT EXISTS tip_ledger (
+    id         BIGSERIAL PRIMARY KEY,
+    tip_id     BIGINT NOT NULL REFERENCES tips (id) ON DELETE CASCADE,
+    artist_id  UUID NOT NULL,
+    action     TEXT NOT NULL CHECK (action IN ('released', 'denied', 'reversed')),
+    amount     NUMERIC(12, 2) NOT NULL,
+    admin_id   UUID NOT NULL,
+    note       TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS tip_ledger_artist_id_idx ON tip_ledger (artist_id, created_at);
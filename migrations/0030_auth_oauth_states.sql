@@ -0,0 +1,9 @@
+-- Pending Google/Apple sign-ins: the PKCE verifier for each state handed to
+-- the client, kept until the authorization code comes back.
+CREATE TABLE IF NOT EXISTS auth_oauth_states (
+    state         TEXT PRIMARY KEY,
+    provider      TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    linking_user  UUID,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
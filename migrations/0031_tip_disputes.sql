@@ -0,0 +1,36 @@
+-- Stripe disputes (chargebacks) against tips. While a dispute is open its
+-- amount is frozen out of the artist's balance; won disputes release it,
+-- lost ones keep it deducted. Dispute entries in tip_ledger have no admin.
+ALTER TABLE tips ADD COLUMN IF NOT EXISTS payment_intent_id TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS tips_payment_intent_id_idx ON tips (payment_intent_id);
+
+CREATE TABLE IF NOT EXISTS tip_disputes (
+    id                BIGSERIAL PRIMARY KEY,
+    stripe_dispute_id TEXT NOT NULL UNIQUE,
+    payment_intent_id TEXT,
+    tip_id            BIGINT REFERENCES tips (id) ON DELETE SET NULL,
+    artist_id         UUID,
+    amount            NUMERIC(12, 2) NOT NULL,
+    frozen_amount     NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    currency          TEXT NOT NULL,
+    reason            TEXT NOT NULL,
+    status            TEXT NOT NULL,
+    evidence_due_by   TIMESTAMPTZ,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    closed_at         TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS tip_disputes_artist_id_idx ON tip_disputes (artist_id, status);
+
+-- Stripe retries webhooks; each event is applied once.
+CREATE TABLE IF NOT EXISTS stripe_events (
+    id          TEXT PRIMARY KEY,
+    type        TEXT NOT NULL,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE tip_ledger ALTER COLUMN admin_id DROP NOT NULL;
+ALTER TABLE tip_ledger DROP CONSTRAINT IF EXISTS tip_ledger_action_check;
+ALTER TABLE tip_ledger ADD CONSTRAINT tip_ledger_action_check
+    CHECK (action IN ('released', 'denied', 'reversed', 'frozen', 'unfrozen', 'charged_back'));
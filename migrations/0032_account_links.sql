@@ -0,0 +1,19 @@
+-- External accounts linked to a profile. The linked Spotify artist is also
+-- kept on the profile itself; one Spotify artist belongs to one profile.
+CREATE TABLE IF NOT EXISTS account_links (
+    id               BIGSERIAL PRIMARY KEY,
+    user_id          UUID NOT NULL,
+    provider         TEXT NOT NULL,
+    external_user_id TEXT NOT NULL,
+    external_name    TEXT NOT NULL DEFAULT '',
+    artist_id        TEXT,
+    access_token     TEXT NOT NULL,
+    refresh_token    TEXT,
+    token_expires_at TIMESTAMPTZ,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, provider)
+);
+
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS spotify_artist_id TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS profiles_spotify_artist_id_idx ON profiles (spotify_artist_id);
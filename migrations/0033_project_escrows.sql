@@ -0,0 +1,34 @@
+-- Escrowed producer fees on projects. The artist pays a fee into escrow
+-- with a Stripe PaymentIntent; the money sits in the platform balance until
+-- the artist marks the milestone complete (or an admin resolves a dispute)
+-- and is then sent to the producer's Stripe Connect account as a transfer,
+-- or refunded to the artist.
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS stripe_account_id TEXT;
+
+CREATE TABLE IF NOT EXISTS project_escrows (
+    id                BIGSERIAL PRIMARY KEY,
+    project_id        BIGINT NOT NULL REFERENCES projects (id) ON DELETE RESTRICT,
+    artist_id         UUID NOT NULL,
+    producer_id       UUID NOT NULL,
+    milestone         TEXT NOT NULL,
+    amount            NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    currency          TEXT NOT NULL DEFAULT 'usd',
+    status            TEXT NOT NULL DEFAULT 'awaiting_payment'
+        CHECK (status IN ('awaiting_payment', 'funded', 'disputed', 'releasing', 'released', 'refunding', 'refunded')),
+    payment_intent_id TEXT UNIQUE,
+    charge_id         TEXT,
+    transfer_id       TEXT,
+    refund_id         TEXT,
+    dispute_reason    TEXT,
+    disputed_by       UUID,
+    resolution_note   TEXT,
+    resolved_by       UUID,
+    last_error        TEXT,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    funded_at         TIMESTAMPTZ,
+    settled_at        TIMESTAMPTZ,
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS project_escrows_project_id_idx ON project_escrows (project_id, created_at);
+CREATE INDEX IF NOT EXISTS project_escrows_disputed_idx ON project_escrows (updated_at) WHERE status = 'disputed';
@@ -0,0 +1,25 @@
+-- TOTP second factor. A row exists from enrollment; enabled_at is set once
+-- the first code is verified. last_step blocks replaying a code, and
+-- repeated wrong codes lock verification for a few minutes.
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id         UUID PRIMARY KEY,
+    secret          TEXT NOT NULL,
+    enabled_at      TIMESTAMPTZ,
+    last_step       BIGINT NOT NULL DEFAULT 0,
+    failed_attempts INT NOT NULL DEFAULT 0,
+    locked_until    TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Proof of a passed challenge, sent as X-MFA-Token. Only a SHA-256 of the
+-- token is stored, and it is tied to the access token's session.
+CREATE TABLE IF NOT EXISTS mfa_sessions (
+    token_hash TEXT PRIMARY KEY,
+    user_id    UUID NOT NULL,
+    session_id TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS mfa_sessions_user_id_idx ON mfa_sessions (user_id);
@@ -0,0 +1,26 @@
+-- Append-only log of domain events (song.published, tip.confirmed,
+-- project.member_added, ...) for support to reconstruct an entity's history.
+-- Events are written in the same transaction as the change they describe.
+CREATE TABLE IF NOT EXISTS domain_events (
+    id          BIGSERIAL PRIMARY KEY,
+    type        TEXT NOT NULL,
+    entity_type TEXT NOT NULL,
+    entity_id   TEXT NOT NULL,
+    actor_id    UUID,
+    data        JSONB NOT NULL DEFAULT '{}',
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS domain_events_entity_idx ON domain_events (entity_type, entity_id, id);
+CREATE INDEX IF NOT EXISTS domain_events_type_idx ON domain_events (type, id);
+
+CREATE OR REPLACE FUNCTION domain_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'domain_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS domain_events_append_only ON domain_events;
+CREATE TRIGGER domain_events_append_only
+    BEFORE UPDATE OR DELETE ON domain_events
+    FOR EACH ROW EXECUTE FUNCTION domain_events_append_only();
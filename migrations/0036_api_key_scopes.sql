@@ -0,0 +1,4 @@
+-- Scopes and a per-minute request budget for API keys. Keys minted before
+-- this migration keep working as read-only keys at the default rate.
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{read}';
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS rate_limit_per_minute INT NOT NULL DEFAULT 60;
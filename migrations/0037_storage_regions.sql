@@ -0,0 +1,5 @@
+-- The Spaces region each uploaded file lives in. '' is the default region
+-- (SPACES_REGION), which holds every file uploaded before this migration.
+ALTER TABLE song_renditions ADD COLUMN IF NOT EXISTS storage_region TEXT NOT NULL DEFAULT '';
+ALTER TABLE contest_stems ADD COLUMN IF NOT EXISTS storage_region TEXT NOT NULL DEFAULT '';
+ALTER TABLE contest_entries ADD COLUMN IF NOT EXISTS storage_region TEXT NOT NULL DEFAULT '';
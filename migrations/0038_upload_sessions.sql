@@ -0,0 +1,44 @@
+-- Resumable uploads. A session is a Spaces multipart upload: the client
+-- sends numbered parts, checks which have landed, and completes the session
+-- once all are in. The assembled object is verified against the declared
+-- size and SHA-256 before it is attached to its song or project.
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id             BIGSERIAL PRIMARY KEY,
+    user_id        UUID NOT NULL,
+    target_type    TEXT NOT NULL CHECK (target_type IN ('song_audio', 'project_stem')),
+    target_id      BIGINT NOT NULL,
+    filename       TEXT NOT NULL DEFAULT '',
+    content_type   TEXT NOT NULL,
+    size_bytes     BIGINT NOT NULL,
+    sha256         TEXT NOT NULL,
+    part_size      BIGINT NOT NULL,
+    part_count     INT NOT NULL,
+    storage_key    TEXT NOT NULL,
+    storage_region TEXT NOT NULL DEFAULT '',
+    multipart_id   TEXT NOT NULL,
+    status         TEXT NOT NULL DEFAULT 'uploading'
+                   CHECK (status IN ('uploading', 'completing', 'completed', 'failed', 'expired')),
+    error          TEXT,
+    assembled_at   TIMESTAMPTZ,
+    attached_id    BIGINT,
+    expires_at     TIMESTAMPTZ NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    completed_at   TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS upload_sessions_user_idx ON upload_sessions (user_id, id DESC);
+CREATE INDEX IF NOT EXISTS upload_sessions_open_idx ON upload_sessions (expires_at)
+    WHERE status IN ('uploading', 'completing');
+
+CREATE TABLE IF NOT EXISTS upload_parts (
+    session_id  BIGINT NOT NULL REFERENCES upload_sessions (id) ON DELETE CASCADE,
+    part_number INT NOT NULL,
+    size_bytes  BIGINT NOT NULL,
+    etag        TEXT NOT NULL,
+    uploaded_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (session_id, part_number)
+);
+
+-- Stems attached through an upload session can live outside the default region.
+ALTER TABLE project_stems ADD COLUMN IF NOT EXISTS storage_region TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,28 @@
+-- Artwork and avatars are checked by the image moderator before they are
+-- shown. Until then (and while a flagged image waits for an admin) a
+-- placeholder is served. Statuses: pending, held, approved, rejected.
+ALTER TABLE song_assets ADD COLUMN IF NOT EXISTS moderation_status TEXT NOT NULL DEFAULT 'approved';
+
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS avatar_hash TEXT;
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS avatar_ext TEXT;
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS avatar_status TEXT;
+
+-- Images the moderator flagged (or couldn't check), for admin review.
+CREATE TABLE IF NOT EXISTS image_reviews (
+    id          BIGSERIAL PRIMARY KEY,
+    kind        TEXT NOT NULL CHECK (kind IN ('artwork', 'avatar')),
+    subject_id  TEXT NOT NULL,
+    hash        TEXT NOT NULL,
+    ext         TEXT NOT NULL,
+    uploaded_by UUID NOT NULL,
+    reason      TEXT NOT NULL,
+    labels      TEXT[] NOT NULL DEFAULT '{}',
+    score       DOUBLE PRECISION,
+    status      TEXT NOT NULL DEFAULT 'held' CHECK (status IN ('held', 'approved', 'rejected')),
+    reviewed_by UUID,
+    review_note TEXT,
+    reviewed_at TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS image_reviews_status_idx ON image_reviews (status, id);
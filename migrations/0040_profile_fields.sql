@@ -0,0 +1,19 @@
+-- Editable profile fields, updated through PATCH /auth/me.
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS bio TEXT;
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS links JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS genre_preferences TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+
+-- Profiles are public, but a user may only edit their own, and only the
+-- fields above; role, payout accounts and avatars are set by the backend.
+ALTER TABLE profiles ENABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS profiles_select ON profiles;
+CREATE POLICY profiles_select ON profiles FOR SELECT USING (true);
+
+DROP POLICY IF EXISTS profiles_update_own ON profiles;
+CREATE POLICY profiles_update_own ON profiles FOR UPDATE TO authenticated
+    USING (id = auth.uid()) WITH CHECK (id = auth.uid());
+
+REVOKE UPDATE ON profiles FROM authenticated;
+GRANT UPDATE (display_name, bio, links, genre_preferences, updated_at) ON profiles TO authenticated;
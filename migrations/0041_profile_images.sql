@@ -0,0 +1,8 @@
+-- Avatars and banners are stored as resized renditions under
+-- avatars/<user_id>/, always JPEG, so only the hash is kept.
+ALTER TABLE profiles DROP COLUMN IF EXISTS avatar_ext;
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS banner_hash TEXT;
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS banner_status TEXT;
+
+ALTER TABLE image_reviews DROP CONSTRAINT IF EXISTS image_reviews_kind_check;
+ALTER TABLE image_reviews ADD CONSTRAINT image_reviews_kind_check CHECK (kind IN ('artwork', 'avatar', 'banner'));
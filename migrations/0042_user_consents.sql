@@ -0,0 +1,14 @@
+-- Each acceptance of a legal document version (terms of service, privacy
+-- policy, ...), kept as history for compliance requests.
+CREATE TABLE IF NOT EXISTS user_consents (
+    id          BIGSERIAL PRIMARY KEY,
+    user_id     UUID NOT NULL,
+    document    TEXT NOT NULL,
+    version     TEXT NOT NULL,
+    ip          TEXT,
+    user_agent  TEXT,
+    accepted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, document, version)
+);
+
+CREATE INDEX IF NOT EXISTS user_consents_user_idx ON user_consents (user_id, accepted_at DESC);
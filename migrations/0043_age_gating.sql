@@ -0,0 +1,7 @@
+-- Birth date and sign-up region drive minimum-age checks and the age
+-- bracket used to keep explicit songs and tipping away from minors. Only
+-- the backend sets them (PUT /auth/me/birth-date), once.
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS birth_date DATE;
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS region TEXT;
+
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS explicit BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,6 @@
+-- Unique public handles for pretty artist URLs (/artists/:handle). Stored
+-- lowercase; set through PATCH /auth/me/handle, which also rejects
+-- reserved words.
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS handle TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS profiles_handle_key ON profiles (handle);
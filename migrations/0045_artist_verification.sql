@@ -0,0 +1,20 @@
+-- Verified-artist status, granted by an admin reviewing an application.
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS verified BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS verified_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS verification_applications (
+    id          BIGSERIAL PRIMARY KEY,
+    user_id     UUID NOT NULL,
+    links       JSONB NOT NULL DEFAULT '[]',
+    evidence    TEXT,
+    status      TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    reviewed_by UUID,
+    review_note TEXT,
+    reviewed_at TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- One open application per user.
+CREATE UNIQUE INDEX IF NOT EXISTS verification_applications_pending_idx
+    ON verification_applications (user_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS verification_applications_status_idx ON verification_applications (status, id);
@@ -0,0 +1,28 @@
+-- VAT/GST on tips. A quote is made when the tip's PaymentIntent is
+-- created; when the tip is recorded its tax lines are copied to
+-- tip_tax_lines, the tax ledger receipts and remittance reports read.
+CREATE TABLE IF NOT EXISTS tip_tax_quotes (
+    payment_intent_id TEXT PRIMARY KEY,
+    song_id           BIGINT NOT NULL,
+    sender_id         UUID NOT NULL,
+    amount            NUMERIC(12,2) NOT NULL,
+    tax_amount        NUMERIC(12,2) NOT NULL,
+    currency          TEXT NOT NULL,
+    country           TEXT,
+    country_source    TEXT NOT NULL,
+    lines             JSONB NOT NULL DEFAULT '[]',
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS tip_tax_lines (
+    id         BIGSERIAL PRIMARY KEY,
+    tip_id     BIGINT NOT NULL REFERENCES tips (id) ON DELETE CASCADE,
+    kind       TEXT NOT NULL,
+    country    TEXT NOT NULL,
+    rate       NUMERIC(5,2) NOT NULL,
+    amount     NUMERIC(12,2) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS tip_tax_lines_tip_idx ON tip_tax_lines (tip_id);
+CREATE INDEX IF NOT EXISTS tip_tax_lines_country_idx ON tip_tax_lines (country, created_at);
@@ -0,0 +1,11 @@
+-- Transcripts and audio descriptions for songs, one per kind and language.
+CREATE TABLE IF NOT EXISTS song_transcripts (
+    song_id    BIGINT NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    kind       TEXT NOT NULL CHECK (kind IN ('transcript', 'description')),
+    language   TEXT NOT NULL,
+    format     TEXT NOT NULL CHECK (format IN ('vtt', 'srt', 'txt')),
+    body       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (song_id, kind, language)
+);
@@ -0,0 +1,33 @@
+-- Short-lived "log in as" tokens issued to support staff, and a record of
+-- every request made with one.
+CREATE TABLE IF NOT EXISTS impersonation_sessions (
+    id         BIGSERIAL PRIMARY KEY,
+    admin_id   UUID NOT NULL,
+    user_id    UUID NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    scope      TEXT NOT NULL CHECK (scope IN ('read', 'write')),
+    reason     TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    revoked_by UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS impersonation_sessions_user_idx ON impersonation_sessions (user_id, id DESC);
+CREATE INDEX IF NOT EXISTS impersonation_sessions_admin_idx ON impersonation_sessions (admin_id, id DESC);
+
+CREATE TABLE IF NOT EXISTS impersonation_audit (
+    id         BIGSERIAL PRIMARY KEY,
+    session_id BIGINT NOT NULL REFERENCES impersonation_sessions(id),
+    admin_id   UUID NOT NULL,
+    user_id    UUID NOT NULL,
+    method     TEXT NOT NULL,
+    path       TEXT NOT NULL,
+    route      TEXT,
+    status     INT NOT NULL,
+    ip         TEXT,
+    user_agent TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS impersonation_audit_session_idx ON impersonation_audit (session_id, id);
@@ -0,0 +1,12 @@
+-- Failed password sign-ins per email (hashed) and per client IP, for the
+-- sign-in lockout.
+CREATE TABLE IF NOT EXISTS login_failures (
+    kind            TEXT NOT NULL CHECK (kind IN ('email', 'ip')),
+    subject         TEXT NOT NULL,
+    failures        INT NOT NULL DEFAULT 0,
+    locked_until    TIMESTAMPTZ,
+    last_failure_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (kind, subject)
+);
+
+CREATE INDEX IF NOT EXISTS login_failures_last_failure_idx ON login_failures (last_failure_at);
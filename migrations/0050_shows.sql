@@ -0,0 +1,45 @@
+-- Episodic shows (podcasts). Episodes are songs attached to a show.
+CREATE TABLE IF NOT EXISTS shows (
+    id          BIGSERIAL PRIMARY KEY,
+    owner_id    UUID NOT NULL,
+    title       TEXT NOT NULL,
+    description TEXT,
+    author      TEXT,
+    language    TEXT NOT NULL DEFAULT 'en',
+    category    TEXT,
+    image_url   TEXT,
+    explicit    BOOLEAN NOT NULL DEFAULT false,
+    show_type   TEXT NOT NULL DEFAULT 'episodic' CHECK (show_type IN ('episodic', 'serial')),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS shows_owner_idx ON shows (owner_id);
+
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS show_id BIGINT REFERENCES shows(id) ON DELETE SET NULL;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS season_number INT;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS episode_number INT;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS episode_type TEXT CHECK (episode_type IN ('full', 'trailer', 'bonus'));
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS episode_description TEXT;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS episode_added_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS songs_show_idx ON songs (show_id) WHERE show_id IS NOT NULL;
+-- Episode numbers are unique within a season (or within the show when unseasoned).
+CREATE UNIQUE INDEX IF NOT EXISTS songs_show_episode_idx
+    ON songs (show_id, COALESCE(season_number, 0), episode_number)
+    WHERE show_id IS NOT NULL AND episode_number IS NOT NULL;
+
+-- Feed enclosure downloads, one row per listener (hashed IP and user agent)
+-- per episode per UTC day.
+CREATE TABLE IF NOT EXISTS episode_downloads (
+    id            BIGSERIAL PRIMARY KEY,
+    show_id       BIGINT NOT NULL REFERENCES shows(id) ON DELETE CASCADE,
+    song_id       BIGINT NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    day           DATE NOT NULL,
+    listener_hash TEXT NOT NULL,
+    user_agent    TEXT,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (song_id, day, listener_hash)
+);
+
+CREATE INDEX IF NOT EXISTS episode_downloads_show_idx ON episode_downloads (show_id, day);
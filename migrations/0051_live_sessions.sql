@@ -0,0 +1,46 @@
+-- Live audio sessions. Each is backed by an unpublished song that takes the
+-- session's tips; stream details come from the external media server.
+CREATE TABLE IF NOT EXISTS live_sessions (
+    id                  BIGSERIAL PRIMARY KEY,
+    artist_id           UUID NOT NULL,
+    song_id             BIGINT NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    title               TEXT NOT NULL,
+    status              TEXT NOT NULL DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'live', 'ended')),
+    scheduled_for       TIMESTAMPTZ,
+    started_at          TIMESTAMPTZ,
+    ended_at            TIMESTAMPTZ,
+    peak_listeners      INT NOT NULL DEFAULT 0,
+    stream_id           TEXT NOT NULL,
+    rtmp_url            TEXT,
+    stream_key          TEXT,
+    webrtc_ingest_url   TEXT,
+    hls_playback_url    TEXT,
+    webrtc_playback_url TEXT,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS live_sessions_artist_idx ON live_sessions (artist_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS live_sessions_live_idx ON live_sessions (peak_listeners DESC) WHERE status = 'live';
+CREATE UNIQUE INDEX IF NOT EXISTS live_sessions_song_idx ON live_sessions (song_id);
+
+CREATE TABLE IF NOT EXISTS live_chat_messages (
+    id         BIGSERIAL PRIMARY KEY,
+    session_id BIGINT NOT NULL REFERENCES live_sessions(id) ON DELETE CASCADE,
+    author_id  UUID NOT NULL,
+    body       TEXT NOT NULL,
+    hidden_at  TIMESTAMPTZ,
+    hidden_by  UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS live_chat_messages_session_idx ON live_chat_messages (session_id, id);
+CREATE INDEX IF NOT EXISTS live_chat_messages_author_idx ON live_chat_messages (session_id, author_id, created_at);
+
+-- One row per listener per session; last_seen_at is bumped by playback heartbeats.
+CREATE TABLE IF NOT EXISTS live_listeners (
+    session_id    BIGINT NOT NULL REFERENCES live_sessions(id) ON DELETE CASCADE,
+    listener_key  TEXT NOT NULL,
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_seen_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (session_id, listener_key)
+);
@@ -0,0 +1,21 @@
+-- Short shareable clips cut from published songs, rendered by clip_render jobs.
+CREATE TABLE IF NOT EXISTS clips (
+    id             BIGSERIAL PRIMARY KEY,
+    slug           TEXT NOT NULL UNIQUE,
+    song_id        BIGINT NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    creator_id     UUID NOT NULL,
+    title          TEXT NOT NULL,
+    start_ms       BIGINT NOT NULL CHECK (start_ms >= 0),
+    end_ms         BIGINT NOT NULL CHECK (end_ms > start_ms),
+    status         TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    error          TEXT,
+    storage_key    TEXT,
+    storage_region TEXT,
+    size_bytes     BIGINT,
+    plays          BIGINT NOT NULL DEFAULT 0,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    rendered_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS clips_song_idx ON clips (song_id, plays DESC) WHERE status = 'ready';
+CREATE INDEX IF NOT EXISTS clips_creator_idx ON clips (creator_id, created_at);
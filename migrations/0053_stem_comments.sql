@@ -0,0 +1,11 @@
+-- Timestamped feedback on project stems, exportable as DAW markers.
+CREATE TABLE IF NOT EXISTS stem_comments (
+    id         BIGSERIAL PRIMARY KEY,
+    stem_id    BIGINT NOT NULL REFERENCES project_stems (id) ON DELETE CASCADE,
+    author_id  UUID NOT NULL,
+    at_ms      BIGINT NOT NULL CHECK (at_ms >= 0),
+    body       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS stem_comments_stem_idx ON stem_comments (stem_id, at_ms);
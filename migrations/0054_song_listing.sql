@@ -0,0 +1,8 @@
+-- Columns behind GET /songs filters. Rows that predate created_at get the
+-- migration time.
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS genre TEXT;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS songs_genre_idx ON songs (genre, created_at DESC) WHERE published;
+CREATE INDEX IF NOT EXISTS songs_created_idx ON songs (created_at DESC, id DESC) WHERE published;
+CREATE INDEX IF NOT EXISTS reviews_song_idx ON reviews (song_id);
@@ -0,0 +1,14 @@
+-- "Fans also listen to": top neighbours per artist, rebuilt by the
+-- similar_artists periodic job.
+CREATE TABLE IF NOT EXISTS artist_similarity (
+    artist_id         UUID NOT NULL,
+    similar_artist_id UUID NOT NULL,
+    score             DOUBLE PRECISION NOT NULL,
+    shared_listeners  BIGINT NOT NULL,
+    shared_genres     TEXT[] NOT NULL DEFAULT '{}',
+    computed_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (artist_id, similar_artist_id)
+);
+
+CREATE INDEX IF NOT EXISTS artist_similarity_rank_idx ON artist_similarity (artist_id, score DESC);
+CREATE INDEX IF NOT EXISTS events_recent_plays_idx ON events (occurred_at) WHERE event_type = 'play' AND NOT is_bot;
@@ -0,0 +1,29 @@
+-- Editorial collections (staff picks, genre spotlights), ordered and scheduled.
+CREATE TABLE IF NOT EXISTS collections (
+    id          BIGSERIAL PRIMARY KEY,
+    slug        TEXT NOT NULL UNIQUE,
+    title       TEXT NOT NULL,
+    description TEXT,
+    kind        TEXT NOT NULL CHECK (kind IN ('staff_picks', 'genre_spotlight', 'editorial')),
+    genre       TEXT,
+    cover_url   TEXT,
+    position    INT NOT NULL DEFAULT 0,
+    starts_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ends_at     TIMESTAMPTZ,
+    created_by  UUID NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (ends_at IS NULL OR ends_at > starts_at),
+    CHECK (kind <> 'genre_spotlight' OR genre IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS collections_window_idx ON collections (position, starts_at DESC);
+
+CREATE TABLE IF NOT EXISTS collection_songs (
+    collection_id BIGINT NOT NULL REFERENCES collections (id) ON DELETE CASCADE,
+    song_id       BIGINT NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    position      INT NOT NULL,
+    PRIMARY KEY (collection_id, song_id)
+);
+
+CREATE INDEX IF NOT EXISTS collection_songs_order_idx ON collection_songs (collection_id, position);
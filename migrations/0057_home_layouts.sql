@@ -0,0 +1,9 @@
+-- Home screen layouts. Every save appends a row; the newest is live.
+CREATE TABLE IF NOT EXISTS home_layouts (
+    id         BIGSERIAL PRIMARY KEY,
+    modules    JSONB NOT NULL,
+    created_by UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS songs_artist_created_idx ON songs (artist_id, created_at DESC) WHERE published;
@@ -0,0 +1,8 @@
+-- Artist-set overrides for search and link-preview titles and descriptions.
+ALTER TABLE songs
+    ADD COLUMN IF NOT EXISTS seo_title       TEXT CHECK (char_length(seo_title) <= 70),
+    ADD COLUMN IF NOT EXISTS seo_description TEXT CHECK (char_length(seo_description) <= 160);
+
+ALTER TABLE profiles
+    ADD COLUMN IF NOT EXISTS seo_title       TEXT CHECK (char_length(seo_title) <= 70),
+    ADD COLUMN IF NOT EXISTS seo_description TEXT CHECK (char_length(seo_description) <= 160);
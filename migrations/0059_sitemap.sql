@@ -0,0 +1,36 @@
+-- songs.updated_at moves on every change so the sitemap can sync
+-- incrementally; a trigger keeps it current however the row is updated.
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE OR REPLACE FUNCTION touch_updated_at() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at := now();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS songs_touch_updated_at ON songs;
+CREATE TRIGGER songs_touch_updated_at
+    BEFORE UPDATE ON songs
+    FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
+
+CREATE INDEX IF NOT EXISTS songs_updated_at_idx ON songs (updated_at);
+CREATE INDEX IF NOT EXISTS profiles_updated_at_idx ON profiles (updated_at);
+
+-- Public catalog pages listed in the sitemap, in stable order per kind.
+CREATE TABLE IF NOT EXISTS sitemap_entries (
+    id      BIGSERIAL PRIMARY KEY,
+    kind    TEXT NOT NULL CHECK (kind IN ('songs', 'artists', 'collections')),
+    key     TEXT NOT NULL,
+    path    TEXT NOT NULL,
+    lastmod TIMESTAMPTZ NOT NULL,
+    UNIQUE (kind, key)
+);
+
+CREATE INDEX IF NOT EXISTS sitemap_entries_kind_idx ON sitemap_entries (kind, id);
+
+-- Single row: when sitemap_entries was last synced.
+CREATE TABLE IF NOT EXISTS sitemap_sync (
+    id        BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    synced_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,25 @@
+-- Third-party apps using the client-credentials grant for public reads.
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id                    BIGSERIAL PRIMARY KEY,
+    owner_id              UUID NOT NULL,
+    client_id             TEXT NOT NULL UNIQUE,
+    secret_hash           TEXT NOT NULL,
+    name                  TEXT NOT NULL,
+    scopes                TEXT[] NOT NULL,
+    rate_limit_per_minute INT NOT NULL,
+    last_used_at          TIMESTAMPTZ,
+    revoked_at            TIMESTAMPTZ,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS oauth_clients_owner_idx ON oauth_clients (owner_id);
+
+-- Access tokens are stored hashed and expire after an hour.
+CREATE TABLE IF NOT EXISTS oauth_access_tokens (
+    token_hash TEXT PRIMARY KEY,
+    client_id  BIGINT NOT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    scopes     TEXT[] NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS oauth_access_tokens_expiry_idx ON oauth_access_tokens (expires_at);
@@ -0,0 +1,26 @@
+-- Move API keys and OAuth clients to the granular scope names:
+-- read -> songs:read, write -> songs:write, analytics -> analytics:read,
+-- and the client scopes catalog:read / search:read -> songs:read.
+CREATE OR REPLACE FUNCTION granular_scopes(scopes TEXT[]) RETURNS TEXT[] AS $$
+    SELECT COALESCE(array_agg(DISTINCT CASE s
+        WHEN 'read'         THEN 'songs:read'
+        WHEN 'write'        THEN 'songs:write'
+        WHEN 'analytics'    THEN 'analytics:read'
+        WHEN 'catalog:read' THEN 'songs:read'
+        WHEN 'search:read'  THEN 'songs:read'
+        ELSE s END), '{}')
+    FROM unnest(scopes) AS s;
+$$ LANGUAGE sql IMMUTABLE;
+
+UPDATE api_keys SET scopes = granular_scopes(scopes)
+WHERE scopes && ARRAY['read', 'write', 'analytics'];
+
+UPDATE oauth_clients SET scopes = granular_scopes(scopes)
+WHERE scopes && ARRAY['catalog:read', 'search:read'];
+
+UPDATE oauth_access_tokens SET scopes = granular_scopes(scopes)
+WHERE scopes && ARRAY['catalog:read', 'search:read'];
+
+ALTER TABLE api_keys ALTER COLUMN scopes SET DEFAULT '{songs:read}';
+
+DROP FUNCTION granular_scopes(TEXT[]);
@@ -0,0 +1,11 @@
+-- Songs fans have reposted to their profile.
+CREATE TABLE IF NOT EXISTS reposts (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    UUID NOT NULL,
+    song_id    BIGINT NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, song_id)
+);
+
+CREATE INDEX IF NOT EXISTS reposts_user_idx ON reposts (user_id, id DESC);
+CREATE INDEX IF NOT EXISTS reposts_song_idx ON reposts (song_id);
@@ -0,0 +1,22 @@
+-- Delegated uploads: a single-use token for a presigned form upload that
+-- Spaces only accepts under key_prefix and up to max_bytes. Tokens are
+-- stored hashed; redeeming one sets used_at and attaches storage_key.
+CREATE TABLE IF NOT EXISTS upload_tokens (
+    id             BIGSERIAL PRIMARY KEY,
+    token_hash     TEXT NOT NULL UNIQUE,
+    user_id        UUID NOT NULL,
+    target_type    TEXT NOT NULL CHECK (target_type IN ('song_audio', 'project_stem')),
+    target_id      BIGINT NOT NULL,
+    filename       TEXT NOT NULL DEFAULT '',
+    content_type   TEXT NOT NULL,
+    max_bytes      BIGINT NOT NULL,
+    key_prefix     TEXT NOT NULL,
+    storage_key    TEXT NOT NULL,
+    storage_region TEXT NOT NULL DEFAULT '',
+    expires_at     TIMESTAMPTZ NOT NULL,
+    used_at        TIMESTAMPTZ,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS upload_tokens_unused_idx ON upload_tokens (expires_at) WHERE used_at IS NULL;
+CREATE INDEX IF NOT EXISTS upload_tokens_used_idx ON upload_tokens (used_at) WHERE used_at IS NOT NULL;
@@ -0,0 +1,4 @@
+-- Renditions transcoded from the original ("hls", "preview") record which
+-- original they were made from, so a re-upload makes them stale until the
+-- transcode job replaces them.
+ALTER TABLE song_renditions ADD COLUMN IF NOT EXISTS source_key TEXT;
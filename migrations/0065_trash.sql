@@ -0,0 +1,9 @@
+-- Deleted songs and comments go to the owner's trash for 30 days before
+-- being purged. A trashed song is unpublished; trashed_published remembers
+-- whether to publish it again on restore.
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS trashed_at TIMESTAMPTZ;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS trashed_published BOOLEAN;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS trashed_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS songs_trashed_idx ON songs (trashed_at) WHERE trashed_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS comments_trashed_idx ON comments (trashed_at) WHERE trashed_at IS NOT NULL;
@@ -0,0 +1,4 @@
+-- Per-user switches hiding activity from everyone else.
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS hide_listening_history BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS hide_library BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS hide_tips BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,32 @@
+-- Press kits for unreleased songs: a press page plus a watermarked preview
+-- per recipient, reachable by that recipient's token until release day.
+CREATE TABLE IF NOT EXISTS press_kits (
+    song_id       BIGINT PRIMARY KEY REFERENCES songs(id) ON DELETE CASCADE,
+    artist_id     UUID NOT NULL,
+    headline      TEXT NOT NULL DEFAULT '',
+    notes         TEXT NOT NULL DEFAULT '',
+    embargo_until TIMESTAMPTZ NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS press_kit_recipients (
+    id              BIGSERIAL PRIMARY KEY,
+    song_id         BIGINT NOT NULL REFERENCES press_kits(song_id) ON DELETE CASCADE,
+    token_hash      TEXT NOT NULL UNIQUE,
+    name            TEXT NOT NULL,
+    email           TEXT NOT NULL DEFAULT '',
+    outlet          TEXT NOT NULL DEFAULT '',
+    preview_status  TEXT NOT NULL DEFAULT 'pending' CHECK (preview_status IN ('pending', 'ready', 'failed')),
+    preview_error   TEXT,
+    storage_key     TEXT,
+    storage_region  TEXT,
+    views           BIGINT NOT NULL DEFAULT 0,
+    plays           BIGINT NOT NULL DEFAULT 0,
+    first_viewed_at TIMESTAMPTZ,
+    last_viewed_at  TIMESTAMPTZ,
+    revoked_at      TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS press_kit_recipients_song_idx ON press_kit_recipients (song_id, created_at);
@@ -0,0 +1,21 @@
+-- Playlists the system generates for a user, e.g. the weekly "Fans like
+-- you" mix. One per user, kind and week; songs keep their order.
+CREATE TABLE IF NOT EXISTS system_playlists (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      UUID NOT NULL,
+    kind         TEXT NOT NULL,
+    title        TEXT NOT NULL,
+    week_start   DATE NOT NULL,
+    generated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, kind, week_start)
+);
+
+CREATE TABLE IF NOT EXISTS system_playlist_songs (
+    playlist_id       BIGINT NOT NULL REFERENCES system_playlists(id) ON DELETE CASCADE,
+    position          INT NOT NULL,
+    song_id           BIGINT NOT NULL REFERENCES songs(id) ON DELETE CASCADE,
+    because_artist_id UUID,
+    PRIMARY KEY (playlist_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS system_playlists_week_idx ON system_playlists (kind, week_start);
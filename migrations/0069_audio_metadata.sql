@@ -0,0 +1,13 @@
+-- What ffprobe reports for song renditions and project stems, so clients
+-- don't have to load a file to learn its length or format.
+ALTER TABLE song_renditions ADD COLUMN IF NOT EXISTS bit_rate BIGINT;
+ALTER TABLE song_renditions ADD COLUMN IF NOT EXISTS sample_rate INT;
+ALTER TABLE song_renditions ADD COLUMN IF NOT EXISTS channels INT;
+ALTER TABLE song_renditions ADD COLUMN IF NOT EXISTS codec TEXT;
+
+ALTER TABLE project_stems ADD COLUMN IF NOT EXISTS duration_ms BIGINT;
+ALTER TABLE project_stems ADD COLUMN IF NOT EXISTS bit_rate BIGINT;
+ALTER TABLE project_stems ADD COLUMN IF NOT EXISTS sample_rate INT;
+ALTER TABLE project_stems ADD COLUMN IF NOT EXISTS channels INT;
+ALTER TABLE project_stems ADD COLUMN IF NOT EXISTS codec TEXT;
+ALTER TABLE project_stems ADD COLUMN IF NOT EXISTS probed_at TIMESTAMPTZ;
@@ -0,0 +1,9 @@
+-- Fan achievements awarded by the badges job.
+CREATE TABLE IF NOT EXISTS user_badges (
+    user_id    UUID NOT NULL,
+    badge      TEXT NOT NULL,
+    awarded_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, badge)
+);
+
+CREATE INDEX IF NOT EXISTS user_badges_badge_idx ON user_badges (badge);
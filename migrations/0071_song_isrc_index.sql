@@ -0,0 +1,2 @@
+-- ISRCs are checked for reuse across songs when set.
+CREATE INDEX IF NOT EXISTS songs_isrc_idx ON songs (isrc) WHERE isrc IS NOT NULL;
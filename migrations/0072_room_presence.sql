@@ -0,0 +1,10 @@
+-- Heartbeats for presence and typing in project and song rooms.
+CREATE TABLE IF NOT EXISTS room_presence (
+    room         TEXT NOT NULL,
+    user_id      UUID NOT NULL,
+    typing_until TIMESTAMPTZ,
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (room, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS room_presence_seen_idx ON room_presence (last_seen_at);
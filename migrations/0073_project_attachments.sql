@@ -0,0 +1,28 @@
+-- Small images and voice memos attached to project chat messages and stem
+-- comments. Each is scanned before it is served and attached at most once.
+CREATE TABLE IF NOT EXISTS project_attachments (
+    id             BIGSERIAL PRIMARY KEY,
+    project_id     BIGINT NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    uploader_id    UUID NOT NULL,
+    kind           TEXT NOT NULL CHECK (kind IN ('image', 'audio')),
+    content_type   TEXT NOT NULL,
+    size_bytes     BIGINT NOT NULL,
+    storage_key    TEXT NOT NULL,
+    storage_region TEXT NOT NULL DEFAULT '',
+    duration_ms    BIGINT,
+    scan_status    TEXT NOT NULL DEFAULT 'pending'
+                   CHECK (scan_status IN ('pending', 'clean', 'infected', 'rejected')),
+    scan_result    TEXT,
+    scanned_at     TIMESTAMPTZ,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS project_attachments_project_idx ON project_attachments (project_id, created_at);
+
+ALTER TABLE project_messages ADD COLUMN IF NOT EXISTS attachment_id BIGINT REFERENCES project_attachments (id) ON DELETE SET NULL;
+ALTER TABLE stem_comments ADD COLUMN IF NOT EXISTS attachment_id BIGINT REFERENCES project_attachments (id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS project_messages_attachment_idx ON project_messages (attachment_id) WHERE attachment_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS stem_comments_attachment_idx ON stem_comments (attachment_id) WHERE attachment_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS project_messages_project_idx ON project_messages (project_id, id);
@@ -0,0 +1,12 @@
+-- Decayed engagement scores for GET /songs/trending, replaced wholesale by
+-- the trending rollup.
+CREATE TABLE IF NOT EXISTS song_trending (
+    song_id     BIGINT PRIMARY KEY REFERENCES songs (id) ON DELETE CASCADE,
+    score       DOUBLE PRECISION NOT NULL,
+    plays       BIGINT NOT NULL DEFAULT 0,
+    tips        BIGINT NOT NULL DEFAULT 0,
+    comments    BIGINT NOT NULL DEFAULT 0,
+    computed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS song_trending_score_idx ON song_trending (score DESC);
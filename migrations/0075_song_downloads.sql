@@ -0,0 +1,5 @@
+-- Artists opt songs in to downloads of the original file, optionally only
+-- for followers or fans who have tipped the song.
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS downloads_enabled BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS download_gate TEXT NOT NULL DEFAULT 'none'
+    CHECK (download_gate IN ('none', 'follow', 'tip'));
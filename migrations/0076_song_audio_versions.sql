@@ -0,0 +1,23 @@
+-- Every original a song has had. The song's original rendition points at
+-- one of them; the rest are kept so the artist can roll back.
+CREATE TABLE IF NOT EXISTS song_audio_versions (
+    id             BIGSERIAL PRIMARY KEY,
+    song_id        BIGINT NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    version        INT NOT NULL,
+    storage_key    TEXT NOT NULL,
+    storage_region TEXT NOT NULL DEFAULT '',
+    content_type   TEXT NOT NULL,
+    size_bytes     BIGINT NOT NULL,
+    duration_ms    BIGINT,
+    uploaded_by    UUID,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (song_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS song_audio_versions_key_idx ON song_audio_versions (storage_key);
+
+-- Existing audio becomes version 1.
+INSERT INTO song_audio_versions (song_id, version, storage_key, storage_region, content_type, size_bytes, duration_ms, created_at)
+SELECT song_id, 1, storage_key, storage_region, content_type, size_bytes, duration_ms, created_at
+FROM song_renditions WHERE name = 'original'
+ON CONFLICT (song_id, version) DO NOTHING;
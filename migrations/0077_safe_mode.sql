@@ -0,0 +1,2 @@
+-- Adults can opt out of explicit songs; minors never see them.
+ALTER TABLE profiles ADD COLUMN IF NOT EXISTS safe_mode BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,5 @@
+-- A running job holds a lease the worker renews while it works. A job whose
+-- lease ran out was left behind by a worker that died, and is requeued.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS jobs_running_lease_idx ON jobs (locked_until) WHERE status = 'running';
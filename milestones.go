@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Milestones are lifetime play counts a song has crossed, recorded once in
+// song_milestones from the daily stats rollup. New releases are songs past
+// the last one announced. A periodic job detects both and announces them to
+// the artist's linked integrations.
+const (
+	milestonesName     = "milestones"
+	milestonesInterval = 15 * time.Minute
+	releasesWatermark  = "announced_releases"
+)
+
+// playMilestones are the lifetime play counts worth announcing.
+var playMilestones = []int64{100, 1000, 10000, 100000, 1000000}
+
+// checkMilestones records newly reached milestones and announces them, then
+// announces songs created since the last run.
+func checkMilestones(ctx context.Context) error {
+	rows, err := db.Query(ctx, `
+		WITH totals AS (
+			SELECT song_id, SUM(plays) AS plays FROM song_daily_stats GROUP BY song_id
+		)
+		INSERT INTO song_milestones (song_id, metric, threshold)
+		SELECT t.song_id, 'plays', m.threshold
+		FROM totals t
+		CROSS JOIN unnest($1::bigint[]) AS m(threshold)
+		WHERE t.plays >= m.threshold
+		ON CONFLICT DO NOTHING
+		RETURNING song_id, threshold;
+	`, playMilestones)
+	if err != nil {
+		return err
+	}
+	var reached []SongMilestone
+	for rows.Next() {
+		m := SongMilestone{Metric: "plays"}
+		if err := rows.Scan(&m.SongID, &m.Threshold); err != nil {
+			rows.Close()
+			return err
+		}
+		reached = append(reached, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range reached {
+		artistID, title, err := songArtistAndTitle(ctx, m.SongID)
+		if err != nil || artistID == "" {
+			continue
+		}
+		msg := fmt.Sprintf("🎉 **%s** just passed %d plays!", title, m.Threshold)
+		announceToDiscord(ctx, artistID, discordKindMilestone, msg)
+	}
+
+	return announceNewReleases(ctx)
+}
+
+// announceNewReleases announces songs with an ID past the releases
+// watermark. The first run only sets the watermark so existing catalogs
+// aren't announced all at once.
+func announceNewReleases(ctx context.Context) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// The watermark row stores the last announced song ID.
+	var lastID, maxID int64
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE((SELECT last_event_id FROM rollup_watermarks WHERE name = $1 FOR UPDATE), -1),
+		       COALESCE((SELECT MAX(id) FROM songs), 0);
+	`, releasesWatermark).Scan(&lastID, &maxID)
+	if err != nil {
+		return err
+	}
+	if lastID < 0 {
+		if err := writeWatermark(ctx, tx, releasesWatermark, maxID); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	}
+	if maxID <= lastID {
+		return nil
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, artist_id::text, title FROM songs
+		WHERE id > $1 AND id <= $2 AND artist_id IS NOT NULL
+		ORDER BY id;
+	`, lastID, maxID)
+	if err != nil {
+		return err
+	}
+	type release struct {
+		songID          int64
+		artistID, title string
+	}
+	var releases []release
+	for rows.Next() {
+		var r release
+		if err := rows.Scan(&r.songID, &r.artistID, &r.title); err != nil {
+			rows.Close()
+			return err
+		}
+		releases = append(releases, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if err := writeWatermark(ctx, tx, releasesWatermark, maxID); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	for _, r := range releases {
+		msg := fmt.Sprintf("🎵 New release: **%s** is out now on Leep!", r.title)
+		announceToDiscord(ctx, r.artistID, discordKindRelease, msg)
+	}
+	return nil
+}
+
+// songArtistAndTitle returns the song's artist ID ("" when unset) and title.
+func songArtistAndTitle(ctx context.Context, songID int64) (string, string, error) {
+	var (
+		artistID *string
+		title    string
+	)
+	err := db.QueryRow(ctx, `SELECT artist_id::text, title FROM songs WHERE id = $1;`, songID).Scan(&artistID, &title)
+	if err != nil || artistID == nil {
+		return "", title, err
+	}
+	return *artistID, title, nil
+}
@@ -1,6 +1,9 @@
 package main
 
-import "time"
+import (
+    "encoding/json"
+    "time"
+)
 
 type Project struct {
     ID        int64     `json:"id"`
@@ -17,11 +20,15 @@ type ProjectInvitation struct {
 }
 
 type Comment struct {
-    ID        int64     `json:"id"`
-    SongID    int64     `json:"song_id"`
-    AuthorID  string    `json:"author_id"`
-    Body      string    `json:"body"`
-    CreatedAt time.Time `json:"created_at"`
+    ID           int64      `json:"id"`
+    SongID       int64      `json:"song_id"`
+    AuthorID     string     `json:"author_id"`
+    ParentID     *int64     `json:"parent_id,omitempty"`
+    Body         string     `json:"body"`
+    HiddenAt     *time.Time `json:"hidden_at,omitempty"`
+    PinnedAt     *time.Time `json:"pinned_at,omitempty"`
+    AuthorBadges []string   `json:"author_badges,omitempty"`
+    CreatedAt    time.Time  `json:"created_at"`
 }
 
 type Review struct {
@@ -34,9 +41,1000 @@ type Review struct {
 }
 
 type Tip struct {
+    ID              int64     `json:"id"`
+    SongID          int64     `json:"song_id"`
+    SenderID        string    `json:"sender_id"`
+    Amount          float64   `json:"amount"`
+    PaymentIntentID *string   `json:"payment_intent_id,omitempty"`
+    ReviewStatus    string    `json:"review_status"`
+    CreatedAt       time.Time `json:"created_at"`
+}
+
+type ProjectGuestLink struct {
+    ID         int64      `json:"id"`
+    ProjectID  int64      `json:"project_id"`
+    Label      string     `json:"label"`
+    CreatedBy  string     `json:"created_by"`
+    ExpiresAt  time.Time  `json:"expires_at"`
+    RevokedAt  *time.Time `json:"revoked_at"`
+    LastUsedAt *time.Time `json:"last_used_at"`
+    CreatedAt  time.Time  `json:"created_at"`
+}
+
+type ProjectStem struct {
+    ID          int64     `json:"id"`
+    ProjectID   int64     `json:"project_id"`
+    UploaderID  string    `json:"uploader_id"`
+    Filename    string    `json:"filename"`
+    FileKey     string    `json:"file_key"`
+    StorageRegion string  `json:"-"`
+    SizeBytes   int64     `json:"size_bytes"`
+    ContentType string    `json:"content_type"`
+    DurationMs  *int64    `json:"duration_ms"`
+    BitRate     *int64    `json:"bit_rate"`
+    SampleRate  *int      `json:"sample_rate"`
+    Channels    *int      `json:"channels"`
+    Codec       *string   `json:"codec"`
+    ProbedAt    *time.Time `json:"probed_at"`
+    CreatedAt   time.Time `json:"created_at"`
+}
+
+type ProjectMessage struct {
+    ID           int64              `json:"id"`
+    ProjectID    int64              `json:"project_id"`
+    AuthorID     string             `json:"author_id"`
+    Body         string             `json:"body"`
+    AttachmentID *int64             `json:"attachment_id"`
+    Attachment   *ProjectAttachment `json:"attachment,omitempty"`
+    CreatedAt    time.Time          `json:"created_at"`
+}
+
+type ProjectTask struct {
+    ID          int64      `json:"id"`
+    ProjectID   int64      `json:"project_id"`
+    Title       string     `json:"title"`
+    AssigneeID  *string    `json:"assignee_id"`
+    CreatedBy   string     `json:"created_by"`
+    CompletedAt *time.Time `json:"completed_at"`
+    CreatedAt   time.Time  `json:"created_at"`
+}
+
+type Job struct {
+    ID         int64           `json:"id"`
+    Type       string          `json:"type"`
+    Payload    json.RawMessage `json:"-"`
+    Status     string          `json:"status"`
+    Progress   int             `json:"progress"`
+    Result     json.RawMessage `json:"result"`
+    Error      *string         `json:"error"`
+    Attempts   int             `json:"attempts"`
+    CreatedBy  *string         `json:"created_by"`
+    CreatedAt  time.Time       `json:"created_at"`
+    UpdatedAt  time.Time       `json:"updated_at"`
+    FinishedAt *time.Time      `json:"finished_at"`
+}
+
+type BotRule struct {
+    ID        int64     `json:"id"`
+    Kind      string    `json:"kind"`
+    Pattern   string    `json:"pattern"`
+    Action    string    `json:"action"`
+    Enabled   bool      `json:"enabled"`
+    Note      string    `json:"note"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+type Experiment struct {
+    ID             int64               `json:"id"`
+    Key            string              `json:"key"`
+    Description    string              `json:"description"`
+    Status         string              `json:"status"`
+    TrafficPercent int                 `json:"traffic_percent"`
+    Variants       []ExperimentVariant `json:"variants"`
+    CreatedAt      time.Time           `json:"created_at"`
+}
+
+type Notification struct {
+    ID        int64           `json:"id"`
+    UserID    string          `json:"user_id"`
+    Kind      string          `json:"kind"`
+    Title     string          `json:"title"`
+    Body      string          `json:"body"`
+    Data      json.RawMessage `json:"data"`
+    ReadAt    *time.Time      `json:"read_at"`
+    CreatedAt time.Time       `json:"created_at"`
+}
+
+type ArtistAlert struct {
+    ID               int64      `json:"id"`
+    UserID           string     `json:"user_id"`
+    SongID           *int64     `json:"song_id"`
+    Metric           string     `json:"metric"`
+    Condition        string     `json:"condition"`
+    Threshold        float64    `json:"threshold"`
+    Enabled          bool       `json:"enabled"`
+    LastTriggeredDay *time.Time `json:"last_triggered_day"`
+    CreatedAt        time.Time  `json:"created_at"`
+}
+
+type ArtistWebhook struct {
+    ID        int64     `json:"id"`
+    UserID    string    `json:"user_id"`
+    URL       string    `json:"url"`
+    Secret    string    `json:"-"`
+    Events    []string  `json:"events"`
+    Enabled   bool      `json:"enabled"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+    ID             int64           `json:"id"`
+    WebhookID      int64           `json:"webhook_id"`
+    EventType      string          `json:"event_type"`
+    Payload        json.RawMessage `json:"payload"`
+    Status         string          `json:"status"`
+    Attempts       int             `json:"attempts"`
+    ResponseStatus *int            `json:"response_status"`
+    LastError      *string         `json:"last_error"`
+    CreatedAt      time.Time       `json:"created_at"`
+    DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+type Payout struct {
+    ID            int64     `json:"id"`
+    ArtistID      string    `json:"artist_id"`
+    Amount        float64   `json:"amount"`
+    Status        string    `json:"status"`
+    FailureReason *string   `json:"failure_reason"`
+    CreatedAt     time.Time `json:"created_at"`
+    UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type APIKey struct {
+    ID         int64      `json:"id"`
+    UserID     string     `json:"user_id"`
+    Name       string     `json:"name"`
+    Prefix     string     `json:"prefix"`
+    Scopes     []string   `json:"scopes"`
+    RateLimitPerMinute int `json:"rate_limit_per_minute"`
+    LastUsedAt *time.Time `json:"last_used_at"`
+    RevokedAt  *time.Time `json:"revoked_at"`
+    CreatedAt  time.Time  `json:"created_at"`
+}
+
+type Follow struct {
+    ID         int64     `json:"id"`
+    FollowerID string    `json:"follower_id"`
+    FolloweeID string    `json:"followee_id"`
+    CreatedAt  time.Time `json:"created_at"`
+}
+
+type DiscordIntegration struct {
+    ID               int64     `json:"id"`
+    UserID           string    `json:"user_id"`
+    WebhookURL       string    `json:"-"`
+    ChannelName      string    `json:"channel_name"`
+    NotifyReleases   bool      `json:"notify_releases"`
+    NotifyMilestones bool      `json:"notify_milestones"`
+    Enabled          bool      `json:"enabled"`
+    CreatedAt        time.Time `json:"created_at"`
+    UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type DiscordDelivery struct {
+    ID             int64      `json:"id"`
+    Kind           string     `json:"kind"`
+    Content        string     `json:"content"`
+    Status         string     `json:"status"`
+    Attempts       int        `json:"attempts"`
+    ResponseStatus *int       `json:"response_status"`
+    LastError      *string    `json:"last_error"`
+    CreatedAt      time.Time  `json:"created_at"`
+    DeliveredAt    *time.Time `json:"delivered_at"`
+}
+
+type SongMilestone struct {
+    SongID    int64     `json:"song_id"`
+    Metric    string    `json:"metric"`
+    Threshold int64     `json:"threshold"`
+    ReachedAt time.Time `json:"reached_at"`
+}
+
+type ScheduledRelease struct {
+    ID        int64     `json:"id"`
+    ArtistID  string    `json:"artist_id"`
+    SongID    *int64    `json:"song_id"`
+    Kind      string    `json:"kind"`
+    Title     string    `json:"title"`
+    Notes     string    `json:"notes"`
+    StartsAt  time.Time `json:"starts_at"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SongCredit struct {
+    ID       int64   `json:"id"`
+    SongID   int64   `json:"song_id"`
+    Name     string  `json:"name"`
+    Role     string  `json:"role"`
+    UserID   *string `json:"user_id"`
+    Position int     `json:"position"`
+}
+
+type SmartLink struct {
+    ID        int64             `json:"id"`
+    ArtistID  string            `json:"artist_id"`
+    SongID    *int64            `json:"song_id"`
+    ReleaseID *int64            `json:"release_id"`
+    Slug      string            `json:"slug"`
+    Title     string            `json:"title"`
+    Links     []SmartLinkTarget `json:"links"`
+    Pixels    map[string]string `json:"pixels"`
+    CreatedAt time.Time         `json:"created_at"`
+    UpdatedAt time.Time         `json:"updated_at"`
+}
+
+type SmartLinkTarget struct {
+    Platform string `json:"platform"`
+    URL      string `json:"url"`
+}
+
+type Song struct {
+    ID              int64           `json:"id"`
+    Title           string          `json:"title"`
+    ArtistID        *string         `json:"artist_id"`
+    Published       bool            `json:"published"`
+    DurationSeconds *int            `json:"duration_seconds"`
+    ReleaseDate     *time.Time      `json:"release_date"`
+    ISRC            *string         `json:"isrc"`
+    Label           *string         `json:"label"`
+    Genre           *string         `json:"genre"`
+    ArtworkURL      *string         `json:"artwork_url"`
+    ArtworkStatus   *string         `json:"artwork_status,omitempty"`
+    WaveformURL     *string         `json:"waveform_url"`
+    CommentPolicy   string          `json:"comment_policy"`
+    DownloadsEnabled bool           `json:"downloads_enabled"`
+    DownloadGate    string          `json:"download_gate"`
+    Explicit        bool            `json:"explicit"`
+    ArtistVerified  bool            `json:"artist_verified"`
+    RepostCount     int64           `json:"repost_count"`
+    Episode         *SongEpisode    `json:"episode,omitempty"`
+    Credits         []SongCredit     `json:"credits"`
+    Renditions      []SongRendition  `json:"renditions"`
+    Transcripts     []SongTranscript `json:"transcripts"`
+}
+
+type SongRendition struct {
+    SongID         int64      `json:"-"`
+    Name           string     `json:"name"`
+    StorageKey     string     `json:"-"`
+    StorageRegion  string     `json:"-"`
+    ContentType    string     `json:"content_type"`
+    SizeBytes      int64      `json:"size_bytes"`
+    Status         string     `json:"status"`
+    ErrorCode      *string    `json:"error_code,omitempty"`
+    Error          *string    `json:"error,omitempty"`
+    DurationMs     *int64     `json:"duration_ms"`
+    BitRate        *int64     `json:"bit_rate"`
+    SampleRate     *int       `json:"sample_rate"`
+    Channels       *int       `json:"channels"`
+    Codec          *string    `json:"codec"`
+    IntegratedLUFS *float64   `json:"integrated_lufs"`
+    TruePeakDBTP   *float64   `json:"true_peak_dbtp"`
+    ReplayGainDB   *float64   `json:"replay_gain_db"`
+    SourceKey      *string    `json:"-"`
+    ProcessedAt    *time.Time `json:"processed_at"`
+}
+
+type ProjectRelease struct {
+    ID                 int64      `json:"id"`
+    ProjectID          int64      `json:"project_id"`
+    SongID             int64      `json:"song_id"`
+    MasterStemID       int64      `json:"master_stem_id"`
+    CreatedBy          string     `json:"created_by"`
+    ToleranceSeconds   float64    `json:"tolerance_seconds"`
+    AlignmentStatus    string     `json:"alignment_status"`
+    MasterSeconds      *float64   `json:"master_seconds"`
+    LongestStemID      *int64     `json:"longest_stem_id"`
+    LongestStemSeconds *float64   `json:"longest_stem_seconds"`
+    AlignmentError     *string    `json:"alignment_error"`
+    CreatedAt          time.Time  `json:"created_at"`
+    CheckedAt          *time.Time `json:"checked_at"`
+}
+
+type TeamMember struct {
+    ArtistID  string    `json:"artist_id"`
+    MemberID  string    `json:"member_id"`
+    Scopes    []string  `json:"scopes"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Label struct {
+    ID        int64     `json:"id"`
+    OwnerID   string    `json:"owner_id"`
+    Name      string    `json:"name"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+type LabelArtist struct {
+    LabelID     int64     `json:"label_id"`
+    ArtistID    string    `json:"artist_id"`
+    DisplayName string    `json:"display_name"`
+    CreatedAt   time.Time `json:"created_at"`
+}
+
+type RemixContest struct {
+    ID        int64     `json:"id"`
+    ArtistID  string    `json:"artist_id"`
+    SongID    *int64    `json:"song_id"`
+    Title     string    `json:"title"`
+    Rules     string    `json:"rules"`
+    Deadline  time.Time `json:"deadline"`
+    Open      bool      `json:"open"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+type ContestStem struct {
+    ID          int64     `json:"id"`
+    ContestID   int64     `json:"contest_id"`
+    Filename    string    `json:"filename"`
+    StorageKey  string    `json:"-"`
+    StorageRegion string  `json:"-"`
+    SizeBytes   int64     `json:"size_bytes"`
+    ContentType string    `json:"content_type"`
+    DownloadURL string    `json:"download_url,omitempty"`
+    CreatedAt   time.Time `json:"created_at"`
+}
+
+type ContestEntry struct {
+    ID          int64     `json:"id"`
+    ContestID   int64     `json:"contest_id"`
+    ProducerID  string    `json:"producer_id"`
+    Title       string    `json:"title"`
+    StorageKey  string    `json:"-"`
+    StorageRegion string  `json:"-"`
+    SizeBytes   int64     `json:"size_bytes"`
+    ContentType string    `json:"content_type"`
+    Shortlisted bool      `json:"shortlisted"`
+    Placement   *int      `json:"placement"`
+    StreamURL   string    `json:"stream_url,omitempty"`
+    CreatedAt   time.Time `json:"created_at"`
+    UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type AuthResponse struct {
+    AccessToken  string          `json:"access_token"`
+    TokenType    string          `json:"token_type"`
+    ExpiresIn    int64           `json:"expires_in"`
+    ExpiresAt    int64           `json:"expires_at"`
+    RefreshToken string          `json:"refresh_token"`
+    User         json.RawMessage `json:"user"`
+}
+
+type SongAnnouncement struct {
     ID        int64     `json:"id"`
     SongID    int64     `json:"song_id"`
-    SenderID  string    `json:"sender_id"`
+    CreatedBy string    `json:"created_by"`
+    Body      string    `json:"body"`
+    PublishAt time.Time `json:"publish_at"`
+    Status    string    `json:"status"`
+    JobID     *int64    `json:"job_id,omitempty"`
+    CommentID *int64    `json:"comment_id,omitempty"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SocialConnection struct {
+    ID           int64      `json:"id"`
+    UserID       string     `json:"user_id"`
+    Provider     string     `json:"provider"`
+    AccountName  string     `json:"account_name"`
+    AccessToken  *string    `json:"-"`
+    RefreshToken *string    `json:"-"`
+    ExpiresAt    *time.Time `json:"-"`
+    WebhookURL   *string    `json:"webhook_url,omitempty"`
+    Secret       *string    `json:"-"`
+    AutoPost     bool       `json:"auto_post"`
+    Status       string     `json:"status"`
+    LastError    *string    `json:"last_error"`
+    CreatedAt    time.Time  `json:"created_at"`
+    UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+type SocialPost struct {
+    ID           int64      `json:"id"`
+    ConnectionID int64      `json:"connection_id"`
+    Provider     string     `json:"provider"`
+    SongID       int64      `json:"song_id"`
+    Content      string     `json:"content"`
+    LinkURL      *string    `json:"link_url"`
+    Status       string     `json:"status"`
+    Attempts     int        `json:"attempts"`
+    ExternalID   *string    `json:"external_id"`
+    LastError    *string    `json:"last_error"`
+    CreatedAt    time.Time  `json:"created_at"`
+    PostedAt     *time.Time `json:"posted_at"`
+}
+
+type TipReview struct {
+    Tip
+    ArtistID   *string    `json:"artist_id"`
+    Reasons    []string   `json:"review_reasons"`
+    ReviewedBy *string    `json:"reviewed_by"`
+    ReviewedAt *time.Time `json:"reviewed_at"`
+}
+
+type TipLedgerEntry struct {
+    ID        int64     `json:"id"`
+    TipID     int64     `json:"tip_id"`
+    ArtistID  string    `json:"artist_id"`
+    Action    string    `json:"action"`
     Amount    float64   `json:"amount"`
+    AdminID   *string   `json:"admin_id"`
+    Note      *string   `json:"note"`
     CreatedAt time.Time `json:"created_at"`
 }
+
+type TipDispute struct {
+    ID              int64      `json:"id"`
+    StripeDisputeID string     `json:"stripe_dispute_id"`
+    PaymentIntentID *string    `json:"payment_intent_id"`
+    TipID           *int64     `json:"tip_id"`
+    ArtistID        *string    `json:"artist_id"`
+    Amount          float64    `json:"amount"`
+    FrozenAmount    float64    `json:"frozen_amount"`
+    Currency        string     `json:"currency"`
+    Reason          string     `json:"reason"`
+    Status          string     `json:"status"`
+    EvidenceDueBy   *time.Time `json:"evidence_due_by"`
+    CreatedAt       time.Time  `json:"created_at"`
+    UpdatedAt       time.Time  `json:"updated_at"`
+    ClosedAt        *time.Time `json:"closed_at"`
+}
+
+type AccountLink struct {
+    Provider       string    `json:"provider"`
+    ExternalUserID string    `json:"external_user_id"`
+    ExternalName   string    `json:"external_name"`
+    ArtistID       *string   `json:"artist_id"`
+    CreatedAt      time.Time `json:"created_at"`
+    UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type ProjectEscrow struct {
+    ID             int64      `json:"id"`
+    ProjectID      int64      `json:"project_id"`
+    ArtistID       string     `json:"artist_id"`
+    ProducerID     string     `json:"producer_id"`
+    Milestone      string     `json:"milestone"`
+    Amount         float64    `json:"amount"`
+    Currency       string     `json:"currency"`
+    Status         string     `json:"status"`
+    DisputeReason  *string    `json:"dispute_reason"`
+    DisputedBy     *string    `json:"disputed_by"`
+    ResolutionNote *string    `json:"resolution_note"`
+    LastError      *string    `json:"last_error"`
+    CreatedAt      time.Time  `json:"created_at"`
+    FundedAt       *time.Time `json:"funded_at"`
+    SettledAt      *time.Time `json:"settled_at"`
+    UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type AuthSession struct {
+    ID         string    `json:"id"`
+    UserAgent  *string   `json:"user_agent"`
+    IP         *string   `json:"ip"`
+    AAL        *string   `json:"aal"`
+    Current    bool      `json:"current"`
+    CreatedAt  time.Time `json:"created_at"`
+    LastUsedAt time.Time `json:"last_used_at"`
+}
+
+type DomainEvent struct {
+    ID         int64           `json:"id"`
+    Type       string          `json:"type"`
+    EntityType string          `json:"entity_type"`
+    EntityID   string          `json:"entity_id"`
+    ActorID    *string         `json:"actor_id"`
+    Data       json.RawMessage `json:"data"`
+    OccurredAt time.Time       `json:"occurred_at"`
+}
+
+type UploadSession struct {
+    ID            int64      `json:"id"`
+    UserID        string     `json:"user_id"`
+    TargetType    string     `json:"target_type"`
+    TargetID      int64      `json:"target_id"`
+    Filename      string     `json:"filename"`
+    ContentType   string     `json:"content_type"`
+    SizeBytes     int64      `json:"size_bytes"`
+    SHA256        string     `json:"sha256"`
+    PartSize      int64      `json:"part_size"`
+    PartCount     int        `json:"part_count"`
+    StorageKey    string     `json:"-"`
+    StorageRegion string     `json:"-"`
+    MultipartID   string     `json:"-"`
+    Status        string     `json:"status"`
+    Error         *string    `json:"error"`
+    AssembledAt   *time.Time `json:"-"`
+    AttachedID    *int64     `json:"attached_id"`
+    ExpiresAt     time.Time  `json:"expires_at"`
+    CreatedAt     time.Time  `json:"created_at"`
+    UpdatedAt     time.Time  `json:"updated_at"`
+    CompletedAt   *time.Time `json:"completed_at"`
+}
+
+type UploadPart struct {
+    PartNumber int       `json:"part_number"`
+    SizeBytes  int64     `json:"size_bytes"`
+    ETag       string    `json:"etag"`
+    UploadedAt time.Time `json:"uploaded_at"`
+}
+
+type ImageReview struct {
+    ID         int64      `json:"id"`
+    Kind       string     `json:"kind"`
+    SubjectID  string     `json:"subject_id"`
+    Hash       string     `json:"hash"`
+    Ext        string     `json:"ext"`
+    URL        string     `json:"url"`
+    UploadedBy string     `json:"uploaded_by"`
+    Reason     string     `json:"reason"`
+    Labels     []string   `json:"labels"`
+    Score      *float64   `json:"score"`
+    Status     string     `json:"status"`
+    ReviewedBy *string    `json:"reviewed_by"`
+    ReviewNote *string    `json:"review_note"`
+    ReviewedAt *time.Time `json:"reviewed_at"`
+    CreatedAt  time.Time  `json:"created_at"`
+}
+
+type Profile struct {
+    ID               string            `json:"id"`
+    Handle           *string           `json:"handle"`
+    DisplayName      *string           `json:"display_name"`
+    Verified         bool              `json:"verified"`
+    Bio              *string           `json:"bio"`
+    Links            []ProfileLink     `json:"links"`
+    GenrePreferences []string          `json:"genre_preferences"`
+    AvatarURL        *string           `json:"avatar_url"`
+    AvatarURLs       map[string]string `json:"avatar_urls,omitempty"`
+    AvatarStatus     *string           `json:"avatar_status,omitempty"`
+    BannerURL        *string           `json:"banner_url"`
+    BannerURLs       map[string]string `json:"banner_urls,omitempty"`
+    BannerStatus     *string           `json:"banner_status,omitempty"`
+    UpdatedAt        *time.Time        `json:"updated_at"`
+}
+
+type ProfileLink struct {
+    Label string `json:"label"`
+    URL   string `json:"url"`
+}
+
+type UserConsent struct {
+    ID         int64     `json:"id"`
+    UserID     string    `json:"user_id"`
+    Document   string    `json:"document"`
+    Version    string    `json:"version"`
+    IP         *string   `json:"ip"`
+    UserAgent  *string   `json:"user_agent"`
+    AcceptedAt time.Time `json:"accepted_at"`
+}
+
+type LegalDocument struct {
+    Document string `json:"document"`
+    Version  string `json:"version"`
+}
+
+type VerificationApplication struct {
+    ID         int64         `json:"id"`
+    UserID     string        `json:"user_id"`
+    Links      []ProfileLink `json:"links"`
+    Evidence   *string       `json:"evidence"`
+    Status     string        `json:"status"`
+    ReviewedBy *string       `json:"reviewed_by"`
+    ReviewNote *string       `json:"review_note"`
+    ReviewedAt *time.Time    `json:"reviewed_at"`
+    CreatedAt  time.Time     `json:"created_at"`
+}
+
+type TaxLine struct {
+    Kind    string  `json:"kind"`
+    Country string  `json:"country"`
+    Rate    float64 `json:"rate"`
+    Amount  float64 `json:"amount"`
+}
+
+type TipReceipt struct {
+    TipID     int64     `json:"tip_id"`
+    SongID    int64     `json:"song_id"`
+    SongTitle *string   `json:"song_title"`
+    Amount    float64   `json:"amount"`
+    TaxLines  []TaxLine `json:"tax_lines"`
+    Total     float64   `json:"total"`
+    Currency  string    `json:"currency"`
+    PaidAt    time.Time `json:"paid_at"`
+}
+
+type SongTranscript struct {
+    Kind      string    `json:"kind"`
+    Language  string    `json:"language"`
+    Format    string    `json:"format"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ImpersonationSession struct {
+    ID        int64      `json:"id"`
+    AdminID   string     `json:"admin_id"`
+    UserID    string     `json:"user_id"`
+    Scope     string     `json:"scope"`
+    Reason    string     `json:"reason"`
+    ExpiresAt time.Time  `json:"expires_at"`
+    RevokedAt *time.Time `json:"revoked_at"`
+    RevokedBy *string    `json:"revoked_by"`
+    CreatedAt time.Time  `json:"created_at"`
+}
+
+type ImpersonationAuditEntry struct {
+    ID        int64     `json:"id"`
+    SessionID int64     `json:"session_id"`
+    AdminID   string    `json:"admin_id"`
+    UserID    string    `json:"user_id"`
+    Method    string    `json:"method"`
+    Path      string    `json:"path"`
+    Route     *string   `json:"route"`
+    Status    int       `json:"status"`
+    IP        *string   `json:"ip"`
+    UserAgent *string   `json:"user_agent"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+type SongEpisode struct {
+    ShowID int64  `json:"show_id"`
+    Season *int   `json:"season"`
+    Number *int   `json:"number"`
+    Type   string `json:"type"`
+}
+
+type Show struct {
+    ID          int64     `json:"id"`
+    OwnerID     string    `json:"owner_id"`
+    Title       string    `json:"title"`
+    Description *string   `json:"description"`
+    Author      *string   `json:"author"`
+    Language    string    `json:"language"`
+    Category    *string   `json:"category"`
+    ImageURL    *string   `json:"image_url"`
+    Explicit    bool      `json:"explicit"`
+    ShowType    string    `json:"show_type"`
+    CreatedAt   time.Time `json:"created_at"`
+    UpdatedAt   time.Time `json:"updated_at"`
+    Episodes    []Song    `json:"episodes,omitempty"`
+}
+
+type EpisodeAnalytics struct {
+    SongID         int64   `json:"song_id"`
+    Title          string  `json:"title"`
+    Season         *int    `json:"season"`
+    Episode        *int    `json:"episode"`
+    Downloads      int64   `json:"downloads"`
+    Plays          int64   `json:"plays"`
+    CompletionRate float64 `json:"completion_rate"`
+}
+
+type LiveSession struct {
+    ID                int64       `json:"id"`
+    ArtistID          string      `json:"artist_id"`
+    SongID            int64       `json:"song_id"`
+    Title             string      `json:"title"`
+    Status            string      `json:"status"`
+    ScheduledFor      *time.Time  `json:"scheduled_for"`
+    StartedAt         *time.Time  `json:"started_at"`
+    EndedAt           *time.Time  `json:"ended_at"`
+    PeakListeners     int         `json:"peak_listeners"`
+    HLSPlaybackURL    *string     `json:"hls_playback_url,omitempty"`
+    WebRTCPlaybackURL *string     `json:"webrtc_playback_url,omitempty"`
+    Ingest            *LiveIngest `json:"ingest,omitempty"`
+    CreatedAt         time.Time   `json:"created_at"`
+}
+
+type LiveIngest struct {
+    RTMPURL   *string `json:"rtmp_url"`
+    StreamKey *string `json:"stream_key"`
+    WebRTCURL *string `json:"webrtc_url"`
+}
+
+type LiveChatMessage struct {
+    ID        int64     `json:"id"`
+    SessionID int64     `json:"session_id"`
+    AuthorID  string    `json:"author_id"`
+    Body      string    `json:"body"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+type LiveSessionStats struct {
+    SessionID       int64   `json:"session_id"`
+    Status          string  `json:"status"`
+    DurationSeconds int64   `json:"duration_seconds"`
+    UniqueListeners int64   `json:"unique_listeners"`
+    PeakListeners   int     `json:"peak_listeners"`
+    ChatMessages    int64   `json:"chat_messages"`
+    Chatters        int64   `json:"chatters"`
+    Tips            int64   `json:"tips"`
+    TipTotal        float64 `json:"tip_total"`
+}
+
+type Clip struct {
+    ID            int64            `json:"id"`
+    Slug          string           `json:"slug"`
+    SongID        int64            `json:"song_id"`
+    CreatorID     string           `json:"creator_id"`
+    Title         string           `json:"title"`
+    StartMs       int64            `json:"start_ms"`
+    EndMs         int64            `json:"end_ms"`
+    Status        string           `json:"status"`
+    Error         *string          `json:"error,omitempty"`
+    StorageKey    *string          `json:"-"`
+    StorageRegion *string          `json:"-"`
+    Plays         int64            `json:"plays"`
+    ShareURL      string           `json:"share_url"`
+    AudioURL      string           `json:"audio_url,omitempty"`
+    Song          *ClipAttribution `json:"song"`
+    CreatedAt     time.Time        `json:"created_at"`
+    RenderedAt    *time.Time       `json:"rendered_at"`
+}
+
+// ClipAttribution links a clip back to the full song, with the offset the
+// clip starts at so players can continue from there.
+type ClipAttribution struct {
+    SongID         int64   `json:"song_id"`
+    Title          string  `json:"title"`
+    ArtistID       *string `json:"artist_id"`
+    ArtistVerified bool    `json:"artist_verified"`
+    ArtworkURL     *string `json:"artwork_url"`
+    URL            string  `json:"url"`
+    StartMs        int64   `json:"start_ms"`
+}
+
+type StemComment struct {
+    ID           int64              `json:"id"`
+    StemID       int64              `json:"stem_id"`
+    AuthorID     string             `json:"author_id"`
+    AuthorName   string             `json:"author_name"`
+    AtMs         int64              `json:"at_ms"`
+    Body         string             `json:"body"`
+    AttachmentID *int64             `json:"attachment_id"`
+    Attachment   *ProjectAttachment `json:"attachment,omitempty"`
+    CreatedAt    time.Time          `json:"created_at"`
+}
+
+type SimilarArtist struct {
+    ArtistID        string    `json:"artist_id"`
+    Profile         *Profile  `json:"profile"`
+    Score           float64   `json:"score"`
+    SharedListeners int64     `json:"shared_listeners"`
+    SharedGenres    []string  `json:"shared_genres"`
+    ComputedAt      time.Time `json:"computed_at"`
+}
+
+type Collection struct {
+    ID          int64      `json:"id"`
+    Slug        string     `json:"slug"`
+    Title       string     `json:"title"`
+    Description *string    `json:"description"`
+    Kind        string     `json:"kind"`
+    Genre       *string    `json:"genre"`
+    CoverURL    *string    `json:"cover_url"`
+    Position    int        `json:"position"`
+    StartsAt    time.Time  `json:"starts_at"`
+    EndsAt      *time.Time `json:"ends_at"`
+    CreatedBy   string     `json:"created_by"`
+    CreatedAt   time.Time  `json:"created_at"`
+    UpdatedAt   time.Time  `json:"updated_at"`
+    Songs       []Song     `json:"songs,omitempty"`
+}
+
+type HomeModuleConfig struct {
+    Type           string `json:"type"`
+    Title          string `json:"title,omitempty"`
+    CollectionSlug string `json:"collection_slug,omitempty"`
+    Limit          int    `json:"limit"`
+}
+
+type HomeLayout struct {
+    ID        int64              `json:"id"`
+    Modules   []HomeModuleConfig `json:"modules"`
+    CreatedBy string             `json:"created_by"`
+    CreatedAt time.Time          `json:"created_at"`
+}
+
+type HomeModule struct {
+    Type       string      `json:"type"`
+    Title      string      `json:"title,omitempty"`
+    Collection *Collection `json:"collection,omitempty"`
+    Songs      []Song      `json:"songs"`
+}
+
+type OpenGraph struct {
+    Type        string  `json:"type"`
+    Title       string  `json:"title"`
+    Description string  `json:"description"`
+    Image       *string `json:"image"`
+    URL         string  `json:"url"`
+}
+
+type OAuthClient struct {
+    ID                 int64      `json:"id"`
+    ClientID           string     `json:"client_id"`
+    Name               string     `json:"name"`
+    Scopes             []string   `json:"scopes"`
+    RateLimitPerMinute int        `json:"rate_limit_per_minute"`
+    LastUsedAt         *time.Time `json:"last_used_at"`
+    RevokedAt          *time.Time `json:"revoked_at"`
+    CreatedAt          time.Time  `json:"created_at"`
+}
+
+type Repost struct {
+    ID        int64     `json:"id"`
+    UserID    string    `json:"user_id"`
+    SongID    int64     `json:"song_id"`
+    CreatedAt time.Time `json:"created_at"`
+    Song      *Song     `json:"song,omitempty"`
+}
+
+type UploadToken struct {
+    ID            int64      `json:"id"`
+    UserID        string     `json:"user_id"`
+    TargetType    string     `json:"target_type"`
+    TargetID      int64      `json:"target_id"`
+    Filename      string     `json:"filename"`
+    ContentType   string     `json:"content_type"`
+    MaxBytes      int64      `json:"max_bytes"`
+    KeyPrefix     string     `json:"key_prefix"`
+    StorageKey    string     `json:"key"`
+    StorageRegion string     `json:"-"`
+    ExpiresAt     time.Time  `json:"expires_at"`
+    UsedAt        *time.Time `json:"used_at"`
+    CreatedAt     time.Time  `json:"created_at"`
+}
+
+type TrashItem struct {
+    Kind      string    `json:"kind"`
+    ID        int64     `json:"id"`
+    Label     string    `json:"label,omitempty"`
+    TrashedAt time.Time `json:"trashed_at"`
+    PurgeAt   time.Time `json:"purge_at"`
+}
+
+type PrivacySettings struct {
+    HideListeningHistory bool `json:"hide_listening_history"`
+    HideLibrary          bool `json:"hide_library"`
+    HideTips             bool `json:"hide_tips"`
+}
+
+type PressKit struct {
+    SongID       int64            `json:"song_id"`
+    ArtistID     string           `json:"artist_id"`
+    Headline     string           `json:"headline"`
+    Notes        string           `json:"notes"`
+    EmbargoUntil time.Time        `json:"embargo_until"`
+    Recipients   []PressRecipient `json:"recipients"`
+    CreatedAt    time.Time        `json:"created_at"`
+    UpdatedAt    time.Time        `json:"updated_at"`
+}
+
+type PressRecipient struct {
+    ID            int64      `json:"id"`
+    SongID        int64      `json:"song_id"`
+    Name          string     `json:"name"`
+    Email         string     `json:"email"`
+    Outlet        string     `json:"outlet"`
+    PreviewStatus string     `json:"preview_status"`
+    PreviewError  *string    `json:"preview_error,omitempty"`
+    StorageKey    *string    `json:"-"`
+    StorageRegion *string    `json:"-"`
+    Views         int64      `json:"views"`
+    Plays         int64      `json:"plays"`
+    FirstViewedAt *time.Time `json:"first_viewed_at"`
+    LastViewedAt  *time.Time `json:"last_viewed_at"`
+    RevokedAt     *time.Time `json:"revoked_at"`
+    CreatedAt     time.Time  `json:"created_at"`
+}
+
+// PressPage is what a press recipient's link shows.
+type PressPage struct {
+    SongID        int64     `json:"song_id"`
+    Title         string    `json:"title"`
+    ArtistID      *string   `json:"artist_id"`
+    ArtworkURL    *string   `json:"artwork_url"`
+    Headline      string    `json:"headline"`
+    Notes         string    `json:"notes"`
+    EmbargoUntil  time.Time `json:"embargo_until"`
+    RecipientName string    `json:"recipient_name"`
+    PreviewStatus string    `json:"preview_status"`
+    AudioURL      string    `json:"audio_url,omitempty"`
+}
+
+type SystemPlaylist struct {
+    ID          int64                `json:"id"`
+    Kind        string               `json:"kind"`
+    Title       string               `json:"title"`
+    WeekStart   string               `json:"week_start"`
+    GeneratedAt time.Time            `json:"generated_at"`
+    RefreshesAt time.Time            `json:"refreshes_at"`
+    Songs       []SystemPlaylistSong `json:"songs"`
+}
+
+// SystemPlaylistSong is a generated pick and the artist from the listener's
+// own rotation that led to it.
+type SystemPlaylistSong struct {
+    Song
+    BecauseArtistID *string `json:"because_artist_id"`
+}
+
+type Badge struct {
+    Badge       string    `json:"badge"`
+    Title       string    `json:"title"`
+    Description string    `json:"description"`
+    AwardedAt   time.Time `json:"awarded_at"`
+}
+
+// RoomPresence is who has heartbeated in a project or song room recently.
+type RoomPresence struct {
+    Room             string   `json:"room"`
+    Present          []string `json:"present"`
+    Typing           []string `json:"typing"`
+    HeartbeatSeconds int      `json:"heartbeat_seconds"`
+    Throttled        bool     `json:"throttled,omitempty"`
+}
+
+// RelatedSong is a recommendation from GET /songs/:id/related, with the
+// signals that put it there.
+type RelatedSong struct {
+    Song
+    Score   float64  `json:"score"`
+    Reasons []string `json:"reasons"`
+}
+
+// ProjectAttachment is an image or voice memo for project chat or a stem
+// comment. URL is set once the file is clean.
+type ProjectAttachment struct {
+    ID            int64     `json:"id"`
+    ProjectID     int64     `json:"project_id"`
+    UploaderID    string    `json:"uploader_id"`
+    Kind          string    `json:"kind"`
+    ContentType   string    `json:"content_type"`
+    SizeBytes     int64     `json:"size_bytes"`
+    StorageKey    string    `json:"-"`
+    StorageRegion string    `json:"-"`
+    DurationMs    *int64    `json:"duration_ms"`
+    ScanStatus    string    `json:"scan_status"`
+    URL           *string   `json:"url,omitempty"`
+    CreatedAt     time.Time `json:"created_at"`
+}
+
+// TrendingSong is a song on the trending chart with its decayed score and
+// the raw engagement counts behind it.
+type TrendingSong struct {
+    Song
+    Score    float64 `json:"score"`
+    Plays    int64   `json:"plays"`
+    Tips     int64   `json:"tips"`
+    Comments int64   `json:"comments"`
+}
+
+// SongAudioVersion is one original a song has had. Current marks the one
+// the song plays now.
+type SongAudioVersion struct {
+    SongID        int64     `json:"song_id"`
+    Version       int       `json:"version"`
+    StorageKey    string    `json:"-"`
+    StorageRegion string    `json:"-"`
+    ContentType   string    `json:"content_type"`
+    SizeBytes     int64     `json:"size_bytes"`
+    DurationMs    *int64    `json:"duration_ms"`
+    UploadedBy    *string   `json:"uploaded_by"`
+    Current       bool      `json:"current"`
+    CreatedAt     time.Time `json:"created_at"`
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Artwork, avatars, and banners go through the image moderator before they are
+// shown. An upload starts "pending" and the image_moderation job checks it:
+// a clean image becomes "approved", a flagged one (or one the moderator
+// still couldn't check after the job's retries) is "held" for an admin,
+// who approves or rejects it. Anything not approved is served as the
+// placeholder. With no moderator configured uploads are approved at once.
+const (
+	imageModerationJob = "image_moderation"
+
+	imageKindArtwork = "artwork"
+	imageKindAvatar  = "avatar"
+	imageKindBanner  = "banner"
+
+	moderationPending  = "pending"
+	moderationHeld     = "held"
+	moderationApproved = "approved"
+	moderationRejected = "rejected"
+
+	placeholderAsset        = "placeholder.png"
+	placeholderCacheControl = "public, max-age=3600"
+	placeholderSize         = 512
+)
+
+// ImageModerator decides whether an image may be shown publicly.
+type ImageModerator interface {
+	Moderate(ctx context.Context, data []byte, contentType string) (imageVerdict, error)
+}
+
+type imageVerdict struct {
+	Flagged bool     `json:"flagged"`
+	Labels  []string `json:"labels"`
+	Score   *float64 `json:"score"`
+}
+
+// imageModerator is nil when moderation is off.
+var imageModerator ImageModerator
+
+var placeholderPNG = renderPlaceholder()
+
+// renderPlaceholder draws the neutral square served in place of images
+// that aren't approved.
+func renderPlaceholder() []byte {
+	img := image.NewGray(image.Rect(0, 0, placeholderSize, placeholderSize))
+	for i := range img.Pix {
+		img.Pix[i] = 0x2a
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+// InitImageModeration selects the moderator from IMAGE_MODERATION.
+func InitImageModeration() error {
+	switch cfg.ImageModeration {
+	case "":
+		return nil
+	case "http":
+		if cfg.ImageModerationURL == "" {
+			return errors.New("IMAGE_MODERATION=http needs IMAGE_MODERATION_URL, images are not moderated")
+		}
+		imageModerator = httpModerator{
+			url:  cfg.ImageModerationURL,
+			key:  cfg.ImageModerationKey,
+			http: &http.Client{Timeout: 30 * time.Second},
+		}
+	default:
+		return fmt.Errorf("unknown IMAGE_MODERATION %q, images are not moderated", cfg.ImageModeration)
+	}
+	log.Printf("✅ Moderating artwork, avatars, and banners with %s", cfg.ImageModeration)
+	return nil
+}
+
+// httpModerator POSTs the image body to a moderation service, which answers
+// {"flagged":bool,"labels":[...],"score":0.97}.
+type httpModerator struct {
+	url  string
+	key  string
+	http *http.Client
+}
+
+func (m httpModerator) Moderate(ctx context.Context, data []byte, contentType string) (imageVerdict, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url, bytes.NewReader(data))
+	if err != nil {
+		return imageVerdict{}, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if m.key != "" {
+		req.Header.Set("Authorization", "Bearer "+m.key)
+	}
+
+	resp, err := m.http.Do(req)
+	if err != nil {
+		return imageVerdict{}, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return imageVerdict{}, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return imageVerdict{}, fmt.Errorf("moderation service returned %d: %s", resp.StatusCode, raw)
+	}
+
+	var v imageVerdict
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return imageVerdict{}, err
+	}
+	return v, nil
+}
+
+// initialModerationStatus is the status a newly uploaded image starts in.
+func initialModerationStatus() string {
+	if imageModerator == nil {
+		return moderationApproved
+	}
+	return moderationPending
+}
+
+// moderatedImageURL is the URL to show for an image in status.
+func moderatedImageURL(hash, ext, status string) string {
+	if status != moderationApproved {
+		return cfg.AssetBaseURL + "/assets/" + placeholderAsset
+	}
+	return assetURL(hash, ext)
+}
+
+// imageObjectKey is the storage key of the image the moderator checks.
+func imageObjectKey(kind, subjectID, hash, ext string) string {
+	if kind == imageKindArtwork {
+		return assetKeyPrefix + hash + "." + ext
+	}
+	return profileImageKey(subjectID, kind, hash, profileImageKinds[kind].sizes[0])
+}
+
+type imageModerationPayload struct {
+	Kind       string `json:"kind"`
+	SubjectID  string `json:"subject_id"`
+	Hash       string `json:"hash"`
+	Ext        string `json:"ext"`
+	UploadedBy string `json:"uploaded_by"`
+}
+
+func init() {
+	RegisterJobHandler(imageModerationJob, runImageModeration)
+}
+
+func queueImageModeration(ctx context.Context, kind, subjectID, hash, ext, uploadedBy string) error {
+	_, err := EnqueueJob(ctx, imageModerationJob,
+		imageModerationPayload{Kind: kind, SubjectID: subjectID, Hash: hash, Ext: ext, UploadedBy: uploadedBy}, uploadedBy)
+	return err
+}
+
+// setImageStatus moves the song's artwork or the user's avatar or banner to status,
+// but only while it still shows hash. It reports whether it did.
+func setImageStatus(ctx context.Context, tx pgx.Tx, kind, subjectID, hash, status string) (bool, error) {
+	var sql string
+	switch kind {
+	case imageKindArtwork:
+		sql = `UPDATE song_assets SET moderation_status = $3
+			WHERE song_id = $1::bigint AND kind = 'artwork' AND hash = $2;`
+	case imageKindAvatar:
+		sql = `UPDATE profiles SET avatar_status = $3 WHERE id = $1::uuid AND avatar_hash = $2;`
+	case imageKindBanner:
+		sql = `UPDATE profiles SET banner_status = $3 WHERE id = $1::uuid AND banner_hash = $2;`
+	default:
+		return false, fmt.Errorf("unknown image kind %q", kind)
+	}
+	tag, err := tx.Exec(ctx, sql, subjectID, hash, status)
+	return tag.RowsAffected() > 0, err
+}
+
+// holdImage holds the image for review with reason.
+func holdImage(ctx context.Context, p imageModerationPayload, reason string, v imageVerdict) (bool, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	current, err := setImageStatus(ctx, tx, p.Kind, p.SubjectID, p.Hash, moderationHeld)
+	if err != nil || !current {
+		return false, err
+	}
+	if v.Labels == nil {
+		v.Labels = []string{}
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO image_reviews (kind, subject_id, hash, ext, uploaded_by, reason, labels, score)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+	`, p.Kind, p.SubjectID, p.Hash, p.Ext, p.UploadedBy, reason, v.Labels, v.Score); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+func runImageModeration(ctx context.Context, job *Job) (interface{}, error) {
+	var p imageModerationPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return nil, err
+	}
+	if imageModerator == nil {
+		return nil, fmt.Errorf("image moderation is not configured")
+	}
+	if storage == nil {
+		return nil, fmt.Errorf("storage is not configured")
+	}
+
+	body, err := storage.GetObject(ctx, imageObjectKey(p.Kind, p.SubjectID, p.Hash, p.Ext))
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxArtworkBytes+1))
+	body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := imageModerator.Moderate(ctx, data, assetContentTypes[p.Ext])
+	if err != nil {
+		// Don't leave the image pending forever: once retries run out, an
+		// admin decides.
+		if job.Attempts >= jobMaxAttempts {
+			if _, herr := holdImage(ctx, p, "moderation check failed: "+err.Error(), imageVerdict{}); herr != nil {
+				log.Printf("image moderation %s %s: failed to hold: %v", p.Kind, p.SubjectID, herr)
+			}
+		}
+		return nil, err
+	}
+
+	if v.Flagged {
+		held, err := holdImage(ctx, p, "flagged", v)
+		if err != nil {
+			return nil, err
+		}
+		if !held {
+			return gin.H{"skipped": true}, nil
+		}
+		return gin.H{"status": moderationHeld, "labels": v.Labels}, nil
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	current, err := setImageStatus(ctx, tx, p.Kind, p.SubjectID, p.Hash, moderationApproved)
+	if err != nil {
+		return nil, err
+	}
+	if !current {
+		return gin.H{"skipped": true}, nil
+	}
+	return gin.H{"status": moderationApproved}, tx.Commit(ctx)
+}
+
+const imageReviewColumns = `id, kind, subject_id, hash, ext, uploaded_by::text, reason, labels, score, status,
+	reviewed_by::text, review_note, reviewed_at, created_at`
+
+func scanImageReview(row pgx.Row) (ImageReview, error) {
+	var r ImageReview
+	err := row.Scan(&r.ID, &r.Kind, &r.SubjectID, &r.Hash, &r.Ext, &r.UploadedBy, &r.Reason, &r.Labels, &r.Score,
+		&r.Status, &r.ReviewedBy, &r.ReviewNote, &r.ReviewedAt, &r.CreatedAt)
+	r.URL = cfg.AssetBaseURL + "/" + imageObjectKey(r.Kind, r.SubjectID, r.Hash, r.Ext)
+	return r, err
+}
+
+// deleteRejectedImage removes a rejected image from storage. Artwork is
+// content-addressed and may be shared, so it is kept while any approved
+// artwork still shows it.
+func deleteRejectedImage(ctx context.Context, rv ImageReview) error {
+	if rv.Kind != imageKindArtwork {
+		return deleteProfileImage(ctx, rv.SubjectID, rv.Kind, rv.Hash)
+	}
+	var used bool
+	if err := db.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM song_assets WHERE hash = $1 AND moderation_status = 'approved');
+	`, rv.Hash).Scan(&used); err != nil || used {
+		return err
+	}
+	return storage.DeleteObject(ctx, imageObjectKey(rv.Kind, rv.SubjectID, rv.Hash, rv.Ext))
+}
+
+// RegisterModerationRoutes defines the admin image review queue.
+func RegisterModerationRoutes(r *gin.Engine) {
+	admin := r.Group("/admin/image-reviews", RequireAuth(), RequireRole("admin"), RequireMFA())
+
+	// GET /admin/image-reviews?status=held — oldest first
+	admin.GET("", func(c *gin.Context) {
+		status := c.DefaultQuery("status", moderationHeld)
+		if status != moderationHeld && status != moderationApproved && status != moderationRejected {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be held, approved, or rejected"})
+			return
+		}
+
+		rows, err := db.Query(context.Background(), `
+			SELECT `+imageReviewColumns+` FROM image_reviews
+			WHERE status = $1
+			ORDER BY id
+			LIMIT 200;
+		`, status)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		reviews := []ImageReview{}
+		for rows.Next() {
+			rv, err := scanImageReview(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			reviews = append(reviews, rv)
+		}
+		c.JSON(http.StatusOK, reviews)
+	})
+
+	// POST /admin/image-reviews/:id/approve|reject — {"note":"..."}
+	decide := func(outcome string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid review id"})
+				return
+			}
+			var body struct {
+				Note string `json:"note"`
+			}
+			if c.Request.ContentLength > 0 {
+				if err := c.BindJSON(&body); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+					return
+				}
+			}
+			ctx := context.Background()
+
+			tx, err := db.Begin(ctx)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			defer tx.Rollback(ctx)
+
+			rv, err := scanImageReview(tx.QueryRow(ctx, `
+				UPDATE image_reviews
+				SET status = $2, reviewed_by = $3, review_note = NULLIF($4, ''), reviewed_at = now()
+				WHERE id = $1 AND status = 'held'
+				RETURNING `+imageReviewColumns+`;
+			`, id, outcome, currentUserID(c), strings.TrimSpace(body.Note)))
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "no held image with that id"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if _, err := setImageStatus(ctx, tx, rv.Kind, rv.SubjectID, rv.Hash, outcome); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if err := tx.Commit(ctx); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			if outcome == moderationRejected {
+				if storage != nil {
+					if err := deleteRejectedImage(ctx, rv); err != nil {
+						log.Printf("image review %d: failed to delete rejected image: %v", rv.ID, err)
+					}
+				}
+				msg := "An image you uploaded was removed for breaking the content rules. Upload a different one."
+				if rv.ReviewNote != nil {
+					msg += " Note from the moderator: " + *rv.ReviewNote
+				}
+				if err := notify(ctx, rv.UploadedBy, "image_rejected", "Your "+rv.Kind+" was removed", msg,
+					gin.H{"kind": rv.Kind, "subject_id": rv.SubjectID}); err != nil {
+					log.Printf("image review %d: failed to notify uploader: %v", rv.ID, err)
+				}
+			}
+			c.JSON(http.StatusOK, rv)
+		}
+	}
+	admin.POST("/:id/approve", decide(moderationApproved))
+	admin.POST("/:id/reject", decide(moderationRejected))
+}
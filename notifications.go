@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// notify stores an in-app notification for userID. data is optional extra
+// JSON for the client (e.g. the song an alert is about).
+func notify(ctx context.Context, userID, kind, title, body string, data interface{}) error {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(ctx, `
+		INSERT INTO notifications (user_id, kind, title, body, data)
+		VALUES ($1, $2, $3, $4, $5);
+	`, userID, kind, title, body, raw)
+	return err
+}
+
+// RegisterNotificationRoutes defines the caller's notification inbox.
+func RegisterNotificationRoutes(r *gin.Engine) {
+	me := r.Group("/me/notifications", RequireAuth())
+
+	// GET /me/notifications?unread=true
+	me.GET("", func(c *gin.Context) {
+		rows, err := db.Query(context.Background(), `
+			SELECT id, user_id, kind, title, body, data, read_at, created_at
+			FROM notifications
+			WHERE user_id = $1 AND (read_at IS NULL OR NOT $2)
+			ORDER BY created_at DESC
+			LIMIT 100;
+		`, currentUserID(c), c.Query("unread") == "true")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		notifications := []Notification{}
+		for rows.Next() {
+			var n Notification
+			if err := rows.Scan(&n.ID, &n.UserID, &n.Kind, &n.Title, &n.Body, &n.Data, &n.ReadAt, &n.CreatedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			notifications = append(notifications, n)
+		}
+
+		c.JSON(http.StatusOK, notifications)
+	})
+
+	// POST /me/notifications/:id/read
+	me.POST("/:id/read", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid notification id"})
+			return
+		}
+
+		tag, err := db.Exec(context.Background(), `
+			UPDATE notifications SET read_at = COALESCE(read_at, now())
+			WHERE id = $1 AND user_id = $2;
+		`, id, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "notification not found"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+}
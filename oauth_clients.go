@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Third-party apps read the public catalog with OAuth2 client credentials
+// (RFC 6749 §4.4) instead of a user's session: a developer registers a
+// client under /me/oauth-clients, the app trades its ID and secret at
+// POST /oauth/token for a short-lived bearer token, and public read routes
+// that opt in with AllowClient accept that token, subject to RequireScope.
+// Tokens act for no user, so every other route refuses them.
+const (
+	oauthClientIDPrefix     = "lpc_"
+	oauthClientSecretPrefix = "lpcs_"
+	oauthTokenPrefix        = "lpat_"
+
+	maxOAuthClientsPerUser = 10
+	maxOAuthClientName     = 80
+	oauthTokenTTL          = time.Hour
+
+	oauthTokenPruneName     = "oauth_token_prune"
+	oauthTokenPruneInterval = time.Hour
+)
+
+// errInvalidClient means the client ID is unknown or revoked, or the
+// secret is wrong.
+var errInvalidClient = errors.New("invalid client")
+
+// clientScopes are the API scopes a client may register for: only reads,
+// since a client token acts for no user.
+var clientScopes = map[string]bool{apiScopeSongsRead: true}
+
+// Per-client request counts, kept apart from API keys' since IDs overlap.
+var oauthClientRates = &apiKeyRateTracker{windows: map[int64]*botWindow{}}
+
+const oauthClientColumns = `id, client_id, name, scopes, rate_limit_per_minute, last_used_at, revoked_at, created_at`
+
+func scanOAuthClient(row pgx.Row) (OAuthClient, error) {
+	var cl OAuthClient
+	err := row.Scan(&cl.ID, &cl.ClientID, &cl.Name, &cl.Scopes, &cl.RateLimitPerMinute, &cl.LastUsedAt, &cl.RevokedAt, &cl.CreatedAt)
+	return cl, err
+}
+
+// writeOAuthError writes an RFC 6749 §5.2 error response.
+func writeOAuthError(c *gin.Context, status int, code, description string) {
+	if code == "invalid_client" {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
+
+// AllowClient lets a client-credentials token call a public read route.
+// The client is stored under "oauth_client_id", its token's scopes under
+// "token_scopes", and it's rate limited; requests without such a token pass
+// through unchanged, so it goes before OptionalAuth where a route has one.
+func AllowClient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if !strings.HasPrefix(token, oauthTokenPrefix) {
+			c.Next()
+			return
+		}
+
+		var (
+			id     int64
+			limit  int
+			scopes []string
+		)
+		err := db.QueryRow(context.Background(), `
+			SELECT cl.id, cl.rate_limit_per_minute, t.scopes
+			FROM oauth_access_tokens t
+			JOIN oauth_clients cl ON cl.id = t.client_id
+			WHERE t.token_hash = $1 AND t.expires_at > now() AND cl.revoked_at IS NULL;
+		`, hashToken(token)).Scan(&id, &limit, &scopes)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired client token"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !enforceRateLimit(c, oauthClientRates, id, limit, "client") {
+			return
+		}
+
+		c.Set("oauth_client_id", id)
+		c.Set("token_scopes", scopes)
+		c.Next()
+	}
+}
+
+// authenticateOAuthClient checks the client's credentials, from HTTP Basic
+// or the form body.
+func authenticateOAuthClient(c *gin.Context) (OAuthClient, error) {
+	clientID, secret, ok := c.Request.BasicAuth()
+	if !ok {
+		clientID, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+	var secretHash string
+	var cl OAuthClient
+	err := db.QueryRow(context.Background(), `
+		SELECT `+oauthClientColumns+`, secret_hash FROM oauth_clients
+		WHERE client_id = $1 AND revoked_at IS NULL;
+	`, clientID).Scan(&cl.ID, &cl.ClientID, &cl.Name, &cl.Scopes, &cl.RateLimitPerMinute,
+		&cl.LastUsedAt, &cl.RevokedAt, &cl.CreatedAt, &secretHash)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && subtle.ConstantTimeCompare([]byte(secretHash), []byte(hashToken(secret))) != 1) {
+		return cl, errInvalidClient
+	}
+	return cl, err
+}
+
+// pruneOAuthTokens drops expired access tokens.
+func pruneOAuthTokens(ctx context.Context) error {
+	_, err := db.Exec(ctx, `DELETE FROM oauth_access_tokens WHERE expires_at < now();`)
+	return err
+}
+
+// RegisterOAuthClientRoutes defines client registration and the token
+// endpoint.
+func RegisterOAuthClientRoutes(r *gin.Engine) {
+	// POST /oauth/token — grant_type=client_credentials&scope=... (form), client authenticated by Basic or client_id/client_secret
+	r.POST("/oauth/token", func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		c.Header("Pragma", "no-cache")
+		if c.PostForm("grant_type") != "client_credentials" {
+			writeOAuthError(c, http.StatusBadRequest, "unsupported_grant_type", "only client_credentials is supported")
+			return
+		}
+		cl, err := authenticateOAuthClient(c)
+		if errors.Is(err, errInvalidClient) {
+			writeOAuthError(c, http.StatusUnauthorized, "invalid_client", "unknown client or wrong secret")
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Default to everything the client was registered for; a request
+		// may narrow that but not widen it.
+		scopes := cl.Scopes
+		if requested := strings.Fields(c.PostForm("scope")); len(requested) > 0 {
+			registered := map[string]bool{}
+			for _, s := range cl.Scopes {
+				registered[s] = true
+			}
+			for _, s := range requested {
+				if !registered[s] {
+					writeOAuthError(c, http.StatusBadRequest, "invalid_scope", "client is not registered for "+s)
+					return
+				}
+			}
+			scopes = requested
+		}
+
+		token, err := newOpaqueToken(oauthTokenPrefix)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := db.Exec(context.Background(), `
+			WITH used AS (UPDATE oauth_clients SET last_used_at = now() WHERE id = $1)
+			INSERT INTO oauth_access_tokens (token_hash, client_id, scopes, expires_at)
+			VALUES ($2, $1, $3, now() + make_interval(secs => $4));
+		`, cl.ID, hashToken(token), scopes, oauthTokenTTL.Seconds()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"access_token": token,
+			"token_type":   "Bearer",
+			"expires_in":   int(oauthTokenTTL.Seconds()),
+			"scope":        strings.Join(scopes, " "),
+		})
+	})
+
+	me := r.Group("/me/oauth-clients", RequireAuth())
+
+	// GET /me/oauth-clients
+	me.GET("", func(c *gin.Context) {
+		rows, err := db.Query(context.Background(),
+			`SELECT `+oauthClientColumns+` FROM oauth_clients WHERE owner_id = $1 ORDER BY id;`, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		clients := []OAuthClient{}
+		for rows.Next() {
+			cl, err := scanOAuthClient(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			clients = append(clients, cl)
+		}
+		c.JSON(http.StatusOK, clients)
+	})
+
+	// POST /me/oauth-clients {"name","scopes":["songs:read"],"rate_limit_per_minute"} — the secret is only returned here
+	me.POST("", func(c *gin.Context) {
+		var body struct {
+			Name               string   `json:"name"`
+			Scopes             []string `json:"scopes"`
+			RateLimitPerMinute int      `json:"rate_limit_per_minute"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		body.Name = strings.TrimSpace(body.Name)
+		if body.Name == "" || len([]rune(body.Name)) > maxOAuthClientName {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name must be 1-" + strconv.Itoa(maxOAuthClientName) + " characters"})
+			return
+		}
+		if len(body.Scopes) == 0 {
+			body.Scopes = []string{apiScopeSongsRead}
+		}
+		for _, s := range body.Scopes {
+			if !clientScopes[s] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "clients can only have songs:read"})
+				return
+			}
+		}
+		if body.RateLimitPerMinute == 0 {
+			body.RateLimitPerMinute = defaultAPIKeyRate
+		}
+		if body.RateLimitPerMinute < 1 || body.RateLimitPerMinute > maxAPIKeyRate {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "rate_limit_per_minute must be 1-" + strconv.Itoa(maxAPIKeyRate)})
+			return
+		}
+
+		ctx := context.Background()
+		var count int
+		if err := db.QueryRow(ctx,
+			`SELECT COUNT(*) FROM oauth_clients WHERE owner_id = $1 AND revoked_at IS NULL;`, currentUserID(c),
+		).Scan(&count); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if count >= maxOAuthClientsPerUser {
+			c.JSON(http.StatusConflict, gin.H{"error": "too many active clients; revoke one first"})
+			return
+		}
+
+		clientID, err := newOpaqueToken(oauthClientIDPrefix)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		secret, err := newOpaqueToken(oauthClientSecretPrefix)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		cl, err := scanOAuthClient(db.QueryRow(ctx, `
+			INSERT INTO oauth_clients (owner_id, client_id, secret_hash, name, scopes, rate_limit_per_minute)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING `+oauthClientColumns+`;
+		`, currentUserID(c), clientID, hashToken(secret), body.Name, body.Scopes, body.RateLimitPerMinute))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"client": cl, "client_secret": secret})
+	})
+
+	// POST /me/oauth-clients/:id/secret — replaces the secret; tokens already issued run out normally
+	me.POST("/:id/secret", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid client id"})
+			return
+		}
+		secret, err := newOpaqueToken(oauthClientSecretPrefix)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		cl, err := scanOAuthClient(db.QueryRow(context.Background(), `
+			UPDATE oauth_clients SET secret_hash = $3
+			WHERE id = $1 AND owner_id = $2 AND revoked_at IS NULL
+			RETURNING `+oauthClientColumns+`;
+		`, id, currentUserID(c), hashToken(secret)))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "client not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"client": cl, "client_secret": secret})
+	})
+
+	// DELETE /me/oauth-clients/:id — revokes the client and its tokens
+	me.DELETE("/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid client id"})
+			return
+		}
+		tag, err := db.Exec(context.Background(), `
+			UPDATE oauth_clients SET revoked_at = now()
+			WHERE id = $1 AND owner_id = $2 AND revoked_at IS NULL;
+		`, id, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "client not found"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
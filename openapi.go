@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openapi.json is the published API contract, served at /openapi.json.
+// With OPENAPI_VALIDATION=requests, requests to the operations it describes
+// are checked against it and rejected with 400 "schema_violation" when they
+// don't match; with OPENAPI_VALIDATION=all (dev and staging), responses are
+// checked too and any drift from the contract is logged. Routes the spec
+// doesn't describe pass through unchecked.
+//
+// The validator covers the JSON Schema subset the spec uses: type, nullable,
+// properties, required, additionalProperties: false, items, enum, minimum,
+// maximum, minLength, maxLength, format: date-time, and local $refs.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+const (
+	openAPIOff      = "off"
+	openAPIRequests = "requests"
+	openAPIAll      = "all"
+
+	// Larger bodies (uploads) aren't buffered for validation.
+	maxValidatedBody = 1 << 20
+)
+
+var openAPIPathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref"`
+	Type                 string                    `json:"type"`
+	Format               string                    `json:"format"`
+	Nullable             bool                      `json:"nullable"`
+	Properties           map[string]*openAPISchema `json:"properties"`
+	Required             []string                  `json:"required"`
+	AdditionalProperties json.RawMessage           `json:"additionalProperties"`
+	Items                *openAPISchema            `json:"items"`
+	Enum                 []interface{}             `json:"enum"`
+	Minimum              *float64                  `json:"minimum"`
+	Maximum              *float64                  `json:"maximum"`
+	MinLength            *int                      `json:"minLength"`
+	MaxLength            *int                      `json:"maxLength"`
+}
+
+type openAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required"`
+	Schema   *openAPISchema `json:"schema"`
+}
+
+type openAPIContent map[string]struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPIOperation struct {
+	Parameters  []openAPIParameter `json:"parameters"`
+	RequestBody *struct {
+		Required bool           `json:"required"`
+		Content  openAPIContent `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content openAPIContent `json:"content"`
+	} `json:"responses"`
+}
+
+type openAPIDocument struct {
+	Paths map[string]struct {
+		Parameters []openAPIParameter `json:"parameters"`
+		Get        *openAPIOperation  `json:"get"`
+		Post       *openAPIOperation  `json:"post"`
+		Put        *openAPIOperation  `json:"put"`
+		Patch      *openAPIOperation  `json:"patch"`
+		Delete     *openAPIOperation  `json:"delete"`
+	} `json:"paths"`
+	Components struct {
+		Schemas map[string]*openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+// openAPIValidator checks requests and responses for the operations in the
+// spec, keyed by "METHOD /gin/:path".
+type openAPIValidator struct {
+	ops     map[string]*openAPIOperation
+	schemas map[string]*openAPISchema
+}
+
+func newOpenAPIValidator(raw []byte) (*openAPIValidator, error) {
+	var doc openAPIDocument
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	v := &openAPIValidator{ops: map[string]*openAPIOperation{}, schemas: doc.Components.Schemas}
+	for path, item := range doc.Paths {
+		ginPath := openAPIPathParam.ReplaceAllString(path, ":$1")
+		for method, op := range map[string]*openAPIOperation{
+			http.MethodGet: item.Get, http.MethodPost: item.Post, http.MethodPut: item.Put,
+			http.MethodPatch: item.Patch, http.MethodDelete: item.Delete,
+		} {
+			if op == nil {
+				continue
+			}
+			op.Parameters = append(append([]openAPIParameter{}, item.Parameters...), op.Parameters...)
+			v.ops[method+" "+ginPath] = op
+		}
+	}
+	return v, nil
+}
+
+// resolve follows a "#/components/schemas/Name" reference.
+func (v *openAPIValidator) resolve(s *openAPISchema) *openAPISchema {
+	for s != nil && s.Ref != "" {
+		s = v.schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// check appends a problem for each way value (decoded with UseNumber)
+// doesn't match s; at names the location, like "body.amount".
+func (v *openAPIValidator) check(s *openAPISchema, value interface{}, at string, problems *[]string) {
+	s = v.resolve(s)
+	if s == nil {
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, at+": "+fmt.Sprintf(format, args...))
+	}
+	if value == nil {
+		if !s.Nullable && s.Type != "" {
+			fail("must not be null")
+		}
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				fail("%s is required", name)
+			}
+		}
+		closed := string(s.AdditionalProperties) == "false"
+		for name, fv := range obj {
+			if ps, ok := s.Properties[name]; ok {
+				v.check(ps, fv, at+"."+name, problems)
+			} else if closed {
+				fail("unknown property %s", name)
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		for i, item := range arr {
+			v.check(s.Items, item, fmt.Sprintf("%s[%d]", at, i), problems)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		n := len([]rune(str))
+		if s.MinLength != nil && n < *s.MinLength {
+			fail("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("must be at most %d characters", *s.MaxLength)
+		}
+		if s.Format == "date-time" && !rfc3339Pattern.MatchString(str) {
+			fail("must be an RFC 3339 date-time")
+		}
+	case "integer", "number":
+		num, ok := value.(json.Number)
+		if !ok {
+			fail("must be of type %s", s.Type)
+			return
+		}
+		f, err := num.Float64()
+		if err != nil {
+			fail("must be of type %s", s.Type)
+			return
+		}
+		if s.Type == "integer" {
+			if _, err := num.Int64(); err != nil {
+				fail("must be an integer")
+				return
+			}
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			fail("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			fail("must be <= %v", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+			return
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) {
+				return
+			}
+		}
+		fail("must be one of %v", s.Enum)
+	}
+}
+
+var rfc3339Pattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$`)
+
+// paramValue converts a path or query string to the JSON value its schema
+// expects, so check can validate it.
+func (v *openAPIValidator) paramValue(s *openAPISchema, raw string) interface{} {
+	switch s = v.resolve(s); {
+	case s == nil:
+		return raw
+	case s.Type == "integer" || s.Type == "number":
+		if _, err := strconv.ParseFloat(raw, 64); err == nil {
+			return json.Number(raw)
+		}
+	case s.Type == "boolean":
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b
+		}
+	}
+	return raw
+}
+
+// decodeJSON decodes body keeping numbers as json.Number.
+func decodeJSON(body []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var out interface{}
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// checkRequest returns the ways the request doesn't match op. The body is
+// read and put back for the handler.
+func (v *openAPIValidator) checkRequest(c *gin.Context, op *openAPIOperation) []string {
+	var problems []string
+	for _, p := range op.Parameters {
+		var (
+			raw     string
+			present bool
+		)
+		switch p.In {
+		case "path":
+			raw = c.Param(p.Name)
+			present = raw != ""
+		case "query":
+			raw, present = c.GetQuery(p.Name)
+		default:
+			continue
+		}
+		if !present {
+			if p.Required {
+				problems = append(problems, fmt.Sprintf("%s.%s: is required", p.In, p.Name))
+			}
+			continue
+		}
+		v.check(p.Schema, v.paramValue(p.Schema, raw), p.In+"."+p.Name, &problems)
+	}
+
+	rb := op.RequestBody
+	if rb == nil || c.Request.ContentLength > maxValidatedBody {
+		return problems
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxValidatedBody+1))
+	if err != nil {
+		return append(problems, "body: could not be read")
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if len(body) > maxValidatedBody {
+		return problems
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if rb.Required {
+			problems = append(problems, "body: is required")
+		}
+		return problems
+	}
+	media, ok := rb.Content["application/json"]
+	if !ok {
+		return problems
+	}
+	value, err := decodeJSON(body)
+	if err != nil {
+		return append(problems, "body: must be valid JSON")
+	}
+	v.check(media.Schema, value, "body", &problems)
+	return problems
+}
+
+// checkResponse returns the ways a JSON response doesn't match op.
+func (v *openAPIValidator) checkResponse(op *openAPIOperation, status int, contentType string, body []byte) []string {
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		if resp, ok = op.Responses["default"]; !ok {
+			return []string{fmt.Sprintf("status %d is not documented", status)}
+		}
+	}
+	media, ok := resp.Content["application/json"]
+	if !ok || media.Schema == nil || !strings.HasPrefix(contentType, "application/json") {
+		return nil
+	}
+	value, err := decodeJSON(body)
+	if err != nil {
+		return []string{"response: must be valid JSON"}
+	}
+	var problems []string
+	v.check(media.Schema, value, "response", &problems)
+	return problems
+}
+
+// capturingWriter keeps a copy of what the handler writes, up to
+// maxValidatedBody, for response validation.
+type capturingWriter struct {
+	gin.ResponseWriter
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	if w.buf.Len()+len(b) <= maxValidatedBody {
+		w.buf.Write(b)
+	} else {
+		w.truncated = true
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// apiValidator is nil when validation is off.
+var apiValidator *openAPIValidator
+
+// InitOpenAPIValidation checks OPENAPI_VALIDATION and loads openapi.json
+// when validation is on.
+func InitOpenAPIValidation() error {
+	mode := cfg.OpenAPIValidation
+	if mode == openAPIOff {
+		return errNotConfigured
+	}
+	if mode != openAPIRequests && mode != openAPIAll {
+		return fmt.Errorf("OPENAPI_VALIDATION must be off, requests, or all; got %q", mode)
+	}
+	v, err := newOpenAPIValidator(openAPISpec)
+	if err != nil {
+		return fmt.Errorf("failed to load openapi.json: %w", err)
+	}
+	apiValidator = v
+	return nil
+}
+
+// ValidateOpenAPI returns the validation middleware for the configured mode;
+// install it before the routes, after InitOpenAPIValidation.
+func ValidateOpenAPI() gin.HandlerFunc {
+	mode, v := cfg.OpenAPIValidation, apiValidator
+	if v == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		op := v.ops[c.Request.Method+" "+c.FullPath()]
+		if op == nil {
+			c.Next()
+			return
+		}
+		if problems := v.checkRequest(c, op); len(problems) > 0 {
+			sort.Strings(problems)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":   "request does not match the API schema",
+				"code":    "schema_violation",
+				"details": problems,
+			})
+			return
+		}
+		if mode != openAPIAll {
+			c.Next()
+			return
+		}
+
+		w := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		if w.truncated {
+			return
+		}
+		if problems := v.checkResponse(op, w.Status(), w.Header().Get("Content-Type"), w.buf.Bytes()); len(problems) > 0 {
+			sort.Strings(problems)
+			log.Printf("openapi: %s %s responded %d off contract: %s",
+				c.Request.Method, c.FullPath(), w.Status(), strings.Join(problems, "; "))
+		}
+	}
+}
+
+// RegisterOpenAPIRoutes serves the spec.
+func RegisterOpenAPIRoutes(r *gin.Engine) {
+	// GET /openapi.json
+	r.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", openAPISpec)
+	})
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /admin/ops gathers the numbers an on-call admin checks first: job
+// queue depths, webhook delivery failures, the transcoding backlog, recent
+// Supabase Auth errors, and storage used. Database figures cover the whole
+// deployment; Supabase figures are counted in memory by this instance.
+const (
+	opsWindow          = 24 * time.Hour
+	upstreamStatsSlots = 60 // one-minute buckets, so the last hour
+)
+
+// upstreamStats counts calls to an upstream service and how many failed
+// (a transport error or a 5xx) in one-minute buckets.
+type upstreamStats struct {
+	mu      sync.Mutex
+	buckets [upstreamStatsSlots]struct {
+		minute         int64
+		calls, errored int64
+	}
+}
+
+var supabaseCalls = &upstreamStats{}
+
+func (u *upstreamStats) record(failed bool) {
+	minute := time.Now().Unix() / 60
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	b := &u.buckets[minute%upstreamStatsSlots]
+	if b.minute != minute {
+		b.minute, b.calls, b.errored = minute, 0, 0
+	}
+	b.calls++
+	if failed {
+		b.errored++
+	}
+}
+
+// lastHour sums the buckets from the last hour.
+func (u *upstreamStats) lastHour() (calls, errored int64) {
+	now := time.Now().Unix() / 60
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, b := range u.buckets {
+		if now-b.minute < upstreamStatsSlots {
+			calls += b.calls
+			errored += b.errored
+		}
+	}
+	return calls, errored
+}
+
+// countingTransport records each round trip in stats.
+type countingTransport struct {
+	base  http.RoundTripper
+	stats *upstreamStats
+}
+
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	t.stats.record(err != nil || resp.StatusCode >= 500)
+	return resp, err
+}
+
+type jobQueueDepth struct {
+	Type             string   `json:"type"`
+	Queued           int64    `json:"queued"`
+	Scheduled        int64    `json:"scheduled"`
+	Running          int64    `json:"running"`
+	FailedLast24h    int64    `json:"failed_last_24h"`
+	OldestQueuedSecs *float64 `json:"oldest_queued_seconds"`
+}
+
+func loadJobQueueDepths(ctx context.Context) ([]jobQueueDepth, error) {
+	rows, err := db.Query(ctx, `
+		SELECT type,
+		       COUNT(*) FILTER (WHERE status = 'queued' AND run_at <= now()),
+		       COUNT(*) FILTER (WHERE status = 'queued' AND run_at > now()),
+		       COUNT(*) FILTER (WHERE status = 'running'),
+		       COUNT(*) FILTER (WHERE status = 'failed' AND finished_at > $1),
+		       EXTRACT(EPOCH FROM now() - MIN(run_at) FILTER (WHERE status = 'queued' AND run_at <= now()))::float8
+		FROM jobs
+		WHERE status IN ('queued', 'running') OR finished_at > $1
+		GROUP BY type
+		ORDER BY type;
+	`, time.Now().Add(-opsWindow))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	depths := []jobQueueDepth{}
+	for rows.Next() {
+		var d jobQueueDepth
+		if err := rows.Scan(&d.Type, &d.Queued, &d.Scheduled, &d.Running, &d.FailedLast24h, &d.OldestQueuedSecs); err != nil {
+			return nil, err
+		}
+		depths = append(depths, d)
+	}
+	return depths, rows.Err()
+}
+
+// RegisterOpsRoutes defines the admin operational metrics endpoint.
+func RegisterOpsRoutes(r *gin.Engine) {
+	// GET /admin/ops
+	r.GET("/admin/ops", RequireAuth(), RequireRole("admin"), RequireMFA(), func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		queues, err := loadJobQueueDepths(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var webhookPending, webhookFailed, webhookFailingEndpoints int64
+		err = db.QueryRow(ctx, `
+			SELECT COUNT(*) FILTER (WHERE status = 'pending'),
+			       COUNT(*) FILTER (WHERE status = 'failed'),
+			       COUNT(DISTINCT webhook_id) FILTER (WHERE status = 'failed')
+			FROM webhook_deliveries
+			WHERE status = 'pending' OR created_at > $1;
+		`, time.Now().Add(-opsWindow)).Scan(&webhookPending, &webhookFailed, &webhookFailingEndpoints)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var (
+			transcodePending, transcodeFailed int64
+			oldestPendingSecs                 *float64
+		)
+		err = db.QueryRow(ctx, `
+			SELECT COUNT(*) FILTER (WHERE status = 'pending'),
+			       COUNT(*) FILTER (WHERE status = 'failed' AND processed_at > $1),
+			       EXTRACT(EPOCH FROM now() - MIN(created_at) FILTER (WHERE status = 'pending'))::float8
+			FROM song_renditions;
+		`, time.Now().Add(-opsWindow)).Scan(&transcodePending, &transcodeFailed, &oldestPendingSecs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var renditionBytes, versionBytes, assetBytes, stemBytes, contestBytes int64
+		err = db.QueryRow(ctx, `
+			SELECT (SELECT COALESCE(SUM(size_bytes), 0)::bigint FROM song_renditions),
+			       (SELECT COALESCE(SUM(v.size_bytes), 0)::bigint FROM song_audio_versions v
+			        WHERE v.storage_key LIKE 'audio/%'
+			          AND NOT EXISTS (SELECT 1 FROM song_renditions r WHERE r.storage_key = v.storage_key)),
+			       (SELECT COALESCE(SUM(size_bytes), 0)::bigint FROM song_assets),
+			       (SELECT COALESCE(SUM(size_bytes), 0)::bigint FROM project_stems),
+			       (SELECT COALESCE(SUM(size_bytes), 0)::bigint FROM contest_stems) +
+			       (SELECT COALESCE(SUM(size_bytes), 0)::bigint FROM contest_entries);
+		`).Scan(&renditionBytes, &versionBytes, &assetBytes, &stemBytes, &contestBytes)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		calls, errored := supabaseCalls.lastHour()
+		var errorRate float64
+		if calls > 0 {
+			errorRate = float64(errored) / float64(calls)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"generated_at": time.Now().UTC(),
+			"job_queues":   queues,
+			"webhooks": gin.H{
+				"pending":                    webhookPending,
+				"failed_last_24h":            webhookFailed,
+				"failing_endpoints_last_24h": webhookFailingEndpoints,
+			},
+			"transcoding": gin.H{
+				"pending":                transcodePending,
+				"failed_last_24h":        transcodeFailed,
+				"oldest_pending_seconds": oldestPendingSecs,
+			},
+			"supabase_auth": gin.H{
+				"calls_last_hour":  calls,
+				"errors_last_hour": errored,
+				"error_rate":       errorRate,
+			},
+			"storage_bytes": gin.H{
+				"renditions":     renditionBytes,
+				"audio_versions": versionBytes,
+				"assets":         assetBytes,
+				"project_stems":  stemBytes,
+				"contests":       contestBytes,
+				"total":          renditionBytes + versionBytes + assetBytes + stemBytes + contestBytes,
+			},
+		})
+	})
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Payouts are recorded by admins as they move through the payment
+// processor: initiated, then paid or failed. Every transition, and every
+// confirmed tip, is sent to the artist's webhooks with net amounts so their
+// accounting tools can reconcile.
+
+type createPayoutInput struct {
+	ArtistID string  `json:"artist_id"`
+	Amount   float64 `json:"amount"`
+}
+
+type updatePayoutInput struct {
+	Status        string `json:"status"`
+	FailureReason string `json:"failure_reason"`
+}
+
+const payoutColumns = `id, artist_id, amount, status, failure_reason, created_at, updated_at`
+
+func scanPayout(row pgx.Row) (Payout, error) {
+	var p Payout
+	err := row.Scan(&p.ID, &p.ArtistID, &p.Amount, &p.Status, &p.FailureReason, &p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+// tipFee splits a tip into the platform fee and the artist's net, in cents.
+func tipFee(amount float64) (fee, net float64) {
+	fee = math.Round(amount*cfg.PlatformFeePercent) / 100
+	return fee, math.Round((amount-fee)*100) / 100
+}
+
+// emitTipConfirmed sends tip.confirmed to the song artist's webhooks.
+// Failures are logged so they never fail the tip itself.
+func emitTipConfirmed(t Tip) {
+	ctx := context.Background()
+
+	var artistID *string
+	if err := db.QueryRow(ctx, `SELECT artist_id::text FROM songs WHERE id = $1;`, t.SongID).Scan(&artistID); err != nil {
+		log.Printf("tip %d: failed to look up artist: %v", t.ID, err)
+		return
+	}
+	if artistID == nil {
+		return
+	}
+
+	// Senders who hide their tips are anonymous to the artist.
+	var senderID *string
+	privacy, err := loadPrivacy(ctx, t.SenderID)
+	if err != nil {
+		log.Printf("tip %d: failed to load sender privacy: %v", t.ID, err)
+		return
+	}
+	if !privacy.HideTips {
+		senderID = &t.SenderID
+	}
+
+	fee, net := tipFee(t.Amount)
+	data := gin.H{
+		"tip_id":       t.ID,
+		"song_id":      t.SongID,
+		"sender_id":    senderID,
+		"gross_amount": t.Amount,
+		"fee_amount":   fee,
+		"net_amount":   net,
+		"created_at":   t.CreatedAt,
+	}
+	if err := emitWebhookEvent(ctx, *artistID, "tip.confirmed", data); err != nil {
+		log.Printf("tip %d: failed to queue webhooks: %v", t.ID, err)
+	}
+}
+
+// emitPayoutEvent sends payout.<status> to the artist's webhooks.
+func emitPayoutEvent(ctx context.Context, p Payout) {
+	data := gin.H{
+		"payout_id":      p.ID,
+		"status":         p.Status,
+		"net_amount":     p.Amount,
+		"failure_reason": p.FailureReason,
+		"created_at":     p.CreatedAt,
+		"updated_at":     p.UpdatedAt,
+	}
+	if err := emitWebhookEvent(ctx, p.ArtistID, "payout."+p.Status, data); err != nil {
+		log.Printf("payout %d: failed to queue webhooks: %v", p.ID, err)
+	}
+}
+
+// RegisterPayoutRoutes defines the artist and admin payout endpoints.
+func RegisterPayoutRoutes(r *gin.Engine) {
+	// GET /me/payouts
+	r.GET("/me/payouts", RequireAuth(), func(c *gin.Context) {
+		rows, err := db.Query(context.Background(),
+			`SELECT `+payoutColumns+` FROM payouts WHERE artist_id = $1 ORDER BY created_at DESC LIMIT 100;`,
+			currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		payouts := []Payout{}
+		for rows.Next() {
+			p, err := scanPayout(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			payouts = append(payouts, p)
+		}
+		c.JSON(http.StatusOK, payouts)
+	})
+
+	admin := r.Group("/admin/payouts", RequireAuth(), RequireRole("admin"), RequireMFA())
+
+	// POST /admin/payouts — records an initiated payout
+	admin.POST("", func(c *gin.Context) {
+		var body createPayoutInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if body.ArtistID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "artist_id is required"})
+			return
+		}
+		if body.Amount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be > 0"})
+			return
+		}
+
+		p, err := scanPayout(db.QueryRow(context.Background(), `
+			INSERT INTO payouts (artist_id, amount)
+			VALUES ($1, $2)
+			RETURNING `+payoutColumns+`;
+		`, body.ArtistID, body.Amount))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		emitPayoutEvent(context.Background(), p)
+		c.JSON(http.StatusCreated, p)
+	})
+
+	// PATCH /admin/payouts/:id — {"status":"paid"} or {"status":"failed","failure_reason":"..."}
+	admin.PATCH("/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payout id"})
+			return
+		}
+
+		var body updatePayoutInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if body.Status != "paid" && body.Status != "failed" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be paid or failed"})
+			return
+		}
+		var reason *string
+		if body.Status == "failed" {
+			if strings.TrimSpace(body.FailureReason) == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "failure_reason is required"})
+				return
+			}
+			reason = &body.FailureReason
+		}
+
+		// Only initiated payouts can move, so each transition is emitted once.
+		p, err := scanPayout(db.QueryRow(context.Background(), `
+			UPDATE payouts SET status = $2, failure_reason = $3, updated_at = now()
+			WHERE id = $1 AND status = 'initiated'
+			RETURNING `+payoutColumns+`;
+		`, id, body.Status, reason))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "payout not found or already settled"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		emitPayoutEvent(context.Background(), p)
+		c.JSON(http.StatusOK, p)
+	})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Headline numbers for the marketing site. A periodic job computes them into
+// the single-row platform_stats table; GET /stats serves that row from a
+// short in-memory cache so the public endpoint never scans songs or rollups.
+const (
+	platformStatsName     = "platform_stats"
+	platformStatsInterval = 15 * time.Minute
+	platformStatsCacheTTL = time.Minute
+)
+
+type PlatformStats struct {
+	TotalSongs    int64     `json:"total_songs"`
+	TotalArtists  int64     `json:"total_artists"`
+	PlaysThisWeek int64     `json:"plays_this_week"`
+	ComputedAt    time.Time `json:"computed_at"`
+}
+
+var platformStatsCache struct {
+	mu       sync.RWMutex
+	stats    *PlatformStats
+	loadedAt time.Time
+}
+
+// refreshPlatformStats recomputes the headline numbers. Plays come from the
+// daily rollup for the last 7 UTC days, so bot traffic is already excluded.
+func refreshPlatformStats(ctx context.Context) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO platform_stats (id, total_songs, total_artists, plays_this_week, computed_at)
+		SELECT true,
+		       (SELECT COUNT(*) FROM songs),
+		       (SELECT COUNT(DISTINCT artist_id) FROM songs WHERE artist_id IS NOT NULL),
+		       (SELECT COALESCE(SUM(plays), 0) FROM song_daily_stats
+		        WHERE day > (now() AT TIME ZONE 'UTC')::date - 7),
+		       now()
+		ON CONFLICT (id) DO UPDATE SET
+			total_songs     = EXCLUDED.total_songs,
+			total_artists   = EXCLUDED.total_artists,
+			plays_this_week = EXCLUDED.plays_this_week,
+			computed_at     = EXCLUDED.computed_at;
+	`)
+	return err
+}
+
+func loadPlatformStats(ctx context.Context) (*PlatformStats, error) {
+	var s PlatformStats
+	err := db.QueryRow(ctx, `
+		SELECT total_songs, total_artists, plays_this_week, computed_at
+		FROM platform_stats WHERE id;
+	`).Scan(&s.TotalSongs, &s.TotalArtists, &s.PlaysThisWeek, &s.ComputedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// currentPlatformStats returns cached stats, reloading after the TTL. If the
+// job has never run (fresh deploy), it computes them once inline.
+func currentPlatformStats(ctx context.Context) (*PlatformStats, error) {
+	platformStatsCache.mu.RLock()
+	if platformStatsCache.stats != nil && time.Since(platformStatsCache.loadedAt) < platformStatsCacheTTL {
+		s := platformStatsCache.stats
+		platformStatsCache.mu.RUnlock()
+		return s, nil
+	}
+	platformStatsCache.mu.RUnlock()
+
+	s, err := loadPlatformStats(ctx)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if err := refreshPlatformStats(ctx); err != nil {
+			return nil, err
+		}
+		s, err = loadPlatformStats(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	platformStatsCache.mu.Lock()
+	platformStatsCache.stats = s
+	platformStatsCache.loadedAt = time.Now()
+	platformStatsCache.mu.Unlock()
+	return s, nil
+}
+
+// RegisterPlatformStatsRoutes defines the public GET /stats endpoint.
+func RegisterPlatformStatsRoutes(r *gin.Engine) {
+	r.GET("/stats", func(c *gin.Context) {
+		s, err := currentPlatformStats(context.Background())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, s)
+	})
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Presence and typing indicators for project chat and song comments. Each
+// project and song is a room; clients heartbeat with POST .../presence,
+// flagging "typing" while the compose box has input, and read who else is
+// around from the response or GET .../presence. Like live listener counts,
+// it is polled rather than pushed. Heartbeats closer together than
+// presenceMinInterval are throttled: they aren't written unless the typing
+// state changed, so a chatty client costs a read, not a write.
+const (
+	presenceWindow      = 30 * time.Second
+	typingWindow        = 6 * time.Second
+	presenceMinInterval = 2 * time.Second
+
+	presencePruneName     = "presence_prune"
+	presencePruneInterval = 10 * time.Minute
+	presenceRetention     = time.Hour
+)
+
+func projectRoom(projectID int64) string { return "project:" + strconv.FormatInt(projectID, 10) }
+func songRoom(songID int64) string       { return "song:" + strconv.FormatInt(songID, 10) }
+
+// heartbeatPresence marks userID present in room, typing or not, and
+// reports whether the write went through or was throttled.
+func heartbeatPresence(ctx context.Context, room, userID string, typing bool) (bool, error) {
+	tag, err := db.Exec(ctx, `
+		INSERT INTO room_presence (room, user_id, typing_until, last_seen_at)
+		VALUES ($1, $2, CASE WHEN $3 THEN now() + make_interval(secs => $4) END, now())
+		ON CONFLICT (room, user_id) DO UPDATE SET
+			typing_until = EXCLUDED.typing_until, last_seen_at = now()
+		WHERE room_presence.last_seen_at < now() - make_interval(secs => $5)
+		   OR COALESCE(room_presence.typing_until > now(), false) <> $3;
+	`, room, userID, typing, typingWindow.Seconds(), presenceMinInterval.Seconds())
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func loadPresence(ctx context.Context, room string) (RoomPresence, error) {
+	rows, err := db.Query(ctx, `
+		SELECT user_id::text, COALESCE(typing_until > now(), false) FROM room_presence
+		WHERE room = $1 AND last_seen_at > now() - make_interval(secs => $2)
+		ORDER BY last_seen_at DESC;
+	`, room, presenceWindow.Seconds())
+	if err != nil {
+		return RoomPresence{}, err
+	}
+	defer rows.Close()
+
+	p := RoomPresence{Room: room, Present: []string{}, Typing: []string{},
+		HeartbeatSeconds: int(presenceWindow.Seconds() / 3)}
+	for rows.Next() {
+		var (
+			userID string
+			typing bool
+		)
+		if err := rows.Scan(&userID, &typing); err != nil {
+			return RoomPresence{}, err
+		}
+		p.Present = append(p.Present, userID)
+		if typing {
+			p.Typing = append(p.Typing, userID)
+		}
+	}
+	return p, rows.Err()
+}
+
+// prunePresence forgets people who left a while ago.
+func prunePresence(ctx context.Context) error {
+	_, err := db.Exec(ctx, `DELETE FROM room_presence WHERE last_seen_at < $1;`, time.Now().Add(-presenceRetention))
+	return err
+}
+
+// songRoomFor returns the room for the song in :id when the caller can see
+// it, writing the error when not.
+func songRoomFor(c *gin.Context) (string, bool) {
+	songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+		return "", false
+	}
+	var (
+		artistID  *string
+		published bool
+	)
+	err = db.QueryRow(c.Request.Context(),
+		`SELECT artist_id::text, published FROM songs WHERE id = $1 AND trashed_at IS NULL;`, songID,
+	).Scan(&artistID, &published)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !published && (artistID == nil || *artistID != currentUserID(c))) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "song not found"})
+		return "", false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return songRoom(songID), true
+}
+
+// presenceHandlers returns the GET and POST handlers for a kind of room.
+func presenceHandlers(room func(c *gin.Context) (string, bool)) (get, post gin.HandlerFunc) {
+	get = func(c *gin.Context) {
+		name, ok := room(c)
+		if !ok {
+			return
+		}
+		p, err := loadPresence(c.Request.Context(), name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, p)
+	}
+	post = func(c *gin.Context) {
+		var body struct {
+			Typing bool `json:"typing"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.BindJSON(&body); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+				return
+			}
+		}
+		name, ok := room(c)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+		written, err := heartbeatPresence(ctx, name, currentUserID(c), body.Typing)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		p, err := loadPresence(ctx, name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		p.Throttled = !written
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, p)
+	}
+	return get, post
+}
+
+// RegisterPresenceRoutes defines presence and typing for project chat and
+// song comments.
+func RegisterPresenceRoutes(r *gin.Engine) {
+	projectGet, projectPost := presenceHandlers(func(c *gin.Context) (string, bool) {
+		return projectRoom(c.GetInt64("project_id")), true
+	})
+	// GET /projects/:id/presence — members and guests
+	r.GET("/projects/:id/presence", RequireProjectAccess(), projectGet)
+	// POST /projects/:id/presence {"typing":true} — members; returns the room like GET
+	r.POST("/projects/:id/presence", RequireProjectAccess(), projectPost)
+
+	songGet, songPost := presenceHandlers(songRoomFor)
+	// GET /songs/:id/presence — who is reading and typing comments
+	r.GET("/songs/:id/presence", OptionalAuth(), songGet)
+	// POST /songs/:id/presence {"typing":true} — returns the room like GET
+	r.POST("/songs/:id/presence", RequireAuth(), songPost)
+}
@@ -0,0 +1,531 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Artists send unreleased songs to press with a press kit: a press page and
+// a preview stream for each recipient, reached through that recipient's own
+// token until the embargo lifts on release day. Every recipient gets their
+// own rendering, watermarked with a faint tone at a pitch derived from the
+// recipient, so a leaked file points back to who it was sent to; views and
+// plays are counted per recipient. Revoking a recipient kills their link and
+// deletes their preview. Once the song is out, links point at the real song.
+const (
+	pressTokenPrefix = "lpp_"
+	pressRenderJob   = "press_preview_render"
+	pressKeyPrefix   = "press/"
+	pressRenderLimit = 10 * time.Minute
+	pressURLExpiry   = time.Hour
+	pressBitrate     = "128k"
+
+	// The watermark is a short tone every pressWatermarkEvery, quiet enough
+	// to review through.
+	pressWatermarkEvery  = 15 // seconds
+	pressWatermarkLength = 0.4
+	pressWatermarkVolume = 0.05
+
+	maxPressRecipients = 200
+	maxPressHeadline   = 200
+	maxPressNotes      = 5000
+)
+
+type pressRenderPayload struct {
+	RecipientID int64 `json:"recipient_id"`
+}
+
+func init() {
+	RegisterJobHandler(pressRenderJob, runPressRender)
+}
+
+type pressKitInput struct {
+	Headline     string     `json:"headline"`
+	Notes        string     `json:"notes"`
+	EmbargoUntil *time.Time `json:"embargo_until"`
+}
+
+type pressRecipientInput struct {
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Outlet string `json:"outlet"`
+}
+
+const pressKitColumns = `song_id, artist_id::text, headline, notes, embargo_until, created_at, updated_at`
+
+func scanPressKit(row pgx.Row) (PressKit, error) {
+	var k PressKit
+	err := row.Scan(&k.SongID, &k.ArtistID, &k.Headline, &k.Notes, &k.EmbargoUntil, &k.CreatedAt, &k.UpdatedAt)
+	return k, err
+}
+
+const pressRecipientColumns = `id, song_id, name, email, outlet, preview_status, preview_error, storage_key, storage_region,
+	views, plays, first_viewed_at, last_viewed_at, revoked_at, created_at`
+
+func scanPressRecipient(row pgx.Row) (PressRecipient, error) {
+	var p PressRecipient
+	err := row.Scan(&p.ID, &p.SongID, &p.Name, &p.Email, &p.Outlet, &p.PreviewStatus, &p.PreviewError, &p.StorageKey,
+		&p.StorageRegion, &p.Views, &p.Plays, &p.FirstViewedAt, &p.LastViewedAt, &p.RevokedAt, &p.CreatedAt)
+	return p, err
+}
+
+func loadPressRecipients(ctx context.Context, songID int64) ([]PressRecipient, error) {
+	rows, err := db.Query(ctx,
+		`SELECT `+pressRecipientColumns+` FROM press_kit_recipients WHERE song_id = $1 ORDER BY created_at, id;`, songID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recipients := []PressRecipient{}
+	for rows.Next() {
+		p, err := scanPressRecipient(rows)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, p)
+	}
+	return recipients, rows.Err()
+}
+
+// pressWatermarkHz is the pitch of a recipient's watermark tone, one of 24
+// steps between 800 and 1950 Hz.
+func pressWatermarkHz(recipientID int64) int64 {
+	return 800 + (recipientID%24)*50
+}
+
+// renderPressPreview writes inputURL as an MP3 at path with the recipient's
+// watermark tone mixed in and their ID in the file's comment tag.
+func renderPressPreview(ctx context.Context, inputURL, path string, recipientID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, pressRenderLimit)
+	defer cancel()
+
+	filter := fmt.Sprintf("[0:a]aformat=sample_rates=44100:channel_layouts=stereo[a];"+
+		"sine=frequency=%d:sample_rate=44100,aformat=channel_layouts=stereo,"+
+		"volume='if(lt(mod(t,%d),%.1f),%.2f,0)':eval=frame[w];"+
+		"[a][w]amix=inputs=2:duration=first:normalize=0[out]",
+		pressWatermarkHz(recipientID), pressWatermarkEvery, pressWatermarkLength, pressWatermarkVolume)
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, cfg.FFmpegPath,
+		"-nostats", "-hide_banner", "-y", "-i", inputURL,
+		"-filter_complex", filter, "-map", "[out]", "-map_metadata", "-1",
+		"-metadata", fmt.Sprintf("comment=Leep press preview %d", recipientID),
+		"-c:a", "libmp3lame", "-b:a", pressBitrate, "-f", "mp3", path)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg: %v: %s", err, lastLines(stderr.Bytes(), 3))
+	}
+	return nil
+}
+
+func runPressRender(ctx context.Context, job *Job) (interface{}, error) {
+	var p pressRenderPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return nil, err
+	}
+	rc, err := scanPressRecipient(db.QueryRow(ctx, `
+		SELECT `+pressRecipientColumns+` FROM press_kit_recipients
+		WHERE id = $1 AND preview_status = 'pending' AND revoked_at IS NULL;
+	`, p.RecipientID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return gin.H{"skipped": true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if storage == nil {
+		return nil, fmt.Errorf("storage is not configured")
+	}
+
+	rend, err := scanRendition(db.QueryRow(ctx,
+		`SELECT `+renditionColumns+` FROM song_renditions WHERE song_id = $1 AND name = $2 AND status = 'ready';`,
+		rc.SongID, originalRendition))
+	if errors.Is(err, pgx.ErrNoRows) {
+		failPressPreview(ctx, rc.ID, "The song has no processed audio yet.")
+		return gin.H{"status": "failed"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "press-*.mp3")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := renderPressPreview(ctx, storageFor(rend.StorageRegion).PresignGet(rend.StorageKey, processingURLExpiry),
+		tmp.Name(), rc.ID); err != nil {
+		if job.Attempts >= jobMaxAttempts {
+			failPressPreview(ctx, rc.ID, "The preview could not be rendered.")
+		}
+		return nil, err
+	}
+	size, err := tmp.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%s%d/%d.mp3", pressKeyPrefix, rc.SongID, rc.ID)
+	if err := storageFor(rend.StorageRegion).PutObject(ctx, key, tmp, size, "audio/mpeg"); err != nil {
+		return nil, err
+	}
+	tag, err := db.Exec(ctx, `
+		UPDATE press_kit_recipients SET preview_status = 'ready', storage_key = $2, storage_region = $3
+		WHERE id = $1 AND revoked_at IS NULL;
+	`, rc.ID, key, rend.StorageRegion)
+	if err != nil {
+		return nil, err
+	}
+	// Revoked while rendering: don't leave the file behind.
+	if tag.RowsAffected() == 0 {
+		if err := storageFor(rend.StorageRegion).DeleteObject(ctx, key); err != nil {
+			log.Printf("press recipient %d: failed to delete revoked preview %s: %v", rc.ID, key, err)
+		}
+		return gin.H{"skipped": true}, nil
+	}
+	return gin.H{"status": "ready", "size_bytes": size}, nil
+}
+
+func failPressPreview(ctx context.Context, recipientID int64, msg string) {
+	if _, err := db.Exec(ctx, `UPDATE press_kit_recipients SET preview_status = 'failed', preview_error = $2 WHERE id = $1;`,
+		recipientID, msg); err != nil {
+		log.Printf("press recipient %d: failed to record failure: %v", recipientID, err)
+	}
+}
+
+// deletePressPreview removes a recipient's rendered preview, if any.
+func deletePressPreview(ctx context.Context, rc PressRecipient) error {
+	if rc.StorageKey == nil {
+		return nil
+	}
+	err := storageFor(*rc.StorageRegion).DeleteObject(ctx, *rc.StorageKey)
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil
+	}
+	return err
+}
+
+// loadPressAccess returns the recipient holding :token with their kit and
+// song, writing the error when the link is unknown or revoked (404) or the
+// song has since been released (410, pointing at the song).
+func loadPressAccess(c *gin.Context) (PressRecipient, PressKit, Song, bool) {
+	ctx := c.Request.Context()
+	rc, err := scanPressRecipient(db.QueryRow(ctx, `
+		SELECT `+pressRecipientColumns+` FROM press_kit_recipients WHERE token_hash = $1 AND revoked_at IS NULL;
+	`, hashToken(c.Param("token"))))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "press link is invalid or has been revoked"})
+		return PressRecipient{}, PressKit{}, Song{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return PressRecipient{}, PressKit{}, Song{}, false
+	}
+	kit, err := scanPressKit(db.QueryRow(ctx, `SELECT `+pressKitColumns+` FROM press_kits WHERE song_id = $1;`, rc.SongID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return PressRecipient{}, PressKit{}, Song{}, false
+	}
+	s, err := scanSong(db.QueryRow(ctx,
+		`SELECT `+songColumns+` FROM `+songFrom+` WHERE s.id = $1 AND s.trashed_at IS NULL;`, rc.SongID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "press link is invalid or has been revoked"})
+		return PressRecipient{}, PressKit{}, Song{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return PressRecipient{}, PressKit{}, Song{}, false
+	}
+	if s.Published || !time.Now().Before(kit.EmbargoUntil) {
+		resp := gin.H{"error": "this song is out now; the press preview has ended"}
+		if s.Published {
+			resp["song_url"] = requestOrigin(c) + "/songs/" + strconv.FormatInt(s.ID, 10)
+		}
+		c.JSON(http.StatusGone, resp)
+		return PressRecipient{}, PressKit{}, Song{}, false
+	}
+	return rc, kit, s, true
+}
+
+// requireUnreleasedSong checks that the caller owns the song in :id and it
+// hasn't been published, writing the error when not.
+func requireUnreleasedSong(c *gin.Context) (int64, bool) {
+	songID, ok := ownedSongID(c, "send out")
+	if !ok {
+		return 0, false
+	}
+	var published bool
+	if err := db.QueryRow(c.Request.Context(), `SELECT published FROM songs WHERE id = $1;`, songID).Scan(&published); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return 0, false
+	}
+	if published {
+		c.JSON(http.StatusConflict, gin.H{"error": "press kits are for unreleased songs; this one is already published"})
+		return 0, false
+	}
+	return songID, true
+}
+
+// RegisterPressKitRoutes defines the artist's press kit management and the
+// recipients' press pages.
+func RegisterPressKitRoutes(r *gin.Engine) {
+	kit := r.Group("/songs/:id/press-kit", RequireAuth())
+
+	// PUT /songs/:id/press-kit {"headline","notes","embargo_until"} — embargo_until defaults to the song's scheduled release
+	kit.PUT("", func(c *gin.Context) {
+		var body pressKitInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		body.Headline, body.Notes = strings.TrimSpace(body.Headline), strings.TrimSpace(body.Notes)
+		if len(body.Headline) > maxPressHeadline || len(body.Notes) > maxPressNotes {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("headline must be at most %d characters and notes %d",
+				maxPressHeadline, maxPressNotes)})
+			return
+		}
+		songID, ok := requireUnreleasedSong(c)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		if body.EmbargoUntil == nil {
+			var startsAt time.Time
+			err := db.QueryRow(ctx, `
+				SELECT starts_at FROM scheduled_releases
+				WHERE song_id = $1 AND kind = 'release' AND starts_at > now()
+				ORDER BY starts_at LIMIT 1;
+			`, songID).Scan(&startsAt)
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "embargo_until is required when the song has no scheduled release"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			body.EmbargoUntil = &startsAt
+		}
+		if !body.EmbargoUntil.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "embargo_until must be in the future"})
+			return
+		}
+
+		k, err := scanPressKit(db.QueryRow(ctx, `
+			INSERT INTO press_kits (song_id, artist_id, headline, notes, embargo_until)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (song_id) DO UPDATE SET
+				headline = EXCLUDED.headline, notes = EXCLUDED.notes, embargo_until = EXCLUDED.embargo_until,
+				updated_at = now()
+			RETURNING `+pressKitColumns+`;
+		`, songID, currentUserID(c), body.Headline, body.Notes, *body.EmbargoUntil))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if k.Recipients, err = loadPressRecipients(ctx, songID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, k)
+	})
+
+	// GET /songs/:id/press-kit — the kit with each recipient's views and plays
+	kit.GET("", func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "view press kits for")
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+		k, err := scanPressKit(db.QueryRow(ctx, `SELECT `+pressKitColumns+` FROM press_kits WHERE song_id = $1;`, songID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "this song has no press kit"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if k.Recipients, err = loadPressRecipients(ctx, songID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, k)
+	})
+
+	// POST /songs/:id/press-kit/recipients {"name","email","outlet"} — returns the recipient's token once
+	kit.POST("/recipients", func(c *gin.Context) {
+		var body pressRecipientInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		body.Name, body.Email, body.Outlet = strings.TrimSpace(body.Name), strings.TrimSpace(body.Email), strings.TrimSpace(body.Outlet)
+		if body.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+			return
+		}
+		if storage == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is not configured"})
+			return
+		}
+		songID, ok := requireUnreleasedSong(c)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		var embargoUntil time.Time
+		var recipients int
+		err := db.QueryRow(ctx, `
+			SELECT k.embargo_until, (SELECT COUNT(*) FROM press_kit_recipients WHERE song_id = k.song_id)
+			FROM press_kits k WHERE k.song_id = $1;
+		`, songID).Scan(&embargoUntil, &recipients)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "set up the press kit first with PUT /songs/:id/press-kit"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !time.Now().Before(embargoUntil) {
+			c.JSON(http.StatusConflict, gin.H{"error": "the embargo has already lifted"})
+			return
+		}
+		if recipients >= maxPressRecipients {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("a press kit can have at most %d recipients", maxPressRecipients)})
+			return
+		}
+
+		token, err := newOpaqueToken(pressTokenPrefix)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		rc, err := scanPressRecipient(db.QueryRow(ctx, `
+			INSERT INTO press_kit_recipients (song_id, token_hash, name, email, outlet)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+pressRecipientColumns+`;
+		`, songID, hashToken(token), body.Name, body.Email, body.Outlet))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		jobID, err := EnqueueJob(ctx, pressRenderJob, pressRenderPayload{RecipientID: rc.ID}, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{
+			"recipient": rc,
+			"token":     token,
+			"press_url": requestOrigin(c) + "/press/" + token,
+			"job_id":    jobID,
+		})
+	})
+
+	// DELETE /songs/:id/press-kit/recipients/:recipientId — revokes the link and deletes the preview
+	kit.DELETE("/recipients/:recipientId", func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "manage press kits for")
+		if !ok {
+			return
+		}
+		recipientID, err := strconv.ParseInt(c.Param("recipientId"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recipient id"})
+			return
+		}
+		ctx := c.Request.Context()
+
+		rc, err := scanPressRecipient(db.QueryRow(ctx, `
+			UPDATE press_kit_recipients SET revoked_at = now()
+			WHERE id = $1 AND song_id = $2 AND revoked_at IS NULL
+			RETURNING `+pressRecipientColumns+`;
+		`, recipientID, songID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipient not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := deletePressPreview(ctx, rc); err != nil {
+			log.Printf("press recipient %d: failed to delete preview: %v", rc.ID, err)
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	// GET /press/:token — the recipient's press page; counts the view
+	r.GET("/press/:token", func(c *gin.Context) {
+		rc, k, s, ok := loadPressAccess(c)
+		if !ok {
+			return
+		}
+		if _, err := db.Exec(context.Background(), `
+			UPDATE press_kit_recipients
+			SET views = views + 1, first_viewed_at = COALESCE(first_viewed_at, now()), last_viewed_at = now()
+			WHERE id = $1;
+		`, rc.ID); err != nil {
+			log.Printf("press recipient %d: failed to count view: %v", rc.ID, err)
+		}
+
+		page := PressPage{
+			SongID:        s.ID,
+			Title:         s.Title,
+			ArtistID:      s.ArtistID,
+			ArtworkURL:    s.ArtworkURL,
+			Headline:      k.Headline,
+			Notes:         k.Notes,
+			EmbargoUntil:  k.EmbargoUntil,
+			RecipientName: rc.Name,
+			PreviewStatus: rc.PreviewStatus,
+		}
+		if rc.PreviewStatus == "ready" {
+			page.AudioURL = requestOrigin(c) + "/press/" + c.Param("token") + "/audio"
+		}
+		c.Header("Cache-Control", "no-store")
+		c.Header("X-Robots-Tag", "noindex")
+		c.JSON(http.StatusOK, page)
+	})
+
+	// GET /press/:token/audio — counts the play and redirects to the recipient's watermarked preview
+	r.GET("/press/:token/audio", func(c *gin.Context) {
+		rc, _, _, ok := loadPressAccess(c)
+		if !ok {
+			return
+		}
+		if rc.PreviewStatus != "ready" {
+			c.JSON(http.StatusConflict, gin.H{"error": "preview is " + rc.PreviewStatus})
+			return
+		}
+		if r := c.GetHeader("Range"); r == "" || strings.HasPrefix(r, "bytes=0-") {
+			if _, err := db.Exec(context.Background(), `UPDATE press_kit_recipients SET plays = plays + 1 WHERE id = $1;`, rc.ID); err != nil {
+				log.Printf("press recipient %d: failed to count play: %v", rc.ID, err)
+			}
+		}
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, storageFor(*rc.StorageRegion).PresignGet(*rc.StorageKey, pressURLExpiry))
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Users can keep their activity to themselves. Each switch is enforced
+// wherever that activity reaches someone else:
+//   - hide_listening_history: their plays are left out of the "fans also
+//     listen to" similarity build.
+//   - hide_library: GET /users/:id/reposts is refused to anyone else.
+//   - hide_tips: artists see their tips as anonymous, in the tip.confirmed
+//     webhook and the new_tip integration feed.
+const privacyColumns = `hide_listening_history, hide_library, hide_tips`
+
+// loadPrivacy returns userID's settings; users without a profile have the
+// defaults, which hide nothing.
+func loadPrivacy(ctx context.Context, userID string) (PrivacySettings, error) {
+	var p PrivacySettings
+	err := db.QueryRow(ctx, `SELECT `+privacyColumns+` FROM profiles WHERE id::text = $1;`, userID).
+		Scan(&p.HideListeningHistory, &p.HideLibrary, &p.HideTips)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return PrivacySettings{}, nil
+	}
+	return p, err
+}
+
+// RegisterPrivacyRoutes defines the caller's activity privacy settings.
+func RegisterPrivacyRoutes(r *gin.Engine) {
+	// GET /auth/me/privacy
+	r.GET("/auth/me/privacy", RequireAuth(), func(c *gin.Context) {
+		p, err := loadPrivacy(c.Request.Context(), currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, p)
+	})
+
+	// PATCH /auth/me/privacy {"hide_listening_history","hide_library","hide_tips"} — omitted fields are unchanged
+	r.PATCH("/auth/me/privacy", RequireAuth(), func(c *gin.Context) {
+		var body struct {
+			HideListeningHistory *bool `json:"hide_listening_history"`
+			HideLibrary          *bool `json:"hide_library"`
+			HideTips             *bool `json:"hide_tips"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+
+		var p PrivacySettings
+		err := db.QueryRow(c.Request.Context(), `
+			UPDATE profiles SET
+				hide_listening_history = COALESCE($2, hide_listening_history),
+				hide_library           = COALESCE($3, hide_library),
+				hide_tips              = COALESCE($4, hide_tips),
+				updated_at             = now()
+			WHERE id = $1
+			RETURNING `+privacyColumns+`;
+		`, currentUserID(c), body.HideListeningHistory, body.HideLibrary, body.HideTips).
+			Scan(&p.HideListeningHistory, &p.HideLibrary, &p.HideTips)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "profile not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, p)
+	})
+}
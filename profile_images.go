@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Avatars and banners are uploaded once and stored as fixed-size JPEG
+// renditions at avatars/<user_id>/<kind>-<sha256>-<WxH>.jpg, the hash being
+// that of the uploaded file. As with /assets, a URL never changes meaning,
+// so renditions are cached forever and a new upload just moves the profile
+// to a new hash. The largest rendition is the one moderated.
+const (
+	profileImageKeyPrefix = "avatars/"
+	maxProfileImageBytes  = 10 << 20
+	maxProfileImagePixels = 25_000_000
+	profileImageQuality   = 85
+)
+
+type imageSize struct {
+	W, H int
+}
+
+func (s imageSize) String() string {
+	return fmt.Sprintf("%dx%d", s.W, s.H)
+}
+
+type profileImageKind struct {
+	sizes []imageSize // largest first
+	min   imageSize
+}
+
+var profileImageKinds = map[string]profileImageKind{
+	imageKindAvatar: {sizes: []imageSize{{512, 512}, {256, 256}, {64, 64}}, min: imageSize{128, 128}},
+	imageKindBanner: {sizes: []imageSize{{1500, 500}, {750, 250}}, min: imageSize{600, 200}},
+}
+
+var (
+	userIDPattern           = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	profileImageFilePattern = regexp.MustCompile(`^(avatar|banner)-([0-9a-f]{64})-([0-9]+x[0-9]+)\.jpg$`)
+)
+
+func profileImageKey(userID, kind, hash string, size imageSize) string {
+	return profileImageKeyPrefix + userID + "/" + kind + "-" + hash + "-" + size.String() + ".jpg"
+}
+
+// profileImageURLs maps each rendition size to its URL, or to the
+// placeholder while the image isn't approved.
+func profileImageURLs(userID, kind, hash, status string) map[string]string {
+	urls := map[string]string{}
+	for _, size := range profileImageKinds[kind].sizes {
+		if status == moderationApproved {
+			urls[size.String()] = cfg.AssetBaseURL + "/" + profileImageKey(userID, kind, hash, size)
+		} else {
+			urls[size.String()] = cfg.AssetBaseURL + "/assets/" + placeholderAsset
+		}
+	}
+	return urls
+}
+
+// renderProfileImage center-crops img to size's aspect ratio, scales it to
+// size, and encodes it as JPEG. Transparency is flattened onto white.
+func renderProfileImage(img image.Image, size imageSize) ([]byte, error) {
+	b := img.Bounds()
+	crop := b
+	if b.Dx()*size.H > b.Dy()*size.W {
+		w := b.Dy() * size.W / size.H
+		crop.Min.X += (b.Dx() - w) / 2
+		crop.Max.X = crop.Min.X + w
+	} else {
+		h := b.Dx() * size.H / size.W
+		crop.Min.Y += (b.Dy() - h) / 2
+		crop.Max.Y = crop.Min.Y + h
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, crop.Dx(), crop.Dy()))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(src, src.Bounds(), img, crop.Min, draw.Over)
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, scaleRGBA(src, size), &jpeg.Options{Quality: profileImageQuality})
+	return buf.Bytes(), err
+}
+
+// scaleRGBA resizes src to size, averaging the source pixels each
+// destination pixel covers.
+func scaleRGBA(src *image.RGBA, size imageSize) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, size.W, size.H))
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	for y := 0; y < size.H; y++ {
+		y0, y1 := y*sh/size.H, (y+1)*sh/size.H
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < size.W; x++ {
+			x0, x1 := x*sw/size.W, (x+1)*sw/size.W
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+			var r, g, bl, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4:]
+					r, g, bl, n = r+int(p[0]), g+int(p[1]), bl+int(p[2]), n+1
+				}
+			}
+			d := dst.Pix[y*dst.Stride+x*4:]
+			d[0], d[1], d[2], d[3] = uint8(r/n), uint8(g/n), uint8(bl/n), 0xff
+		}
+	}
+	return dst
+}
+
+// decodeProfileImage decodes a JPEG or PNG upload after checking its
+// dimensions, so a small file can't expand into a huge bitmap.
+func decodeProfileImage(data []byte, kind profileImageKind) (image.Image, error) {
+	conf, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "jpeg" && format != "png") {
+		return nil, fmt.Errorf("image must be JPEG or PNG")
+	}
+	if conf.Width*conf.Height > maxProfileImagePixels {
+		return nil, fmt.Errorf("image must be at most %d pixels", maxProfileImagePixels)
+	}
+	if conf.Width < kind.min.W || conf.Height < kind.min.H {
+		return nil, fmt.Errorf("image must be at least %s", kind.min)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("image could not be decoded")
+	}
+	return img, nil
+}
+
+// storeProfileImage renders and uploads every rendition of img, decoded
+// from data, returning the hash the profile should point at.
+func storeProfileImage(ctx context.Context, userID, kind string, data []byte, img image.Image) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	for _, size := range profileImageKinds[kind].sizes {
+		out, err := renderProfileImage(img, size)
+		if err != nil {
+			return "", err
+		}
+		key := profileImageKey(userID, kind, hash, size)
+		if err := storage.PutObject(ctx, key, bytes.NewReader(out), int64(len(out)), "image/jpeg"); err != nil {
+			return "", err
+		}
+	}
+	return hash, nil
+}
+
+// deleteProfileImage removes every rendition of an image.
+func deleteProfileImage(ctx context.Context, userID, kind, hash string) error {
+	for _, size := range profileImageKinds[kind].sizes {
+		if err := storage.DeleteObject(ctx, profileImageKey(userID, kind, hash, size)); err != nil && !errors.Is(err, ErrObjectNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterProfileImageRoutes defines avatar and banner uploads and the
+// public rendition proxy.
+func RegisterProfileImageRoutes(r *gin.Engine) {
+	// GET /avatars/:user/:file — <kind>-<sha256>-<WxH>.jpg, served with a far-future cache
+	r.GET("/avatars/:user/:file", func(c *gin.Context) {
+		m := profileImageFilePattern.FindStringSubmatch(c.Param("file"))
+		if m == nil || !userIDPattern.MatchString(c.Param("user")) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "image not found"})
+			return
+		}
+		if storage == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is not configured"})
+			return
+		}
+		serveImmutableObject(c, profileImageKeyPrefix+c.Param("user")+"/"+m[0], `"`+m[2]+"-"+m[3]+`"`, "image/jpeg")
+	})
+
+	// POST /auth/me/avatar — multipart, JPEG or PNG in the "image" field
+	// POST /auth/me/banner
+	upload := func(kind string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			if storage == nil {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is not configured"})
+				return
+			}
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxProfileImageBytes+1<<20)
+			fh, err := c.FormFile("image")
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": `expected a multipart "image" file`})
+				return
+			}
+			if fh.Size > maxProfileImageBytes {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": kind + " is too large", "max_bytes": maxProfileImageBytes})
+				return
+			}
+			f, err := fh.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "could not read image"})
+				return
+			}
+			data, err := io.ReadAll(io.LimitReader(f, maxProfileImageBytes))
+			f.Close()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "could not read image"})
+				return
+			}
+
+			img, err := decodeProfileImage(data, profileImageKinds[kind])
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+				return
+			}
+
+			ctx := context.Background()
+			userID := currentUserID(c)
+			hash, err := storeProfileImage(ctx, userID, kind, data, img)
+			if err != nil {
+				writeStorageError(c, http.StatusInternalServerError, err)
+				return
+			}
+
+			status := initialModerationStatus()
+			sql := `UPDATE profiles SET avatar_hash = $2, avatar_status = $3 WHERE id = $1;`
+			if kind == imageKindBanner {
+				sql = `UPDATE profiles SET banner_hash = $2, banner_status = $3 WHERE id = $1;`
+			}
+			tag, err := db.Exec(ctx, sql, userID, hash, status)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if tag.RowsAffected() == 0 {
+				c.JSON(http.StatusNotFound, gin.H{"error": "profile not found"})
+				return
+			}
+			if status == moderationPending {
+				if err := queueImageModeration(ctx, kind, userID, hash, "jpg", userID); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+			}
+
+			urls := profileImageURLs(userID, kind, hash, status)
+			c.JSON(http.StatusOK, gin.H{
+				"url":               urls[profileImageKinds[kind].sizes[0].String()],
+				"sizes":             urls,
+				"moderation_status": status,
+			})
+		}
+	}
+	r.POST("/auth/me/avatar", RequireAuth(), upload(imageKindAvatar))
+	r.POST("/auth/me/banner", RequireAuth(), upload(imageKindBanner))
+}
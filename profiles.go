@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Users edit their own display name, bio, links, and genre preferences
+// with PATCH /auth/me. The update runs as the caller (the "authenticated"
+// role with their JWT claims) so the profiles RLS policy and column grants
+// apply just as they would to a direct Supabase client.
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 500
+	maxProfileLinks      = 10
+	maxLinkLabelLength   = 40
+	maxGenrePreferences  = 10
+	maxGenreLength       = 32
+)
+
+// UpdateProfileRequest is the body of PATCH /auth/me. Omitted fields are
+// left unchanged; an empty bio clears it.
+type UpdateProfileRequest struct {
+	DisplayName      *string        `json:"display_name"`
+	Bio              *string        `json:"bio"`
+	Links            *[]ProfileLink `json:"links"`
+	GenrePreferences *[]string      `json:"genre_preferences"`
+}
+
+// normalize trims the fields and lowercases and dedupes genres, then
+// checks them.
+func (in *UpdateProfileRequest) normalize() error {
+	if in.DisplayName == nil && in.Bio == nil && in.Links == nil && in.GenrePreferences == nil {
+		return fmt.Errorf("nothing to update")
+	}
+	if in.DisplayName != nil {
+		name := strings.TrimSpace(*in.DisplayName)
+		if name == "" {
+			return fmt.Errorf("display_name cannot be empty")
+		}
+		if utf8.RuneCountInString(name) > maxDisplayNameLength {
+			return fmt.Errorf("display_name must be at most %d characters", maxDisplayNameLength)
+		}
+		in.DisplayName = &name
+	}
+	if in.Bio != nil {
+		bio := strings.TrimSpace(*in.Bio)
+		if utf8.RuneCountInString(bio) > maxBioLength {
+			return fmt.Errorf("bio must be at most %d characters", maxBioLength)
+		}
+		in.Bio = &bio
+	}
+	if in.Links != nil {
+		links, err := normalizeLinks(*in.Links)
+		if err != nil {
+			return err
+		}
+		in.Links = &links
+	}
+	if in.GenrePreferences != nil {
+		genres := []string{}
+		seen := map[string]bool{}
+		for _, g := range *in.GenrePreferences {
+			g = strings.ToLower(strings.TrimSpace(g))
+			if g == "" || utf8.RuneCountInString(g) > maxGenreLength {
+				return fmt.Errorf("genres must be 1-%d characters", maxGenreLength)
+			}
+			if !seen[g] {
+				seen[g] = true
+				genres = append(genres, g)
+			}
+		}
+		if len(genres) > maxGenrePreferences {
+			return fmt.Errorf("at most %d genre preferences", maxGenrePreferences)
+		}
+		in.GenrePreferences = &genres
+	}
+	return nil
+}
+
+// normalizeLinks trims labeled links and checks they are https URLs.
+func normalizeLinks(in []ProfileLink) ([]ProfileLink, error) {
+	if len(in) > maxProfileLinks {
+		return nil, fmt.Errorf("at most %d links", maxProfileLinks)
+	}
+	links := make([]ProfileLink, 0, len(in))
+	for _, l := range in {
+		l.Label, l.URL = strings.TrimSpace(l.Label), strings.TrimSpace(l.URL)
+		if l.Label == "" || utf8.RuneCountInString(l.Label) > maxLinkLabelLength {
+			return nil, fmt.Errorf("link labels must be 1-%d characters", maxLinkLabelLength)
+		}
+		if u, err := url.Parse(l.URL); err != nil || u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("link %q must be an https URL", l.Label)
+		}
+		links = append(links, l)
+	}
+	return links, nil
+}
+
+const profileColumns = `id::text, handle, display_name, verified, bio, links, genre_preferences,
+	avatar_hash, avatar_status, banner_hash, banner_status, updated_at`
+
+// scanProfile scans profileColumns. Image URLs are the placeholder while
+// the image awaits moderation.
+func scanProfile(row pgx.Row) (Profile, error) {
+	var p Profile
+	var links []byte
+	var avatarHash, avatarStatus, bannerHash, bannerStatus *string
+	err := row.Scan(&p.ID, &p.Handle, &p.DisplayName, &p.Verified, &p.Bio, &links, &p.GenrePreferences,
+		&avatarHash, &avatarStatus, &bannerHash, &bannerStatus, &p.UpdatedAt)
+	if err != nil {
+		return p, err
+	}
+	p.Links = []ProfileLink{}
+	json.Unmarshal(links, &p.Links)
+	p.AvatarURL, p.AvatarURLs, p.AvatarStatus = profileImage(p.ID, imageKindAvatar, avatarHash, avatarStatus)
+	p.BannerURL, p.BannerURLs, p.BannerStatus = profileImage(p.ID, imageKindBanner, bannerHash, bannerStatus)
+	return p, nil
+}
+
+// profileImage returns the URL of the largest rendition, every rendition's
+// URL, and the moderation status unless approved.
+func profileImage(userID, kind string, hash, status *string) (*string, map[string]string, *string) {
+	if hash == nil || status == nil {
+		return nil, nil, nil
+	}
+	urls := profileImageURLs(userID, kind, *hash, *status)
+	u := urls[profileImageKinds[kind].sizes[0].String()]
+	if *status == moderationApproved {
+		status = nil
+	}
+	return &u, urls, status
+}
+
+// actAsUser switches tx to the caller's database identity, so RLS policies
+// that check auth.uid() apply to the rest of the transaction.
+func actAsUser(ctx context.Context, tx pgx.Tx, userID string) error {
+	claims, _ := json.Marshal(gin.H{"sub": userID, "role": "authenticated"})
+	_, err := tx.Exec(ctx, `
+		SELECT set_config('request.jwt.claims', $1, true), set_config('role', 'authenticated', true);
+	`, string(claims))
+	return err
+}
+
+// RegisterProfileRoutes defines PATCH /auth/me.
+func RegisterProfileRoutes(r *gin.Engine) {
+	// PATCH /auth/me {"display_name","bio","links":[{"label","url"}],"genre_preferences"}
+	r.PATCH("/auth/me", RequireAuth(), func(c *gin.Context) {
+		var body UpdateProfileRequest
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if err := body.normalize(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		var linksJSON []byte
+		if body.Links != nil {
+			linksJSON, _ = json.Marshal(*body.Links)
+		}
+		ctx := c.Request.Context()
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback(ctx)
+		if err := actAsUser(ctx, tx, currentUserID(c)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		p, err := scanProfile(tx.QueryRow(ctx, `
+			UPDATE profiles SET
+				display_name      = COALESCE($2, display_name),
+				bio               = CASE WHEN $3::text IS NULL THEN bio ELSE NULLIF($3, '') END,
+				links             = COALESCE($4, links),
+				genre_preferences = COALESCE($5, genre_preferences),
+				updated_at        = now()
+			WHERE id = $1
+			RETURNING `+profileColumns+`;
+		`, currentUserID(c), body.DisplayName, body.Bio, linksJSON, body.GenrePreferences))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "profile not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := tx.Commit(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, p)
+	})
+}
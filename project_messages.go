@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Project chat: members post messages, optionally with an attachment (see
+// attachments.go); members and guests read them newest first, a page at a
+// time.
+const (
+	maxProjectMessageBody = 4000
+	defaultMessagePage    = 50
+	maxMessagePage        = 200
+)
+
+const projectMessageColumns = `id, project_id, author_id::text, body, attachment_id, created_at`
+
+func scanProjectMessage(row pgx.Row) (ProjectMessage, error) {
+	var m ProjectMessage
+	err := row.Scan(&m.ID, &m.ProjectID, &m.AuthorID, &m.Body, &m.AttachmentID, &m.CreatedAt)
+	return m, err
+}
+
+// withMessageAttachments fills in the attachment of each message that has one.
+func withMessageAttachments(ctx context.Context, messages []ProjectMessage) error {
+	ids := []int64{}
+	for _, m := range messages {
+		if m.AttachmentID != nil {
+			ids = append(ids, *m.AttachmentID)
+		}
+	}
+	attachments, err := loadAttachments(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i, m := range messages {
+		if m.AttachmentID != nil {
+			if a, ok := attachments[*m.AttachmentID]; ok {
+				messages[i].Attachment = &a
+			}
+		}
+	}
+	return nil
+}
+
+// messageInput checks a message or comment body and its optional
+// attachment in projectID: the body may only be empty when there is an
+// attachment. It writes the error response when refused.
+func messageInput(c *gin.Context, projectID int64, body string, attachmentID *int64, maxBody int) (string, bool) {
+	text := strings.TrimSpace(body)
+	if utf8.RuneCountInString(text) > maxBody || (text == "" && attachmentID == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("body must be 1-%d characters, or empty with an attachment", maxBody)})
+		return "", false
+	}
+	if attachmentID != nil && !checkAttachment(c, projectID, *attachmentID) {
+		return "", false
+	}
+	return text, true
+}
+
+// RegisterProjectMessageRoutes defines project chat.
+func RegisterProjectMessageRoutes(r *gin.Engine) {
+	// GET /projects/:id/messages?before=<id>&limit=50 — members and guests; newest first
+	r.GET("/projects/:id/messages", RequireProjectAccess(), func(c *gin.Context) {
+		limit := defaultMessagePage
+		if s := c.Query("limit"); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil || v < 1 || v > maxMessagePage {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be 1-%d", maxMessagePage)})
+				return
+			}
+			limit = v
+		}
+		var before *int64
+		if s := c.Query("before"); s != "" {
+			v, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "before must be a message id"})
+				return
+			}
+			before = &v
+		}
+
+		ctx := c.Request.Context()
+		rows, err := db.Query(ctx, `
+			SELECT `+projectMessageColumns+` FROM project_messages
+			WHERE project_id = $1 AND ($2::bigint IS NULL OR id < $2)
+			ORDER BY id DESC
+			LIMIT $3;
+		`, c.GetInt64("project_id"), before, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		messages := []ProjectMessage{}
+		for rows.Next() {
+			m, err := scanProjectMessage(rows)
+			if err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			messages = append(messages, m)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := withMessageAttachments(ctx, messages); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, messages)
+	})
+
+	// POST /projects/:id/messages {"body","attachment_id"?} — members only
+	r.POST("/projects/:id/messages", RequireProjectAccess(), func(c *gin.Context) {
+		var body struct {
+			Body         string `json:"body"`
+			AttachmentID *int64 `json:"attachment_id"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		projectID := c.GetInt64("project_id")
+		text, ok := messageInput(c, projectID, body.Body, body.AttachmentID, maxProjectMessageBody)
+		if !ok {
+			return
+		}
+
+		ctx := c.Request.Context()
+		m, err := scanProjectMessage(db.QueryRow(ctx, `
+			INSERT INTO project_messages (project_id, author_id, body, attachment_id) VALUES ($1, $2, $3, $4)
+			RETURNING `+projectMessageColumns+`;
+		`, projectID, currentUserID(c), text, body.AttachmentID))
+		if err != nil {
+			writeAttachError(c, err)
+			return
+		}
+		messages := []ProjectMessage{m}
+		if err := withMessageAttachments(ctx, messages); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, messages[0])
+	})
+}
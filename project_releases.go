@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// A project owner releases a song by picking one of the project's stems as
+// the master. The song starts as a draft with the master as its original
+// rendition. Optionally a stem_alignment job checks the master against the
+// longest stem: a bounce shorter or longer than the session usually means
+// the wrong file was picked, so every collaborator is warned before the
+// draft is published.
+const (
+	stemAlignmentJob        = "stem_alignment"
+	defaultAlignmentSeconds = 1.0
+	maxAlignmentSeconds     = 30.0
+)
+
+type stemAlignmentPayload struct {
+	ReleaseID int64 `json:"release_id"`
+}
+
+func init() {
+	RegisterJobHandler(stemAlignmentJob, runStemAlignment)
+}
+
+const projectReleaseColumns = `id, project_id, song_id, master_stem_id, created_by, tolerance_seconds, alignment_status,
+	master_seconds, longest_stem_id, longest_stem_seconds, alignment_error, created_at, checked_at`
+
+func scanProjectRelease(row pgx.Row) (ProjectRelease, error) {
+	var r ProjectRelease
+	err := row.Scan(&r.ID, &r.ProjectID, &r.SongID, &r.MasterStemID, &r.CreatedBy, &r.ToleranceSeconds,
+		&r.AlignmentStatus, &r.MasterSeconds, &r.LongestStemID, &r.LongestStemSeconds, &r.AlignmentError,
+		&r.CreatedAt, &r.CheckedAt)
+	return r, err
+}
+
+// projectMemberIDs returns the owner and every invitee.
+func projectMemberIDs(ctx context.Context, projectID int64) ([]string, error) {
+	rows, err := db.Query(ctx, `
+		SELECT owner_id::text FROM projects WHERE id = $1
+		UNION
+		SELECT invitee_id::text FROM project_invitations WHERE project_id = $1;
+	`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func runStemAlignment(ctx context.Context, job *Job) (interface{}, error) {
+	var p stemAlignmentPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return nil, err
+	}
+
+	rel, err := scanProjectRelease(db.QueryRow(ctx,
+		`SELECT `+projectReleaseColumns+` FROM project_releases WHERE id = $1;`, p.ReleaseID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return gin.H{"skipped": true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if storage == nil {
+		return nil, fmt.Errorf("storage is not configured")
+	}
+
+	stems, err := loadProjectStems(ctx, rel.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		masterSeconds, longestSeconds float64
+		longestID                     *int64
+	)
+	for _, s := range stems {
+		if !strings.HasPrefix(s.ContentType, "audio/") {
+			continue
+		}
+		seconds, err := probeDuration(ctx, storageFor(s.StorageRegion).PresignGet(s.FileKey, processingURLExpiry))
+		if err != nil {
+			err = fmt.Errorf("stem %d (%s): %w", s.ID, s.Filename, err)
+			if job.Attempts >= jobMaxAttempts {
+				recordAlignmentError(ctx, rel.ID, err)
+			}
+			return nil, err
+		}
+		if s.ID == rel.MasterStemID {
+			masterSeconds = seconds
+			continue
+		}
+		if longestID == nil || seconds > longestSeconds {
+			id := s.ID
+			longestID, longestSeconds = &id, seconds
+		}
+	}
+
+	status := "ok"
+	if longestID != nil && math.Abs(masterSeconds-longestSeconds) > rel.ToleranceSeconds {
+		status = "mismatch"
+	}
+	var longest *float64
+	if longestID != nil {
+		longest = &longestSeconds
+	}
+	_, err = db.Exec(ctx, `
+		UPDATE project_releases
+		SET alignment_status = $2, master_seconds = $3, longest_stem_id = $4, longest_stem_seconds = $5,
+		    alignment_error = NULL, checked_at = now()
+		WHERE id = $1;
+	`, rel.ID, status, masterSeconds, longestID, longest)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == "mismatch" {
+		warnAlignmentMismatch(ctx, rel, masterSeconds, longestSeconds)
+	}
+	return gin.H{"alignment_status": status, "master_seconds": masterSeconds, "longest_stem_seconds": longest}, nil
+}
+
+func recordAlignmentError(ctx context.Context, releaseID int64, cause error) {
+	_, err := db.Exec(ctx, `
+		UPDATE project_releases SET alignment_status = 'error', alignment_error = $2, checked_at = now()
+		WHERE id = $1;
+	`, releaseID, cause.Error())
+	if err != nil {
+		log.Printf("project release %d: failed to record alignment error: %v", releaseID, err)
+	}
+}
+
+// warnAlignmentMismatch notifies every collaborator on the project.
+func warnAlignmentMismatch(ctx context.Context, rel ProjectRelease, masterSeconds, longestSeconds float64) {
+	members, err := projectMemberIDs(ctx, rel.ProjectID)
+	if err != nil {
+		log.Printf("project release %d: failed to load members: %v", rel.ID, err)
+		return
+	}
+
+	body := fmt.Sprintf("The master is %.1fs but the longest stem is %.1fs. Check the right bounce was picked before publishing.",
+		masterSeconds, longestSeconds)
+	data := gin.H{"project_id": rel.ProjectID, "release_id": rel.ID, "song_id": rel.SongID}
+	for _, userID := range members {
+		if err := notify(ctx, userID, "stem_alignment", "Master doesn't match the stems", body, data); err != nil {
+			log.Printf("project release %d: failed to notify %s: %v", rel.ID, userID, err)
+		}
+	}
+}
+
+// RegisterProjectReleaseRoutes defines releasing a song from a project.
+func RegisterProjectReleaseRoutes(r *gin.Engine) {
+	// POST /projects/:id/releases — {"title":"...","master_stem_id":1,"verify_alignment":true,"tolerance_seconds":1}
+	r.POST("/projects/:id/releases", RequireProjectAccess(), func(c *gin.Context) {
+		projectID := c.GetInt64("project_id")
+		if !requireProjectOwner(c, projectID) {
+			return
+		}
+
+		var body struct {
+			Title            string   `json:"title"`
+			MasterStemID     int64    `json:"master_stem_id"`
+			VerifyAlignment  bool     `json:"verify_alignment"`
+			ToleranceSeconds *float64 `json:"tolerance_seconds"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		body.Title = strings.TrimSpace(body.Title)
+		if body.Title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+			return
+		}
+		tolerance := defaultAlignmentSeconds
+		if body.ToleranceSeconds != nil {
+			tolerance = *body.ToleranceSeconds
+		}
+		if tolerance < 0 || tolerance > maxAlignmentSeconds {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("tolerance_seconds must be between 0 and %g", maxAlignmentSeconds)})
+			return
+		}
+
+		var master ProjectStem
+		err := db.QueryRow(context.Background(), `
+			SELECT id, file_key, storage_region, size_bytes, content_type FROM project_stems WHERE id = $1 AND project_id = $2;
+		`, body.MasterStemID, projectID).Scan(&master.ID, &master.FileKey, &master.StorageRegion, &master.SizeBytes, &master.ContentType)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "master_stem_id is not a stem in this project"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if audioTypes[master.ContentType] == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the master stem is not a supported audio file", "content_type": master.ContentType})
+			return
+		}
+
+		alignment := "skipped"
+		if body.VerifyAlignment {
+			alignment = "pending"
+		}
+
+		tx, err := db.Begin(context.Background())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback(context.Background())
+
+		var songID int64
+		err = tx.QueryRow(context.Background(), `
+			INSERT INTO songs (title, artist_id, published) VALUES ($1, $2, false) RETURNING id;
+		`, body.Title, currentUserID(c)).Scan(&songID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		_, err = tx.Exec(context.Background(), `
+			INSERT INTO song_renditions (song_id, name, storage_key, storage_region, content_type, size_bytes)
+			VALUES ($1, $2, $3, $4, $5, $6);
+		`, songID, originalRendition, master.FileKey, master.StorageRegion, master.ContentType, master.SizeBytes)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		_, err = tx.Exec(context.Background(), `
+			INSERT INTO song_audio_versions (song_id, version, storage_key, storage_region, content_type, size_bytes, uploaded_by)
+			VALUES ($1, 1, $2, $3, $4, $5, $6);
+		`, songID, master.FileKey, master.StorageRegion, master.ContentType, master.SizeBytes, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		rel, err := scanProjectRelease(tx.QueryRow(context.Background(), `
+			INSERT INTO project_releases (project_id, song_id, master_stem_id, created_by, tolerance_seconds, alignment_status)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING `+projectReleaseColumns+`;
+		`, projectID, songID, master.ID, currentUserID(c), tolerance, alignment))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := tx.Commit(context.Background()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		resp := gin.H{"release": rel}
+		jobID, err := EnqueueJob(context.Background(), audioProcessingJob,
+			audioProcessingPayload{SongID: songID, Rendition: originalRendition}, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		resp["processing_job_id"] = jobID
+		if body.VerifyAlignment {
+			jobID, err := EnqueueJob(context.Background(), stemAlignmentJob, stemAlignmentPayload{ReleaseID: rel.ID}, currentUserID(c))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			resp["alignment_job_id"] = jobID
+		}
+
+		c.JSON(http.StatusCreated, resp)
+	})
+
+	// GET /projects/:id/releases — with each release's alignment result
+	r.GET("/projects/:id/releases", RequireProjectAccess(), func(c *gin.Context) {
+		if rejectGuest(c) {
+			return
+		}
+
+		rows, err := db.Query(context.Background(),
+			`SELECT `+projectReleaseColumns+` FROM project_releases WHERE project_id = $1 ORDER BY id DESC;`,
+			c.GetInt64("project_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		releases := []ProjectRelease{}
+		for rows.Next() {
+			rel, err := scanProjectRelease(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			releases = append(releases, rel)
+		}
+		c.JSON(http.StatusOK, releases)
+	})
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// projectAccess reports whether userID owns the project or was invited to it.
+// found is false when the project does not exist.
+func projectAccess(ctx context.Context, projectID int64, userID string) (isOwner, isMember, found bool, err error) {
+	sql := `
+		SELECT
+			p.owner_id = $2,
+			EXISTS (
+				SELECT 1 FROM project_invitations i
+				WHERE i.project_id = p.id AND i.invitee_id = $2
+			)
+		FROM projects p
+		WHERE p.id = $1;
+	`
+
+	err = db.QueryRow(ctx, sql, projectID, userID).Scan(&isOwner, &isMember)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, false, false, nil
+	}
+	if err != nil {
+		return false, false, false, err
+	}
+	return isOwner, isOwner || isMember, true, nil
+}
+
+// RegisterProjectRoutes defines the read endpoints for a single project.
+func RegisterProjectRoutes(r *gin.Engine) {
+	// GET /projects/:id — members, or guests holding a valid link
+	r.GET("/projects/:id", RequireProjectAccess(), func(c *gin.Context) {
+		projectID := c.GetInt64("project_id")
+
+		p, err := loadProject(context.Background(), projectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		invitations, err := loadProjectInvitations(context.Background(), projectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"project": p, "invitations": invitations})
+	})
+
+	// GET /projects/:id/contributions — per-member activity for split-sheet talks
+	r.GET("/projects/:id/contributions", RequireProjectAccess(), func(c *gin.Context) {
+		if rejectGuest(c) {
+			return
+		}
+
+		sql := `
+			WITH members AS (
+				SELECT owner_id AS user_id FROM projects WHERE id = $1
+				UNION
+				SELECT invitee_id FROM project_invitations WHERE project_id = $1
+			)
+			SELECT
+				m.user_id,
+				COALESCE(s.uploads, 0),
+				COALESCE(s.storage_bytes, 0),
+				COALESCE(msg.comments, 0),
+				COALESCE(t.tasks_completed, 0)
+			FROM members m
+			LEFT JOIN (
+				SELECT uploader_id, COUNT(*) AS uploads, SUM(size_bytes)::bigint AS storage_bytes
+				FROM project_stems WHERE project_id = $1
+				GROUP BY uploader_id
+			) s ON s.uploader_id = m.user_id
+			LEFT JOIN (
+				SELECT author_id, COUNT(*) AS comments
+				FROM project_messages WHERE project_id = $1
+				GROUP BY author_id
+			) msg ON msg.author_id = m.user_id
+			LEFT JOIN (
+				SELECT assignee_id, COUNT(*) AS tasks_completed
+				FROM project_tasks WHERE project_id = $1 AND completed_at IS NOT NULL
+				GROUP BY assignee_id
+			) t ON t.assignee_id = m.user_id
+			ORDER BY COALESCE(s.uploads, 0) DESC, m.user_id;
+		`
+
+		rows, err := db.Query(context.Background(), sql, c.GetInt64("project_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		type MemberContribution struct {
+			UserID         string `json:"user_id"`
+			Uploads        int64  `json:"uploads"`
+			StorageBytes   int64  `json:"storage_bytes"`
+			Comments       int64  `json:"comments"`
+			TasksCompleted int64  `json:"tasks_completed"`
+		}
+
+		contributions := []MemberContribution{}
+		for rows.Next() {
+			var m MemberContribution
+			if err := rows.Scan(&m.UserID, &m.Uploads, &m.StorageBytes, &m.Comments, &m.TasksCompleted); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			contributions = append(contributions, m)
+		}
+
+		c.JSON(http.StatusOK, contributions)
+	})
+}
+
+func loadProject(ctx context.Context, projectID int64) (Project, error) {
+	var p Project
+	err := db.QueryRow(ctx,
+		`SELECT id, owner_id, title, created_at FROM projects WHERE id = $1;`,
+		projectID,
+	).Scan(&p.ID, &p.OwnerID, &p.Title, &p.CreatedAt)
+	return p, err
+}
+
+func loadProjectInvitations(ctx context.Context, projectID int64) ([]ProjectInvitation, error) {
+	rows, err := db.Query(ctx, `
+		SELECT id, project_id, invitee_id, created_at
+		FROM project_invitations
+		WHERE project_id = $1
+		ORDER BY created_at;
+	`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []ProjectInvitation{}
+	for rows.Next() {
+		var inv ProjectInvitation
+		if err := rows.Scan(&inv.ID, &inv.ProjectID, &inv.InviteeID, &inv.CreatedAt); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, inv)
+	}
+	return invitations, rows.Err()
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Related songs for a song page. Candidates are gathered from four sources —
+// songs co-listened by the seed's recent listeners, the seed artist's other
+// songs, popular songs in the same genre, and songs by artists the
+// similarity graph puts next to the seed's — and each gets a set of
+// features scored in Go by a relatedScorer. Scorers are named so new ones
+// can be tried side by side: a running related_songs experiment whose
+// variant names a scorer routes enrolled users to it, and everyone else
+// gets defaultRelatedScorer. Songs don't carry tags, so genre is the only
+// shared-label signal.
+const (
+	relatedExperimentKey = "related_songs"
+	defaultRelatedScorer = "blend_v1"
+
+	relatedWindowDays     = 90
+	relatedPopularDays    = 28
+	relatedSeedListeners  = 1000
+	relatedMinCoListeners = 3
+	relatedCandidates     = 200
+	defaultRelatedLimit   = 20
+	maxRelatedLimit       = 50
+)
+
+// relatedFeatures describes one candidate relative to the seed song.
+type relatedFeatures struct {
+	SongID           int64
+	CoListeners      int64   // seed listeners who also played the candidate
+	SeedListeners    int64   // seed listeners considered, at most relatedSeedListeners
+	SameGenre        bool    // both have the same, non-empty genre
+	SameArtist       bool    // same artist as the seed
+	ArtistSimilarity float64 // artist_similarity score, 0 when unrelated
+	RecentPlays      int64   // plays in the last relatedPopularDays
+}
+
+// A relatedScorer ranks a candidate; higher is more related, and anything
+// at or below zero is dropped.
+type relatedScorer func(f relatedFeatures) float64
+
+var relatedScorers = map[string]relatedScorer{
+	// Co-listening dominates once there's enough of it; genre and the
+	// artist graph carry new and niche songs, with a nudge for popularity
+	// to break ties. Same-artist songs count less than similar artists so
+	// the list isn't one discography.
+	"blend_v1": func(f relatedFeatures) float64 {
+		score := 0.0
+		if f.SeedListeners > 0 {
+			score += 0.6 * float64(f.CoListeners) / float64(f.SeedListeners)
+		}
+		if f.SameGenre {
+			score += 0.2
+		}
+		if f.SameArtist {
+			score += 0.1
+		}
+		score += 0.15 * f.ArtistSimilarity
+		if score > 0 {
+			score += 0.01 * math.Log1p(float64(f.RecentPlays))
+		}
+		return score
+	},
+	// Co-listening only, for comparison against the blend.
+	"co_listen": func(f relatedFeatures) float64 {
+		if f.SeedListeners == 0 {
+			return 0
+		}
+		return float64(f.CoListeners) / float64(f.SeedListeners)
+	},
+}
+
+// relatedScorerFor picks the scorer for userID: their related_songs
+// variant when it names a scorer, otherwise the default.
+func relatedScorerFor(ctx context.Context, userID string) (string, error) {
+	e, found, err := loadExperiment(ctx, relatedExperimentKey)
+	if err != nil || !found {
+		return defaultRelatedScorer, err
+	}
+	if v := assignVariant(e, userID); relatedScorers[v] != nil {
+		return v, nil
+	}
+	return defaultRelatedScorer, nil
+}
+
+// loadRelatedFeatures gathers candidates for seed. Listeners hiding their
+// history (see privacy.go) don't count towards co-listening, and
+// candidates with fewer than relatedMinCoListeners shared listeners get no
+// co-listen credit so one person's plays can't be read off the list.
+func loadRelatedFeatures(ctx context.Context, seed int64, hideExplicit bool) ([]relatedFeatures, error) {
+	rows, err := db.Query(ctx, `
+		WITH seed AS (
+			SELECT id, artist_id, NULLIF(genre, '') AS genre FROM songs WHERE id = $1
+		), seed_listeners AS (
+			SELECT DISTINCT COALESCE(e.user_id::text, e.properties->>'device_id') AS listener FROM events e
+			WHERE e.song_id = $1 AND e.event_type = 'play' AND NOT e.is_bot
+			  AND e.occurred_at > now() - make_interval(days => $2)
+			  AND COALESCE(e.user_id::text, e.properties->>'device_id') IS NOT NULL
+			  AND NOT EXISTS (SELECT 1 FROM profiles p WHERE p.id = e.user_id AND p.hide_listening_history)
+			LIMIT $3
+		), co AS (
+			SELECT e.song_id, COUNT(DISTINCT sl.listener) AS n FROM events e
+			JOIN seed_listeners sl ON sl.listener = COALESCE(e.user_id::text, e.properties->>'device_id')
+			WHERE e.event_type = 'play' AND NOT e.is_bot AND e.song_id IS NOT NULL AND e.song_id <> $1
+			  AND e.occurred_at > now() - make_interval(days => $2)
+			GROUP BY e.song_id
+			HAVING COUNT(DISTINCT sl.listener) >= $4
+			ORDER BY n DESC, e.song_id
+			LIMIT $5
+		), sim AS (
+			SELECT a.similar_artist_id, a.score FROM artist_similarity a JOIN seed ON a.artist_id = seed.artist_id
+		), popular AS (
+			SELECT song_id, SUM(plays) AS plays FROM song_daily_stats
+			WHERE day > (now() AT TIME ZONE 'UTC')::date - $6::int
+			GROUP BY song_id
+		), candidates AS (
+			SELECT song_id AS id FROM co
+			UNION
+			(SELECT s.id FROM songs s JOIN seed ON s.artist_id = seed.artist_id
+			 WHERE s.published AND s.trashed_at IS NULL
+			 ORDER BY s.created_at DESC LIMIT $5)
+			UNION
+			(SELECT s.id FROM songs s JOIN seed ON s.genre = seed.genre
+			 LEFT JOIN popular p ON p.song_id = s.id
+			 WHERE s.published AND s.trashed_at IS NULL
+			 ORDER BY p.plays DESC NULLS LAST, s.id DESC LIMIT $5)
+			UNION
+			(SELECT s.id FROM songs s JOIN sim ON sim.similar_artist_id = s.artist_id
+			 LEFT JOIN popular p ON p.song_id = s.id
+			 WHERE s.published AND s.trashed_at IS NULL
+			 ORDER BY sim.score DESC, p.plays DESC NULLS LAST, s.id DESC LIMIT $5)
+		)
+		SELECT s.id, COALESCE(co.n, 0), (SELECT COUNT(*) FROM seed_listeners),
+		       COALESCE(s.genre = seed.genre, false), COALESCE(s.artist_id = seed.artist_id, false),
+		       COALESCE(sim.score, 0), COALESCE(p.plays, 0)
+		FROM candidates c
+		JOIN songs s ON s.id = c.id
+		CROSS JOIN seed
+		LEFT JOIN co ON co.song_id = s.id
+		LEFT JOIN sim ON sim.similar_artist_id = s.artist_id
+		LEFT JOIN popular p ON p.song_id = s.id
+		WHERE s.id <> $1 AND s.published AND s.trashed_at IS NULL AND NOT (s.explicit AND $7);
+	`, seed, relatedWindowDays, relatedSeedListeners, relatedMinCoListeners, relatedCandidates,
+		relatedPopularDays, hideExplicit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	features := []relatedFeatures{}
+	for rows.Next() {
+		var f relatedFeatures
+		if err := rows.Scan(&f.SongID, &f.CoListeners, &f.SeedListeners, &f.SameGenre, &f.SameArtist,
+			&f.ArtistSimilarity, &f.RecentPlays); err != nil {
+			return nil, err
+		}
+		features = append(features, f)
+	}
+	return features, rows.Err()
+}
+
+// relatedReasons names the signals behind a pick, for clients to explain it.
+func relatedReasons(f relatedFeatures) []string {
+	reasons := []string{}
+	if f.CoListeners > 0 {
+		reasons = append(reasons, "co_listened")
+	}
+	if f.SameGenre {
+		reasons = append(reasons, "same_genre")
+	}
+	if f.SameArtist {
+		reasons = append(reasons, "same_artist")
+	}
+	if f.ArtistSimilarity > 0 {
+		reasons = append(reasons, "similar_artist")
+	}
+	return reasons
+}
+
+// RegisterRelatedSongRoutes defines related-song recommendations.
+func RegisterRelatedSongRoutes(r *gin.Engine) {
+	// GET /songs/:id/related?limit=20 — best first, with the scorer used
+	r.GET("/songs/:id/related", AllowClient(), RequireScope(apiScopeSongsRead), OptionalAuth(), func(c *gin.Context) {
+		songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+			return
+		}
+		limit := defaultRelatedLimit
+		if s := c.Query("limit"); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil || v < 1 || v > maxRelatedLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be 1-50"})
+				return
+			}
+			limit = v
+		}
+
+		ctx := c.Request.Context()
+		seed, err := loadSong(ctx, songID)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && !songVisibleTo(seed, currentUserID(c))) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "song not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		hide := hideExplicit(c)
+		if seed.Explicit && abortIfExplicit(c, "this song") {
+			return
+		}
+
+		name, err := relatedScorerFor(ctx, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		features, err := loadRelatedFeatures(ctx, songID, hide)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		score := relatedScorers[name]
+		type scored struct {
+			relatedFeatures
+			score float64
+		}
+		picks := []scored{}
+		for _, f := range features {
+			if s := score(f); s > 0 {
+				picks = append(picks, scored{f, s})
+			}
+		}
+		sort.Slice(picks, func(i, j int) bool {
+			if picks[i].score != picks[j].score {
+				return picks[i].score > picks[j].score
+			}
+			return picks[i].SongID > picks[j].SongID
+		})
+		if len(picks) > limit {
+			picks = picks[:limit]
+		}
+
+		ids := make([]int64, len(picks))
+		for i, p := range picks {
+			ids[i] = p.SongID
+		}
+		songs, err := querySongs(ctx, `SELECT `+songColumns+` FROM `+songFrom+` WHERE s.id = ANY ($1);`, ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		byID := map[int64]Song{}
+		for _, s := range songs {
+			byID[s.ID] = s
+		}
+
+		related := make([]RelatedSong, 0, len(picks))
+		for _, p := range picks {
+			if s, ok := byID[p.SongID]; ok {
+				related = append(related, RelatedSong{Song: s, Score: p.score, Reasons: relatedReasons(p.relatedFeatures)})
+			}
+		}
+		c.Header("Cache-Control", "private, max-age=300")
+		c.JSON(http.StatusOK, gin.H{"song_id": songID, "scorer": name, "related": related})
+	})
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Artists keep a schedule of upcoming releases and listening parties, which
+// is also published as an iCalendar feed. Calendar apps can't send a bearer
+// token, so the feed accepts a per-user secret ?token= as well.
+const (
+	calendarTokenPrefix = "lpc_"
+	icsTimeLayout       = "20060102T150405Z"
+
+	// Listening parties get a default length so they show as blocks; releases
+	// are one-hour markers.
+	listeningPartyDuration = 2 * time.Hour
+	releaseEventDuration   = time.Hour
+)
+
+var releaseKinds = map[string]bool{"release": true, "listening_party": true}
+
+type releaseInput struct {
+	SongID   *int64     `json:"song_id"`
+	Kind     *string    `json:"kind"`
+	Title    *string    `json:"title"`
+	Notes    *string    `json:"notes"`
+	StartsAt *time.Time `json:"starts_at"`
+}
+
+const releaseColumns = `id, artist_id, song_id, kind, title, notes, starts_at, created_at, updated_at`
+
+func scanRelease(row pgx.Row) (ScheduledRelease, error) {
+	var r ScheduledRelease
+	err := row.Scan(&r.ID, &r.ArtistID, &r.SongID, &r.Kind, &r.Title, &r.Notes, &r.StartsAt, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+func loadReleases(ctx context.Context, artistID string, since time.Time) ([]ScheduledRelease, error) {
+	rows, err := db.Query(ctx,
+		`SELECT `+releaseColumns+` FROM scheduled_releases WHERE artist_id = $1 AND starts_at >= $2 ORDER BY starts_at;`,
+		artistID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	releases := []ScheduledRelease{}
+	for rows.Next() {
+		r, err := scanRelease(rows)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, r)
+	}
+	return releases, rows.Err()
+}
+
+// icsEscape escapes a TEXT value per RFC 5545.
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// buildReleaseCalendar renders releases as an iCalendar document.
+func buildReleaseCalendar(releases []ScheduledRelease, host string) string {
+	var b strings.Builder
+	line := func(s string) { b.WriteString(s + "\r\n") }
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//Leep//Release Schedule//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:Leep releases")
+
+	now := time.Now().UTC().Format(icsTimeLayout)
+	for _, r := range releases {
+		duration, label := releaseEventDuration, "Release"
+		if r.Kind == "listening_party" {
+			duration, label = listeningPartyDuration, "Listening party"
+		}
+
+		line("BEGIN:VEVENT")
+		line(fmt.Sprintf("UID:release-%d@%s", r.ID, host))
+		line("DTSTAMP:" + now)
+		line("DTSTART:" + r.StartsAt.UTC().Format(icsTimeLayout))
+		line("DTEND:" + r.StartsAt.Add(duration).UTC().Format(icsTimeLayout))
+		line("LAST-MODIFIED:" + r.UpdatedAt.UTC().Format(icsTimeLayout))
+		line("SUMMARY:" + icsEscape(label+": "+r.Title))
+		if r.Notes != "" {
+			line("DESCRIPTION:" + icsEscape(r.Notes))
+		}
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+// calendarFeedUser resolves the caller from ?token= or a bearer token.
+func calendarFeedUser(c *gin.Context) (string, bool) {
+	if token := c.Query("token"); token != "" {
+		var userID string
+		err := db.QueryRow(context.Background(),
+			`SELECT user_id FROM calendar_feed_tokens WHERE token_hash = $1;`, hashToken(token),
+		).Scan(&userID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid calendar token"})
+			return "", false
+		}
+		return userID, true
+	}
+
+	RequireAuth()(c)
+	if c.IsAborted() {
+		return "", false
+	}
+	return currentUserID(c), true
+}
+
+// RegisterReleaseRoutes defines the release schedule and its calendar feed.
+func RegisterReleaseRoutes(r *gin.Engine) {
+	// GET /me/releases.ics — past 30 days and everything upcoming
+	r.GET("/me/releases.ics", func(c *gin.Context) {
+		userID, ok := calendarFeedUser(c)
+		if !ok {
+			return
+		}
+
+		releases, err := loadReleases(context.Background(), userID, time.Now().AddDate(0, 0, -30))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("Content-Disposition", `inline; filename="releases.ics"`)
+		c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(buildReleaseCalendar(releases, c.Request.Host)))
+	})
+
+	me := r.Group("/me/releases", RequireAuth(), RequireTeamScope(scopeReleasesManage))
+
+	// GET /me/releases — upcoming only
+	me.GET("", func(c *gin.Context) {
+		releases, err := loadReleases(context.Background(), currentUserID(c), time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, releases)
+	})
+
+	// POST /me/releases — {"kind":"release","title":"...","starts_at":"2025-06-01T16:00:00Z"}
+	me.POST("", func(c *gin.Context) {
+		var body releaseInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if body.Kind == nil || !releaseKinds[*body.Kind] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be release or listening_party"})
+			return
+		}
+		if body.Title == nil || strings.TrimSpace(*body.Title) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+			return
+		}
+		if body.StartsAt == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "starts_at is required"})
+			return
+		}
+		if body.SongID != nil {
+			owned, err := songOwnedBy(context.Background(), *body.SongID, currentUserID(c))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if !owned {
+				c.JSON(http.StatusForbidden, gin.H{"error": "you can only schedule your own songs"})
+				return
+			}
+		}
+		notes := ""
+		if body.Notes != nil {
+			notes = *body.Notes
+		}
+
+		rel, err := scanRelease(db.QueryRow(context.Background(), `
+			INSERT INTO scheduled_releases (artist_id, song_id, kind, title, notes, starts_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING `+releaseColumns+`;
+		`, currentUserID(c), body.SongID, *body.Kind, strings.TrimSpace(*body.Title), notes, *body.StartsAt))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, rel)
+	})
+
+	// PATCH /me/releases/:id — title, notes, and/or starts_at
+	me.PATCH("/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid release id"})
+			return
+		}
+
+		var body releaseInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if body.Title != nil && strings.TrimSpace(*body.Title) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "title cannot be empty"})
+			return
+		}
+
+		rel, err := scanRelease(db.QueryRow(context.Background(), `
+			UPDATE scheduled_releases SET
+				title      = COALESCE($3, title),
+				notes      = COALESCE($4, notes),
+				starts_at  = COALESCE($5, starts_at),
+				updated_at = now()
+			WHERE id = $1 AND artist_id = $2
+			RETURNING `+releaseColumns+`;
+		`, id, currentUserID(c), body.Title, body.Notes, body.StartsAt))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "release not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, rel)
+	})
+
+	// DELETE /me/releases/:id
+	me.DELETE("/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid release id"})
+			return
+		}
+
+		tag, err := db.Exec(context.Background(),
+			`DELETE FROM scheduled_releases WHERE id = $1 AND artist_id = $2;`, id, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "release not found"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	// POST /me/releases/feed-token — issues (or rotates) the calendar subscription token
+	me.POST("/feed-token", func(c *gin.Context) {
+		token, err := newOpaqueToken(calendarTokenPrefix)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		_, err = db.Exec(context.Background(), `
+			INSERT INTO calendar_feed_tokens (user_id, token_hash)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = now();
+		`, currentUserID(c), hashToken(token))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"token": token, "feed_url": "/me/releases.ics?token=" + token})
+	})
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Fans repost published songs to their profile, where GET /users/:id/reposts
+// lists them for anyone browsing it. Each song carries its repost count,
+// and the artist hears about new reposts.
+const (
+	defaultRepostPage = 20
+	maxRepostPage     = 100
+)
+
+const repostColumns = `id, user_id::text, song_id, created_at`
+
+func scanRepost(row pgx.Row) (Repost, error) {
+	var rp Repost
+	err := row.Scan(&rp.ID, &rp.UserID, &rp.SongID, &rp.CreatedAt)
+	return rp, err
+}
+
+// RegisterRepostRoutes defines reposting and profile repost feeds.
+func RegisterRepostRoutes(r *gin.Engine) {
+	// POST /songs/:id/repost — idempotent
+	r.POST("/songs/:id/repost", RequireAuth(), func(c *gin.Context) {
+		songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+			return
+		}
+		ctx := c.Request.Context()
+		s, err := scanSong(db.QueryRow(ctx, `SELECT `+songColumns+` FROM `+songFrom+` WHERE s.id = $1 AND s.published;`, songID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "song not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if s.ArtistID != nil && *s.ArtistID == currentUserID(c) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot repost your own song"})
+			return
+		}
+		if s.Explicit && abortIfExplicit(c, "this song") {
+			return
+		}
+
+		rp, err := scanRepost(db.QueryRow(ctx, `
+			INSERT INTO reposts (user_id, song_id) VALUES ($1, $2)
+			ON CONFLICT (user_id, song_id) DO NOTHING
+			RETURNING `+repostColumns+`;
+		`, currentUserID(c), songID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Already reposted.
+			rp, err = scanRepost(db.QueryRow(ctx,
+				`SELECT `+repostColumns+` FROM reposts WHERE user_id = $1 AND song_id = $2;`, currentUserID(c), songID))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, rp)
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		recordServerEvent(songID, currentUserID(c), "repost")
+		if s.ArtistID != nil {
+			if err := notify(context.Background(), *s.ArtistID, "repost", "Your song was reposted",
+				"Someone reposted \""+s.Title+"\" to their profile.",
+				gin.H{"song_id": songID, "user_id": currentUserID(c)}); err != nil {
+				log.Printf("repost %d: failed to notify artist: %v", rp.ID, err)
+			}
+		}
+		c.JSON(http.StatusOK, rp)
+	})
+
+	// DELETE /songs/:id/repost
+	r.DELETE("/songs/:id/repost", RequireAuth(), func(c *gin.Context) {
+		songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+			return
+		}
+		if _, err := db.Exec(c.Request.Context(),
+			`DELETE FROM reposts WHERE user_id = $1 AND song_id = $2;`, currentUserID(c), songID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	// GET /users/:id/reposts?before_id=&limit= — newest first, with each song
+	r.GET("/users/:id/reposts", OptionalAuth(), func(c *gin.Context) {
+		userID := c.Param("id")
+		if !userIDPattern.MatchString(userID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultRepostPage)))
+		if err != nil || limit < 1 || limit > maxRepostPage {
+			limit = defaultRepostPage
+		}
+		var beforeID *int64
+		if s := c.Query("before_id"); s != "" {
+			id, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before_id"})
+				return
+			}
+			beforeID = &id
+		}
+
+		ctx := c.Request.Context()
+		if userID != currentUserID(c) {
+			privacy, err := loadPrivacy(ctx, userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if privacy.HideLibrary {
+				c.JSON(http.StatusForbidden, gin.H{"error": "this user keeps their reposts private", "code": "activity_private"})
+				return
+			}
+		}
+		rows, err := db.Query(ctx, `
+			SELECT `+repostColumns+` FROM reposts
+			WHERE user_id = $1 AND ($2::bigint IS NULL OR id < $2)
+			ORDER BY id DESC
+			LIMIT $3;
+		`, userID, beforeID, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		page := []Repost{}
+		ids := []int64{}
+		for rows.Next() {
+			rp, err := scanRepost(rows)
+			if err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			page = append(page, rp)
+			ids = append(ids, rp.SongID)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Songs unpublished since the repost drop out, as do explicit ones
+		// for minors and safe mode; next_before_id still moves past them.
+		songs := map[int64]Song{}
+		srows, err := db.Query(ctx, `
+			SELECT `+songColumns+` FROM `+songFrom+`
+			WHERE s.id = ANY ($1) AND s.published AND NOT (s.explicit AND $2);
+		`, ids, hideExplicit(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer srows.Close()
+		for srows.Next() {
+			s, err := scanSong(srows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			songs[s.ID] = s
+		}
+
+		reposts := make([]Repost, 0, len(page))
+		for _, rp := range page {
+			if s, ok := songs[rp.SongID]; ok {
+				rp.Song = &s
+				reposts = append(reposts, rp)
+			}
+		}
+		resp := gin.H{"user_id": userID, "reposts": reposts}
+		if len(page) == limit {
+			resp["next_before_id"] = page[len(page)-1].ID
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// roleCacheTTL bounds how long a role (or birth date) change made outside
+// this API takes to apply to requests; changes made here are broadcast to
+// every instance and apply at once. roleCacheMaxEntries bounds memory: the
+// least recently used entries are evicted first.
+const (
+	roleCacheTTL        = time.Minute
+	roleCacheMaxEntries = 50000
+
+	// roleChangedChannel is the Postgres NOTIFY channel that tells every
+	// instance to drop a user's cached entry; the payload is the user ID.
+	roleChangedChannel = "role_changed"
+)
+
+// assignableRoles are the roles an admin can set with PUT
+// /admin/users/:id/role.
+var assignableRoles = map[string]bool{"admin": true, "curator": true, "label": true, "artist": true, "producer": true, "fan": true}
+
+var userRoles = newRoleCache(roleCacheMaxEntries)
+
+// roleCache is an LRU of looked-up roles and birth dates, each kept for
+// roleCacheTTL, so role- and age-gated routes don't query profiles on every
+// request.
+type roleCache struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]*list.Element
+	order   *list.List // front is most recently used
+}
+
+type roleEntry struct {
+	userID    string
+	role      string
+	birthDate *time.Time
+	region    string
+	safeMode  bool
+	loadedAt  time.Time
+}
+
+func newRoleCache(max int) *roleCache {
+	return &roleCache{max: max, entries: map[string]*list.Element{}, order: list.New()}
+}
+
+func (rc *roleCache) get(userID string) (roleEntry, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	el, ok := rc.entries[userID]
+	if !ok {
+		return roleEntry{}, false
+	}
+	e := el.Value.(roleEntry)
+	if time.Since(e.loadedAt) >= roleCacheTTL {
+		rc.order.Remove(el)
+		delete(rc.entries, userID)
+		return roleEntry{}, false
+	}
+	rc.order.MoveToFront(el)
+	return e, true
+}
+
+// forget drops userID on this instance only; use forgetAccessProfile after
+// changing a profile.
+func (rc *roleCache) forget(userID string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if el, ok := rc.entries[userID]; ok {
+		rc.order.Remove(el)
+		delete(rc.entries, userID)
+	}
+}
+
+func (rc *roleCache) set(userID string, e roleEntry) {
+	e.userID = userID
+	e.loadedAt = time.Now()
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if el, ok := rc.entries[userID]; ok {
+		el.Value = e
+		rc.order.MoveToFront(el)
+		return
+	}
+	rc.entries[userID] = rc.order.PushFront(e)
+	for rc.order.Len() > rc.max {
+		oldest := rc.order.Back()
+		rc.order.Remove(oldest)
+		delete(rc.entries, oldest.Value.(roleEntry).userID)
+	}
+}
+
+// forgetAccessProfile drops userID's cached entry here and, through
+// roleChangedChannel, on every other instance, so a change just made to
+// their profile applies to their next request.
+func forgetAccessProfile(ctx context.Context, userID string) {
+	userRoles.forget(userID)
+	if _, err := db.Exec(ctx, `SELECT pg_notify($1, $2);`, roleChangedChannel, userID); err != nil {
+		log.Printf("role cache: failed to broadcast change for %s: %v", userID, err)
+	}
+}
+
+// StartRoleInvalidation listens for roleChangedChannel and forgets the
+// users named there, reconnecting until ctx is done. Between a dropped
+// connection and the next LISTEN the cache falls back to roleCacheTTL.
+func StartRoleInvalidation(ctx context.Context) {
+	go func() {
+		for ctx.Err() == nil {
+			if err := listenRoleChanges(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("role cache: invalidation listener: %v", err)
+				time.Sleep(5 * time.Second)
+			}
+		}
+	}()
+}
+
+func listenRoleChanges(ctx context.Context) error {
+	conn, err := db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `LISTEN `+roleChangedChannel+`;`); err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), `UNLISTEN `+roleChangedChannel+`;`)
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		userRoles.forget(n.Payload)
+	}
+}
+
+// accessProfile returns the role, birth date, region, and safe mode
+// setting stored on the user's profile, all empty if the user has no
+// profile row yet. Results are cached for roleCacheTTL.
+func accessProfile(ctx context.Context, userID string) (roleEntry, error) {
+	if e, ok := userRoles.get(userID); ok {
+		return e, nil
+	}
+
+	var e roleEntry
+	var role, region *string
+	err := db.QueryRow(ctx, `SELECT role, birth_date, region, safe_mode FROM profiles WHERE id = $1;`, userID).
+		Scan(&role, &e.birthDate, &region, &e.safeMode)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return roleEntry{}, err
+	}
+	if role != nil {
+		e.role = *role
+	}
+	if region != nil {
+		e.region = *region
+	}
+	userRoles.set(userID, e)
+	return e, nil
+}
+
+// userRole returns the role stored on the caller's profile, or "" if the
+// user has no profile row yet.
+func userRole(ctx context.Context, userID string) (string, error) {
+	e, err := accessProfile(ctx, userID)
+	return e.role, err
+}
+
+// callerRole returns the caller's role. With JWT_ROLE_CLAIM set, the
+// user_role claim that Supabase's custom access token hook adds is trusted
+// when present, which saves the lookup but means a role change only applies
+// once the token is refreshed. Impersonated requests always use the profile.
+func callerRole(c *gin.Context) (string, error) {
+	if cfg.JWTRoleClaim && impersonatorID(c) == "" {
+		if claims, ok := c.Get("claims"); ok && claims.(*Claims).UserRole != "" {
+			return claims.(*Claims).UserRole, nil
+		}
+	}
+	return userRole(c.Request.Context(), currentUserID(c))
+}
+
+// RequireRole allows the request only if the caller's role is one of roles.
+// It must run after RequireAuth. The role is stored under "role".
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, err := callerRole(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Set("role", role)
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient role"})
+	}
+}
+
+// RegisterRoleRoutes defines the admin role assignment endpoint.
+func RegisterRoleRoutes(r *gin.Engine) {
+	// PUT /admin/users/:id/role {"role":"artist"} — null clears the role
+	r.PUT("/admin/users/:id/role", RequireAuth(), RequireRole("admin"), RequireMFA(), func(c *gin.Context) {
+		userID := c.Param("id")
+		if !userIDPattern.MatchString(userID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			return
+		}
+		if userID == currentUserID(c) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "you can't change your own role"})
+			return
+		}
+		var body struct {
+			Role *string `json:"role"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if body.Role != nil && !assignableRoles[*body.Role] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role must be admin, curator, label, artist, producer, fan, or null"})
+			return
+		}
+		ctx := c.Request.Context()
+
+		tag, err := db.Exec(ctx, `UPDATE profiles SET role = $2, updated_at = now() WHERE id = $1;`, userID, body.Role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		forgetAccessProfile(ctx, userID)
+
+		role := ""
+		if body.Role != nil {
+			role = *body.Role
+		}
+		log.Printf("role change: admin %s set %s's role to %q", currentUserID(c), userID, role)
+		c.JSON(http.StatusOK, gin.H{"user_id": userID, "role": body.Role})
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// roleRouter serves GET /t behind RequireRole(allowed...), as userID with
+// the role already cached, so no database is needed.
+func roleRouter(userID string, allowed ...string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/t", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	}, RequireRole(allowed...), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("role"))
+	})
+	return r
+}
+
+func TestRequireRole(t *testing.T) {
+	cfg = &Config{}
+	tests := []struct {
+		role    string
+		allowed []string
+		want    int
+	}{
+		{"fan", []string{"admin"}, http.StatusForbidden},
+		{"fan", []string{"fan", "artist"}, http.StatusOK},
+		{"artist", []string{"admin", "curator"}, http.StatusForbidden},
+		{"artist", []string{"artist"}, http.StatusOK},
+		{"producer", []string{"artist"}, http.StatusForbidden},
+		{"producer", []string{"producer"}, http.StatusOK},
+		{"admin", []string{"admin"}, http.StatusOK},
+		{"admin", []string{"label"}, http.StatusForbidden},
+		{"", []string{"fan"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		const userID = "00000000-0000-0000-0000-000000000001"
+		userRoles.set(userID, roleEntry{role: tt.role})
+
+		w := httptest.NewRecorder()
+		roleRouter(userID, tt.allowed...).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/t", nil))
+		if w.Code != tt.want {
+			t.Errorf("role %q, allowed %v: got %d, want %d", tt.role, tt.allowed, w.Code, tt.want)
+		}
+		if w.Code == http.StatusOK && w.Body.String() != tt.role {
+			t.Errorf("role %q: stored role %q", tt.role, w.Body.String())
+		}
+		userRoles.forget(userID)
+	}
+}
+
+func TestRequireRoleClaim(t *testing.T) {
+	cfg = &Config{JWTRoleClaim: true}
+	defer func() { cfg = &Config{} }()
+	const userID = "00000000-0000-0000-0000-000000000002"
+	userRoles.set(userID, roleEntry{role: "fan"})
+	defer userRoles.forget(userID)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/t", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Set("claims", &Claims{UserRole: "producer"})
+		c.Next()
+	}, RequireRole("producer"), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("role"))
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/t", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "producer" {
+		t.Errorf("got %d %q, want the claim's role", w.Code, w.Body.String())
+	}
+}
+
+func TestAssignableRoles(t *testing.T) {
+	for _, role := range []string{"admin", "curator", "label", "artist", "producer", "fan"} {
+		if !assignableRoles[role] {
+			t.Errorf("%q is not assignable", role)
+		}
+	}
+	if assignableRoles["owner"] {
+		t.Error(`"owner" is assignable`)
+	}
+}
+
+func TestRoleCacheExpiry(t *testing.T) {
+	rc := newRoleCache(10)
+	rc.set("u1", roleEntry{role: "artist"})
+	if e, ok := rc.get("u1"); !ok || e.role != "artist" {
+		t.Fatalf("get after set = %+v, %v", e, ok)
+	}
+
+	// Age the entry past the TTL.
+	rc.mu.Lock()
+	el := rc.entries["u1"]
+	e := el.Value.(roleEntry)
+	e.loadedAt = time.Now().Add(-roleCacheTTL)
+	el.Value = e
+	rc.mu.Unlock()
+
+	if _, ok := rc.get("u1"); ok {
+		t.Fatal("expired entry was returned")
+	}
+	if len(rc.entries) != 0 || rc.order.Len() != 0 {
+		t.Errorf("expired entry was kept: %d entries, %d in order", len(rc.entries), rc.order.Len())
+	}
+}
+
+func TestRoleCacheForget(t *testing.T) {
+	rc := newRoleCache(10)
+	rc.set("u1", roleEntry{role: "fan"})
+	rc.set("u2", roleEntry{role: "producer"})
+	rc.forget("u1")
+	if _, ok := rc.get("u1"); ok {
+		t.Error("forgotten entry was returned")
+	}
+	if e, ok := rc.get("u2"); !ok || e.role != "producer" {
+		t.Errorf("other entry = %+v, %v", e, ok)
+	}
+	rc.forget("missing")
+}
+
+func TestRoleCacheEviction(t *testing.T) {
+	rc := newRoleCache(2)
+	rc.set("u1", roleEntry{role: "fan"})
+	rc.set("u2", roleEntry{role: "artist"})
+	rc.get("u1") // u2 is now least recently used
+	rc.set("u3", roleEntry{role: "admin"})
+	if _, ok := rc.get("u2"); ok {
+		t.Error("least recently used entry was kept")
+	}
+	for _, id := range []string{"u1", "u3"} {
+		if _, ok := rc.get(id); !ok {
+			t.Errorf("%s was evicted", id)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Credentials that aren't a user's own session — API keys and OAuth client
+// tokens — carry scopes naming what they may do. RequireAPIKey,
+// RequireAuthOrAPIKey, and AllowClient only authenticate; every route that
+// accepts such a credential pairs them with RequireScope. A user's session
+// passes RequireScope, since it can already do everything the user can.
+const (
+	apiScopeSongsRead     = "songs:read"  // the catalog and activity on it: comments, tips, followers
+	apiScopeSongsWrite    = "songs:write" // editing your songs; implies songs:read
+	apiScopeAnalyticsRead = "analytics:read"
+	apiScopeTipsWrite     = "tips:write"
+)
+
+var apiScopes = map[string]bool{
+	apiScopeSongsRead:     true,
+	apiScopeSongsWrite:    true,
+	apiScopeAnalyticsRead: true,
+	apiScopeTipsWrite:     true,
+}
+
+// legacyAPIScopes maps the scope names keys were created with before the
+// granular model, still accepted when minting a key.
+var legacyAPIScopes = map[string]string{
+	"read":      apiScopeSongsRead,
+	"write":     apiScopeSongsWrite,
+	"analytics": apiScopeAnalyticsRead,
+}
+
+// hasScope reports whether a credential with scopes may act with need.
+func hasScope(scopes []string, need string) bool {
+	for _, s := range scopes {
+		if s == need || (s == apiScopeSongsWrite && need == apiScopeSongsRead) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope rejects requests made with an API key or client token that
+// doesn't carry scope. It must run after the middleware that authenticated
+// the credential, which stores its scopes under "token_scopes".
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, ok := c.Get("token_scopes")
+		if ok && !hasScope(scopes.([]string), scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "credential lacks scope " + scope, "scope": scope})
+			return
+		}
+		c.Next()
+	}
+}
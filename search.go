@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxSearchQueryLen = 100
+	defaultSearchSize = 20
+	maxSearchSize     = 50
+)
+
+type searchClickInput struct {
+	SearchID string `json:"search_id"`
+	SongID   int64  `json:"song_id"`
+	Position int    `json:"position"`
+}
+
+// Patterns scrubbed from logged queries so search logs hold no contact details.
+var (
+	searchEmailRe  = regexp.MustCompile(`\S+@\S+`)
+	searchURLRe    = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+	searchDigitsRe = regexp.MustCompile(`\+?\d[\d\s().-]{6,}\d`)
+	searchSpaceRe  = regexp.MustCompile(`\s+`)
+	likeEscaper    = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+)
+
+// scrubSearchQuery normalizes a query for aggregation and strips anything
+// that looks like an email, URL, or phone/card number.
+func scrubSearchQuery(q string) string {
+	q = strings.ToLower(q)
+	q = searchEmailRe.ReplaceAllString(q, "[email]")
+	q = searchURLRe.ReplaceAllString(q, "[url]")
+	q = searchDigitsRe.ReplaceAllString(q, "[number]")
+	q = strings.TrimSpace(searchSpaceRe.ReplaceAllString(q, " "))
+	if len(q) > maxSearchQueryLen {
+		q = q[:maxSearchQueryLen]
+	}
+	return q
+}
+
+// SearchResult is one song matched by GET /search.
+type SearchResult struct {
+	SongID int64  `json:"song_id"`
+	Title  string `json:"title"`
+}
+
+// searchSongs matches q against published song titles, leaving out trashed
+// songs and, with hideExplicit, explicit ones.
+func searchSongs(ctx context.Context, q string, limit int, hideExplicit bool) ([]SearchResult, error) {
+	rows, err := db.Query(ctx, `
+		SELECT id, title
+		FROM songs
+		WHERE published AND trashed_at IS NULL
+		  AND title ILIKE '%' || $1 || '%' AND NOT (explicit AND $3)
+		ORDER BY title
+		LIMIT $2;
+	`, likeEscaper.Replace(q), limit, hideExplicit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var res SearchResult
+		if err := rows.Scan(&res.SongID, &res.Title); err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
+
+// RegisterSearchRoutes defines song search and its click logging.
+func RegisterSearchRoutes(r *gin.Engine) {
+	// GET /search?q=&limit= — published songs only; results carry a search_id
+	// for click attribution; explicit songs are left out for minors and safe mode
+	r.GET("/search", AllowClient(), RequireScope(apiScopeSongsRead), OptionalAuth(), func(c *gin.Context) {
+		q := strings.TrimSpace(c.Query("q"))
+		if q == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+			return
+		}
+		if len(q) > maxSearchQueryLen {
+			q = q[:maxSearchQueryLen]
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSearchSize)))
+		if err != nil || limit < 1 || limit > maxSearchSize {
+			limit = defaultSearchSize
+		}
+
+		results, err := searchSongs(c.Request.Context(), q, limit, hideExplicit(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Logged without any user identifier; search_id only links clicks.
+		var searchID string
+		err = db.QueryRow(context.Background(), `
+			INSERT INTO search_queries (query, result_count)
+			VALUES ($1, $2)
+			RETURNING search_id;
+		`, scrubSearchQuery(q), len(results)).Scan(&searchID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"search_id": searchID, "results": results})
+	})
+
+	// POST /search/clicks — the client reports which result was opened
+	r.POST("/search/clicks", func(c *gin.Context) {
+		var body searchClickInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if body.SearchID == "" || body.SongID <= 0 || body.Position < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "search_id, song_id, and position are required"})
+			return
+		}
+
+		tag, err := db.Exec(context.Background(), `
+			INSERT INTO search_clicks (search_id, song_id, position)
+			SELECT search_id, $2, $3 FROM search_queries WHERE search_id::text = $1;
+		`, body.SearchID, body.SongID, body.Position)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "search not found"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+
+	admin := r.Group("/admin/analytics", RequireAuth(), RequireRole("admin"), RequireMFA())
+
+	// GET /admin/analytics/search?from=&to=&limit=
+	admin.GET("/search", func(c *gin.Context) {
+		from, to, err := parseDateRange(c, 30, 366)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		until := to.AddDate(0, 0, 1)
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "25"))
+		if err != nil || limit < 1 || limit > 100 {
+			limit = 25
+		}
+
+		type QueryStats struct {
+			Query    string  `json:"query"`
+			Searches int64   `json:"searches"`
+			Clicks   int64   `json:"clicks"`
+			CTR      float64 `json:"ctr"`
+		}
+
+		scanQueryStats := func(sql string) ([]QueryStats, error) {
+			rows, err := db.Query(context.Background(), sql, from, until, limit)
+			if err != nil {
+				return nil, err
+			}
+			defer rows.Close()
+
+			stats := []QueryStats{}
+			for rows.Next() {
+				var s QueryStats
+				var clicked int64
+				if err := rows.Scan(&s.Query, &s.Searches, &clicked, &s.Clicks); err != nil {
+					return nil, err
+				}
+				s.CTR = ratio(clicked, s.Searches)
+				stats = append(stats, s)
+			}
+			return stats, rows.Err()
+		}
+
+		// CTR is the share of searches with at least one click.
+		const perQuery = `
+			WITH clicks AS (
+				SELECT search_id, COUNT(*) AS n FROM search_clicks GROUP BY search_id
+			)
+			SELECT q.query,
+			       COUNT(*) AS searches,
+			       COUNT(k.search_id) AS clicked,
+			       COALESCE(SUM(k.n), 0)::bigint AS clicks
+			FROM search_queries q
+			LEFT JOIN clicks k ON k.search_id = q.search_id
+			WHERE q.created_at >= $1 AND q.created_at < $2 %s
+			GROUP BY q.query
+			ORDER BY searches DESC, q.query
+			LIMIT $3;
+		`
+
+		top, err := scanQueryStats(fmt.Sprintf(perQuery, ""))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		zero, err := scanQueryStats(fmt.Sprintf(perQuery, "AND q.result_count = 0"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var searches, clicked int64
+		err = db.QueryRow(context.Background(), `
+			SELECT COUNT(*),
+			       COUNT(*) FILTER (WHERE EXISTS (
+			           SELECT 1 FROM search_clicks k WHERE k.search_id = q.search_id
+			       ))
+			FROM search_queries q
+			WHERE q.created_at >= $1 AND q.created_at < $2;
+		`, from, until).Scan(&searches, &clicked)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"from":                from.Format(dateLayout),
+			"to":                  to.Format(dateLayout),
+			"total_searches":      searches,
+			"ctr":                 ratio(clicked, searches),
+			"top_queries":         top,
+			"zero_result_queries": zero,
+		})
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// searchTestDB points db at TEST_DATABASE_URL with a single connection, so
+// a temporary songs table shadows the real one for the whole test.
+func searchTestDB(t *testing.T) context.Context {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	poolCfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poolCfg.MaxConns = 1
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := db
+	db = pool
+	t.Cleanup(func() {
+		db = prev
+		pool.Close()
+	})
+
+	if _, err := db.Exec(ctx, `
+		CREATE TEMP TABLE songs (
+			id         BIGINT PRIMARY KEY,
+			title      TEXT NOT NULL,
+			published  BOOLEAN NOT NULL DEFAULT false,
+			explicit   BOOLEAN NOT NULL DEFAULT false,
+			trashed_at TIMESTAMPTZ
+		);
+	`); err != nil {
+		t.Fatal(err)
+	}
+	return ctx
+}
+
+func TestSearchSongsSkipsUnpublishedAndTrashed(t *testing.T) {
+	ctx := searchTestDB(t)
+	if _, err := db.Exec(ctx, `
+		INSERT INTO songs (id, title, published, trashed_at) VALUES
+			(1, 'Night Drive', true, NULL),
+			(2, 'Night Drive (draft)', false, NULL),
+			(3, 'Night Drive (trashed)', false, now()),
+			(4, 'Night Drive (trashed while published)', true, now());
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := searchSongs(ctx, "night", maxSearchSize, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].SongID != 1 {
+		t.Errorf("got %+v, want only the published song", results)
+	}
+}
+
+func TestSearchSongsHidesExplicit(t *testing.T) {
+	ctx := searchTestDB(t)
+	if _, err := db.Exec(ctx, `
+		INSERT INTO songs (id, title, published, explicit, trashed_at) VALUES
+			(1, 'Rain', true, false, NULL),
+			(2, 'Rain (explicit)', true, true, NULL),
+			(3, 'Rain (explicit draft)', false, true, NULL),
+			(4, 'Rain (explicit, trashed)', true, true, now());
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		hideExplicit bool
+		want         []int64
+	}{
+		{false, []int64{1, 2}},
+		{true, []int64{1}},
+	} {
+		results, err := searchSongs(ctx, "rain", maxSearchSize, tt.hideExplicit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []int64{}
+		for _, r := range results {
+			got = append(got, r.SongID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("hideExplicit %v: got %v, want %v", tt.hideExplicit, got, tt.want)
+		}
+	}
+}
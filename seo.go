@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Artists can override the title and description search engines and link
+// previews show for their songs and artist page. The OpenGraph endpoints
+// hand the web app's share-page renderer finished tags: the artist's
+// overrides when set, otherwise ones built from the song or profile.
+const (
+	maxSEOTitle       = 70
+	maxSEODescription = 160
+)
+
+// seoInput is a full replacement of a page's overrides; null or "" clears
+// a field back to the default.
+type seoInput struct {
+	Title       *string `json:"seo_title"`
+	Description *string `json:"seo_description"`
+}
+
+func (in *seoInput) normalize() (err error) {
+	if in.Title, err = normalizeSEOField(in.Title, "seo_title", maxSEOTitle); err != nil {
+		return err
+	}
+	in.Description, err = normalizeSEOField(in.Description, "seo_description", maxSEODescription)
+	return err
+}
+
+// normalizeSEOField collapses pasted whitespace, since previews are one
+// line, and checks the length.
+func normalizeSEOField(v *string, name string, max int) (*string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	s := strings.Join(strings.Fields(*v), " ")
+	if utf8.RuneCountInString(s) > max {
+		return nil, fmt.Errorf("%s must be at most %d characters", name, max)
+	}
+	if s == "" {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+// shareURL is the public web page for path, or "" without SHARE_BASE_URL.
+func shareURL(path string) string {
+	if cfg.ShareBaseURL == "" {
+		return ""
+	}
+	return cfg.ShareBaseURL + path
+}
+
+// songOpenGraph builds the preview tags for a published song.
+func songOpenGraph(ctx context.Context, songID int64) (OpenGraph, error) {
+	s, err := scanSong(db.QueryRow(ctx, `SELECT `+songColumns+` FROM `+songFrom+` WHERE s.id = $1 AND s.published;`, songID))
+	if err != nil {
+		return OpenGraph{}, err
+	}
+	var artist string
+	var seoTitle, seoDescription *string
+	if err := db.QueryRow(ctx, `
+		SELECT COALESCE(p.display_name, ''), s.seo_title, s.seo_description
+		FROM songs s LEFT JOIN profiles p ON p.id = s.artist_id
+		WHERE s.id = $1;
+	`, songID).Scan(&artist, &seoTitle, &seoDescription); err != nil {
+		return OpenGraph{}, err
+	}
+
+	og := OpenGraph{Type: "music.song", Title: s.Title, URL: shareURL(fmt.Sprintf("/songs/%d", songID)), Image: s.ArtworkURL}
+	if artist != "" {
+		og.Title += " by " + artist
+		og.Description = fmt.Sprintf("Listen to %s by %s on Leep.", s.Title, artist)
+	} else {
+		og.Description = fmt.Sprintf("Listen to %s on Leep.", s.Title)
+	}
+	if seoTitle != nil {
+		og.Title = *seoTitle
+	}
+	if seoDescription != nil {
+		og.Description = *seoDescription
+	}
+	return og, nil
+}
+
+// artistOpenGraph builds the preview tags for an artist page.
+func artistOpenGraph(ctx context.Context, handle string) (OpenGraph, error) {
+	p, err := scanProfile(db.QueryRow(ctx, `SELECT `+profileColumns+` FROM profiles WHERE handle = $1;`, handle))
+	if err != nil {
+		return OpenGraph{}, err
+	}
+	var seoTitle, seoDescription *string
+	if err := db.QueryRow(ctx, `SELECT seo_title, seo_description FROM profiles WHERE id::text = $1;`,
+		p.ID).Scan(&seoTitle, &seoDescription); err != nil {
+		return OpenGraph{}, err
+	}
+
+	name := "@" + handle
+	if p.DisplayName != nil && *p.DisplayName != "" {
+		name = *p.DisplayName
+	}
+	og := OpenGraph{Type: "profile", Title: name, URL: shareURL("/artists/" + handle), Image: p.AvatarURL}
+	og.Description = fmt.Sprintf("Listen to %s on Leep.", name)
+	if p.Bio != nil && *p.Bio != "" {
+		og.Description = strings.Join(strings.Fields(*p.Bio), " ")
+		if utf8.RuneCountInString(og.Description) > maxSEODescription {
+			og.Description = string([]rune(og.Description)[:maxSEODescription-1]) + "…"
+		}
+	}
+	if seoTitle != nil {
+		og.Title = *seoTitle
+	}
+	if seoDescription != nil {
+		og.Description = *seoDescription
+	}
+	return og, nil
+}
+
+// RegisterSEORoutes defines SEO overrides and the OpenGraph tags built from
+// them.
+func RegisterSEORoutes(r *gin.Engine) {
+	// PUT /songs/:id/seo {"seo_title","seo_description"}
+	r.PUT("/songs/:id/seo", RequireAuthOrAPIKey(), RequireScope(apiScopeSongsWrite), func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "edit the SEO metadata of")
+		if !ok {
+			return
+		}
+		var body seoInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if err := body.normalize(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := db.Exec(c.Request.Context(),
+			`UPDATE songs SET seo_title = $2, seo_description = $3 WHERE id = $1;`,
+			songID, body.Title, body.Description); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"song_id": songID, "seo_title": body.Title, "seo_description": body.Description})
+	})
+
+	// PUT /auth/me/seo {"seo_title","seo_description"} — the caller's artist page
+	r.PUT("/auth/me/seo", RequireAuth(), func(c *gin.Context) {
+		var body seoInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if err := body.normalize(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		tag, err := db.Exec(c.Request.Context(),
+			`UPDATE profiles SET seo_title = $2, seo_description = $3, updated_at = now() WHERE id = $1;`,
+			currentUserID(c), body.Title, body.Description)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "profile not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"seo_title": body.Title, "seo_description": body.Description})
+	})
+
+	// GET /og/songs/:id — preview tags for a published song's share page
+	r.GET("/og/songs/:id", func(c *gin.Context) {
+		songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+			return
+		}
+		og, err := songOpenGraph(c.Request.Context(), songID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "song not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, og)
+	})
+
+	// GET /og/artists/:handle — preview tags for an artist page
+	r.GET("/og/artists/:handle", func(c *gin.Context) {
+		handle := strings.ToLower(strings.TrimPrefix(c.Param("handle"), "@"))
+		if !handlePattern.MatchString(handle) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "artist not found"})
+			return
+		}
+		og, err := artistOpenGraph(c.Request.Context(), handle)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "artist not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, og)
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Sessions are Supabase Auth's auth.sessions rows, one per signed-in
+// device. Revoking one deletes it, which also deletes its refresh tokens,
+// so that device can't refresh again; its current access token lapses at
+// expiry, and any MFA token issued to it stops working immediately.
+
+var sessionIDPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// RegisterSessionRoutes defines /auth/sessions.
+func RegisterSessionRoutes(r *gin.Engine) {
+	s := r.Group("/auth/sessions", RequireAuth())
+
+	// GET /auth/sessions — the caller's active sessions, most recently used first
+	s.GET("", func(c *gin.Context) {
+		claims := c.MustGet("claims").(*Claims)
+		rows, err := db.Query(context.Background(), `
+			SELECT id::text, user_agent, host(ip), aal::text, created_at, COALESCE(refreshed_at, updated_at, created_at)
+			FROM auth.sessions
+			WHERE user_id = $1 AND (not_after IS NULL OR not_after > now())
+			ORDER BY 6 DESC;
+		`, claims.Subject)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		sessions := []AuthSession{}
+		for rows.Next() {
+			var as AuthSession
+			if err := rows.Scan(&as.ID, &as.UserAgent, &as.IP, &as.AAL, &as.CreatedAt, &as.LastUsedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			as.Current = as.ID == claims.SessionID
+			sessions = append(sessions, as)
+		}
+		c.JSON(http.StatusOK, sessions)
+	})
+
+	// DELETE /auth/sessions/:id — signs that device out
+	s.DELETE("/:id", func(c *gin.Context) {
+		id := strings.ToLower(c.Param("id"))
+		if !sessionIDPattern.MatchString(id) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session id"})
+			return
+		}
+		ctx := context.Background()
+
+		tag, err := db.Exec(ctx,
+			`DELETE FROM auth.sessions WHERE id = $1::uuid AND user_id = $2;`, id, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+		if _, err := db.Exec(ctx,
+			`DELETE FROM mfa_sessions WHERE user_id = $1 AND session_id = $2;`, currentUserID(c), id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
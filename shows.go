@@ -0,0 +1,610 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Shows are episodic audio (podcasts, behind-the-scenes series) hosted
+// alongside music. An episode is an ordinary song attached to a show with a
+// season, number, and type, so uploads, processing, and playback work as
+// for any song. Each show has a public RSS feed that podcast apps
+// subscribe to; its enclosures point at a redirect here that counts the
+// download before handing out a short-lived storage URL. Downloads are
+// counted once per listener (IP and user agent) per episode per UTC day,
+// and range requests after the first byte don't count, as podcast apps
+// fetch one file in many pieces.
+const (
+	maxShowTitleLength       = 200
+	maxShowDescriptionLength = 4000
+	episodeURLExpiry         = time.Hour
+	showFeedMaxEpisodes      = 300
+)
+
+var showTypes = map[string]bool{"episodic": true, "serial": true}
+
+var episodeTypes = map[string]bool{"full": true, "trailer": true, "bonus": true}
+
+type showInput struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	Author      *string `json:"author"`
+	Language    *string `json:"language"`
+	Category    *string `json:"category"`
+	ImageURL    *string `json:"image_url"`
+	Explicit    *bool   `json:"explicit"`
+	ShowType    *string `json:"show_type"`
+}
+
+// validate checks the fields that are set; create additionally requires a
+// title.
+func (in *showInput) validate() error {
+	for _, f := range []*string{in.Title, in.Description, in.Author, in.Category, in.ImageURL} {
+		if f != nil {
+			*f = strings.TrimSpace(*f)
+		}
+	}
+	if in.Title != nil && (*in.Title == "" || utf8.RuneCountInString(*in.Title) > maxShowTitleLength) {
+		return fmt.Errorf("title must be 1-%d characters", maxShowTitleLength)
+	}
+	if in.Description != nil && utf8.RuneCountInString(*in.Description) > maxShowDescriptionLength {
+		return fmt.Errorf("description must be at most %d characters", maxShowDescriptionLength)
+	}
+	if in.Language != nil && !languagePattern.MatchString(*in.Language) {
+		return fmt.Errorf("language must be a language tag such as en or pt-BR")
+	}
+	if in.ImageURL != nil && *in.ImageURL != "" {
+		if u, err := url.Parse(*in.ImageURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("image_url must be an https URL")
+		}
+	}
+	if in.ShowType != nil && !showTypes[*in.ShowType] {
+		return fmt.Errorf("show_type must be episodic or serial")
+	}
+	return nil
+}
+
+const showColumns = `id, owner_id::text, title, description, author, language, category, image_url, explicit, show_type,
+	created_at, updated_at`
+
+func scanShow(row pgx.Row) (Show, error) {
+	var s Show
+	err := row.Scan(&s.ID, &s.OwnerID, &s.Title, &s.Description, &s.Author, &s.Language, &s.Category, &s.ImageURL,
+		&s.Explicit, &s.ShowType, &s.CreatedAt, &s.UpdatedAt)
+	return s, err
+}
+
+// loadShow returns a show, writing the 404 or 500 when it can't.
+func loadShow(c *gin.Context) (Show, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid show id"})
+		return Show{}, false
+	}
+	s, err := scanShow(db.QueryRow(context.Background(), `SELECT `+showColumns+` FROM shows WHERE id = $1;`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "show not found"})
+		return Show{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return Show{}, false
+	}
+	return s, true
+}
+
+// ownedShow is loadShow for the show's owner only.
+func ownedShow(c *gin.Context) (Show, bool) {
+	s, ok := loadShow(c)
+	if ok && s.OwnerID != currentUserID(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only manage your own shows"})
+		return Show{}, false
+	}
+	return s, ok
+}
+
+// episodeOrder is listening order: season, then number, then release.
+const episodeOrder = `s.season_number NULLS FIRST, s.episode_number NULLS LAST, s.release_date NULLS LAST, s.id`
+
+// showEpisodes lists a show's episodes in listening order, or newest first
+// for feeds, with drafts only when withDrafts is set.
+func showEpisodes(ctx context.Context, showID int64, withDrafts, newestFirst bool) ([]Song, error) {
+	order := episodeOrder
+	if newestFirst {
+		order = `s.season_number DESC NULLS LAST, s.episode_number DESC NULLS FIRST, s.release_date DESC NULLS FIRST, s.id DESC`
+	}
+	rows, err := db.Query(ctx, `
+		SELECT `+songColumns+` FROM `+songFrom+`
+		WHERE s.show_id = $1 AND (s.published OR $2)
+		ORDER BY `+order+`
+		LIMIT $3;
+	`, showID, withDrafts, showFeedMaxEpisodes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	episodes := []Song{}
+	for rows.Next() {
+		s, err := scanSong(rows)
+		if err != nil {
+			return nil, err
+		}
+		episodes = append(episodes, s)
+	}
+	return episodes, rows.Err()
+}
+
+// requestOrigin is the scheme and host the client reached us on, for the
+// absolute URLs feeds need.
+func requestOrigin(c *gin.Context) string {
+	scheme := "https"
+	if c.Request.TLS == nil && c.GetHeader("X-Forwarded-Proto") == "http" {
+		scheme = "http"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// The RSS 2.0 feed, with the iTunes podcast tags Apple and most apps read.
+type rssFeed struct {
+	XMLName  xml.Name   `xml:"rss"`
+	Version  string     `xml:"version,attr"`
+	XMLNSIt  string     `xml:"xmlns:itunes,attr"`
+	XMLNSAtm string     `xml:"xmlns:atom,attr"`
+	Channel  rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	SelfLink    rssAtom   `xml:"atom:link"`
+	Description string    `xml:"description"`
+	Language    string    `xml:"language"`
+	Author      string    `xml:"itunes:author,omitempty"`
+	Image       *rssImage `xml:"itunes:image,omitempty"`
+	Category    *rssCat   `xml:"itunes:category,omitempty"`
+	Explicit    string    `xml:"itunes:explicit"`
+	Type        string    `xml:"itunes:type"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssAtom struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type rssCat struct {
+	Text string `xml:"text,attr"`
+}
+
+type rssItem struct {
+	Title       string       `xml:"title"`
+	Description string       `xml:"description,omitempty"`
+	GUID        rssGUID      `xml:"guid"`
+	PubDate     string       `xml:"pubDate"`
+	Enclosure   rssEnclosure `xml:"enclosure"`
+	Duration    string       `xml:"itunes:duration,omitempty"`
+	Season      *int         `xml:"itunes:season,omitempty"`
+	Episode     *int         `xml:"itunes:episode,omitempty"`
+	EpisodeType string       `xml:"itunes:episodeType"`
+	Explicit    string       `xml:"itunes:explicit"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// episodeFeedRow is what a feed item needs beyond the song payload.
+type episodeFeedRow struct {
+	Description *string
+	PublishedAt time.Time
+	Size        int64
+	ContentType string
+	DurationMs  *int64
+}
+
+// buildShowFeed renders the RSS feed for a show's published episodes that
+// have audio, newest first.
+func buildShowFeed(ctx context.Context, show Show, origin string) ([]byte, error) {
+	episodes, err := showEpisodes(ctx, show.ID, false, true)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := rssChannel{
+		Title:       show.Title,
+		Link:        origin + "/shows/" + strconv.FormatInt(show.ID, 10),
+		SelfLink:    rssAtom{Href: origin + "/shows/" + strconv.FormatInt(show.ID, 10) + "/feed.xml", Rel: "self", Type: "application/rss+xml"},
+		Description: show.Title,
+		Language:    show.Language,
+		Explicit:    strconv.FormatBool(show.Explicit),
+		Type:        show.ShowType,
+		Items:       []rssItem{},
+	}
+	if show.Description != nil {
+		ch.Description = *show.Description
+	}
+	if show.Author != nil {
+		ch.Author = *show.Author
+	}
+	if show.ImageURL != nil {
+		ch.Image = &rssImage{Href: *show.ImageURL}
+	}
+	if show.Category != nil && *show.Category != "" {
+		ch.Category = &rssCat{Text: *show.Category}
+	}
+
+	for _, e := range episodes {
+		var row episodeFeedRow
+		err := db.QueryRow(ctx, `
+			SELECT s.episode_description, COALESCE(s.release_date, s.episode_added_at), r.size_bytes, r.content_type, r.duration_ms
+			FROM songs s
+			JOIN song_renditions r ON r.song_id = s.id AND r.name = $2
+			WHERE s.id = $1;
+		`, e.ID, originalRendition).Scan(&row.Description, &row.PublishedAt, &row.Size, &row.ContentType, &row.DurationMs)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		item := rssItem{
+			Title:       e.Title,
+			GUID:        rssGUID{Value: fmt.Sprintf("leep-episode-%d", e.ID)},
+			PubDate:     row.PublishedAt.UTC().Format(time.RFC1123Z),
+			Enclosure:   rssEnclosure{URL: fmt.Sprintf("%s/shows/%d/episodes/%d/audio", origin, show.ID, e.ID), Length: row.Size, Type: row.ContentType},
+			EpisodeType: e.Episode.Type,
+			Season:      e.Episode.Season,
+			Episode:     e.Episode.Number,
+			Explicit:    strconv.FormatBool(e.Explicit || show.Explicit),
+		}
+		if row.Description != nil {
+			item.Description = *row.Description
+		}
+		if row.DurationMs != nil {
+			item.Duration = strconv.FormatInt(*row.DurationMs/1000, 10)
+		} else if e.DurationSeconds != nil {
+			item.Duration = strconv.Itoa(*e.DurationSeconds)
+		}
+		ch.Items = append(ch.Items, item)
+	}
+
+	out, err := xml.MarshalIndent(rssFeed{
+		Version:  "2.0",
+		XMLNSIt:  "http://www.itunes.com/dtds/podcast-1.0.dtd",
+		XMLNSAtm: "http://www.w3.org/2005/Atom",
+		Channel:  ch,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// recordEpisodeDownload counts a download unless the request is a bot or a
+// later piece of a ranged download. Repeats from the same listener the same
+// day are absorbed by the table's unique index.
+func recordEpisodeDownload(c *gin.Context, showID, songID int64) {
+	if r := c.GetHeader("Range"); r != "" && !strings.HasPrefix(r, "bytes=0-") {
+		return
+	}
+	if classifyBot(context.Background(), c, 1).Action != "" {
+		return
+	}
+	if _, err := db.Exec(context.Background(), `
+		INSERT INTO episode_downloads (show_id, song_id, day, listener_hash, user_agent)
+		VALUES ($1, $2, (now() AT TIME ZONE 'UTC')::date, $3, $4)
+		ON CONFLICT DO NOTHING;
+	`, showID, songID, hashToken(c.ClientIP()+"|"+c.Request.UserAgent()), c.Request.UserAgent()); err != nil {
+		log.Printf("episode %d: failed to record download: %v", songID, err)
+	}
+}
+
+// RegisterShowRoutes defines shows, their episodes, the RSS feed, and
+// per-episode analytics.
+func RegisterShowRoutes(r *gin.Engine) {
+	// POST /shows {"title","description","author","language","category","image_url","explicit","show_type"}
+	r.POST("/shows", RequireAuth(), func(c *gin.Context) {
+		var in showInput
+		if err := c.BindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if in.Title == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+			return
+		}
+		if err := in.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		s, err := scanShow(db.QueryRow(context.Background(), `
+			INSERT INTO shows (owner_id, title, description, author, language, category, image_url, explicit, show_type)
+			VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), COALESCE($5, 'en'), NULLIF($6, ''), NULLIF($7, ''),
+			        COALESCE($8, false), COALESCE($9, 'episodic'))
+			RETURNING `+showColumns+`;
+		`, currentUserID(c), *in.Title, in.Description, in.Author, in.Language, in.Category, in.ImageURL, in.Explicit, in.ShowType))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, s)
+	})
+
+	// PATCH /shows/:id — any of the POST fields; "" clears optional text
+	r.PATCH("/shows/:id", RequireAuth(), func(c *gin.Context) {
+		s, ok := ownedShow(c)
+		if !ok {
+			return
+		}
+		var in showInput
+		if err := c.BindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if err := in.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		s, err := scanShow(db.QueryRow(context.Background(), `
+			UPDATE shows SET
+				title       = COALESCE($2, title),
+				description = CASE WHEN $3::text IS NULL THEN description ELSE NULLIF($3, '') END,
+				author      = CASE WHEN $4::text IS NULL THEN author ELSE NULLIF($4, '') END,
+				language    = COALESCE($5, language),
+				category    = CASE WHEN $6::text IS NULL THEN category ELSE NULLIF($6, '') END,
+				image_url   = CASE WHEN $7::text IS NULL THEN image_url ELSE NULLIF($7, '') END,
+				explicit    = COALESCE($8, explicit),
+				show_type   = COALESCE($9, show_type),
+				updated_at  = now()
+			WHERE id = $1
+			RETURNING `+showColumns+`;
+		`, s.ID, in.Title, in.Description, in.Author, in.Language, in.Category, in.ImageURL, in.Explicit, in.ShowType))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, s)
+	})
+
+	// GET /shows/:id — the show and its episodes in order; the owner also sees drafts
+	r.GET("/shows/:id", OptionalAuth(), func(c *gin.Context) {
+		s, ok := loadShow(c)
+		if !ok {
+			return
+		}
+		episodes, err := showEpisodes(context.Background(), s.ID, s.OwnerID == currentUserID(c), false)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if hideExplicit(c) {
+			kept := episodes[:0]
+			for _, e := range episodes {
+				if !e.Explicit {
+					kept = append(kept, e)
+				}
+			}
+			episodes = kept
+		}
+		s.Episodes = episodes
+		c.JSON(http.StatusOK, s)
+	})
+
+	// PUT /shows/:id/episodes/:songId {"season":1,"episode":3,"episode_type":"full","description":"..."}
+	r.PUT("/shows/:id/episodes/:songId", RequireAuth(), func(c *gin.Context) {
+		s, ok := ownedShow(c)
+		if !ok {
+			return
+		}
+		songID, err := strconv.ParseInt(c.Param("songId"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+			return
+		}
+		var body struct {
+			Season      *int    `json:"season"`
+			Episode     *int    `json:"episode"`
+			EpisodeType string  `json:"episode_type"`
+			Description *string `json:"description"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if body.EpisodeType == "" {
+			body.EpisodeType = "full"
+		}
+		if !episodeTypes[body.EpisodeType] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "episode_type must be full, trailer, or bonus"})
+			return
+		}
+		if (body.Season != nil && *body.Season < 1) || (body.Episode != nil && *body.Episode < 1) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "season and episode must be positive"})
+			return
+		}
+		if body.Description != nil && utf8.RuneCountInString(*body.Description) > maxShowDescriptionLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "description is too long", "max_length": maxShowDescriptionLength})
+			return
+		}
+		ctx := context.Background()
+
+		tag, err := db.Exec(ctx, `
+			UPDATE songs SET
+				show_id = $2, season_number = $3, episode_number = $4, episode_type = $5,
+				episode_description = NULLIF($6, ''),
+				episode_added_at = CASE WHEN show_id = $2 THEN episode_added_at ELSE now() END
+			WHERE id = $1 AND artist_id = $7;
+		`, songID, s.ID, body.Season, body.Episode, body.EpisodeType, body.Description, currentUserID(c))
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				c.JSON(http.StatusConflict, gin.H{"error": "another episode already has that season and number"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you can only add your own songs as episodes"})
+			return
+		}
+
+		song, err := loadSong(ctx, songID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, song)
+	})
+
+	// DELETE /shows/:id/episodes/:songId — detaches the episode; the song stays
+	r.DELETE("/shows/:id/episodes/:songId", RequireAuth(), func(c *gin.Context) {
+		s, ok := ownedShow(c)
+		if !ok {
+			return
+		}
+		songID, err := strconv.ParseInt(c.Param("songId"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+			return
+		}
+		tag, err := db.Exec(context.Background(), `
+			UPDATE songs SET show_id = NULL, season_number = NULL, episode_number = NULL, episode_type = NULL,
+				episode_description = NULL, episode_added_at = NULL
+			WHERE id = $1 AND show_id = $2;
+		`, songID, s.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "episode not found"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	// GET /shows/:id/feed.xml — RSS for podcast apps
+	r.GET("/shows/:id/feed.xml", func(c *gin.Context) {
+		s, ok := loadShow(c)
+		if !ok {
+			return
+		}
+		feed, err := buildShowFeed(c.Request.Context(), s, requestOrigin(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Cache-Control", "public, max-age=300")
+		c.Data(http.StatusOK, "application/rss+xml; charset=utf-8", feed)
+	})
+
+	// GET /shows/:id/episodes/:songId/audio — the feed's enclosure; counts the download and redirects to the file
+	r.GET("/shows/:id/episodes/:songId/audio", func(c *gin.Context) {
+		showID, err1 := strconv.ParseInt(c.Param("id"), 10, 64)
+		songID, err2 := strconv.ParseInt(c.Param("songId"), 10, 64)
+		if err1 != nil || err2 != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "episode not found"})
+			return
+		}
+		rend, err := scanRendition(db.QueryRow(context.Background(), `
+			SELECT `+renditionColumns+` FROM song_renditions
+			WHERE name = $3 AND song_id = (SELECT id FROM songs WHERE id = $1 AND show_id = $2 AND published);
+		`, songID, showID, originalRendition))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "episode not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		recordEpisodeDownload(c, showID, songID)
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, storageFor(rend.StorageRegion).PresignGet(rend.StorageKey, episodeURLExpiry))
+	})
+
+	// GET /shows/:id/analytics?from=&to= — per-episode downloads, plays, and completion for the owner
+	r.GET("/shows/:id/analytics", RequireAuth(), func(c *gin.Context) {
+		s, ok := ownedShow(c)
+		if !ok {
+			return
+		}
+		from, to, err := parseDateRange(c, 30, 366)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		end := to.AddDate(0, 0, 1)
+
+		rows, err := db.Query(context.Background(), `
+			SELECT s.id, s.title, s.season_number, s.episode_number,
+			       (SELECT COUNT(*) FROM episode_downloads d
+			        WHERE d.song_id = s.id AND d.day >= $2::date AND d.day < $3::date),
+			       COUNT(e.*) FILTER (WHERE e.event_type = 'play'),
+			       COUNT(e.*) FILTER (WHERE e.event_type = 'play_progress' AND e.properties->>'percent' = '100')
+			FROM songs s
+			LEFT JOIN events e ON e.song_id = s.id AND NOT e.is_bot AND e.occurred_at >= $2 AND e.occurred_at < $3
+			WHERE s.show_id = $1
+			GROUP BY s.id
+			ORDER BY `+episodeOrder+`;
+		`, s.ID, from, end)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		episodes := []EpisodeAnalytics{}
+		for rows.Next() {
+			var a EpisodeAnalytics
+			var completions int64
+			if err := rows.Scan(&a.SongID, &a.Title, &a.Season, &a.Episode, &a.Downloads, &a.Plays, &completions); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			a.CompletionRate = ratio(completions, a.Plays)
+			episodes = append(episodes, a)
+		}
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"show_id":  s.ID,
+			"from":     from.Format(dateLayout),
+			"to":       to.Format(dateLayout),
+			"episodes": episodes,
+		})
+	})
+}
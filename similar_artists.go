@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// The "fans also listen to" module reads a precomputed artist similarity
+// graph. A periodic job rebuilds it from the last 90 days of human plays:
+// two artists are similar when the same listeners play both (cosine
+// similarity over each artist's audience), boosted when their songs share
+// genres. Each listener counts toward at most their top artists, so heavy
+// listeners don't dominate and the pair count stays bounded. Pairs need a
+// few shared listeners before they count, which also keeps a single
+// listener's history from being inferable from the graph.
+const (
+	similarArtistsName     = "similar_artists"
+	similarArtistsInterval = 6 * time.Hour
+
+	similarityWindowDays       = 90
+	similarityArtistsPerFan    = 50
+	similarityMinSharedFans    = 3
+	similarityListenWeight     = 0.8
+	similarityNeighborsPerNode = 50
+	defaultSimilarArtists      = 10
+	maxSimilarArtists          = 50
+)
+
+// rebuildArtistSimilarity replaces the graph in one transaction so readers
+// always see a complete one.
+func rebuildArtistSimilarity(ctx context.Context) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM artist_similarity;`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		WITH plays AS (
+			SELECT COALESCE(e.user_id::text, e.properties->>'device_id') AS listener, s.artist_id, COUNT(*) AS n
+			FROM events e
+			JOIN songs s ON s.id = e.song_id
+			WHERE e.event_type = 'play' AND NOT e.is_bot
+			  AND e.occurred_at > now() - make_interval(days => $1)
+			  AND s.published AND s.artist_id IS NOT NULL
+			  AND NOT EXISTS (SELECT 1 FROM profiles p WHERE p.id = e.user_id AND p.hide_listening_history)
+			GROUP BY 1, 2
+		), fans AS (
+			SELECT listener, artist_id FROM (
+				SELECT listener, artist_id, row_number() OVER (PARTITION BY listener ORDER BY n DESC, artist_id) AS rn
+				FROM plays WHERE listener IS NOT NULL
+			) ranked
+			WHERE rn <= $2
+		), audience AS (
+			SELECT artist_id, COUNT(*) AS n FROM fans GROUP BY artist_id
+		), pairs AS (
+			SELECT a.artist_id AS a, b.artist_id AS b, COUNT(*) AS shared
+			FROM fans a JOIN fans b ON b.listener = a.listener AND b.artist_id <> a.artist_id
+			GROUP BY 1, 2
+			HAVING COUNT(*) >= $3
+		), genres AS (
+			SELECT DISTINCT artist_id, genre FROM songs
+			WHERE published AND artist_id IS NOT NULL AND genre IS NOT NULL
+		), genre_counts AS (
+			SELECT artist_id, COUNT(*) AS n FROM genres GROUP BY artist_id
+		), scored AS (
+			SELECT p.a, p.b, p.shared, g.shared_genres,
+			       $4::float8 * p.shared / sqrt(aa.n * ab.n)
+			       + (1 - $4::float8) * COALESCE(cardinality(g.shared_genres) / sqrt(ga.n * gb.n), 0) AS score
+			FROM pairs p
+			JOIN audience aa ON aa.artist_id = p.a
+			JOIN audience ab ON ab.artist_id = p.b
+			LEFT JOIN genre_counts ga ON ga.artist_id = p.a
+			LEFT JOIN genre_counts gb ON gb.artist_id = p.b
+			CROSS JOIN LATERAL (
+				SELECT COALESCE(array_agg(x.genre ORDER BY x.genre), '{}') AS shared_genres
+				FROM genres x JOIN genres y ON y.genre = x.genre AND y.artist_id = p.b
+				WHERE x.artist_id = p.a
+			) g
+		)
+		INSERT INTO artist_similarity (artist_id, similar_artist_id, score, shared_listeners, shared_genres, computed_at)
+		SELECT a, b, score, shared, shared_genres, now() FROM (
+			SELECT *, row_number() OVER (PARTITION BY a ORDER BY score DESC, shared DESC, b) AS rn FROM scored
+		) ranked
+		WHERE rn <= $5;
+	`, similarityWindowDays, similarityArtistsPerFan, similarityMinSharedFans, similarityListenWeight,
+		similarityNeighborsPerNode); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// resolveArtistID accepts an artist's user ID or handle.
+func resolveArtistID(ctx context.Context, idOrHandle string) (string, error) {
+	if userIDPattern.MatchString(idOrHandle) {
+		return idOrHandle, nil
+	}
+	handle := strings.ToLower(strings.TrimPrefix(idOrHandle, "@"))
+	if !handlePattern.MatchString(handle) {
+		return "", pgx.ErrNoRows
+	}
+	var id string
+	err := db.QueryRow(ctx, `SELECT id::text FROM profiles WHERE handle = $1;`, handle).Scan(&id)
+	return id, err
+}
+
+// RegisterSimilarArtistRoutes defines the "fans also listen to" endpoint.
+func RegisterSimilarArtistRoutes(r *gin.Engine) {
+	// GET /artists/:handle/similar?limit= — :handle may also be the artist's user ID; most similar first
+	r.GET("/artists/:handle/similar", AllowClient(), RequireScope(apiScopeSongsRead), func(c *gin.Context) {
+		ctx := c.Request.Context()
+		artistID, err := resolveArtistID(ctx, c.Param("handle"))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "artist not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSimilarArtists)))
+		if err != nil || limit < 1 || limit > maxSimilarArtists {
+			limit = defaultSimilarArtists
+		}
+
+		rows, err := db.Query(ctx, `
+			SELECT similar_artist_id::text, score, shared_listeners, shared_genres, computed_at
+			FROM artist_similarity
+			WHERE artist_id = $1
+			ORDER BY score DESC, shared_listeners DESC
+			LIMIT $2;
+		`, artistID, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		similar := []SimilarArtist{}
+		ids := []string{}
+		for rows.Next() {
+			var s SimilarArtist
+			if err := rows.Scan(&s.ArtistID, &s.Score, &s.SharedListeners, &s.SharedGenres, &s.ComputedAt); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			similar = append(similar, s)
+			ids = append(ids, s.ArtistID)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		profiles := map[string]Profile{}
+		prows, err := db.Query(ctx, `SELECT `+profileColumns+` FROM profiles WHERE id::text = ANY ($1);`, ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer prows.Close()
+		for prows.Next() {
+			p, err := scanProfile(prows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			profiles[p.ID] = p
+		}
+
+		out := make([]SimilarArtist, 0, len(similar))
+		for _, s := range similar {
+			if p, ok := profiles[s.ArtistID]; ok {
+				s.Profile = &p
+				out = append(out, s)
+			}
+		}
+		c.Header("Cache-Control", "public, max-age=3600")
+		c.JSON(http.StatusOK, gin.H{"artist_id": artistID, "similar": out})
+	})
+}
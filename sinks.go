@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventSink receives validated engagement events.
+type EventSink interface {
+	Publish(ctx context.Context, e IngestEvent) error
+}
+
+// eventSink is where every engagement event goes. Postgres is always the
+// system of record; InitEventSink may tee events to a streaming sink.
+var eventSink EventSink = postgresSink{}
+
+// InitEventSink adds the streaming sink selected by EVENT_STREAM
+// ("nats", "kafka", or empty for Postgres only).
+func InitEventSink() error {
+	var stream EventSink
+	switch cfg.EventStream {
+	case "":
+		return nil
+	case "nats":
+		stream = &natsSink{addr: cfg.NATSAddr, subject: cfg.EventStreamTopic}
+	case "kafka":
+		stream = &kafkaRESTSink{
+			proxyURL: strings.TrimRight(cfg.KafkaRESTURL, "/"),
+			topic:    cfg.EventStreamTopic,
+			http:     &http.Client{Timeout: 5 * time.Second},
+		}
+	default:
+		return fmt.Errorf("unknown EVENT_STREAM %q, events go to Postgres only", cfg.EventStream)
+	}
+
+	eventSink = teeSink{primary: postgresSink{}, stream: stream}
+	log.Printf("✅ Streaming engagement events to %s (%s)", cfg.EventStream, cfg.EventStreamTopic)
+	return nil
+}
+
+// postgresSink inserts into the events table.
+type postgresSink struct{}
+
+func (postgresSink) Publish(ctx context.Context, e IngestEvent) error {
+	return recordEvent(ctx, e)
+}
+
+// teeSink writes to primary and then, best-effort, to stream. A stream
+// outage is logged but never fails the request; Postgres stays authoritative.
+type teeSink struct {
+	primary EventSink
+	stream  EventSink
+}
+
+func (t teeSink) Publish(ctx context.Context, e IngestEvent) error {
+	if err := t.primary.Publish(ctx, e); err != nil {
+		return err
+	}
+	if err := t.stream.Publish(ctx, e); err != nil {
+		log.Printf("event stream: publish failed: %v", err)
+	}
+	return nil
+}
+
+// natsSink publishes JSON events using the NATS text protocol over a single
+// lazily-dialed connection, reconnecting on the next publish after an error.
+type natsSink struct {
+	addr    string
+	subject string
+
+	mu   sync.Mutex
+	conn net.Conn
+	w    *bufio.Writer
+}
+
+func (n *natsSink) Publish(ctx context.Context, e IngestEvent) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.conn == nil {
+		if err := n.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	n.conn.SetWriteDeadline(time.Now().Add(2 * time.Second))
+	fmt.Fprintf(n.w, "PUB %s %d\r\n", n.subject, len(payload))
+	n.w.Write(payload)
+	n.w.WriteString("\r\n")
+	if err := n.w.Flush(); err != nil {
+		n.conn.Close()
+		n.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (n *natsSink) connect(ctx context.Context) error {
+	u, err := url.Parse(n.addr)
+	if err != nil {
+		return err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return err
+	}
+
+	// The server greets with INFO; we don't need anything from it.
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := r.ReadString('\n'); err != nil {
+		conn.Close()
+		return fmt.Errorf("nats handshake: %w", err)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	connect := map[string]interface{}{"verbose": false, "pedantic": false, "name": "leep_backend"}
+	if u.User != nil {
+		connect["user"] = u.User.Username()
+		connect["pass"], _ = u.User.Password()
+	}
+	opts, _ := json.Marshal(connect)
+
+	w := bufio.NewWriter(conn)
+	fmt.Fprintf(w, "CONNECT %s\r\n", opts)
+	if err := w.Flush(); err != nil {
+		conn.Close()
+		return err
+	}
+
+	// Answer server PINGs so the connection isn't dropped as stale.
+	go func() {
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if strings.HasPrefix(line, "PING") {
+				n.mu.Lock()
+				if n.conn == conn {
+					n.w.WriteString("PONG\r\n")
+					n.w.Flush()
+				}
+				n.mu.Unlock()
+			}
+		}
+	}()
+
+	n.conn = conn
+	n.w = w
+	return nil
+}
+
+// kafkaRESTSink produces to Kafka through a Confluent-compatible REST proxy.
+type kafkaRESTSink struct {
+	proxyURL string
+	topic    string
+	http     *http.Client
+}
+
+func (k *kafkaRESTSink) Publish(ctx context.Context, e IngestEvent) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"records": []map[string]interface{}{
+			// Keying by song keeps a song's events ordered within a partition.
+			{"key": fmt.Sprint(e.SongID), "value": e},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		k.proxyURL+"/topics/"+url.PathEscape(k.topic), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+
+	resp, err := k.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("kafka rest proxy: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The public catalog is listed for search engines as a sitemap index at
+// /sitemap.xml pointing at numbered pages per kind (/sitemaps/songs-1.xml,
+// ...). A periodic job keeps sitemap_entries in sync: each run only looks
+// at songs and profiles whose updated_at moved since the last run, so the
+// pages are cheap reads however large the catalog gets. Collections are
+// few and go live on a schedule rather than an update, so they're redone
+// in full each run.
+const (
+	sitemapName     = "sitemap"
+	sitemapInterval = 15 * time.Minute
+
+	// Search engines accept up to 50,000 URLs a page; smaller pages keep
+	// each response light.
+	sitemapPageSize = 10000
+	// Re-read a little before the last sync so rows committed late by a
+	// transaction that started before it aren't missed.
+	sitemapSyncOverlap = 5 * time.Minute
+
+	sitemapXMLNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+)
+
+var (
+	sitemapKinds       = []string{"songs", "artists", "collections"}
+	sitemapPagePattern = regexp.MustCompile(`^(songs|artists|collections)-([1-9][0-9]{0,5})\.xml$`)
+)
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	XMLNS    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapRef `xml:"sitemap"`
+}
+
+type sitemapRef struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapRef `xml:"url"`
+}
+
+// refreshSitemap brings sitemap_entries up to date with published songs,
+// artists with a handle and at least one published song, and collections
+// inside their window.
+func refreshSitemap(ctx context.Context) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	since := time.Time{}
+	var syncedAt *time.Time
+	if err := tx.QueryRow(ctx, `SELECT max(synced_at) FROM sitemap_sync;`).Scan(&syncedAt); err != nil {
+		return err
+	}
+	if syncedAt != nil {
+		since = syncedAt.Add(-sitemapSyncOverlap)
+	}
+
+	for _, step := range []struct {
+		sql  string
+		args []interface{}
+	}{
+		{`INSERT INTO sitemap_entries (kind, key, path, lastmod)
+		 SELECT 'songs', id::text, '/songs/' || id, updated_at FROM songs
+		 WHERE published AND updated_at > $1
+		 ON CONFLICT (kind, key) DO UPDATE SET path = EXCLUDED.path, lastmod = EXCLUDED.lastmod;`, []interface{}{since}},
+
+		{`DELETE FROM sitemap_entries e
+		 WHERE e.kind = 'songs'
+		   AND NOT EXISTS (SELECT 1 FROM songs s WHERE s.id = e.key::bigint AND s.published);`, nil},
+
+		// An artist changes when their profile does or one of their songs does.
+		{`WITH changed AS (
+			SELECT id FROM profiles WHERE updated_at > $1
+			UNION
+			SELECT artist_id FROM songs WHERE updated_at > $1 AND artist_id IS NOT NULL
+		 )
+		 INSERT INTO sitemap_entries (kind, key, path, lastmod)
+		 SELECT 'artists', p.id::text, '/artists/' || p.handle, GREATEST(p.updated_at, s.lastmod)
+		 FROM changed
+		 JOIN profiles p ON p.id = changed.id
+		 JOIN LATERAL (SELECT max(updated_at) AS lastmod FROM songs WHERE artist_id = p.id AND published) s
+		   ON s.lastmod IS NOT NULL
+		 WHERE p.handle IS NOT NULL
+		 ON CONFLICT (kind, key) DO UPDATE SET path = EXCLUDED.path, lastmod = EXCLUDED.lastmod;`, []interface{}{since}},
+
+		{`DELETE FROM sitemap_entries e
+		 WHERE e.kind = 'artists'
+		   AND NOT EXISTS (
+			SELECT 1 FROM profiles p
+			WHERE p.id::text = e.key AND p.handle IS NOT NULL
+			  AND EXISTS (SELECT 1 FROM songs s WHERE s.artist_id = p.id AND s.published)
+		   );`, nil},
+
+		{`INSERT INTO sitemap_entries (kind, key, path, lastmod)
+		 SELECT 'collections', id::text, '/collections/' || slug, GREATEST(updated_at, starts_at) FROM collections
+		 WHERE ` + collectionActive + `
+		 ON CONFLICT (kind, key) DO UPDATE SET path = EXCLUDED.path, lastmod = EXCLUDED.lastmod;`, nil},
+
+		{`DELETE FROM sitemap_entries e
+		 WHERE e.kind = 'collections'
+		   AND NOT EXISTS (SELECT 1 FROM collections c WHERE c.id = e.key::bigint AND ` + collectionActive + `);`, nil},
+	} {
+		if _, err := tx.Exec(ctx, step.sql, step.args...); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO sitemap_sync (id, synced_at) VALUES (true, now())
+		ON CONFLICT (id) DO UPDATE SET synced_at = EXCLUDED.synced_at;
+	`); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func writeSitemapXML(c *gin.Context, v interface{}) {
+	out, err := xml.Marshal(v)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", append([]byte(xml.Header), out...))
+}
+
+// RegisterSitemapRoutes defines the sitemap index and its pages.
+func RegisterSitemapRoutes(r *gin.Engine) {
+	// GET /sitemap.xml — the index: one entry per page, with the page's newest lastmod
+	r.GET("/sitemap.xml", func(c *gin.Context) {
+		rows, err := db.Query(c.Request.Context(), `
+			SELECT kind, page, max(lastmod) FROM (
+				SELECT kind, lastmod, (row_number() OVER (PARTITION BY kind ORDER BY id) - 1) / $1 + 1 AS page
+				FROM sitemap_entries
+			) e
+			GROUP BY kind, page;
+		`, sitemapPageSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		pages := map[string][]sitemapRef{}
+		for rows.Next() {
+			var (
+				kind    string
+				page    int64
+				lastmod time.Time
+			)
+			if err := rows.Scan(&kind, &page, &lastmod); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			for int64(len(pages[kind])) < page {
+				pages[kind] = append(pages[kind], sitemapRef{})
+			}
+			pages[kind][page-1] = sitemapRef{
+				Loc:     fmt.Sprintf("%s/sitemaps/%s-%d.xml", requestOrigin(c), kind, page),
+				LastMod: lastmod.UTC().Format(time.RFC3339),
+			}
+		}
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		index := sitemapIndex{XMLNS: sitemapXMLNS, Sitemaps: []sitemapRef{}}
+		for _, kind := range sitemapKinds {
+			index.Sitemaps = append(index.Sitemaps, pages[kind]...)
+		}
+		writeSitemapXML(c, index)
+	})
+
+	// GET /sitemaps/:page — e.g. /sitemaps/songs-1.xml
+	r.GET("/sitemaps/:page", func(c *gin.Context) {
+		m := sitemapPagePattern.FindStringSubmatch(c.Param("page"))
+		if m == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "sitemap not found"})
+			return
+		}
+		if cfg.ShareBaseURL == "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "sitemaps need SHARE_BASE_URL"})
+			return
+		}
+		page, _ := strconv.Atoi(m[2])
+
+		rows, err := db.Query(c.Request.Context(), `
+			SELECT path, lastmod FROM sitemap_entries
+			WHERE kind = $1
+			ORDER BY id
+			LIMIT $2 OFFSET $3;
+		`, m[1], sitemapPageSize, (page-1)*sitemapPageSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		set := sitemapURLSet{XMLNS: sitemapXMLNS}
+		for rows.Next() {
+			var (
+				path    string
+				lastmod time.Time
+			)
+			if err := rows.Scan(&path, &lastmod); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			set.URLs = append(set.URLs, sitemapRef{Loc: shareURL(path), LastMod: lastmod.UTC().Format(time.RFC3339)})
+		}
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(set.URLs) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "sitemap not found"})
+			return
+		}
+		writeSitemapXML(c, set)
+	})
+}
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Smart links are artist-managed link-in-bio pages for a song or release.
+// GET /l/:slug returns everything a client needs to render the page
+// (including tracking pixel IDs) and counts a view; GET /l/:slug/go/:platform
+// counts a click and redirects. Bot traffic is not counted.
+const maxSmartLinkTargets = 20
+
+var (
+	smartLinkSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{2,63}$`)
+	pixelIDPattern       = regexp.MustCompile(`^[A-Za-z0-9_-]{1,40}$`)
+)
+
+var smartLinkPlatforms = map[string]bool{
+	"leep": true, "spotify": true, "apple_music": true, "youtube": true, "youtube_music": true,
+	"soundcloud": true, "bandcamp": true, "tidal": true, "deezer": true, "amazon_music": true, "website": true,
+}
+
+var smartLinkPixels = map[string]bool{"meta": true, "tiktok": true, "google": true, "snapchat": true}
+
+type smartLinkInput struct {
+	SongID    *int64             `json:"song_id"`
+	ReleaseID *int64             `json:"release_id"`
+	Slug      *string            `json:"slug"`
+	Title     *string            `json:"title"`
+	Links     *[]SmartLinkTarget `json:"links"`
+	Pixels    *map[string]string `json:"pixels"`
+}
+
+// validate checks the fields that are set; create additionally requires
+// slug and title.
+func (in smartLinkInput) validate() error {
+	if in.Slug != nil && !smartLinkSlugPattern.MatchString(*in.Slug) {
+		return fmt.Errorf("slug must be 3-64 lowercase letters, digits, or dashes")
+	}
+	if in.Title != nil && strings.TrimSpace(*in.Title) == "" {
+		return fmt.Errorf("title cannot be empty")
+	}
+	if in.Links != nil {
+		if len(*in.Links) > maxSmartLinkTargets {
+			return fmt.Errorf("at most %d links", maxSmartLinkTargets)
+		}
+		seen := map[string]bool{}
+		for _, l := range *in.Links {
+			if !smartLinkPlatforms[l.Platform] {
+				return fmt.Errorf("unsupported platform %q", l.Platform)
+			}
+			if seen[l.Platform] {
+				return fmt.Errorf("platform %q is listed twice", l.Platform)
+			}
+			seen[l.Platform] = true
+			if u, err := url.Parse(l.URL); err != nil || u.Scheme != "https" || u.Host == "" {
+				return fmt.Errorf("link for %s must be an https URL", l.Platform)
+			}
+		}
+	}
+	if in.Pixels != nil {
+		for k, v := range *in.Pixels {
+			if !smartLinkPixels[k] {
+				return fmt.Errorf("unsupported pixel %q", k)
+			}
+			if !pixelIDPattern.MatchString(v) {
+				return fmt.Errorf("invalid %s pixel ID", k)
+			}
+		}
+	}
+	return nil
+}
+
+const smartLinkColumns = `id, artist_id, song_id, release_id, slug, title, links, pixels, created_at, updated_at`
+
+func scanSmartLink(row pgx.Row) (SmartLink, error) {
+	var l SmartLink
+	var links, pixels []byte
+	err := row.Scan(&l.ID, &l.ArtistID, &l.SongID, &l.ReleaseID, &l.Slug, &l.Title, &links, &pixels, &l.CreatedAt, &l.UpdatedAt)
+	if err != nil {
+		return l, err
+	}
+	json.Unmarshal(links, &l.Links)
+	json.Unmarshal(pixels, &l.Pixels)
+	return l, nil
+}
+
+// recordSmartLinkHit counts a view (platform "") or click unless the
+// request looks like a bot.
+func recordSmartLinkHit(c *gin.Context, linkID int64, platform string) {
+	if classifyBot(context.Background(), c, 1).Action != "" {
+		return
+	}
+
+	referrer := ""
+	if u, err := url.Parse(c.GetHeader("Referer")); err == nil {
+		referrer = u.Hostname()
+	}
+	var p *string
+	if platform != "" {
+		p = &platform
+	}
+	if _, err := db.Exec(context.Background(),
+		`INSERT INTO smart_link_hits (link_id, platform, referrer_host) VALUES ($1, $2, $3);`,
+		linkID, p, referrer,
+	); err != nil {
+		log.Printf("smart link %d: failed to record hit: %v", linkID, err)
+	}
+}
+
+// checkSmartLinkTargets verifies the caller owns the song and release.
+func checkSmartLinkTargets(ctx context.Context, artistID string, in smartLinkInput) (bool, error) {
+	if in.SongID != nil {
+		if owned, err := songOwnedBy(ctx, *in.SongID, artistID); err != nil || !owned {
+			return false, err
+		}
+	}
+	if in.ReleaseID != nil {
+		var owned bool
+		err := db.QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM scheduled_releases WHERE id = $1 AND artist_id = $2);`,
+			*in.ReleaseID, artistID,
+		).Scan(&owned)
+		if err != nil || !owned {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// RegisterSmartLinkRoutes defines smart link management and the public pages.
+func RegisterSmartLinkRoutes(r *gin.Engine) {
+	// GET /l/:slug — public page data
+	r.GET("/l/:slug", func(c *gin.Context) {
+		l, err := scanSmartLink(db.QueryRow(context.Background(),
+			`SELECT `+smartLinkColumns+` FROM smart_links WHERE slug = $1;`, c.Param("slug")))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "link not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		recordSmartLinkHit(c, l.ID, "")
+
+		// Clients render buttons pointing at the click-tracking redirect.
+		links := make([]gin.H, 0, len(l.Links))
+		for _, t := range l.Links {
+			links = append(links, gin.H{
+				"platform": t.Platform,
+				"url":      t.URL,
+				"go_url":   fmt.Sprintf("/l/%s/go/%s", l.Slug, t.Platform),
+			})
+		}
+		page := gin.H{"slug": l.Slug, "title": l.Title, "links": links, "pixels": l.Pixels}
+		if l.SongID != nil {
+			page["song_id"] = *l.SongID
+			if og, err := songOpenGraph(context.Background(), *l.SongID); err == nil {
+				og.URL = shareURL("/l/" + l.Slug)
+				page["og"] = og
+			}
+		}
+		if l.ReleaseID != nil {
+			var startsAt time.Time
+			if err := db.QueryRow(context.Background(),
+				`SELECT starts_at FROM scheduled_releases WHERE id = $1;`, *l.ReleaseID,
+			).Scan(&startsAt); err == nil {
+				page["release_at"] = startsAt
+			}
+		}
+		c.JSON(http.StatusOK, page)
+	})
+
+	// GET /l/:slug/go/:platform — counts the click and redirects
+	r.GET("/l/:slug/go/:platform", func(c *gin.Context) {
+		l, err := scanSmartLink(db.QueryRow(context.Background(),
+			`SELECT `+smartLinkColumns+` FROM smart_links WHERE slug = $1;`, c.Param("slug")))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "link not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		for _, t := range l.Links {
+			if t.Platform == c.Param("platform") {
+				recordSmartLinkHit(c, l.ID, t.Platform)
+				c.Redirect(http.StatusFound, t.URL)
+				return
+			}
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "platform not on this link"})
+	})
+
+	me := r.Group("/me/smart-links", RequireAuth())
+
+	// GET /me/smart-links
+	me.GET("", func(c *gin.Context) {
+		rows, err := db.Query(context.Background(),
+			`SELECT `+smartLinkColumns+` FROM smart_links WHERE artist_id = $1 ORDER BY id;`, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		links := []SmartLink{}
+		for rows.Next() {
+			l, err := scanSmartLink(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			links = append(links, l)
+		}
+		c.JSON(http.StatusOK, links)
+	})
+
+	// POST /me/smart-links
+	me.POST("", func(c *gin.Context) {
+		var body smartLinkInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if body.Slug == nil || body.Title == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "slug and title are required"})
+			return
+		}
+		if err := body.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		owned, err := checkSmartLinkTargets(context.Background(), currentUserID(c), body)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !owned {
+			c.JSON(http.StatusForbidden, gin.H{"error": "song or release is not yours"})
+			return
+		}
+
+		links, pixels := []SmartLinkTarget{}, map[string]string{}
+		if body.Links != nil {
+			links = *body.Links
+		}
+		if body.Pixels != nil {
+			pixels = *body.Pixels
+		}
+		linksJSON, _ := json.Marshal(links)
+		pixelsJSON, _ := json.Marshal(pixels)
+
+		l, err := scanSmartLink(db.QueryRow(context.Background(), `
+			INSERT INTO smart_links (artist_id, song_id, release_id, slug, title, links, pixels)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (slug) DO NOTHING
+			RETURNING `+smartLinkColumns+`;
+		`, currentUserID(c), body.SongID, body.ReleaseID, *body.Slug, strings.TrimSpace(*body.Title), linksJSON, pixelsJSON))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "slug is taken"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, l)
+	})
+
+	// PATCH /me/smart-links/:id
+	me.PATCH("/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid link id"})
+			return
+		}
+
+		var body smartLinkInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if err := body.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		owned, err := checkSmartLinkTargets(context.Background(), currentUserID(c), body)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !owned {
+			c.JSON(http.StatusForbidden, gin.H{"error": "song or release is not yours"})
+			return
+		}
+
+		var linksJSON, pixelsJSON []byte
+		if body.Links != nil {
+			linksJSON, _ = json.Marshal(*body.Links)
+		}
+		if body.Pixels != nil {
+			pixelsJSON, _ = json.Marshal(*body.Pixels)
+		}
+
+		l, err := scanSmartLink(db.QueryRow(context.Background(), `
+			UPDATE smart_links SET
+				song_id    = COALESCE($3, song_id),
+				release_id = COALESCE($4, release_id),
+				slug       = COALESCE($5, slug),
+				title      = COALESCE($6, title),
+				links      = COALESCE($7, links),
+				pixels     = COALESCE($8, pixels),
+				updated_at = now()
+			WHERE id = $1 AND artist_id = $2
+			RETURNING `+smartLinkColumns+`;
+		`, id, currentUserID(c), body.SongID, body.ReleaseID, body.Slug, body.Title, linksJSON, pixelsJSON))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "link not found"})
+			return
+		}
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				c.JSON(http.StatusConflict, gin.H{"error": "slug is taken"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, l)
+	})
+
+	// DELETE /me/smart-links/:id
+	me.DELETE("/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid link id"})
+			return
+		}
+
+		tag, err := db.Exec(context.Background(),
+			`DELETE FROM smart_links WHERE id = $1 AND artist_id = $2;`, id, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "link not found"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	// GET /me/smart-links/:id/analytics?from=&to= — views, clicks per platform, top referrers
+	me.GET("/:id/analytics", RequireTeamScope(scopeAnalyticsRead), func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid link id"})
+			return
+		}
+		from, to, err := parseDateRange(c, 30, 366)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var owned bool
+		if err := db.QueryRow(context.Background(),
+			`SELECT EXISTS (SELECT 1 FROM smart_links WHERE id = $1 AND artist_id = $2);`, id, currentUserID(c),
+		).Scan(&owned); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !owned {
+			c.JSON(http.StatusNotFound, gin.H{"error": "link not found"})
+			return
+		}
+
+		rows, err := db.Query(context.Background(), `
+			SELECT COALESCE(platform, ''), COUNT(*)
+			FROM smart_link_hits
+			WHERE link_id = $1 AND created_at >= $2 AND created_at < $3::date + 1
+			GROUP BY 1;
+		`, id, from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var views, clicks int64
+		byPlatform := map[string]int64{}
+		for rows.Next() {
+			var (
+				platform string
+				n        int64
+			)
+			if err := rows.Scan(&platform, &n); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if platform == "" {
+				views = n
+				continue
+			}
+			clicks += n
+			byPlatform[platform] = n
+		}
+		rows.Close()
+
+		rows, err = db.Query(context.Background(), `
+			SELECT referrer_host, COUNT(*)
+			FROM smart_link_hits
+			WHERE link_id = $1 AND platform IS NULL AND referrer_host <> ''
+			  AND created_at >= $2 AND created_at < $3::date + 1
+			GROUP BY 1 ORDER BY 2 DESC LIMIT 10;
+		`, id, from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		type referrer struct {
+			Host  string `json:"host"`
+			Views int64  `json:"views"`
+		}
+		referrers := []referrer{}
+		for rows.Next() {
+			var ref referrer
+			if err := rows.Scan(&ref.Host, &ref.Views); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			referrers = append(referrers, ref)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"link_id":            id,
+			"from":               from.Format(dateLayout),
+			"to":                 to.Format(dateLayout),
+			"views":              views,
+			"clicks":             clicks,
+			"click_through_rate": ratio(clicks, views),
+			"clicks_by_platform": byPlatform,
+			"top_referrers":      referrers,
+		})
+	})
+}
@@ -0,0 +1,723 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Artists connect social accounts so publishing a song can post a release
+// card (title, artist, share link) for them. X (Twitter) is connected with
+// OAuth 2.0 + PKCE: the app sends the user to authorize_url and hands the
+// returned code and state to /me/social/twitter/callback. Instagram has no
+// posting API for most accounts, so it goes through an artist-run webhook
+// proxy (Zapier, Make, ...) that receives the card signed like our
+// webhooks. Each post is a social_posts row delivered by the job queue.
+const (
+	socialPostJob     = "social_post"
+	providerTwitter   = "twitter"
+	providerInstagram = "instagram"
+
+	twitterAuthorizeURL = "https://twitter.com/i/oauth2/authorize"
+	twitterTokenURL     = "https://api.twitter.com/2/oauth2/token"
+	twitterAPIURL       = "https://api.twitter.com/2"
+	twitterScopes       = "tweet.read tweet.write users.read offline.access"
+
+	// X counts every link as 23 characters.
+	maxTweetLength = 280
+	tweetURLLength = 23
+
+	socialOAuthStateTTL = 10 * time.Minute
+)
+
+var socialProviders = map[string]bool{providerTwitter: true, providerInstagram: true}
+
+// errReconnectRequired means the provider rejected our credentials; the
+// artist has to connect the account again.
+var errReconnectRequired = errors.New("the account needs to be reconnected")
+
+type socialPostPayload struct {
+	PostID int64 `json:"post_id"`
+}
+
+type twitterTokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+func init() {
+	RegisterJobHandler(socialPostJob, runSocialPost)
+}
+
+const socialConnectionColumns = `id, user_id::text, provider, account_name, access_token, refresh_token, token_expires_at,
+	webhook_url, secret, auto_post, status, last_error, created_at, updated_at`
+
+func scanSocialConnection(row pgx.Row) (SocialConnection, error) {
+	var s SocialConnection
+	err := row.Scan(&s.ID, &s.UserID, &s.Provider, &s.AccountName, &s.AccessToken, &s.RefreshToken, &s.ExpiresAt,
+		&s.WebhookURL, &s.Secret, &s.AutoPost, &s.Status, &s.LastError, &s.CreatedAt, &s.UpdatedAt)
+	return s, err
+}
+
+const socialPostColumns = `p.id, p.connection_id, sc.provider, p.song_id, p.content, p.link_url, p.status, p.attempts,
+	p.external_id, p.last_error, p.created_at, p.posted_at`
+
+func scanSocialPost(row pgx.Row) (SocialPost, error) {
+	var p SocialPost
+	err := row.Scan(&p.ID, &p.ConnectionID, &p.Provider, &p.SongID, &p.Content, &p.LinkURL, &p.Status, &p.Attempts,
+		&p.ExternalID, &p.LastError, &p.CreatedAt, &p.PostedAt)
+	return p, err
+}
+
+func twitterConfigured() bool {
+	return cfg.TwitterClientID != "" && cfg.TwitterClientSecret != "" && cfg.TwitterRedirectURL != ""
+}
+
+// pkceChallenge is the S256 code challenge for verifier.
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// requestTwitterTokens calls the token endpoint for an authorization_code
+// or refresh_token grant. A 400 or 401 means the grant itself was refused.
+func requestTwitterTokens(ctx context.Context, form url.Values) (twitterTokens, error) {
+	form.Set("client_id", cfg.TwitterClientID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, twitterTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return twitterTokens{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(cfg.TwitterClientID, cfg.TwitterClientSecret)
+
+	resp, err := webhookHTTP.Do(req)
+	if err != nil {
+		return twitterTokens{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		return twitterTokens{}, errReconnectRequired
+	}
+	if resp.StatusCode != http.StatusOK {
+		return twitterTokens{}, fmt.Errorf("x token endpoint returned %d", resp.StatusCode)
+	}
+
+	var t twitterTokens
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&t); err != nil {
+		return twitterTokens{}, err
+	}
+	if t.AccessToken == "" {
+		return twitterTokens{}, fmt.Errorf("x token endpoint returned no access token")
+	}
+	return t, nil
+}
+
+// twitterRequest makes an authenticated X API call and decodes the JSON
+// response into out.
+func twitterRequest(ctx context.Context, method, path, accessToken string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, twitterAPIURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := webhookHTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return errReconnectRequired
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("x returned %d", resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(out)
+}
+
+// freshTwitterToken returns a usable access token, refreshing and storing
+// new tokens when the current one is about to expire.
+func freshTwitterToken(ctx context.Context, conn SocialConnection) (string, error) {
+	if conn.AccessToken == nil {
+		return "", errReconnectRequired
+	}
+	if conn.ExpiresAt == nil || time.Until(*conn.ExpiresAt) > time.Minute {
+		return *conn.AccessToken, nil
+	}
+	if conn.RefreshToken == nil {
+		return "", errReconnectRequired
+	}
+
+	t, err := requestTwitterTokens(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {*conn.RefreshToken},
+	})
+	if err != nil {
+		return "", err
+	}
+	// X rotates refresh tokens; keep the old one if none came back.
+	_, err = db.Exec(ctx, `
+		UPDATE social_connections
+		SET access_token = $2, refresh_token = COALESCE(NULLIF($3, ''), refresh_token),
+		    token_expires_at = $4, updated_at = now()
+		WHERE id = $1;
+	`, conn.ID, t.AccessToken, t.RefreshToken, time.Now().Add(time.Duration(t.ExpiresIn)*time.Second))
+	return t.AccessToken, err
+}
+
+// releaseCard builds the post for a song: the text, the share link (empty
+// when SHARE_BASE_URL is unset), and the artwork URL (empty without one).
+func releaseCard(ctx context.Context, songID int64) (content, link, image string, err error) {
+	s, err := loadSong(ctx, songID)
+	if err != nil {
+		return "", "", "", err
+	}
+	var artist, slug string
+	err = db.QueryRow(ctx, `
+		SELECT COALESCE((SELECT display_name FROM profiles WHERE id::text = $2), ''),
+		       COALESCE((SELECT slug FROM smart_links WHERE song_id = $1 ORDER BY id DESC LIMIT 1), '');
+	`, songID, s.ArtistID).Scan(&artist, &slug)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if cfg.ShareBaseURL != "" {
+		link = fmt.Sprintf("%s/songs/%d", cfg.ShareBaseURL, songID)
+		if slug != "" {
+			link = cfg.ShareBaseURL + "/l/" + slug
+		}
+	}
+	if s.ArtworkURL != nil {
+		image = *s.ArtworkURL
+	}
+
+	by := ""
+	if artist != "" {
+		by = " by " + artist
+	}
+	// Leave room for the link on X, shortening the title if it doesn't fit.
+	format := "🎵 New release: \"%s\"%s is out now on Leep"
+	title := []rune(s.Title)
+	room := maxTweetLength - tweetURLLength - 1 - len([]rune(fmt.Sprintf(format, "", by)))
+	if len(title) > room && room > 1 {
+		title = append(title[:room-1], '…')
+	}
+	content = fmt.Sprintf(format, string(title), by)
+	return content, link, image, nil
+}
+
+// crossPostRelease queues a release card for each of the artist's active
+// connections in providers, or every auto_post connection when providers
+// is nil. A song is posted at most once per connection.
+func crossPostRelease(ctx context.Context, artistID string, songID int64, providers []string) ([]SocialPost, error) {
+	content, link, image, err := releaseCard(ctx, songID)
+	if err != nil {
+		return nil, err
+	}
+	var linkURL, imageURL *string
+	if link != "" {
+		linkURL = &link
+	}
+	if image != "" {
+		imageURL = &image
+	}
+
+	rows, err := db.Query(ctx, `
+		WITH targets AS (
+			SELECT id FROM social_connections
+			WHERE user_id::text = $1 AND status = 'active'
+			  AND (($2::text[] IS NULL AND auto_post) OR provider = ANY ($2))
+		), queued AS (
+			INSERT INTO social_posts (connection_id, song_id, content, link_url, image_url)
+			SELECT id, $3, $4, $5, $6 FROM targets
+			ON CONFLICT (connection_id, song_id) DO NOTHING
+			RETURNING *
+		)
+		SELECT `+socialPostColumns+`
+		FROM queued p JOIN social_connections sc ON sc.id = p.connection_id;
+	`, artistID, providers, songID, content, linkURL, imageURL)
+	if err != nil {
+		return nil, err
+	}
+	posts := []SocialPost{}
+	for rows.Next() {
+		p, err := scanSocialPost(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, p := range posts {
+		if _, err := EnqueueJob(ctx, socialPostJob, socialPostPayload{PostID: p.ID}, artistID); err != nil {
+			return posts, err
+		}
+	}
+	return posts, nil
+}
+
+func runSocialPost(ctx context.Context, job *Job) (interface{}, error) {
+	var p socialPostPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return nil, err
+	}
+
+	var (
+		content                  string
+		songID                   int64
+		status                   string
+		linkURL, imageURL, title *string
+	)
+	conn, err := scanSocialConnection(db.QueryRow(ctx, `
+		SELECT `+socialConnectionColumns+` FROM social_connections
+		WHERE id = (SELECT connection_id FROM social_posts WHERE id = $1);
+	`, p.PostID))
+	if err == nil {
+		err = db.QueryRow(ctx, `
+			SELECT p.song_id, p.content, p.link_url, p.image_url, p.status, s.title
+			FROM social_posts p LEFT JOIN songs s ON s.id = p.song_id
+			WHERE p.id = $1;
+		`, p.PostID).Scan(&songID, &content, &linkURL, &imageURL, &status, &title)
+	}
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && (status == "posted" || conn.Status != "active")) {
+		// Disconnected, already posted, or waiting on a reconnect.
+		return gin.H{"skipped": true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var externalID string
+	switch conn.Provider {
+	case providerTwitter:
+		externalID, err = postToTwitter(ctx, conn, content, linkURL)
+	case providerInstagram:
+		externalID, err = postToInstagramProxy(ctx, conn, p.PostID, gin.H{
+			"song_id":   songID,
+			"title":     title,
+			"caption":   content,
+			"link_url":  linkURL,
+			"image_url": imageURL,
+		})
+	default:
+		err = fmt.Errorf("unknown provider %q", conn.Provider)
+	}
+
+	if err == nil {
+		_, dbErr := db.Exec(ctx, `
+			UPDATE social_posts
+			SET status = 'posted', attempts = attempts + 1, external_id = NULLIF($2, ''), last_error = NULL, posted_at = now()
+			WHERE id = $1;
+		`, p.PostID, externalID)
+		if dbErr == nil {
+			_, dbErr = db.Exec(ctx,
+				`UPDATE social_connections SET last_error = NULL, updated_at = now() WHERE id = $1;`, conn.ID)
+		}
+		return gin.H{"external_id": externalID}, dbErr
+	}
+
+	// A rejected credential won't fix itself, so stop retrying and flag the
+	// connection instead.
+	reconnect := errors.Is(err, errReconnectRequired)
+	finalStatus := "pending"
+	if reconnect || job.Attempts >= jobMaxAttempts {
+		finalStatus = "failed"
+	}
+	if _, dbErr := db.Exec(ctx, `
+		UPDATE social_posts SET status = $2, attempts = attempts + 1, last_error = $3 WHERE id = $1;
+	`, p.PostID, finalStatus, err.Error()); dbErr != nil {
+		log.Printf("social post %d: failed to record attempt: %v", p.PostID, dbErr)
+	}
+	if _, dbErr := db.Exec(ctx, `
+		UPDATE social_connections
+		SET last_error = $2, status = CASE WHEN $3 THEN 'reconnect_required' ELSE status END, updated_at = now()
+		WHERE id = $1;
+	`, conn.ID, err.Error(), reconnect); dbErr != nil {
+		log.Printf("social connection %d: failed to record error: %v", conn.ID, dbErr)
+	}
+	if reconnect {
+		return gin.H{"failed": true, "error": err.Error()}, nil
+	}
+	return nil, err
+}
+
+func postToTwitter(ctx context.Context, conn SocialConnection, content string, linkURL *string) (string, error) {
+	token, err := freshTwitterToken(ctx, conn)
+	if err != nil {
+		return "", err
+	}
+	text := content
+	if linkURL != nil {
+		text += " " + *linkURL
+	}
+
+	var resp struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := twitterRequest(ctx, http.MethodPost, "/tweets", token, gin.H{"text": text}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Data.ID, nil
+}
+
+// postToInstagramProxy sends the card to the artist's proxy, which does the
+// actual posting. Any 2xx counts as posted.
+func postToInstagramProxy(ctx context.Context, conn SocialConnection, postID int64, card gin.H) (string, error) {
+	if conn.WebhookURL == nil || conn.Secret == nil {
+		return "", errReconnectRequired
+	}
+	payload, err := json.Marshal(gin.H{
+		"id":         postID,
+		"type":       "social.release",
+		"provider":   providerInstagram,
+		"created_at": time.Now().UTC(),
+		"data":       card,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, *conn.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Leep-Webhooks/1.0")
+	req.Header.Set("Leep-Event", "social.release")
+	req.Header.Set("Leep-Delivery", strconv.FormatInt(postID, 10))
+	req.Header.Set("Leep-Signature", signWebhook(*conn.Secret, time.Now().Unix(), payload))
+
+	resp, err := webhookHTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("proxy returned %d", resp.StatusCode)
+	}
+	return "", nil
+}
+
+// RegisterSocialRoutes defines /me/social for connecting accounts and
+// checking on cross-posts.
+func RegisterSocialRoutes(r *gin.Engine) {
+	me := r.Group("/me/social", RequireAuth())
+
+	// GET /me/social — connections plus the 50 most recent posts
+	me.GET("", func(c *gin.Context) {
+		rows, err := db.Query(context.Background(),
+			`SELECT `+socialConnectionColumns+` FROM social_connections WHERE user_id = $1 ORDER BY provider;`,
+			currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		connections := []SocialConnection{}
+		for rows.Next() {
+			conn, err := scanSocialConnection(rows)
+			if err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			connections = append(connections, conn)
+		}
+		rows.Close()
+
+		rows, err = db.Query(context.Background(), `
+			SELECT `+socialPostColumns+`
+			FROM social_posts p JOIN social_connections sc ON sc.id = p.connection_id
+			WHERE sc.user_id = $1
+			ORDER BY p.id DESC
+			LIMIT 50;
+		`, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		posts := []SocialPost{}
+		for rows.Next() {
+			p, err := scanSocialPost(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			posts = append(posts, p)
+		}
+		c.JSON(http.StatusOK, gin.H{"connections": connections, "posts": posts})
+	})
+
+	// POST /me/social/twitter/authorize — returns the URL to send the user to
+	me.POST("/twitter/authorize", func(c *gin.Context) {
+		if !twitterConfigured() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "x is not configured"})
+			return
+		}
+		state, err := newOpaqueToken("")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		verifier, err := newOpaqueToken("")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		_, err = db.Exec(context.Background(),
+			`DELETE FROM social_oauth_states WHERE created_at < $1;`, time.Now().Add(-socialOAuthStateTTL))
+		if err == nil {
+			_, err = db.Exec(context.Background(), `
+				INSERT INTO social_oauth_states (state, user_id, provider, code_verifier) VALUES ($1, $2, $3, $4);
+			`, state, currentUserID(c), providerTwitter, verifier)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		q := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {cfg.TwitterClientID},
+			"redirect_uri":          {cfg.TwitterRedirectURL},
+			"scope":                 {twitterScopes},
+			"state":                 {state},
+			"code_challenge":        {pkceChallenge(verifier)},
+			"code_challenge_method": {"S256"},
+		}
+		c.JSON(http.StatusOK, gin.H{"authorize_url": twitterAuthorizeURL + "?" + q.Encode(), "state": state})
+	})
+
+	// POST /me/social/twitter/callback — {"code":"...","state":"..."} from the redirect
+	me.POST("/twitter/callback", func(c *gin.Context) {
+		if !twitterConfigured() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "x is not configured"})
+			return
+		}
+		var body struct {
+			Code  string `json:"code"`
+			State string `json:"state"`
+		}
+		if err := c.BindJSON(&body); err != nil || body.Code == "" || body.State == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
+			return
+		}
+
+		var verifier string
+		err := db.QueryRow(context.Background(), `
+			DELETE FROM social_oauth_states
+			WHERE state = $1 AND user_id = $2 AND provider = $3 AND created_at > $4
+			RETURNING code_verifier;
+		`, body.State, currentUserID(c), providerTwitter, time.Now().Add(-socialOAuthStateTTL)).Scan(&verifier)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "authorization expired or was already used; start again"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		t, err := requestTwitterTokens(context.Background(), url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {body.Code},
+			"redirect_uri":  {cfg.TwitterRedirectURL},
+			"code_verifier": {verifier},
+		})
+		if errors.Is(err, errReconnectRequired) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "x rejected the authorization code"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		var user struct {
+			Data struct {
+				Username string `json:"username"`
+			} `json:"data"`
+		}
+		if err := twitterRequest(context.Background(), http.MethodGet, "/users/me", t.AccessToken, nil, &user); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+
+		conn, err := scanSocialConnection(db.QueryRow(context.Background(), `
+			INSERT INTO social_connections (user_id, provider, account_name, access_token, refresh_token, token_expires_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+			ON CONFLICT (user_id, provider) DO UPDATE SET
+				account_name = EXCLUDED.account_name, access_token = EXCLUDED.access_token,
+				refresh_token = EXCLUDED.refresh_token, token_expires_at = EXCLUDED.token_expires_at,
+				status = 'active', last_error = NULL, updated_at = now()
+			RETURNING `+socialConnectionColumns+`;
+		`, currentUserID(c), providerTwitter, user.Data.Username, t.AccessToken, t.RefreshToken,
+			time.Now().Add(time.Duration(t.ExpiresIn)*time.Second)))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, conn)
+	})
+
+	// PUT /me/social/instagram — {"webhook_url":"https://...","account_name":"@me"}; a new secret is returned when first linked
+	me.PUT("/instagram", func(c *gin.Context) {
+		var body struct {
+			WebhookURL  string `json:"webhook_url"`
+			AccountName string `json:"account_name"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		u, err := checkWebhookURL(c.Request.Context(), "webhook_url", body.WebhookURL)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		secret, err := newOpaqueToken("whsec_")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		conn, err := scanSocialConnection(db.QueryRow(context.Background(), `
+			INSERT INTO social_connections (user_id, provider, account_name, webhook_url, secret)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (user_id, provider) DO UPDATE SET
+				account_name = EXCLUDED.account_name, webhook_url = EXCLUDED.webhook_url,
+				status = 'active', last_error = NULL, updated_at = now()
+			RETURNING `+socialConnectionColumns+`;
+		`, currentUserID(c), providerInstagram, strings.TrimSpace(body.AccountName), u.String(), secret))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		// An existing proxy keeps its secret, so only a new one is shown.
+		resp := gin.H{"connection": conn}
+		if conn.Secret != nil && *conn.Secret == secret {
+			resp["secret"] = secret
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+
+	// PATCH /me/social/:provider — {"auto_post":false}
+	me.PATCH("/:provider", func(c *gin.Context) {
+		var body struct {
+			AutoPost *bool `json:"auto_post"`
+		}
+		if err := c.BindJSON(&body); err != nil || body.AutoPost == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "auto_post is required"})
+			return
+		}
+
+		conn, err := scanSocialConnection(db.QueryRow(context.Background(), `
+			UPDATE social_connections SET auto_post = $3, updated_at = now()
+			WHERE user_id = $1 AND provider = $2
+			RETURNING `+socialConnectionColumns+`;
+		`, currentUserID(c), c.Param("provider"), *body.AutoPost))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "account is not connected"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, conn)
+	})
+
+	// DELETE /me/social/:provider — disconnects and drops the post log
+	me.DELETE("/:provider", func(c *gin.Context) {
+		tag, err := db.Exec(context.Background(),
+			`DELETE FROM social_connections WHERE user_id = $1 AND provider = $2;`, currentUserID(c), c.Param("provider"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "account is not connected"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	// POST /me/social/posts/:id/retry — requeues a failed post
+	me.POST("/posts/:id/retry", func(c *gin.Context) {
+		postID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post id"})
+			return
+		}
+
+		var postStatus, connStatus string
+		err = db.QueryRow(context.Background(), `
+			SELECT p.status, sc.status
+			FROM social_posts p JOIN social_connections sc ON sc.id = p.connection_id
+			WHERE p.id = $1 AND sc.user_id = $2;
+		`, postID, currentUserID(c)).Scan(&postStatus, &connStatus)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if postStatus != "failed" {
+			c.JSON(http.StatusConflict, gin.H{"error": "only failed posts can be retried", "status": postStatus})
+			return
+		}
+		if connStatus != "active" {
+			c.JSON(http.StatusConflict, gin.H{"error": "reconnect the account before retrying"})
+			return
+		}
+
+		if _, err := db.Exec(context.Background(),
+			`UPDATE social_posts SET status = 'pending', last_error = NULL WHERE id = $1;`, postID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		jobID, err := EnqueueJob(context.Background(), socialPostJob, socialPostPayload{PostID: postID}, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"post_id": postID, "job_id": jobID})
+	})
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Google and Apple sign-in go through Supabase Auth. Browsers use the PKCE
+// flow: /start returns the Supabase authorize URL and a state, and the app
+// posts the code it gets back with that state to /callback. The mobile
+// apps sign in natively and post the provider's ID token to /id-token.
+//
+// Supabase links a provider identity to the existing user when the emails
+// match and are verified. When it creates a second user instead, sign-in
+// is refused with code "account_exists": the user signs in the usual way
+// and connects the provider with /link, which attaches the identity to the
+// signed-in account.
+const oauthStateTTL = 10 * time.Minute
+
+var oauthLoginProviders = map[string]bool{"google": true, "apple": true}
+
+// sessionUser is the part of a Supabase session's user we read.
+type sessionUser struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+}
+
+// oauthRedirect picks the redirect URL: the requested one if allowed,
+// otherwise the default. ok is false for a URL that isn't allowed.
+func oauthRedirect(requested string) (string, bool) {
+	if requested == "" {
+		return cfg.OAuthRedirectURLs[0], true
+	}
+	for _, u := range cfg.OAuthRedirectURLs {
+		if u == requested {
+			return u, true
+		}
+	}
+	return "", false
+}
+
+// startOAuth stores a new state and PKCE verifier and returns them.
+func startOAuth(ctx context.Context, provider, linkingUser string) (state, verifier string, err error) {
+	if state, err = newOpaqueToken(""); err != nil {
+		return "", "", err
+	}
+	if verifier, err = newOpaqueToken(""); err != nil {
+		return "", "", err
+	}
+	if _, err = db.Exec(ctx,
+		`DELETE FROM auth_oauth_states WHERE created_at < $1;`, time.Now().Add(-oauthStateTTL)); err != nil {
+		return "", "", err
+	}
+	_, err = db.Exec(ctx, `
+		INSERT INTO auth_oauth_states (state, provider, code_verifier, linking_user)
+		VALUES ($1, $2, $3, NULLIF($4, '')::uuid);
+	`, state, provider, verifier, linkingUser)
+	return state, verifier, err
+}
+
+// identityLinkURL asks Supabase for the URL that links provider to the
+// account behind accessToken.
+func identityLinkURL(ctx context.Context, accessToken string, q url.Values) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		cfg.SupabaseURL+"/auth/v1/user/identities/authorize?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("apikey", cfg.SupabaseAnonKey)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := supabaseHTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("supabase auth returned %d: %s", resp.StatusCode, raw)
+	}
+	var out struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil || out.URL == "" {
+		return "", errors.New("supabase auth returned no link URL")
+	}
+	return out.URL, nil
+}
+
+// otherAccountWithEmail returns the ID of an existing user, other than
+// userID, with the same email. Lookup errors are logged and treated as no
+// match so sign-in isn't blocked on them.
+func otherAccountWithEmail(ctx context.Context, userID, email string) string {
+	if email == "" {
+		return ""
+	}
+	var otherID string
+	err := db.QueryRow(ctx, `
+		SELECT id::text FROM auth.users
+		WHERE lower(email) = lower($1) AND id::text <> $2 AND deleted_at IS NULL
+		ORDER BY created_at
+		LIMIT 1;
+	`, email, userID).Scan(&otherID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("oauth sign-in: failed to check for an existing account: %v", err)
+	}
+	return otherID
+}
+
+// finishOAuthSignIn writes the session, or account_exists when the provider
+// sign-in created a second account for an email we already have.
+func finishOAuthSignIn(c *gin.Context, provider string, session AuthResponse) {
+	var user sessionUser
+	if err := json.Unmarshal(session.User, &user); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "the auth provider returned an unexpected user"})
+		return
+	}
+	if otherAccountWithEmail(c.Request.Context(), user.ID, user.Email) != "" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":    fmt.Sprintf("an account with this email already exists; sign in to it and connect %s from your settings", provider),
+			"code":     "account_exists",
+			"provider": provider,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// writeGrantError writes a failed code or ID token exchange.
+func writeGrantError(c *gin.Context, err error) {
+	if errors.Is(err, errGrantRejected) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sign-in was rejected; start again", "code": "invalid_grant"})
+		return
+	}
+	log.Printf("oauth sign-in: %v", err)
+	c.JSON(http.StatusBadGateway, gin.H{"error": "could not reach the auth provider"})
+}
+
+// RegisterSocialLoginRoutes defines Google and Apple sign-in under /auth/oauth.
+func RegisterSocialLoginRoutes(r *gin.Engine) {
+	a := r.Group("/auth/oauth", func(c *gin.Context) {
+		if !supabaseConfigured() || len(cfg.OAuthRedirectURLs) == 0 {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "social sign-in is not configured"})
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.Next()
+	})
+
+	// providerParam validates :provider, writing the error when unsupported.
+	providerParam := func(c *gin.Context) (string, bool) {
+		provider := c.Param("provider")
+		if !oauthLoginProviders[provider] {
+			c.JSON(http.StatusNotFound, gin.H{"error": "provider must be google or apple"})
+			return "", false
+		}
+		return provider, true
+	}
+
+	// redirectBody reads an optional {"redirect_to":"..."}.
+	redirectBody := func(c *gin.Context) (string, bool) {
+		var body struct {
+			RedirectTo string `json:"redirect_to"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&body); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+				return "", false
+			}
+		}
+		redirectTo, ok := oauthRedirect(body.RedirectTo)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "redirect_to is not an allowed URL"})
+			return "", false
+		}
+		return redirectTo, true
+	}
+
+	// POST /auth/oauth/:provider/start — {"redirect_to":"..."}; send the user to "url", keep "state" for /callback
+	a.POST("/:provider/start", func(c *gin.Context) {
+		provider, ok := providerParam(c)
+		if !ok {
+			return
+		}
+		redirectTo, ok := redirectBody(c)
+		if !ok {
+			return
+		}
+
+		state, verifier, err := startOAuth(c.Request.Context(), provider, "")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		q := url.Values{
+			"provider":              {provider},
+			"redirect_to":           {redirectTo},
+			"code_challenge":        {pkceChallenge(verifier)},
+			"code_challenge_method": {"s256"},
+		}
+		c.JSON(http.StatusOK, gin.H{"url": cfg.SupabaseURL + "/auth/v1/authorize?" + q.Encode(), "state": state})
+	})
+
+	// POST /auth/oauth/:provider/link — like /start, but connects the provider to the signed-in account
+	a.POST("/:provider/link", RequireAuth(), func(c *gin.Context) {
+		provider, ok := providerParam(c)
+		if !ok {
+			return
+		}
+		redirectTo, ok := redirectBody(c)
+		if !ok {
+			return
+		}
+
+		state, verifier, err := startOAuth(c.Request.Context(), provider, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		linkURL, err := identityLinkURL(c.Request.Context(), bearerToken(c), url.Values{
+			"provider":              {provider},
+			"redirect_to":           {redirectTo},
+			"code_challenge":        {pkceChallenge(verifier)},
+			"code_challenge_method": {"s256"},
+			"skip_http_redirect":    {"true"},
+		})
+		if err != nil {
+			log.Printf("oauth link: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "could not reach the auth provider"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"url": linkURL, "state": state})
+	})
+
+	// POST /auth/oauth/callback — {"state":"...","code":"..."} from the redirect
+	a.POST("/callback", func(c *gin.Context) {
+		var body struct {
+			State string `json:"state"`
+			Code  string `json:"code"`
+		}
+		if err := c.BindJSON(&body); err != nil || body.State == "" || body.Code == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "state and code are required"})
+			return
+		}
+
+		var (
+			provider, verifier string
+			linkingUser        *string
+		)
+		err := db.QueryRow(c.Request.Context(), `
+			DELETE FROM auth_oauth_states WHERE state = $1 AND created_at > $2
+			RETURNING provider, code_verifier, linking_user::text;
+		`, body.State, time.Now().Add(-oauthStateTTL)).Scan(&provider, &verifier, &linkingUser)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sign-in expired or was already completed; start again", "code": "invalid_state"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		session, err := supabaseToken(c.Request.Context(), "pkce", gin.H{"auth_code": body.Code, "code_verifier": verifier})
+		if err != nil {
+			writeGrantError(c, err)
+			return
+		}
+		if linkingUser != nil {
+			// Linking keeps the user signed in to the same account.
+			var user sessionUser
+			if err := json.Unmarshal(session.User, &user); err != nil || !strings.EqualFold(user.ID, *linkingUser) {
+				c.JSON(http.StatusConflict, gin.H{"error": "the " + provider + " account is already used by another account", "code": "identity_in_use"})
+				return
+			}
+			c.JSON(http.StatusOK, session)
+			return
+		}
+		finishOAuthSignIn(c, provider, session)
+	})
+
+	// POST /auth/oauth/:provider/id-token — {"id_token":"...","nonce":"..."} from native Google/Apple sign-in
+	a.POST("/:provider/id-token", func(c *gin.Context) {
+		provider, ok := providerParam(c)
+		if !ok {
+			return
+		}
+		var body struct {
+			IDToken string `json:"id_token"`
+			Nonce   string `json:"nonce"`
+		}
+		if err := c.BindJSON(&body); err != nil || body.IDToken == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "id_token is required"})
+			return
+		}
+
+		payload := gin.H{"provider": provider, "id_token": body.IDToken}
+		if body.Nonce != "" {
+			payload["nonce"] = body.Nonce
+		}
+		session, err := supabaseToken(c.Request.Context(), "id_token", payload)
+		if err != nil {
+			writeGrantError(c, err)
+			return
+		}
+		finishOAuthSignIn(c, provider, session)
+	})
+}
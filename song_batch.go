@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Batch endpoints let an artist, or a team member managing their releases,
+// change many songs in one request. A batch is all or nothing: if any ID
+// isn't one of the caller's songs, nothing is changed and the offending IDs
+// are returned.
+const maxSongBatch = 100
+
+// songBatchIDs checks ids, returning them deduplicated and sorted, and
+// confirms the caller owns every one. It writes the error response when
+// not.
+func songBatchIDs(c *gin.Context, ids []int64, action string) ([]int64, bool) {
+	seen := map[int64]bool{}
+	unique := []int64{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) == 0 || len(unique) > maxSongBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("song_ids must list 1-%d songs", maxSongBatch)})
+		return nil, false
+	}
+	sort.Slice(unique, func(i, j int) bool { return unique[i] < unique[j] })
+
+	rows, err := db.Query(c.Request.Context(), `
+		SELECT u.id FROM unnest($1::bigint[]) AS u (id)
+		WHERE NOT EXISTS (SELECT 1 FROM songs s WHERE s.id = u.id AND s.artist_id = $2 AND s.trashed_at IS NULL)
+		ORDER BY u.id;
+	`, unique, currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	defer rows.Close()
+	notOwned := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		notOwned = append(notOwned, id)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if len(notOwned) > 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only " + action + " your own songs", "song_ids": notOwned})
+		return nil, false
+	}
+	return unique, true
+}
+
+// RegisterSongBatchRoutes defines the batch song endpoints.
+func RegisterSongBatchRoutes(r *gin.Engine) {
+	// PATCH /songs/batch {"song_ids":[1,2],"genre":"hip hop","published":false}
+	// Either field may be left out; "" clears the genre. Publishing checks
+	// each song's audio, so it stays one at a time at POST /songs/:id/publish.
+	r.PATCH("/songs/batch", RequireAuthOrAPIKey(), RequireScope(apiScopeSongsWrite), RequireTeamScope(scopeReleasesManage), func(c *gin.Context) {
+		var body struct {
+			SongIDs   []int64 `json:"song_ids"`
+			Genre     *string `json:"genre"`
+			Published *bool   `json:"published"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if body.Genre == nil && body.Published == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "nothing to change: set genre or published"})
+			return
+		}
+		if body.Published != nil && *body.Published {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "songs can only be unpublished in a batch; publish with POST /songs/:id/publish"})
+			return
+		}
+		if body.Genre != nil && *body.Genre != "" {
+			g, err := normalizeGenre(*body.Genre)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			body.Genre = &g
+		}
+		ids, ok := songBatchIDs(c, body.SongIDs, "change")
+		if !ok {
+			return
+		}
+
+		ctx := context.Background()
+		if _, err := db.Exec(ctx, `
+			UPDATE songs SET
+				genre     = CASE WHEN $2::text IS NULL THEN genre ELSE NULLIF($2, '') END,
+				published = COALESCE($3, published)
+			WHERE id = ANY ($1) AND artist_id = $4 AND trashed_at IS NULL;
+		`, ids, body.Genre, body.Published, currentUserID(c)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		songs, err := querySongs(ctx, `SELECT `+songColumns+` FROM `+songFrom+` WHERE s.id = ANY ($1) ORDER BY s.id;`, ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"updated": len(songs), "songs": songs})
+	})
+
+	// DELETE /songs/batch {"song_ids":[1,2]} — moves the caller's songs to their trash
+	r.DELETE("/songs/batch", RequireAuth(), func(c *gin.Context) {
+		var body struct {
+			SongIDs []int64 `json:"song_ids"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		ids, ok := songBatchIDs(c, body.SongIDs, "delete")
+		if !ok {
+			return
+		}
+
+		rows, err := db.Query(c.Request.Context(), `
+			UPDATE songs SET trashed_at = now(), trashed_published = published, published = false
+			WHERE id = ANY ($1) AND artist_id = $2 AND trashed_at IS NULL
+			RETURNING id, title, trashed_at;
+		`, ids, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+		trashed := []TrashItem{}
+		for rows.Next() {
+			var (
+				t  TrashItem
+				at time.Time
+			)
+			if err := rows.Scan(&t.ID, &t.Label, &at); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			t.Kind, t.TrashedAt, t.PurgeAt = trashKindSong, at, at.Add(trashRetention)
+			trashed = append(trashed, t)
+		}
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		sort.Slice(trashed, func(i, j int) bool { return trashed[i].ID < trashed[j].ID })
+		c.JSON(http.StatusOK, gin.H{"trashed": trashed})
+	})
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+// The song payload. Asset URLs are derived from song_assets so a new upload
+// changes the URL the next time the payload is read (artwork not yet
+// through moderation shows the placeholder); renditions carry the loudness
+// players normalize with.
+const songColumns = `s.id, s.title, s.artist_id::text, s.published, s.duration_seconds, s.release_date, s.isrc, s.label,
+	s.genre, s.comment_policy, s.downloads_enabled, s.download_gate, s.explicit, COALESCE(ap.verified, false),
+	s.show_id, s.season_number, s.episode_number, s.episode_type,
+	art.hash, art.ext, art.moderation_status, wav.hash, wav.ext,
+	(SELECT COUNT(*) FROM reposts rp WHERE rp.song_id = s.id)`
+
+const songFrom = `songs s
+	LEFT JOIN profiles ap ON ap.id = s.artist_id
+	LEFT JOIN song_assets art ON art.song_id = s.id AND art.kind = 'artwork'
+	LEFT JOIN song_assets wav ON wav.song_id = s.id AND wav.kind = 'waveform'`
+
+func scanSong(row pgx.Row) (Song, error) {
+	var (
+		s               Song
+		artHash, artExt *string
+		artStatus       *string
+		wavHash, wavExt *string
+		ep              SongEpisode
+		showID          *int64
+		episodeType     *string
+	)
+	err := row.Scan(&s.ID, &s.Title, &s.ArtistID, &s.Published, &s.DurationSeconds, &s.ReleaseDate,
+		&s.ISRC, &s.Label, &s.Genre, &s.CommentPolicy, &s.DownloadsEnabled, &s.DownloadGate, &s.Explicit, &s.ArtistVerified,
+		&showID, &ep.Season, &ep.Number, &episodeType, &artHash, &artExt, &artStatus, &wavHash, &wavExt,
+		&s.RepostCount)
+	if artHash != nil {
+		u := moderatedImageURL(*artHash, *artExt, *artStatus)
+		s.ArtworkURL = &u
+		if *artStatus != moderationApproved {
+			s.ArtworkStatus = artStatus
+		}
+	}
+	if wavHash != nil {
+		u := assetURL(*wavHash, *wavExt)
+		s.WaveformURL = &u
+	}
+	if showID != nil {
+		ep.ShowID = *showID
+		if episodeType != nil {
+			ep.Type = *episodeType
+		}
+		s.Episode = &ep
+	}
+	return s, err
+}
+
+// hotReads collapses concurrent identical reads of the busiest payloads,
+// like a song everyone opens the minute it's released, into one query whose
+// result they all share. Keys name the read and every input it depends on;
+// the result must not depend on the caller, and callers must not modify it.
+// Loads run on a background context so one client hanging up doesn't fail
+// the others waiting on it.
+var hotReads singleflight.Group
+
+// sharedSong is loadSong through hotReads.
+func sharedSong(songID int64) (Song, error) {
+	v, err, _ := hotReads.Do(fmt.Sprintf("song:%d", songID), func() (interface{}, error) {
+		return loadSong(context.Background(), songID)
+	})
+	return v.(Song), err
+}
+
+func loadSong(ctx context.Context, songID int64) (Song, error) {
+	s, err := scanSong(db.QueryRow(ctx, `SELECT `+songColumns+` FROM `+songFrom+` WHERE s.id = $1 AND s.trashed_at IS NULL;`, songID))
+	if err != nil {
+		return s, err
+	}
+	if s.Renditions, err = loadRenditions(ctx, songID); err != nil {
+		return s, err
+	}
+	if s.Credits, err = loadCredits(ctx, songID); err != nil {
+		return s, err
+	}
+	s.Transcripts, err = loadTranscripts(ctx, songID)
+	return s, err
+}
+
+// songVisibleTo reports whether userID may see s: drafts are owner-only.
+// Explicit songs are further kept from minors and safe mode by the handlers.
+func songVisibleTo(s Song, userID string) bool {
+	return s.Published || (s.ArtistID != nil && *s.ArtistID == userID)
+}
+
+// Song listing sorts. Each maps to a fixed ORDER BY; query input never
+// reaches the SQL text.
+var songListSorts = map[string]string{
+	"newest":      `s.created_at DESC, s.id DESC`,
+	"most_played": `COALESCE(st.plays, 0) DESC, s.id DESC`,
+	"top_rated":   `rv.avg_rating DESC NULLS LAST, COALESCE(rv.ratings, 0) DESC, s.id DESC`,
+}
+
+const (
+	defaultSongPage = 20
+	maxSongPage     = 100
+	maxSongOffset   = 10000
+)
+
+// normalizeGenre lowercases and trims a genre the way profile genre
+// preferences are, so the two can be matched.
+func normalizeGenre(g string) (string, error) {
+	g = strings.ToLower(strings.TrimSpace(g))
+	if g == "" || utf8.RuneCountInString(g) > maxGenreLength {
+		return "", fmt.Errorf("genre must be 1-%d characters", maxGenreLength)
+	}
+	return g, nil
+}
+
+// RegisterSongRoutes defines the song payload and listing endpoints.
+func RegisterSongRoutes(r *gin.Engine) {
+	// GET /songs?genre=&artist_id=&published=&created_after=&sort=newest|most_played|top_rated&limit=&offset=
+	// Drafts (published=false) are listed only for the caller's own songs.
+	r.GET("/songs", AllowClient(), RequireScope(apiScopeSongsRead), OptionalAuth(), func(c *gin.Context) {
+		var genre, artistID *string
+		if g := c.Query("genre"); g != "" {
+			n, err := normalizeGenre(g)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			genre = &n
+		}
+		if a := c.Query("artist_id"); a != "" {
+			if !userIDPattern.MatchString(a) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid artist_id"})
+				return
+			}
+			artistID = &a
+		}
+		published, err := strconv.ParseBool(c.DefaultQuery("published", "true"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "published must be true or false"})
+			return
+		}
+		if !published {
+			uid := currentUserID(c)
+			if uid == "" || (artistID != nil && *artistID != uid) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "you can only list your own unpublished songs"})
+				return
+			}
+			artistID = &uid
+		}
+		var createdAfter *time.Time
+		if v := c.Query("created_after"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				t, err = time.Parse(dateLayout, v)
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "created_after must be an RFC 3339 time or YYYY-MM-DD"})
+				return
+			}
+			createdAfter = &t
+		}
+		sort := c.DefaultQuery("sort", "newest")
+		order, ok := songListSorts[sort]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be newest, most_played, or top_rated"})
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSongPage)))
+		if err != nil || limit < 1 || limit > maxSongPage {
+			limit = defaultSongPage
+		}
+		offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if err != nil || offset < 0 || offset > maxSongOffset {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("offset must be 0-%d", maxSongOffset)})
+			return
+		}
+
+		rows, err := db.Query(c.Request.Context(), `
+			SELECT `+songColumns+` FROM `+songFrom+`
+			LEFT JOIN (SELECT song_id, SUM(plays) AS plays FROM song_daily_stats GROUP BY song_id) st ON st.song_id = s.id
+			LEFT JOIN (SELECT song_id, AVG(rating) AS avg_rating, COUNT(*) AS ratings FROM reviews GROUP BY song_id) rv ON rv.song_id = s.id
+			WHERE s.published = $1 AND s.trashed_at IS NULL
+			  AND ($2::text IS NULL OR s.genre = $2)
+			  AND ($3::uuid IS NULL OR s.artist_id = $3)
+			  AND ($4::timestamptz IS NULL OR s.created_at > $4)
+			  AND NOT (s.explicit AND $5)
+			ORDER BY `+order+`
+			LIMIT $6 OFFSET $7;
+		`, published, genre, artistID, createdAfter, hideExplicit(c), limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		songs := []Song{}
+		for rows.Next() {
+			s, err := scanSong(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			songs = append(songs, s)
+		}
+		c.JSON(http.StatusOK, gin.H{"songs": songs, "sort": sort, "limit": limit, "offset": offset})
+	})
+
+	// PUT /songs/:id/genre — {"genre":"hip hop"} or {"genre":null}
+	r.PUT("/songs/:id/genre", RequireAuthOrAPIKey(), RequireScope(apiScopeSongsWrite), func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "set the genre of")
+		if !ok {
+			return
+		}
+		var body struct {
+			Genre *string `json:"genre"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": `expected {"genre":"..."}`})
+			return
+		}
+		if body.Genre != nil {
+			g, err := normalizeGenre(*body.Genre)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			body.Genre = &g
+		}
+		if _, err := db.Exec(context.Background(),
+			`UPDATE songs SET genre = $2 WHERE id = $1;`, songID, body.Genre); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"song_id": songID, "genre": body.Genre})
+	})
+
+	// GET /songs/:id
+	r.GET("/songs/:id", AllowClient(), RequireScope(apiScopeSongsRead), OptionalAuth(), func(c *gin.Context) {
+		songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+			return
+		}
+
+		s, err := sharedSong(songID)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && !songVisibleTo(s, currentUserID(c))) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "song not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if s.Explicit && abortIfExplicit(c, "this song") {
+			return
+		}
+		c.JSON(http.StatusOK, s)
+	})
+}
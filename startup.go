@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// The server starts in steps, each run once the steps it needs have
+// succeeded. Steps that reach another service are retried with backoff, so
+// a database or bucket that comes up a few seconds after us doesn't fail
+// the deploy. A required step that still fails stops startup; an optional
+// one is reported as degraded and the server starts anyway, its feature
+// failing until the service comes back. Every step's outcome is logged
+// before serving, or before exiting.
+const (
+	startupAttempts    = 5
+	startupRetryBase   = time.Second
+	startupRetryMax    = 10 * time.Second
+	startupStepTimeout = 15 * time.Second
+)
+
+// Step outcomes in the startup report.
+const (
+	startupOK         = "ok"
+	startupSkipped    = "skipped"
+	startupDegraded   = "degraded"
+	startupFailed     = "failed"
+	startupNotStarted = "not started"
+)
+
+type startupStep struct {
+	name  string
+	needs []string
+	// retry runs the step again with backoff when it fails.
+	retry bool
+	// optional steps that fail leave the server running without them.
+	optional bool
+	run      func(ctx context.Context) error
+}
+
+// errNotConfigured is returned by a step with nothing to do.
+var errNotConfigured = errors.New("not configured")
+
+type startupResult struct {
+	name     string
+	status   string
+	attempts int
+	elapsed  time.Duration
+	err      error
+}
+
+// runStep runs s, retrying if it allows.
+func runStep(ctx context.Context, s startupStep) startupResult {
+	res := startupResult{name: s.name}
+	start := time.Now()
+	backoff := startupRetryBase
+	for {
+		res.attempts++
+		stepCtx, cancel := context.WithTimeout(ctx, startupStepTimeout)
+		res.err = s.run(stepCtx)
+		cancel()
+		if res.err == nil || errors.Is(res.err, errNotConfigured) || !s.retry || res.attempts == startupAttempts {
+			break
+		}
+		log.Printf("⚠️  startup: %s failed (attempt %d/%d), retrying in %s: %v",
+			s.name, res.attempts, startupAttempts, backoff, res.err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > startupRetryMax {
+			backoff = startupRetryMax
+		}
+	}
+	res.elapsed = time.Since(start)
+
+	switch {
+	case res.err == nil:
+		res.status = startupOK
+	case errors.Is(res.err, errNotConfigured):
+		res.status, res.err = startupSkipped, nil
+	case s.optional:
+		res.status = startupDegraded
+	default:
+		res.status = startupFailed
+	}
+	return res
+}
+
+// runStartup runs steps in dependency order. It stops at the first
+// required failure and reports whether startup succeeded.
+func runStartup(ctx context.Context, steps []startupStep) ([]startupResult, bool) {
+	done := map[string]bool{}
+	pending := steps
+	results := []startupResult{}
+	for len(pending) > 0 {
+		// The first step whose needs are all done runs next.
+		next := -1
+		for i, s := range pending {
+			ready := true
+			for _, n := range s.needs {
+				if !done[n] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			for _, s := range pending {
+				results = append(results, startupResult{name: s.name, status: startupNotStarted,
+					err: fmt.Errorf("needs %v, which never became ready", s.needs)})
+			}
+			return results, false
+		}
+
+		s := pending[next]
+		pending = append(pending[:next:next], pending[next+1:]...)
+		res := runStep(ctx, s)
+		results = append(results, res)
+		if res.status == startupFailed {
+			for _, s := range pending {
+				results = append(results, startupResult{name: s.name, status: startupNotStarted})
+			}
+			return results, false
+		}
+		done[s.name] = true
+	}
+	return results, true
+}
+
+// logStartupReport logs one line per step.
+func logStartupReport(results []startupResult) {
+	for _, r := range results {
+		line := fmt.Sprintf("startup: %-12s %-11s", r.name, r.status)
+		if r.attempts > 0 {
+			line += fmt.Sprintf(" %d attempt(s), %s", r.attempts, r.elapsed.Round(time.Millisecond))
+		}
+		if r.err != nil {
+			line += ": " + r.err.Error()
+		}
+		log.Print(line)
+	}
+}
+
+// checkSupabase makes sure Supabase Auth answers: the signing keys when
+// tokens are verified against them, otherwise the auth health endpoint.
+func checkSupabase(ctx context.Context) error {
+	if cfg.JWTVerification == jwtVerifyJWKS || cfg.JWTVerification == jwtVerifyBoth {
+		return jwks.prime(ctx)
+	}
+	if !supabaseConfigured() {
+		return errNotConfigured
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.SupabaseURL+"/auth/v1/health", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("apikey", cfg.SupabaseAnonKey)
+	resp, err := supabaseHTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("auth health returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// checkStorage makes sure each configured bucket answers with our
+// credentials; a missing object is a good answer.
+func checkStorage(ctx context.Context) error {
+	if storage == nil {
+		return errNotConfigured
+	}
+	for _, region := range storageRegionNames {
+		_, err := storageFor(region).HeadObject(ctx, "startup-check")
+		if err != nil && !errors.Is(err, ErrObjectNotFound) {
+			return fmt.Errorf("%s: %w", region, err)
+		}
+	}
+	return nil
+}
+
+// startWorkers starts the job worker and the periodic rollups.
+func startWorkers(ctx context.Context) {
+	// Background jobs (exports, ...) and periodic rollups
+	StartJobWorker(ctx)
+	StartPeriodic(ctx, uniquesRollupName, uniquesRollupInterval, rollupUniqueListeners)
+	StartPeriodic(ctx, dailyStatsRollupName, dailyStatsRollupInterval, rollupDailyStats)
+	StartPeriodic(ctx, trendingRollupName, trendingRollupInterval, rollupTrending)
+	StartPeriodic(ctx, alertEvalName, alertEvalInterval, evaluateAlerts)
+	StartPeriodic(ctx, platformStatsName, platformStatsInterval, refreshPlatformStats)
+	StartPeriodic(ctx, eventArchiveName, eventArchiveInterval, scheduleEventArchive)
+	StartPeriodic(ctx, milestonesName, milestonesInterval, checkMilestones)
+	StartPeriodic(ctx, badgesName, badgesInterval, awardBadges)
+	StartPeriodic(ctx, uploadSweepName, uploadSweepInterval, scheduleUploadSweep)
+	StartPeriodic(ctx, loginFailurePruneName, loginFailurePruneInterval, pruneLoginFailures)
+	StartPeriodic(ctx, similarArtistsName, similarArtistsInterval, rebuildArtistSimilarity)
+	StartPeriodic(ctx, sitemapName, sitemapInterval, refreshSitemap)
+	StartPeriodic(ctx, oauthTokenPruneName, oauthTokenPruneInterval, pruneOAuthTokens)
+	StartPeriodic(ctx, uploadTokenPruneName, uploadTokenPruneInterval, pruneUploadTokens)
+	StartPeriodic(ctx, trashPurgeName, trashPurgeInterval, purgeTrash)
+	StartPeriodic(ctx, weeklyPlaylistName, weeklyPlaylistInterval, scheduleWeeklyPlaylists)
+	StartPeriodic(ctx, presencePruneName, presencePruneInterval, prunePresence)
+	StartPeriodic(ctx, attachmentPruneName, attachmentPruneInterval, pruneAttachments)
+}
+
+// Startup loads config and the API schema, connects to Postgres, Supabase,
+// and storage, and starts the role cache listener and workers, exiting with
+// a report if a required step fails.
+func Startup(ctx context.Context) {
+	var poolCfg *pgxpool.Config
+	steps := []startupStep{
+		{name: "config", run: func(context.Context) error {
+			LoadConfig()
+			p, err := dbPoolConfig()
+			poolCfg = p
+			return err
+		}},
+		{name: "settings", needs: []string{"config"}, run: func(context.Context) error {
+			InitConsent()
+			InitAgeGating()
+			return nil
+		}},
+		{name: "database", needs: []string{"config"}, retry: true, run: func(ctx context.Context) error {
+			return connectDB(ctx, poolCfg)
+		}},
+		{name: "supabase", needs: []string{"config"}, retry: true, optional: true, run: checkSupabase},
+		{name: "storage", needs: []string{"config"}, retry: true, optional: true, run: func(ctx context.Context) error {
+			if storage == nil {
+				InitStorage()
+			}
+			return checkStorage(ctx)
+		}},
+		{name: "openapi", needs: []string{"config"}, run: func(context.Context) error {
+			return InitOpenAPIValidation()
+		}},
+		// A misconfigured integration leaves its feature off.
+		{name: "integrations", needs: []string{"config"}, optional: true, run: func(context.Context) error {
+			return errors.Join(InitEventSink(), InitImageModeration(), InitVirusScanning(), InitLiveStreaming())
+		}},
+		// Background work runs on ctx, not the step's deadline.
+		{name: "cache", needs: []string{"database"}, run: func(context.Context) error {
+			StartRoleInvalidation(ctx)
+			return nil
+		}},
+		{name: "workers", needs: []string{"database", "storage", "integrations"}, run: func(context.Context) error {
+			startWorkers(ctx)
+			return nil
+		}},
+	}
+
+	results, ok := runStartup(ctx, steps)
+	logStartupReport(results)
+	if !ok {
+		log.Print("❌ Startup failed, see the report above")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// song_daily_stats holds per-song, per-UTC-day engagement counts (bot
+// traffic excluded), maintained incrementally from the events table. Alerts
+// and other dashboards read these instead of scanning raw events.
+const (
+	dailyStatsRollupName     = "daily_stats"
+	dailyStatsRollupInterval = 5 * time.Minute
+	dailyStatsRollupBatch    = 50000
+)
+
+// rollupDailyStats processes events past the watermark until it catches up.
+func rollupDailyStats(ctx context.Context) error {
+	for {
+		n, err := rollupDailyStatsBatch(ctx)
+		if err != nil {
+			return err
+		}
+		if n < dailyStatsRollupBatch {
+			return nil
+		}
+	}
+}
+
+func rollupDailyStatsBatch(ctx context.Context) (int64, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	lastID, err := readWatermark(ctx, tx, dailyStatsRollupName)
+	if err != nil {
+		return 0, err
+	}
+
+	var upper, n int64
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(MAX(id), $1), COUNT(*)
+		FROM (SELECT id FROM events WHERE id > $1 ORDER BY id LIMIT $2) b;
+	`, lastID, dailyStatsRollupBatch).Scan(&upper, &n)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO song_daily_stats (song_id, day, plays, skips, likes, shares, comments, reviews, tips)
+		SELECT song_id,
+		       (occurred_at AT TIME ZONE 'UTC')::date,
+		       COUNT(*) FILTER (WHERE event_type = 'play'),
+		       COUNT(*) FILTER (WHERE event_type = 'skip'),
+		       COUNT(*) FILTER (WHERE event_type = 'like'),
+		       COUNT(*) FILTER (WHERE event_type = 'share'),
+		       COUNT(*) FILTER (WHERE event_type = 'comment'),
+		       COUNT(*) FILTER (WHERE event_type = 'review'),
+		       COUNT(*) FILTER (WHERE event_type = 'tip')
+		FROM events
+		WHERE id > $1 AND id <= $2 AND NOT is_bot
+		GROUP BY 1, 2
+		ON CONFLICT (song_id, day) DO UPDATE SET
+			plays      = song_daily_stats.plays + EXCLUDED.plays,
+			skips      = song_daily_stats.skips + EXCLUDED.skips,
+			likes      = song_daily_stats.likes + EXCLUDED.likes,
+			shares     = song_daily_stats.shares + EXCLUDED.shares,
+			comments   = song_daily_stats.comments + EXCLUDED.comments,
+			reviews    = song_daily_stats.reviews + EXCLUDED.reviews,
+			tips       = song_daily_stats.tips + EXCLUDED.tips,
+			updated_at = now();
+	`, lastID, upper)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := writeWatermark(ctx, tx, dailyStatsRollupName, upper); err != nil {
+		return 0, err
+	}
+	return n, tx.Commit(ctx)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Project members leave feedback on a stem at a point in its timeline.
+// The comments export as marker files a DAW can import, so producers see
+// the notes in their session: a Standard MIDI File of marker events (which
+// every DAW reads) or a REAPER-style marker CSV.
+const (
+	maxStemCommentBody = 2000
+	maxMarkerText      = 200
+
+	// With 500 ticks per quarter note at 120 BPM, one tick is exactly a
+	// millisecond, so comment offsets become tick positions unchanged.
+	midiTicksPerQuarter = 500
+	midiTempoMicros     = 500000
+)
+
+const stemCommentColumns = `c.id, c.stem_id, c.author_id::text, COALESCE(p.display_name, ''), c.at_ms, c.body, c.attachment_id,
+	c.created_at`
+
+const stemCommentFrom = `stem_comments c LEFT JOIN profiles p ON p.id = c.author_id`
+
+func scanStemComment(row pgx.Row) (StemComment, error) {
+	var sc StemComment
+	err := row.Scan(&sc.ID, &sc.StemID, &sc.AuthorID, &sc.AuthorName, &sc.AtMs, &sc.Body, &sc.AttachmentID, &sc.CreatedAt)
+	return sc, err
+}
+
+// RequireStemAccess is RequireProjectAccess for the project owning the stem
+// in :id. It stores "stem_id", "stem_filename", and "project_id".
+func RequireStemAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stemID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid stem id"})
+			return
+		}
+		var projectID int64
+		var filename string
+		err = db.QueryRow(context.Background(),
+			`SELECT project_id, filename FROM project_stems WHERE id = $1;`, stemID).Scan(&projectID, &filename)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "stem not found"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set("stem_id", stemID)
+		c.Set("stem_filename", filename)
+		c.Set("project_id", projectID)
+		authorizeProject(c, projectID)
+	}
+}
+
+func loadStemComments(ctx context.Context, stemID int64) ([]StemComment, error) {
+	rows, err := db.Query(ctx, `
+		SELECT `+stemCommentColumns+` FROM `+stemCommentFrom+`
+		WHERE c.stem_id = $1
+		ORDER BY c.at_ms, c.id;
+	`, stemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []StemComment{}
+	for rows.Next() {
+		sc, err := scanStemComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, sc)
+	}
+	return comments, rows.Err()
+}
+
+// withStemCommentAttachments fills in the attachment of each comment that
+// has one.
+func withStemCommentAttachments(ctx context.Context, comments []StemComment) error {
+	ids := []int64{}
+	for _, sc := range comments {
+		if sc.AttachmentID != nil {
+			ids = append(ids, *sc.AttachmentID)
+		}
+	}
+	attachments, err := loadAttachments(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i, sc := range comments {
+		if sc.AttachmentID != nil {
+			if a, ok := attachments[*sc.AttachmentID]; ok {
+				comments[i].Attachment = &a
+			}
+		}
+	}
+	return nil
+}
+
+// markerText is a comment as a one-line marker name, prefixed with its
+// author and cut to a length DAWs display. A comment that is only an
+// attachment is marked as such.
+func markerText(sc StemComment) string {
+	text := strings.Join(strings.Fields(sc.Body), " ")
+	if text == "" && sc.AttachmentID != nil {
+		text = "[attachment]"
+	}
+	if sc.AuthorName != "" {
+		text = sc.AuthorName + ": " + text
+	}
+	if len(text) > maxMarkerText {
+		cut := maxMarkerText
+		for !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut]
+	}
+	return text
+}
+
+// appendVarLen appends n as a MIDI variable-length quantity.
+func appendVarLen(b []byte, n uint32) []byte {
+	var tmp [5]byte
+	i := len(tmp) - 1
+	tmp[i] = byte(n & 0x7f)
+	for n >>= 7; n > 0; n >>= 7 {
+		i--
+		tmp[i] = byte(n&0x7f) | 0x80
+	}
+	return append(b, tmp[i:]...)
+}
+
+// appendMeta appends a meta event delta ticks after the previous event.
+func appendMeta(b []byte, delta uint32, kind byte, data []byte) []byte {
+	b = appendVarLen(b, delta)
+	b = append(b, 0xff, kind)
+	b = appendVarLen(b, uint32(len(data)))
+	return append(b, data...)
+}
+
+// stemMarkersMIDI renders comments as a format 0 Standard MIDI File with a
+// marker meta event at each comment's offset.
+func stemMarkersMIDI(trackName string, comments []StemComment) []byte {
+	var track []byte
+	track = appendMeta(track, 0, 0x03, []byte(trackName))
+	track = appendMeta(track, 0, 0x51, []byte{midiTempoMicros >> 16, midiTempoMicros >> 8 & 0xff, midiTempoMicros & 0xff})
+	var last int64
+	for _, sc := range comments {
+		track = appendMeta(track, uint32(sc.AtMs-last), 0x06, []byte(markerText(sc)))
+		last = sc.AtMs
+	}
+	track = appendMeta(track, 0, 0x2f, nil)
+
+	var out bytes.Buffer
+	out.WriteString("MThd")
+	binary.Write(&out, binary.BigEndian, []uint32{6})
+	binary.Write(&out, binary.BigEndian, []uint16{0, 1, midiTicksPerQuarter})
+	out.WriteString("MTrk")
+	binary.Write(&out, binary.BigEndian, uint32(len(track)))
+	out.Write(track)
+	return out.Bytes()
+}
+
+// formatMarkerTime renders ms as h:mm:ss.mmm.
+func formatMarkerTime(ms int64) string {
+	return fmt.Sprintf("%d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// RegisterStemCommentRoutes defines timestamped stem comments and their
+// export as DAW markers.
+func RegisterStemCommentRoutes(r *gin.Engine) {
+	// GET /stems/:id/comments — members, or guests holding a valid link; in timeline order
+	r.GET("/stems/:id/comments", RequireStemAccess(), func(c *gin.Context) {
+		ctx := context.Background()
+		comments, err := loadStemComments(ctx, c.GetInt64("stem_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := withStemCommentAttachments(ctx, comments); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, comments)
+	})
+
+	// POST /stems/:id/comments {"at_ms","body","attachment_id"?} — members only
+	r.POST("/stems/:id/comments", RequireStemAccess(), func(c *gin.Context) {
+		var body struct {
+			AtMs         *int64 `json:"at_ms"`
+			Body         string `json:"body"`
+			AttachmentID *int64 `json:"attachment_id"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if body.AtMs == nil || *body.AtMs < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "at_ms must be a non-negative offset into the stem"})
+			return
+		}
+		text, ok := messageInput(c, c.GetInt64("project_id"), body.Body, body.AttachmentID, maxStemCommentBody)
+		if !ok {
+			return
+		}
+
+		ctx := context.Background()
+		var id int64
+		if err := db.QueryRow(ctx, `
+			INSERT INTO stem_comments (stem_id, author_id, at_ms, body, attachment_id) VALUES ($1, $2, $3, $4, $5)
+			RETURNING id;
+		`, c.GetInt64("stem_id"), currentUserID(c), *body.AtMs, text, body.AttachmentID).Scan(&id); err != nil {
+			writeAttachError(c, err)
+			return
+		}
+		sc, err := scanStemComment(db.QueryRow(ctx,
+			`SELECT `+stemCommentColumns+` FROM `+stemCommentFrom+` WHERE c.id = $1;`, id))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		comments := []StemComment{sc}
+		if err := withStemCommentAttachments(ctx, comments); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, comments[0])
+	})
+
+	// GET /stems/:id/comments/export?format=midi-markers|csv — a marker file to import into a DAW
+	r.GET("/stems/:id/comments/export", RequireStemAccess(), func(c *gin.Context) {
+		format := c.DefaultQuery("format", "midi-markers")
+		if format != "midi-markers" && format != "csv" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be midi-markers or csv"})
+			return
+		}
+		comments, err := loadStemComments(context.Background(), c.GetInt64("stem_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		stem := c.GetString("stem_filename")
+		base := strings.TrimSuffix(stem, path.Ext(stem))
+		filename := strings.Map(func(r rune) rune {
+			if r == '"' || r == '\\' || r < 0x20 {
+				return '_'
+			}
+			return r
+		}, base) + "-markers"
+
+		if format == "csv" {
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+			w := csv.NewWriter(c.Writer)
+			w.Write([]string{"#", "Name", "Start", "End", "Length"})
+			for i, sc := range comments {
+				w.Write([]string{"M" + strconv.Itoa(i+1), markerText(sc), formatMarkerTime(sc.AtMs), "", ""})
+			}
+			w.Flush()
+			return
+		}
+
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.mid"`, filename))
+		c.Data(http.StatusOK, "audio/midi", stemMarkersMIDI(base, comments))
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Audio stems are probed after upload by a stem_probe job, which records
+// their duration, bitrate, sample rate, channels, and codec on the stem.
+// Non-audio stems (session files, artwork) are left alone.
+const stemProbeJob = "stem_probe"
+
+type stemProbePayload struct {
+	StemID int64 `json:"stem_id"`
+}
+
+func init() {
+	RegisterJobHandler(stemProbeJob, runStemProbe)
+}
+
+// queueStemProbe queues probing for an audio stem. The stem is already
+// attached, so a failure to queue is logged rather than failing the upload.
+func queueStemProbe(ctx context.Context, s ProjectStem) *int64 {
+	if !strings.HasPrefix(s.ContentType, "audio/") {
+		return nil
+	}
+	jobID, err := EnqueueJob(ctx, stemProbeJob, stemProbePayload{StemID: s.ID}, s.UploaderID)
+	if err != nil {
+		log.Printf("stem %d: failed to queue probe: %v", s.ID, err)
+		return nil
+	}
+	return &jobID
+}
+
+func runStemProbe(ctx context.Context, job *Job) (interface{}, error) {
+	var p stemProbePayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return nil, err
+	}
+	var key, region string
+	err := db.QueryRow(ctx, `SELECT file_key, storage_region FROM project_stems WHERE id = $1;`, p.StemID).Scan(&key, &region)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return gin.H{"skipped": true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if storage == nil {
+		return nil, fmt.Errorf("storage is not configured")
+	}
+
+	probe, err := probeAudio(ctx, storageFor(region).PresignGet(key, processingURLExpiry))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(ctx, `
+		UPDATE project_stems
+		SET duration_ms = $2, bit_rate = $3, sample_rate = $4, channels = $5, codec = $6, probed_at = now()
+		WHERE id = $1;
+	`, p.StemID, probe.DurationMs, probe.BitRate, probe.SampleRate, probe.Channels, probe.Codec); err != nil {
+		return nil, err
+	}
+	return gin.H{"duration_ms": probe.DurationMs, "bit_rate": probe.BitRate, "sample_rate": probe.SampleRate,
+		"channels": probe.Channels, "codec": probe.Codec}, nil
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SpacesClient talks to DigitalOcean Spaces (S3-compatible) using
+// path-style URLs and AWS Signature V4.
+type SpacesClient struct {
+	Endpoint  string // e.g. https://nyc3.digitaloceanspaces.com
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	HTTP      *http.Client
+
+	breakers sync.Map // route -> *storageBreaker
+}
+
+// ObjectInfo is the subset of object metadata returned by HeadObject.
+type ObjectInfo struct {
+	Size        int64
+	ETag        string
+	ContentType string
+}
+
+// ErrObjectNotFound is returned when a key does not exist in the bucket.
+var ErrObjectNotFound = errors.New("object not found")
+
+// regionHintHeader lets clients name where an upload should be stored:
+// a region slug ("ams3") or an area ("na", "eu", "ap").
+const regionHintHeader = "X-Region-Hint"
+
+var (
+	storage *SpacesClient
+
+	// storageRegions holds a client per configured region, including the
+	// default one; storageRegionNames lists them in a stable order.
+	storageRegions     = map[string]*SpacesClient{}
+	storageRegionNames []string
+)
+
+// spacesAreas groups DigitalOcean region slugs (without the number) by
+// area for region hints that don't name a configured region.
+var spacesAreas = map[string]string{
+	"nyc": "na", "sfo": "na", "tor": "na", "atl": "na",
+	"ams": "eu", "fra": "eu", "lon": "eu",
+	"sgp": "ap", "blr": "ap", "syd": "ap",
+}
+
+func spacesArea(region string) string {
+	if area, ok := spacesAreas[strings.TrimRight(region, "0123456789")]; ok {
+		return area
+	}
+	return region
+}
+
+// InitStorage configures the Spaces client from cfg. Storage is optional:
+// when it is not configured `storage` stays nil and file features return 503.
+func InitStorage() {
+	if cfg.SpacesBucket == "" || cfg.SpacesKey == "" || cfg.SpacesSecret == "" {
+		log.Println("⚠️  Spaces is not configured, file features are disabled")
+		return
+	}
+
+	endpoint := cfg.SpacesEndpoint
+	if endpoint == "" {
+		endpoint = "https://" + cfg.SpacesRegion + ".digitaloceanspaces.com"
+	}
+
+	storage = &SpacesClient{
+		Endpoint:  strings.TrimRight(endpoint, "/"),
+		Region:    cfg.SpacesRegion,
+		Bucket:    cfg.SpacesBucket,
+		AccessKey: cfg.SpacesKey,
+		SecretKey: cfg.SpacesSecret,
+		HTTP:      &http.Client{Timeout: 5 * time.Minute, Transport: storageTransport()},
+	}
+	storageRegions[cfg.SpacesRegion] = storage
+	storageRegionNames = []string{cfg.SpacesRegion}
+
+	for _, pair := range cfg.SpacesRegionBuckets {
+		region, bucket, ok := strings.Cut(pair, "=")
+		region, bucket = strings.ToLower(strings.TrimSpace(region)), strings.TrimSpace(bucket)
+		if !ok || region == "" || bucket == "" {
+			log.Printf("⚠️  ignoring SPACES_REGION_BUCKETS entry %q, want region=bucket", pair)
+			continue
+		}
+		if _, dup := storageRegions[region]; dup {
+			continue
+		}
+		storageRegions[region] = &SpacesClient{
+			Endpoint:  "https://" + region + ".digitaloceanspaces.com",
+			Region:    region,
+			Bucket:    bucket,
+			AccessKey: cfg.SpacesKey,
+			SecretKey: cfg.SpacesSecret,
+			HTTP:      storage.HTTP,
+		}
+		storageRegionNames = append(storageRegionNames, region)
+	}
+}
+
+// storageFor returns the client for the region stored on a file record.
+// Records from before regions were tracked have "", which is the default.
+func storageFor(region string) *SpacesClient {
+	if s, ok := storageRegions[region]; ok {
+		return s
+	}
+	return storage
+}
+
+// uploadRegion picks the region for a new upload from the X-Region-Hint
+// header: the named region if configured, else the first configured region
+// in the same area, else the default.
+func uploadRegion(c *gin.Context) string {
+	hint := strings.ToLower(strings.TrimSpace(c.GetHeader(regionHintHeader)))
+	if hint == "" {
+		return cfg.SpacesRegion
+	}
+	if _, ok := storageRegions[hint]; ok {
+		return hint
+	}
+	area := spacesArea(hint)
+	for _, region := range storageRegionNames {
+		if spacesArea(region) == area {
+			return region
+		}
+	}
+	return cfg.SpacesRegion
+}
+
+// PutObject uploads size bytes from body to key.
+func (s *SpacesClient) PutObject(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetObject returns a reader for the object's contents; the caller closes it.
+func (s *SpacesClient) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// HeadObject returns the object's size, ETag, and content type.
+func (s *SpacesClient) HeadObject(ctx context.Context, key string) (ObjectInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.objectURL(key), nil)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	resp.Body.Close()
+
+	size, _ := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	return ObjectInfo{
+		Size:        size,
+		ETag:        strings.Trim(resp.Header.Get("ETag"), `"`),
+		ContentType: resp.Header.Get("Content-Type"),
+	}, nil
+}
+
+// DeleteObject removes key; deleting a missing key is not an error.
+func (s *SpacesClient) DeleteObject(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// PresignGet returns a URL that allows anyone holding it to GET key until ttl elapses.
+func (s *SpacesClient) PresignGet(key string, ttl time.Duration) string {
+	return s.presign(http.MethodGet, key, ttl, nil)
+}
+
+// PresignDownload is PresignGet for a file the browser should save as
+// filename rather than play.
+func (s *SpacesClient) PresignDownload(key, filename string, ttl time.Duration) string {
+	return s.presign(http.MethodGet, key, ttl, url.Values{
+		"response-content-disposition": {mime.FormatMediaType("attachment", map[string]string{"filename": filename})},
+	})
+}
+
+// PresignedPost is a browser-style form upload: POST the fields, then the
+// file as the last "file" field, to URL.
+type PresignedPost struct {
+	URL    string            `json:"url"`
+	Fields map[string]string `json:"fields"`
+}
+
+// PresignPost returns a form upload of key that storage itself holds to the
+// policy: the key must start with prefix, the body must be contentType and
+// at most maxBytes, and the form stops working after ttl.
+func (s *SpacesClient) PresignPost(key, prefix, contentType string, maxBytes int64, ttl time.Duration) (PresignedPost, error) {
+	now := time.Now().UTC()
+	credential := s.AccessKey + "/" + s.scope(now)
+	date := now.Format("20060102T150405Z")
+
+	policy, err := json.Marshal(map[string]interface{}{
+		"expiration": now.Add(ttl).Format("2006-01-02T15:04:05.000Z"),
+		"conditions": []interface{}{
+			map[string]string{"bucket": s.Bucket},
+			[]interface{}{"starts-with", "$key", prefix},
+			map[string]string{"Content-Type": contentType},
+			[]interface{}{"content-length-range", 1, maxBytes},
+			map[string]string{"x-amz-algorithm": "AWS4-HMAC-SHA256"},
+			map[string]string{"x-amz-credential": credential},
+			map[string]string{"x-amz-date": date},
+		},
+	})
+	if err != nil {
+		return PresignedPost{}, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(policy)
+
+	return PresignedPost{
+		URL: s.Endpoint + "/" + s.Bucket,
+		Fields: map[string]string{
+			"key":              key,
+			"Content-Type":     contentType,
+			"policy":           encoded,
+			"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+			"x-amz-credential": credential,
+			"x-amz-date":       date,
+			"x-amz-signature":  hex.EncodeToString(hmacSHA256(s.signingKey(now), encoded)),
+		},
+	}, nil
+}
+
+// CreateMultipartUpload starts a multipart upload to key and returns its ID.
+func (s *SpacesClient) CreateMultipartUpload(ctx context.Context, key, contentType string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.objectURL(key)+"?uploads=", nil)
+	if err != nil {
+		return "", err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if result.UploadID == "" {
+		return "", fmt.Errorf("spaces: no upload ID for %s", key)
+	}
+	return result.UploadID, nil
+}
+
+// UploadPart uploads size bytes from body as part number part and returns
+// the part's ETag.
+func (s *SpacesClient) UploadPart(ctx context.Context, key, uploadID string, part int, body io.Reader, size int64) (string, error) {
+	q := url.Values{"partNumber": {strconv.Itoa(part)}, "uploadId": {uploadID}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key)+"?"+q.Encode(), body)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+
+	resp, err := s.do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
+
+// CompleteMultipartUpload assembles the parts, given as ETags in part order
+// starting at part 1.
+func (s *SpacesClient) CompleteMultipartUpload(ctx context.Context, key, uploadID string, etags []string) error {
+	var b strings.Builder
+	b.WriteString("<CompleteMultipartUpload>")
+	for i, etag := range etags {
+		fmt.Fprintf(&b, `<Part><PartNumber>%d</PartNumber><ETag>"%s"</ETag></Part>`, i+1, etag)
+	}
+	b.WriteString("</CompleteMultipartUpload>")
+
+	q := url.Values{"uploadId": {uploadID}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.objectURL(key)+"?"+q.Encode(), strings.NewReader(b.String()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/xml")
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The request can fail after a 200 has been sent; the body says so.
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.XMLName.Local == "Error" {
+		return fmt.Errorf("spaces complete %s: %s: %s", key, result.Code, result.Message)
+	}
+	return nil
+}
+
+// AbortMultipartUpload discards an upload and its parts; aborting one that
+// no longer exists is not an error.
+func (s *SpacesClient) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	q := url.Values{"uploadId": {uploadID}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key)+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *SpacesClient) objectURL(key string) string {
+	return s.Endpoint + "/" + s.Bucket + "/" + encodePath(key)
+}
+
+// send signs and sends req once, turning non-2xx responses into errors.
+func (s *SpacesClient) send(req *http.Request) (*http.Response, error) {
+	now := time.Now().UTC()
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+
+	signedHeaders, canonicalHeaders := canonicalHeaderList(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders,
+		signedHeaders,
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	scope := s.scope(now)
+	signature := s.sign(now, scope, canonicalRequest)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature,
+	))
+
+	resp, err := s.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrObjectNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, storageStatusError{status: resp.StatusCode,
+			msg: fmt.Sprintf("spaces %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, msg)}
+	}
+	return resp, nil
+}
+
+func (s *SpacesClient) presign(method, key string, ttl time.Duration, params url.Values) string {
+	now := time.Now().UTC()
+	scope := s.scope(now)
+	u, _ := url.Parse(s.objectURL(key))
+
+	q := url.Values{}
+	for k, v := range params {
+		q[k] = v
+	}
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", s.AccessKey+"/"+scope)
+	q.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	q.Set("X-Amz-Expires", strconv.Itoa(int(ttl.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+	// url.Values.Encode uses '+' for spaces; SigV4 requires %20.
+	query := strings.ReplaceAll(q.Encode(), "+", "%20")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		query,
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	return u.String() + "?" + query + "&X-Amz-Signature=" + s.sign(now, scope, canonicalRequest)
+}
+
+func (s *SpacesClient) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.Region + "/s3/aws4_request"
+}
+
+func (s *SpacesClient) sign(t time.Time, scope, canonicalRequest string) string {
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + t.Format("20060102T150405Z") + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+	return hex.EncodeToString(hmacSHA256(s.signingKey(t), stringToSign))
+}
+
+func (s *SpacesClient) signingKey(t time.Time) []byte {
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalHeaderList signs host plus every Content-Type / X-Amz-* header.
+func canonicalHeaderList(req *http.Request) (signed, canonical string) {
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "content-type" || strings.HasPrefix(lk, "x-amz-") {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, k := range names {
+		b.WriteString(k + ":" + headers[k] + "\n")
+	}
+	return strings.Join(names, ";"), b.String()
+}
+
+// encodePath percent-encodes each segment of an object key, keeping '/'.
+func encodePath(key string) string {
+	parts := strings.Split(key, "/")
+	for i, p := range parts {
+		parts[i] = strings.ReplaceAll(url.PathEscape(p), "+", "%2B")
+	}
+	return strings.Join(parts, "/")
+}
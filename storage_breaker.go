@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Calls to Spaces are bounded and fail fast during an outage. The client's
+// transport gives up on a connection, handshake, or response that takes
+// too long; idempotent calls whose body can be replayed are retried with
+// backoff on network errors, 5xx, and 429; and each region keeps a circuit
+// breaker per route (GET, PUT, HEAD, DELETE, multipart). After
+// storageBreakerThreshold consecutive failures a route opens for
+// storageBreakerCooldown: calls fail at once with ErrStorageUnavailable,
+// which handlers turn into a 503 with Retry-After, instead of each request
+// waiting out its deadline. One probe is then let through; success closes
+// the route again.
+const (
+	storageDialTimeout      = 5 * time.Second
+	storageHeaderTimeout    = 20 * time.Second
+	storageMaxAttempts      = 3
+	storageRetryBase        = 250 * time.Millisecond
+	storageBreakerThreshold = 5
+	storageBreakerCooldown  = 30 * time.Second
+)
+
+// ErrStorageUnavailable is returned while a storage route's breaker is open.
+var ErrStorageUnavailable = errors.New("storage is temporarily unavailable")
+
+type storageUnavailableError struct {
+	route      string
+	retryAfter time.Duration
+}
+
+func (e storageUnavailableError) Error() string {
+	return fmt.Sprintf("%v (%s), retry in %s", ErrStorageUnavailable, e.route, e.retryAfter.Round(time.Second))
+}
+
+func (e storageUnavailableError) Is(target error) bool { return target == ErrStorageUnavailable }
+
+// storageStatusError is a non-2xx response other than 404.
+type storageStatusError struct {
+	status int
+	msg    string
+}
+
+func (e storageStatusError) Error() string { return e.msg }
+
+// storageTransport is the HTTP transport for Spaces clients.
+func storageTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = (&net.Dialer{Timeout: storageDialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	t.TLSHandshakeTimeout = storageDialTimeout
+	t.ResponseHeaderTimeout = storageHeaderTimeout
+	return t
+}
+
+type storageBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow reports whether a call may go ahead, or how long until it may.
+func (b *storageBreaker) allow() (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < storageBreakerThreshold {
+		return 0, true
+	}
+	if wait := time.Until(b.openUntil); wait > 0 {
+		return wait, false
+	}
+	if b.probing {
+		return storageBreakerCooldown, false
+	}
+	b.probing = true
+	return 0, true
+}
+
+// record notes how a call went; failed is nil when the outcome says
+// nothing about storage's health, e.g. the caller gave up.
+func (b *storageBreaker) record(failed *bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	switch {
+	case failed == nil:
+	case !*failed:
+		b.failures = 0
+	default:
+		b.failures++
+		if b.failures >= storageBreakerThreshold {
+			b.openUntil = time.Now().Add(storageBreakerCooldown)
+		}
+	}
+}
+
+// storageRoute names the breaker a request goes through.
+func storageRoute(req *http.Request) string {
+	q := req.URL.Query()
+	if q.Has("uploads") || q.Has("uploadId") {
+		return "multipart"
+	}
+	return req.Method
+}
+
+func (s *SpacesClient) breaker(route string) *storageBreaker {
+	b, _ := s.breakers.LoadOrStore(route, &storageBreaker{})
+	return b.(*storageBreaker)
+}
+
+// retryableStorageError reports whether err might go away on its own.
+func retryableStorageError(err error) bool {
+	var status storageStatusError
+	if errors.As(err, &status) {
+		return status.status >= 500 || status.status == http.StatusTooManyRequests
+	}
+	return !errors.Is(err, ErrObjectNotFound) && !errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded)
+}
+
+// do sends req through its route's breaker, retrying while that helps.
+func (s *SpacesClient) do(req *http.Request) (*http.Response, error) {
+	route := storageRoute(req)
+	b := s.breaker(route)
+	if wait, ok := b.allow(); !ok {
+		return nil, storageUnavailableError{route: s.Region + " " + route, retryAfter: wait}
+	}
+
+	ctx := req.Context()
+	replayable := req.Body == nil || req.GetBody != nil
+	var (
+		resp *http.Response
+		err  error
+	)
+	for attempt := 1; ; attempt++ {
+		resp, err = s.send(req)
+		if err == nil || !retryableStorageError(err) || !replayable || attempt == storageMaxAttempts {
+			break
+		}
+		backoff := storageRetryBase << (attempt - 1)
+		backoff += time.Duration(rand.Int63n(int64(backoff)))
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if req.GetBody != nil {
+			body, gerr := req.GetBody()
+			if gerr != nil {
+				break
+			}
+			req.Body = body
+		}
+	}
+
+	// Missing objects and refused requests are storage working as intended.
+	var status storageStatusError
+	failed := err != nil && !errors.Is(err, ErrObjectNotFound) &&
+		!(errors.As(err, &status) && status.status < 500 && status.status != http.StatusTooManyRequests)
+	if ctx.Err() != nil {
+		b.record(nil)
+	} else {
+		b.record(&failed)
+	}
+	return resp, err
+}
+
+// writeStorageError writes the response for a failed storage call: 503
+// with Retry-After while storage is unavailable, otherwise status.
+func writeStorageError(c *gin.Context, status int, err error) {
+	var unavailable storageUnavailableError
+	if errors.As(err, &unavailable) {
+		secs := int(unavailable.retryAfter.Seconds()) + 1
+		c.Header("Retry-After", strconv.Itoa(secs))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is temporarily unavailable, try again shortly",
+			"retry_after": secs})
+		return
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
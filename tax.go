@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Tips are charged VAT/GST on top of the tip where the payer's country
+// requires it for digital services. The country comes from the billing
+// country the app sends, or else the CDN's GeoIP header. POST
+// /tips/payment-intent quotes the tax and creates the PaymentIntent for the
+// total; POST /tips with that PaymentIntent copies the quote's lines to
+// tip_tax_lines. Tax never touches the artist's balance, which is computed
+// on the tip alone.
+const (
+	tipCurrency        = "usd"
+	countrySourceBill  = "billing"
+	countrySourceGeoIP = "geoip"
+	countrySourceNone  = "unknown"
+)
+
+type taxRule struct {
+	kind string
+	rate float64 // percent
+}
+
+// digitalTaxRules are the standard rates on digital services by payer
+// country. Keep this current when rates change.
+var digitalTaxRules = map[string]taxRule{
+	"AT": {"VAT", 20}, "BE": {"VAT", 21}, "BG": {"VAT", 20}, "HR": {"VAT", 25}, "CY": {"VAT", 19},
+	"CZ": {"VAT", 21}, "DK": {"VAT", 25}, "EE": {"VAT", 24}, "FI": {"VAT", 25.5}, "FR": {"VAT", 20},
+	"DE": {"VAT", 19}, "GR": {"VAT", 24}, "HU": {"VAT", 27}, "IE": {"VAT", 23}, "IT": {"VAT", 22},
+	"LV": {"VAT", 21}, "LT": {"VAT", 21}, "LU": {"VAT", 17}, "MT": {"VAT", 18}, "NL": {"VAT", 21},
+	"PL": {"VAT", 23}, "PT": {"VAT", 23}, "RO": {"VAT", 21}, "SK": {"VAT", 23}, "SI": {"VAT", 22},
+	"ES": {"VAT", 21}, "SE": {"VAT", 25},
+	"GB": {"VAT", 20}, "NO": {"VAT", 25}, "CH": {"VAT", 8.1}, "IS": {"VAT", 24},
+	"AU": {"GST", 10}, "NZ": {"GST", 15}, "SG": {"GST", 9}, "CA": {"GST", 5}, "IN": {"GST", 18},
+	"JP": {"JCT", 10},
+}
+
+// payerCountry returns the tip payer's country and where it came from.
+func payerCountry(c *gin.Context, billing string) (string, string) {
+	if country := strings.ToUpper(strings.TrimSpace(billing)); regionPattern.MatchString(country) {
+		return country, countrySourceBill
+	}
+	// CDNs send XX for unknown and T1 for Tor.
+	if country := strings.ToUpper(c.GetHeader(cfg.GeoIPCountryHeader)); regionPattern.MatchString(country) && country != "XX" {
+		return country, countrySourceGeoIP
+	}
+	return "", countrySourceNone
+}
+
+// tipTaxLines returns the tax owed on a tip of amount from country.
+func tipTaxLines(amount float64, country string) []TaxLine {
+	rule, ok := digitalTaxRules[country]
+	if !ok {
+		return []TaxLine{}
+	}
+	return []TaxLine{{
+		Kind:    rule.kind,
+		Country: country,
+		Rate:    rule.rate,
+		Amount:  math.Round(amount*rule.rate) / 100,
+	}}
+}
+
+func sumTaxLines(lines []TaxLine) float64 {
+	var total float64
+	for _, l := range lines {
+		total += l.Amount
+	}
+	return math.Round(total*100) / 100
+}
+
+// tipTaxQuote is the tax worked out when a tip's PaymentIntent was created.
+type tipTaxQuote struct {
+	SongID   int64
+	SenderID string
+	Amount   float64
+	Currency string
+	Lines    []TaxLine
+}
+
+// loadTipTaxQuote returns the quote for a PaymentIntent, or nil for tips
+// paid without one.
+func loadTipTaxQuote(ctx context.Context, paymentIntentID string) (*tipTaxQuote, error) {
+	var q tipTaxQuote
+	var lines []byte
+	err := db.QueryRow(ctx, `
+		SELECT song_id, sender_id::text, amount::float8, currency, lines
+		FROM tip_tax_quotes WHERE payment_intent_id = $1;
+	`, paymentIntentID).Scan(&q.SongID, &q.SenderID, &q.Amount, &q.Currency, &lines)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	q.Lines = []TaxLine{}
+	json.Unmarshal(lines, &q.Lines)
+	return &q, nil
+}
+
+// insertTipTaxLines records a tip's tax lines in the tax ledger.
+func insertTipTaxLines(ctx context.Context, tx pgx.Tx, tipID int64, lines []TaxLine) error {
+	for _, l := range lines {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO tip_tax_lines (tip_id, kind, country, rate, amount)
+			VALUES ($1, $2, $3, $4, $5);
+		`, tipID, l.Kind, l.Country, l.Rate, l.Amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTipQuote matches a recorded tip against its PaymentIntent's quote.
+func checkTipQuote(t Tip, q *tipTaxQuote) error {
+	if q.SongID != t.SongID || q.SenderID != t.SenderID {
+		return fmt.Errorf("payment_intent_id was created for a different tip")
+	}
+	if math.Abs(q.Amount-t.Amount) >= 0.005 {
+		return fmt.Errorf("amount must match the %.2f quoted for this payment", q.Amount)
+	}
+	return nil
+}
+
+// checkTipPayment makes sure a tip's PaymentIntent has been paid, by its
+// sender, for the quoted tip and its tax.
+func checkTipPayment(t Tip, q *tipTaxQuote, pi stripePaymentIntent) error {
+	if pi.Status != "succeeded" {
+		return fmt.Errorf("payment has not succeeded (status %q)", pi.Status)
+	}
+	if pi.Metadata["sender_id"] != t.SenderID || pi.Metadata["song_id"] != strconv.FormatInt(t.SongID, 10) {
+		return fmt.Errorf("payment_intent_id was created for a different tip")
+	}
+	total := math.Round((q.Amount+sumTaxLines(q.Lines))*100) / 100
+	if pi.AmountReceived < toCents(total) {
+		return fmt.Errorf("payment received is less than the %.2f charged for this tip", total)
+	}
+	return nil
+}
+
+// RegisterTaxRoutes defines tip PaymentIntent creation with tax and tip
+// receipts.
+func RegisterTaxRoutes(r *gin.Engine) {
+	// POST /tips/payment-intent {"song_id":1,"amount":5,"billing_country":"DE"}
+	// Returns the tax lines, the total to charge, and the PaymentIntent "client_secret".
+	r.POST("/tips/payment-intent", RequireAuth(), func(c *gin.Context) {
+		if cfg.StripeSecretKey == "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "payments are not configured"})
+			return
+		}
+		var body struct {
+			SongID         int64   `json:"song_id"`
+			Amount         float64 `json:"amount"`
+			BillingCountry string  `json:"billing_country"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		body.Amount = math.Round(body.Amount*100) / 100
+		if body.Amount < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be at least 1.00"})
+			return
+		}
+		if abortIfMinor(c, ageBracket(c), "tipping") {
+			return
+		}
+		ctx := c.Request.Context()
+
+		// Songs backing a live session take tips once it has started.
+		var published bool
+		err := db.QueryRow(ctx, `
+			SELECT published OR EXISTS (
+				SELECT 1 FROM live_sessions WHERE song_id = songs.id AND status <> 'scheduled'
+			) FROM songs WHERE id = $1;
+		`, body.SongID).Scan(&published)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && !published) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "song not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		country, source := payerCountry(c, body.BillingCountry)
+		lines := tipTaxLines(body.Amount, country)
+		tax := sumTaxLines(lines)
+		total := math.Round((body.Amount+tax)*100) / 100
+
+		var pi struct {
+			ID           string `json:"id"`
+			ClientSecret string `json:"client_secret"`
+		}
+		err = stripePost(ctx, "/payment_intents", url.Values{
+			"amount":                             {strconv.FormatInt(toCents(total), 10)},
+			"currency":                           {tipCurrency},
+			"automatic_payment_methods[enabled]": {"true"},
+			"metadata[song_id]":                  {strconv.FormatInt(body.SongID, 10)},
+			"metadata[sender_id]":                {currentUserID(c)},
+			"metadata[tip_amount]":               {strconv.FormatFloat(body.Amount, 'f', 2, 64)},
+			"metadata[tax_amount]":               {strconv.FormatFloat(tax, 'f', 2, 64)},
+			"metadata[tax_country]":              {country},
+		}, "", &pi)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+
+		linesJSON, _ := json.Marshal(lines)
+		if _, err := db.Exec(ctx, `
+			INSERT INTO tip_tax_quotes (payment_intent_id, song_id, sender_id, amount, tax_amount, currency, country, country_source, lines)
+			VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9);
+		`, pi.ID, body.SongID, currentUserID(c), body.Amount, tax, tipCurrency, country, source, linesJSON); err != nil {
+			log.Printf("tip payment intent %s: failed to save tax quote: %v", pi.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"payment_intent_id": pi.ID,
+			"client_secret":     pi.ClientSecret,
+			"amount":            body.Amount,
+			"tax_lines":         lines,
+			"total":             total,
+			"currency":          tipCurrency,
+			"country":           country,
+		})
+	})
+
+	// GET /tips/:id/receipt — the sender's receipt, with tax lines
+	r.GET("/tips/:id/receipt", RequireAuth(), func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tip id"})
+			return
+		}
+		ctx := c.Request.Context()
+
+		var rc TipReceipt
+		var senderID string
+		var currency *string
+		err = db.QueryRow(ctx, `
+			SELECT t.id, t.song_id, s.title, t.amount::float8, t.sender_id::text, t.created_at, q.currency
+			FROM tips t
+			LEFT JOIN songs s ON s.id = t.song_id
+			LEFT JOIN tip_tax_quotes q ON q.payment_intent_id = t.payment_intent_id
+			WHERE t.id = $1;
+		`, id).Scan(&rc.TipID, &rc.SongID, &rc.SongTitle, &rc.Amount, &senderID, &rc.PaidAt, &currency)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && senderID != currentUserID(c)) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "tip not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		rows, err := db.Query(ctx, `
+			SELECT kind, country, rate::float8, amount::float8 FROM tip_tax_lines WHERE tip_id = $1 ORDER BY id;
+		`, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+		rc.TaxLines = []TaxLine{}
+		for rows.Next() {
+			var l TaxLine
+			if err := rows.Scan(&l.Kind, &l.Country, &l.Rate, &l.Amount); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			rc.TaxLines = append(rc.TaxLines, l)
+		}
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		rc.Currency = tipCurrency
+		if currency != nil {
+			rc.Currency = *currency
+		}
+		rc.Total = math.Round((rc.Amount+sumTaxLines(rc.TaxLines))*100) / 100
+		c.JSON(http.StatusOK, rc)
+	})
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Artists can delegate parts of their account to a manager. The manager
+// calls the usual endpoints with X-Act-As: <artist id>; routes wrapped in
+// RequireTeamScope then run as the artist, with the manager kept as
+// "actor_id". Routes without RequireTeamScope ignore the header, so
+// anything not listed in teamScopes (payouts, webhooks, API keys, the team
+// itself) can't be delegated.
+const (
+	actAsHeader         = "X-Act-As"
+	scopeAnalyticsRead  = "analytics:read"
+	scopeCommentsReply  = "comments:respond"
+	scopeReleasesManage = "releases:manage"
+)
+
+var teamScopes = map[string]bool{
+	scopeAnalyticsRead:  true,
+	scopeCommentsReply:  true,
+	scopeReleasesManage: true,
+}
+
+const teamMemberColumns = `artist_id::text, member_id::text, scopes, created_at, updated_at`
+
+func scanTeamMember(row pgx.Row) (TeamMember, error) {
+	var m TeamMember
+	err := row.Scan(&m.ArtistID, &m.MemberID, &m.Scopes, &m.CreatedAt, &m.UpdatedAt)
+	return m, err
+}
+
+func loadTeamMembers(ctx context.Context, where string, userID string) ([]TeamMember, error) {
+	rows, err := db.Query(ctx,
+		`SELECT `+teamMemberColumns+` FROM team_members WHERE `+where+` = $1 ORDER BY created_at;`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []TeamMember{}
+	for rows.Next() {
+		m, err := scanTeamMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// RequireTeamScope lets a team member act for the artist named in X-Act-As
+// when they hold scope. Without the header the caller acts as themselves.
+// It must run after RequireAuth.
+func RequireTeamScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		artistID := c.GetHeader(actAsHeader)
+		if artistID == "" || artistID == currentUserID(c) {
+			c.Next()
+			return
+		}
+
+		var allowed bool
+		err := db.QueryRow(context.Background(), `
+			SELECT EXISTS (
+				SELECT 1 FROM team_members
+				WHERE artist_id::text = $1 AND member_id = $2 AND $3 = ANY (scopes)
+			);
+		`, artistID, currentUserID(c), scope).Scan(&allowed)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !allowed {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not permitted to act for this artist", "scope": scope})
+			return
+		}
+
+		c.Set("actor_id", currentUserID(c))
+		c.Set("user_id", artistID)
+		c.Next()
+	}
+}
+
+// actorID is who actually made the request: the team member when acting
+// for an artist, otherwise the caller.
+func actorID(c *gin.Context) string {
+	if id := c.GetString("actor_id"); id != "" {
+		return id
+	}
+	return currentUserID(c)
+}
+
+// RegisterTeamRoutes defines /me/team.
+func RegisterTeamRoutes(r *gin.Engine) {
+	me := r.Group("/me/team", RequireAuth())
+
+	// GET /me/team — accounts the caller has delegated to
+	me.GET("", func(c *gin.Context) {
+		members, err := loadTeamMembers(context.Background(), "artist_id", currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, members)
+	})
+
+	// GET /me/team/artists — artists the caller can act for
+	me.GET("/artists", func(c *gin.Context) {
+		members, err := loadTeamMembers(context.Background(), "member_id", currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, members)
+	})
+
+	// PUT /me/team/:member_id — {"scopes":["analytics:read","releases:manage"]}
+	me.PUT("/:member_id", func(c *gin.Context) {
+		memberID := c.Param("member_id")
+		if memberID == currentUserID(c) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "you can't add yourself to your team"})
+			return
+		}
+
+		var body struct {
+			Scopes []string `json:"scopes"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if len(body.Scopes) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "scopes is required"})
+			return
+		}
+		seen := map[string]bool{}
+		scopes := []string{}
+		for _, s := range body.Scopes {
+			if !teamScopes[s] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported scope %q", s)})
+				return
+			}
+			if !seen[s] {
+				seen[s] = true
+				scopes = append(scopes, s)
+			}
+		}
+		sort.Strings(scopes)
+
+		var exists bool
+		if err := db.QueryRow(context.Background(),
+			`SELECT EXISTS (SELECT 1 FROM profiles WHERE id::text = $1);`, memberID).Scan(&exists); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		m, err := scanTeamMember(db.QueryRow(context.Background(), `
+			INSERT INTO team_members (artist_id, member_id, scopes)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (artist_id, member_id) DO UPDATE SET scopes = EXCLUDED.scopes, updated_at = now()
+			RETURNING `+teamMemberColumns+`;
+		`, currentUserID(c), memberID, scopes))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, m)
+	})
+
+	// DELETE /me/team/:member_id — revokes all access
+	me.DELETE("/:member_id", func(c *gin.Context) {
+		tag, err := db.Exec(context.Background(),
+			`DELETE FROM team_members WHERE artist_id = $1 AND member_id::text = $2;`,
+			currentUserID(c), c.Param("member_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "team member not found"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Tips that look like fraud are held for review instead of being credited.
+// A held tip is out of the artist's balance, fires no tip.confirmed webhook,
+// and counts no engagement until an admin approves it. Denying a held tip
+// drops it; denying one that already cleared reverses it. Each decision is
+// written to tip_ledger with its effect on the balance.
+const (
+	tipReviewAmount      = 200.0
+	tipVelocityWindow    = 10 * time.Minute
+	tipVelocityCount     = 5
+	tipDailyAmount       = 1000.0
+	maxTipReviewPage     = 100
+	tipReasonLargeAmount = "large_amount"
+	tipReasonVelocity    = "sender_velocity"
+	tipReasonDailyVolume = "sender_daily_volume"
+	tipReasonSelfTip     = "self_tip"
+	tipStatusCleared     = "cleared"
+	tipStatusHeld        = "held"
+	tipStatusDenied      = "denied"
+)
+
+var tipReviewStatuses = map[string]bool{tipStatusCleared: true, tipStatusHeld: true, tipStatusDenied: true}
+
+// tipRiskReasons returns the heuristics a new tip trips, if any.
+func tipRiskReasons(ctx context.Context, t Tip) ([]string, error) {
+	var (
+		artistID    *string
+		recentCount int
+		dailyAmount float64
+	)
+	err := db.QueryRow(ctx, `
+		SELECT (SELECT artist_id::text FROM songs WHERE id = $1),
+		       (SELECT COUNT(*) FROM tips WHERE sender_id = $2 AND created_at > $3),
+		       (SELECT COALESCE(SUM(amount), 0)::float8 FROM tips WHERE sender_id = $2 AND created_at > $4);
+	`, t.SongID, t.SenderID, time.Now().Add(-tipVelocityWindow), time.Now().Add(-24*time.Hour),
+	).Scan(&artistID, &recentCount, &dailyAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	reasons := []string{}
+	if t.Amount >= tipReviewAmount {
+		reasons = append(reasons, tipReasonLargeAmount)
+	}
+	if recentCount >= tipVelocityCount {
+		reasons = append(reasons, tipReasonVelocity)
+	}
+	if dailyAmount+t.Amount > tipDailyAmount {
+		reasons = append(reasons, tipReasonDailyVolume)
+	}
+	if artistID != nil && *artistID == t.SenderID {
+		reasons = append(reasons, tipReasonSelfTip)
+	}
+	return reasons, nil
+}
+
+// appendTipConfirmed logs tip.confirmed against the tip's song.
+func appendTipConfirmed(ctx context.Context, tx pgx.Tx, t Tip, actorID string) error {
+	return appendDomainEvent(ctx, tx, domainTipConfirmed, "song", strconv.FormatInt(t.SongID, 10), actorID,
+		gin.H{"tip_id": t.ID, "sender_id": t.SenderID, "amount": t.Amount})
+}
+
+// creditTip does what a cleared tip triggers: the engagement event and the
+// artist's tip.confirmed webhook.
+func creditTip(t Tip) {
+	recordServerEvent(t.SongID, t.SenderID, "tip")
+	emitTipConfirmed(t)
+}
+
+const tipReviewColumns = `t.id, t.song_id, t.sender_id::text, t.amount::float8, t.review_status, t.created_at,
+	s.artist_id::text, t.review_reasons, t.reviewed_by::text, t.reviewed_at`
+
+func scanTipReview(row pgx.Row) (TipReview, error) {
+	var t TipReview
+	err := row.Scan(&t.ID, &t.SongID, &t.SenderID, &t.Amount, &t.ReviewStatus, &t.CreatedAt,
+		&t.ArtistID, &t.Reasons, &t.ReviewedBy, &t.ReviewedAt)
+	return t, err
+}
+
+// reviewTip moves a tip to cleared or denied and writes the ledger entry,
+// writing the error response when the tip can't make that move.
+func reviewTip(c *gin.Context, to string) (TipReview, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tip id"})
+		return TipReview{}, false
+	}
+	var body struct {
+		Note string `json:"note"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return TipReview{}, false
+		}
+	}
+	var note *string
+	if n := strings.TrimSpace(body.Note); n != "" {
+		note = &n
+	}
+
+	ctx := context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return TipReview{}, false
+	}
+	defer tx.Rollback(ctx)
+
+	t, err := scanTipReview(tx.QueryRow(ctx, `
+		SELECT `+tipReviewColumns+`
+		FROM tips t LEFT JOIN songs s ON s.id = t.song_id
+		WHERE t.id = $1
+		FOR UPDATE OF t;
+	`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tip not found"})
+		return TipReview{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return TipReview{}, false
+	}
+
+	_, net := tipFee(t.Amount)
+	var action string
+	var amount float64
+	switch {
+	case to == tipStatusCleared && t.ReviewStatus == tipStatusHeld:
+		action, amount = "released", net
+	case to == tipStatusDenied && t.ReviewStatus == tipStatusHeld:
+		action, amount = "denied", 0
+	case to == tipStatusDenied && t.ReviewStatus == tipStatusCleared:
+		action, amount = "reversed", -net
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": "tip is already " + t.ReviewStatus})
+		return TipReview{}, false
+	}
+
+	err = tx.QueryRow(ctx, `
+		UPDATE tips SET review_status = $2, reviewed_by = $3, reviewed_at = now()
+		WHERE id = $1
+		RETURNING review_status, reviewed_by::text, reviewed_at;
+	`, t.ID, to, currentUserID(c)).Scan(&t.ReviewStatus, &t.ReviewedBy, &t.ReviewedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return TipReview{}, false
+	}
+	// Tips on songs without an artist have no balance to adjust.
+	if t.ArtistID != nil {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO tip_ledger (tip_id, artist_id, action, amount, admin_id, note)
+			VALUES ($1, $2, $3, $4, $5, $6);
+		`, t.ID, *t.ArtistID, action, amount, currentUserID(c), note)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return TipReview{}, false
+		}
+	}
+	if action == "released" {
+		if err := appendTipConfirmed(ctx, tx, t.Tip, currentUserID(c)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return TipReview{}, false
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return TipReview{}, false
+	}
+	return t, true
+}
+
+// RegisterTipRoutes defines tipping, artist balances, and the admin review queue.
+func RegisterTipRoutes(r *gin.Engine) {
+	// POST /tips — {"song_id":1,"amount":5,"payment_intent_id":"pi_..."}; the PaymentIntent comes from
+	// /tips/payment-intent and must have succeeded. Suspicious tips come back "held".
+	r.POST("/tips", RequireAuthOrAPIKey(), RequireScope(apiScopeTipsWrite), func(c *gin.Context) {
+		var body Tip
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		// A key tips as its owner.
+		body.SenderID = currentUserID(c)
+
+		if body.Amount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be > 0"})
+			return
+		}
+		if abortIfMinor(c, ageBracket(c), "tipping") {
+			return
+		}
+
+		if cfg.StripeSecretKey == "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "payments are not configured"})
+			return
+		}
+		if body.PaymentIntentID == nil || *body.PaymentIntentID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "payment_intent_id is required"})
+			return
+		}
+		quote, err := loadTipTaxQuote(context.Background(), *body.PaymentIntentID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if quote == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "payment_intent_id must come from /tips/payment-intent"})
+			return
+		}
+		if err := checkTipQuote(body, quote); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		var pi stripePaymentIntent
+		if err := stripeGet(c.Request.Context(), "/payment_intents/"+url.PathEscape(*body.PaymentIntentID), &pi); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkTipPayment(body, quote, pi); err != nil {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+			return
+		}
+
+		reasons, err := tipRiskReasons(context.Background(), body)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		status := tipStatusCleared
+		if len(reasons) > 0 {
+			status = tipStatusHeld
+		}
+
+		sql := `INSERT INTO tips (song_id, sender_id, amount, payment_intent_id, review_status, review_reasons)
+		        VALUES ($1, $2, $3, $4, $5, $6)
+		        RETURNING id, song_id, sender_id, amount, payment_intent_id, review_status, created_at;`
+
+		ctx := context.Background()
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		err = tx.QueryRow(ctx, sql,
+			body.SongID, body.SenderID, body.Amount, body.PaymentIntentID, status, reasons,
+		).Scan(&body.ID, &body.SongID, &body.SenderID, &body.Amount, &body.PaymentIntentID, &body.ReviewStatus, &body.CreatedAt)
+		if err == nil {
+			err = insertTipTaxLines(ctx, tx, body.ID, quote.Lines)
+		}
+		if err == nil && status == tipStatusCleared {
+			err = appendTipConfirmed(ctx, tx, body, "")
+		}
+		if err == nil {
+			err = tx.Commit(ctx)
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "this payment has already been used for a tip"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if status == tipStatusCleared {
+			creditTip(body)
+		}
+
+		c.JSON(http.StatusCreated, body)
+	})
+
+	// GET /me/balance — net of cleared tips less payouts and disputes, plus what's held and recent adjustments
+	r.GET("/me/balance", RequireAuth(), func(c *gin.Context) {
+		var (
+			clearedNet, heldNet, paidOut float64
+			disputed, chargedBack        float64
+			heldTips                     int64
+		)
+		err := db.QueryRow(context.Background(), `
+			WITH artist_tips AS (
+				SELECT t.review_status, t.amount::numeric - ROUND(t.amount::numeric * $2::numeric) / 100 AS net
+				FROM tips t JOIN songs s ON s.id = t.song_id
+				WHERE s.artist_id = $1
+			)
+			SELECT COALESCE(SUM(net) FILTER (WHERE review_status = 'cleared'), 0)::float8,
+			       COALESCE(SUM(net) FILTER (WHERE review_status = 'held'), 0)::float8,
+			       COUNT(*) FILTER (WHERE review_status = 'held'),
+			       (SELECT COALESCE(SUM(amount), 0)::float8 FROM payouts WHERE artist_id = $1 AND status <> 'failed'),
+			       (SELECT COALESCE(SUM(frozen_amount), 0)::float8 FROM tip_disputes WHERE artist_id = $1 AND closed_at IS NULL),
+			       (SELECT COALESCE(SUM(frozen_amount), 0)::float8 FROM tip_disputes WHERE artist_id = $1 AND status = 'lost')
+			FROM artist_tips;
+		`, currentUserID(c), cfg.PlatformFeePercent).Scan(&clearedNet, &heldNet, &heldTips, &paidOut, &disputed, &chargedBack)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		rows, err := db.Query(context.Background(), `
+			SELECT id, tip_id, artist_id::text, action, amount::float8, admin_id::text, note, created_at
+			FROM tip_ledger WHERE artist_id = $1
+			ORDER BY id DESC
+			LIMIT 50;
+		`, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		ledger := []TipLedgerEntry{}
+		for rows.Next() {
+			var e TipLedgerEntry
+			if err := rows.Scan(&e.ID, &e.TipID, &e.ArtistID, &e.Action, &e.Amount, &e.AdminID, &e.Note, &e.CreatedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			ledger = append(ledger, e)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"available_amount":    math.Round((clearedNet-paidOut-disputed-chargedBack)*100) / 100,
+			"held_amount":         math.Round(heldNet*100) / 100,
+			"held_tips":           heldTips,
+			"disputed_amount":     math.Round(disputed*100) / 100,
+			"charged_back_amount": math.Round(chargedBack*100) / 100,
+			"paid_out_amount":     math.Round(paidOut*100) / 100,
+			"adjustments":         ledger,
+		})
+	})
+
+	admin := r.Group("/admin/tips", RequireAuth(), RequireRole("admin"), RequireMFA())
+
+	// GET /admin/tips/review?status=held|cleared|denied — the held queue, or past decisions
+	admin.GET("/review", func(c *gin.Context) {
+		status := c.DefaultQuery("status", tipStatusHeld)
+		if !tipReviewStatuses[status] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be held, cleared, or denied"})
+			return
+		}
+
+		rows, err := db.Query(context.Background(), `
+			SELECT `+tipReviewColumns+`
+			FROM tips t LEFT JOIN songs s ON s.id = t.song_id
+			WHERE t.review_status = $1 AND ($1 = 'held' OR t.reviewed_at IS NOT NULL)
+			ORDER BY t.created_at
+			LIMIT $2;
+		`, status, maxTipReviewPage)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		tips := []TipReview{}
+		for rows.Next() {
+			t, err := scanTipReview(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			tips = append(tips, t)
+		}
+		c.JSON(http.StatusOK, tips)
+	})
+
+	// POST /admin/tips/:id/approve — {"note":"..."}; credits a held tip
+	admin.POST("/:id/approve", func(c *gin.Context) {
+		t, ok := reviewTip(c, tipStatusCleared)
+		if !ok {
+			return
+		}
+		creditTip(t.Tip)
+		c.JSON(http.StatusOK, t)
+	})
+
+	// POST /admin/tips/:id/deny — {"note":"..."}; drops a held tip or reverses a cleared one
+	admin.POST("/:id/deny", func(c *gin.Context) {
+		t, ok := reviewTip(c, tipStatusDenied)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, t)
+	})
+}
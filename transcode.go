@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Once a song's original passes processing, an audio_transcode job runs
+// ffmpeg once over it to produce two more renditions: "hls", an AAC HLS
+// stream whose storage key is the manifest with its segments alongside,
+// and "preview", a 128kbps MP3. Each records the original it was made from
+// in source_key, so after a re-upload they are stale until the next
+// transcode replaces them; GET /songs/:id/stream only serves current ones.
+const (
+	audioTranscodeJob = "audio_transcode"
+	hlsRendition      = "hls"
+	previewRendition  = "preview"
+
+	hlsManifestName    = "index.m3u8"
+	hlsSegmentSeconds  = 6
+	hlsBitrate         = "160k"
+	previewBitrate     = "128k"
+	transcodeLimit     = 20 * time.Minute
+	maxHLSManifestSize = 1 << 20
+	maxCachedManifests = 2000
+
+	hlsContentType = "application/vnd.apple.mpegurl"
+)
+
+type audioTranscodePayload struct {
+	SongID    int64  `json:"song_id"`
+	SourceKey string `json:"source_key"`
+}
+
+func init() {
+	RegisterJobHandler(audioTranscodeJob, runAudioTranscode)
+}
+
+// transcodeAudio writes inputURL as an HLS stream into hlsDir and as an MP3
+// preview at previewPath.
+func transcodeAudio(ctx context.Context, inputURL, hlsDir, previewPath string) error {
+	ctx, cancel := context.WithTimeout(ctx, transcodeLimit)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, cfg.FFmpegPath,
+		"-nostats", "-hide_banner", "-y", "-i", inputURL,
+		"-map", "0:a", "-vn", "-c:a", "aac", "-b:a", hlsBitrate,
+		"-f", "hls", "-hls_time", fmt.Sprint(hlsSegmentSeconds), "-hls_playlist_type", "vod",
+		"-hls_segment_filename", filepath.Join(hlsDir, "segment%04d.ts"), filepath.Join(hlsDir, hlsManifestName),
+		"-map", "0:a", "-vn", "-c:a", "libmp3lame", "-b:a", previewBitrate, "-f", "mp3", previewPath)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg: %v: %s", err, lastLines(stderr.Bytes(), 3))
+	}
+	return nil
+}
+
+// uploadFile stores the file at p under key and returns its size.
+func uploadFile(ctx context.Context, store *SpacesClient, p, key, contentType string) (int64, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), store.PutObject(ctx, key, f, info.Size(), contentType)
+}
+
+func runAudioTranscode(ctx context.Context, job *Job) (interface{}, error) {
+	var p audioTranscodePayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return nil, err
+	}
+
+	// The source key skips jobs for an original that has since been replaced.
+	orig, err := scanRendition(db.QueryRow(ctx, `
+		SELECT `+renditionColumns+` FROM song_renditions
+		WHERE song_id = $1 AND name = $2 AND storage_key = $3 AND status = 'ready';
+	`, p.SongID, originalRendition, p.SourceKey))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return gin.H{"skipped": true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if storage == nil {
+		return nil, fmt.Errorf("storage is not configured")
+	}
+	store := storageFor(orig.StorageRegion)
+
+	dir, err := os.MkdirTemp("", "transcode-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	hlsDir := filepath.Join(dir, "hls")
+	if err := os.Mkdir(hlsDir, 0o700); err != nil {
+		return nil, err
+	}
+	previewPath := filepath.Join(dir, "preview.mp3")
+
+	if err := transcodeAudio(ctx, store.PresignGet(orig.StorageKey, processingURLExpiry), hlsDir, previewPath); err != nil {
+		return nil, err
+	}
+	SetJobProgress(ctx, job.ID, 50)
+
+	// Segments go up before the manifest that points at them.
+	prefix := fmt.Sprintf("%s%d/renditions/%d/", audioKeyPrefix, p.SongID, job.ID)
+	files, err := os.ReadDir(hlsDir)
+	if err != nil {
+		return nil, err
+	}
+	var hlsSize int64
+	for _, f := range files {
+		if f.Name() == hlsManifestName {
+			continue
+		}
+		n, err := uploadFile(ctx, store, filepath.Join(hlsDir, f.Name()), prefix+"hls/"+f.Name(), "video/mp2t")
+		if err != nil {
+			return nil, err
+		}
+		hlsSize += n
+	}
+	manifestKey := prefix + "hls/" + hlsManifestName
+	n, err := uploadFile(ctx, store, filepath.Join(hlsDir, hlsManifestName), manifestKey, hlsContentType)
+	if err != nil {
+		return nil, err
+	}
+	hlsSize += n
+	previewKey := prefix + "preview.mp3"
+	previewSize, err := uploadFile(ctx, store, previewPath, previewKey, "audio/mpeg")
+	if err != nil {
+		return nil, err
+	}
+	SetJobProgress(ctx, job.ID, 90)
+
+	for _, r := range []SongRendition{
+		{Name: hlsRendition, StorageKey: manifestKey, ContentType: hlsContentType, SizeBytes: hlsSize},
+		{Name: previewRendition, StorageKey: previewKey, ContentType: "audio/mpeg", SizeBytes: previewSize},
+	} {
+		if err := replaceTranscodedRendition(ctx, orig, r); err != nil {
+			return nil, err
+		}
+	}
+	return gin.H{"hls_key": manifestKey, "preview_key": previewKey, "segments": len(files) - 1}, nil
+}
+
+// replaceTranscodedRendition records r as made from orig and deletes the
+// files of the rendition it replaces.
+func replaceTranscodedRendition(ctx context.Context, orig, r SongRendition) error {
+	var previousKey, previousRegion *string
+	db.QueryRow(ctx,
+		`SELECT storage_key, storage_region FROM song_renditions WHERE song_id = $1 AND name = $2;`,
+		orig.SongID, r.Name).Scan(&previousKey, &previousRegion)
+
+	if _, err := db.Exec(ctx, `
+		INSERT INTO song_renditions (song_id, name, storage_key, storage_region, content_type, size_bytes, status,
+		                             duration_ms, source_key, processed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, 'ready', $7, $8, now())
+		ON CONFLICT (song_id, name) DO UPDATE SET
+			storage_key = EXCLUDED.storage_key, storage_region = EXCLUDED.storage_region,
+			content_type = EXCLUDED.content_type, size_bytes = EXCLUDED.size_bytes, status = 'ready',
+			error_code = NULL, error = NULL, duration_ms = EXCLUDED.duration_ms, source_key = EXCLUDED.source_key,
+			created_at = now(), processed_at = now();
+	`, orig.SongID, r.Name, r.StorageKey, orig.StorageRegion, r.ContentType, r.SizeBytes, orig.DurationMs, orig.StorageKey); err != nil {
+		return err
+	}
+
+	if previousKey == nil || *previousKey == r.StorageKey {
+		return nil
+	}
+	store := storageFor(*previousRegion)
+	if r.Name == hlsRendition {
+		if err := deleteHLSSegments(ctx, store, *previousKey); err != nil {
+			log.Printf("song %d: failed to delete replaced HLS segments under %s: %v", orig.SongID, *previousKey, err)
+		}
+	}
+	if err := store.DeleteObject(ctx, *previousKey); err != nil {
+		log.Printf("song %d: failed to delete replaced %s rendition %s: %v", orig.SongID, r.Name, *previousKey, err)
+	}
+	return nil
+}
+
+// hlsManifest reads the manifest at key and returns its lines.
+func hlsManifest(ctx context.Context, store *SpacesClient, key string) ([]string, error) {
+	body, err := store.GetObject(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	lines := []string{}
+	sc := bufio.NewScanner(io.LimitReader(body, maxHLSManifestSize))
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	return lines, sc.Err()
+}
+
+// hlsManifestCache keeps the lines of manifests served for playback. A
+// manifest never changes at its key (each transcode writes under its own
+// job's prefix), so streams can start without a storage read and keep
+// working, with freshly signed segment URLs, while storage is unavailable.
+var hlsManifestCache = struct {
+	sync.Mutex
+	lines map[string][]string
+}{lines: map[string][]string{}}
+
+// cachedHLSManifest is hlsManifest through hlsManifestCache.
+func cachedHLSManifest(ctx context.Context, store *SpacesClient, key string) ([]string, error) {
+	id := store.Region + "/" + key
+	hlsManifestCache.Lock()
+	lines, ok := hlsManifestCache.lines[id]
+	hlsManifestCache.Unlock()
+	if ok {
+		return lines, nil
+	}
+
+	lines, err := hlsManifest(ctx, store, key)
+	if err != nil {
+		return nil, err
+	}
+	hlsManifestCache.Lock()
+	if len(hlsManifestCache.lines) >= maxCachedManifests {
+		for k := range hlsManifestCache.lines {
+			delete(hlsManifestCache.lines, k)
+			break
+		}
+	}
+	hlsManifestCache.lines[id] = lines
+	hlsManifestCache.Unlock()
+	return lines, nil
+}
+
+// isHLSURI reports whether a manifest line names a segment rather than
+// being a tag, comment, or blank.
+func isHLSURI(line string) bool {
+	line = strings.TrimSpace(line)
+	return line != "" && !strings.HasPrefix(line, "#")
+}
+
+// deleteHLSSegments deletes the segments the manifest at key lists.
+func deleteHLSSegments(ctx context.Context, store *SpacesClient, key string) error {
+	hlsManifestCache.Lock()
+	delete(hlsManifestCache.lines, store.Region+"/"+key)
+	hlsManifestCache.Unlock()
+
+	lines, err := hlsManifest(ctx, store, key)
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	dir := path.Dir(key) + "/"
+	for _, line := range lines {
+		if isHLSURI(line) {
+			if err := store.DeleteObject(ctx, dir+strings.TrimSpace(line)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// signedHLSManifest returns the manifest at key with each segment replaced
+// by a presigned URL valid for ttl, since segments aren't public.
+func signedHLSManifest(ctx context.Context, store *SpacesClient, key string, ttl time.Duration) ([]byte, error) {
+	lines, err := cachedHLSManifest(ctx, store, key)
+	if err != nil {
+		return nil, err
+	}
+	dir := path.Dir(key) + "/"
+	var b bytes.Buffer
+	for _, line := range lines {
+		if isHLSURI(line) {
+			line = store.PresignGet(dir+strings.TrimSpace(line), ttl)
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.Bytes(), nil
+}
+
+// hlsSegmentURLGrace is how long past the manifest's own lifetime segment
+// URLs stay valid: the song's length, doubled to allow for pausing.
+func hlsSegmentURLGrace(durationMs *int64) time.Duration {
+	if durationMs == nil {
+		return time.Hour
+	}
+	return 2 * time.Duration(*durationMs) * time.Millisecond
+}
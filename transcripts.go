@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Songs can carry transcripts (lyrics or spoken word, timed or not) and
+// audio descriptions, one per kind and language, for listeners using
+// captions or screen readers. Timed text is WebVTT or SRT and is checked
+// cue by cue on upload so players can rely on it; plain text is served as
+// is.
+const maxTranscriptBytes = 1 << 20
+
+var transcriptKinds = map[string]bool{"transcript": true, "description": true}
+
+// transcriptFormats maps upload content types to formats, and formats to
+// the content type served.
+var (
+	transcriptUploadTypes = map[string]string{
+		"text/vtt":             "vtt",
+		"application/x-subrip": "srt",
+		"text/srt":             "srt",
+		"text/plain":           "txt",
+	}
+	transcriptContentTypes = map[string]string{
+		"vtt": "text/vtt; charset=utf-8",
+		"srt": "application/x-subrip; charset=utf-8",
+		"txt": "text/plain; charset=utf-8",
+	}
+)
+
+var (
+	languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+	vttTimingLine   = regexp.MustCompile(`^((?:\d{2,}:)?\d{2}:\d{2}\.\d{3})[ \t]+-->[ \t]+((?:\d{2,}:)?\d{2}:\d{2}\.\d{3})(?:[ \t].*)?$`)
+	srtTimingLine   = regexp.MustCompile(`^(\d{2}:\d{2}:\d{2},\d{3}) --> (\d{2}:\d{2}:\d{2},\d{3})$`)
+)
+
+// cueMillis parses hh:mm:ss.mmm, mm:ss.mmm, or hh:mm:ss,mmm.
+func cueMillis(ts string) int64 {
+	ts = strings.Replace(ts, ",", ".", 1)
+	secs, frac, _ := strings.Cut(ts, ".")
+	parts := strings.Split(secs, ":")
+	var total int64
+	for _, p := range parts {
+		n, _ := strconv.ParseInt(p, 10, 64)
+		total = total*60 + n
+	}
+	ms, _ := strconv.ParseInt(frac, 10, 64)
+	return total*1000 + ms
+}
+
+// checkCueTiming checks a cue's end isn't before its start and that its
+// minutes and seconds are in range.
+func checkCueTiming(line int, start, end string) error {
+	for _, ts := range []string{start, end} {
+		parts := strings.Split(strings.FieldsFunc(ts, func(r rune) bool { return r == '.' || r == ',' })[0], ":")
+		for _, p := range parts[1:] {
+			if n, _ := strconv.Atoi(p); n > 59 {
+				return fmt.Errorf("line %d: %s is not a valid timestamp", line, ts)
+			}
+		}
+	}
+	if cueMillis(end) < cueMillis(start) {
+		return fmt.Errorf("line %d: cue ends before it starts", line)
+	}
+	return nil
+}
+
+// validateVTT checks the WEBVTT header and every cue timing line.
+func validateVTT(text string) error {
+	sc := bufio.NewScanner(strings.NewReader(strings.TrimPrefix(text, "\ufeff")))
+	sc.Buffer(make([]byte, 64<<10), maxTranscriptBytes)
+	if !sc.Scan() {
+		return fmt.Errorf("file is empty")
+	}
+	if header := sc.Text(); header != "WEBVTT" && !strings.HasPrefix(header, "WEBVTT ") && !strings.HasPrefix(header, "WEBVTT\t") {
+		return fmt.Errorf(`line 1: WebVTT files must start with "WEBVTT"`)
+	}
+	cues := 0
+	for line := 2; sc.Scan(); line++ {
+		l := sc.Text()
+		if !strings.Contains(l, "-->") {
+			continue
+		}
+		m := vttTimingLine.FindStringSubmatch(l)
+		if m == nil {
+			return fmt.Errorf("line %d: malformed cue timing", line)
+		}
+		if err := checkCueTiming(line, m[1], m[2]); err != nil {
+			return err
+		}
+		cues++
+	}
+	if cues == 0 {
+		return fmt.Errorf("file has no cues")
+	}
+	return sc.Err()
+}
+
+// validateSRT checks each block is an index, a timing line, and text.
+func validateSRT(text string) error {
+	lines := strings.Split(strings.ReplaceAll(strings.TrimPrefix(text, "\ufeff"), "\r\n", "\n"), "\n")
+	cues := 0
+	for i := 0; i < len(lines); {
+		if strings.TrimSpace(lines[i]) == "" {
+			i++
+			continue
+		}
+		if _, err := strconv.Atoi(strings.TrimSpace(lines[i])); err != nil {
+			return fmt.Errorf("line %d: expected a cue number", i+1)
+		}
+		if i+1 >= len(lines) {
+			return fmt.Errorf("line %d: cue has no timing", i+1)
+		}
+		m := srtTimingLine.FindStringSubmatch(strings.TrimSpace(lines[i+1]))
+		if m == nil {
+			return fmt.Errorf("line %d: malformed cue timing", i+2)
+		}
+		if err := checkCueTiming(i+2, m[1], m[2]); err != nil {
+			return err
+		}
+		if i+2 >= len(lines) || strings.TrimSpace(lines[i+2]) == "" {
+			return fmt.Errorf("line %d: cue has no text", i+3)
+		}
+		for i += 2; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+		}
+		cues++
+	}
+	if cues == 0 {
+		return fmt.Errorf("file has no cues")
+	}
+	return nil
+}
+
+func validateTranscript(format, text string) error {
+	if !utf8.ValidString(text) {
+		return fmt.Errorf("file must be UTF-8")
+	}
+	switch format {
+	case "vtt":
+		return validateVTT(text)
+	case "srt":
+		return validateSRT(text)
+	}
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("file is empty")
+	}
+	return nil
+}
+
+// loadTranscripts lists a song's transcripts and descriptions for the song
+// payload.
+func loadTranscripts(ctx context.Context, songID int64) ([]SongTranscript, error) {
+	rows, err := db.Query(ctx, `
+		SELECT kind, language, format, updated_at FROM song_transcripts
+		WHERE song_id = $1
+		ORDER BY kind, language;
+	`, songID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []SongTranscript{}
+	for rows.Next() {
+		var t SongTranscript
+		if err := rows.Scan(&t.Kind, &t.Language, &t.Format, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// transcriptParams reads ?kind= (default transcript) and ?lang=, writing
+// the error response when either is invalid.
+func transcriptParams(c *gin.Context, langRequired bool) (string, string, bool) {
+	kind := c.DefaultQuery("kind", "transcript")
+	if !transcriptKinds[kind] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be transcript or description"})
+		return "", "", false
+	}
+	lang := c.Query("lang")
+	if (lang != "" || langRequired) && !languagePattern.MatchString(lang) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lang must be a language tag such as en or pt-BR"})
+		return "", "", false
+	}
+	return kind, lang, true
+}
+
+// RegisterTranscriptRoutes defines song transcript and audio description
+// upload and serving.
+func RegisterTranscriptRoutes(r *gin.Engine) {
+	// GET /songs/:id/transcript?kind=transcript|description&lang=en — the file itself; any language when lang is omitted
+	r.GET("/songs/:id/transcript", OptionalAuth(), func(c *gin.Context) {
+		songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+			return
+		}
+		kind, lang, ok := transcriptParams(c, false)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+
+		s, err := scanSong(db.QueryRow(ctx, `SELECT `+songColumns+` FROM `+songFrom+` WHERE s.id = $1;`, songID))
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && !songVisibleTo(s, currentUserID(c))) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "song not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if s.Explicit && abortIfExplicit(c, "this song") {
+			return
+		}
+
+		var format, language, body string
+		err = db.QueryRow(ctx, `
+			SELECT format, language, body FROM song_transcripts
+			WHERE song_id = $1 AND kind = $2 AND ($3 = '' OR language = $3)
+			ORDER BY language
+			LIMIT 1;
+		`, songID, kind, lang).Scan(&format, &language, &body)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no " + kind + " for this song"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Content-Language", language)
+		c.Data(http.StatusOK, transcriptContentTypes[format], []byte(body))
+	})
+
+	// PUT /songs/:id/transcript?kind=transcript|description&lang=en — raw WebVTT (text/vtt), SRT (application/x-subrip), or text/plain body
+	r.PUT("/songs/:id/transcript", RequireAuthOrAPIKey(), RequireScope(apiScopeSongsWrite), func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "add transcripts to")
+		if !ok {
+			return
+		}
+		kind, lang, ok := transcriptParams(c, true)
+		if !ok {
+			return
+		}
+		mediaType := strings.TrimSpace(strings.SplitN(c.ContentType(), ";", 2)[0])
+		format := transcriptUploadTypes[mediaType]
+		if format == "" {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "transcripts must be text/vtt, application/x-subrip, or text/plain"})
+			return
+		}
+
+		data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxTranscriptBytes+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "could not read body"})
+			return
+		}
+		if len(data) > maxTranscriptBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "transcript is too large", "max_bytes": maxTranscriptBytes})
+			return
+		}
+		if err := validateTranscript(format, string(data)); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+
+		var t SongTranscript
+		err = db.QueryRow(context.Background(), `
+			INSERT INTO song_transcripts (song_id, kind, language, format, body)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (song_id, kind, language) DO UPDATE SET
+				format = EXCLUDED.format, body = EXCLUDED.body, updated_at = now()
+			RETURNING kind, language, format, updated_at;
+		`, songID, kind, lang, format, string(data)).Scan(&t.Kind, &t.Language, &t.Format, &t.UpdatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, t)
+	})
+
+	// DELETE /songs/:id/transcript?kind=transcript|description&lang=en
+	r.DELETE("/songs/:id/transcript", RequireAuthOrAPIKey(), RequireScope(apiScopeSongsWrite), func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "remove transcripts from")
+		if !ok {
+			return
+		}
+		kind, lang, ok := transcriptParams(c, true)
+		if !ok {
+			return
+		}
+		tag, err := db.Exec(context.Background(),
+			`DELETE FROM song_transcripts WHERE song_id = $1 AND kind = $2 AND language = $3;`, songID, kind, lang)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no " + kind + " in that language"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
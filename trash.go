@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Deleting a song or comment moves it to its owner's trash instead: it
+// disappears everywhere else at once but can be restored from GET /me/trash
+// for trashRetention. Public song queries filter on trashed_at IS NULL
+// alongside published, so a trashed song drops out of them; trashing also
+// unpublishes it and remembers whether to publish it again on restore.
+// The trash_purge job deletes items past the window for good, along with
+// the song's audio in Spaces.
+const (
+	trashRetention     = 30 * 24 * time.Hour
+	trashPurgeName     = "trash_purge"
+	trashPurgeInterval = time.Hour
+	trashPurgeBatch    = 50
+
+	trashKindSong    = "song"
+	trashKindComment = "comment"
+)
+
+// purgeTrash permanently deletes songs and comments trashed longer than
+// trashRetention. A song whose files can't be deleted is left for the next
+// run rather than orphaning them.
+func purgeTrash(ctx context.Context) error {
+	cutoff := time.Now().Add(-trashRetention)
+	if _, err := db.Exec(ctx, `DELETE FROM comments WHERE trashed_at < $1;`, cutoff); err != nil {
+		return err
+	}
+	if storage == nil {
+		return nil
+	}
+
+	rows, err := db.Query(ctx, `SELECT id FROM songs WHERE trashed_at < $1 ORDER BY id LIMIT $2;`, cutoff, trashPurgeBatch)
+	if err != nil {
+		return err
+	}
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := deleteSongFiles(ctx, id); err != nil {
+			log.Printf("song %d: purge failed to delete files: %v", id, err)
+			continue
+		}
+		if _, err := db.Exec(ctx, `DELETE FROM songs WHERE id = $1 AND trashed_at < $2;`, id, cutoff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteSongFiles deletes a song's renditions, including HLS segments, its
+// earlier audio versions, its clips, and its press previews from storage.
+// Artwork and waveforms are content-addressed and may be shared, so they
+// stay.
+func deleteSongFiles(ctx context.Context, songID int64) error {
+	renditions, err := loadRenditions(ctx, songID)
+	if err != nil {
+		return err
+	}
+	for _, r := range renditions {
+		store := storageFor(r.StorageRegion)
+		// Renditions released from a project point at the stem's own file,
+		// which stays with the project.
+		if !strings.HasPrefix(r.StorageKey, audioKeyPrefix) {
+			continue
+		}
+		if r.Name == hlsRendition {
+			if err := deleteHLSSegments(ctx, store, r.StorageKey); err != nil {
+				return err
+			}
+		}
+		if err := store.DeleteObject(ctx, r.StorageKey); err != nil {
+			return err
+		}
+	}
+
+	versions, err := db.Query(ctx,
+		`SELECT storage_key, storage_region FROM song_audio_versions WHERE song_id = $1;`, songID)
+	if err != nil {
+		return err
+	}
+	defer versions.Close()
+	for versions.Next() {
+		var key, region string
+		if err := versions.Scan(&key, &region); err != nil {
+			return err
+		}
+		if !strings.HasPrefix(key, audioKeyPrefix) {
+			continue
+		}
+		if err := storageFor(region).DeleteObject(ctx, key); err != nil {
+			return err
+		}
+	}
+	if err := versions.Err(); err != nil {
+		return err
+	}
+
+	rows, err := db.Query(ctx,
+		`SELECT storage_key, storage_region FROM clips WHERE song_id = $1 AND storage_key IS NOT NULL;`, songID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, region string
+		if err := rows.Scan(&key, &region); err != nil {
+			return err
+		}
+		if err := storageFor(region).DeleteObject(ctx, key); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	recipients, err := loadPressRecipients(ctx, songID)
+	if err != nil {
+		return err
+	}
+	for _, rc := range recipients {
+		if err := deletePressPreview(ctx, rc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterTrashRoutes defines deleting songs and comments to the trash,
+// listing it, and restoring from it.
+func RegisterTrashRoutes(r *gin.Engine) {
+	// DELETE /songs/:id — moves the caller's song to their trash
+	r.DELETE("/songs/:id", RequireAuth(), func(c *gin.Context) {
+		songID, ok := ownedSongID(c, "delete")
+		if !ok {
+			return
+		}
+		var trashedAt time.Time
+		err := db.QueryRow(c.Request.Context(), `
+			UPDATE songs SET trashed_at = now(), trashed_published = published, published = false
+			WHERE id = $1 AND trashed_at IS NULL
+			RETURNING trashed_at;
+		`, songID).Scan(&trashedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "song not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, TrashItem{Kind: trashKindSong, ID: songID, TrashedAt: trashedAt, PurgeAt: trashedAt.Add(trashRetention)})
+	})
+
+	// DELETE /comments/:id — the comment's author moves it to their trash
+	r.DELETE("/comments/:id", RequireAuth(), func(c *gin.Context) {
+		commentID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid comment id"})
+			return
+		}
+		var trashedAt time.Time
+		err = db.QueryRow(c.Request.Context(), `
+			UPDATE comments SET trashed_at = now(), pinned_at = NULL
+			WHERE id = $1 AND author_id = $2 AND trashed_at IS NULL
+			RETURNING trashed_at;
+		`, commentID, currentUserID(c)).Scan(&trashedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, TrashItem{Kind: trashKindComment, ID: commentID, TrashedAt: trashedAt, PurgeAt: trashedAt.Add(trashRetention)})
+	})
+
+	me := r.Group("/me/trash", RequireAuth())
+
+	// GET /me/trash — most recently trashed first
+	me.GET("", func(c *gin.Context) {
+		rows, err := db.Query(c.Request.Context(), `
+			SELECT 'song', id, title, trashed_at FROM songs WHERE artist_id = $1 AND trashed_at IS NOT NULL
+			UNION ALL
+			SELECT 'comment', id, body, trashed_at FROM comments WHERE author_id = $1 AND trashed_at IS NOT NULL
+			ORDER BY trashed_at DESC;
+		`, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		items := []TrashItem{}
+		for rows.Next() {
+			var t TrashItem
+			if err := rows.Scan(&t.Kind, &t.ID, &t.Label, &t.TrashedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			t.PurgeAt = t.TrashedAt.Add(trashRetention)
+			items = append(items, t)
+		}
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": items, "retention_days": int(trashRetention.Hours() / 24)})
+	})
+
+	// POST /me/trash/:kind/:id/restore — kind is song or comment
+	me.POST("/:kind/:id/restore", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		ctx := c.Request.Context()
+
+		var sql string
+		switch c.Param("kind") {
+		case trashKindSong:
+			sql = `UPDATE songs SET published = COALESCE(trashed_published, false), trashed_at = NULL, trashed_published = NULL
+				WHERE id = $1 AND artist_id = $2 AND trashed_at IS NOT NULL;`
+		case trashKindComment:
+			sql = `UPDATE comments SET trashed_at = NULL WHERE id = $1 AND author_id = $2 AND trashed_at IS NOT NULL;`
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be song or comment"})
+			return
+		}
+		tag, err := db.Exec(ctx, sql, id, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not in your trash"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"kind": c.Param("kind"), "id": id, "restored": true})
+	})
+}
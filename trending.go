@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Trending songs are ranked by a decayed engagement score: each play, tip,
+// and comment in the last trendingWindowDays counts its weight halved for
+// every trendingHalfLife since it happened, so a burst today outranks a
+// bigger one last week. The periodic trending rollup recomputes every
+// song's score from events into song_trending, and GET /songs/trending only
+// reads it. Scores all decay at the same rate, so a ranking stays correct
+// between refreshes even though the numbers age.
+const (
+	trendingRollupName     = "trending"
+	trendingRollupInterval = 10 * time.Minute
+	trendingWindowDays     = 7
+	trendingHalfLife       = 24 * time.Hour
+
+	defaultTrendingLimit = 20
+	maxTrendingLimit     = 100
+)
+
+// trendingWeights is what each engagement is worth before decay.
+var trendingWeights = map[string]float64{
+	"play":    1,
+	"comment": 3,
+	"tip":     5,
+}
+
+// rollupTrending replaces song_trending with fresh scores.
+func rollupTrending(ctx context.Context) error {
+	types := make([]string, 0, len(trendingWeights))
+	weights := make([]float64, 0, len(trendingWeights))
+	for t, w := range trendingWeights {
+		types, weights = append(types, t), append(weights, w)
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM song_trending;`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO song_trending (song_id, score, plays, tips, comments, computed_at)
+		SELECT e.song_id,
+		       SUM(w.weight * exp(-ln(2) * extract(epoch FROM now() - e.occurred_at) / $3)),
+		       COUNT(*) FILTER (WHERE e.event_type = 'play'),
+		       COUNT(*) FILTER (WHERE e.event_type = 'tip'),
+		       COUNT(*) FILTER (WHERE e.event_type = 'comment'),
+		       now()
+		FROM events e
+		JOIN unnest($1::text[], $2::float8[]) AS w (event_type, weight) ON w.event_type = e.event_type
+		JOIN songs s ON s.id = e.song_id
+		WHERE NOT e.is_bot AND e.occurred_at > now() - make_interval(days => $4)
+		GROUP BY e.song_id;
+	`, types, weights, trendingHalfLife.Seconds(), trendingWindowDays); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// trendingPage is a page of the chart and when it was computed (nil before
+// the first rollup).
+type trendingPage struct {
+	computedAt *time.Time
+	songs      []TrendingSong
+}
+
+// loadTrending reads a page of the chart, leaving out explicit songs when
+// hideExplicit is set.
+func loadTrending(ctx context.Context, hideExplicit bool, genre string, limit int) (trendingPage, error) {
+	songs, err := querySongs(ctx, `
+		SELECT `+songColumns+` FROM `+songFrom+`
+		JOIN song_trending t ON t.song_id = s.id
+		WHERE s.published AND s.trashed_at IS NULL AND NOT (s.explicit AND $1)
+		  AND ($2 = '' OR s.genre = $2)
+		ORDER BY t.score DESC, s.id DESC
+		LIMIT $3;
+	`, hideExplicit, genre, limit)
+	if err != nil {
+		return trendingPage{}, err
+	}
+
+	ids := make([]int64, len(songs))
+	for i, s := range songs {
+		ids[i] = s.ID
+	}
+	type score struct {
+		score                 float64
+		plays, tips, comments int64
+	}
+	scores := map[int64]score{}
+	var computedAt *time.Time
+	rows, err := db.Query(ctx, `
+		SELECT song_id, score, plays, tips, comments, computed_at FROM song_trending WHERE song_id = ANY ($1);
+	`, ids)
+	if err != nil {
+		return trendingPage{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id int64
+			sc score
+			at time.Time
+		)
+		if err := rows.Scan(&id, &sc.score, &sc.plays, &sc.tips, &sc.comments, &at); err != nil {
+			return trendingPage{}, err
+		}
+		scores[id] = sc
+		computedAt = &at
+	}
+	if err := rows.Err(); err != nil {
+		return trendingPage{}, err
+	}
+
+	trending := make([]TrendingSong, 0, len(songs))
+	for _, s := range songs {
+		sc := scores[s.ID]
+		trending = append(trending, TrendingSong{Song: s, Score: sc.score, Plays: sc.plays, Tips: sc.tips,
+			Comments: sc.comments})
+	}
+	return trendingPage{computedAt, trending}, nil
+}
+
+// RegisterTrendingRoutes defines the trending songs chart.
+func RegisterTrendingRoutes(r *gin.Engine) {
+	// GET /songs/trending?genre=&limit=20 — highest decayed score first
+	r.GET("/songs/trending", AllowClient(), RequireScope(apiScopeSongsRead), OptionalAuth(), func(c *gin.Context) {
+		limit := defaultTrendingLimit
+		if s := c.Query("limit"); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil || v < 1 || v > maxTrendingLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be 1-%d", maxTrendingLimit)})
+				return
+			}
+			limit = v
+		}
+		genre := strings.TrimSpace(c.Query("genre"))
+
+		hide := hideExplicit(c)
+		key := fmt.Sprintf("trending:%t:%d:%s", hide, limit, genre)
+		v, err, _ := hotReads.Do(key, func() (interface{}, error) {
+			return loadTrending(context.Background(), hide, genre, limit)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		p := v.(trendingPage)
+		c.Header("Cache-Control", "private, max-age=60")
+		c.JSON(http.StatusOK, gin.H{"computed_at": p.computedAt, "songs": p.songs})
+	})
+}
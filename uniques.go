@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Unique listeners are approximated with one HyperLogLog sketch per song per
+// UTC day. A periodic rollup folds new play events into the sketches, and
+// range queries merge the daily sketches instead of running COUNT(DISTINCT)
+// over the raw events table.
+const (
+	uniquesRollupName     = "unique_listeners"
+	uniquesRollupInterval = 5 * time.Minute
+	uniquesRollupBatch    = 50000
+)
+
+type songDay struct {
+	songID int64
+	day    time.Time
+}
+
+// rollupUniqueListeners processes events past the watermark in batches
+// until it catches up.
+func rollupUniqueListeners(ctx context.Context) error {
+	for {
+		n, err := rollupUniqueListenersBatch(ctx)
+		if err != nil {
+			return err
+		}
+		if n < uniquesRollupBatch {
+			return nil
+		}
+	}
+}
+
+func rollupUniqueListenersBatch(ctx context.Context) (int, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	lastID, err := readWatermark(ctx, tx, uniquesRollupName)
+	if err != nil {
+		return 0, err
+	}
+
+	// Anonymous listeners are identified by the client's device_id property.
+	rows, err := tx.Query(ctx, `
+		SELECT id, song_id, (occurred_at AT TIME ZONE 'UTC')::date,
+		       COALESCE(user_id::text, properties->>'device_id'),
+		       event_type = 'play' AND NOT is_bot
+		FROM events
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2;
+	`, lastID, uniquesRollupBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	sketches := map[songDay]*HLL{}
+	n := 0
+	for rows.Next() {
+		var (
+			id       int64
+			key      songDay
+			listener *string
+			counts   bool
+		)
+		if err := rows.Scan(&id, &key.songID, &key.day, &listener, &counts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		n++
+		lastID = id
+		if !counts || listener == nil {
+			continue
+		}
+
+		h, ok := sketches[key]
+		if !ok {
+			h = NewHLL()
+			sketches[key] = h
+		}
+		h.Add(*listener)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for key, h := range sketches {
+		var existing []byte
+		err := tx.QueryRow(ctx,
+			`SELECT registers FROM song_daily_uniques WHERE song_id = $1 AND day = $2 FOR UPDATE;`,
+			key.songID, key.day,
+		).Scan(&existing)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return 0, err
+		}
+		if prev, err := HLLFromBytes(existing); err == nil {
+			h.Merge(prev)
+		}
+
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO song_daily_uniques (song_id, day, registers, estimate)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (song_id, day)
+			DO UPDATE SET registers = EXCLUDED.registers, estimate = EXCLUDED.estimate, updated_at = now();
+		`, key.songID, key.day, h.Bytes(), int64(h.Count())); err != nil {
+			return 0, err
+		}
+	}
+
+	if n > 0 {
+		if err := writeWatermark(ctx, tx, uniquesRollupName, lastID); err != nil {
+			return 0, err
+		}
+	}
+	return n, tx.Commit(ctx)
+}
+
+// readWatermark returns the last processed event ID for a rollup, locking
+// the row for the rest of the transaction.
+func readWatermark(ctx context.Context, tx pgx.Tx, name string) (int64, error) {
+	var lastID int64
+	err := tx.QueryRow(ctx,
+		`SELECT last_event_id FROM rollup_watermarks WHERE name = $1 FOR UPDATE;`, name,
+	).Scan(&lastID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return lastID, err
+}
+
+func writeWatermark(ctx context.Context, tx pgx.Tx, name string, lastID int64) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO rollup_watermarks (name, last_event_id)
+		VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET last_event_id = EXCLUDED.last_event_id, updated_at = now();
+	`, name, lastID)
+	return err
+}
+
+// RegisterUniqueListenerRoutes defines the unique listener endpoint.
+func RegisterUniqueListenerRoutes(r *gin.Engine) {
+	// GET /analytics/songs/:id/uniques?from=YYYY-MM-DD&to=YYYY-MM-DD
+	r.GET("/analytics/songs/:id/uniques", func(c *gin.Context) {
+		songID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid song id"})
+			return
+		}
+
+		from, to, err := parseDateRange(c, 30, 366)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		rows, err := db.Query(context.Background(), `
+			SELECT day, registers, estimate
+			FROM song_daily_uniques
+			WHERE song_id = $1 AND day BETWEEN $2 AND $3
+			ORDER BY day;
+		`, songID, from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		type DailyUniques struct {
+			Day             string `json:"day"`
+			UniqueListeners int64  `json:"unique_listeners"`
+		}
+
+		total := NewHLL()
+		daily := []DailyUniques{}
+		for rows.Next() {
+			var (
+				day       time.Time
+				registers []byte
+				estimate  int64
+			)
+			if err := rows.Scan(&day, &registers, &estimate); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if h, err := HLLFromBytes(registers); err == nil {
+				total.Merge(h)
+			}
+			daily = append(daily, DailyUniques{Day: day.Format(dateLayout), UniqueListeners: estimate})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"song_id":          songID,
+			"from":             from.Format(dateLayout),
+			"to":               to.Format(dateLayout),
+			"unique_listeners": total.Count(),
+			"daily":            daily,
+		})
+	})
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Delegated uploads let the mobile app send a file straight to Spaces.
+// POST /uploads/token hands out a presigned form upload that storage only
+// accepts under a key prefix fresh to the token, up to the declared size,
+// for uploadTokenTTL; no long-lived credential leaves the backend and the
+// bytes never pass through it. The app then redeems the token once at POST
+// /uploads/token/complete, which checks the object and attaches it the way
+// a completed resumable upload is. Tokens that lapse unredeemed have their
+// object removed by the prune job.
+const (
+	uploadTokenPrefix = "lput_"
+	uploadTokenTTL    = 15 * time.Minute
+
+	uploadTokenPruneName     = "upload_token_prune"
+	uploadTokenPruneInterval = time.Hour
+	uploadTokenPruneBatch    = 100
+	// Redeemed tokens are kept a day for support questions.
+	uploadTokenRetention = 24 * time.Hour
+)
+
+type uploadTokenInput struct {
+	TargetType  string `json:"target_type"`
+	TargetID    int64  `json:"target_id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes"`
+}
+
+const uploadTokenColumns = `id, user_id::text, target_type, target_id, filename, content_type, max_bytes,
+	key_prefix, storage_key, storage_region, expires_at, used_at, created_at`
+
+func scanUploadToken(row pgx.Row) (UploadToken, error) {
+	var t UploadToken
+	err := row.Scan(&t.ID, &t.UserID, &t.TargetType, &t.TargetID, &t.Filename, &t.ContentType, &t.MaxBytes,
+		&t.KeyPrefix, &t.StorageKey, &t.StorageRegion, &t.ExpiresAt, &t.UsedAt, &t.CreatedAt)
+	return t, err
+}
+
+// pruneUploadTokens removes the objects of tokens that lapsed unredeemed,
+// then forgets old tokens.
+func pruneUploadTokens(ctx context.Context) error {
+	if storage == nil {
+		return nil
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT `+uploadTokenColumns+` FROM upload_tokens
+		WHERE used_at IS NULL AND expires_at < now()
+		ORDER BY id
+		LIMIT $1;
+	`, uploadTokenPruneBatch)
+	if err != nil {
+		return err
+	}
+	tokens := []UploadToken{}
+	for rows.Next() {
+		t, err := scanUploadToken(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		tokens = append(tokens, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, t := range tokens {
+		if err := storageFor(t.StorageRegion).DeleteObject(ctx, t.StorageKey); err != nil {
+			log.Printf("upload token %d: failed to delete unredeemed object %s: %v", t.ID, t.StorageKey, err)
+			continue
+		}
+		if _, err := db.Exec(ctx, `DELETE FROM upload_tokens WHERE id = $1;`, t.ID); err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Exec(ctx, `DELETE FROM upload_tokens WHERE used_at < $1;`, time.Now().Add(-uploadTokenRetention))
+	return err
+}
+
+// RegisterUploadTokenRoutes defines delegated upload tokens.
+func RegisterUploadTokenRoutes(r *gin.Engine) {
+	up := r.Group("/uploads/token", RequireAuth())
+
+	// POST /uploads/token {target_type, target_id, filename, content_type, size_bytes}
+	up.POST("", func(c *gin.Context) {
+		if storage == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is not configured"})
+			return
+		}
+
+		var body uploadTokenInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		body.ContentType = strings.TrimSpace(body.ContentType)
+		ext, ok := uploadFileExt(c, body.ContentType, body.SizeBytes)
+		if !ok {
+			return
+		}
+		prefix, name, filename, ok := uploadTargetKey(c, body.TargetType, body.TargetID, body.Filename, ext)
+		if !ok {
+			return
+		}
+
+		token, err := newOpaqueToken(uploadTokenPrefix)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		region := uploadRegion(c)
+		form, err := storageFor(region).PresignPost(prefix+name, prefix, body.ContentType, body.SizeBytes, uploadTokenTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		t, err := scanUploadToken(db.QueryRow(c.Request.Context(), `
+			INSERT INTO upload_tokens (token_hash, user_id, target_type, target_id, filename, content_type, max_bytes,
+			                           key_prefix, storage_key, storage_region, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			RETURNING `+uploadTokenColumns+`;
+		`, hashToken(token), currentUserID(c), body.TargetType, body.TargetID, filename, body.ContentType, body.SizeBytes,
+			prefix, prefix+name, region, time.Now().Add(uploadTokenTTL)))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"token": token, "upload_token": t, "upload": form})
+	})
+
+	// POST /uploads/token/complete {token} — redeems the token once the file is up
+	up.POST("/complete", func(c *gin.Context) {
+		if storage == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is not configured"})
+			return
+		}
+		var body struct {
+			Token string `json:"token"`
+		}
+		if err := c.BindJSON(&body); err != nil || !strings.HasPrefix(body.Token, uploadTokenPrefix) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+			return
+		}
+		ctx := context.Background()
+
+		t, err := scanUploadToken(db.QueryRow(ctx,
+			`SELECT `+uploadTokenColumns+` FROM upload_tokens WHERE token_hash = $1 AND user_id = $2;`,
+			hashToken(body.Token), currentUserID(c)))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "upload token not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		switch {
+		case t.UsedAt != nil:
+			c.JSON(http.StatusConflict, gin.H{"error": "upload token has already been used"})
+			return
+		case time.Now().After(t.ExpiresAt):
+			c.JSON(http.StatusGone, gin.H{"error": "upload token has expired; request a new one"})
+			return
+		}
+
+		// Storage held the upload to the policy; check anyway before attaching.
+		info, err := storageFor(t.StorageRegion).HeadObject(ctx, t.StorageKey)
+		if errors.Is(err, ErrObjectNotFound) {
+			c.JSON(http.StatusConflict, gin.H{"error": "nothing has been uploaded with this token yet"})
+			return
+		}
+		if err != nil {
+			writeStorageError(c, http.StatusBadGateway, err)
+			return
+		}
+		if info.Size > t.MaxBytes {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "uploaded file is larger than the token allows", "max_bytes": t.MaxBytes})
+			return
+		}
+
+		// Claim the token so a second redemption can't attach it again.
+		t, err = scanUploadToken(db.QueryRow(ctx, `
+			UPDATE upload_tokens SET used_at = now()
+			WHERE id = $1 AND used_at IS NULL AND expires_at > now()
+			RETURNING `+uploadTokenColumns+`;
+		`, t.ID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "upload token has already been used"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		release := func() {
+			db.Exec(ctx, `UPDATE upload_tokens SET used_at = NULL WHERE id = $1;`, t.ID)
+		}
+
+		resp := gin.H{"upload_token": t}
+		switch t.TargetType {
+		case uploadTargetSongAudio:
+			rend, jobID, err := attachSongAudio(ctx, t.TargetID, t.StorageKey, t.StorageRegion, t.ContentType, info.Size, t.UserID)
+			if err != nil {
+				release()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			resp["rendition"], resp["job_id"] = rend, jobID
+		case uploadTargetProjectStem:
+			s := ProjectStem{ProjectID: t.TargetID, UploaderID: t.UserID, Filename: t.Filename, FileKey: t.StorageKey,
+				StorageRegion: t.StorageRegion, SizeBytes: info.Size, ContentType: t.ContentType}
+			err = db.QueryRow(ctx, `
+				INSERT INTO project_stems (project_id, uploader_id, filename, file_key, storage_region, size_bytes, content_type)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+				RETURNING id, created_at;
+			`, s.ProjectID, s.UploaderID, s.Filename, s.FileKey, s.StorageRegion, s.SizeBytes, s.ContentType).Scan(&s.ID, &s.CreatedAt)
+			if err != nil {
+				release()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			resp["stem"], resp["job_id"] = s, queueStemProbe(ctx, s)
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+}
@@ -0,0 +1,629 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Resumable uploads: POST /uploads opens a Spaces multipart upload for a
+// song's audio or a project stem, the client PUTs numbered parts (and can
+// ask which have landed after a dropped connection), and POST
+// /uploads/:id/complete assembles them. The assembled object must match
+// the declared size and SHA-256 before it is attached; sessions left open
+// past uploadSessionTTL are aborted by the upload_sweep job.
+const (
+	uploadTargetSongAudio   = "song_audio"
+	uploadTargetProjectStem = "project_stem"
+
+	uploadPartSize      = 16 << 20
+	uploadSessionTTL    = 24 * time.Hour
+	uploadCompleteLease = 10 * time.Minute
+
+	uploadSweepJob      = "upload_sweep"
+	uploadSweepName     = "upload_sweep"
+	uploadSweepInterval = 15 * time.Minute
+	uploadSweepBatch    = 100
+)
+
+var sha256Hex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+type createUploadInput struct {
+	TargetType  string `json:"target_type"`
+	TargetID    int64  `json:"target_id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes"`
+	SHA256      string `json:"sha256"`
+}
+
+func init() {
+	RegisterJobHandler(uploadSweepJob, runUploadSweep)
+}
+
+const uploadSessionColumns = `id, user_id::text, target_type, target_id, filename, content_type, size_bytes, sha256,
+	part_size, part_count, storage_key, storage_region, multipart_id, status, error, assembled_at, attached_id,
+	expires_at, created_at, updated_at, completed_at`
+
+func scanUploadSession(row pgx.Row) (UploadSession, error) {
+	var u UploadSession
+	err := row.Scan(&u.ID, &u.UserID, &u.TargetType, &u.TargetID, &u.Filename, &u.ContentType, &u.SizeBytes, &u.SHA256,
+		&u.PartSize, &u.PartCount, &u.StorageKey, &u.StorageRegion, &u.MultipartID, &u.Status, &u.Error, &u.AssembledAt,
+		&u.AttachedID, &u.ExpiresAt, &u.CreatedAt, &u.UpdatedAt, &u.CompletedAt)
+	return u, err
+}
+
+// partBytes is the exact size part n of u must have; only the last part
+// may be shorter than the part size.
+func (u UploadSession) partBytes(n int) int64 {
+	if n == u.PartCount {
+		return u.SizeBytes - int64(u.PartCount-1)*u.PartSize
+	}
+	return u.PartSize
+}
+
+// loadOwnUpload loads the caller's upload session from :id, writing the
+// error response when it can't.
+func loadOwnUpload(c *gin.Context) (UploadSession, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid upload id"})
+		return UploadSession{}, false
+	}
+
+	u, err := scanUploadSession(db.QueryRow(context.Background(),
+		`SELECT `+uploadSessionColumns+` FROM upload_sessions WHERE id = $1 AND user_id = $2;`, id, currentUserID(c)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "upload not found"})
+		return UploadSession{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return UploadSession{}, false
+	}
+	return u, true
+}
+
+func loadUploadParts(ctx context.Context, sessionID int64) ([]UploadPart, error) {
+	rows, err := db.Query(ctx, `
+		SELECT part_number, size_bytes, etag, uploaded_at
+		FROM upload_parts WHERE session_id = $1 ORDER BY part_number;
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	parts := []UploadPart{}
+	for rows.Next() {
+		var p UploadPart
+		if err := rows.Scan(&p.PartNumber, &p.SizeBytes, &p.ETag, &p.UploadedAt); err != nil {
+			return nil, err
+		}
+		parts = append(parts, p)
+	}
+	return parts, rows.Err()
+}
+
+// missingParts lists the part numbers of u not in parts.
+func missingParts(u UploadSession, parts []UploadPart) []int {
+	have := map[int]bool{}
+	for _, p := range parts {
+		have[p.PartNumber] = true
+	}
+	missing := []int{}
+	for n := 1; n <= u.PartCount; n++ {
+		if !have[n] {
+			missing = append(missing, n)
+		}
+	}
+	return missing
+}
+
+// uploadStatusError explains why u can no longer take parts or be
+// completed, or returns "" if it can.
+func uploadStatusError(u UploadSession) (int, string) {
+	switch {
+	case u.Status == "completed":
+		return http.StatusConflict, "upload is already complete"
+	case u.Status == "failed":
+		return http.StatusConflict, "upload failed verification; start a new upload"
+	case u.Status == "expired" || time.Now().After(u.ExpiresAt):
+		return http.StatusGone, "upload session has expired; start a new upload"
+	}
+	return 0, ""
+}
+
+// verifyUpload checks the assembled object against the declared size and
+// SHA-256. A mismatch is returned as mismatch; err is for storage errors.
+func verifyUpload(ctx context.Context, u UploadSession) (mismatch string, err error) {
+	store := storageFor(u.StorageRegion)
+	info, err := store.HeadObject(ctx, u.StorageKey)
+	if err != nil {
+		return "", err
+	}
+	if info.Size != u.SizeBytes {
+		return fmt.Sprintf("uploaded file is %d bytes, expected %d", info.Size, u.SizeBytes), nil
+	}
+
+	body, err := store.GetObject(ctx, u.StorageKey)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return "", err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != u.SHA256 {
+		return "uploaded file's SHA-256 is " + sum + ", expected " + u.SHA256, nil
+	}
+	return "", nil
+}
+
+// failUpload marks u failed and removes the assembled object.
+func failUpload(ctx context.Context, u UploadSession, reason string) {
+	if err := storageFor(u.StorageRegion).DeleteObject(ctx, u.StorageKey); err != nil {
+		log.Printf("upload %d: failed to delete rejected object %s: %v", u.ID, u.StorageKey, err)
+	}
+	if _, err := db.Exec(ctx, `
+		UPDATE upload_sessions SET status = 'failed', error = $2, updated_at = now() WHERE id = $1;
+	`, u.ID, reason); err != nil {
+		log.Printf("upload %d: failed to record failure: %v", u.ID, err)
+	}
+}
+
+// scheduleUploadSweep queues a sweep when expired sessions are waiting and
+// no sweep is already pending.
+func scheduleUploadSweep(ctx context.Context) error {
+	if storage == nil {
+		return nil
+	}
+
+	var due bool
+	err := db.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM upload_sessions
+			WHERE status IN ('uploading', 'completing') AND expires_at < now()
+		) AND NOT EXISTS (
+			SELECT 1 FROM jobs WHERE type = $1 AND status IN ('queued', 'running')
+		);
+	`, uploadSweepJob).Scan(&due)
+	if err != nil || !due {
+		return err
+	}
+
+	_, err = EnqueueJob(ctx, uploadSweepJob, struct{}{}, "")
+	return err
+}
+
+// runUploadSweep aborts expired sessions: the multipart upload if it was
+// never assembled, else the assembled object. Sessions a complete call is
+// still working on are left until its lease runs out.
+func runUploadSweep(ctx context.Context, job *Job) (interface{}, error) {
+	if storage == nil {
+		return nil, fmt.Errorf("storage is not configured")
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT `+uploadSessionColumns+` FROM upload_sessions
+		WHERE expires_at < now()
+		  AND (status = 'uploading' OR (status = 'completing' AND updated_at < $1))
+		ORDER BY id
+		LIMIT $2;
+	`, time.Now().Add(-uploadCompleteLease), uploadSweepBatch)
+	if err != nil {
+		return nil, err
+	}
+	sessions := []UploadSession{}
+	for rows.Next() {
+		u, err := scanUploadSession(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		sessions = append(sessions, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var expired, failed int
+	for _, u := range sessions {
+		store := storageFor(u.StorageRegion)
+		if u.AssembledAt == nil {
+			err = store.AbortMultipartUpload(ctx, u.StorageKey, u.MultipartID)
+		} else {
+			// A complete call that died after attaching song audio leaves
+			// the object as one of the song's audio versions; keep it then.
+			var attached bool
+			err = db.QueryRow(ctx, `
+				SELECT EXISTS (SELECT 1 FROM song_renditions WHERE storage_key = $1)
+				    OR EXISTS (SELECT 1 FROM song_audio_versions WHERE storage_key = $1);
+			`, u.StorageKey).Scan(&attached)
+			if err == nil && !attached {
+				err = store.DeleteObject(ctx, u.StorageKey)
+			}
+		}
+		if err != nil {
+			log.Printf("upload %d: sweep failed: %v", u.ID, err)
+			failed++
+			continue
+		}
+
+		if _, err := db.Exec(ctx, `
+			UPDATE upload_sessions SET status = 'expired', updated_at = now()
+			WHERE id = $1 AND status IN ('uploading', 'completing');
+		`, u.ID); err != nil {
+			return nil, err
+		}
+		if _, err := db.Exec(ctx, `DELETE FROM upload_parts WHERE session_id = $1;`, u.ID); err != nil {
+			return nil, err
+		}
+		expired++
+	}
+	return gin.H{"expired": expired, "failed": failed}, nil
+}
+
+// uploadFileExt checks a declared audio file and returns its extension,
+// writing the error response if it isn't accepted.
+func uploadFileExt(c *gin.Context, contentType string, size int64) (string, bool) {
+	ext := audioTypes[contentType]
+	if ext == "" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "unsupported audio type", "content_type": contentType})
+		return "", false
+	}
+	if size <= 0 || size > maxAudioBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "size_bytes must be between 1 and the audio limit", "max_bytes": maxAudioBytes})
+		return "", false
+	}
+	return ext, true
+}
+
+// uploadTargetKey checks the caller may upload to the target and picks
+// where the file goes: a prefix fresh to this upload plus the name within
+// it. filename is the caller's filename cleaned up for the target. It
+// writes the error response if the target is refused.
+func uploadTargetKey(c *gin.Context, targetType string, targetID int64, filename, ext string) (prefix, name, cleaned string, ok bool) {
+	ctx := context.Background()
+	userID := currentUserID(c)
+	now := time.Now()
+	switch targetType {
+	case uploadTargetSongAudio:
+		owned, err := songOwnedBy(ctx, targetID, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return "", "", "", false
+		}
+		if !owned {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you can only upload audio to your own songs"})
+			return "", "", "", false
+		}
+		prefix = fmt.Sprintf("%s%d/%d/", audioKeyPrefix, targetID, now.UnixNano())
+		return prefix, originalRendition + "." + ext, strings.TrimSpace(filename), true
+	case uploadTargetProjectStem:
+		_, isMember, found, err := projectAccess(ctx, targetID, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return "", "", "", false
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+			return "", "", "", false
+		}
+		if !isMember {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only project members can upload stems"})
+			return "", "", "", false
+		}
+		cleaned = contestFilename(filename)
+		return fmt.Sprintf("projects/%d/stems/%d-", targetID, now.UnixNano()), cleaned, cleaned, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "target_type must be song_audio or project_stem"})
+	return "", "", "", false
+}
+
+// RegisterUploadRoutes defines the resumable upload endpoints.
+func RegisterUploadRoutes(r *gin.Engine) {
+	up := r.Group("/uploads", RequireAuth())
+
+	// POST /uploads {target_type, target_id, filename, content_type, size_bytes, sha256}
+	up.POST("", func(c *gin.Context) {
+		if storage == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is not configured"})
+			return
+		}
+
+		var body createUploadInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		body.ContentType = strings.TrimSpace(body.ContentType)
+		body.SHA256 = strings.ToLower(strings.TrimSpace(body.SHA256))
+		ext, ok := uploadFileExt(c, body.ContentType, body.SizeBytes)
+		if !ok {
+			return
+		}
+		if !sha256Hex.MatchString(body.SHA256) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sha256 must be the file's hex-encoded SHA-256"})
+			return
+		}
+
+		ctx := context.Background()
+		userID := currentUserID(c)
+		prefix, name, filename, ok := uploadTargetKey(c, body.TargetType, body.TargetID, body.Filename, ext)
+		if !ok {
+			return
+		}
+		body.Filename = filename
+		key := prefix + name
+
+		region := uploadRegion(c)
+		multipartID, err := storageFor(region).CreateMultipartUpload(ctx, key, body.ContentType)
+		if err != nil {
+			writeStorageError(c, http.StatusBadGateway, err)
+			return
+		}
+
+		partCount := int((body.SizeBytes + uploadPartSize - 1) / uploadPartSize)
+		u, err := scanUploadSession(db.QueryRow(ctx, `
+			INSERT INTO upload_sessions (user_id, target_type, target_id, filename, content_type, size_bytes, sha256,
+			                             part_size, part_count, storage_key, storage_region, multipart_id, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			RETURNING `+uploadSessionColumns+`;
+		`, userID, body.TargetType, body.TargetID, body.Filename, body.ContentType, body.SizeBytes, body.SHA256,
+			uploadPartSize, partCount, key, region, multipartID, time.Now().Add(uploadSessionTTL)))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, u)
+	})
+
+	// PUT /uploads/:id/parts/:number — raw part body of exactly the part's size;
+	// an optional Content-MD5 is checked. Re-sending a part replaces it.
+	up.PUT("/:id/parts/:number", func(c *gin.Context) {
+		if storage == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is not configured"})
+			return
+		}
+		u, ok := loadOwnUpload(c)
+		if !ok {
+			return
+		}
+		if status, msg := uploadStatusError(u); msg != "" {
+			c.JSON(status, gin.H{"error": msg})
+			return
+		}
+		if u.Status == "completing" || u.AssembledAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "parts have already been assembled; complete the upload"})
+			return
+		}
+		n, err := strconv.Atoi(c.Param("number"))
+		if err != nil || n < 1 || n > u.PartCount {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("part number must be 1-%d", u.PartCount)})
+			return
+		}
+		if want := u.partBytes(n); c.Request.ContentLength != want {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "part has the wrong size", "expected_bytes": want})
+			return
+		}
+
+		h := md5.New()
+		etag, err := storageFor(u.StorageRegion).UploadPart(context.Background(), u.StorageKey, u.MultipartID, n,
+			io.TeeReader(c.Request.Body, h), c.Request.ContentLength)
+		if err != nil {
+			writeStorageError(c, http.StatusBadGateway, err)
+			return
+		}
+		if want := c.GetHeader("Content-MD5"); want != "" && want != base64.StdEncoding.EncodeToString(h.Sum(nil)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "part does not match Content-MD5; send it again"})
+			return
+		}
+
+		var p UploadPart
+		err = db.QueryRow(context.Background(), `
+			INSERT INTO upload_parts (session_id, part_number, size_bytes, etag)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (session_id, part_number) DO UPDATE SET
+				size_bytes = EXCLUDED.size_bytes, etag = EXCLUDED.etag, uploaded_at = now()
+			RETURNING part_number, size_bytes, etag, uploaded_at;
+		`, u.ID, n, c.Request.ContentLength, etag).Scan(&p.PartNumber, &p.SizeBytes, &p.ETag, &p.UploadedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		db.Exec(context.Background(), `UPDATE upload_sessions SET updated_at = now() WHERE id = $1;`, u.ID)
+
+		c.JSON(http.StatusOK, p)
+	})
+
+	// GET /uploads/:id/status — which parts have landed, to resume from
+	up.GET("/:id/status", func(c *gin.Context) {
+		u, ok := loadOwnUpload(c)
+		if !ok {
+			return
+		}
+		parts, err := loadUploadParts(context.Background(), u.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var received int64
+		for _, p := range parts {
+			received += p.SizeBytes
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"upload":         u,
+			"parts":          parts,
+			"missing_parts":  missingParts(u, parts),
+			"received_bytes": received,
+		})
+	})
+
+	// POST /uploads/:id/complete — assembles, verifies, and attaches the file
+	up.POST("/:id/complete", func(c *gin.Context) {
+		if storage == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "storage is not configured"})
+			return
+		}
+		u, ok := loadOwnUpload(c)
+		if !ok {
+			return
+		}
+		if u.Status == "completed" {
+			c.JSON(http.StatusOK, gin.H{"upload": u})
+			return
+		}
+		if status, msg := uploadStatusError(u); msg != "" {
+			c.JSON(status, gin.H{"error": msg})
+			return
+		}
+		ctx := context.Background()
+
+		parts, err := loadUploadParts(ctx, u.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if u.AssembledAt == nil {
+			if missing := missingParts(u, parts); len(missing) > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "parts are missing", "missing_parts": missing})
+				return
+			}
+		}
+
+		// Claim the session so concurrent calls don't assemble or attach it
+		// twice. A claim older than uploadCompleteLease is presumed dead.
+		u, err = scanUploadSession(db.QueryRow(ctx, `
+			UPDATE upload_sessions SET status = 'completing', updated_at = now()
+			WHERE id = $1 AND expires_at > now()
+			  AND (status = 'uploading' OR (status = 'completing' AND updated_at < $2))
+			RETURNING `+uploadSessionColumns+`;
+		`, u.ID, time.Now().Add(-uploadCompleteLease)))
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "upload is already being completed"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		release := func() {
+			db.Exec(ctx, `UPDATE upload_sessions SET status = 'uploading', updated_at = now() WHERE id = $1;`, u.ID)
+		}
+
+		if u.AssembledAt == nil {
+			etags := make([]string, len(parts))
+			for i, p := range parts {
+				etags[i] = p.ETag
+			}
+			if err := storageFor(u.StorageRegion).CompleteMultipartUpload(ctx, u.StorageKey, u.MultipartID, etags); err != nil {
+				release()
+				writeStorageError(c, http.StatusBadGateway, err)
+				return
+			}
+			if _, err := db.Exec(ctx, `UPDATE upload_sessions SET assembled_at = now() WHERE id = $1;`, u.ID); err != nil {
+				release()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		mismatch, err := verifyUpload(ctx, u)
+		if err != nil {
+			release()
+			writeStorageError(c, http.StatusBadGateway, err)
+			return
+		}
+		if mismatch != "" {
+			failUpload(ctx, u, mismatch)
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": mismatch})
+			return
+		}
+
+		// Re-attaching song audio after a failure below is harmless: the
+		// rendition already points at this key, so nothing is deleted.
+		resp := gin.H{}
+		if u.TargetType == uploadTargetSongAudio {
+			rend, jobID, err := attachSongAudio(ctx, u.TargetID, u.StorageKey, u.StorageRegion, u.ContentType, u.SizeBytes, u.UserID)
+			if err != nil {
+				release()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			resp["rendition"], resp["job_id"] = rend, jobID
+		}
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			release()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		var (
+			attachedID *int64
+			stem       *ProjectStem
+		)
+		if u.TargetType == uploadTargetProjectStem {
+			s := ProjectStem{ProjectID: u.TargetID, UploaderID: u.UserID, Filename: u.Filename, FileKey: u.StorageKey,
+				StorageRegion: u.StorageRegion, SizeBytes: u.SizeBytes, ContentType: u.ContentType}
+			err = tx.QueryRow(ctx, `
+				INSERT INTO project_stems (project_id, uploader_id, filename, file_key, storage_region, size_bytes, content_type)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+				RETURNING id, created_at;
+			`, s.ProjectID, s.UploaderID, s.Filename, s.FileKey, s.StorageRegion, s.SizeBytes, s.ContentType).Scan(&s.ID, &s.CreatedAt)
+			if err != nil {
+				release()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			attachedID = &s.ID
+			resp["stem"] = s
+			stem = &s
+		}
+
+		u, err = scanUploadSession(tx.QueryRow(ctx, `
+			UPDATE upload_sessions
+			SET status = 'completed', attached_id = $2, completed_at = now(), updated_at = now()
+			WHERE id = $1
+			RETURNING `+uploadSessionColumns+`;
+		`, u.ID, attachedID))
+		if err != nil {
+			release()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := tx.Commit(ctx); err != nil {
+			release()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		db.Exec(ctx, `DELETE FROM upload_parts WHERE session_id = $1;`, u.ID)
+		if stem != nil {
+			resp["job_id"] = queueStemProbe(ctx, *stem)
+		}
+
+		resp["upload"] = u
+		c.JSON(http.StatusOK, resp)
+	})
+}
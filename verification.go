@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Artists apply for the verified badge with links (streaming profiles,
+// socials, press) and a note; an admin approves or rejects each
+// application. Approval sets profiles.verified, which profile and song
+// payloads surface. A user has at most one pending application.
+const maxVerificationEvidenceLength = 2000
+
+const verificationColumns = `id, user_id::text, links, evidence, status, reviewed_by::text, review_note, reviewed_at, created_at`
+
+func scanVerificationApplication(row pgx.Row) (VerificationApplication, error) {
+	var a VerificationApplication
+	var links []byte
+	err := row.Scan(&a.ID, &a.UserID, &links, &a.Evidence, &a.Status, &a.ReviewedBy, &a.ReviewNote, &a.ReviewedAt, &a.CreatedAt)
+	if err != nil {
+		return a, err
+	}
+	a.Links = []ProfileLink{}
+	json.Unmarshal(links, &a.Links)
+	return a, nil
+}
+
+// RegisterVerificationRoutes defines verified-artist applications and the
+// admin review queue.
+func RegisterVerificationRoutes(r *gin.Engine) {
+	// POST /me/verification {"links":[{"label","url"}],"evidence":"..."}
+	r.POST("/me/verification", RequireAuth(), func(c *gin.Context) {
+		var body struct {
+			Links    []ProfileLink `json:"links"`
+			Evidence string        `json:"evidence"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+		if len(body.Links) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "add at least one link that shows your work"})
+			return
+		}
+		links, err := normalizeLinks(body.Links)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		evidence := strings.TrimSpace(body.Evidence)
+		if utf8.RuneCountInString(evidence) > maxVerificationEvidenceLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "evidence is too long", "max_length": maxVerificationEvidenceLength})
+			return
+		}
+
+		ctx := context.Background()
+		var verified bool
+		err = db.QueryRow(ctx, `SELECT verified FROM profiles WHERE id = $1;`, currentUserID(c)).Scan(&verified)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "profile not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if verified {
+			c.JSON(http.StatusConflict, gin.H{"error": "you are already verified"})
+			return
+		}
+
+		linksJSON, _ := json.Marshal(links)
+		a, err := scanVerificationApplication(db.QueryRow(ctx, `
+			INSERT INTO verification_applications (user_id, links, evidence)
+			VALUES ($1, $2, NULLIF($3, ''))
+			RETURNING `+verificationColumns+`;
+		`, currentUserID(c), linksJSON, evidence))
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				c.JSON(http.StatusConflict, gin.H{"error": "you already have an application under review"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, a)
+	})
+
+	// GET /me/verification — your applications, newest first
+	r.GET("/me/verification", RequireAuth(), func(c *gin.Context) {
+		rows, err := db.Query(context.Background(), `
+			SELECT `+verificationColumns+` FROM verification_applications
+			WHERE user_id = $1
+			ORDER BY id DESC;
+		`, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		apps := []VerificationApplication{}
+		for rows.Next() {
+			a, err := scanVerificationApplication(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			apps = append(apps, a)
+		}
+		c.JSON(http.StatusOK, apps)
+	})
+
+	admin := r.Group("/admin/verification", RequireAuth(), RequireRole("admin"), RequireMFA())
+
+	// GET /admin/verification?status=pending — oldest first
+	admin.GET("", func(c *gin.Context) {
+		status := c.DefaultQuery("status", "pending")
+		if status != "pending" && status != "approved" && status != "rejected" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, approved, or rejected"})
+			return
+		}
+
+		rows, err := db.Query(context.Background(), `
+			SELECT `+verificationColumns+` FROM verification_applications
+			WHERE status = $1
+			ORDER BY id
+			LIMIT 200;
+		`, status)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		apps := []VerificationApplication{}
+		for rows.Next() {
+			a, err := scanVerificationApplication(rows)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			apps = append(apps, a)
+		}
+		c.JSON(http.StatusOK, apps)
+	})
+
+	// POST /admin/verification/:id/approve|reject — {"note":"..."}
+	decide := func(outcome string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid application id"})
+				return
+			}
+			var body struct {
+				Note string `json:"note"`
+			}
+			if c.Request.ContentLength > 0 {
+				if err := c.BindJSON(&body); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+					return
+				}
+			}
+			ctx := context.Background()
+
+			tx, err := db.Begin(ctx)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			defer tx.Rollback(ctx)
+
+			a, err := scanVerificationApplication(tx.QueryRow(ctx, `
+				UPDATE verification_applications
+				SET status = $2, reviewed_by = $3, review_note = NULLIF($4, ''), reviewed_at = now()
+				WHERE id = $1 AND status = 'pending'
+				RETURNING `+verificationColumns+`;
+			`, id, outcome, currentUserID(c), strings.TrimSpace(body.Note)))
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "no pending application with that id"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if outcome == "approved" {
+				if _, err := tx.Exec(ctx,
+					`UPDATE profiles SET verified = true, verified_at = now() WHERE id = $1;`, a.UserID); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+			}
+			if err := tx.Commit(ctx); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			title, msg := "You're verified", "Your profile now shows the verified badge."
+			if outcome == "rejected" {
+				title, msg = "Verification not approved", "We couldn't verify your artist profile this time. You can apply again with more links."
+				if a.ReviewNote != nil {
+					msg += " Note from the reviewer: " + *a.ReviewNote
+				}
+			}
+			if err := notify(ctx, a.UserID, "verification_"+outcome, title, msg, gin.H{"application_id": a.ID}); err != nil {
+				log.Printf("verification %d: failed to notify applicant: %v", a.ID, err)
+			}
+			c.JSON(http.StatusOK, a)
+		}
+	}
+	admin.POST("/:id/approve", decide("approved"))
+	admin.POST("/:id/reject", decide("rejected"))
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Artists register HTTPS endpoints to receive account events. Each event
+// becomes one webhook_deliveries row per subscribed endpoint, delivered by
+// the job queue (and retried with it). Requests carry a
+// "Leep-Signature: t=<unix>,v1=<hex>" header, the HMAC-SHA256 of
+// "<t>.<body>" under the endpoint's secret.
+const (
+	webhookDeliveryJob     = "webhook_delivery"
+	webhookDeliveryTimeout = 10 * time.Second
+	maxWebhooksPerUser     = 10
+)
+
+// webhookEventTypes are the events an endpoint may subscribe to.
+var webhookEventTypes = map[string]bool{
+	"tip.confirmed":    true,
+	"payout.initiated": true,
+	"payout.paid":      true,
+	"payout.failed":    true,
+}
+
+type createWebhookInput struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type webhookDeliveryPayload struct {
+	DeliveryID int64 `json:"delivery_id"`
+}
+
+// webhookHTTP calls user-supplied URLs. Its dialer refuses internal
+// addresses at connect time, so a host that resolved to a public address
+// when it was registered can't be re-pointed at one later (DNS rebinding),
+// and neither can a redirect. It ignores proxy settings, which would hide
+// the address dialed.
+var webhookHTTP = func() *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = (&net.Dialer{
+		Timeout: webhookDeliveryTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || internalIP(ip) {
+				return fmt.Errorf("refusing to connect to internal address %s", host)
+			}
+			return nil
+		},
+	}).DialContext
+	return &http.Client{Timeout: webhookDeliveryTimeout, Transport: t}
+}()
+
+// sharedAddressSpace is carrier-grade NAT (RFC 6598), internal to some
+// hosting networks.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// internalIP reports whether ip is one webhooks must not reach: loopback,
+// private (RFC 1918 and IPv6 ULA), link-local (including the
+// 169.254.169.254 cloud metadata service), unspecified, multicast, or
+// shared address space.
+func internalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// checkWebhookURL parses raw as an absolute https URL whose host resolves
+// only to public addresses. field names it in the error.
+func checkWebhookURL(ctx context.Context, field, raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("%s must be an absolute https URL", field)
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return nil, fmt.Errorf("%s host %q does not resolve", field, u.Hostname())
+	}
+	for _, a := range addrs {
+		if internalIP(a.IP) {
+			return nil, fmt.Errorf("%s must not point to a private or internal address", field)
+		}
+	}
+	return u, nil
+}
+
+func init() {
+	RegisterJobHandler(webhookDeliveryJob, runWebhookDelivery)
+}
+
+// emitWebhookEvent queues delivery of an event to every enabled endpoint of
+// userID subscribed to eventType.
+func emitWebhookEvent(ctx context.Context, userID, eventType string, data interface{}) error {
+	rows, err := db.Query(ctx, `
+		SELECT id FROM artist_webhooks
+		WHERE user_id = $1 AND enabled AND (cardinality(events) = 0 OR $2 = ANY(events));
+	`, userID, eventType)
+	if err != nil {
+		return err
+	}
+	var webhookIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		webhookIDs = append(webhookIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, webhookID := range webhookIDs {
+		var deliveryID int64
+		err := db.QueryRow(ctx, `
+			INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+			VALUES ($1, $2, '{}')
+			RETURNING id;
+		`, webhookID, eventType).Scan(&deliveryID)
+		if err != nil {
+			return err
+		}
+
+		// The envelope includes the delivery ID so receivers can dedupe retries.
+		payload, err := json.Marshal(gin.H{
+			"id":         deliveryID,
+			"type":       eventType,
+			"created_at": time.Now().UTC(),
+			"data":       data,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec(ctx, `UPDATE webhook_deliveries SET payload = $2 WHERE id = $1;`, deliveryID, payload); err != nil {
+			return err
+		}
+
+		if _, err := EnqueueJob(ctx, webhookDeliveryJob, webhookDeliveryPayload{DeliveryID: deliveryID}, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// signWebhook returns the Leep-Signature header value for body.
+func signWebhook(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", ts)
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", ts, hex.EncodeToString(mac.Sum(nil)))
+}
+
+func runWebhookDelivery(ctx context.Context, job *Job) (interface{}, error) {
+	var p webhookDeliveryPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return nil, err
+	}
+
+	var (
+		eventType, hookURL, secret string
+		payload                    []byte
+		enabled                    bool
+	)
+	err := db.QueryRow(ctx, `
+		SELECT d.event_type, d.payload, w.url, w.secret, w.enabled
+		FROM webhook_deliveries d
+		JOIN artist_webhooks w ON w.id = d.webhook_id
+		WHERE d.id = $1;
+	`, p.DeliveryID).Scan(&eventType, &payload, &hookURL, &secret, &enabled)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !enabled) {
+		// The endpoint was deleted or disabled after the event was queued.
+		return gin.H{"skipped": true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hookURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Leep-Webhooks/1.0")
+	req.Header.Set("Leep-Event", eventType)
+	req.Header.Set("Leep-Delivery", strconv.FormatInt(p.DeliveryID, 10))
+	req.Header.Set("Leep-Signature", signWebhook(secret, time.Now().Unix(), payload))
+
+	var status *int
+	resp, err := webhookHTTP.Do(req)
+	if err == nil {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		status = &resp.StatusCode
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			err = fmt.Errorf("endpoint returned %d", resp.StatusCode)
+		}
+	}
+
+	if err == nil {
+		_, dbErr := db.Exec(ctx, `
+			UPDATE webhook_deliveries
+			SET status = 'delivered', attempts = attempts + 1, response_status = $2,
+			    last_error = NULL, delivered_at = now()
+			WHERE id = $1;
+		`, p.DeliveryID, status)
+		return gin.H{"response_status": *status}, dbErr
+	}
+
+	finalStatus := "pending"
+	if job.Attempts >= jobMaxAttempts {
+		finalStatus = "failed"
+	}
+	if _, dbErr := db.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1, response_status = $3, last_error = $4
+		WHERE id = $1;
+	`, p.DeliveryID, finalStatus, status, err.Error()); dbErr != nil {
+		log.Printf("webhook delivery %d: failed to record attempt: %v", p.DeliveryID, dbErr)
+	}
+	return nil, err
+}
+
+// RegisterWebhookRoutes defines /me/webhooks for managing endpoints and
+// inspecting deliveries.
+func RegisterWebhookRoutes(r *gin.Engine) {
+	me := r.Group("/me/webhooks", RequireAuth())
+
+	// GET /me/webhooks
+	me.GET("", func(c *gin.Context) {
+		rows, err := db.Query(context.Background(), `
+			SELECT id, user_id, url, events, enabled, created_at
+			FROM artist_webhooks WHERE user_id = $1 ORDER BY id;
+		`, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		webhooks := []ArtistWebhook{}
+		for rows.Next() {
+			var w ArtistWebhook
+			if err := rows.Scan(&w.ID, &w.UserID, &w.URL, &w.Events, &w.Enabled, &w.CreatedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			webhooks = append(webhooks, w)
+		}
+		c.JSON(http.StatusOK, webhooks)
+	})
+
+	// POST /me/webhooks — {"url":"https://...","events":["payout.paid"]}; the secret is only returned here
+	me.POST("", func(c *gin.Context) {
+		var body createWebhookInput
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON"})
+			return
+		}
+
+		u, err := checkWebhookURL(c.Request.Context(), "url", body.URL)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if body.Events == nil {
+			body.Events = []string{}
+		}
+		for _, e := range body.Events {
+			if !webhookEventTypes[e] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported event %q", e)})
+				return
+			}
+		}
+
+		var count int
+		if err := db.QueryRow(context.Background(),
+			`SELECT COUNT(*) FROM artist_webhooks WHERE user_id = $1;`, currentUserID(c),
+		).Scan(&count); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if count >= maxWebhooksPerUser {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("at most %d webhooks per account", maxWebhooksPerUser)})
+			return
+		}
+
+		secret, err := newOpaqueToken("whsec_")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var w ArtistWebhook
+		err = db.QueryRow(context.Background(), `
+			INSERT INTO artist_webhooks (user_id, url, secret, events)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, user_id, url, events, enabled, created_at;
+		`, currentUserID(c), u.String(), secret, body.Events,
+		).Scan(&w.ID, &w.UserID, &w.URL, &w.Events, &w.Enabled, &w.CreatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"webhook": w, "secret": secret})
+	})
+
+	// DELETE /me/webhooks/:id
+	me.DELETE("/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook id"})
+			return
+		}
+
+		tag, err := db.Exec(context.Background(),
+			`DELETE FROM artist_webhooks WHERE id = $1 AND user_id = $2;`, id, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	// GET /me/webhooks/:id/deliveries — the 100 most recent
+	me.GET("/:id/deliveries", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook id"})
+			return
+		}
+
+		rows, err := db.Query(context.Background(), `
+			SELECT d.id, d.webhook_id, d.event_type, d.payload, d.status, d.attempts,
+			       d.response_status, d.last_error, d.created_at, d.delivered_at
+			FROM webhook_deliveries d
+			JOIN artist_webhooks w ON w.id = d.webhook_id
+			WHERE d.webhook_id = $1 AND w.user_id = $2
+			ORDER BY d.id DESC
+			LIMIT 100;
+		`, id, currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		deliveries := []WebhookDelivery{}
+		for rows.Next() {
+			var d WebhookDelivery
+			if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+				&d.ResponseStatus, &d.LastError, &d.CreatedAt, &d.DeliveredAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			deliveries = append(deliveries, d)
+		}
+		c.JSON(http.StatusOK, deliveries)
+	})
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Every active listener gets a weekly "Fans like you" playlist: songs by
+// artists the similarity graph puts next to the ones they play most, that
+// they haven't played yet, at most two per artist. Weeks start Monday UTC;
+// the hourly check queues a weekly_playlists job while any listener active
+// in the last weeklyActiveDays lacks this week's playlist, so new weeks and
+// newly active listeners are both picked up. Playlists are kept a few weeks
+// and the newest is served at GET /me/weekly.
+const (
+	weeklyPlaylistKind     = "weekly"
+	weeklyPlaylistTitle    = "Fans like you"
+	weeklyPlaylistJob      = "weekly_playlists"
+	weeklyPlaylistName     = "weekly_playlists"
+	weeklyPlaylistInterval = time.Hour
+
+	weeklyActiveDays     = 28
+	weeklyPopularDays    = 28
+	weeklyTasteDays      = 90
+	weeklySeedArtists    = 20
+	weeklyPlaylistSize   = 30
+	weeklySongsPerArtist = 2
+	weeklyBatch          = 200
+	weeklyKeepWeeks      = 4
+)
+
+func init() {
+	RegisterJobHandler(weeklyPlaylistJob, runWeeklyPlaylists)
+}
+
+// weekStart is the Monday (UTC) of t's week.
+func weekStart(t time.Time) time.Time {
+	t = t.UTC().Truncate(24 * time.Hour)
+	return t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+}
+
+// weeklyPending selects listeners active in the last $1 days without a
+// playlist for week $2.
+const weeklyPending = `
+	SELECT DISTINCT e.user_id::text FROM events e
+	WHERE e.event_type = 'play' AND NOT e.is_bot AND e.user_id IS NOT NULL
+	  AND e.occurred_at > now() - make_interval(days => $1)
+	  AND NOT EXISTS (
+		SELECT 1 FROM system_playlists p
+		WHERE p.user_id = e.user_id AND p.kind = '` + weeklyPlaylistKind + `' AND p.week_start = $2
+	  )`
+
+func scheduleWeeklyPlaylists(ctx context.Context) error {
+	var due bool
+	err := db.QueryRow(ctx, `
+		SELECT EXISTS (`+weeklyPending+`) AND NOT EXISTS (
+			SELECT 1 FROM jobs WHERE type = $3 AND status IN ('queued', 'running')
+		);
+	`, weeklyActiveDays, weekStart(time.Now()), weeklyPlaylistJob).Scan(&due)
+	if err != nil || !due {
+		return err
+	}
+	_, err = EnqueueJob(ctx, weeklyPlaylistJob, struct{}{}, "")
+	return err
+}
+
+// runWeeklyPlaylists generates this week's playlist for every listener
+// still missing one, then drops playlists past weeklyKeepWeeks.
+func runWeeklyPlaylists(ctx context.Context, job *Job) (interface{}, error) {
+	week := weekStart(time.Now())
+	generated := 0
+	for {
+		rows, err := db.Query(ctx, weeklyPending+` LIMIT $3;`, weeklyActiveDays, week, weeklyBatch)
+		if err != nil {
+			return nil, err
+		}
+		users := []string{}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			users = append(users, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		if len(users) == 0 {
+			break
+		}
+
+		for _, id := range users {
+			if err := generateWeeklyPlaylist(ctx, id, week); err != nil {
+				return nil, err
+			}
+		}
+		generated += len(users)
+	}
+
+	tag, err := db.Exec(ctx, `DELETE FROM system_playlists WHERE kind = $1 AND week_start < $2;`,
+		weeklyPlaylistKind, week.AddDate(0, 0, -7*weeklyKeepWeeks))
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{"week_start": week.Format("2006-01-02"), "generated": generated, "pruned": tag.RowsAffected()}, nil
+}
+
+// generateWeeklyPlaylist (re)builds userID's playlist for week. A listener
+// with nothing to recommend still gets an empty one, so they aren't retried
+// every hour.
+func generateWeeklyPlaylist(ctx context.Context, userID string, week time.Time) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var playlistID int64
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO system_playlists (user_id, kind, title, week_start)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, kind, week_start) DO UPDATE SET generated_at = now()
+		RETURNING id;
+	`, userID, weeklyPlaylistKind, weeklyPlaylistTitle, week).Scan(&playlistID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM system_playlist_songs WHERE playlist_id = $1;`, playlistID); err != nil {
+		return err
+	}
+
+	// Seeds are the listener's most played artists, weighted by log plays so
+	// one obsession doesn't crowd out the rest. Candidates are their
+	// neighbours in the similarity graph, credited to the seed that
+	// contributed most; each candidate's most played recent songs come first.
+	if _, err := tx.Exec(ctx, `
+		WITH heard AS (
+			SELECT e.song_id, s.artist_id FROM events e
+			JOIN songs s ON s.id = e.song_id
+			WHERE e.user_id = $2 AND e.event_type = 'play' AND NOT e.is_bot
+			  AND e.occurred_at > now() - make_interval(days => $3)
+		), taste AS (
+			SELECT artist_id, ln(1 + COUNT(*)) AS weight FROM heard
+			WHERE artist_id IS NOT NULL
+			GROUP BY artist_id
+			ORDER BY COUNT(*) DESC, artist_id
+			LIMIT $4
+		), candidates AS (
+			SELECT sim.similar_artist_id AS artist_id, SUM(sim.score * t.weight) AS score,
+			       (array_agg(sim.artist_id ORDER BY sim.score * t.weight DESC))[1] AS because
+			FROM artist_similarity sim
+			JOIN taste t ON t.artist_id = sim.artist_id
+			WHERE sim.similar_artist_id NOT IN (SELECT artist_id FROM taste)
+			  AND sim.similar_artist_id <> $2
+			GROUP BY sim.similar_artist_id
+		), picks AS (
+			SELECT s.id, c.because, c.score,
+			       row_number() OVER (PARTITION BY s.artist_id ORDER BY COALESCE(st.plays, 0) DESC, s.id DESC) AS rn
+			FROM songs s
+			JOIN candidates c ON c.artist_id = s.artist_id
+			LEFT JOIN (
+				SELECT song_id, SUM(plays) AS plays FROM song_daily_stats
+				WHERE day > (now() AT TIME ZONE 'UTC')::date - $5::int
+				GROUP BY song_id
+			) st ON st.song_id = s.id
+			WHERE s.published AND s.trashed_at IS NULL
+			  AND s.id NOT IN (SELECT song_id FROM heard)
+		)
+		INSERT INTO system_playlist_songs (playlist_id, position, song_id, because_artist_id)
+		SELECT $1, row_number() OVER (ORDER BY rn, score DESC, id), id, because FROM (
+			SELECT * FROM picks WHERE rn <= $6 ORDER BY rn, score DESC, id LIMIT $7
+		) chosen;
+	`, playlistID, userID, weeklyTasteDays, weeklySeedArtists, weeklyPopularDays, weeklySongsPerArtist,
+		weeklyPlaylistSize); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// RegisterWeeklyPlaylistRoutes defines the caller's weekly playlist.
+func RegisterWeeklyPlaylistRoutes(r *gin.Engine) {
+	// GET /me/weekly — this week's "Fans like you" playlist, or last week's until the new one is ready
+	r.GET("/me/weekly", RequireAuth(), func(c *gin.Context) {
+		ctx := c.Request.Context()
+		var (
+			p    SystemPlaylist
+			week time.Time
+		)
+		err := db.QueryRow(ctx, `
+			SELECT id, kind, title, week_start, generated_at FROM system_playlists
+			WHERE user_id = $1 AND kind = $2
+			ORDER BY week_start DESC LIMIT 1;
+		`, currentUserID(c), weeklyPlaylistKind).Scan(&p.ID, &p.Kind, &p.Title, &week, &p.GeneratedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "your weekly playlist isn't ready yet; keep listening and check back"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		p.WeekStart = week.Format("2006-01-02")
+		p.RefreshesAt = weekStart(time.Now()).AddDate(0, 0, 7)
+
+		because := map[int64]*string{}
+		rows, err := db.Query(ctx,
+			`SELECT song_id, because_artist_id::text FROM system_playlist_songs WHERE playlist_id = $1;`, p.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for rows.Next() {
+			var (
+				songID int64
+				artist *string
+			)
+			if err := rows.Scan(&songID, &artist); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			because[songID] = artist
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Songs unpublished or trashed since generation drop out.
+		songs, err := querySongs(ctx, `
+			SELECT `+songColumns+` FROM `+songFrom+`
+			JOIN system_playlist_songs ps ON ps.song_id = s.id
+			WHERE ps.playlist_id = $1 AND s.published AND s.trashed_at IS NULL AND NOT (s.explicit AND $2)
+			ORDER BY ps.position;
+		`, p.ID, hideExplicit(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		p.Songs = make([]SystemPlaylistSong, 0, len(songs))
+		for _, s := range songs {
+			p.Songs = append(p.Songs, SystemPlaylistSong{Song: s, BecauseArtistID: because[s.ID]})
+		}
+		c.Header("Cache-Control", "private, max-age=300")
+		c.JSON(http.StatusOK, p)
+	})
+}